@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path"
 	goruntime "runtime"
 
 	"github.com/blang/semver/v4"
@@ -240,7 +241,7 @@ func Run(ctx context.Context, cc *schedulerserverconfig.CompletedConfig, sched *
 
 	// Start up the healthz server.
 	if cc.SecureServing != nil {
-		handler := buildHandlerChain(newHealthEndpointsAndMetricsHandler(&cc.ComponentConfig, cc.InformerFactory, isLeader, checks, readyzChecks), cc.Authentication.Authenticator, cc.Authorization.Authorizer)
+		handler := buildHandlerChain(newHealthEndpointsAndMetricsHandler(&cc.ComponentConfig, cc.InformerFactory, sched.Profiles, isLeader, checks, readyzChecks), cc.Authentication.Authenticator, cc.Authorization.Authorizer)
 		// TODO: handle stoppedCh and listenerStoppedCh returned by c.SecureServing.Serve
 		if _, _, err := cc.SecureServing.Serve(handler, 0, ctx.Done()); err != nil {
 			// fail early for secure handlers, removing the old error loop from above
@@ -349,7 +350,7 @@ func installMetricHandler(pathRecorderMux *mux.PathRecorderMux, informers inform
 // newHealthEndpointsAndMetricsHandler creates an API health server from the config, and will also
 // embed the metrics handler.
 // TODO: healthz check is deprecated, please use livez and readyz instead. Will be removed in the future.
-func newHealthEndpointsAndMetricsHandler(config *kubeschedulerconfig.KubeSchedulerConfiguration, informers informers.SharedInformerFactory, isLeader func() bool, healthzChecks, readyzChecks []healthz.HealthChecker) http.Handler {
+func newHealthEndpointsAndMetricsHandler(config *kubeschedulerconfig.KubeSchedulerConfiguration, informers informers.SharedInformerFactory, profiles profile.Map, isLeader func() bool, healthzChecks, readyzChecks []healthz.HealthChecker) http.Handler {
 	pathRecorderMux := mux.NewPathRecorderMux("kube-scheduler")
 	healthz.InstallHandler(pathRecorderMux, healthzChecks...)
 	healthz.InstallLivezHandler(pathRecorderMux)
@@ -363,10 +364,21 @@ func newHealthEndpointsAndMetricsHandler(config *kubeschedulerconfig.KubeSchedul
 			goruntime.SetBlockProfileRate(1)
 		}
 		routes.DebugFlags{}.Install(pathRecorderMux, "v", routes.StringFlagPutHandler(logs.GlogSetter))
+		installPluginDebugHandlers(pathRecorderMux, profiles)
 	}
 	return pathRecorderMux
 }
 
+// installPluginDebugHandlers makes the debug handlers of plugins available
+// under /debug/plugins/<profile name>/<plugin name>.
+func installPluginDebugHandlers(pathRecorderMux *mux.PathRecorderMux, profiles profile.Map) {
+	for profileName, fwk := range profiles {
+		for pluginName, handler := range fwk.DebugHandlers() {
+			pathRecorderMux.Handle(path.Join("/debug/plugins", profileName, pluginName), handler)
+		}
+	}
+}
+
 func getRecorderFactory(cc *schedulerserverconfig.CompletedConfig) profile.RecorderFactory {
 	return func(name string) events.EventRecorder {
 		return cc.EventBroadcaster.NewRecorder(name)
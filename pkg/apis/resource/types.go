@@ -215,11 +215,30 @@ type BasicDevice struct {
 	//
 	// +optional
 	Capacity map[QualifiedName]resource.Quantity
+
+	// Taints are the taints of the device. A device with a taint
+	// only gets allocated for a pod which tolerates the taint, see
+	// pod.spec.tolerations. This is useful for devices which should
+	// not be used by default, for example because they are
+	// experimental. Taints of a device are independent of the
+	// taints of the node that the device is attached to.
+	//
+	// Only the NoSchedule effect is supported.
+	//
+	// The maximum number of taints is 8.
+	//
+	// +optional
+	// +listType=atomic
+	// +featureGate=DRADeviceTaints
+	Taints []core.Taint
 }
 
 // Limit for the sum of the number of entries in both ResourceSlices.
 const ResourceSliceMaxAttributesAndCapacitiesPerDevice = 32
 
+// BasicDeviceMaxTaints is the maximum number of taints per device.
+const BasicDeviceMaxTaints = 8
+
 // QualifiedName is the name of a device attribute or capacity.
 //
 // Attributes and capacities are defined either by the owner of the specific
@@ -274,11 +293,27 @@ type DeviceAttribute struct {
 	// +optional
 	// +oneOf=ValueType
 	VersionValue *string
+
+	// StringListValue is a list of strings, for example the numeric
+	// precisions supported by a device. It must have at least one and at
+	// most 16 entries. Each entry must not be longer than 64 characters.
+	//
+	// This is an alpha field and requires enabling the DRAStringListAttributes
+	// feature gate.
+	//
+	// +optional
+	// +oneOf=ValueType
+	// +listType=atomic
+	// +featureGate=DRAStringListAttributes
+	StringListValue []string
 }
 
 // DeviceAttributeMaxValueLength is the maximum length of a string or version attribute value.
 const DeviceAttributeMaxValueLength = 64
 
+// DeviceAttributeMaxListLength is the maximum number of entries in a string list attribute value.
+const DeviceAttributeMaxListLength = 16
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // ResourceSliceList is a collection of ResourceSlices.
@@ -448,6 +483,36 @@ type DeviceRequest struct {
 	// +optional
 	// +default=false
 	AdminAccess bool
+
+	// Capacity defines how much of the named capacities of a device
+	// each device allocated for this request consumes. When set, a
+	// device may get shared with other requests which also specify
+	// capacity, as long as the sum of what all of them consume does not
+	// exceed what the device provides. Each named capacity must be
+	// provided by the device, otherwise the device is not suitable.
+	//
+	// When not set, each allocated device is used exclusively.
+	//
+	// This is an alpha field and requires enabling the DRAConsumableCapacity
+	// feature gate.
+	//
+	// +optional
+	// +featureGate=DRAConsumableCapacity
+	Capacity map[QualifiedName]resource.Quantity
+
+	// NodeLocalOnly restricts the devices for this request to those from
+	// resource pools which belong to the node that the pod gets scheduled
+	// to, i.e. ResourceSlices which have NodeName set. Devices in pools
+	// which are shared by several nodes, i.e. in slices with NodeSelector
+	// or AllNodes, are not considered for this request.
+	//
+	// This is an alpha field and requires enabling the DRANodeLocalOnly
+	// feature gate.
+	//
+	// +optional
+	// +default=false
+	// +featureGate=DRANodeLocalOnly
+	NodeLocalOnly bool
 }
 
 const (
@@ -469,6 +534,18 @@ type DeviceSelector struct {
 	// +optional
 	// +oneOf=SelectorType
 	CEL *CELDeviceSelector
+
+	// Attribute compares one attribute of a device against a list of
+	// values. It covers common filters without having to write a CEL
+	// expression.
+	//
+	// This is an alpha field and requires enabling the DRAAttributeSelectors
+	// feature gate.
+	//
+	// +optional
+	// +oneOf=SelectorType
+	// +featureGate=DRAAttributeSelectors
+	Attribute *AttributeDeviceSelector
 }
 
 // CELDeviceSelector contains a CEL expression for selecting a device.
@@ -524,6 +601,55 @@ type CELDeviceSelector struct {
 	Expression string
 }
 
+// AttributeDeviceSelectorMaxValues is the maximum number of values in an
+// AttributeDeviceSelector.
+const AttributeDeviceSelectorMaxValues = 32
+
+// AttributeDeviceSelector compares one attribute of a device against a list
+// of values. Only string and boolean attributes are supported. A device
+// which does not have the attribute does not match, regardless of the
+// operator.
+type AttributeDeviceSelector struct {
+	// Name is the name of the attribute. It must be fully qualified, i.e.
+	// include the domain. If the domain is the name of the driver of a
+	// device, attributes without the domain are also found, like in a
+	// MatchAttribute constraint.
+	//
+	// +required
+	Name FullyQualifiedName
+
+	// Operator defines how the attribute gets compared against the values.
+	// Supported values are:
+	//  - NotEquals: the attribute must not be equal to the single value.
+	//  - In: the attribute must be equal to one of the values.
+	//  - NotIn: the attribute must not be equal to any of the values.
+	//
+	// More operators may get added in the future. Clients must refuse to
+	// handle selectors with unknown operators.
+	//
+	// +required
+	Operator AttributeSelectorOperator
+
+	// Values are the values which the attribute gets compared against.
+	// Boolean attributes are compared against "true" and "false".
+	// NotEquals requires exactly one value, In and NotIn at least one.
+	//
+	// +listType=atomic
+	// +required
+	Values []string
+}
+
+// AttributeSelectorOperator defines how an AttributeDeviceSelector compares
+// an attribute against its values.
+type AttributeSelectorOperator string
+
+// Valid [AttributeDeviceSelector.Operator] values.
+const (
+	AttributeSelectorOpNotEquals = AttributeSelectorOperator("NotEquals")
+	AttributeSelectorOpIn        = AttributeSelectorOperator("In")
+	AttributeSelectorOpNotIn     = AttributeSelectorOperator("NotIn")
+)
+
 // DeviceConstraint must have exactly one field set besides Requests.
 type DeviceConstraint struct {
 	// Requests is a list of the one or more requests in this claim which
@@ -673,6 +799,33 @@ type ResourceClaimConsumerReference struct {
 	// UID identifies exactly one incarnation of the resource.
 	// +required
 	UID types.UID
+
+	// ReservationDeadline, if set, is the time until which the consumer
+	// is expected to start using the claim. The scheduler sets it for
+	// pods when reserving the claim in the binding phase. If the pod has
+	// not been bound to a node by then, the reservation may be
+	// removed again by a controller.
+	//
+	// This is an alpha field and requires enabling the DRAReservationDeadline
+	// feature gate.
+	//
+	// +optional
+	// +featureGate=DRAReservationDeadline
+	ReservationDeadline *metav1.Time
+
+	// DeviceIndices, if set, are the indices of those entries in
+	// status.allocation.devices.results which the consumer uses. If
+	// unset, the consumer uses all allocated devices. This is
+	// useful when a claim is shared and each consumer only needs
+	// some of its devices.
+	//
+	// This is an alpha field and requires enabling the DRAReservedDeviceIndices
+	// feature gate.
+	//
+	// +optional
+	// +listType=atomic
+	// +featureGate=DRAReservedDeviceIndices
+	DeviceIndices []int32
 }
 
 // AllocationResult contains attributes of an allocated resource.
@@ -763,6 +916,18 @@ type DeviceRequestAllocationResult struct {
 	//
 	// +required
 	Device string
+
+	// ConsumedCapacity records how much of the named capacities of the
+	// device is consumed by this allocation. It is set if (and only if)
+	// the request specified capacity, in which case the device may be
+	// shared with other allocations.
+	//
+	// This is an alpha field and requires enabling the DRAConsumableCapacity
+	// feature gate.
+	//
+	// +optional
+	// +featureGate=DRAConsumableCapacity
+	ConsumedCapacity map[QualifiedName]resource.Quantity
 }
 
 // DeviceAllocationConfiguration gets embedded in an AllocationResult.
@@ -950,10 +1115,9 @@ type DeviceClassSpec struct {
 
 	// Only nodes matching the selector will be considered by the scheduler
 	// when trying to find a Node that fits a Pod when that Pod uses
-	// a claim that has not been allocated yet *and* that claim
-	// gets allocated through a control plane controller. It is ignored
-	// when the claim does not use a control plane controller
-	// for allocation.
+	// a claim that has not been allocated yet. This applies to claims
+	// which get allocated by the scheduler and to claims which get
+	// allocated through a control plane controller.
 	//
 	// Setting this field is optional. If unset, all Nodes are candidates.
 	//
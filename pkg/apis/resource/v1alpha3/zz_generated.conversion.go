@@ -27,6 +27,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	v1alpha3 "k8s.io/api/resource/v1alpha3"
 	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	conversion "k8s.io/apimachinery/pkg/conversion"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	types "k8s.io/apimachinery/pkg/types"
@@ -51,6 +52,16 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddGeneratedConversionFunc((*v1alpha3.AttributeDeviceSelector)(nil), (*resource.AttributeDeviceSelector)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha3_AttributeDeviceSelector_To_resource_AttributeDeviceSelector(a.(*v1alpha3.AttributeDeviceSelector), b.(*resource.AttributeDeviceSelector), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*resource.AttributeDeviceSelector)(nil), (*v1alpha3.AttributeDeviceSelector)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_resource_AttributeDeviceSelector_To_v1alpha3_AttributeDeviceSelector(a.(*resource.AttributeDeviceSelector), b.(*v1alpha3.AttributeDeviceSelector), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddGeneratedConversionFunc((*v1alpha3.BasicDevice)(nil), (*resource.BasicDevice)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_BasicDevice_To_resource_BasicDevice(a.(*v1alpha3.BasicDevice), b.(*resource.BasicDevice), scope)
 	}); err != nil {
@@ -432,9 +443,34 @@ func Convert_resource_AllocationResult_To_v1alpha3_AllocationResult(in *resource
 	return autoConvert_resource_AllocationResult_To_v1alpha3_AllocationResult(in, out, s)
 }
 
+func autoConvert_v1alpha3_AttributeDeviceSelector_To_resource_AttributeDeviceSelector(in *v1alpha3.AttributeDeviceSelector, out *resource.AttributeDeviceSelector, s conversion.Scope) error {
+	out.Name = resource.FullyQualifiedName(in.Name)
+	out.Operator = resource.AttributeSelectorOperator(in.Operator)
+	out.Values = *(*[]string)(unsafe.Pointer(&in.Values))
+	return nil
+}
+
+// Convert_v1alpha3_AttributeDeviceSelector_To_resource_AttributeDeviceSelector is an autogenerated conversion function.
+func Convert_v1alpha3_AttributeDeviceSelector_To_resource_AttributeDeviceSelector(in *v1alpha3.AttributeDeviceSelector, out *resource.AttributeDeviceSelector, s conversion.Scope) error {
+	return autoConvert_v1alpha3_AttributeDeviceSelector_To_resource_AttributeDeviceSelector(in, out, s)
+}
+
+func autoConvert_resource_AttributeDeviceSelector_To_v1alpha3_AttributeDeviceSelector(in *resource.AttributeDeviceSelector, out *v1alpha3.AttributeDeviceSelector, s conversion.Scope) error {
+	out.Name = v1alpha3.FullyQualifiedName(in.Name)
+	out.Operator = v1alpha3.AttributeSelectorOperator(in.Operator)
+	out.Values = *(*[]string)(unsafe.Pointer(&in.Values))
+	return nil
+}
+
+// Convert_resource_AttributeDeviceSelector_To_v1alpha3_AttributeDeviceSelector is an autogenerated conversion function.
+func Convert_resource_AttributeDeviceSelector_To_v1alpha3_AttributeDeviceSelector(in *resource.AttributeDeviceSelector, out *v1alpha3.AttributeDeviceSelector, s conversion.Scope) error {
+	return autoConvert_resource_AttributeDeviceSelector_To_v1alpha3_AttributeDeviceSelector(in, out, s)
+}
+
 func autoConvert_v1alpha3_BasicDevice_To_resource_BasicDevice(in *v1alpha3.BasicDevice, out *resource.BasicDevice, s conversion.Scope) error {
 	out.Attributes = *(*map[resource.QualifiedName]resource.DeviceAttribute)(unsafe.Pointer(&in.Attributes))
 	out.Capacity = *(*map[resource.QualifiedName]apiresource.Quantity)(unsafe.Pointer(&in.Capacity))
+	out.Taints = *(*[]core.Taint)(unsafe.Pointer(&in.Taints))
 	return nil
 }
 
@@ -446,6 +482,7 @@ func Convert_v1alpha3_BasicDevice_To_resource_BasicDevice(in *v1alpha3.BasicDevi
 func autoConvert_resource_BasicDevice_To_v1alpha3_BasicDevice(in *resource.BasicDevice, out *v1alpha3.BasicDevice, s conversion.Scope) error {
 	out.Attributes = *(*map[v1alpha3.QualifiedName]v1alpha3.DeviceAttribute)(unsafe.Pointer(&in.Attributes))
 	out.Capacity = *(*map[v1alpha3.QualifiedName]apiresource.Quantity)(unsafe.Pointer(&in.Capacity))
+	out.Taints = *(*[]v1.Taint)(unsafe.Pointer(&in.Taints))
 	return nil
 }
 
@@ -551,6 +588,7 @@ func autoConvert_v1alpha3_DeviceAttribute_To_resource_DeviceAttribute(in *v1alph
 	out.BoolValue = (*bool)(unsafe.Pointer(in.BoolValue))
 	out.StringValue = (*string)(unsafe.Pointer(in.StringValue))
 	out.VersionValue = (*string)(unsafe.Pointer(in.VersionValue))
+	out.StringListValue = *(*[]string)(unsafe.Pointer(&in.StringListValue))
 	return nil
 }
 
@@ -564,6 +602,7 @@ func autoConvert_resource_DeviceAttribute_To_v1alpha3_DeviceAttribute(in *resour
 	out.BoolValue = (*bool)(unsafe.Pointer(in.BoolValue))
 	out.StringValue = (*string)(unsafe.Pointer(in.StringValue))
 	out.VersionValue = (*string)(unsafe.Pointer(in.VersionValue))
+	out.StringListValue = *(*[]string)(unsafe.Pointer(&in.StringListValue))
 	return nil
 }
 
@@ -767,6 +806,8 @@ func autoConvert_v1alpha3_DeviceRequest_To_resource_DeviceRequest(in *v1alpha3.D
 	out.AllocationMode = resource.DeviceAllocationMode(in.AllocationMode)
 	out.Count = in.Count
 	out.AdminAccess = in.AdminAccess
+	out.Capacity = *(*map[resource.QualifiedName]apiresource.Quantity)(unsafe.Pointer(&in.Capacity))
+	out.NodeLocalOnly = in.NodeLocalOnly
 	return nil
 }
 
@@ -782,6 +823,8 @@ func autoConvert_resource_DeviceRequest_To_v1alpha3_DeviceRequest(in *resource.D
 	out.AllocationMode = v1alpha3.DeviceAllocationMode(in.AllocationMode)
 	out.Count = in.Count
 	out.AdminAccess = in.AdminAccess
+	out.Capacity = *(*map[v1alpha3.QualifiedName]apiresource.Quantity)(unsafe.Pointer(&in.Capacity))
+	out.NodeLocalOnly = in.NodeLocalOnly
 	return nil
 }
 
@@ -795,6 +838,7 @@ func autoConvert_v1alpha3_DeviceRequestAllocationResult_To_resource_DeviceReques
 	out.Driver = in.Driver
 	out.Pool = in.Pool
 	out.Device = in.Device
+	out.ConsumedCapacity = *(*map[resource.QualifiedName]apiresource.Quantity)(unsafe.Pointer(&in.ConsumedCapacity))
 	return nil
 }
 
@@ -808,6 +852,7 @@ func autoConvert_resource_DeviceRequestAllocationResult_To_v1alpha3_DeviceReques
 	out.Driver = in.Driver
 	out.Pool = in.Pool
 	out.Device = in.Device
+	out.ConsumedCapacity = *(*map[v1alpha3.QualifiedName]apiresource.Quantity)(unsafe.Pointer(&in.ConsumedCapacity))
 	return nil
 }
 
@@ -818,6 +863,7 @@ func Convert_resource_DeviceRequestAllocationResult_To_v1alpha3_DeviceRequestAll
 
 func autoConvert_v1alpha3_DeviceSelector_To_resource_DeviceSelector(in *v1alpha3.DeviceSelector, out *resource.DeviceSelector, s conversion.Scope) error {
 	out.CEL = (*resource.CELDeviceSelector)(unsafe.Pointer(in.CEL))
+	out.Attribute = (*resource.AttributeDeviceSelector)(unsafe.Pointer(in.Attribute))
 	return nil
 }
 
@@ -828,6 +874,7 @@ func Convert_v1alpha3_DeviceSelector_To_resource_DeviceSelector(in *v1alpha3.Dev
 
 func autoConvert_resource_DeviceSelector_To_v1alpha3_DeviceSelector(in *resource.DeviceSelector, out *v1alpha3.DeviceSelector, s conversion.Scope) error {
 	out.CEL = (*v1alpha3.CELDeviceSelector)(unsafe.Pointer(in.CEL))
+	out.Attribute = (*v1alpha3.AttributeDeviceSelector)(unsafe.Pointer(in.Attribute))
 	return nil
 }
 
@@ -991,6 +1038,8 @@ func autoConvert_v1alpha3_ResourceClaimConsumerReference_To_resource_ResourceCla
 	out.Resource = in.Resource
 	out.Name = in.Name
 	out.UID = types.UID(in.UID)
+	out.ReservationDeadline = (*metav1.Time)(unsafe.Pointer(in.ReservationDeadline))
+	out.DeviceIndices = *(*[]int32)(unsafe.Pointer(&in.DeviceIndices))
 	return nil
 }
 
@@ -1004,6 +1053,8 @@ func autoConvert_resource_ResourceClaimConsumerReference_To_v1alpha3_ResourceCla
 	out.Resource = in.Resource
 	out.Name = in.Name
 	out.UID = types.UID(in.UID)
+	out.ReservationDeadline = (*metav1.Time)(unsafe.Pointer(in.ReservationDeadline))
+	out.DeviceIndices = *(*[]int32)(unsafe.Pointer(&in.DeviceIndices))
 	return nil
 }
 
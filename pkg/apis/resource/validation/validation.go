@@ -26,15 +26,19 @@ import (
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apiresource "k8s.io/apimachinery/pkg/api/resource"
 	apimachineryvalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apiserver/pkg/cel"
 	"k8s.io/apiserver/pkg/cel/environment"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	dracel "k8s.io/dynamic-resource-allocation/cel"
+	"k8s.io/kubernetes/pkg/apis/core"
 	corevalidation "k8s.io/kubernetes/pkg/apis/core/validation"
 	"k8s.io/kubernetes/pkg/apis/resource"
+	"k8s.io/kubernetes/pkg/features"
 )
 
 var (
@@ -151,15 +155,50 @@ func validateDeviceRequest(request resource.DeviceRequest, fldPath *field.Path,
 	default:
 		allErrs = append(allErrs, field.NotSupported(fldPath.Child("allocationMode"), request.AllocationMode, []resource.DeviceAllocationMode{resource.DeviceAllocationModeAll, resource.DeviceAllocationModeExactCount}))
 	}
+	allErrs = append(allErrs, validateMap(request.Capacity, resource.ResourceSliceMaxAttributesAndCapacitiesPerDevice, validateQualifiedName, validatePositiveQuantity, fldPath.Child("capacity"))...)
 	return allErrs
 }
 
 func validateSelector(selector resource.DeviceSelector, fldPath *field.Path, stored bool) field.ErrorList {
 	var allErrs field.ErrorList
-	if selector.CEL == nil {
-		allErrs = append(allErrs, field.Required(fldPath.Child("cel"), ""))
-	} else {
+	switch {
+	case selector.CEL != nil && selector.Attribute != nil:
+		allErrs = append(allErrs, field.Invalid(fldPath, "", "exactly one of `cel` or `attribute` must be set"))
+	case selector.CEL != nil:
 		allErrs = append(allErrs, validateCELSelector(*selector.CEL, fldPath.Child("cel"), stored)...)
+	case selector.Attribute != nil:
+		allErrs = append(allErrs, validateAttributeSelector(*selector.Attribute, fldPath.Child("attribute"))...)
+	default:
+		allErrs = append(allErrs, field.Required(fldPath.Child("cel"), ""))
+	}
+	return allErrs
+}
+
+func validateAttributeSelector(selector resource.AttributeDeviceSelector, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, validateFullyQualifiedName(selector.Name, fldPath.Child("name"))...)
+	switch selector.Operator {
+	case resource.AttributeSelectorOpNotEquals:
+		if len(selector.Values) != 1 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("values"), selector.Values, "must have exactly one value for operator NotEquals"))
+		}
+	case resource.AttributeSelectorOpIn, resource.AttributeSelectorOpNotIn:
+		if len(selector.Values) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("values"), fmt.Sprintf("must have at least one value for operator %s", selector.Operator)))
+		}
+	case "":
+		allErrs = append(allErrs, field.Required(fldPath.Child("operator"), ""))
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("operator"), selector.Operator, []resource.AttributeSelectorOperator{resource.AttributeSelectorOpNotEquals, resource.AttributeSelectorOpIn, resource.AttributeSelectorOpNotIn}))
+	}
+	allErrs = append(allErrs, validateSet(selector.Values, resource.AttributeDeviceSelectorMaxValues, validateAttributeSelectorValue, stringKey, fldPath.Child("values"))...)
+	return allErrs
+}
+
+func validateAttributeSelectorValue(value string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(value) > resource.DeviceAttributeMaxValueLength {
+		allErrs = append(allErrs, field.TooLongMaxLength(fldPath, value, resource.DeviceAttributeMaxValueLength))
 	}
 	return allErrs
 }
@@ -170,7 +209,10 @@ func validateCELSelector(celSelector resource.CELDeviceSelector, fldPath *field.
 	if stored {
 		envType = environment.StoredExpressions
 	}
-	result := dracel.GetCompiler().CompileCELExpression(celSelector.Expression, envType)
+	celFeatures := dracel.Features{
+		PodLabels: utilfeature.DefaultFeatureGate.Enabled(features.DRAPodLabelSelectors),
+	}
+	result := dracel.GetCompiler(celFeatures).CompileCELExpression(celSelector.Expression, envType)
 	if result.Error != nil {
 		allErrs = append(allErrs, convertCELErrorToValidationError(fldPath.Child("expression"), celSelector.Expression, result.Error))
 	}
@@ -269,13 +311,27 @@ func validateResourceClaimStatusUpdate(status, oldStatus *resource.ResourceClaim
 			// Items may be removed from ReservedFor while the claim is meant to be deallocated,
 			// but not added.
 			if claimDeleted || status.DeallocationRequested {
-				oldSet := sets.New(oldStatus.ReservedFor...)
-				newSet := sets.New(status.ReservedFor...)
+				oldSet := consumerUIDs(oldStatus.ReservedFor)
+				newSet := consumerUIDs(status.ReservedFor)
 				newItems := newSet.Difference(oldSet)
 				if len(newItems) > 0 {
 					allErrs = append(allErrs, field.Forbidden(fldPath.Child("reservedFor"), "new entries may not be added while `deallocationRequested` or `deletionTimestamp` are set"))
 				}
 			}
+
+			// Device indices must refer to allocated devices.
+			numResults := len(status.Allocation.Devices.Results)
+			for i, consumer := range status.ReservedFor {
+				allErrs = append(allErrs, validateSet(consumer.DeviceIndices, -1,
+					func(index int32, fldPath *field.Path) field.ErrorList {
+						if index < 0 || int(index) >= numResults {
+							return field.ErrorList{field.Invalid(fldPath, index, fmt.Sprintf("must be in the range 0 to %d, the number of allocated devices minus one", numResults-1))}
+						}
+						return nil
+					},
+					func(index int32) (int32, string) { return index, "" },
+					fldPath.Child("reservedFor").Index(i).Child("deviceIndices"))...)
+			}
 		}
 	}
 
@@ -311,6 +367,16 @@ func validateResourceClaimStatusUpdate(status, oldStatus *resource.ResourceClaim
 	return allErrs
 }
 
+// consumerUIDs returns the UIDs which identify the consumers. The
+// references themselves are not comparable.
+func consumerUIDs(consumers []resource.ResourceClaimConsumerReference) sets.Set[types.UID] {
+	uids := sets.New[types.UID]()
+	for _, consumer := range consumers {
+		uids.Insert(consumer.UID)
+	}
+	return uids
+}
+
 func validateResourceClaimUserReference(ref resource.ResourceClaimConsumerReference, fldPath *field.Path) field.ErrorList {
 	var allErrs field.ErrorList
 	if ref.Resource == "" {
@@ -361,6 +427,7 @@ func validateDeviceRequestAllocationResult(result resource.DeviceRequestAllocati
 	allErrs = append(allErrs, validateDriverName(result.Driver, fldPath.Child("driver"))...)
 	allErrs = append(allErrs, validatePoolName(result.Pool, fldPath.Child("pool"))...)
 	allErrs = append(allErrs, validateDeviceName(result.Device, fldPath.Child("device"))...)
+	allErrs = append(allErrs, validateMap(result.ConsumedCapacity, resource.ResourceSliceMaxAttributesAndCapacitiesPerDevice, validateQualifiedName, validatePositiveQuantity, fldPath.Child("consumedCapacity"))...)
 	return allErrs
 }
 
@@ -596,6 +663,27 @@ func validateBasicDevice(device resource.BasicDevice, fldPath *field.Path) field
 	if combinedLen, max := len(device.Attributes)+len(device.Capacity), resource.ResourceSliceMaxAttributesAndCapacitiesPerDevice; combinedLen > max {
 		allErrs = append(allErrs, field.Invalid(fldPath, combinedLen, fmt.Sprintf("the total number of attributes and capacities must not exceed %d", max)))
 	}
+	allErrs = append(allErrs, validateSet(device.Taints, resource.BasicDeviceMaxTaints, validateDeviceTaint,
+		func(taint core.Taint) (string, string) {
+			// Taints must be unique by key and effect, like node taints.
+			return taint.Key + ":" + string(taint.Effect), ""
+		}, fldPath.Child("taints"))...)
+	return allErrs
+}
+
+func validateDeviceTaint(taint core.Taint, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, metav1validation.ValidateLabelName(taint.Key, fldPath.Child("key"))...)
+	for _, msg := range validation.IsValidLabelValue(taint.Value) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("value"), taint.Value, msg))
+	}
+	switch taint.Effect {
+	case "":
+		allErrs = append(allErrs, field.Required(fldPath.Child("effect"), ""))
+	case core.TaintEffectNoSchedule:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("effect"), taint.Effect, []core.TaintEffect{core.TaintEffectNoSchedule}))
+	}
 	return allErrs
 }
 
@@ -644,6 +732,20 @@ func validateDeviceAttribute(attribute resource.DeviceAttribute, fldPath *field.
 			allErrs = append(allErrs, field.TooLongMaxLength(fldPath.Child("version"), *attribute.VersionValue, resource.DeviceAttributeMaxValueLength))
 		}
 	}
+	if attribute.StringListValue != nil {
+		numFields++
+		switch {
+		case len(attribute.StringListValue) == 0:
+			allErrs = append(allErrs, field.Required(fldPath.Child("stringList"), "must have at least one entry"))
+		case len(attribute.StringListValue) > resource.DeviceAttributeMaxListLength:
+			allErrs = append(allErrs, field.TooMany(fldPath.Child("stringList"), len(attribute.StringListValue), resource.DeviceAttributeMaxListLength))
+		}
+		for i, value := range attribute.StringListValue {
+			if len(value) > resource.DeviceAttributeMaxValueLength {
+				allErrs = append(allErrs, field.TooLongMaxLength(fldPath.Child("stringList").Index(i), value, resource.DeviceAttributeMaxValueLength))
+			}
+		}
+	}
 
 	switch numFields {
 	case 0:
@@ -661,6 +763,14 @@ func validateQuantity(quantity apiresource.Quantity, fldPath *field.Path) field.
 	return nil
 }
 
+func validatePositiveQuantity(quantity apiresource.Quantity, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if quantity.Sign() <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath, quantity.String(), "must be greater than zero"))
+	}
+	return allErrs
+}
+
 func validateQualifiedName(name resource.QualifiedName, fldPath *field.Path) field.ErrorList {
 	var allErrs field.ErrorList
 	if name == "" {
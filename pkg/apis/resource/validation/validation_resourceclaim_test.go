@@ -22,12 +22,16 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
 	"k8s.io/kubernetes/pkg/apis/core"
 	"k8s.io/kubernetes/pkg/apis/resource"
+	"k8s.io/kubernetes/pkg/features"
 	"k8s.io/utils/pointer"
 	"k8s.io/utils/ptr"
 )
@@ -210,6 +214,27 @@ func TestValidateClaim(t *testing.T) {
 				return claim
 			}(),
 		},
+		"good-capacity": {
+			claim: func() *resource.ResourceClaim {
+				claim := testClaim(goodName, goodNS, validClaimSpec)
+				claim.Spec.Devices.Requests[0].Capacity = map[resource.QualifiedName]apiresource.Quantity{
+					"memory": apiresource.MustParse("1Gi"),
+				}
+				return claim
+			}(),
+		},
+		"bad-capacity": {
+			wantFailures: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "devices", "requests").Index(0).Child("capacity").Key("memory"), "0", "must be greater than zero"),
+			},
+			claim: func() *resource.ResourceClaim {
+				claim := testClaim(goodName, goodNS, validClaimSpec)
+				claim.Spec.Devices.Requests[0].Capacity = map[resource.QualifiedName]apiresource.Quantity{
+					"memory": apiresource.MustParse("0"),
+				}
+				return claim
+			}(),
+		},
 		"invalid-request-name": {
 			wantFailures: field.ErrorList{
 				field.Invalid(field.NewPath("spec", "devices", "constraints").Index(0).Child("requests").Index(1), badName, "a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')"),
@@ -316,6 +341,42 @@ func TestValidateClaim(t *testing.T) {
 				return claim
 			}(),
 		},
+		"good-attribute-selectors": {
+			claim: func() *resource.ResourceClaim {
+				claim := testClaim(goodName, goodNS, validClaimSpec)
+				claim.Spec.Devices.Requests[0].Selectors = []resource.DeviceSelector{
+					{Attribute: &resource.AttributeDeviceSelector{Name: "dra.example.com/model", Operator: resource.AttributeSelectorOpNotEquals, Values: []string{"A100"}}},
+					{Attribute: &resource.AttributeDeviceSelector{Name: "dra.example.com/model", Operator: resource.AttributeSelectorOpIn, Values: []string{"A100", "H100"}}},
+					{Attribute: &resource.AttributeDeviceSelector{Name: "dra.example.com/shared", Operator: resource.AttributeSelectorOpNotIn, Values: []string{"true"}}},
+				}
+				return claim
+			}(),
+		},
+		"bad-attribute-selectors": {
+			wantFailures: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "devices", "requests").Index(0).Child("selectors").Index(0), "", "exactly one of `cel` or `attribute` must be set"),
+				field.Required(field.NewPath("spec", "devices", "requests").Index(0).Child("selectors").Index(1).Child("attribute", "name", "domain"), "must include a prefix"),
+				field.Invalid(field.NewPath("spec", "devices", "requests").Index(0).Child("selectors").Index(2).Child("attribute", "values"), []string{"A100", "H100"}, "must have exactly one value for operator NotEquals"),
+				field.Required(field.NewPath("spec", "devices", "requests").Index(0).Child("selectors").Index(3).Child("attribute", "values"), "must have at least one value for operator In"),
+				field.NotSupported(field.NewPath("spec", "devices", "requests").Index(0).Child("selectors").Index(4).Child("attribute", "operator"), resource.AttributeSelectorOperator("Equals"), []resource.AttributeSelectorOperator{resource.AttributeSelectorOpNotEquals, resource.AttributeSelectorOpIn, resource.AttributeSelectorOpNotIn}),
+				field.Duplicate(field.NewPath("spec", "devices", "requests").Index(0).Child("selectors").Index(5).Child("attribute", "values").Index(1), "A100"),
+			},
+			claim: func() *resource.ResourceClaim {
+				claim := testClaim(goodName, goodNS, validClaimSpec)
+				claim.Spec.Devices.Requests[0].Selectors = []resource.DeviceSelector{
+					{
+						CEL:       &resource.CELDeviceSelector{Expression: "true"},
+						Attribute: &resource.AttributeDeviceSelector{Name: "dra.example.com/model", Operator: resource.AttributeSelectorOpIn, Values: []string{"A100"}},
+					},
+					{Attribute: &resource.AttributeDeviceSelector{Name: "model", Operator: resource.AttributeSelectorOpIn, Values: []string{"A100"}}},
+					{Attribute: &resource.AttributeDeviceSelector{Name: "dra.example.com/model", Operator: resource.AttributeSelectorOpNotEquals, Values: []string{"A100", "H100"}}},
+					{Attribute: &resource.AttributeDeviceSelector{Name: "dra.example.com/model", Operator: resource.AttributeSelectorOpIn}},
+					{Attribute: &resource.AttributeDeviceSelector{Name: "dra.example.com/model", Operator: "Equals", Values: []string{"A100"}}},
+					{Attribute: &resource.AttributeDeviceSelector{Name: "dra.example.com/model", Operator: resource.AttributeSelectorOpNotIn, Values: []string{"A100", "A100"}}},
+				}
+				return claim
+			}(),
+		},
 	}
 
 	for name, scenario := range scenarios {
@@ -326,6 +387,46 @@ func TestValidateClaim(t *testing.T) {
 	}
 }
 
+func TestValidateClaimPodLabelSelector(t *testing.T) {
+	claim := testClaim(goodName, goodNS, validClaimSpec)
+	claim.Spec.Devices.Requests[0].Selectors = []resource.DeviceSelector{{
+		CEL: &resource.CELDeviceSelector{Expression: `pod.labels["team"] == "a"`},
+	}}
+	claim.ResourceVersion = "1"
+
+	for name, scenario := range map[string]struct {
+		enabled      bool
+		update       bool
+		wantFailures bool
+	}{
+		"create-disabled": {
+			wantFailures: true,
+		},
+		"create-enabled": {
+			enabled: true,
+		},
+		"update-disabled": {
+			// Stored expressions remain valid.
+			update: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRAPodLabelSelectors, scenario.enabled)
+			var errs field.ErrorList
+			if scenario.update {
+				errs = ValidateResourceClaimUpdate(claim.DeepCopy(), claim)
+			} else {
+				errs = ValidateResourceClaim(claim)
+			}
+			if scenario.wantFailures {
+				assert.NotEmpty(t, errs)
+			} else {
+				assert.Empty(t, errs)
+			}
+		})
+	}
+}
+
 func TestValidateClaimUpdate(t *testing.T) {
 	scenarios := map[string]struct {
 		oldClaim     *resource.ResourceClaim
@@ -426,6 +527,28 @@ func TestValidateClaimStatusUpdate(t *testing.T) {
 				return claim
 			},
 		},
+		"invalid-add-allocation-bad-consumed-capacity": {
+			wantFailures: field.ErrorList{
+				field.Invalid(field.NewPath("status", "allocation", "devices", "results").Index(0).Child("consumedCapacity").Key("memory"), "-1Gi", "must be greater than zero"),
+			},
+			oldClaim: validClaim,
+			update: func(claim *resource.ResourceClaim) *resource.ResourceClaim {
+				claim.Status.Allocation = &resource.AllocationResult{
+					Devices: resource.DeviceAllocationResult{
+						Results: []resource.DeviceRequestAllocationResult{{
+							Request: goodName,
+							Driver:  goodName,
+							Pool:    goodName,
+							Device:  goodName,
+							ConsumedCapacity: map[resource.QualifiedName]apiresource.Quantity{
+								"memory": apiresource.MustParse("-1Gi"),
+							},
+						}},
+					},
+				}
+				return claim
+			},
+		},
 		"invalid-node-selector": {
 			wantFailures: field.ErrorList{field.Required(field.NewPath("status", "allocation", "nodeSelector", "nodeSelectorTerms"), "must have at least one node selector term")},
 			oldClaim:     validClaim,
@@ -497,6 +620,53 @@ func TestValidateClaimStatusUpdate(t *testing.T) {
 				return claim
 			},
 		},
+		"add-reservation-with-device-indices": {
+			oldClaim: validAllocatedClaim,
+			update: func(claim *resource.ResourceClaim) *resource.ResourceClaim {
+				claim.Status.ReservedFor = []resource.ResourceClaimConsumerReference{
+					{
+						Resource:      "pods",
+						Name:          "foo",
+						UID:           "1",
+						DeviceIndices: []int32{0},
+					},
+				}
+				return claim
+			},
+		},
+		"invalid-reserved-for-device-index-out-of-range": {
+			wantFailures: field.ErrorList{
+				field.Invalid(field.NewPath("status", "reservedFor").Index(0).Child("deviceIndices").Index(0), int32(-1), "must be in the range 0 to 0, the number of allocated devices minus one"),
+				field.Invalid(field.NewPath("status", "reservedFor").Index(0).Child("deviceIndices").Index(1), int32(1), "must be in the range 0 to 0, the number of allocated devices minus one"),
+			},
+			oldClaim: validAllocatedClaim,
+			update: func(claim *resource.ResourceClaim) *resource.ResourceClaim {
+				claim.Status.ReservedFor = []resource.ResourceClaimConsumerReference{
+					{
+						Resource:      "pods",
+						Name:          "foo",
+						UID:           "1",
+						DeviceIndices: []int32{-1, 1},
+					},
+				}
+				return claim
+			},
+		},
+		"invalid-reserved-for-device-index-duplicate": {
+			wantFailures: field.ErrorList{field.Duplicate(field.NewPath("status", "reservedFor").Index(0).Child("deviceIndices").Index(1), int32(0))},
+			oldClaim:     validAllocatedClaim,
+			update: func(claim *resource.ResourceClaim) *resource.ResourceClaim {
+				claim.Status.ReservedFor = []resource.ResourceClaimConsumerReference{
+					{
+						Resource:      "pods",
+						Name:          "foo",
+						UID:           "1",
+						DeviceIndices: []int32{0, 0},
+					},
+				}
+				return claim
+			},
+		},
 		"invalid-reserved-for-no-allocation": {
 			wantFailures: field.ErrorList{field.Forbidden(field.NewPath("status", "reservedFor"), "may not be specified when `allocated` is not set")},
 			oldClaim:     validClaim,
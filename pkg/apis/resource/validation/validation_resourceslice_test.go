@@ -17,12 +17,14 @@ limitations under the License.
 package validation
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kubernetes/pkg/apis/core"
 	"k8s.io/kubernetes/pkg/apis/resource"
 	"k8s.io/utils/ptr"
 )
@@ -201,6 +203,101 @@ func TestValidateResourceSlice(t *testing.T) {
 			wantFailures: field.ErrorList{field.Invalid(field.NewPath("spec", "driver"), badName, "a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')")},
 			slice:        testResourceSlice(goodName, goodName, badName),
 		},
+		"good-taints": {
+			slice: func() *resource.ResourceSlice {
+				slice := testResourceSlice(goodName, goodName, driverName)
+				slice.Spec.Devices = []resource.Device{{
+					Name: goodName,
+					Basic: &resource.BasicDevice{
+						Taints: []core.Taint{
+							{Key: "example.com/experimental", Effect: core.TaintEffectNoSchedule},
+							{Key: "example.com/maintenance", Value: "true", Effect: core.TaintEffectNoSchedule},
+						},
+					},
+				}}
+				return slice
+			}(),
+		},
+		"bad-taints": {
+			wantFailures: field.ErrorList{
+				field.Required(field.NewPath("spec", "devices").Index(0).Child("basic", "taints").Index(0).Child("effect"), ""),
+				field.NotSupported(field.NewPath("spec", "devices").Index(0).Child("basic", "taints").Index(1).Child("effect"), core.TaintEffectNoExecute, []core.TaintEffect{core.TaintEffectNoSchedule}),
+				field.Duplicate(field.NewPath("spec", "devices").Index(0).Child("basic", "taints").Index(3), "example.com/c:NoSchedule"),
+			},
+			slice: func() *resource.ResourceSlice {
+				slice := testResourceSlice(goodName, goodName, driverName)
+				slice.Spec.Devices = []resource.Device{{
+					Name: goodName,
+					Basic: &resource.BasicDevice{
+						Taints: []core.Taint{
+							{Key: "example.com/a"},
+							{Key: "example.com/b", Effect: core.TaintEffectNoExecute},
+							{Key: "example.com/c", Effect: core.TaintEffectNoSchedule},
+							{Key: "example.com/c", Effect: core.TaintEffectNoSchedule},
+						},
+					},
+				}}
+				return slice
+			}(),
+		},
+		"good-string-list-attribute": {
+			slice: func() *resource.ResourceSlice {
+				slice := testResourceSlice(goodName, goodName, driverName)
+				slice.Spec.Devices = []resource.Device{{
+					Name: goodName,
+					Basic: &resource.BasicDevice{
+						Attributes: map[resource.QualifiedName]resource.DeviceAttribute{
+							"precisions": {StringListValue: []string{"fp16", "fp32"}},
+						},
+					},
+				}}
+				return slice
+			}(),
+		},
+		"bad-string-list-attributes": {
+			wantFailures: field.ErrorList{
+				field.Required(field.NewPath("spec", "devices").Index(0).Child("basic", "attributes").Key("precisions").Child("stringList"), "must have at least one entry"),
+				field.TooMany(field.NewPath("spec", "devices").Index(1).Child("basic", "attributes").Key("precisions").Child("stringList"), resource.DeviceAttributeMaxListLength+1, resource.DeviceAttributeMaxListLength),
+				field.Invalid(field.NewPath("spec", "devices").Index(2).Child("basic", "attributes").Key("precisions"), resource.DeviceAttribute{StringValue: ptr.To("fp16"), StringListValue: []string{"fp16"}}, "exactly one field must be specified"),
+			},
+			slice: func() *resource.ResourceSlice {
+				slice := testResourceSlice(goodName, goodName, driverName)
+				many := make([]string, resource.DeviceAttributeMaxListLength+1)
+				for i := range many {
+					many[i] = fmt.Sprintf("value-%d", i)
+				}
+				for _, attribute := range []resource.DeviceAttribute{
+					{StringListValue: []string{}},
+					{StringListValue: many},
+					{StringValue: ptr.To("fp16"), StringListValue: []string{"fp16"}},
+				} {
+					slice.Spec.Devices = append(slice.Spec.Devices, resource.Device{
+						Name: fmt.Sprintf("device-%d", len(slice.Spec.Devices)),
+						Basic: &resource.BasicDevice{
+							Attributes: map[resource.QualifiedName]resource.DeviceAttribute{"precisions": attribute},
+						},
+					})
+				}
+				return slice
+			}(),
+		},
+		"too-many-taints": {
+			wantFailures: field.ErrorList{
+				field.TooLongMaxLength(field.NewPath("spec", "devices").Index(0).Child("basic", "taints"), resource.BasicDeviceMaxTaints+1, resource.BasicDeviceMaxTaints),
+			},
+			slice: func() *resource.ResourceSlice {
+				slice := testResourceSlice(goodName, goodName, driverName)
+				var taints []core.Taint
+				for i := 0; i < resource.BasicDeviceMaxTaints+1; i++ {
+					taints = append(taints, core.Taint{Key: fmt.Sprintf("example.com/key-%d", i), Effect: core.TaintEffectNoSchedule})
+				}
+				slice.Spec.Devices = []resource.Device{{
+					Name:  goodName,
+					Basic: &resource.BasicDevice{Taints: taints},
+				}}
+				return slice
+			}(),
+		},
 	}
 
 	for name, scenario := range scenarios {
@@ -239,6 +239,21 @@ const (
 	// DisableNodeKubeProxyVersion disable the status.nodeInfo.kubeProxyVersion field of v1.Node
 	DisableNodeKubeProxyVersion featuregate.Feature = "DisableNodeKubeProxyVersion"
 
+	// owner: TBD
+	// alpha: v1.31
+	//
+	// Enables selecting devices by comparing a string or boolean attribute
+	// against a list of values without having to write a CEL expression.
+	DRAAttributeSelectors featuregate.Feature = "DRAAttributeSelectors"
+
+	// owner: TBD
+	// alpha: v1.31
+	//
+	// Enables devices which declare capacities to be shared by several
+	// ResourceClaims as long as the sum of the capacity requested by those
+	// claims does not exceed what the device provides.
+	DRAConsumableCapacity featuregate.Feature = "DRAConsumableCapacity"
+
 	// owner: @pohly
 	// kep: http://kep.k8s.io/3063
 	// alpha: v1.26
@@ -248,6 +263,49 @@ const (
 	// "control plane controller" in cooperation with the scheduler.
 	DRAControlPlaneController featuregate.Feature = "DRAControlPlaneController"
 
+	// owner: TBD
+	// alpha: v1.31
+	//
+	// Enables taints for devices in ResourceSlices. Tainted devices only
+	// get allocated for pods which tolerate the taints.
+	DRADeviceTaints featuregate.Feature = "DRADeviceTaints"
+
+	// owner: TBD
+	// alpha: v1.31
+	//
+	// Enables NodeLocalOnly in device requests, which restricts a request
+	// to devices from pools that belong to the node of the pod.
+	DRANodeLocalOnly featuregate.Feature = "DRANodeLocalOnly"
+
+	// owner: TBD
+	// alpha: v1.31
+	//
+	// Enables pod.labels in CEL device selectors, which makes it possible
+	// to select devices depending on the pod that uses a claim.
+	DRAPodLabelSelectors featuregate.Feature = "DRAPodLabelSelectors"
+
+	// owner: TBD
+	// alpha: v1.31
+	//
+	// Enables ReservationDeadline in the ReservedFor entries of
+	// ResourceClaims. The scheduler sets it when reserving a claim
+	// for a pod.
+	DRAReservationDeadline featuregate.Feature = "DRAReservationDeadline"
+
+	// owner: TBD
+	// alpha: v1.31
+	//
+	// Enables DeviceIndices in the ReservedFor entries of ResourceClaims,
+	// which record that a consumer only uses some of the allocated devices.
+	DRAReservedDeviceIndices featuregate.Feature = "DRAReservedDeviceIndices"
+
+	// owner: TBD
+	// alpha: v1.31
+	//
+	// Enables device attributes in ResourceSlices which hold a list of
+	// strings.
+	DRAStringListAttributes featuregate.Feature = "DRAStringListAttributes"
+
 	// owner: @pohly
 	// kep: http://kep.k8s.io/4381
 	// alpha: v1.29
@@ -1060,8 +1118,24 @@ var defaultKubernetesFeatureGates = map[featuregate.Feature]featuregate.FeatureS
 
 	DevicePluginCDIDevices: {Default: true, PreRelease: featuregate.GA, LockToDefault: true}, // remove in 1.33
 
+	DRAAttributeSelectors: {Default: false, PreRelease: featuregate.Alpha},
+
+	DRAConsumableCapacity: {Default: false, PreRelease: featuregate.Alpha},
+
 	DRAControlPlaneController: {Default: false, PreRelease: featuregate.Alpha},
 
+	DRADeviceTaints: {Default: false, PreRelease: featuregate.Alpha},
+
+	DRANodeLocalOnly: {Default: false, PreRelease: featuregate.Alpha},
+
+	DRAPodLabelSelectors: {Default: false, PreRelease: featuregate.Alpha},
+
+	DRAReservationDeadline: {Default: false, PreRelease: featuregate.Alpha},
+
+	DRAReservedDeviceIndices: {Default: false, PreRelease: featuregate.Alpha},
+
+	DRAStringListAttributes: {Default: false, PreRelease: featuregate.Alpha},
+
 	DynamicResourceAllocation: {Default: false, PreRelease: featuregate.Alpha},
 
 	EventedPLEG: {Default: false, PreRelease: featuregate.Alpha},
@@ -892,6 +892,7 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 		"k8s.io/api/rbac/v1beta1.RoleRef":                                                                       schema_k8sio_api_rbac_v1beta1_RoleRef(ref),
 		"k8s.io/api/rbac/v1beta1.Subject":                                                                       schema_k8sio_api_rbac_v1beta1_Subject(ref),
 		"k8s.io/api/resource/v1alpha3.AllocationResult":                                                         schema_k8sio_api_resource_v1alpha3_AllocationResult(ref),
+		"k8s.io/api/resource/v1alpha3.AttributeDeviceSelector":                                                  schema_k8sio_api_resource_v1alpha3_AttributeDeviceSelector(ref),
 		"k8s.io/api/resource/v1alpha3.BasicDevice":                                                              schema_k8sio_api_resource_v1alpha3_BasicDevice(ref),
 		"k8s.io/api/resource/v1alpha3.CELDeviceSelector":                                                        schema_k8sio_api_resource_v1alpha3_CELDeviceSelector(ref),
 		"k8s.io/api/resource/v1alpha3.Device":                                                                   schema_k8sio_api_resource_v1alpha3_Device(ref),
@@ -45875,6 +45876,56 @@ func schema_k8sio_api_resource_v1alpha3_AllocationResult(ref common.ReferenceCal
 	}
 }
 
+func schema_k8sio_api_resource_v1alpha3_AttributeDeviceSelector(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "AttributeDeviceSelector compares one attribute of a device against a list of values. Only string and boolean attributes are supported. A device which does not have the attribute does not match, regardless of the operator.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name is the name of the attribute. It must be fully qualified, i.e. include the domain. If the domain is the name of the driver of a device, attributes without the domain are also found, like in a MatchAttribute constraint.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"operator": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Operator defines how the attribute gets compared against the values. Supported values are:\n - NotEquals: the attribute must not be equal to the single value.\n - In: the attribute must be equal to one of the values.\n - NotIn: the attribute must not be equal to any of the values.\n\nMore operators may get added in the future. Clients must refuse to handle selectors with unknown operators.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"values": {
+						VendorExtensible: spec.VendorExtensible{
+							Extensions: spec.Extensions{
+								"x-kubernetes-list-type": "atomic",
+							},
+						},
+						SchemaProps: spec.SchemaProps{
+							Description: "Values are the values which the attribute gets compared against. Boolean attributes are compared against \"true\" and \"false\". NotEquals requires exactly one value, In and NotIn at least one.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"name", "operator", "values"},
+			},
+		},
+	}
+}
+
 func schema_k8sio_api_resource_v1alpha3_BasicDevice(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
@@ -45911,11 +45962,30 @@ func schema_k8sio_api_resource_v1alpha3_BasicDevice(ref common.ReferenceCallback
 							},
 						},
 					},
+					"taints": {
+						VendorExtensible: spec.VendorExtensible{
+							Extensions: spec.Extensions{
+								"x-kubernetes-list-type": "atomic",
+							},
+						},
+						SchemaProps: spec.SchemaProps{
+							Description: "Taints are the taints of the device. A device with a taint only gets allocated for a pod which tolerates the taint, see pod.spec.tolerations. This is useful for devices which should not be used by default, for example because they are experimental. Taints of a device are independent of the taints of the node that the device is attached to.\n\nOnly the NoSchedule effect is supported.\n\nThe maximum number of taints is 8.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("k8s.io/api/core/v1.Taint"),
+									},
+								},
+							},
+						},
+					},
 				},
 			},
 		},
 		Dependencies: []string{
-			"k8s.io/api/resource/v1alpha3.DeviceAttribute", "k8s.io/apimachinery/pkg/api/resource.Quantity"},
+			"k8s.io/api/core/v1.Taint", "k8s.io/api/resource/v1alpha3.DeviceAttribute", "k8s.io/apimachinery/pkg/api/resource.Quantity"},
 	}
 }
 
@@ -46109,6 +46179,26 @@ func schema_k8sio_api_resource_v1alpha3_DeviceAttribute(ref common.ReferenceCall
 							Format:      "",
 						},
 					},
+					"stringList": {
+						VendorExtensible: spec.VendorExtensible{
+							Extensions: spec.Extensions{
+								"x-kubernetes-list-type": "atomic",
+							},
+						},
+						SchemaProps: spec.SchemaProps{
+							Description: "StringListValue is a list of strings, for example the numeric precisions supported by a device. It must have at least one and at most 16 entries. Each entry must not be longer than 64 characters.\n\nThis is an alpha field and requires enabling the DRAStringListAttributes feature gate.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
 				},
 			},
 		},
@@ -46391,7 +46481,7 @@ func schema_k8sio_api_resource_v1alpha3_DeviceClassSpec(ref common.ReferenceCall
 					},
 					"suitableNodes": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Only nodes matching the selector will be considered by the scheduler when trying to find a Node that fits a Pod when that Pod uses a claim that has not been allocated yet *and* that claim gets allocated through a control plane controller. It is ignored when the claim does not use a control plane controller for allocation.\n\nSetting this field is optional. If unset, all Nodes are candidates.\n\nThis is an alpha field and requires enabling the DRAControlPlaneController feature gate.",
+							Description: "Only nodes matching the selector will be considered by the scheduler when trying to find a Node that fits a Pod when that Pod uses a claim that has not been allocated yet. This applies to claims which get allocated by the scheduler and to claims which get allocated through a control plane controller.\n\nSetting this field is optional. If unset, all Nodes are candidates.\n\nThis is an alpha field and requires enabling the DRAControlPlaneController feature gate.",
 							Ref:         ref("k8s.io/api/core/v1.NodeSelector"),
 						},
 					},
@@ -46528,12 +46618,34 @@ func schema_k8sio_api_resource_v1alpha3_DeviceRequest(ref common.ReferenceCallba
 							Format:      "",
 						},
 					},
+					"capacity": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Capacity defines how much of the named capacities of a device each device allocated for this request consumes. When set, a device may get shared with other requests which also specify capacity, as long as the sum of what all of them consume does not exceed what the device provides. Each named capacity must be provided by the device, otherwise the device is not suitable.\n\nWhen not set, each allocated device is used exclusively.\n\nThis is an alpha field and requires enabling the DRAConsumableCapacity feature gate.",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+									},
+								},
+							},
+						},
+					},
+					"nodeLocalOnly": {
+						SchemaProps: spec.SchemaProps{
+							Description: "NodeLocalOnly restricts the devices for this request to those from resource pools which belong to the node that the pod gets scheduled to, i.e. ResourceSlices which have NodeName set. Devices in pools which are shared by several nodes, i.e. in slices with NodeSelector or AllNodes, are not considered for this request.\n\nThis is an alpha field and requires enabling the DRANodeLocalOnly feature gate.",
+							Default:     false,
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
 				},
 				Required: []string{"name", "deviceClassName"},
 			},
 		},
 		Dependencies: []string{
-			"k8s.io/api/resource/v1alpha3.DeviceSelector"},
+			"k8s.io/api/resource/v1alpha3.DeviceSelector", "k8s.io/apimachinery/pkg/api/resource.Quantity"},
 	}
 }
 
@@ -46576,10 +46688,26 @@ func schema_k8sio_api_resource_v1alpha3_DeviceRequestAllocationResult(ref common
 							Format:      "",
 						},
 					},
+					"consumedCapacity": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ConsumedCapacity records how much of the named capacities of the device is consumed by this allocation. It is set if (and only if) the request specified capacity, in which case the device may be shared with other allocations.\n\nThis is an alpha field and requires enabling the DRAConsumableCapacity feature gate.",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+									},
+								},
+							},
+						},
+					},
 				},
 				Required: []string{"request", "driver", "pool", "device"},
 			},
 		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/api/resource.Quantity"},
 	}
 }
 
@@ -46596,11 +46724,17 @@ func schema_k8sio_api_resource_v1alpha3_DeviceSelector(ref common.ReferenceCallb
 							Ref:         ref("k8s.io/api/resource/v1alpha3.CELDeviceSelector"),
 						},
 					},
+					"attribute": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Attribute compares one attribute of a device against a list of values. It covers common filters without having to write a CEL expression.\n\nThis is an alpha field and requires enabling the DRAAttributeSelectors feature gate.",
+							Ref:         ref("k8s.io/api/resource/v1alpha3.AttributeDeviceSelector"),
+						},
+					},
 				},
 			},
 		},
 		Dependencies: []string{
-			"k8s.io/api/resource/v1alpha3.CELDeviceSelector"},
+			"k8s.io/api/resource/v1alpha3.AttributeDeviceSelector", "k8s.io/api/resource/v1alpha3.CELDeviceSelector"},
 	}
 }
 
@@ -46902,10 +47036,38 @@ func schema_k8sio_api_resource_v1alpha3_ResourceClaimConsumerReference(ref commo
 							Format:      "",
 						},
 					},
+					"reservationDeadline": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ReservationDeadline, if set, is the time until which the consumer is expected to start using the claim. The scheduler sets it for pods when reserving the claim in the binding phase. If the pod has not been bound to a node by then, the reservation may be removed again by a controller.\n\nThis is an alpha field and requires enabling the DRAReservationDeadline feature gate.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"deviceIndices": {
+						VendorExtensible: spec.VendorExtensible{
+							Extensions: spec.Extensions{
+								"x-kubernetes-list-type": "atomic",
+							},
+						},
+						SchemaProps: spec.SchemaProps{
+							Description: "DeviceIndices, if set, are the indices of those entries in status.allocation.devices.results which the consumer uses. If unset, the consumer uses all allocated devices. This is useful when a claim is shared and each consumer only needs some of its devices.\n\nThis is an alpha field and requires enabling the DRAReservedDeviceIndices feature gate.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: 0,
+										Type:    []string{"integer"},
+										Format:  "int32",
+									},
+								},
+							},
+						},
+					},
 				},
 				Required: []string{"resource", "name", "uid"},
 			},
 		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
 	}
 }
 
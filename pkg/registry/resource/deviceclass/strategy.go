@@ -89,8 +89,14 @@ func (deviceClassStrategy) AllowUnconditionalUpdate() bool {
 	return true
 }
 
-// dropDisabledFields removes fields which are covered by the optional DRAControlPlaneController feature gate.
+// dropDisabledFields removes fields which are covered by optional feature gates.
 func dropDisabledFields(newClass, oldClass *resource.DeviceClass) {
+	dropDisabledDRAControlPlaneControllerFields(newClass, oldClass)
+	dropDisabledDRAAttributeSelectorsFields(newClass, oldClass)
+}
+
+// dropDisabledDRAControlPlaneControllerFields removes fields which are covered by the optional DRAControlPlaneController feature gate.
+func dropDisabledDRAControlPlaneControllerFields(newClass, oldClass *resource.DeviceClass) {
 	if utilfeature.DefaultFeatureGate.Enabled(features.DRAControlPlaneController) {
 		// No need to drop anything.
 		return
@@ -107,3 +113,28 @@ func dropDisabledFields(newClass, oldClass *resource.DeviceClass) {
 		newClass.Spec.SuitableNodes = nil
 	}
 }
+
+// dropDisabledDRAAttributeSelectorsFields removes fields which are covered by the optional DRAAttributeSelectors feature gate.
+func dropDisabledDRAAttributeSelectorsFields(newClass, oldClass *resource.DeviceClass) {
+	if utilfeature.DefaultFeatureGate.Enabled(features.DRAAttributeSelectors) {
+		// No need to drop anything.
+		return
+	}
+
+	if oldClass != nil && attributeSelectorsInUse(oldClass.Spec.Selectors) {
+		// Keep what is already stored.
+		return
+	}
+	for i := range newClass.Spec.Selectors {
+		newClass.Spec.Selectors[i].Attribute = nil
+	}
+}
+
+func attributeSelectorsInUse(selectors []resource.DeviceSelector) bool {
+	for _, selector := range selectors {
+		if selector.Attribute != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -172,8 +172,18 @@ func toSelectableFields(claim *resource.ResourceClaim) fields.Set {
 	return fields
 }
 
-// dropDisabledFields removes fields which are covered by the optional DRAControlPlaneController feature gate.
+// dropDisabledFields removes fields which are covered by optional feature gates.
 func dropDisabledFields(newClaim, oldClaim *resource.ResourceClaim) {
+	dropDisabledDRAControlPlaneControllerFields(newClaim, oldClaim)
+	dropDisabledDRAConsumableCapacityFields(newClaim, oldClaim)
+	dropDisabledDRAAttributeSelectorsFields(newClaim, oldClaim)
+	dropDisabledDRANodeLocalOnlyFields(newClaim, oldClaim)
+	dropDisabledDRAReservationDeadlineFields(newClaim, oldClaim)
+	dropDisabledDRAReservedDeviceIndicesFields(newClaim, oldClaim)
+}
+
+// dropDisabledDRAControlPlaneControllerFields removes fields which are covered by the optional DRAControlPlaneController feature gate.
+func dropDisabledDRAControlPlaneControllerFields(newClaim, oldClaim *resource.ResourceClaim) {
 	if utilfeature.DefaultFeatureGate.Enabled(features.DRAControlPlaneController) {
 		// No need to drop anything.
 		return
@@ -204,3 +214,154 @@ func dropDisabledFields(newClaim, oldClaim *resource.ResourceClaim) {
 		newClaim.Status.DeallocationRequested = false
 	}
 }
+
+// dropDisabledDRAConsumableCapacityFields removes fields which are covered by the optional DRAConsumableCapacity feature gate.
+func dropDisabledDRAConsumableCapacityFields(newClaim, oldClaim *resource.ResourceClaim) {
+	if utilfeature.DefaultFeatureGate.Enabled(features.DRAConsumableCapacity) {
+		// No need to drop anything.
+		return
+	}
+
+	if oldClaim == nil || !capacityInUse(oldClaim.Spec.Devices.Requests) {
+		for i := range newClaim.Spec.Devices.Requests {
+			newClaim.Spec.Devices.Requests[i].Capacity = nil
+		}
+	}
+
+	// An allocation which does not record consumed capacity blocks the
+	// device for other claims, so dropping it is safe. An existing
+	// allocation must be preserved.
+	if newClaim.Status.Allocation != nil &&
+		(oldClaim == nil || oldClaim.Status.Allocation == nil || !consumedCapacityInUse(oldClaim.Status.Allocation.Devices.Results)) {
+		for i := range newClaim.Status.Allocation.Devices.Results {
+			newClaim.Status.Allocation.Devices.Results[i].ConsumedCapacity = nil
+		}
+	}
+}
+
+func capacityInUse(requests []resource.DeviceRequest) bool {
+	for _, request := range requests {
+		if len(request.Capacity) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func consumedCapacityInUse(results []resource.DeviceRequestAllocationResult) bool {
+	for _, result := range results {
+		if len(result.ConsumedCapacity) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// dropDisabledDRAAttributeSelectorsFields removes fields which are covered by the optional DRAAttributeSelectors feature gate.
+func dropDisabledDRAAttributeSelectorsFields(newClaim, oldClaim *resource.ResourceClaim) {
+	if utilfeature.DefaultFeatureGate.Enabled(features.DRAAttributeSelectors) {
+		// No need to drop anything.
+		return
+	}
+
+	if oldClaim != nil && attributeSelectorsInUse(oldClaim.Spec.Devices.Requests) {
+		// Keep what is already stored.
+		return
+	}
+	// A selector without any field set fails validation, so
+	// the claim gets rejected instead of silently matching
+	// more devices than intended.
+	for i := range newClaim.Spec.Devices.Requests {
+		for j := range newClaim.Spec.Devices.Requests[i].Selectors {
+			newClaim.Spec.Devices.Requests[i].Selectors[j].Attribute = nil
+		}
+	}
+}
+
+func attributeSelectorsInUse(requests []resource.DeviceRequest) bool {
+	for _, request := range requests {
+		for _, selector := range request.Selectors {
+			if selector.Attribute != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dropDisabledDRANodeLocalOnlyFields removes fields which are covered by the optional DRANodeLocalOnly feature gate.
+func dropDisabledDRANodeLocalOnlyFields(newClaim, oldClaim *resource.ResourceClaim) {
+	if utilfeature.DefaultFeatureGate.Enabled(features.DRANodeLocalOnly) {
+		// No need to drop anything.
+		return
+	}
+
+	if oldClaim != nil && nodeLocalOnlyInUse(oldClaim.Spec.Devices.Requests) {
+		// Keep what is already stored.
+		return
+	}
+	for i := range newClaim.Spec.Devices.Requests {
+		newClaim.Spec.Devices.Requests[i].NodeLocalOnly = false
+	}
+}
+
+func nodeLocalOnlyInUse(requests []resource.DeviceRequest) bool {
+	for _, request := range requests {
+		if request.NodeLocalOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// dropDisabledDRAReservationDeadlineFields removes fields which are covered by the optional DRAReservationDeadline feature gate.
+func dropDisabledDRAReservationDeadlineFields(newClaim, oldClaim *resource.ResourceClaim) {
+	if utilfeature.DefaultFeatureGate.Enabled(features.DRAReservationDeadline) {
+		// No need to drop anything.
+		return
+	}
+
+	if oldClaim != nil && reservationDeadlineInUse(oldClaim.Status.ReservedFor) {
+		// Keep what is already stored.
+		return
+	}
+	for i := range newClaim.Status.ReservedFor {
+		newClaim.Status.ReservedFor[i].ReservationDeadline = nil
+	}
+}
+
+func reservationDeadlineInUse(reservedFor []resource.ResourceClaimConsumerReference) bool {
+	for _, consumer := range reservedFor {
+		if consumer.ReservationDeadline != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// dropDisabledDRAReservedDeviceIndicesFields removes fields which are covered by the optional DRAReservedDeviceIndices feature gate.
+func dropDisabledDRAReservedDeviceIndicesFields(newClaim, oldClaim *resource.ResourceClaim) {
+	if utilfeature.DefaultFeatureGate.Enabled(features.DRAReservedDeviceIndices) {
+		// No need to drop anything.
+		return
+	}
+
+	if oldClaim != nil && deviceIndicesInUse(oldClaim.Status.ReservedFor) {
+		// Keep what is already stored.
+		return
+	}
+	// Without indices, the consumer uses all devices, which is
+	// the safe choice.
+	for i := range newClaim.Status.ReservedFor {
+		newClaim.Status.ReservedFor[i].DeviceIndices = nil
+	}
+}
+
+func deviceIndicesInUse(reservedFor []resource.ResourceClaimConsumerReference) bool {
+	for _, consumer := range reservedFor {
+		if len(consumer.DeviceIndices) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -18,9 +18,11 @@ package resourceclaim
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
 	utilfeature "k8s.io/apiserver/pkg/util/feature"
@@ -72,6 +74,115 @@ var objWithGatedStatusFields = &resource.ResourceClaim{
 	},
 }
 
+var objWithCapacity = &resource.ResourceClaim{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "valid-claim",
+		Namespace: "default",
+	},
+	Spec: resource.ResourceClaimSpec{
+		Devices: resource.DeviceClaim{
+			Requests: []resource.DeviceRequest{{
+				Name:            "req-0",
+				DeviceClassName: "class",
+				AllocationMode:  resource.DeviceAllocationModeExactCount,
+				Count:           1,
+				Capacity: map[resource.QualifiedName]apiresource.Quantity{
+					"memory": apiresource.MustParse("1Gi"),
+				},
+			}},
+		},
+	},
+}
+
+var objWithConsumedCapacity = func() *resource.ResourceClaim {
+	obj := objWithCapacity.DeepCopy()
+	obj.Status.Allocation = &resource.AllocationResult{
+		Devices: resource.DeviceAllocationResult{
+			Results: []resource.DeviceRequestAllocationResult{{
+				Request: "req-0",
+				Driver:  "dra.example.com",
+				Pool:    "pool",
+				Device:  "device",
+				ConsumedCapacity: map[resource.QualifiedName]apiresource.Quantity{
+					"memory": apiresource.MustParse("1Gi"),
+				},
+			}},
+		},
+	}
+	return obj
+}()
+
+var objWithAttributeSelector = &resource.ResourceClaim{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "valid-claim",
+		Namespace: "default",
+	},
+	Spec: resource.ResourceClaimSpec{
+		Devices: resource.DeviceClaim{
+			Requests: []resource.DeviceRequest{{
+				Name:            "req-0",
+				DeviceClassName: "class",
+				AllocationMode:  resource.DeviceAllocationModeExactCount,
+				Count:           1,
+				Selectors: []resource.DeviceSelector{{
+					Attribute: &resource.AttributeDeviceSelector{
+						Name:     "dra.example.com/model",
+						Operator: resource.AttributeSelectorOpIn,
+						Values:   []string{"A100", "H100"},
+					},
+				}},
+			}},
+		},
+	},
+}
+
+var objWithNodeLocalOnly = &resource.ResourceClaim{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "valid-claim",
+		Namespace: "default",
+	},
+	Spec: resource.ResourceClaimSpec{
+		Devices: resource.DeviceClaim{
+			Requests: []resource.DeviceRequest{{
+				Name:            "req-0",
+				DeviceClassName: "class",
+				AllocationMode:  resource.DeviceAllocationModeExactCount,
+				Count:           1,
+				NodeLocalOnly:   true,
+			}},
+		},
+	},
+}
+
+var objWithReservationDeadline = func() *resource.ResourceClaim {
+	obj := objWithStatus.DeepCopy()
+	deadline := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	obj.Status.ReservedFor = []resource.ResourceClaimConsumerReference{{
+		Resource:            "pods",
+		Name:                "pod",
+		UID:                 "pod-uid",
+		ReservationDeadline: &deadline,
+	}}
+	return obj
+}()
+
+var objWithReservation = func() *resource.ResourceClaim {
+	obj := objWithReservationDeadline.DeepCopy()
+	obj.Status.ReservedFor[0].ReservationDeadline = nil
+	return obj
+}()
+
+var objWithDeviceIndices = func() *resource.ResourceClaim {
+	obj := objWithConsumedCapacity.DeepCopy()
+	obj.Status.ReservedFor = []resource.ResourceClaimConsumerReference{{
+		Resource:      "pods",
+		Name:          "pod",
+		UID:           "pod-uid",
+		DeviceIndices: []int32{0},
+	}}
+	return obj
+}()
+
 func TestStrategy(t *testing.T) {
 	if !Strategy.NamespaceScoped() {
 		t.Errorf("ResourceClaim must be namespace scoped")
@@ -87,6 +198,9 @@ func TestStrategyCreate(t *testing.T) {
 	testcases := map[string]struct {
 		obj                    *resource.ResourceClaim
 		controlPlaneController bool
+		consumableCapacity     bool
+		attributeSelectors     bool
+		nodeLocalOnly          bool
 		expectValidationError  bool
 		expectObj              *resource.ResourceClaim
 	}{
@@ -112,11 +226,53 @@ func TestStrategyCreate(t *testing.T) {
 			controlPlaneController: true,
 			expectObj:              objWithGatedFields,
 		},
+		"drop-capacity": {
+			obj:                objWithCapacity,
+			consumableCapacity: false,
+			expectObj: func() *resource.ResourceClaim {
+				obj := objWithCapacity.DeepCopy()
+				obj.Spec.Devices.Requests[0].Capacity = nil
+				return obj
+			}(),
+		},
+		"keep-capacity": {
+			obj:                objWithCapacity,
+			consumableCapacity: true,
+			expectObj:          objWithCapacity,
+		},
+		"drop-attribute-selector": {
+			// The remaining empty selector is invalid.
+			obj:                   objWithAttributeSelector,
+			attributeSelectors:    false,
+			expectValidationError: true,
+		},
+		"keep-attribute-selector": {
+			obj:                objWithAttributeSelector,
+			attributeSelectors: true,
+			expectObj:          objWithAttributeSelector,
+		},
+		"drop-node-local-only": {
+			obj:           objWithNodeLocalOnly,
+			nodeLocalOnly: false,
+			expectObj: func() *resource.ResourceClaim {
+				obj := objWithNodeLocalOnly.DeepCopy()
+				obj.Spec.Devices.Requests[0].NodeLocalOnly = false
+				return obj
+			}(),
+		},
+		"keep-node-local-only": {
+			obj:           objWithNodeLocalOnly,
+			nodeLocalOnly: true,
+			expectObj:     objWithNodeLocalOnly,
+		},
 	}
 
 	for name, tc := range testcases {
 		t.Run(name, func(t *testing.T) {
 			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRAControlPlaneController, tc.controlPlaneController)
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRAConsumableCapacity, tc.consumableCapacity)
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRAAttributeSelectors, tc.attributeSelectors)
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRANodeLocalOnly, tc.nodeLocalOnly)
 
 			obj := tc.obj.DeepCopy()
 			Strategy.PrepareForCreate(ctx, obj)
@@ -144,6 +300,7 @@ func TestStrategyUpdate(t *testing.T) {
 		oldObj                 *resource.ResourceClaim
 		newObj                 *resource.ResourceClaim
 		controlPlaneController bool
+		nodeLocalOnly          bool
 		expectValidationError  bool
 		expectObj              *resource.ResourceClaim
 	}{
@@ -179,11 +336,18 @@ func TestStrategyUpdate(t *testing.T) {
 			controlPlaneController: false,
 			expectObj:              objWithGatedFields,
 		},
+		"keep-existing-node-local-only": {
+			oldObj:        objWithNodeLocalOnly,
+			newObj:        objWithNodeLocalOnly,
+			nodeLocalOnly: false,
+			expectObj:     objWithNodeLocalOnly,
+		},
 	}
 
 	for name, tc := range testcases {
 		t.Run(name, func(t *testing.T) {
 			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRAControlPlaneController, tc.controlPlaneController)
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRANodeLocalOnly, tc.nodeLocalOnly)
 			oldObj := tc.oldObj.DeepCopy()
 			newObj := tc.newObj.DeepCopy()
 			newObj.ResourceVersion = "4"
@@ -216,6 +380,9 @@ func TestStatusStrategyUpdate(t *testing.T) {
 		oldObj                 *resource.ResourceClaim
 		newObj                 *resource.ResourceClaim
 		controlPlaneController bool
+		consumableCapacity     bool
+		reservationDeadline    bool
+		deviceIndices          bool
 		expectValidationError  bool
 		expectObj              *resource.ResourceClaim
 	}{
@@ -285,11 +452,69 @@ func TestStatusStrategyUpdate(t *testing.T) {
 				return oldObj
 			}(),
 		},
+		"drop-consumed-capacity": {
+			oldObj:             objWithCapacity,
+			newObj:             objWithConsumedCapacity,
+			consumableCapacity: false,
+			expectObj: func() *resource.ResourceClaim {
+				obj := objWithConsumedCapacity.DeepCopy()
+				obj.Status.Allocation.Devices.Results[0].ConsumedCapacity = nil
+				return obj
+			}(),
+		},
+		"keep-consumed-capacity": {
+			oldObj:             objWithCapacity,
+			newObj:             objWithConsumedCapacity,
+			consumableCapacity: true,
+			expectObj:          objWithConsumedCapacity,
+		},
+		"drop-reservation-deadline": {
+			oldObj:    objWithStatus,
+			newObj:    objWithReservationDeadline,
+			expectObj: objWithReservation,
+		},
+		"keep-reservation-deadline": {
+			oldObj:              objWithStatus,
+			newObj:              objWithReservationDeadline,
+			reservationDeadline: true,
+			expectObj:           objWithReservationDeadline,
+		},
+		"keep-existing-reservation-deadline": {
+			oldObj:    objWithReservationDeadline,
+			newObj:    objWithReservationDeadline,
+			expectObj: objWithReservationDeadline,
+		},
+		"drop-device-indices": {
+			oldObj:             objWithConsumedCapacity,
+			newObj:             objWithDeviceIndices,
+			consumableCapacity: true,
+			expectObj: func() *resource.ResourceClaim {
+				obj := objWithDeviceIndices.DeepCopy()
+				obj.Status.ReservedFor[0].DeviceIndices = nil
+				return obj
+			}(),
+		},
+		"keep-device-indices": {
+			oldObj:             objWithConsumedCapacity,
+			newObj:             objWithDeviceIndices,
+			consumableCapacity: true,
+			deviceIndices:      true,
+			expectObj:          objWithDeviceIndices,
+		},
+		"keep-existing-device-indices": {
+			oldObj:             objWithDeviceIndices,
+			newObj:             objWithDeviceIndices,
+			consumableCapacity: true,
+			expectObj:          objWithDeviceIndices,
+		},
 	}
 
 	for name, tc := range testcases {
 		t.Run(name, func(t *testing.T) {
 			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRAControlPlaneController, tc.controlPlaneController)
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRAConsumableCapacity, tc.consumableCapacity)
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRAReservationDeadline, tc.reservationDeadline)
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRAReservedDeviceIndices, tc.deviceIndices)
 			oldObj := tc.oldObj.DeepCopy()
 			newObj := tc.newObj.DeepCopy()
 			newObj.ResourceVersion = "4"
@@ -26,9 +26,11 @@ import (
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apiserver/pkg/registry/generic"
 	"k8s.io/apiserver/pkg/storage/names"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	"k8s.io/kubernetes/pkg/api/legacyscheme"
 	"k8s.io/kubernetes/pkg/apis/resource"
 	"k8s.io/kubernetes/pkg/apis/resource/validation"
+	"k8s.io/kubernetes/pkg/features"
 )
 
 // resourceClaimTemplateStrategy implements behavior for ResourceClaimTemplate objects
@@ -44,6 +46,8 @@ func (resourceClaimTemplateStrategy) NamespaceScoped() bool {
 }
 
 func (resourceClaimTemplateStrategy) PrepareForCreate(ctx context.Context, obj runtime.Object) {
+	template := obj.(*resource.ResourceClaimTemplate)
+	dropDisabledFields(template, nil)
 }
 
 func (resourceClaimTemplateStrategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
@@ -63,6 +67,9 @@ func (resourceClaimTemplateStrategy) AllowCreateOnUpdate() bool {
 }
 
 func (resourceClaimTemplateStrategy) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
+	template := obj.(*resource.ResourceClaimTemplate)
+	oldTemplate := old.(*resource.ResourceClaimTemplate)
+	dropDisabledFields(template, oldTemplate)
 }
 
 func (resourceClaimTemplateStrategy) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
@@ -92,3 +99,89 @@ func toSelectableFields(template *resource.ResourceClaimTemplate) fields.Set {
 	fields := generic.ObjectMetaFieldsSet(&template.ObjectMeta, true)
 	return fields
 }
+
+// dropDisabledFields removes fields which are covered by optional feature gates.
+func dropDisabledFields(newTemplate, oldTemplate *resource.ResourceClaimTemplate) {
+	dropDisabledDRAConsumableCapacityFields(newTemplate, oldTemplate)
+	dropDisabledDRAAttributeSelectorsFields(newTemplate, oldTemplate)
+	dropDisabledDRANodeLocalOnlyFields(newTemplate, oldTemplate)
+}
+
+// dropDisabledDRAConsumableCapacityFields removes fields which are covered by the optional DRAConsumableCapacity feature gate.
+func dropDisabledDRAConsumableCapacityFields(newTemplate, oldTemplate *resource.ResourceClaimTemplate) {
+	if utilfeature.DefaultFeatureGate.Enabled(features.DRAConsumableCapacity) {
+		// No need to drop anything.
+		return
+	}
+
+	if oldTemplate != nil && capacityInUse(oldTemplate.Spec.Spec.Devices.Requests) {
+		// Keep what is already stored.
+		return
+	}
+	for i := range newTemplate.Spec.Spec.Devices.Requests {
+		newTemplate.Spec.Spec.Devices.Requests[i].Capacity = nil
+	}
+}
+
+// dropDisabledDRAAttributeSelectorsFields removes fields which are covered by the optional DRAAttributeSelectors feature gate.
+func dropDisabledDRAAttributeSelectorsFields(newTemplate, oldTemplate *resource.ResourceClaimTemplate) {
+	if utilfeature.DefaultFeatureGate.Enabled(features.DRAAttributeSelectors) {
+		// No need to drop anything.
+		return
+	}
+
+	if oldTemplate != nil && attributeSelectorsInUse(oldTemplate.Spec.Spec.Devices.Requests) {
+		// Keep what is already stored.
+		return
+	}
+	for i := range newTemplate.Spec.Spec.Devices.Requests {
+		for j := range newTemplate.Spec.Spec.Devices.Requests[i].Selectors {
+			newTemplate.Spec.Spec.Devices.Requests[i].Selectors[j].Attribute = nil
+		}
+	}
+}
+
+// dropDisabledDRANodeLocalOnlyFields removes fields which are covered by the optional DRANodeLocalOnly feature gate.
+func dropDisabledDRANodeLocalOnlyFields(newTemplate, oldTemplate *resource.ResourceClaimTemplate) {
+	if utilfeature.DefaultFeatureGate.Enabled(features.DRANodeLocalOnly) {
+		// No need to drop anything.
+		return
+	}
+
+	if oldTemplate != nil && nodeLocalOnlyInUse(oldTemplate.Spec.Spec.Devices.Requests) {
+		// Keep what is already stored.
+		return
+	}
+	for i := range newTemplate.Spec.Spec.Devices.Requests {
+		newTemplate.Spec.Spec.Devices.Requests[i].NodeLocalOnly = false
+	}
+}
+
+func capacityInUse(requests []resource.DeviceRequest) bool {
+	for _, request := range requests {
+		if len(request.Capacity) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func attributeSelectorsInUse(requests []resource.DeviceRequest) bool {
+	for _, request := range requests {
+		for _, selector := range request.Selectors {
+			if selector.Attribute != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func nodeLocalOnlyInUse(requests []resource.DeviceRequest) bool {
+	for _, request := range requests {
+		if request.NodeLocalOnly {
+			return true
+		}
+	}
+	return false
+}
@@ -19,9 +19,14 @@ package resourceclaimtemplate
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
 	"k8s.io/kubernetes/pkg/apis/resource"
+	"k8s.io/kubernetes/pkg/features"
 )
 
 var resourceClaimTemplate = &resource.ResourceClaimTemplate{
@@ -31,6 +36,18 @@ var resourceClaimTemplate = &resource.ResourceClaimTemplate{
 	},
 }
 
+var resourceClaimTemplateWithNodeLocalOnly = func() *resource.ResourceClaimTemplate {
+	template := resourceClaimTemplate.DeepCopy()
+	template.Spec.Spec.Devices.Requests = []resource.DeviceRequest{{
+		Name:            "req-0",
+		DeviceClassName: "class",
+		AllocationMode:  resource.DeviceAllocationModeExactCount,
+		Count:           1,
+		NodeLocalOnly:   true,
+	}}
+	return template
+}()
+
 func TestClaimTemplateStrategy(t *testing.T) {
 	if !Strategy.NamespaceScoped() {
 		t.Errorf("ResourceClaimTemplate must be namespace scoped")
@@ -79,3 +96,56 @@ func TestClaimTemplateStrategyUpdate(t *testing.T) {
 		}
 	})
 }
+
+func TestClaimTemplateStrategyNodeLocalOnly(t *testing.T) {
+	ctx := genericapirequest.NewDefaultContext()
+	withoutNodeLocalOnly := resourceClaimTemplateWithNodeLocalOnly.DeepCopy()
+	withoutNodeLocalOnly.Spec.Spec.Devices.Requests[0].NodeLocalOnly = false
+
+	testcases := map[string]struct {
+		oldObj        *resource.ResourceClaimTemplate
+		newObj        *resource.ResourceClaimTemplate
+		nodeLocalOnly bool
+		expectObj     *resource.ResourceClaimTemplate
+	}{
+		"drop-on-create": {
+			newObj:        resourceClaimTemplateWithNodeLocalOnly,
+			nodeLocalOnly: false,
+			expectObj:     withoutNodeLocalOnly,
+		},
+		"keep-on-create": {
+			newObj:        resourceClaimTemplateWithNodeLocalOnly,
+			nodeLocalOnly: true,
+			expectObj:     resourceClaimTemplateWithNodeLocalOnly,
+		},
+		"keep-existing-on-update": {
+			oldObj:        resourceClaimTemplateWithNodeLocalOnly,
+			newObj:        resourceClaimTemplateWithNodeLocalOnly,
+			nodeLocalOnly: false,
+			expectObj:     resourceClaimTemplateWithNodeLocalOnly,
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRANodeLocalOnly, tc.nodeLocalOnly)
+			newObj := tc.newObj.DeepCopy()
+			if tc.oldObj == nil {
+				Strategy.PrepareForCreate(ctx, newObj)
+				if errs := Strategy.Validate(ctx, newObj); len(errs) != 0 {
+					t.Fatalf("unexpected validation errors: %q", errs)
+				}
+			} else {
+				oldObj := tc.oldObj.DeepCopy()
+				newObj.ResourceVersion = "4"
+				Strategy.PrepareForUpdate(ctx, newObj, oldObj)
+				if errs := Strategy.ValidateUpdate(ctx, newObj, oldObj); len(errs) != 0 {
+					t.Fatalf("unexpected validation errors: %q", errs)
+				}
+			}
+			expectObj := tc.expectObj.DeepCopy()
+			expectObj.ResourceVersion = newObj.ResourceVersion
+			assert.Equal(t, expectObj, newObj)
+		})
+	}
+}
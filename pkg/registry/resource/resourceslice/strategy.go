@@ -28,10 +28,12 @@ import (
 	"k8s.io/apiserver/pkg/registry/generic"
 	"k8s.io/apiserver/pkg/storage"
 	"k8s.io/apiserver/pkg/storage/names"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/kubernetes/pkg/api/legacyscheme"
 	"k8s.io/kubernetes/pkg/apis/resource"
 	"k8s.io/kubernetes/pkg/apis/resource/validation"
+	"k8s.io/kubernetes/pkg/features"
 )
 
 // resourceSliceStrategy implements behavior for ResourceSlice objects
@@ -49,6 +51,8 @@ func (resourceSliceStrategy) NamespaceScoped() bool {
 func (resourceSliceStrategy) PrepareForCreate(ctx context.Context, obj runtime.Object) {
 	slice := obj.(*resource.ResourceSlice)
 	slice.Generation = 1
+
+	dropDisabledFields(slice, nil)
 }
 
 func (resourceSliceStrategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
@@ -71,6 +75,8 @@ func (resourceSliceStrategy) PrepareForUpdate(ctx context.Context, obj, old runt
 	slice := obj.(*resource.ResourceSlice)
 	oldSlice := old.(*resource.ResourceSlice)
 
+	dropDisabledFields(slice, oldSlice)
+
 	// Any changes to the spec increment the generation number.
 	if !apiequality.Semantic.DeepEqual(oldSlice.Spec, slice.Spec) {
 		slice.Generation = oldSlice.Generation + 1
@@ -147,3 +153,77 @@ func toSelectableFields(slice *resource.ResourceSlice) fields.Set {
 	// Adds one field.
 	return generic.AddObjectMetaFieldsSet(fields, &slice.ObjectMeta, false)
 }
+
+// dropDisabledFields removes fields which are covered by optional feature gates.
+func dropDisabledFields(newSlice, oldSlice *resource.ResourceSlice) {
+	dropDisabledDRADeviceTaintsFields(newSlice, oldSlice)
+	dropDisabledDRAStringListAttributesFields(newSlice, oldSlice)
+}
+
+// dropDisabledDRADeviceTaintsFields removes fields which are covered by the optional DRADeviceTaints feature gate.
+func dropDisabledDRADeviceTaintsFields(newSlice, oldSlice *resource.ResourceSlice) {
+	if utilfeature.DefaultFeatureGate.Enabled(features.DRADeviceTaints) {
+		// No need to drop anything.
+		return
+	}
+
+	if oldSlice != nil && deviceTaintsInUse(oldSlice) {
+		// Keep what is already stored.
+		return
+	}
+	for _, device := range newSlice.Spec.Devices {
+		if device.Basic != nil {
+			device.Basic.Taints = nil
+		}
+	}
+}
+
+func deviceTaintsInUse(slice *resource.ResourceSlice) bool {
+	for _, device := range slice.Spec.Devices {
+		if device.Basic != nil && len(device.Basic.Taints) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// dropDisabledDRAStringListAttributesFields removes fields which are covered by the optional DRAStringListAttributes feature gate.
+func dropDisabledDRAStringListAttributesFields(newSlice, oldSlice *resource.ResourceSlice) {
+	if utilfeature.DefaultFeatureGate.Enabled(features.DRAStringListAttributes) {
+		// No need to drop anything.
+		return
+	}
+
+	if oldSlice != nil && stringListAttributesInUse(oldSlice) {
+		// Keep what is already stored.
+		return
+	}
+	// An attribute without any value fails validation, so the
+	// slice gets rejected instead of silently publishing devices
+	// without the attribute.
+	for _, device := range newSlice.Spec.Devices {
+		if device.Basic == nil {
+			continue
+		}
+		for name, attribute := range device.Basic.Attributes {
+			if attribute.StringListValue != nil {
+				attribute.StringListValue = nil
+				device.Basic.Attributes[name] = attribute
+			}
+		}
+	}
+}
+
+func stringListAttributesInUse(slice *resource.ResourceSlice) bool {
+	for _, device := range slice.Spec.Devices {
+		if device.Basic == nil {
+			continue
+		}
+		for _, attribute := range device.Basic.Attributes {
+			if attribute.StringListValue != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -19,9 +19,16 @@ package resourceslice
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+	"k8s.io/kubernetes/pkg/apis/core"
 	"k8s.io/kubernetes/pkg/apis/resource"
+	"k8s.io/kubernetes/pkg/features"
 )
 
 var slice = &resource.ResourceSlice{
@@ -86,3 +93,141 @@ func TestResourceSliceStrategyUpdate(t *testing.T) {
 		}
 	})
 }
+
+var sliceWithTaints = func() *resource.ResourceSlice {
+	slice := slice.DeepCopy()
+	slice.Spec.Devices = []resource.Device{{
+		Name: "device",
+		Basic: &resource.BasicDevice{
+			Taints: []core.Taint{{Key: "example.com/experimental", Effect: core.TaintEffectNoSchedule}},
+		},
+	}}
+	return slice
+}()
+
+func TestResourceSliceStrategyDeviceTaints(t *testing.T) {
+	sliceWithoutTaints := func() *resource.ResourceSlice {
+		slice := sliceWithTaints.DeepCopy()
+		slice.Spec.Devices[0].Basic.Taints = nil
+		return slice
+	}()
+
+	testcases := map[string]struct {
+		oldObj    *resource.ResourceSlice
+		newObj    *resource.ResourceSlice
+		enabled   bool
+		expectObj *resource.ResourceSlice
+	}{
+		"create-drop-taints": {
+			newObj:    sliceWithTaints,
+			expectObj: sliceWithoutTaints,
+		},
+		"create-keep-taints": {
+			newObj:    sliceWithTaints,
+			enabled:   true,
+			expectObj: sliceWithTaints,
+		},
+		"update-drop-taints": {
+			oldObj:    sliceWithoutTaints,
+			newObj:    sliceWithTaints,
+			expectObj: sliceWithoutTaints,
+		},
+		"update-keep-existing-taints": {
+			oldObj:    sliceWithTaints,
+			newObj:    sliceWithTaints,
+			expectObj: sliceWithTaints,
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRADeviceTaints, tc.enabled)
+			ctx := genericapirequest.NewDefaultContext()
+			obj := tc.newObj.DeepCopy()
+			if tc.oldObj == nil {
+				Strategy.PrepareForCreate(ctx, obj)
+				assert.Empty(t, Strategy.Validate(ctx, obj), "validate")
+			} else {
+				obj.ResourceVersion = "4"
+				Strategy.PrepareForUpdate(ctx, obj, tc.oldObj.DeepCopy())
+				assert.Empty(t, Strategy.ValidateUpdate(ctx, obj, tc.oldObj), "validate update")
+			}
+			assert.Equal(t, tc.expectObj.Spec, obj.Spec, "spec")
+		})
+	}
+}
+
+var sliceWithStringList = func() *resource.ResourceSlice {
+	slice := slice.DeepCopy()
+	slice.Spec.Devices = []resource.Device{{
+		Name: "device",
+		Basic: &resource.BasicDevice{
+			Attributes: map[resource.QualifiedName]resource.DeviceAttribute{
+				"precisions": {StringListValue: []string{"fp16", "fp32"}},
+			},
+		},
+	}}
+	return slice
+}()
+
+func TestResourceSliceStrategyStringListAttributes(t *testing.T) {
+	sliceWithoutStringList := func() *resource.ResourceSlice {
+		slice := sliceWithStringList.DeepCopy()
+		slice.Spec.Devices[0].Basic.Attributes["precisions"] = resource.DeviceAttribute{}
+		return slice
+	}()
+
+	testcases := map[string]struct {
+		oldObj                *resource.ResourceSlice
+		newObj                *resource.ResourceSlice
+		enabled               bool
+		expectValidationError bool
+		expectObj             *resource.ResourceSlice
+	}{
+		"create-drop-string-list": {
+			// The remaining attribute without a value is invalid.
+			newObj:                sliceWithStringList,
+			expectValidationError: true,
+			expectObj:             sliceWithoutStringList,
+		},
+		"create-keep-string-list": {
+			newObj:    sliceWithStringList,
+			enabled:   true,
+			expectObj: sliceWithStringList,
+		},
+		"update-drop-string-list": {
+			oldObj:                slice,
+			newObj:                sliceWithStringList,
+			expectValidationError: true,
+			expectObj:             sliceWithoutStringList,
+		},
+		"update-keep-existing-string-list": {
+			oldObj:    sliceWithStringList,
+			newObj:    sliceWithStringList,
+			expectObj: sliceWithStringList,
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.DRAStringListAttributes, tc.enabled)
+			ctx := genericapirequest.NewDefaultContext()
+			obj := tc.newObj.DeepCopy()
+			var errs field.ErrorList
+			if tc.oldObj == nil {
+				Strategy.PrepareForCreate(ctx, obj)
+				errs = Strategy.Validate(ctx, obj)
+			} else {
+				obj.ResourceVersion = "4"
+				Strategy.PrepareForUpdate(ctx, obj, tc.oldObj.DeepCopy())
+				errs = Strategy.ValidateUpdate(ctx, obj, tc.oldObj)
+			}
+			if tc.expectValidationError {
+				assert.NotEmpty(t, errs, "validation errors")
+			} else {
+				assert.Empty(t, errs, "validation errors")
+			}
+			assert.Equal(t, tc.expectObj.Spec, obj.Spec, "spec")
+		})
+	}
+}
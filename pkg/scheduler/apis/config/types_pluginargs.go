@@ -216,3 +216,151 @@ type RequestedToCapacityRatioParam struct {
 	// Shape is a list of points defining the scoring function shape.
 	Shape []UtilizationShapePoint
 }
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DynamicResourcesArgs holds arguments used to configure the DynamicResources plugin.
+type DynamicResourcesArgs struct {
+	metav1.TypeMeta
+
+	// WriteStrategy determines how the plugin writes the status of
+	// ResourceClaims.
+	WriteStrategy WriteStrategyType
+
+	// DeviceQuotas limit how many devices of a class the plugin may
+	// allocate for claims in a namespace or in the entire cluster.
+	DeviceQuotas []DeviceQuota
+
+	// DeviceSelectionPolicy determines which device the plugin picks
+	// for a request which consumes some capacity of a device.
+	DeviceSelectionPolicy DeviceSelectionPolicyType
+
+	// ResourceSliceMaxAgeSeconds is the maximum age of the heartbeat of
+	// a ResourceSlice. Slices with an older heartbeat are ignored. Zero
+	// disables the check.
+	ResourceSliceMaxAgeSeconds int64
+
+	// MissingAttributeBehavior determines how the plugin handles device
+	// selectors which reference attributes that a device doesn't have.
+	MissingAttributeBehavior MissingAttributeBehaviorType
+
+	// ClaimEventCoalescingMilliseconds is the time window during which
+	// repeated claim events for the same pod only requeue it once. Zero
+	// disables coalescing.
+	ClaimEventCoalescingMilliseconds int64
+
+	// FailedAttemptsBeforeEscalation is the number of failed scheduling
+	// attempts after which the plugin handles a pod more aggressively.
+	// Zero disables escalation.
+	FailedAttemptsBeforeEscalation int32
+
+	// ValidateClaimTemplates enables checking that claims which were
+	// generated from a ResourceClaimTemplate still match it.
+	ValidateClaimTemplates bool
+
+	// EnablePostFilterDeallocation determines whether PostFilter may
+	// deallocate claims to make a pod schedulable.
+	EnablePostFilterDeallocation bool
+
+	// AllowedNamespaces restricts the plugin to pods in the listed
+	// namespaces. Empty allows all namespaces.
+	AllowedNamespaces []string
+
+	// NamespaceSelector restricts the plugin to pods in namespaces with
+	// matching labels. Nil allows all namespaces.
+	NamespaceSelector *metav1.LabelSelector
+
+	// RecordAllocatedDeviceClasses enables storing the DeviceClasses
+	// that were used for allocating a claim in an annotation of the claim.
+	RecordAllocatedDeviceClasses bool
+
+	// PreferReservedClaims determines whether PostFilter keeps claims
+	// which are already reserved for the pod instead of deallocating them.
+	PreferReservedClaims bool
+
+	// SkipClassDriverCheck disables rejecting pods in PreFilter when a
+	// DeviceClass selects attributes of a driver without ResourceSlices.
+	SkipClassDriverCheck bool
+
+	// KeepAllocationOnTopologyMismatch determines whether PostFilter keeps
+	// allocated claims which cannot be used on any of the candidate nodes
+	// instead of deallocating them. Claims can override this with an
+	// annotation.
+	KeepAllocationOnTopologyMismatch bool
+
+	// DriverReadinessSeconds is the maximum time since the generation of
+	// a pool that a driver publishes for a node was updated. Nodes where
+	// a driver needed for a new allocation has no recently updated pool
+	// are rejected. Zero disables the check.
+	DriverReadinessSeconds int64
+
+	// AllowedCELFunctionGroups restricts which groups of optional
+	// functions CEL selectors may call. Empty allows all functions.
+	AllowedCELFunctionGroups []string
+
+	// Disabled turns off the handling of ResourceClaims in this profile.
+	// Pods with claims are unschedulable then. The zero value keeps the
+	// plugin enabled, so args which were not defaulted do not disable it.
+	Disabled bool
+
+	// VerifyAllocatedSelectors enables emitting warning events for claims
+	// whose allocated devices no longer match their selectors after
+	// a ResourceSlice update.
+	VerifyAllocatedSelectors bool
+
+	// AllowTerminatingDeviceClasses determines whether claims may get
+	// allocated with a DeviceClass which is being deleted.
+	AllowTerminatingDeviceClasses bool
+}
+
+// DeviceQuota limits the number of devices of one class which may be
+// allocated for claims in one namespace or, without a namespace, for
+// all claims in the cluster.
+type DeviceQuota struct {
+	// Namespace of the claims, empty for all namespaces.
+	Namespace string
+	// DeviceClassName is the name of the DeviceClass.
+	DeviceClassName string
+	// MaxDevices is the maximum number of allocated devices.
+	MaxDevices int32
+}
+
+// WriteStrategyType defines how the DynamicResources plugin writes changes
+// of ResourceClaim status to the apiserver.
+type WriteStrategyType string
+
+const (
+	// UpdateWriteStrategy replaces the status with an update. Concurrent
+	// changes of the claim cause a conflict and the plugin retries with
+	// the latest claim.
+	UpdateWriteStrategy WriteStrategyType = "Update"
+	// PatchWriteStrategy only sends the changes with a patch. Adding
+	// the pod to the claim's ReservedFor list does not conflict with
+	// concurrent changes, everything else still does.
+	PatchWriteStrategy WriteStrategyType = "Patch"
+)
+
+// DeviceSelectionPolicyType defines which device the DynamicResources plugin
+// picks when several devices could satisfy a request.
+type DeviceSelectionPolicyType string
+
+const (
+	// FirstFitDeviceSelectionPolicy picks the first suitable device.
+	FirstFitDeviceSelectionPolicy DeviceSelectionPolicyType = "FirstFit"
+	// BestFitDeviceSelectionPolicy picks the device which is left with
+	// the least unused capacity.
+	BestFitDeviceSelectionPolicy DeviceSelectionPolicyType = "BestFit"
+)
+
+// MissingAttributeBehaviorType defines how the DynamicResources plugin handles
+// CEL selectors which look up an attribute that a device doesn't have.
+type MissingAttributeBehaviorType string
+
+const (
+	// ErrorMissingAttributeBehavior treats the lookup as an error which
+	// keeps the pod pending.
+	ErrorMissingAttributeBehavior MissingAttributeBehaviorType = "Error"
+	// ExcludeMissingAttributeBehavior treats the device as not matching
+	// the selector.
+	ExcludeMissingAttributeBehavior MissingAttributeBehaviorType = "Exclude"
+)
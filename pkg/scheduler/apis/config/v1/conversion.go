@@ -25,6 +25,7 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	v1 "k8s.io/kube-scheduler/config/v1"
 	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/utils/ptr"
 )
 
 var (
@@ -83,6 +84,27 @@ func Convert_config_KubeSchedulerConfiguration_To_v1_KubeSchedulerConfiguration(
 	return convertToExternalPluginConfigArgs(out)
 }
 
+// Convert_v1_DynamicResourcesArgs_To_config_DynamicResourcesArgs converts the
+// optional Enabled field into the internal Disabled field. Unset means
+// enabled.
+func Convert_v1_DynamicResourcesArgs_To_config_DynamicResourcesArgs(in *v1.DynamicResourcesArgs, out *config.DynamicResourcesArgs, s conversion.Scope) error {
+	if err := autoConvert_v1_DynamicResourcesArgs_To_config_DynamicResourcesArgs(in, out, s); err != nil {
+		return err
+	}
+	out.Disabled = in.Enabled != nil && !*in.Enabled
+	return nil
+}
+
+// Convert_config_DynamicResourcesArgs_To_v1_DynamicResourcesArgs converts the
+// internal Disabled field into the Enabled field.
+func Convert_config_DynamicResourcesArgs_To_v1_DynamicResourcesArgs(in *config.DynamicResourcesArgs, out *v1.DynamicResourcesArgs, s conversion.Scope) error {
+	if err := autoConvert_config_DynamicResourcesArgs_To_v1_DynamicResourcesArgs(in, out, s); err != nil {
+		return err
+	}
+	out.Enabled = ptr.To(!in.Disabled)
+	return nil
+}
+
 // convertToExternalPluginConfigArgs converts PluginConfig#Args into
 // external (versioned) types using a scheme.
 func convertToExternalPluginConfigArgs(out *v1.KubeSchedulerConfiguration) error {
@@ -242,3 +242,27 @@ func SetDefaults_NodeResourcesFitArgs(obj *configv1.NodeResourcesFitArgs) {
 		}
 	}
 }
+
+func SetDefaults_DynamicResourcesArgs(obj *configv1.DynamicResourcesArgs) {
+	if obj.WriteStrategy == "" {
+		obj.WriteStrategy = configv1.UpdateWriteStrategy
+	}
+	if obj.DeviceSelectionPolicy == "" {
+		obj.DeviceSelectionPolicy = configv1.BestFitDeviceSelectionPolicy
+	}
+	if obj.MissingAttributeBehavior == "" {
+		obj.MissingAttributeBehavior = configv1.ErrorMissingAttributeBehavior
+	}
+	if obj.FailedAttemptsBeforeEscalation == nil {
+		obj.FailedAttemptsBeforeEscalation = ptr.To[int32](10)
+	}
+	if obj.EnablePostFilterDeallocation == nil {
+		obj.EnablePostFilterDeallocation = ptr.To(true)
+	}
+	if obj.PreferReservedClaims == nil {
+		obj.PreferReservedClaims = ptr.To(true)
+	}
+	if obj.Enabled == nil {
+		obj.Enabled = ptr.To(true)
+	}
+}
@@ -833,6 +833,40 @@ func TestPluginArgsDefaults(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "DynamicResourcesArgs empty",
+			in:   &configv1.DynamicResourcesArgs{},
+			want: &configv1.DynamicResourcesArgs{
+				WriteStrategy:                  configv1.UpdateWriteStrategy,
+				DeviceSelectionPolicy:          configv1.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior:       configv1.ErrorMissingAttributeBehavior,
+				FailedAttemptsBeforeEscalation: ptr.To[int32](10),
+				EnablePostFilterDeallocation:   ptr.To(true),
+				PreferReservedClaims:           ptr.To(true),
+				Enabled:                        ptr.To(true),
+			},
+		},
+		{
+			name: "DynamicResourcesArgs with value",
+			in: &configv1.DynamicResourcesArgs{
+				WriteStrategy:                  configv1.PatchWriteStrategy,
+				DeviceSelectionPolicy:          configv1.FirstFitDeviceSelectionPolicy,
+				MissingAttributeBehavior:       configv1.ExcludeMissingAttributeBehavior,
+				FailedAttemptsBeforeEscalation: ptr.To[int32](0),
+				EnablePostFilterDeallocation:   ptr.To(false),
+				PreferReservedClaims:           ptr.To(false),
+				Enabled:                        ptr.To(false),
+			},
+			want: &configv1.DynamicResourcesArgs{
+				WriteStrategy:                  configv1.PatchWriteStrategy,
+				DeviceSelectionPolicy:          configv1.FirstFitDeviceSelectionPolicy,
+				MissingAttributeBehavior:       configv1.ExcludeMissingAttributeBehavior,
+				FailedAttemptsBeforeEscalation: ptr.To[int32](0),
+				EnablePostFilterDeallocation:   ptr.To(false),
+				PreferReservedClaims:           ptr.To(false),
+				Enabled:                        ptr.To(false),
+			},
+		},
 	}
 	for _, tc := range tests {
 		scheme := runtime.NewScheme()
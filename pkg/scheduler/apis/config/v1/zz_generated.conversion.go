@@ -50,6 +50,16 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddGeneratedConversionFunc((*v1.DeviceQuota)(nil), (*config.DeviceQuota)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1_DeviceQuota_To_config_DeviceQuota(a.(*v1.DeviceQuota), b.(*config.DeviceQuota), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*config.DeviceQuota)(nil), (*v1.DeviceQuota)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_config_DeviceQuota_To_v1_DeviceQuota(a.(*config.DeviceQuota), b.(*v1.DeviceQuota), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddGeneratedConversionFunc((*v1.Extender)(nil), (*config.Extender)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1_Extender_To_config_Extender(a.(*v1.Extender), b.(*config.Extender), scope)
 	}); err != nil {
@@ -230,11 +240,21 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*config.DynamicResourcesArgs)(nil), (*v1.DynamicResourcesArgs)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_config_DynamicResourcesArgs_To_v1_DynamicResourcesArgs(a.(*config.DynamicResourcesArgs), b.(*v1.DynamicResourcesArgs), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*config.KubeSchedulerConfiguration)(nil), (*v1.KubeSchedulerConfiguration)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_config_KubeSchedulerConfiguration_To_v1_KubeSchedulerConfiguration(a.(*config.KubeSchedulerConfiguration), b.(*v1.KubeSchedulerConfiguration), scope)
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1.DynamicResourcesArgs)(nil), (*config.DynamicResourcesArgs)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1_DynamicResourcesArgs_To_config_DynamicResourcesArgs(a.(*v1.DynamicResourcesArgs), b.(*config.DynamicResourcesArgs), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*v1.KubeSchedulerConfiguration)(nil), (*config.KubeSchedulerConfiguration)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1_KubeSchedulerConfiguration_To_config_KubeSchedulerConfiguration(a.(*v1.KubeSchedulerConfiguration), b.(*config.KubeSchedulerConfiguration), scope)
 	}); err != nil {
@@ -273,6 +293,90 @@ func Convert_config_DefaultPreemptionArgs_To_v1_DefaultPreemptionArgs(in *config
 	return autoConvert_config_DefaultPreemptionArgs_To_v1_DefaultPreemptionArgs(in, out, s)
 }
 
+func autoConvert_v1_DeviceQuota_To_config_DeviceQuota(in *v1.DeviceQuota, out *config.DeviceQuota, s conversion.Scope) error {
+	out.Namespace = in.Namespace
+	out.DeviceClassName = in.DeviceClassName
+	out.MaxDevices = in.MaxDevices
+	return nil
+}
+
+// Convert_v1_DeviceQuota_To_config_DeviceQuota is an autogenerated conversion function.
+func Convert_v1_DeviceQuota_To_config_DeviceQuota(in *v1.DeviceQuota, out *config.DeviceQuota, s conversion.Scope) error {
+	return autoConvert_v1_DeviceQuota_To_config_DeviceQuota(in, out, s)
+}
+
+func autoConvert_config_DeviceQuota_To_v1_DeviceQuota(in *config.DeviceQuota, out *v1.DeviceQuota, s conversion.Scope) error {
+	out.Namespace = in.Namespace
+	out.DeviceClassName = in.DeviceClassName
+	out.MaxDevices = in.MaxDevices
+	return nil
+}
+
+// Convert_config_DeviceQuota_To_v1_DeviceQuota is an autogenerated conversion function.
+func Convert_config_DeviceQuota_To_v1_DeviceQuota(in *config.DeviceQuota, out *v1.DeviceQuota, s conversion.Scope) error {
+	return autoConvert_config_DeviceQuota_To_v1_DeviceQuota(in, out, s)
+}
+
+func autoConvert_v1_DynamicResourcesArgs_To_config_DynamicResourcesArgs(in *v1.DynamicResourcesArgs, out *config.DynamicResourcesArgs, s conversion.Scope) error {
+	out.WriteStrategy = config.WriteStrategyType(in.WriteStrategy)
+	out.DeviceQuotas = *(*[]config.DeviceQuota)(unsafe.Pointer(&in.DeviceQuotas))
+	out.DeviceSelectionPolicy = config.DeviceSelectionPolicyType(in.DeviceSelectionPolicy)
+	out.ResourceSliceMaxAgeSeconds = in.ResourceSliceMaxAgeSeconds
+	out.MissingAttributeBehavior = config.MissingAttributeBehaviorType(in.MissingAttributeBehavior)
+	out.ClaimEventCoalescingMilliseconds = in.ClaimEventCoalescingMilliseconds
+	if err := metav1.Convert_Pointer_int32_To_int32(&in.FailedAttemptsBeforeEscalation, &out.FailedAttemptsBeforeEscalation, s); err != nil {
+		return err
+	}
+	out.ValidateClaimTemplates = in.ValidateClaimTemplates
+	if err := metav1.Convert_Pointer_bool_To_bool(&in.EnablePostFilterDeallocation, &out.EnablePostFilterDeallocation, s); err != nil {
+		return err
+	}
+	out.AllowedNamespaces = *(*[]string)(unsafe.Pointer(&in.AllowedNamespaces))
+	out.NamespaceSelector = (*metav1.LabelSelector)(unsafe.Pointer(in.NamespaceSelector))
+	out.RecordAllocatedDeviceClasses = in.RecordAllocatedDeviceClasses
+	if err := metav1.Convert_Pointer_bool_To_bool(&in.PreferReservedClaims, &out.PreferReservedClaims, s); err != nil {
+		return err
+	}
+	out.SkipClassDriverCheck = in.SkipClassDriverCheck
+	out.KeepAllocationOnTopologyMismatch = in.KeepAllocationOnTopologyMismatch
+	out.DriverReadinessSeconds = in.DriverReadinessSeconds
+	out.AllowedCELFunctionGroups = *(*[]string)(unsafe.Pointer(&in.AllowedCELFunctionGroups))
+	// WARNING: in.Enabled requires manual conversion: does not exist in peer-type
+	out.VerifyAllocatedSelectors = in.VerifyAllocatedSelectors
+	out.AllowTerminatingDeviceClasses = in.AllowTerminatingDeviceClasses
+	return nil
+}
+
+func autoConvert_config_DynamicResourcesArgs_To_v1_DynamicResourcesArgs(in *config.DynamicResourcesArgs, out *v1.DynamicResourcesArgs, s conversion.Scope) error {
+	out.WriteStrategy = v1.WriteStrategyType(in.WriteStrategy)
+	out.DeviceQuotas = *(*[]v1.DeviceQuota)(unsafe.Pointer(&in.DeviceQuotas))
+	out.DeviceSelectionPolicy = v1.DeviceSelectionPolicyType(in.DeviceSelectionPolicy)
+	out.ResourceSliceMaxAgeSeconds = in.ResourceSliceMaxAgeSeconds
+	out.MissingAttributeBehavior = v1.MissingAttributeBehaviorType(in.MissingAttributeBehavior)
+	out.ClaimEventCoalescingMilliseconds = in.ClaimEventCoalescingMilliseconds
+	if err := metav1.Convert_int32_To_Pointer_int32(&in.FailedAttemptsBeforeEscalation, &out.FailedAttemptsBeforeEscalation, s); err != nil {
+		return err
+	}
+	out.ValidateClaimTemplates = in.ValidateClaimTemplates
+	if err := metav1.Convert_bool_To_Pointer_bool(&in.EnablePostFilterDeallocation, &out.EnablePostFilterDeallocation, s); err != nil {
+		return err
+	}
+	out.AllowedNamespaces = *(*[]string)(unsafe.Pointer(&in.AllowedNamespaces))
+	out.NamespaceSelector = (*metav1.LabelSelector)(unsafe.Pointer(in.NamespaceSelector))
+	out.RecordAllocatedDeviceClasses = in.RecordAllocatedDeviceClasses
+	if err := metav1.Convert_bool_To_Pointer_bool(&in.PreferReservedClaims, &out.PreferReservedClaims, s); err != nil {
+		return err
+	}
+	out.SkipClassDriverCheck = in.SkipClassDriverCheck
+	out.KeepAllocationOnTopologyMismatch = in.KeepAllocationOnTopologyMismatch
+	out.DriverReadinessSeconds = in.DriverReadinessSeconds
+	out.AllowedCELFunctionGroups = *(*[]string)(unsafe.Pointer(&in.AllowedCELFunctionGroups))
+	// WARNING: in.Disabled requires manual conversion: does not exist in peer-type
+	out.VerifyAllocatedSelectors = in.VerifyAllocatedSelectors
+	out.AllowTerminatingDeviceClasses = in.AllowTerminatingDeviceClasses
+	return nil
+}
+
 func autoConvert_v1_Extender_To_config_Extender(in *v1.Extender, out *config.Extender, s conversion.Scope) error {
 	out.URLPrefix = in.URLPrefix
 	out.FilterVerb = in.FilterVerb
@@ -31,6 +31,7 @@ import (
 // All generated defaulters are covering - they call all nested defaulters.
 func RegisterDefaults(scheme *runtime.Scheme) error {
 	scheme.AddTypeDefaultingFunc(&v1.DefaultPreemptionArgs{}, func(obj interface{}) { SetObjectDefaults_DefaultPreemptionArgs(obj.(*v1.DefaultPreemptionArgs)) })
+	scheme.AddTypeDefaultingFunc(&v1.DynamicResourcesArgs{}, func(obj interface{}) { SetObjectDefaults_DynamicResourcesArgs(obj.(*v1.DynamicResourcesArgs)) })
 	scheme.AddTypeDefaultingFunc(&v1.InterPodAffinityArgs{}, func(obj interface{}) { SetObjectDefaults_InterPodAffinityArgs(obj.(*v1.InterPodAffinityArgs)) })
 	scheme.AddTypeDefaultingFunc(&v1.KubeSchedulerConfiguration{}, func(obj interface{}) {
 		SetObjectDefaults_KubeSchedulerConfiguration(obj.(*v1.KubeSchedulerConfiguration))
@@ -48,6 +49,10 @@ func SetObjectDefaults_DefaultPreemptionArgs(in *v1.DefaultPreemptionArgs) {
 	SetDefaults_DefaultPreemptionArgs(in)
 }
 
+func SetObjectDefaults_DynamicResourcesArgs(in *v1.DynamicResourcesArgs) {
+	SetDefaults_DynamicResourcesArgs(in)
+}
+
 func SetObjectDefaults_InterPodAffinityArgs(in *v1.InterPodAffinityArgs) {
 	SetDefaults_InterPodAffinityArgs(in)
 }
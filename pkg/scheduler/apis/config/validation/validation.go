@@ -150,6 +150,7 @@ func validatePluginConfig(path *field.Path, apiVersion string, profile *config.K
 	var errs []error
 	m := map[string]interface{}{
 		"DefaultPreemption":               ValidateDefaultPreemptionArgs,
+		"DynamicResources":                ValidateDynamicResourcesArgs,
 		"InterPodAffinity":                ValidateInterPodAffinityArgs,
 		"NodeAffinity":                    ValidateNodeAffinityArgs,
 		"NodeResourcesBalancedAllocation": ValidateNodeResourcesBalancedAllocationArgs,
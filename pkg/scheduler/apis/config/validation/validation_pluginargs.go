@@ -24,9 +24,11 @@ import (
 	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
 	"k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	"k8s.io/component-helpers/scheduling/corev1/nodeaffinity"
+	dracel "k8s.io/dynamic-resource-allocation/cel"
 	"k8s.io/kubernetes/pkg/features"
 	"k8s.io/kubernetes/pkg/scheduler/apis/config"
 )
@@ -327,3 +329,82 @@ func ValidateNodeResourcesFitArgs(path *field.Path, args *config.NodeResourcesFi
 	}
 	return allErrs.ToAggregate()
 }
+
+// ValidateDynamicResourcesArgs validates that DynamicResourcesArgs are correct.
+func ValidateDynamicResourcesArgs(path *field.Path, args *config.DynamicResourcesArgs) error {
+	var allErrs field.ErrorList
+	if args.WriteStrategy != config.UpdateWriteStrategy && args.WriteStrategy != config.PatchWriteStrategy {
+		allErrs = append(allErrs, field.NotSupported(path.Child("writeStrategy"), args.WriteStrategy, []string{string(config.UpdateWriteStrategy), string(config.PatchWriteStrategy)}))
+	}
+	allErrs = append(allErrs, validateDeviceQuotas(path.Child("deviceQuotas"), args.DeviceQuotas)...)
+	if args.DeviceSelectionPolicy != config.FirstFitDeviceSelectionPolicy && args.DeviceSelectionPolicy != config.BestFitDeviceSelectionPolicy {
+		allErrs = append(allErrs, field.NotSupported(path.Child("deviceSelectionPolicy"), args.DeviceSelectionPolicy, []string{string(config.FirstFitDeviceSelectionPolicy), string(config.BestFitDeviceSelectionPolicy)}))
+	}
+	if args.ResourceSliceMaxAgeSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("resourceSliceMaxAgeSeconds"), args.ResourceSliceMaxAgeSeconds, "must not be negative"))
+	}
+	if args.DriverReadinessSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("driverReadinessSeconds"), args.DriverReadinessSeconds, "must not be negative"))
+	}
+	functionGroups := dracel.FunctionGroups()
+	for i, group := range args.AllowedCELFunctionGroups {
+		if !sets.New(functionGroups...).Has(group) {
+			allErrs = append(allErrs, field.NotSupported(path.Child("allowedCELFunctionGroups").Index(i), group, functionGroups))
+		}
+	}
+	if args.ClaimEventCoalescingMilliseconds < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("claimEventCoalescingMilliseconds"), args.ClaimEventCoalescingMilliseconds, "must not be negative"))
+	}
+	if args.FailedAttemptsBeforeEscalation < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("failedAttemptsBeforeEscalation"), args.FailedAttemptsBeforeEscalation, "must not be negative"))
+	}
+	if args.MissingAttributeBehavior != config.ErrorMissingAttributeBehavior && args.MissingAttributeBehavior != config.ExcludeMissingAttributeBehavior {
+		allErrs = append(allErrs, field.NotSupported(path.Child("missingAttributeBehavior"), args.MissingAttributeBehavior, []string{string(config.ErrorMissingAttributeBehavior), string(config.ExcludeMissingAttributeBehavior)}))
+	}
+	existingNamespaces := sets.New[string]()
+	for i, namespace := range args.AllowedNamespaces {
+		p := path.Child("allowedNamespaces").Index(i)
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			allErrs = append(allErrs, field.Invalid(p, namespace, msg))
+		}
+		if existingNamespaces.Has(namespace) {
+			allErrs = append(allErrs, field.Duplicate(p, namespace))
+		}
+		existingNamespaces.Insert(namespace)
+	}
+	if args.NamespaceSelector != nil {
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(args.NamespaceSelector, metav1validation.LabelSelectorValidationOptions{}, path.Child("namespaceSelector"))...)
+	}
+	return allErrs.ToAggregate()
+}
+
+func validateDeviceQuotas(path *field.Path, quotas []config.DeviceQuota) field.ErrorList {
+	var allErrs field.ErrorList
+	type key struct{ namespace, className string }
+	existing := sets.New[key]()
+	for i, quota := range quotas {
+		p := path.Index(i)
+		// An empty namespace is a budget for the entire cluster.
+		if quota.Namespace != "" {
+			for _, msg := range validation.IsDNS1123Label(quota.Namespace) {
+				allErrs = append(allErrs, field.Invalid(p.Child("namespace"), quota.Namespace, msg))
+			}
+		}
+		if quota.DeviceClassName == "" {
+			allErrs = append(allErrs, field.Required(p.Child("deviceClassName"), ""))
+		} else {
+			for _, msg := range validation.IsDNS1123Subdomain(quota.DeviceClassName) {
+				allErrs = append(allErrs, field.Invalid(p.Child("deviceClassName"), quota.DeviceClassName, msg))
+			}
+		}
+		if quota.MaxDevices < 0 {
+			allErrs = append(allErrs, field.Invalid(p.Child("maxDevices"), quota.MaxDevices, "must not be negative"))
+		}
+		k := key{namespace: quota.Namespace, className: quota.DeviceClassName}
+		if existing.Has(k) {
+			allErrs = append(allErrs, field.Duplicate(p, fmt.Sprintf("{%s, %s}", quota.Namespace, quota.DeviceClassName)))
+		}
+		existing.Insert(k)
+	}
+	return allErrs
+}
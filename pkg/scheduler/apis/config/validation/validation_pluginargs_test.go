@@ -1155,3 +1155,308 @@ func TestValidateRequestedToCapacityRatioScoringStrategy(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateDynamicResourcesArgs(t *testing.T) {
+	cases := map[string]struct {
+		args     config.DynamicResourcesArgs
+		wantErrs field.ErrorList
+	}{
+		"update": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+			},
+		},
+		"patch": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.PatchWriteStrategy,
+				DeviceSelectionPolicy:    config.FirstFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+			},
+		},
+		"empty writeStrategy": {
+			args: config.DynamicResourcesArgs{
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeNotSupported,
+					Field: "writeStrategy",
+				},
+			},
+		},
+		"empty deviceSelectionPolicy": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeNotSupported,
+					Field: "deviceSelectionPolicy",
+				},
+			},
+		},
+		"unknown deviceSelectionPolicy": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:    "WorstFit",
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeNotSupported,
+					Field: "deviceSelectionPolicy",
+				},
+			},
+		},
+		"unknown writeStrategy": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            "Apply",
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeNotSupported,
+					Field: "writeStrategy",
+				},
+			},
+		},
+		"exclude missing attributes": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ExcludeMissingAttributeBehavior,
+			},
+		},
+		"empty missingAttributeBehavior": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:         config.UpdateWriteStrategy,
+				DeviceSelectionPolicy: config.BestFitDeviceSelectionPolicy,
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeNotSupported,
+					Field: "missingAttributeBehavior",
+				},
+			},
+		},
+		"unknown missingAttributeBehavior": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: "Ignore",
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeNotSupported,
+					Field: "missingAttributeBehavior",
+				},
+			},
+		},
+		"resource slice max age": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:              config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:      config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior:   config.ErrorMissingAttributeBehavior,
+				ResourceSliceMaxAgeSeconds: 60,
+			},
+		},
+		"negative resource slice max age": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:              config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:      config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior:   config.ErrorMissingAttributeBehavior,
+				ResourceSliceMaxAgeSeconds: -1,
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeInvalid,
+					Field: "resourceSliceMaxAgeSeconds",
+				},
+			},
+		},
+		"negative driver readiness": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+				DriverReadinessSeconds:   -1,
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeInvalid,
+					Field: "driverReadinessSeconds",
+				},
+			},
+		},
+		"cel function groups": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+				AllowedCELFunctionGroups: []string{"strings", "semver"},
+			},
+		},
+		"unknown cel function group": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+				AllowedCELFunctionGroups: []string{"strings", "regexp"},
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeNotSupported,
+					Field: "allowedCELFunctionGroups[1]",
+				},
+			},
+		},
+		"claim event coalescing": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:                    config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:            config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior:         config.ErrorMissingAttributeBehavior,
+				ClaimEventCoalescingMilliseconds: 100,
+			},
+		},
+		"negative claim event coalescing": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:                    config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:            config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior:         config.ErrorMissingAttributeBehavior,
+				ClaimEventCoalescingMilliseconds: -1,
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeInvalid,
+					Field: "claimEventCoalescingMilliseconds",
+				},
+			},
+		},
+		"allowed namespaces": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+				AllowedNamespaces:        []string{"team-a", "team-b"},
+				NamespaceSelector:        &metav1.LabelSelector{MatchLabels: map[string]string{"dra": "enabled"}},
+			},
+		},
+		"invalid allowed namespaces": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+				AllowedNamespaces:        []string{"team-a", "Team_B", "team-a"},
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeInvalid,
+					Field: "allowedNamespaces[1]",
+				},
+				{
+					Type:  field.ErrorTypeDuplicate,
+					Field: "allowedNamespaces[2]",
+				},
+			},
+		},
+		"invalid namespace selector": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "dra", Operator: "no-such-operator"}},
+				},
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeInvalid,
+					Field: "namespaceSelector.matchExpressions[0].operator",
+				},
+			},
+		},
+		"escalation": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:                  config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:          config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior:       config.ErrorMissingAttributeBehavior,
+				FailedAttemptsBeforeEscalation: 10,
+			},
+		},
+		"negative escalation": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:                  config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:          config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior:       config.ErrorMissingAttributeBehavior,
+				FailedAttemptsBeforeEscalation: -1,
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeInvalid,
+					Field: "failedAttemptsBeforeEscalation",
+				},
+			},
+		},
+		"device quotas": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+				DeviceQuotas: []config.DeviceQuota{
+					{Namespace: "default", DeviceClassName: "gpu.example.com", MaxDevices: 1},
+					{Namespace: "other", DeviceClassName: "gpu.example.com", MaxDevices: 0},
+					{DeviceClassName: "gpu.example.com", MaxDevices: 10},
+				},
+			},
+		},
+		"bad device quotas": {
+			args: config.DynamicResourcesArgs{
+				WriteStrategy:            config.UpdateWriteStrategy,
+				DeviceSelectionPolicy:    config.BestFitDeviceSelectionPolicy,
+				MissingAttributeBehavior: config.ErrorMissingAttributeBehavior,
+				DeviceQuotas: []config.DeviceQuota{
+					{Namespace: "default_", DeviceClassName: "gpu.example.com", MaxDevices: 1},
+					{Namespace: "Default", MaxDevices: 1},
+					{Namespace: "default", DeviceClassName: "gpu.example.com", MaxDevices: -1},
+					{Namespace: "default", DeviceClassName: "gpu.example.com", MaxDevices: 2},
+				},
+			},
+			wantErrs: field.ErrorList{
+				{
+					Type:  field.ErrorTypeInvalid,
+					Field: "deviceQuotas[0].namespace",
+				},
+				{
+					Type:  field.ErrorTypeInvalid,
+					Field: "deviceQuotas[1].namespace",
+				},
+				{
+					Type:  field.ErrorTypeRequired,
+					Field: "deviceQuotas[1].deviceClassName",
+				},
+				{
+					Type:  field.ErrorTypeInvalid,
+					Field: "deviceQuotas[2].maxDevices",
+				},
+				{
+					Type:  field.ErrorTypeDuplicate,
+					Field: "deviceQuotas[3]",
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateDynamicResourcesArgs(nil, &tc.args)
+			if diff := cmp.Diff(tc.wantErrs.ToAggregate(), err, ignoreBadValueDetail); diff != "" {
+				t.Errorf("ValidateDynamicResourcesArgs returned err (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -235,6 +235,7 @@ func TestAddAllEventHandlers(t *testing.T) {
 				framework.PodSchedulingContext: framework.Add,
 				framework.ResourceClaim:        framework.Add,
 				framework.DeviceClass:          framework.Add,
+				framework.ResourceSlice:        framework.Add,
 			},
 			expectStaticInformers: map[reflect.Type]bool{
 				reflect.TypeOf(&v1.Pod{}):       true,
@@ -249,6 +250,7 @@ func TestAddAllEventHandlers(t *testing.T) {
 				framework.PodSchedulingContext: framework.Add,
 				framework.ResourceClaim:        framework.Add,
 				framework.DeviceClass:          framework.Add,
+				framework.ResourceSlice:        framework.Add,
 			},
 			enableDRA: true,
 			expectStaticInformers: map[reflect.Type]bool{
@@ -258,6 +260,7 @@ func TestAddAllEventHandlers(t *testing.T) {
 				reflect.TypeOf(&resourceapi.PodSchedulingContext{}): true,
 				reflect.TypeOf(&resourceapi.ResourceClaim{}):        true,
 				reflect.TypeOf(&resourceapi.DeviceClass{}):          true,
+				reflect.TypeOf(&resourceapi.ResourceSlice{}):        true,
 			},
 			expectDynamicInformers: map[schema.GroupVersionResource]bool{},
 		},
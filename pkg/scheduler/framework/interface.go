@@ -22,6 +22,7 @@ import (
 	"context"
 	"errors"
 	"math"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -174,6 +175,36 @@ func NewPodsToActivate() *PodsToActivate {
 	return &PodsToActivate{Map: make(map[string]*v1.Pod)}
 }
 
+// PodSchedulingAttemptsKey is a reserved state key for the number of times
+// that the scheduler has tried to schedule the pod, including the current
+// attempt. Plugins may use it to handle pods which failed repeatedly
+// differently.
+var PodSchedulingAttemptsKey StateKey = "kubernetes.io/pod-scheduling-attempts"
+
+// PodSchedulingAttempts stores the attempt counter of the pod.
+type PodSchedulingAttempts struct {
+	Attempts int
+}
+
+// Clone just returns the same state. It never gets modified.
+func (s *PodSchedulingAttempts) Clone() StateData {
+	return s
+}
+
+// GetPodSchedulingAttempts returns the attempt counter stored in the
+// cycle state, zero if unknown.
+func GetPodSchedulingAttempts(cs *CycleState) int {
+	state, err := cs.Read(PodSchedulingAttemptsKey)
+	if err != nil {
+		return 0
+	}
+	attempts, ok := state.(*PodSchedulingAttempts)
+	if !ok {
+		return 0
+	}
+	return attempts.Attempts
+}
+
 // Status indicates the result of running a plugin. It consists of a code, a
 // message, (optionally) an error, and a plugin name it fails by.
 // When the status code is not Success, the reasons should explain why.
@@ -384,6 +415,16 @@ type EnqueueExtensions interface {
 	EventsToRegister(context.Context) ([]ClusterEventWithHint, error)
 }
 
+// DebugHandlerProvider is an optional interface for plugins which can serve
+// information about their internal state for troubleshooting. The scheduler
+// installs the handlers on its debug endpoints when profiling is enabled.
+// The handlers must not modify anything.
+type DebugHandlerProvider interface {
+	Plugin
+	// DebugHandler returns the handler for HTTP requests.
+	DebugHandler() http.Handler
+}
+
 // PreFilterExtensions is an interface that is included in plugins that allow specifying
 // callbacks to make incremental updates to its supposedly pre-calculated
 // state.
@@ -652,6 +693,10 @@ type Framework interface {
 	// ListPlugins returns a map of extension point name to list of configured Plugins.
 	ListPlugins() *config.Plugins
 
+	// DebugHandlers returns the handlers of all plugins which implement
+	// DebugHandlerProvider, indexed by plugin name.
+	DebugHandlers() map[string]http.Handler
+
 	// ProfileName returns the profile name associated to a profile.
 	ProfileName() string
 
@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/dynamic-resource-allocation/resourceclaim"
+)
+
+// AllocatedDevicesReader is implemented by the plugin. Debugging tools
+// can get the plugin instance from the scheduler framework and check
+// whether it implements this interface.
+type AllocatedDevicesReader interface {
+	// AllocatedDevices returns the devices of the node which the
+	// scheduler considers allocated. It is safe to call concurrently
+	// with scheduling.
+	AllocatedDevices(nodeName string) (*NodeAllocatedDevices, error)
+}
+
+var _ AllocatedDevicesReader = &dynamicResources{}
+
+// NodeAllocatedDevices lists the allocated devices of one node.
+type NodeAllocatedDevices struct {
+	// NodeName is the name of the node.
+	NodeName string
+
+	// Time is when the information was collected from the informer
+	// caches. Changes which the scheduler has not observed yet are
+	// not included.
+	Time time.Time
+
+	// Devices are sorted by driver, pool, device and claim.
+	Devices []AllocatedDevice
+}
+
+// AllocatedDevice is one device and the claim it is allocated for.
+type AllocatedDevice struct {
+	Driver string
+	Pool   string
+	Device string
+
+	// Claim identifies the ResourceClaim object.
+	Claim types.NamespacedName
+
+	// ClaimResourceVersion is the ResourceVersion of the claim as
+	// seen by the scheduler. For an allocation which is in flight,
+	// it is the version before the allocation.
+	ClaimResourceVersion string
+
+	// InFlight is true if the scheduler has picked the device and
+	// not written the allocation yet.
+	InFlight bool
+
+	// Pods are the names of the pods in the namespace of the claim
+	// for which the claim is reserved and which use the device.
+	Pods []string
+}
+
+// AllocatedDevices implements AllocatedDevicesReader.
+func (pl *dynamicResources) AllocatedDevices(nodeName string) (*NodeAllocatedDevices, error) {
+	result := &NodeAllocatedDevices{
+		NodeName: nodeName,
+		Time:     time.Now(),
+	}
+	if !pl.enabled {
+		return result, nil
+	}
+
+	// Only devices from pools which are local to the node are relevant.
+	type pool struct{ driver, name string }
+	pools := make(map[pool]bool)
+	slices, err := pl.sliceLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list resource slices: %w", err)
+	}
+	for _, slice := range slices {
+		if slice.Spec.NodeName == nodeName {
+			pools[pool{driver: slice.Spec.Driver, name: slice.Spec.Pool.Name}] = true
+		}
+	}
+	if len(pools) == 0 {
+		return result, nil
+	}
+
+	claims, err := (&claimListerForAssumeCache{assumeCache: pl.claimAssumeCache, inFlightAllocations: &pl.inFlightAllocations}).ListAllAllocated()
+	if err != nil {
+		return nil, fmt.Errorf("list allocated claims: %w", err)
+	}
+	for _, claim := range claims {
+		_, inFlight := pl.inFlightAllocations.load(claim.UID)
+		for i, allocated := range claim.Status.Allocation.Devices.Results {
+			if !pools[pool{driver: allocated.Driver, name: allocated.Pool}] {
+				continue
+			}
+			var pods []string
+			for _, consumer := range claim.Status.ReservedFor {
+				if resourceclaim.IsPodReference(consumer) && resourceclaim.UsesDevice(consumer, i) {
+					pods = append(pods, consumer.Name)
+				}
+			}
+			result.Devices = append(result.Devices, AllocatedDevice{
+				Driver:               allocated.Driver,
+				Pool:                 allocated.Pool,
+				Device:               allocated.Device,
+				Claim:                types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name},
+				ClaimResourceVersion: claim.ResourceVersion,
+				InFlight:             inFlight,
+				Pods:                 pods,
+			})
+		}
+	}
+	sort.Slice(result.Devices, func(i, j int) bool {
+		a, b := result.Devices[i], result.Devices[j]
+		switch {
+		case a.Driver != b.Driver:
+			return a.Driver < b.Driver
+		case a.Pool != b.Pool:
+			return a.Pool < b.Pool
+		case a.Device != b.Device:
+			return a.Device < b.Device
+		default:
+			return a.Claim.String() < b.Claim.String()
+		}
+	})
+	return result, nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/dynamic-resource-allocation/structured"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/dynamicresources/metrics"
+)
+
+const (
+	// ReasonAllocatedDeviceMismatch is used for the warning event that
+	// gets emitted for a ResourceClaim when a device which is allocated
+	// for it no longer matches the selectors of the claim because the
+	// driver changed the attributes of the device.
+	ReasonAllocatedDeviceMismatch = "AllocatedDeviceMismatch"
+)
+
+// allocatedSelectorsEventHandler returns a handler for ResourceSlice
+// updates which checks the claims that use devices of the slice, see
+// verifyAllocatedSelectors.
+func (pl *dynamicResources) allocatedSelectorsEventHandler(ctx context.Context) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if !pl.verifyAllocatedSelectors {
+				return
+			}
+			oldSlice, newSlice := sliceFromObj(oldObj), sliceFromObj(newObj)
+			if oldSlice == nil || newSlice == nil {
+				return
+			}
+			pl.checkAllocatedSelectors(ctx, oldSlice, newSlice)
+		},
+	}
+}
+
+// checkAllocatedSelectors evaluates the selectors of the allocated claims
+// again for those devices in the slice whose attributes changed. A claim
+// gets reported when one of its devices matched before the update and no
+// longer does. The allocation remains valid and is never modified, the
+// claim merely gets a warning event.
+//
+// Selectors which depend on the labels of a pod do not match here, so
+// they do not get reported.
+func (pl *dynamicResources) checkAllocatedSelectors(ctx context.Context, oldSlice, newSlice *resourceapi.ResourceSlice) {
+	logger := klog.FromContext(ctx)
+	oldDevices := make(map[string]*resourceapi.BasicDevice, len(oldSlice.Spec.Devices))
+	for i := range oldSlice.Spec.Devices {
+		if basic := oldSlice.Spec.Devices[i].Basic; basic != nil {
+			oldDevices[oldSlice.Spec.Devices[i].Name] = basic
+		}
+	}
+	changedDevices := make(map[string]*resourceapi.BasicDevice)
+	for i := range newSlice.Spec.Devices {
+		basic := newSlice.Spec.Devices[i].Basic
+		if basic == nil {
+			continue
+		}
+		oldBasic := oldDevices[newSlice.Spec.Devices[i].Name]
+		if oldBasic != nil && !apiequality.Semantic.DeepEqual(oldBasic.Attributes, basic.Attributes) {
+			changedDevices[newSlice.Spec.Devices[i].Name] = basic
+		}
+	}
+	if len(changedDevices) == 0 {
+		return
+	}
+
+	claims, err := (&claimListerForAssumeCache{assumeCache: pl.claimAssumeCache, inFlightAllocations: &pl.inFlightAllocations}).ListAllAllocated()
+	if err != nil {
+		logger.Error(err, "Listing allocated claims failed, cannot verify allocated devices", "resourceslice", klog.KObj(newSlice))
+		return
+	}
+	for _, claim := range claims {
+		for _, result := range claim.Status.Allocation.Devices.Results {
+			if result.Driver != newSlice.Spec.Driver || result.Pool != newSlice.Spec.Pool.Name {
+				continue
+			}
+			device := changedDevices[result.Device]
+			if device == nil {
+				continue
+			}
+			requestIndex := slices.IndexFunc(claim.Spec.Devices.Requests, func(request resourceapi.DeviceRequest) bool {
+				return request.Name == result.Request
+			})
+			if requestIndex < 0 {
+				continue
+			}
+			className := claim.Spec.Devices.Requests[requestIndex].DeviceClassName
+			class, err := pl.classLister.Get(className)
+			if err != nil {
+				if !apierrors.IsNotFound(err) {
+					logger.Error(err, "Looking up device class failed, cannot verify allocated device", "resourceclaim", klog.KObj(claim), "deviceclass", className)
+					continue
+				}
+				// The claim selectors can still be checked.
+				class = nil
+			}
+			deviceID := structured.DeviceID{Driver: result.Driver, Pool: result.Pool, Device: result.Device}
+			if matched, err := structured.DeviceMatchesRequest(ctx, class, claim, requestIndex, deviceID, oldDevices[result.Device]); !matched || err != nil {
+				// Already reported or never matched, for example
+				// because the selectors depend on pod labels.
+				continue
+			}
+			matches, err := structured.DeviceMatchesRequest(ctx, class, claim, requestIndex, deviceID, device)
+			if err != nil {
+				logger.V(3).Info("Cannot verify allocated device", "resourceclaim", klog.KObj(claim), "device", deviceID, "err", err)
+				continue
+			}
+			if matches {
+				continue
+			}
+			logger.V(2).Info("Allocated device no longer matches the selectors of the claim", "resourceclaim", klog.KObj(claim), "request", result.Request, "device", deviceID)
+			metrics.AllocatedSelectorMismatches.WithLabelValues(result.Driver).Inc()
+			if recorder := pl.eventRecorder; recorder != nil {
+				recorder.Eventf(claim, nil, v1.EventTypeWarning, ReasonAllocatedDeviceMismatch, "VerifyAllocation",
+					"Device %s allocated for request %s no longer matches the selectors after its attributes changed, the allocation remains in place", deviceID, result.Request)
+			}
+		}
+	}
+}
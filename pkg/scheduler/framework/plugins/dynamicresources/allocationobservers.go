@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/klog/v2"
+)
+
+// allocationEventQueueSize is the number of allocation events which may
+// be pending for the observers. Further events get dropped.
+const allocationEventQueueSize = 1000
+
+// AllocationObserver gets informed about allocations made by the plugin.
+// It is meant for in-process components, for example for metering, which
+// would otherwise have to watch ResourceClaims.
+type AllocationObserver interface {
+	// OnAllocationCommitted gets called after the pod was bound for
+	// each claim which the plugin allocated and stored in PreBind.
+	// Calls happen one at a time in a separate goroutine, so a slow
+	// observer does not block scheduling but delays the next calls.
+	// The objects must not be modified.
+	OnAllocationCommitted(claim *resourceapi.ResourceClaim, pod *v1.Pod, nodeName string)
+}
+
+// AllocationObserverFunc implements AllocationObserver with a function.
+type AllocationObserverFunc func(claim *resourceapi.ResourceClaim, pod *v1.Pod, nodeName string)
+
+// OnAllocationCommitted implements AllocationObserver.
+func (f AllocationObserverFunc) OnAllocationCommitted(claim *resourceapi.ResourceClaim, pod *v1.Pod, nodeName string) {
+	f(claim, pod, nodeName)
+}
+
+// WithAllocationObservers adds observers which get called for each
+// allocation after binding.
+func WithAllocationObservers(observers ...AllocationObserver) Option {
+	return func(pl *dynamicResources) {
+		pl.allocationObservers = append(pl.allocationObservers, observers...)
+	}
+}
+
+// allocationEvent is one pending call of the observers.
+type allocationEvent struct {
+	claim    *resourceapi.ResourceClaim
+	pod      *v1.Pod
+	nodeName string
+}
+
+// notifyAllocationObservers calls the observers for all events until
+// the context is canceled.
+func (pl *dynamicResources) notifyAllocationObservers(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-pl.allocationEvents:
+			for _, observer := range pl.allocationObservers {
+				observer.OnAllocationCommitted(event.claim, event.pod, event.nodeName)
+			}
+		}
+	}
+}
+
+// allocationsCommitted queues the claims which were allocated for the
+// pod by this scheduling cycle for the observers. Events get dropped
+// when the queue is full because PostBind must not block.
+func (pl *dynamicResources) allocationsCommitted(logger klog.Logger, state *stateData, pod *v1.Pod, nodeName string) {
+	if pl.allocationEvents == nil {
+		return
+	}
+	for index, claim := range state.claims {
+		if state.informationsForClaim[index].allocation == nil {
+			continue
+		}
+		select {
+		case pl.allocationEvents <- allocationEvent{claim: claim, pod: pod, nodeName: nodeName}:
+		default:
+			logger.Info("Warning: too many pending allocation events, not informing observers", "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim))
+		}
+	}
+}
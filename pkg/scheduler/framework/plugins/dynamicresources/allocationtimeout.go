@@ -0,0 +1,113 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// AnnotationAllocationTimeout may be set on a ResourceClaim which is
+	// allocated by a control plane controller. The value is a duration
+	// in the format accepted by time.ParseDuration, for example "5m".
+	// When the driver has not allocated the claim for the selected node
+	// within that time, the plugin stops waiting for the driver and lets
+	// the scheduler pick a node again. Without the annotation, the
+	// plugin waits as long as it takes.
+	AnnotationAllocationTimeout = "dra.k8s.io/allocation-timeout"
+)
+
+// allocationTimeout returns the timeout from the annotation of the claim,
+// zero if there is none.
+func allocationTimeout(claim *resourceapi.ResourceClaim) (time.Duration, error) {
+	value, ok := claim.Annotations[AnnotationAllocationTimeout]
+	if !ok {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("annotation %s: %w", AnnotationAllocationTimeout, err)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("annotation %s: must be positive, got %s", AnnotationAllocationTimeout, value)
+	}
+	return timeout, nil
+}
+
+// driverWait describes since when the plugin waits for the allocation of
+// a claim by a control plane controller.
+type driverWait struct {
+	nodeName string
+	since    time.Time
+}
+
+// driverWaits tracks claims which wait for a control plane controller.
+// The zero value is ready for use.
+type driverWaits struct {
+	mutex sync.Mutex
+	waits map[types.UID]driverWait
+}
+
+// start records that waiting for the claim begins now, unless the plugin
+// already waits for an allocation for the same node.
+func (w *driverWaits) start(uid types.UID, nodeName string, now time.Time) driverWait {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if wait, ok := w.waits[uid]; ok && wait.nodeName == nodeName {
+		return wait
+	}
+	if w.waits == nil {
+		w.waits = make(map[types.UID]driverWait)
+	}
+	wait := driverWait{nodeName: nodeName, since: now}
+	w.waits[uid] = wait
+	return wait
+}
+
+func (w *driverWaits) forget(uid types.UID) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	delete(w.waits, uid)
+}
+
+// claimEventHandler returns a handler for ResourceClaim events which
+// forgets about claims once they are allocated or deleted.
+func (w *driverWaits) claimEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, newObj interface{}) {
+			if claim, ok := newObj.(*resourceapi.ResourceClaim); ok && claim.Status.Allocation != nil {
+				w.forget(claim.UID)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if claim, ok := obj.(*resourceapi.ResourceClaim); ok {
+				w.forget(claim.UID)
+			}
+		},
+	}
+}
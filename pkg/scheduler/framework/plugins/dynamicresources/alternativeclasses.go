@@ -0,0 +1,83 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/dynamic-resource-allocation/structured"
+)
+
+// alternativeClasses returns the names of the device classes, other than
+// the one of the request which cannot be satisfied, that have enough
+// allocatable devices on the node for the request. The selectors of the
+// request are ignored because they usually depend on the class.
+func (pl *dynamicResources) alternativeClasses(ctx context.Context, pod *v1.Pod, node *v1.Node, unsatisfiable *structured.UnsatisfiableRequest) ([]string, error) {
+	if unsatisfiable.Request == "" {
+		return nil, nil
+	}
+	index := slices.IndexFunc(unsatisfiable.Claim.Spec.Devices.Requests, func(request resourceapi.DeviceRequest) bool {
+		return request.Name == unsatisfiable.Request
+	})
+	if index < 0 {
+		return nil, nil
+	}
+	request := unsatisfiable.Claim.Spec.Devices.Requests[index]
+	needed := 1
+	if request.AllocationMode != resourceapi.DeviceAllocationModeAll && request.Count > 0 {
+		needed = int(request.Count)
+	}
+
+	classes, err := pl.classLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list device classes: %w", err)
+	}
+	var names []string
+	for _, class := range classes {
+		if class.Name == request.DeviceClassName {
+			continue
+		}
+		numDevices, err := pl.AllocatableDevices(ctx, pod, node, class.Name)
+		if err != nil {
+			return nil, err
+		}
+		if numDevices >= needed {
+			names = append(names, class.Name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// alternativeClassesHint turns the result of alternativeClasses into
+// a suffix for the reason why the node is unsuitable.
+func alternativeClassesHint(names []string) string {
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("; consider class %s which has available devices", names[0])
+	default:
+		return fmt.Sprintf("; consider classes %s which have available devices", strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/dynamic-resource-allocation/resourceclaim"
+	"k8s.io/dynamic-resource-allocation/structured"
+)
+
+const (
+	// AnnotationDeviceAntiAffinityPods is a pod annotation with a
+	// comma-separated list of pod names. Devices for the annotated pod
+	// are not allocated on the same card as the devices of those pods.
+	// The pods must be in the same namespace as the annotated pod.
+	AnnotationDeviceAntiAffinityPods = "resource.kubernetes.io/device-anti-affinity-pods"
+
+	// AnnotationDeviceAntiAffinityAttribute is a pod annotation with the
+	// fully qualified name of the device attribute which identifies the
+	// card of a device. It is required when AnnotationDeviceAntiAffinityPods
+	// is set.
+	AnnotationDeviceAntiAffinityAttribute = "resource.kubernetes.io/device-anti-affinity-attribute"
+)
+
+// deviceAntiAffinity determines which devices the pod must stay away from
+// according to its annotations. It returns nil if the pod has no
+// anti-affinity.
+//
+// Pods which don't exist and claims which are not allocated are ignored.
+func (pl *dynamicResources) deviceAntiAffinity(pod *v1.Pod) (*structured.AntiAffinity, error) {
+	podNames := pod.Annotations[AnnotationDeviceAntiAffinityPods]
+	if podNames == "" {
+		return nil, nil
+	}
+	attribute := pod.Annotations[AnnotationDeviceAntiAffinityAttribute]
+	if attribute == "" {
+		return nil, fmt.Errorf("annotation %s is required when %s is set", AnnotationDeviceAntiAffinityAttribute, AnnotationDeviceAntiAffinityPods)
+	}
+	if !strings.Contains(attribute, "/") {
+		return nil, fmt.Errorf("annotation %s: %q is not a fully qualified attribute name", AnnotationDeviceAntiAffinityAttribute, attribute)
+	}
+
+	antiAffinity := &structured.AntiAffinity{
+		Attribute: resourceapi.FullyQualifiedName(attribute),
+	}
+	for _, podName := range strings.Split(podNames, ",") {
+		podName = strings.TrimSpace(podName)
+		if podName == "" || podName == pod.Name {
+			continue
+		}
+		otherPod, err := pl.podLister.Pods(pod.Namespace).Get(podName)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("look up pod %s: %w", podName, err)
+		}
+		antiAffinity.Devices = append(antiAffinity.Devices, pl.allocatedDevices(otherPod)...)
+	}
+	return antiAffinity, nil
+}
+
+// allocatedDevices returns the devices allocated for the claims of the pod,
+// including allocations which are still in flight.
+func (pl *dynamicResources) allocatedDevices(pod *v1.Pod) []structured.DeviceID {
+	var devices []structured.DeviceID
+	for _, podClaim := range pod.Spec.ResourceClaims {
+		claimName, _, err := resourceclaim.Name(pod, &podClaim)
+		if err != nil || claimName == nil {
+			continue
+		}
+		obj, err := pl.claimAssumeCache.Get(pod.Namespace + "/" + *claimName)
+		if err != nil {
+			continue
+		}
+		claim, ok := obj.(*resourceapi.ResourceClaim)
+		if !ok {
+			continue
+		}
+		if inFlightClaim, ok := pl.inFlightAllocations.loadClaim(claim.UID); ok {
+			claim = inFlightClaim
+		}
+		if claim.Status.Allocation == nil {
+			continue
+		}
+		for _, result := range claim.Status.Allocation.Devices.Results {
+			devices = append(devices, structured.DeviceID{Driver: result.Driver, Pool: result.Pool, Device: result.Device})
+		}
+	}
+	return devices
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/dynamic-resource-allocation/structured"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+// ReserveChecker is implemented by the plugin. Other plugins can use it
+// to find out whether Reserve would succeed for a node.
+type ReserveChecker interface {
+	// CanReserve performs the checks of Reserve without changing
+	// anything. It must be called after Filter succeeded for the node
+	// in the same scheduling cycle.
+	CanReserve(ctx context.Context, cs *framework.CycleState, pod *v1.Pod, nodeName string) *framework.Status
+}
+
+var _ ReserveChecker = &dynamicResources{}
+
+// CanReserve implements ReserveChecker. It checks that the device classes
+// of the claims which the scheduler allocates still exist and that their
+// devices have not been allocated for some other claim since Filter.
+// Neither in-flight allocations nor the assume cache are modified.
+//
+// Quotas are not checked because a QuotaChecker cannot be asked without
+// granting a reservation. Claims which get allocated by a control plane
+// controller are not checked either because Reserve merely selects the
+// node for them.
+func (pl *dynamicResources) CanReserve(ctx context.Context, cs *framework.CycleState, pod *v1.Pod, nodeName string) *framework.Status {
+	if !pl.enabled || !hasClaims(pod) {
+		return nil
+	}
+	logger := klog.FromContext(ctx)
+	state, err := getStateData(cs)
+	if err != nil {
+		return statusError(logger, errorContext{pod: pod}.wrap(err))
+	}
+	if len(state.claims) == 0 || state.allocator == nil {
+		return nil
+	}
+
+	claimsToAllocate := state.allocator.ClaimsToAllocate()
+	state.mutex.Lock()
+	allocations, ok := state.nodeAllocations[nodeName]
+	state.mutex.Unlock()
+	if !ok {
+		return statusError(logger, errorContext{pod: pod, nodeName: nodeName}.wrap(errors.New("claim allocation not found for node")))
+	}
+	if len(allocations) != len(claimsToAllocate) {
+		return statusError(logger, errorContext{pod: pod, nodeName: nodeName}.wrap(fmt.Errorf("internal error, have %d allocations, %d claims to allocate", len(allocations), len(claimsToAllocate))))
+	}
+
+	allocated := pl.deviceTracker.snapshot()
+	for i, claim := range claimsToAllocate {
+		if _, found := pl.inFlightAllocations.load(claim.UID); found {
+			return statusUnschedulable(logger, errorContext{claim: claim}.reason("allocation in progress"), "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim))
+		}
+		for _, request := range claim.Spec.Devices.Requests {
+			if _, err := pl.classLister.Get(request.DeviceClassName); err != nil {
+				if apierrors.IsNotFound(err) {
+					return statusUnschedulable(logger, errorContext{claim: claim, request: request.Name, class: request.DeviceClassName}.reason("does not exist"), "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim))
+				}
+				return statusError(logger, errorContext{pod: pod, claim: claim, request: request.Name, class: request.DeviceClassName, nodeName: nodeName}.wrap(fmt.Errorf("look up device class: %w", err)))
+			}
+		}
+		for _, result := range allocations[i].Devices.Results {
+			// Devices which are shared or only partially consumed
+			// can be in use.
+			if len(result.ConsumedCapacity) > 0 || hasAdminAccess(claim, result.Request) {
+				continue
+			}
+			deviceID := structured.DeviceID{Driver: result.Driver, Pool: result.Pool, Device: result.Device}
+			if allocated.InUse(deviceID) {
+				return statusResourcesExhausted(logger, errorContext{claim: claim}.reason(fmt.Sprintf("device %s was allocated for some other claim", deviceID)), "pod", klog.KObj(pod), "node", klog.ObjectRef{Name: nodeName}, "resourceclaim", klog.KObj(claim))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// claimChange is something that the plugin did to a claim in the current
+// scheduling cycle. The changes of a claim get recorded in the order in
+// which they happened and Unreserve undoes them in reverse order. Claims
+// are never modified by Unreserve based on their state alone.
+type claimChange int
+
+const (
+	// claimAssumed means that Reserve stored the claim with the new
+	// allocation as in-flight allocation.
+	claimAssumed claimChange = iota
+	// finalizerAdded means that PreBind added the finalizer.
+	finalizerAdded
+	// allocationWritten means that PreBind stored the allocation.
+	allocationWritten
+	// reservationAdded means that PreBind added the pod to ReservedFor.
+	reservationAdded
+)
+
+func (c claimChange) String() string {
+	switch c {
+	case claimAssumed:
+		return "assumed"
+	case finalizerAdded:
+		return "finalizer added"
+	case allocationWritten:
+		return "allocation written"
+	case reservationAdded:
+		return "reservation added"
+	default:
+		return fmt.Sprintf("claimChange(%d)", int(c))
+	}
+}
+
+// recordChange remembers a change of the claim with the given index.
+func (s *stateData) recordChange(index int, change claimChange) {
+	s.informationsForClaim[index].changes = append(s.informationsForClaim[index].changes, change)
+}
+
+// forgetChange removes a change which was undone.
+func (s *stateData) forgetChange(index int, change claimChange) {
+	s.informationsForClaim[index].changes = slices.DeleteFunc(s.informationsForClaim[index].changes, func(c claimChange) bool { return c == change })
+}
+
+// undoClaimChanges reverts the recorded changes of the claim with the given
+// index, newest first. Failures are only logged. A reservation which cannot
+// be removed expires at its deadline.
+//
+// The allocation is only removed together with the reservation and only if
+// no other consumer started to use the claim in the meantime. The finalizer
+// is only removed if the claim is not allocated anymore.
+func (pl *dynamicResources) undoClaimChanges(ctx context.Context, state *stateData, index int, pod *v1.Pod) {
+	logger := klog.FromContext(ctx)
+	changes := state.informationsForClaim[index].changes
+	for i := len(changes) - 1; i >= 0; i-- {
+		claim := state.claims[index]
+		switch changes[i] {
+		case reservationAdded:
+			if slices.Contains(changes, allocationWritten) {
+				// Both get removed in one write below.
+				continue
+			}
+			// Remove pod from ReservedFor. A strategic-merge-patch is used
+			// because that allows removing an individual entry without having
+			// the latest slice. The entry is identified by the pod UID, so
+			// entries of other consumers (including some other, terminating
+			// instance of a pod with the same name) are left alone. Deleting
+			// an entry which is already gone is not an error.
+			patch := fmt.Sprintf(`{"metadata": {"uid": %q}, "status": { "reservedFor": [ {"$patch": "delete", "uid": %q} ] }}`,
+				claim.UID,
+				pod.UID,
+			)
+			logger.V(5).Info("unreserve", "resourceclaim", klog.KObj(claim), "pod", klog.KObj(pod))
+			if _, err := pl.clientset.ResourceV1alpha3().ResourceClaims(claim.Namespace).Patch(ctx, claim.Name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{}, "status"); err != nil {
+				logger.Error(err, "unreserve", "resourceclaim", klog.KObj(claim))
+			}
+		case allocationWritten:
+			updatedClaim, err := pl.rollbackClaim(ctx, state, index, pod)
+			if err != nil {
+				// The claim might still be allocated, so the
+				// finalizer must stay.
+				logger.Error(err, "unreserve", "resourceclaim", klog.KObj(claim))
+				state.informationsForClaim[index].changes = nil
+				return
+			}
+			state.claims[index] = updatedClaim
+		case finalizerAdded:
+			// The claim in the state is outdated if writing the
+			// allocation failed after adding the finalizer.
+			latestClaim, err := pl.clientset.ResourceV1alpha3().ResourceClaims(claim.Namespace).Get(ctx, claim.Name, metav1.GetOptions{})
+			if err != nil {
+				if !apierrors.IsNotFound(err) {
+					logger.Error(err, "unreserve", "resourceclaim", klog.KObj(claim))
+				}
+				continue
+			}
+			if latestClaim.UID != claim.UID || latestClaim.Status.Allocation != nil {
+				// Still needed.
+				continue
+			}
+			pl.removeOrphanedFinalizer(ctx, latestClaim)
+		case claimAssumed:
+			// If allocation was in-flight, then it's not anymore and we need to revert the
+			// claim object in the assume cache to what it was before.
+			if pl.inFlightAllocations.delete(claim.UID) {
+				pl.claimAssumeCache.Restore(claim.Namespace + "/" + claim.Name)
+			}
+		}
+	}
+	state.informationsForClaim[index].changes = nil
+}
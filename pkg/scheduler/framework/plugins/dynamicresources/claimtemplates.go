@@ -0,0 +1,108 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/dynamic-resource-allocation/resourceclaim"
+	"k8s.io/klog/v2"
+)
+
+// checkClaimTemplates compares the claims of the pod which were generated
+// from a ResourceClaimTemplate against the current template. A claim which
+// was edited after it was generated no longer is what the author of the
+// pod asked for. The result is a message about the first claim which
+// differs, empty if all claims match their template.
+//
+// A template which no longer exists cannot be compared against, so such
+// claims are accepted.
+func (pl *dynamicResources) checkClaimTemplates(logger klog.Logger, pod *v1.Pod, claims []*resourceapi.ResourceClaim) (string, error) {
+	claimsByName := make(map[string]*resourceapi.ResourceClaim, len(claims))
+	for _, claim := range claims {
+		claimsByName[claim.Name] = claim
+	}
+	for _, resource := range pod.Spec.ResourceClaims {
+		if resource.ResourceClaimTemplateName == nil {
+			continue
+		}
+		claimName, _, err := resourceclaim.Name(pod, &resource)
+		if err != nil || claimName == nil {
+			// Already handled when looking up the claims.
+			continue
+		}
+		claim := claimsByName[*claimName]
+		if claim == nil {
+			continue
+		}
+		templateName := *resource.ResourceClaimTemplateName
+		template, err := pl.claimTemplateLister.ResourceClaimTemplates(pod.Namespace).Get(templateName)
+		if apierrors.IsNotFound(err) {
+			logger.V(5).Info("ResourceClaimTemplate not found, not checking the claim", "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim), "resourceclaimtemplate", klog.KRef(pod.Namespace, templateName))
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("look up ResourceClaimTemplate %s: %w", templateName, err)
+		}
+		if fields := divergentClaimFields(&template.Spec.Spec, &claim.Spec); len(fields) > 0 {
+			return fmt.Sprintf("resourceclaim %s differs from resourceclaimtemplate %s in %s", claim.Name, templateName, strings.Join(fields, ", ")), nil
+		}
+	}
+	return "", nil
+}
+
+// divergentClaimFields returns the paths of the fields whose value in the
+// claim is different from the one in the template.
+func divergentClaimFields(template, claim *resourceapi.ResourceClaimSpec) []string {
+	var fields []string
+	diverges := func(path *field.Path, a, b any) {
+		if !apiequality.Semantic.DeepEqual(a, b) {
+			fields = append(fields, path.String())
+		}
+	}
+
+	spec := field.NewPath("spec")
+	diverges(spec.Child("controller"), template.Controller, claim.Controller)
+
+	devices := spec.Child("devices")
+	requests := devices.Child("requests")
+	if len(template.Devices.Requests) != len(claim.Devices.Requests) {
+		fields = append(fields, requests.String())
+	} else {
+		for i := range template.Devices.Requests {
+			a, b := &template.Devices.Requests[i], &claim.Devices.Requests[i]
+			request := requests.Index(i)
+			diverges(request.Child("name"), a.Name, b.Name)
+			diverges(request.Child("deviceClassName"), a.DeviceClassName, b.DeviceClassName)
+			diverges(request.Child("selectors"), a.Selectors, b.Selectors)
+			diverges(request.Child("allocationMode"), a.AllocationMode, b.AllocationMode)
+			diverges(request.Child("count"), a.Count, b.Count)
+			diverges(request.Child("adminAccess"), a.AdminAccess, b.AdminAccess)
+			diverges(request.Child("capacity"), a.Capacity, b.Capacity)
+			diverges(request.Child("nodeLocalOnly"), a.NodeLocalOnly, b.NodeLocalOnly)
+		}
+	}
+	diverges(devices.Child("constraints"), template.Devices.Constraints, claim.Devices.Constraints)
+	diverges(devices.Child("config"), template.Devices.Config, claim.Devices.Config)
+	return fields
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/dynamic-resource-allocation/structured"
+)
+
+// classDevicesCache remembers how many devices in the cluster match the
+// selectors of a device class. Counting them requires evaluating CEL
+// expressions for all devices, which is too expensive to repeat for each
+// pod.
+//
+// The cached counts become stale whenever some ResourceSlice changes.
+// A class update is detected through its ResourceVersion. Selectors may
+// depend on the labels of the pod, so those are part of the key.
+type classDevicesCache struct {
+	mutex sync.Mutex
+
+	// generation gets incremented for each reset. A count which
+	// was computed while a reset happened is not stored because
+	// it might be based on out-dated slices.
+	generation int64
+	entries    map[classDevicesKey]classDevicesEntry
+}
+
+type classDevicesKey struct {
+	className string
+	podLabels string
+}
+
+type classDevicesEntry struct {
+	resourceVersion string
+	numDevices      int
+}
+
+// reset forgets all cached counts.
+func (c *classDevicesCache) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	c.entries = nil
+}
+
+// resourceEventHandler returns a handler which resets the cache
+// for all ResourceSlice events.
+func (c *classDevicesCache) resourceEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(_ interface{}) { c.reset() },
+		UpdateFunc: func(_, _ interface{}) { c.reset() },
+		DeleteFunc: func(_ interface{}) { c.reset() },
+	}
+}
+
+// classDevices returns an upper bound for the number of devices which
+// can be allocated for the class, see structured.ClassDevices.
+func (pl *dynamicResources) classDevices(ctx context.Context, class *resourceapi.DeviceClass, podLabels map[string]string) (int, error) {
+	key := classDevicesKey{className: class.Name, podLabels: labels.Set(podLabels).String()}
+	c := &pl.classDevicesCache
+	c.mutex.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generation
+	c.mutex.Unlock()
+	if ok && entry.resourceVersion == class.ResourceVersion {
+		return entry.numDevices, nil
+	}
+
+	slices, err := pl.sliceLister.List(labels.Everything())
+	if err != nil {
+		return 0, fmt.Errorf("list resource slices: %w", err)
+	}
+	numDevices := structured.ClassDevices(ctx, class, podLabels, slices)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.generation == generation {
+		if c.entries == nil {
+			c.entries = make(map[classDevicesKey]classDevicesEntry)
+		}
+		c.entries[key] = classDevicesEntry{resourceVersion: class.ResourceVersion, numDevices: numDevices}
+	}
+	return numDevices, nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+// celAttributeDomain matches the domain in CEL expressions like
+// device.attributes["gpu.example.com"].model.
+var celAttributeDomain = regexp.MustCompile(`\.(?:attributes|capacity)\s*\[\s*["']([^"']+)["']\s*\]`)
+
+// attributeDomainsCache remembers which attribute domains are used by
+// the devices in the cluster. Like classDevicesCache, it gets reset
+// whenever some ResourceSlice changes.
+type attributeDomainsCache struct {
+	mutex sync.Mutex
+
+	// generation gets incremented for each reset.
+	generation int64
+	// domains is nil if unknown.
+	domains sets.Set[string]
+}
+
+// reset forgets the domains.
+func (c *attributeDomainsCache) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	c.domains = nil
+}
+
+// resourceEventHandler returns a handler which resets the cache
+// for all ResourceSlice events.
+func (c *attributeDomainsCache) resourceEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(_ interface{}) { c.reset() },
+		UpdateFunc: func(_, _ interface{}) { c.reset() },
+		DeleteFunc: func(_ interface{}) { c.reset() },
+	}
+}
+
+// attributeDomains returns the names of all drivers which publish
+// ResourceSlices and all domains of the attributes and capacities of
+// their devices. The result must not be modified.
+func (pl *dynamicResources) attributeDomains() (sets.Set[string], error) {
+	c := &pl.attributeDomainsCache
+	c.mutex.Lock()
+	domains := c.domains
+	generation := c.generation
+	c.mutex.Unlock()
+	if domains != nil {
+		return domains, nil
+	}
+
+	slices, err := pl.sliceLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list resource slices: %w", err)
+	}
+	domains = sets.New[string]()
+	for _, slice := range slices {
+		domains.Insert(slice.Spec.Driver)
+		for _, device := range slice.Spec.Devices {
+			if device.Basic == nil {
+				continue
+			}
+			for name := range device.Basic.Attributes {
+				if domain, _, ok := strings.Cut(string(name), "/"); ok {
+					domains.Insert(domain)
+				}
+			}
+			for name := range device.Basic.Capacity {
+				if domain, _, ok := strings.Cut(string(name), "/"); ok {
+					domains.Insert(domain)
+				}
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.generation == generation {
+		c.domains = domains
+	}
+	return domains, nil
+}
+
+// unknownClassDriver returns the first attribute domain, in alphabetical
+// order, which is referenced by the selectors of the class and unknown
+// in the cluster. Such a domain usually is a misspelled driver name,
+// in which case no device can match. Selectors which deliberately
+// reference domains which may be absent are possible, which is why
+// the check can be disabled.
+func (pl *dynamicResources) unknownClassDriver(class *resourceapi.DeviceClass) (string, error) {
+	referenced := sets.New[string]()
+	for _, selector := range class.Spec.Selectors {
+		if selector.CEL != nil {
+			for _, match := range celAttributeDomain.FindAllStringSubmatch(selector.CEL.Expression, -1) {
+				referenced.Insert(match[1])
+			}
+		}
+		if selector.Attribute != nil {
+			if domain, _, ok := strings.Cut(string(selector.Attribute.Name), "/"); ok {
+				referenced.Insert(domain)
+			}
+		}
+	}
+	if referenced.Len() == 0 {
+		return "", nil
+	}
+	domains, err := pl.attributeDomains()
+	if err != nil {
+		return "", err
+	}
+	unknown := referenced.Difference(domains).UnsortedList()
+	if len(unknown) == 0 {
+		return "", nil
+	}
+	sort.Strings(unknown)
+	return unknown[0], nil
+}
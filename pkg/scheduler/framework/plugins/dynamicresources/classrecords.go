@@ -0,0 +1,101 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"encoding/json"
+	"fmt"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// AnnotationAllocatedDeviceClasses gets set by PreBind on a claim which
+	// the scheduler allocated if RecordAllocatedDeviceClasses is enabled
+	// in the plugin args. The value is a JSON list of AllocatedDeviceClass
+	// entries, one for each DeviceClass referenced by the claim.
+	AnnotationAllocatedDeviceClasses = "resource.kubernetes.io/allocated-device-classes"
+
+	// maxAllocatedDeviceClassesSize limits the size of the annotation
+	// value. If the selectors make it larger, they are omitted.
+	maxAllocatedDeviceClassesSize = 16 * 1024
+)
+
+// AllocatedDeviceClass records a DeviceClass as it was when the scheduler
+// allocated a claim.
+type AllocatedDeviceClass struct {
+	Name            string    `json:"name"`
+	UID             types.UID `json:"uid"`
+	Generation      int64     `json:"generation"`
+	ResourceVersion string    `json:"resourceVersion"`
+
+	// Selectors are the CEL expressions of the class.
+	Selectors []string `json:"selectors,omitempty"`
+
+	// SelectorsOmitted is true if the selectors were not recorded
+	// because the annotation would have become too large.
+	SelectorsOmitted bool `json:"selectorsOmitted,omitempty"`
+}
+
+// allocatedDeviceClasses returns the value of AnnotationAllocatedDeviceClasses
+// for the claim, based on the classes in the informer cache. Those are the
+// ones that the allocator used.
+func (pl *dynamicResources) allocatedDeviceClasses(claim *resourceapi.ResourceClaim) (string, error) {
+	classNames := sets.New[string]()
+	for _, request := range claim.Spec.Devices.Requests {
+		classNames.Insert(request.DeviceClassName)
+	}
+	classes := make([]AllocatedDeviceClass, 0, classNames.Len())
+	for _, className := range sets.List(classNames) {
+		class, err := pl.classLister.Get(className)
+		if err != nil {
+			return "", fmt.Errorf("get device class %s: %w", className, err)
+		}
+		allocatedClass := AllocatedDeviceClass{
+			Name:            class.Name,
+			UID:             class.UID,
+			Generation:      class.Generation,
+			ResourceVersion: class.ResourceVersion,
+		}
+		for _, selector := range class.Spec.Selectors {
+			if selector.CEL != nil {
+				allocatedClass.Selectors = append(allocatedClass.Selectors, selector.CEL.Expression)
+			}
+		}
+		classes = append(classes, allocatedClass)
+	}
+	value, err := json.Marshal(classes)
+	if err != nil {
+		return "", err
+	}
+	if len(value) <= maxAllocatedDeviceClassesSize {
+		return string(value), nil
+	}
+	for i := range classes {
+		if len(classes[i].Selectors) > 0 {
+			classes[i].Selectors = nil
+			classes[i].SelectorsOmitted = true
+		}
+	}
+	value, err = json.Marshal(classes)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/utils/clock"
+)
+
+// claimEventCoalescer turns a burst of claim events for a pod into a single
+// Queue decision. A driver which updates all claims of a pod in quick
+// succession would otherwise cause one queueing decision per claim.
+//
+// The first event which requeues a pod is passed through immediately.
+// Further Queue decisions for the same pod are turned into QueueSkip
+// until the window expires or the pod gets tried again, whatever
+// happens first. Until then, the pod is waiting to be scheduled anyway.
+type claimEventCoalescer struct {
+	window time.Duration
+	clock  clock.PassiveClock
+
+	mutex sync.Mutex
+	// queued maps the UIDs of pods to the time when they were requeued.
+	queued map[types.UID]time.Time
+}
+
+// newClaimEventCoalescer returns nil when the window is zero, which
+// disables coalescing.
+func newClaimEventCoalescer(window time.Duration, clock clock.PassiveClock) *claimEventCoalescer {
+	if window <= 0 {
+		return nil
+	}
+	return &claimEventCoalescer{
+		window: window,
+		clock:  clock,
+		queued: make(map[types.UID]time.Time),
+	}
+}
+
+// wrap returns a hint function which coalesces the decisions of the given
+// one. Without coalescing, it returns the function itself.
+func (c *claimEventCoalescer) wrap(hintFn framework.QueueingHintFn) framework.QueueingHintFn {
+	if c == nil {
+		return hintFn
+	}
+	return func(logger klog.Logger, pod *v1.Pod, oldObj, newObj interface{}) (framework.QueueingHint, error) {
+		hint, err := hintFn(logger, pod, oldObj, newObj)
+		if err != nil || hint != framework.Queue {
+			return hint, err
+		}
+		if !c.requeue(pod) {
+			logger.V(5).Info("pod was requeued recently", "pod", klog.KObj(pod), "reason", "skipping because claim events get coalesced")
+			return framework.QueueSkip, nil
+		}
+		return hint, nil
+	}
+}
+
+// requeue records that the pod gets requeued now. It returns false if
+// that already happened within the window.
+func (c *claimEventCoalescer) requeue(pod *v1.Pod) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.clock.Now()
+	if queued, ok := c.queued[pod.UID]; ok && now.Sub(queued) < c.window {
+		return false
+	}
+	// Entries of pods which were not tried again, for example because
+	// they got deleted, are removed here.
+	for uid, queued := range c.queued {
+		if now.Sub(queued) >= c.window {
+			delete(c.queued, uid)
+		}
+	}
+	c.queued[pod.UID] = now
+	return true
+}
+
+// forget must be called when the pod gets tried again. Events which
+// arrive after that must not be suppressed because the attempt might
+// not have seen the changes.
+func (c *claimEventCoalescer) forget(pod *v1.Pod) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.queued, pod.UID)
+}
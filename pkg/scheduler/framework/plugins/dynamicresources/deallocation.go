@@ -0,0 +1,103 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/dynamic-resource-allocation/resourceclaim"
+)
+
+// claimsToDeallocate returns the indices of the claims which PostFilter
+// may deallocate. Filter is done, so the mutex is not needed.
+//
+// If there is a node where only claims which may get deallocated were
+// unavailable, then the smallest such set of claims gets returned.
+// Deallocating those claims is enough to make the pod fit on that node,
+// at least as far as this plugin is concerned, so claims which are
+// allocated for the right node are kept. Otherwise all unavailable claims
+// get returned. The order is random in both cases.
+//
+// Claims in kept are never returned, and neither are sets containing them.
+func claimsToDeallocate(pod *v1.Pod, state *stateData, kept sets.Set[int]) []int {
+	// Iterating over a map is random. This is intentional here, there
+	// is no better heuristic for picking one of several nodes which
+	// need the same number of claims to be deallocated.
+	var minimal sets.Set[int]
+	for _, unavailable := range state.unavailableClaimsPerNode {
+		if minimal != nil && unavailable.Len() >= minimal.Len() {
+			continue
+		}
+		if slices.ContainsFunc(unavailable.UnsortedList(), func(index int) bool {
+			return kept.Has(index) || isReservedForOthers(pod, state.claims[index])
+		}) {
+			continue
+		}
+		minimal = unavailable
+	}
+	if minimal != nil {
+		return minimal.UnsortedList()
+	}
+	return state.unavailableClaims.Difference(kept).UnsortedList()
+}
+
+// reservedClaimsToKeep returns the indices of the unavailable claims which
+// are already reserved for the pod and which PostFilter must not deallocate
+// because PreferReservedClaims is enabled. When the pod gets scheduled
+// again, for example after a restart of the scheduler, it is better to
+// wait for the node of the existing allocation than to allocate anew.
+//
+// That only makes sense while that node exists. A claim whose allocation
+// does not match any node is not kept.
+func (pl *dynamicResources) reservedClaimsToKeep(pod *v1.Pod, state *stateData) sets.Set[int] {
+	kept := sets.New[int]()
+	if !pl.preferReservedClaims {
+		return kept
+	}
+	for index := range state.unavailableClaims {
+		claim := state.claims[index]
+		if claim.Status.Allocation == nil ||
+			claim.Status.DeallocationRequested ||
+			!resourceclaim.IsReservedForPod(pod, claim) ||
+			isReservedForOthers(pod, claim) {
+			continue
+		}
+		if pl.allocatedNodeExists(state, index) {
+			kept.Insert(index)
+		}
+	}
+	return kept
+}
+
+// allocatedNodeExists checks whether the allocation of the claim can be
+// used on at least one node. Errors are treated like a missing node because
+// deallocation is the safe fallback.
+func (pl *dynamicResources) allocatedNodeExists(state *stateData, index int) bool {
+	nodeSelector := state.informationsForClaim[index].availableOnNodes[""]
+	if nodeSelector == nil {
+		// Usable on all nodes.
+		return true
+	}
+	nodes, err := pl.nodeLister.List(labels.Everything())
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(nodes, nodeSelector.Match)
+}
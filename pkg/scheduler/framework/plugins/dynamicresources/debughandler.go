@@ -0,0 +1,112 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// DebugAllocations is the content served by the debug handler.
+type DebugAllocations struct {
+	// InFlight lists claims which were allocated in Reserve and
+	// whose status has not been written yet.
+	InFlight []DebugAllocation `json:"inFlight"`
+
+	// Assumed lists claims whose status was written by the plugin
+	// and for which the informer has not seen the update yet.
+	Assumed []DebugAllocation `json:"assumed"`
+}
+
+// DebugAllocation describes the allocation of one claim.
+type DebugAllocation struct {
+	// Claim is <namespace>/<name> of the claim.
+	Claim string    `json:"claim"`
+	UID   types.UID `json:"uid"`
+
+	Allocation  *resourceapi.AllocationResult                `json:"allocation,omitempty"`
+	ReservedFor []resourceapi.ResourceClaimConsumerReference `json:"reservedFor,omitempty"`
+}
+
+// DebugHandler returns a handler which serves the in-flight and assumed
+// allocations of the plugin as JSON.
+func (pl *dynamicResources) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(pl.debugAllocations()); err != nil {
+			klog.FromContext(req.Context()).Error(err, "Encoding DRA debug information failed")
+		}
+	})
+}
+
+func (pl *dynamicResources) debugAllocations() *DebugAllocations {
+	allocations := &DebugAllocations{
+		InFlight: []DebugAllocation{},
+		Assumed:  []DebugAllocation{},
+	}
+	if !pl.enabled {
+		return allocations
+	}
+
+	pl.inFlightAllocations.forEach(func(allocation *inFlightAllocation) bool {
+		allocations.InFlight = append(allocations.InFlight, newDebugAllocation(allocation.claim))
+		return true
+	})
+
+	if pl.claimAssumeCache != nil {
+		for _, obj := range pl.claimAssumeCache.List(nil) {
+			claim, ok := obj.(*resourceapi.ResourceClaim)
+			if !ok {
+				continue
+			}
+			apiObj, err := pl.claimAssumeCache.GetAPIObj(claim.Namespace + "/" + claim.Name)
+			if err != nil || apiObj == obj {
+				// Not assumed.
+				continue
+			}
+			allocations.Assumed = append(allocations.Assumed, newDebugAllocation(claim))
+		}
+	}
+
+	sortDebugAllocations(allocations.InFlight)
+	sortDebugAllocations(allocations.Assumed)
+	return allocations
+}
+
+func newDebugAllocation(claim *resourceapi.ResourceClaim) DebugAllocation {
+	return DebugAllocation{
+		Claim:       claim.Namespace + "/" + claim.Name,
+		UID:         claim.UID,
+		Allocation:  claim.Status.Allocation,
+		ReservedFor: claim.Status.ReservedFor,
+	}
+}
+
+func sortDebugAllocations(allocations []DebugAllocation) {
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].Claim < allocations[j].Claim
+	})
+}
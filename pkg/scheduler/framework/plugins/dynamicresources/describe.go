@@ -0,0 +1,162 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+// PodDRADescription is everything that the plugin knows about the
+// dynamic resources of a pod. It is meant for troubleshooting.
+type PodDRADescription struct {
+	// Claims describes the claims of the pod in the order in which
+	// they are listed in the pod spec.
+	Claims []ClaimDescription
+
+	// Unschedulable is the reason why the pod cannot be scheduled
+	// on any node. Empty if the pod is schedulable on some nodes.
+	Unschedulable string
+
+	// FeasibleNodes are the names of the nodes which are suitable
+	// for the pod as far as this plugin is concerned, in
+	// alphabetical order.
+	FeasibleNodes []string
+
+	// RejectedNodes maps the name of each unsuitable node to the
+	// reason why the plugin rejects it.
+	RejectedNodes map[string]string
+
+	// SchedulingContext is the PodSchedulingContext of the pod. Nil
+	// if it does not exist or DRAControlPlaneController is disabled.
+	SchedulingContext *resourceapi.PodSchedulingContext
+}
+
+// ClaimDescription describes one claim of a pod.
+type ClaimDescription struct {
+	ClaimState
+
+	// Allocation is the allocation stored in the claim status.
+	Allocation *resourceapi.AllocationResult
+
+	// InFlightAllocation is the allocation which was picked for
+	// the claim by some pod and has not been written yet.
+	InFlightAllocation *resourceapi.AllocationResult
+
+	// ReservedFor lists the consumers of the claim.
+	ReservedFor []resourceapi.ResourceClaimConsumerReference
+
+	// DeallocationRequested is true if the claim is waiting for
+	// a control plane controller to deallocate it.
+	DeallocationRequested bool
+}
+
+// DescribePod returns what the plugin knows about the dynamic resources of
+// the pod, based on the informer caches. It evaluates all nodes like
+// PreFilter and Filter would in a scheduling cycle, but without changing
+// anything.
+func (pl *dynamicResources) DescribePod(ctx context.Context, pod *v1.Pod) (*PodDRADescription, error) {
+	description := &PodDRADescription{
+		RejectedNodes: make(map[string]string),
+	}
+	if !pl.enabled {
+		return description, nil
+	}
+
+	if pl.podSchedulingContextLister != nil {
+		schedulingCtx, err := pl.podSchedulingContextLister.PodSchedulingContexts(pod.Namespace).Get(pod.Name)
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			return nil, fmt.Errorf("look up PodSchedulingContext: %w", err)
+		default:
+			description.SchedulingContext = schedulingCtx.DeepCopy()
+		}
+	}
+
+	nodes, err := pl.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list nodes: %w", err)
+	}
+
+	// The extension points have side effects like metrics, events and
+	// caching. Their implementations do not, they only modify the cycle
+	// state, and that is private.
+	cs := framework.NewCycleState()
+	_, status := pl.preFilter(ctx, cs, pod)
+	state, err := getStateData(cs)
+	if err != nil {
+		return nil, err
+	}
+	claims := state.claims
+	claimStates := (&StateReader{state: state}).Claims()
+	if len(claims) == 0 {
+		// PreFilter stops before recording the claims when it
+		// rejects the pod. Their phase is unknown in that case.
+		claims, _ = pl.podResourceClaims(pod)
+		claimStates = make([]ClaimState, 0, len(claims))
+		for _, claim := range claims {
+			claimStates = append(claimStates, ClaimState{PodClaimName: podClaimName(pod, claim), ClaimName: claim.Name})
+		}
+	}
+	for index, claimState := range claimStates {
+		claim := claims[index]
+		claimDescription := ClaimDescription{
+			ClaimState:            claimState,
+			Allocation:            claim.Status.Allocation.DeepCopy(),
+			DeallocationRequested: claim.Status.DeallocationRequested,
+		}
+		if len(claim.Status.ReservedFor) > 0 {
+			claimDescription.ReservedFor = append([]resourceapi.ResourceClaimConsumerReference(nil), claim.Status.ReservedFor...)
+		}
+		if inFlightClaim, ok := pl.inFlightAllocations.loadClaim(claim.UID); ok {
+			claimDescription.InFlightAllocation = inFlightClaim.Status.Allocation.DeepCopy()
+		}
+		description.Claims = append(description.Claims, claimDescription)
+	}
+	switch status.Code() {
+	case framework.Success, framework.Skip:
+	case framework.Error:
+		return nil, status.AsError()
+	default:
+		description.Unschedulable = status.Message()
+		return description, nil
+	}
+
+	for _, node := range nodes {
+		nodeInfo := framework.NewNodeInfo()
+		nodeInfo.SetNode(node)
+		status := pl.filter(ctx, cs, state, pod, nodeInfo, nil)
+		switch status.Code() {
+		case framework.Success:
+			description.FeasibleNodes = append(description.FeasibleNodes, node.Name)
+		case framework.Error:
+			return nil, fmt.Errorf("node %s: %w", node.Name, status.AsError())
+		default:
+			description.RejectedNodes[node.Name] = status.Message()
+		}
+	}
+	sort.Strings(description.FeasibleNodes)
+	return description, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/dynamic-resource-allocation/resourceclaim"
+)
+
+// DeviceClaimsReader is implemented by the plugin. Like
+// AllocatedDevicesReader, it is meant for debugging tools, for example
+// when troubleshooting a broken device.
+type DeviceClaimsReader interface {
+	// ClaimsUsingDevice returns all claims to which the device is
+	// allocated, including those whose allocation is in flight. It is
+	// safe to call concurrently with scheduling.
+	ClaimsUsingDevice(driver, pool, device string) []ClaimRef
+}
+
+var _ DeviceClaimsReader = &dynamicResources{}
+
+// ClaimRef identifies a claim to which a device is allocated.
+type ClaimRef struct {
+	// Claim identifies the ResourceClaim object.
+	Claim types.NamespacedName
+
+	// Request is the name of the request in the claim for which the
+	// device was allocated.
+	Request string
+
+	// InFlight is true if the scheduler has picked the device and
+	// not written the allocation yet.
+	InFlight bool
+
+	// Pods are the names of the pods in the namespace of the claim
+	// for which the claim is reserved and which use the device.
+	Pods []string
+}
+
+// ClaimsUsingDevice implements DeviceClaimsReader. The result is sorted by
+// namespace and name of the claims.
+func (pl *dynamicResources) ClaimsUsingDevice(driver, pool, device string) []ClaimRef {
+	if !pl.enabled {
+		return nil
+	}
+
+	// Listing from the assume cache cannot fail.
+	claims, _ := (&claimListerForAssumeCache{assumeCache: pl.claimAssumeCache, inFlightAllocations: &pl.inFlightAllocations}).ListAllAllocated()
+	var refs []ClaimRef
+	for _, claim := range claims {
+		_, inFlight := pl.inFlightAllocations.load(claim.UID)
+		for i, allocated := range claim.Status.Allocation.Devices.Results {
+			if allocated.Driver != driver || allocated.Pool != pool || allocated.Device != device {
+				continue
+			}
+			var pods []string
+			for _, consumer := range claim.Status.ReservedFor {
+				if resourceclaim.IsPodReference(consumer) && resourceclaim.UsesDevice(consumer, i) {
+					pods = append(pods, consumer.Name)
+				}
+			}
+			refs = append(refs, ClaimRef{
+				Claim:    types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name},
+				Request:  allocated.Request,
+				InFlight: inFlight,
+				Pods:     pods,
+			})
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		if a.Claim != b.Claim {
+			return a.Claim.String() < b.Claim.String()
+		}
+		return a.Request < b.Request
+	})
+	return refs
+}
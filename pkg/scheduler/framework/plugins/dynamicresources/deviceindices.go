@@ -0,0 +1,84 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+)
+
+const (
+	// AnnotationDeviceIndices is a pod annotation which declares that the
+	// pod only uses some of the devices allocated for a claim. The value
+	// is a JSON object which maps the names of entries in
+	// pod.spec.resourceClaims to lists of indices in
+	// status.allocation.devices.results of the claim, for example
+	// {"gpus": [0]}. The indices get recorded in the ReservedFor entry of
+	// the pod. Claims which are not listed are used entirely.
+	AnnotationDeviceIndices = "resource.kubernetes.io/device-indices"
+)
+
+// podDeviceIndices parses the annotation of the pod. It returns nil if
+// the pod doesn't have it.
+func podDeviceIndices(pod *v1.Pod) (map[string][]int32, error) {
+	value, ok := pod.Annotations[AnnotationDeviceIndices]
+	if !ok {
+		return nil, nil
+	}
+	var indices map[string][]int32
+	if err := json.Unmarshal([]byte(value), &indices); err != nil {
+		return nil, fmt.Errorf("annotation %s: %w", AnnotationDeviceIndices, err)
+	}
+	for podClaimName, claimIndices := range indices {
+		if !hasPodClaim(pod, podClaimName) {
+			return nil, fmt.Errorf("annotation %s: pod has no resource claim %q", AnnotationDeviceIndices, podClaimName)
+		}
+		for _, index := range claimIndices {
+			if index < 0 {
+				return nil, fmt.Errorf("annotation %s: negative device index %d for resource claim %q", AnnotationDeviceIndices, index, podClaimName)
+			}
+		}
+	}
+	return indices, nil
+}
+
+func hasPodClaim(pod *v1.Pod, podClaimName string) bool {
+	for _, podClaim := range pod.Spec.ResourceClaims {
+		if podClaim.Name == podClaimName {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDeviceIndices ensures that the indices refer to allocated devices.
+// Only then can the claim be reserved with them.
+func checkDeviceIndices(indices []int32, allocation *resourceapi.AllocationResult) error {
+	numResults := 0
+	if allocation != nil {
+		numResults = len(allocation.Devices.Results)
+	}
+	for _, index := range indices {
+		if int(index) >= numResults {
+			return fmt.Errorf("device index %d is out of range, the claim has %d allocated devices", index, numResults)
+		}
+	}
+	return nil
+}
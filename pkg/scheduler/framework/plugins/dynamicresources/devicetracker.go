@@ -0,0 +1,116 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"sync"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/dynamic-resource-allocation/structured"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/dynamicresources/metrics"
+	"k8s.io/kubernetes/pkg/scheduler/util/assumecache"
+)
+
+// allocatedDevicesTracker maintains the devices which are in use by the
+// claims in the assume cache, including in-flight allocations, across
+// scheduling cycles. The allocator gets a snapshot of those devices
+// through claimListerForAssumeCache instead of gathering them from all
+// allocated claims each time that it runs.
+//
+// Events only mark claims as changed. The latest claim gets read from the
+// assume cache when the next snapshot is taken. This makes the result
+// independent of the order in which the assume cache delivers events
+// (Assume and Restore may happen concurrently) and ensures that a change
+// made by the plugin itself is visible in the next snapshot even when the
+// event for it has not been delivered yet: the plugin marks the claim
+// itself after changing the assume cache or the in-flight allocations.
+type allocatedDevicesTracker struct {
+	assumeCache         *assumecache.AssumeCache
+	inFlightAllocations *inFlightAllocations
+	devices             *structured.AllocatedDevicesTracker
+
+	// mutex serializes updating devices. Without it, an older claim
+	// read by one goroutine could replace a newer one read by another.
+	mutex sync.Mutex
+	// dirty contains the keys of claims which may have changed.
+	dirty sets.Set[string]
+}
+
+func newAllocatedDevicesTracker(assumeCache *assumecache.AssumeCache, inFlightAllocations *inFlightAllocations) *allocatedDevicesTracker {
+	return &allocatedDevicesTracker{
+		assumeCache:         assumeCache,
+		inFlightAllocations: inFlightAllocations,
+		devices:             structured.NewAllocatedDevicesTracker(),
+		dirty:               sets.New[string](),
+	}
+}
+
+// claimEventHandler must be registered with the claim assume cache.
+func (t *allocatedDevicesTracker) claimEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: t.markObj,
+		UpdateFunc: func(_, newObj interface{}) {
+			t.markObj(newObj)
+		},
+		DeleteFunc: t.markObj,
+	}
+}
+
+func (t *allocatedDevicesTracker) markObj(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	t.mark(key)
+}
+
+// markClaim must be called after changing the in-flight allocation
+// of the claim.
+func (t *allocatedDevicesTracker) markClaim(claim *resourceapi.ResourceClaim) {
+	t.mark(claim.Namespace + "/" + claim.Name)
+}
+
+func (t *allocatedDevicesTracker) mark(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.dirty.Insert(key)
+}
+
+// snapshot brings the tracked devices up-to-date and returns them.
+func (t *allocatedDevicesTracker) snapshot() *structured.AllocatedDevices {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for key := range t.dirty {
+		var claim *resourceapi.ResourceClaim
+		if obj, err := t.assumeCache.Get(key); err == nil {
+			claim, _ = obj.(*resourceapi.ResourceClaim)
+		}
+		if claim != nil {
+			if inFlightClaim, ok := t.inFlightAllocations.loadClaim(claim.UID); ok {
+				claim = inFlightClaim
+			}
+		}
+		t.devices.Update(key, claim)
+	}
+	clear(t.dirty)
+	snapshot := t.devices.Snapshot()
+	metrics.AllocatedDevices.Set(float64(snapshot.Len()))
+	return snapshot
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/dynamic-resource-allocation/structured"
+	"k8s.io/klog/v2"
+)
+
+// DoubleAllocationDetector is implemented by the plugin. Like
+// AllocatedDevicesReader, it is meant for debugging tools.
+type DoubleAllocationDetector interface {
+	// DetectDoubleAllocations returns all devices which are allocated
+	// for more than one claim although they may only be used by one.
+	// It is safe to call concurrently with scheduling.
+	DetectDoubleAllocations(ctx context.Context) ([]DeviceRef, error)
+}
+
+var _ DoubleAllocationDetector = &dynamicResources{}
+
+// DeviceRef identifies a device and the claims it is allocated for.
+type DeviceRef struct {
+	Driver string
+	Pool   string
+	Device string
+
+	// Claims are sorted by namespace and name.
+	Claims []types.NamespacedName
+}
+
+// DetectDoubleAllocations implements DoubleAllocationDetector. It checks all
+// allocated claims, including those whose allocation is in flight. Devices
+// which are shared by several claims because each claim only consumes some
+// of their capacity and devices which are allocated with admin access are
+// not reported. The result is sorted by driver, pool and device.
+func (pl *dynamicResources) DetectDoubleAllocations(ctx context.Context) ([]DeviceRef, error) {
+	if !pl.enabled {
+		return nil, nil
+	}
+
+	claims, err := (&claimListerForAssumeCache{assumeCache: pl.claimAssumeCache, inFlightAllocations: &pl.inFlightAllocations}).ListAllAllocated()
+	if err != nil {
+		return nil, fmt.Errorf("list allocated claims: %w", err)
+	}
+
+	type usage struct {
+		claims    []types.NamespacedName
+		exclusive bool
+	}
+	// The same device name may be used in different pools and by
+	// different drivers, so the device must be identified by all three.
+	devices := make(map[structured.DeviceID]*usage)
+	for _, claim := range claims {
+		claimName := types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name}
+		for _, result := range claim.Status.Allocation.Devices.Results {
+			if hasAdminAccess(claim, result.Request) {
+				continue
+			}
+			deviceID := structured.DeviceID{Driver: result.Driver, Pool: result.Pool, Device: result.Device}
+			u := devices[deviceID]
+			if u == nil {
+				u = &usage{}
+				devices[deviceID] = u
+			}
+			u.claims = append(u.claims, claimName)
+			if len(result.ConsumedCapacity) == 0 {
+				u.exclusive = true
+			}
+		}
+	}
+
+	var refs []DeviceRef
+	for deviceID, u := range devices {
+		// A claim might list the same device more than once, which
+		// is also a problem.
+		if !u.exclusive || len(u.claims) < 2 {
+			continue
+		}
+		sort.Slice(u.claims, func(i, j int) bool {
+			return u.claims[i].String() < u.claims[j].String()
+		})
+		refs = append(refs, DeviceRef{
+			Driver: deviceID.Driver,
+			Pool:   deviceID.Pool,
+			Device: deviceID.Device,
+			Claims: u.claims,
+		})
+		klog.FromContext(ctx).V(2).Info("Device is allocated more than once", "device", deviceID, "claims", u.claims)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		switch {
+		case a.Driver != b.Driver:
+			return a.Driver < b.Driver
+		case a.Pool != b.Pool:
+			return a.Pool < b.Pool
+		default:
+			return a.Device < b.Device
+		}
+	})
+	return refs, nil
+}
+
+// hasAdminAccess checks whether the request with the given name asks for
+// admin access. Such requests get devices which are in use.
+func hasAdminAccess(claim *resourceapi.ResourceClaim, requestName string) bool {
+	for _, request := range claim.Spec.Devices.Requests {
+		if request.Name == requestName {
+			return request.AdminAccess
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"sync"
+	"time"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	resourcelisters "k8s.io/client-go/listers/resource/v1alpha3"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
+)
+
+// poolKey identifies a pool. Pool names are unique per driver.
+type poolKey struct {
+	driver string
+	pool   string
+}
+
+// poolGeneration is what poolGenerations knows about a pool.
+type poolGeneration struct {
+	nodeName   string
+	generation int64
+	// updated is when the generation was observed first.
+	updated time.Time
+	// numSlices counts the ResourceSlices of the pool, of all generations.
+	numSlices int
+}
+
+// poolGenerations remembers when the generation of each pool of a node
+// was updated. The ResourceSlice API does not record that, so the time
+// when the plugin observed a new generation is used instead. All pools
+// count as updated when the plugin starts.
+type poolGenerations struct {
+	mutex sync.Mutex
+	clock clock.PassiveClock
+	pools map[poolKey]*poolGeneration
+}
+
+// resourceEventHandler returns a handler which updates the pools for
+// ResourceSlice events. Only slices for a single node are relevant.
+func (p *poolGenerations) resourceEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if slice, ok := obj.(*resourceapi.ResourceSlice); ok {
+				p.add(slice)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldSlice, ok := oldObj.(*resourceapi.ResourceSlice)
+			if !ok {
+				return
+			}
+			newSlice, ok := newObj.(*resourceapi.ResourceSlice)
+			if !ok {
+				return
+			}
+			p.remove(oldSlice)
+			p.add(newSlice)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if slice, ok := obj.(*resourceapi.ResourceSlice); ok {
+				p.remove(slice)
+			}
+		},
+	}
+}
+
+func (p *poolGenerations) add(slice *resourceapi.ResourceSlice) {
+	if slice.Spec.NodeName == "" {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := poolKey{driver: slice.Spec.Driver, pool: slice.Spec.Pool.Name}
+	pool := p.pools[key]
+	if pool == nil {
+		if p.pools == nil {
+			p.pools = make(map[poolKey]*poolGeneration)
+		}
+		pool = &poolGeneration{generation: slice.Spec.Pool.Generation, updated: p.clock.Now()}
+		p.pools[key] = pool
+	}
+	if slice.Spec.Pool.Generation > pool.generation {
+		pool.generation = slice.Spec.Pool.Generation
+		pool.updated = p.clock.Now()
+	}
+	pool.nodeName = slice.Spec.NodeName
+	pool.numSlices++
+}
+
+func (p *poolGenerations) remove(slice *resourceapi.ResourceSlice) {
+	if slice.Spec.NodeName == "" {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := poolKey{driver: slice.Spec.Driver, pool: slice.Spec.Pool.Name}
+	pool := p.pools[key]
+	if pool == nil {
+		return
+	}
+	pool.numSlices--
+	if pool.numSlices <= 0 {
+		delete(p.pools, key)
+	}
+}
+
+// readyDrivers returns, for each node, the drivers with at least one
+// pool whose generation was updated within the given duration.
+func (p *poolGenerations) readyDrivers(readiness time.Duration) map[string]sets.Set[string] {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := p.clock.Now()
+	ready := make(map[string]sets.Set[string])
+	for key, pool := range p.pools {
+		if now.Sub(pool.updated) > readiness {
+			continue
+		}
+		if ready[pool.nodeName] == nil {
+			ready[pool.nodeName] = sets.New[string]()
+		}
+		ready[pool.nodeName].Insert(key.driver)
+	}
+	return ready
+}
+
+// readyDrivers returns the drivers which are ready on the nodes, nil if
+// the check is disabled. A driver is ready on a node if the generation
+// of one of its pools for the node was updated recently. PreFilter
+// determines this once for all nodes.
+func (pl *dynamicResources) readyDrivers() map[string]sets.Set[string] {
+	if pl.driverReadiness <= 0 {
+		return nil
+	}
+	return pl.poolGenerations.readyDrivers(pl.driverReadiness)
+}
+
+// unreadyDriver returns the first driver, in alphabetical order, of the
+// devices in the allocations which is not ready on the node, using the
+// result of readyDrivers. Returns an empty string if all drivers are
+// ready or the check is disabled.
+func unreadyDriver(readyDrivers map[string]sets.Set[string], nodeName string, allocations []*resourceapi.AllocationResult) string {
+	if readyDrivers == nil {
+		return ""
+	}
+	drivers := sets.New[string]()
+	for _, allocation := range allocations {
+		if allocation == nil {
+			continue
+		}
+		for _, result := range allocation.Devices.Results {
+			drivers.Insert(result.Driver)
+		}
+	}
+	ready := readyDrivers[nodeName]
+	for _, driver := range sets.List(drivers) {
+		if !ready.Has(driver) {
+			return driver
+		}
+	}
+	return ""
+}
+
+// readyDriverSliceLister filters out the slices of drivers which are not
+// ready when listing. Filter rejects a node when allocating devices of
+// such a driver.
+type readyDriverSliceLister struct {
+	resourcelisters.ResourceSliceLister
+	ready sets.Set[string]
+}
+
+func (l *readyDriverSliceLister) List(selector labels.Selector) ([]*resourceapi.ResourceSlice, error) {
+	slices, err := l.ResourceSliceLister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	ready := make([]*resourceapi.ResourceSlice, 0, len(slices))
+	for _, slice := range slices {
+		if l.ready.Has(slice.Spec.Driver) {
+			ready = append(ready, slice)
+		}
+	}
+	return ready, nil
+}
@@ -18,11 +18,15 @@ package dynamicresources
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"slices"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
@@ -31,23 +35,36 @@ import (
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	resourceapiapply "k8s.io/client-go/applyconfigurations/resource/v1alpha3"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	resourcelisters "k8s.io/client-go/listers/resource/v1alpha3"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/component-helpers/scheduling/corev1/nodeaffinity"
 	"k8s.io/dynamic-resource-allocation/resourceclaim"
 	"k8s.io/dynamic-resource-allocation/structured"
 	"k8s.io/klog/v2"
+	podutil "k8s.io/kubernetes/pkg/api/v1/pod"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config/validation"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/dynamicresources/metrics"
 	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/feature"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/helper"
 	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/names"
+	schedulermetrics "k8s.io/kubernetes/pkg/scheduler/metrics"
 	schedutil "k8s.io/kubernetes/pkg/scheduler/util"
 	"k8s.io/kubernetes/pkg/scheduler/util/assumecache"
+	"k8s.io/utils/clock"
 	"k8s.io/utils/ptr"
 )
 
@@ -55,9 +72,42 @@ const (
 	// Name is the name of the plugin used in Registry and configurations.
 	Name = names.DynamicResources
 
-	stateKey framework.StateKey = Name
+	// PodConditionResourceClaimsReady is the type of the pod condition
+	// which summarizes the state of the pod's ResourceClaims while the
+	// pod is getting scheduled. The condition gets removed again once
+	// the pod is bound to a node.
+	PodConditionResourceClaimsReady v1.PodConditionType = "ResourceClaimsReady"
+
+	// PodReasonWaitingForDriver is used while a resource driver
+	// still needs to allocate a claim.
+	PodReasonWaitingForDriver = "WaitingForDriver"
+	// PodReasonWaitingForDeallocation is used while a claim needs to be
+	// deallocated before the pod can be scheduled.
+	PodReasonWaitingForDeallocation = "WaitingForDeallocation"
+	// PodReasonNoDevicesAvailable is used when no node has the devices
+	// required by the pod's claims.
+	PodReasonNoDevicesAvailable = "NoDevicesAvailable"
+	// PodReasonDevicesAllocated is used when all claims are allocated
+	// and reserved and the pod is about to be bound.
+	PodReasonDevicesAllocated = "DevicesAllocated"
+
+	// ErrReasonCannotAllocate is used by Filter when there are not enough
+	// free devices on a node. Unless the pod is escalated, it gets followed
+	// by the claim and request which cannot be satisfied.
+	ErrReasonCannotAllocate = "cannot allocate all claims"
+
+	// reservationTimeout determines the ReservationDeadline of the
+	// ReservedFor entry that gets added in PreBind. Binding normally
+	// completes much faster, but other PreBind plugins may wait for a
+	// while, for example volume binding for up to ten minutes.
+	reservationTimeout = 15 * time.Minute
 )
 
+// claimUpdateBackoff limits how often PreBind tries to update a claim
+// when there are conflicts with concurrent changes. Each retry gets the
+// latest claim first.
+var claimUpdateBackoff = retry.DefaultRetry
+
 // The state is initialized in PreFilter phase. Because we save the pointer in
 // framework.CycleState, in the later phases we don't need to call Write method
 // to update the value
@@ -81,6 +131,39 @@ type stateData struct {
 	// Allocator handles claims with structured parameters.
 	allocator *structured.Allocator
 
+	// podCondition is the PodConditionResourceClaimsReady condition
+	// as it is currently stored for the pod, to the best of our
+	// knowledge. Nil if the pod has no such condition. Used to avoid
+	// redundant pod status updates.
+	podCondition *v1.PodCondition
+
+	// unschedulableCondition gets set by PreFilter when it finds a
+	// reason why the pod cannot be scheduled which should be reflected
+	// in the pod condition. PostFilter then publishes it.
+	unschedulableCondition *v1.PodCondition
+
+	// filterReasons is also stored in the CycleState under
+	// FilterReasonsStateKey for other plugins. Nil if the pod
+	// has no claims.
+	filterReasons *FilterReasons
+
+	// escalated is true if the pod has failed to schedule often enough
+	// that the plugin handles it more aggressively. Set by PreFilter.
+	escalated bool
+
+	// targetNode is the only node on which the pod can run, empty if
+	// it can run on different nodes. Set by PreFilter.
+	targetNode string
+
+	// metricsDriver is the driver label of ExtensionPointDuration. Set
+	// by PreFilter and updated by Reserve.
+	metricsDriver string
+
+	// nodeScores are the scores for node preferences, scored attributes
+	// and the nominated node, computed by PreScore. Nil if none of them
+	// applies. Score reads it concurrently without modifying it.
+	nodeScores map[string]int64
+
 	// mutex must be locked while accessing any of the fields below.
 	mutex sync.Mutex
 
@@ -93,10 +176,66 @@ type stateData struct {
 	// protected by the mutex. Used by PostFilter.
 	unavailableClaims sets.Set[int]
 
+	// unavailableClaimsPerNode contains the same indices as
+	// unavailableClaims, for each node separately. Used by PostFilter
+	// to find the smallest set of claims that need to be deallocated.
+	unavailableClaimsPerNode map[string]sets.Set[int]
+
 	informationsForClaim []informationForClaim
 
 	// nodeAllocations caches the result of Filter for the nodes.
+	// Reserve uses it instead of running the allocator again. PreScore
+	// drops the results for nodes which did not pass all Filter plugins.
 	nodeAllocations map[string][]*resourceapi.AllocationResult
+
+	// filterCache avoids running the allocator again when Filter gets
+	// called for the same node and the same inputs more than once,
+	// for example during preemption.
+	filterCache map[filterCacheKey]filterCacheEntry
+
+	// quotaReservations are the requests which the quota checker
+	// granted in Reserve. Unreserve releases them.
+	quotaReservations []QuotaRequest
+
+	// filterErrors records the result of the allocator for each node
+	// where Filter invoked it, nil if it succeeded. Results of
+	// simulations with removed pods are not included.
+	filterErrors map[string]error
+
+	// filterErrorsReported is true once the errors in filterErrors
+	// have been logged and reported as event.
+	filterErrorsReported bool
+
+	// staleDrivers and readyDrivers are determined by PreFilter for
+	// all nodes, see staleDrivers and readyDrivers of the plugin.
+	staleDrivers map[string][]string
+	readyDrivers map[string]sets.Set[string]
+}
+
+// filterCacheKey identifies the inputs of an allocation attempt in Filter.
+type filterCacheKey struct {
+	nodeName string
+	// inputs is a hash of those inputs which may change during a
+	// scheduling cycle, see filterInputs.
+	inputs uint64
+}
+
+type filterCacheEntry struct {
+	allocations   []*resourceapi.AllocationResult
+	unsatisfiable *structured.UnsatisfiableRequest
+	err           error
+}
+
+// removedPods is stored in the CycleState by RemovePod. In contrast to
+// stateData, it gets copied when the CycleState is cloned because the
+// framework removes different pods from different copies while
+// evaluating preemption.
+type removedPods struct {
+	pods sets.Set[types.UID]
+}
+
+func (r *removedPods) Clone() framework.StateData {
+	return &removedPods{pods: r.pods.Clone()}
 }
 
 func (d *stateData) Clone() framework.StateData {
@@ -104,6 +243,9 @@ func (d *stateData) Clone() framework.StateData {
 }
 
 type informationForClaim struct {
+	// podClaimName is the name of the entry in pod.spec.resourceClaims.
+	podClaimName string
+
 	// Node selectors based on the claim status (single entry, key is empty) if allocated,
 	// otherwise the device class AvailableOnNodes selectors (potentially multiple entries,
 	// key is the device class name).
@@ -118,6 +260,23 @@ type informationForClaim struct {
 
 	// Set by Reserved, published by PreBind.
 	allocation *resourceapi.AllocationResult
+
+	// allocatedDeviceClasses is the value of
+	// AnnotationAllocatedDeviceClasses, set by Reserve together with
+	// the allocation if recording is enabled.
+	allocatedDeviceClasses string
+
+	// pinnedNode is the node to which a claim which needs to be
+	// allocated is pinned by AnnotationPinnedNode, empty if none.
+	pinnedNode string
+
+	// deviceIndices are the allocated devices which the pod uses,
+	// nil if it uses all of them. Recorded in ReservedFor by PreBind.
+	deviceIndices []int32
+
+	// changes are what Reserve and PreBind did to the claim, in
+	// the order in which they happened. Unreserve undoes them.
+	changes []claimChange
 }
 
 type podSchedulingState struct {
@@ -130,12 +289,21 @@ type podSchedulingState struct {
 	// managed entirely here.
 	schedulingCtx *resourceapi.PodSchedulingContext
 
+	// claimStatuses are the entries in schedulingCtx.Status.ResourceClaims,
+	// indexed by the name of the claim in the pod spec. Built once by
+	// init so that each claim can be checked without searching the list.
+	claimStatuses map[string]*resourceapi.ResourceClaimSchedulingStatus
+
 	// selectedNode is set if (and only if) a node has been selected.
 	selectedNode *string
 
 	// potentialNodes is set if (and only if) the potential nodes field
 	// needs to be updated or set.
 	potentialNodes *[]string
+
+	// selectedByReserve is set if Reserve picked selectedNode in this
+	// scheduling cycle and PreBind has not published it yet.
+	selectedByReserve bool
 }
 
 func (p *podSchedulingState) isDirty() bool {
@@ -162,6 +330,13 @@ func (p *podSchedulingState) init(ctx context.Context, pod *v1.Pod, podSchedulin
 		}
 	}
 	p.schedulingCtx = schedulingCtx
+	if len(schedulingCtx.Status.ResourceClaims) > 0 {
+		p.claimStatuses = make(map[string]*resourceapi.ResourceClaimSchedulingStatus, len(schedulingCtx.Status.ResourceClaims))
+		for i := range schedulingCtx.Status.ResourceClaims {
+			status := &schedulingCtx.Status.ResourceClaims[i]
+			p.claimStatuses[status.Name] = status
+		}
+	}
 	return nil
 }
 
@@ -253,31 +428,74 @@ func (p *podSchedulingState) publish(ctx context.Context, pod *v1.Pod, clientset
 	}
 	p.potentialNodes = nil
 	p.selectedNode = nil
+	p.selectedByReserve = false
 	return nil
 }
 
-func statusForClaim(schedulingCtx *resourceapi.PodSchedulingContext, podClaimName string) *resourceapi.ResourceClaimSchedulingStatus {
-	if schedulingCtx == nil {
+// clearSelectedNode removes the selected node from the PodSchedulingContext
+// object, if there is one. PotentialNodes are left alone. A merge patch is
+// used because the object may have been updated since it was retrieved.
+// Must not be called concurrently.
+func (p *podSchedulingState) clearSelectedNode(ctx context.Context, pod *v1.Pod, clientset kubernetes.Interface) error {
+	if p.schedulingCtx == nil || p.schedulingCtx.Spec.SelectedNode == "" {
 		return nil
 	}
-	for _, status := range schedulingCtx.Status.ResourceClaims {
-		if status.Name == podClaimName {
-			return &status
-		}
+	logger := klog.FromContext(ctx)
+	logger.V(5).Info("Clearing selected node in PodSchedulingContext", "podSchedulingCtx", klog.KObj(p.schedulingCtx), "selectedNode", p.schedulingCtx.Spec.SelectedNode)
+	_, err := clientset.ResourceV1alpha3().PodSchedulingContexts(pod.Namespace).Patch(ctx, pod.Name, types.MergePatchType, []byte(`{"spec": {"selectedNode": null}}`), metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
 	}
-	return nil
+	return err
+}
+
+// statusForClaim returns the status which the driver reported for the
+// claim, nil if none. The result must not be modified.
+func (p *podSchedulingState) statusForClaim(podClaimName string) *resourceapi.ResourceClaimSchedulingStatus {
+	return p.claimStatuses[podClaimName]
 }
 
 // dynamicResources is a plugin that ensures that ResourceClaims are allocated.
 type dynamicResources struct {
 	enabled                       bool
+	disabledInProfile             bool // see DynamicResourcesArgs.Disabled
 	controlPlaneControllerEnabled bool
-
-	fh                         framework.Handle
+	consumableCapacityEnabled     bool
+	attributeSelectorsEnabled     bool
+	deviceTaintsEnabled           bool
+	nodeLocalOnlyEnabled          bool
+	podLabelSelectorsEnabled      bool
+	reservationDeadlineEnabled    bool
+	deviceIndicesEnabled          bool
+	writeStrategy                 config.WriteStrategyType
+	selectionPolicy               structured.SelectionPolicy
+	missingAttributeBehavior      structured.MissingAttributeBehavior
+	celFunctionGroups             []string
+	sliceMaxAge                   time.Duration
+	driverReadiness               time.Duration
+	claimEvents                   *claimEventCoalescer
+	escalationAttempts            int
+	postFilterDeallocation        bool
+	recordDeviceClasses           bool
+	preferReservedClaims          bool
+	skipClassDriverCheck          bool
+	keepAllocationDefault         bool
+	verifyAllocatedSelectors      bool
+	allowTerminatingClasses       bool
+	namespacePolicy               namespacePolicy
+	finalizerCheckDelay           time.Duration
+	clock                         clock.Clock
+
+	eventRecorder              events.EventRecorder // may be nil
+	cachesSynced               []cache.InformerSynced
 	clientset                  kubernetes.Interface
 	classLister                resourcelisters.DeviceClassLister
-	podSchedulingContextLister resourcelisters.PodSchedulingContextLister // nil if and only if DRAControlPlaneController is disabled
+	podSchedulingContextLister resourcelisters.PodSchedulingContextLister  // nil if and only if DRAControlPlaneController is disabled
+	claimTemplateLister        resourcelisters.ResourceClaimTemplateLister // nil if and only if ValidateClaimTemplates is disabled
 	sliceLister                resourcelisters.ResourceSliceLister
+	podLister                  corelisters.PodLister
+	nodeLister                 corelisters.NodeLister
+	namespaceLister            corelisters.NamespaceLister
 
 	// claimAssumeCache enables temporarily storing a newer claim object
 	// while the scheduler has allocated it and the corresponding object
@@ -306,7 +524,8 @@ type dynamicResources struct {
 	// inFlightAllocations is map from claim UUIDs to claim objects for those claims
 	// for which allocation was triggered during a scheduling cycle and the
 	// corresponding claim status update call in PreBind has not been done
-	// yet. If another pod needs the claim, the pod is treated as "not
+	// yet. Each entry also records the node and the pod for which the claim
+	// was allocated. If another pod needs the claim, the pod is treated as "not
 	// schedulable yet". The cluster event for the claim status update will
 	// make it schedulable.
 	//
@@ -328,46 +547,400 @@ type dynamicResources struct {
 	// - The assume cache is now not reflecting that the claim is allocated,
 	//   which could lead to reusing the same resource for some other claim.
 	//
-	// A sync.Map is used because in practice sharing of a claim between
+	// A sync.Map is used internally because in practice sharing of a claim between
 	// pods is expected to be rare compared to per-pod claim, so we end up
 	// hitting the "multiple goroutines read, write, and overwrite entries
 	// for disjoint sets of keys" case that sync.Map is optimized for.
-	inFlightAllocations sync.Map
+	inFlightAllocations inFlightAllocations
+
+	// startFinalizerCheck gets called by PreFilter, see runFinalizerCheck.
+	// finalizerCheckRunning tracks the goroutine which it may start.
+	startFinalizerCheck   func()
+	finalizerCheckRunning sync.WaitGroup
+
+	// deviceTracker provides the allocator with the devices which
+	// are in use by the claims in claimAssumeCache and
+	// inFlightAllocations.
+	deviceTracker *allocatedDevicesTracker
+
+	// classDevicesCache is used by PreFilter to reject claims which
+	// ask for more devices than exist in the entire cluster.
+	classDevicesCache classDevicesCache
+
+	// attributeDomainsCache is used by PreFilter to detect classes
+	// which select attributes of drivers that publish nothing.
+	attributeDomainsCache attributeDomainsCache
+
+	// podFilterCache is used by Filter to reuse the result of the
+	// allocator from an earlier scheduling cycle of the same pod.
+	podFilterCache podFilterCache
+
+	// sliceTracker is used by Filter to warn about drivers which
+	// stopped publishing ResourceSlices for a node.
+	sliceTracker sliceTracker
+
+	// poolGenerations is used by PreFilter to determine on which
+	// nodes the drivers are ready.
+	poolGenerations poolGenerations
+
+	// sliceAvailability is used by Filter to report an error instead
+	// of rejecting nodes when ResourceSlices cannot be listed.
+	sliceAvailability sliceAvailability
+
+	// driverWaits is used by Reserve to stop waiting for a control
+	// plane controller when a claim has an allocation timeout.
+	driverWaits driverWaits
+
+	// invalidPreferencesLog is used by PreScore to log invalid node
+	// preferences only once.
+	invalidPreferencesLog invalidPreferencesLog
+
+	// schedulingContextQueue contains PodSchedulingContexts which
+	// PostBind wants to have deleted in the background.
+	schedulingContextQueue workqueue.TypedRateLimitingInterface[schedulingContextRef]
+
+	// pendingSchedulingContexts bounds the work in schedulingContextQueue
+	// to maxPendingSchedulingContexts.
+	pendingSchedulingContexts    pendingSchedulingContexts
+	maxPendingSchedulingContexts int
+
+	// quotaChecker is consulted by Reserve before allocating devices.
+	// Nil if no quotas need to be enforced.
+	quotaChecker QuotaChecker
+
+	// deviceScorers are set with WithDeviceScorers and passed
+	// to the allocator.
+	deviceScorers []structured.DeviceScorer
+
+	// allocationObservers are set with WithAllocationObservers.
+	// allocationEvents is nil if there are none.
+	allocationObservers []AllocationObserver
+	allocationEvents    chan allocationEvent
+}
+
+// Option configures the plugin when it gets created by NewWithOptions.
+type Option func(pl *dynamicResources)
+
+// WithDeviceScorers adds scorers which influence which of the suitable
+// devices on a node get allocated. See [structured.DeviceScorer].
+func WithDeviceScorers(scorers ...structured.DeviceScorer) Option {
+	return func(pl *dynamicResources) {
+		pl.deviceScorers = append(pl.deviceScorers, scorers...)
+	}
+}
+
+// NewWithOptions returns a plugin factory which works like New and then
+// applies the options. Schedulers which want to use options register
+// the result instead of New.
+func NewWithOptions(opts ...Option) func(ctx context.Context, plArgs runtime.Object, fh framework.Handle, fts feature.Features) (framework.Plugin, error) {
+	return func(ctx context.Context, plArgs runtime.Object, fh framework.Handle, fts feature.Features) (framework.Plugin, error) {
+		return newPlugin(ctx, plArgs, fh, fts, opts)
+	}
 }
 
 // New initializes a new plugin and returns it.
 func New(ctx context.Context, plArgs runtime.Object, fh framework.Handle, fts feature.Features) (framework.Plugin, error) {
+	return newPlugin(ctx, plArgs, fh, fts, nil)
+}
+
+func newPlugin(ctx context.Context, plArgs runtime.Object, fh framework.Handle, fts feature.Features, opts []Option) (framework.Plugin, error) {
+	if !fts.EnableDynamicResourceAllocation {
+		// Disabled, won't do anything.
+		return &dynamicResources{}, nil
+	}
+	args, err := getArgs(plArgs)
+	if err != nil {
+		return nil, err
+	}
+	if args.Disabled {
+		// Disabled in this profile. Checked before asking for
+		// listers because that would create informers in the
+		// shared factory which nothing else might need.
+		return &dynamicResources{disabledInProfile: true}, nil
+	}
+
+	informerFactory := fh.SharedInformerFactory()
+	deps := Dependencies{
+		Client:        fh.ClientSet(),
+		ClaimCache:    fh.ResourceClaimCache(),
+		ClassLister:   informerFactory.Resource().V1alpha3().DeviceClasses().Lister(),
+		SliceLister:   informerFactory.Resource().V1alpha3().ResourceSlices().Lister(),
+		PodLister:     informerFactory.Core().V1().Pods().Lister(),
+		NodeLister:    informerFactory.Core().V1().Nodes().Lister(),
+		SliceInformer: informerFactory.Resource().V1alpha3().ResourceSlices().Informer(),
+		SliceSynced:   informerFactory.Resource().V1alpha3().ResourceSlices().Informer().HasSynced,
+		NodeInformer:  informerFactory.Core().V1().Nodes().Informer(),
+		CachesSynced: []cache.InformerSynced{
+			informerFactory.Resource().V1alpha3().ResourceClaims().Informer().HasSynced,
+			informerFactory.Core().V1().Pods().Informer().HasSynced,
+		},
+		EventRecorder: fh.EventRecorder(),
+		Features:      fts,
+		Args:          args,
+	}
+	if fts.EnableDRAControlPlaneController {
+		deps.PodSchedulingContextLister = informerFactory.Resource().V1alpha3().PodSchedulingContexts().Lister()
+	}
+	if args.ValidateClaimTemplates {
+		deps.ClaimTemplateLister = informerFactory.Resource().V1alpha3().ResourceClaimTemplates().Lister()
+	}
+	if args.NamespaceSelector != nil {
+		deps.NamespaceLister = informerFactory.Core().V1().Namespaces().Lister()
+	}
+	return NewWithDependencies(ctx, deps, opts...)
+}
+
+// Informer is the subset of [cache.SharedInformer] that the plugin
+// depends upon.
+type Informer interface {
+	AddEventHandler(handler cache.ResourceEventHandler) (cache.ResourceEventHandlerRegistration, error)
+}
+
+// Dependencies are the objects which the plugin needs when it gets
+// created by NewWithDependencies. New gets them from the framework
+// handle.
+type Dependencies struct {
+	// Client is used for all writes. Required.
+	Client kubernetes.Interface
+
+	// ClaimCache provides the ResourceClaims. Required. See
+	// [framework.Handle.ResourceClaimCache].
+	ClaimCache *assumecache.AssumeCache
+
+	// ClassLister, SliceLister, PodLister and NodeLister are required.
+	ClassLister resourcelisters.DeviceClassLister
+	SliceLister resourcelisters.ResourceSliceLister
+	PodLister   corelisters.PodLister
+	NodeLister  corelisters.NodeLister
+
+	// NamespaceLister is required if and only if
+	// Args.NamespaceSelector is set.
+	NamespaceLister corelisters.NamespaceLister
+
+	// PodSchedulingContextLister is required if and only if
+	// Features.EnableDRAControlPlaneController is set.
+	PodSchedulingContextLister resourcelisters.PodSchedulingContextLister
+
+	// ClaimTemplateLister is required if and only if
+	// Args.ValidateClaimTemplates is set.
+	ClaimTemplateLister resourcelisters.ResourceClaimTemplateLister
+
+	// SliceInformer and NodeInformer keep caches of the plugin up-to-date.
+	// They must deliver the same objects as SliceLister and NodeLister.
+	// Required.
+	SliceInformer Informer
+	NodeInformer  Informer
+
+	// SliceSynced reports whether SliceInformer has synced. Optional. If
+	// set and the informer has not synced some time after creating the
+	// plugin, then Filter fails with an error instead of rejecting all
+	// nodes for lack of devices. If SliceInformer also implements
+	// SetWatchErrorHandler, then the error includes the cause.
+	SliceSynced cache.InformerSynced
+
+	// CachesSynced are waited for before the plugin starts checking
+	// claims and pods in the background. Optional.
+	CachesSynced []cache.InformerSynced
+
+	// EventRecorder is used to emit events. Optional.
+	EventRecorder events.EventRecorder
+
+	// Features determines which features are enabled.
+	Features feature.Features
+
+	// Args configure the plugin. Nil is replaced with the defaults.
+	Args *config.DynamicResourcesArgs
+}
+
+// NewWithDependencies initializes a new plugin with the given
+// dependencies instead of a framework handle. This is useful for
+// tests and tools which don't have a framework. The plugin runs
+// goroutines which stop when the context is canceled.
+func NewWithDependencies(ctx context.Context, deps Dependencies, opts ...Option) (framework.Plugin, error) {
+	fts := deps.Features
 	if !fts.EnableDynamicResourceAllocation {
 		// Disabled, won't do anything.
 		return &dynamicResources{}, nil
 	}
+	args := deps.Args
+	if args == nil {
+		var err error
+		if args, err = getArgs(nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := validation.ValidateDynamicResourcesArgs(nil, args); err != nil {
+		return nil, err
+	}
+	if args.Disabled {
+		return &dynamicResources{disabledInProfile: true}, nil
+	}
+	for _, dep := range []struct {
+		name    string
+		missing bool
+	}{
+		{"Client", deps.Client == nil},
+		{"ClaimCache", deps.ClaimCache == nil},
+		{"ClassLister", deps.ClassLister == nil},
+		{"SliceLister", deps.SliceLister == nil},
+		{"PodLister", deps.PodLister == nil},
+		{"NodeLister", deps.NodeLister == nil},
+		{"NamespaceLister", args.NamespaceSelector != nil && deps.NamespaceLister == nil},
+		{"PodSchedulingContextLister", fts.EnableDRAControlPlaneController && deps.PodSchedulingContextLister == nil},
+		{"ClaimTemplateLister", args.ValidateClaimTemplates && deps.ClaimTemplateLister == nil},
+		{"SliceInformer", deps.SliceInformer == nil},
+		{"NodeInformer", deps.NodeInformer == nil},
+	} {
+		if dep.missing {
+			return nil, fmt.Errorf("missing dependency %s", dep.name)
+		}
+	}
 
 	pl := &dynamicResources{
 		enabled:                       true,
 		controlPlaneControllerEnabled: fts.EnableDRAControlPlaneController,
-
-		fh:               fh,
-		clientset:        fh.ClientSet(),
-		classLister:      fh.SharedInformerFactory().Resource().V1alpha3().DeviceClasses().Lister(),
-		sliceLister:      fh.SharedInformerFactory().Resource().V1alpha3().ResourceSlices().Lister(),
-		claimAssumeCache: fh.ResourceClaimCache(),
+		consumableCapacityEnabled:     fts.EnableDRAConsumableCapacity,
+		attributeSelectorsEnabled:     fts.EnableDRAAttributeSelectors,
+		deviceTaintsEnabled:           fts.EnableDRADeviceTaints,
+		nodeLocalOnlyEnabled:          fts.EnableDRANodeLocalOnly,
+		podLabelSelectorsEnabled:      fts.EnableDRAPodLabelSelectors,
+		reservationDeadlineEnabled:    fts.EnableDRAReservationDeadline,
+		deviceIndicesEnabled:          fts.EnableDRAReservedDeviceIndices,
+		writeStrategy:                 args.WriteStrategy,
+		selectionPolicy:               structured.SelectionPolicy(args.DeviceSelectionPolicy),
+		missingAttributeBehavior:      structured.MissingAttributeBehavior(args.MissingAttributeBehavior),
+		celFunctionGroups:             args.AllowedCELFunctionGroups,
+		sliceMaxAge:                   time.Duration(args.ResourceSliceMaxAgeSeconds) * time.Second,
+		driverReadiness:               time.Duration(args.DriverReadinessSeconds) * time.Second,
+		escalationAttempts:            int(args.FailedAttemptsBeforeEscalation),
+		postFilterDeallocation:        args.EnablePostFilterDeallocation,
+		recordDeviceClasses:           args.RecordAllocatedDeviceClasses,
+		preferReservedClaims:          args.PreferReservedClaims,
+		skipClassDriverCheck:          args.SkipClassDriverCheck,
+		keepAllocationDefault:         args.KeepAllocationOnTopologyMismatch,
+		verifyAllocatedSelectors:      args.VerifyAllocatedSelectors,
+		allowTerminatingClasses:       args.AllowTerminatingDeviceClasses,
+		finalizerCheckDelay:           orphanedFinalizerCheckDelay,
+		clock:                         clock.RealClock{},
+
+		eventRecorder:    deps.EventRecorder,
+		cachesSynced:     deps.CachesSynced,
+		clientset:        deps.Client,
+		classLister:      deps.ClassLister,
+		sliceLister:      deps.SliceLister,
+		podLister:        deps.PodLister,
+		nodeLister:       deps.NodeLister,
+		namespaceLister:  deps.NamespaceLister,
+		claimAssumeCache: deps.ClaimCache,
+
+		schedulingContextQueue:       newSchedulingContextQueue(),
+		maxPendingSchedulingContexts: defaultMaxPendingSchedulingContexts,
+	}
+	for _, opt := range opts {
+		opt(pl)
+	}
+	if pl.controlPlaneControllerEnabled {
+		pl.podSchedulingContextLister = deps.PodSchedulingContextLister
+	}
+	if args.ValidateClaimTemplates {
+		pl.claimTemplateLister = deps.ClaimTemplateLister
+	}
+	policy, err := newNamespacePolicy(args)
+	if err != nil {
+		return nil, err
+	}
+	pl.namespacePolicy = policy
+	pl.claimEvents = newClaimEventCoalescer(time.Duration(args.ClaimEventCoalescingMilliseconds)*time.Millisecond, pl.clock)
+	if checker := newArgsQuotaChecker(args.DeviceQuotas, &claimListerForAssumeCache{assumeCache: pl.claimAssumeCache, inFlightAllocations: &pl.inFlightAllocations}); checker != nil {
+		pl.quotaChecker = checker
+	}
+	if _, err := deps.SliceInformer.AddEventHandler(pl.classDevicesCache.resourceEventHandler()); err != nil {
+		return nil, fmt.Errorf("add ResourceSlice event handler: %w", err)
+	}
+	pl.sliceTracker.clock = pl.clock
+	if _, err := deps.SliceInformer.AddEventHandler(pl.sliceTracker.resourceEventHandler()); err != nil {
+		return nil, fmt.Errorf("add ResourceSlice event handler: %w", err)
+	}
+	pl.poolGenerations.clock = pl.clock
+	if _, err := deps.SliceInformer.AddEventHandler(pl.poolGenerations.resourceEventHandler()); err != nil {
+		return nil, fmt.Errorf("add ResourceSlice event handler: %w", err)
+	}
+	if _, err := deps.SliceInformer.AddEventHandler(pl.attributeDomainsCache.resourceEventHandler()); err != nil {
+		return nil, fmt.Errorf("add ResourceSlice event handler: %w", err)
 	}
+	if _, err := deps.SliceInformer.AddEventHandler(pl.podFilterCache.sliceEventHandler()); err != nil {
+		return nil, fmt.Errorf("add ResourceSlice event handler: %w", err)
+	}
+	if _, err := deps.SliceInformer.AddEventHandler(pl.allocatedSelectorsEventHandler(ctx)); err != nil {
+		return nil, fmt.Errorf("add ResourceSlice event handler: %w", err)
+	}
+	pl.claimAssumeCache.AddEventHandler(pl.podFilterCache.claimEventHandler())
 	if pl.controlPlaneControllerEnabled {
-		pl.podSchedulingContextLister = fh.SharedInformerFactory().Resource().V1alpha3().PodSchedulingContexts().Lister()
+		pl.claimAssumeCache.AddEventHandler(pl.driverWaits.claimEventHandler())
+	}
+	pl.deviceTracker = newAllocatedDevicesTracker(pl.claimAssumeCache, &pl.inFlightAllocations)
+	pl.inFlightAllocations.deviceTracker = pl.deviceTracker
+	pl.claimAssumeCache.AddEventHandler(pl.deviceTracker.claimEventHandler())
+	if _, err := deps.NodeInformer.AddEventHandler(pl.sliceTracker.nodeEventHandler()); err != nil {
+		return nil, fmt.Errorf("add Node event handler: %w", err)
+	}
+	if deps.SliceSynced != nil {
+		pl.sliceAvailability.hasSynced = deps.SliceSynced
+		if setter, ok := deps.SliceInformer.(watchErrorHandlerSetter); ok {
+			// Fails if the informer was already started, in which
+			// case the error is simply not known.
+			if err := setter.SetWatchErrorHandler(pl.sliceAvailability.watchErrorHandler); err != nil {
+				klog.FromContext(ctx).V(3).Info("Cannot observe errors of the ResourceSlice informer", "err", err)
+			}
+		}
+
+		// Users need to know when the plugin cannot work at all.
+		go pl.checkSliceAvailability(ctx, sliceSyncGracePeriod)
+	}
+
+	// Claims which carry our finalizer without needing it any more
+	// cannot be deleted. Check for those in the background, but only
+	// once the plugin schedules pods.
+	pl.startFinalizerCheck = sync.OnceFunc(func() { pl.runFinalizerCheck(ctx) })
+
+	// PostBind leaves deleting PodSchedulingContexts to a background
+	// worker which also retries failed deletions.
+	go pl.deleteSchedulingContexts(ctx)
+
+	if len(pl.allocationObservers) > 0 {
+		pl.allocationEvents = make(chan allocationEvent, allocationEventQueueSize)
+		go pl.notifyAllocationObservers(ctx)
 	}
 
 	return pl, nil
 }
 
+// getArgs returns the plugin arguments. Nil is accepted and replaced
+// with the defaults because the plugin does not have to be configured
+// when it gets created directly instead of through the framework.
+func getArgs(obj runtime.Object) (*config.DynamicResourcesArgs, error) {
+	if obj == nil {
+		return &config.DynamicResourcesArgs{WriteStrategy: config.UpdateWriteStrategy, DeviceSelectionPolicy: config.BestFitDeviceSelectionPolicy, MissingAttributeBehavior: config.ErrorMissingAttributeBehavior, FailedAttemptsBeforeEscalation: 10, EnablePostFilterDeallocation: true, PreferReservedClaims: true}, nil
+	}
+	args, ok := obj.(*config.DynamicResourcesArgs)
+	if !ok {
+		return nil, fmt.Errorf("want args to be of type DynamicResourcesArgs, got %T", obj)
+	}
+	return args, nil
+}
+
 var _ framework.PreEnqueuePlugin = &dynamicResources{}
 var _ framework.PreFilterPlugin = &dynamicResources{}
+var _ framework.PreFilterExtensions = &dynamicResources{}
 var _ framework.FilterPlugin = &dynamicResources{}
 var _ framework.PostFilterPlugin = &dynamicResources{}
 var _ framework.PreScorePlugin = &dynamicResources{}
+var _ framework.ScorePlugin = &dynamicResources{}
 var _ framework.ReservePlugin = &dynamicResources{}
 var _ framework.EnqueueExtensions = &dynamicResources{}
 var _ framework.PreBindPlugin = &dynamicResources{}
 var _ framework.PostBindPlugin = &dynamicResources{}
+var _ framework.DebugHandlerProvider = &dynamicResources{}
 
 // Name returns name of the plugin. It is used in logs, etc.
 func (pl *dynamicResources) Name() string {
@@ -383,7 +956,7 @@ func (pl *dynamicResources) EventsToRegister(_ context.Context) ([]framework.Clu
 
 	events := []framework.ClusterEventWithHint{
 		// Allocation is tracked in ResourceClaims, so any changes may make the pods schedulable.
-		{Event: framework.ClusterEvent{Resource: framework.ResourceClaim, ActionType: framework.Add | framework.Update}, QueueingHintFn: pl.isSchedulableAfterClaimChange},
+		{Event: framework.ClusterEvent{Resource: framework.ResourceClaim, ActionType: framework.Add | framework.Update}, QueueingHintFn: pl.claimEvents.wrap(pl.isSchedulableAfterClaimChange)},
 		// A resource might depend on node labels for topology filtering.
 		// A new or updated node may make pods schedulable.
 		//
@@ -395,9 +968,11 @@ func (pl *dynamicResources) EventsToRegister(_ context.Context) ([]framework.Clu
 		// As a workaround, we add UpdateNodeTaint event to catch the case.
 		// We can remove UpdateNodeTaint when we remove the preCheck feature.
 		// See: https://github.com/kubernetes/kubernetes/issues/110175
-		{Event: framework.ClusterEvent{Resource: framework.Node, ActionType: framework.Add | framework.UpdateNodeLabel | framework.UpdateNodeTaint}},
+		{Event: framework.ClusterEvent{Resource: framework.Node, ActionType: framework.Add | framework.UpdateNodeLabel | framework.UpdateNodeTaint}, QueueingHintFn: pl.isSchedulableAfterNodeChange},
 		// A pod might be waiting for a class to get created or modified.
-		{Event: framework.ClusterEvent{Resource: framework.DeviceClass, ActionType: framework.Add | framework.Update}},
+		{Event: framework.ClusterEvent{Resource: framework.DeviceClass, ActionType: framework.Add | framework.Update}, QueueingHintFn: pl.isSchedulableAfterClassChange},
+		// A pod might be waiting for more devices to get published.
+		{Event: framework.ClusterEvent{Resource: framework.ResourceSlice, ActionType: framework.Add | framework.Update}, QueueingHintFn: pl.isSchedulableAfterResourceSliceChange},
 	}
 
 	if pl.podSchedulingContextLister != nil {
@@ -419,9 +994,17 @@ func (pl *dynamicResources) PreEnqueue(ctx context.Context, pod *v1.Pod) (status
 		return nil
 	}
 
-	if err := pl.foreachPodResourceClaim(pod, nil); err != nil {
+	var invalidTimeout error
+	if err := pl.foreachPodResourceClaim(pod, func(_ string, claim *resourceapi.ResourceClaim) {
+		if _, err := allocationTimeout(claim); err != nil && invalidTimeout == nil {
+			invalidTimeout = errorContext{claim: claim}.wrap(err)
+		}
+	}); err != nil {
 		return statusUnschedulable(klog.FromContext(ctx), err.Error())
 	}
+	if invalidTimeout != nil {
+		return statusUnschedulable(klog.FromContext(ctx), invalidTimeout.Error())
+	}
 	return nil
 }
 
@@ -436,6 +1019,17 @@ func (pl *dynamicResources) isSchedulableAfterClaimChange(logger klog.Logger, po
 		return framework.Queue, fmt.Errorf("unexpected object in isSchedulableAfterClaimChange: %w", err)
 	}
 
+	if originalClaim != nil && isNoOpUpdate(originalClaim, modifiedClaim) {
+		logger.V(5).Info("claim did not change", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "reason", "skipping because claim is unchanged")
+		metrics.SkippedNoOpEvents.WithLabelValues("resourceclaims").Inc()
+		return framework.QueueSkip, nil
+	}
+
+	if allowed, err := pl.isNamespaceAllowed(pod.Namespace); err == nil && !allowed {
+		logger.V(5).Info("pod in namespace without ResourceClaim support", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "reason", "skipping because namespace of the pod is not allowed")
+		return framework.QueueSkip, nil
+	}
+
 	usesClaim := false
 	if err := pl.foreachPodResourceClaim(pod, func(_ string, claim *resourceapi.ResourceClaim) {
 		if claim.UID == modifiedClaim.UID {
@@ -445,7 +1039,7 @@ func (pl *dynamicResources) isSchedulableAfterClaimChange(logger klog.Logger, po
 		// This is not an unexpected error: we know that
 		// foreachPodResourceClaim only returns errors for "not
 		// schedulable".
-		logger.V(4).Info("pod is not schedulable", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "reason", err.Error())
+		logger.V(5).Info("pod is not schedulable", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "reason", err.Error())
 		return framework.QueueSkip, nil
 	}
 
@@ -458,18 +1052,36 @@ func (pl *dynamicResources) isSchedulableAfterClaimChange(logger klog.Logger, po
 		//
 		// TODO (https://github.com/kubernetes/kubernetes/issues/123697):
 		// check that the pending claims depend on structured parameters (depends on refactoring foreachPodResourceClaim, see other TODO).
-		logger.V(6).Info("claim with structured parameters got deallocated", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim))
+		logger.V(5).Info("claim with structured parameters got deallocated", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "reason", "queueing because devices of some other claim became available")
 		return framework.Queue, nil
 	}
 
 	if !usesClaim {
 		// This was not the claim the pod was waiting for.
-		logger.V(6).Info("unrelated claim got modified", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim))
+		logger.V(5).Info("unrelated claim got modified", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "reason", "skipping because pod does not use the claim")
 		return framework.QueueSkip, nil
 	}
 
 	if originalClaim == nil {
-		logger.V(4).Info("claim for pod got created", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim))
+		logger.V(5).Info("claim for pod got created", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "reason", "queueing because claim was created")
+		return framework.Queue, nil
+	}
+
+	if isDeallocationRequestedForPod(pod, originalClaim, modifiedClaim) {
+		// PostFilter asked the driver to deallocate. The pod cannot
+		// be scheduled before the driver has done that, which
+		// triggers another event.
+		logger.V(5).Info("deallocation of claim for pod got requested", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "reason", "skipping because deallocation is still pending")
+		return framework.QueueSkip, nil
+	}
+
+	if originalClaim.Annotations[AnnotationAllocationTimeout] != modifiedClaim.Annotations[AnnotationAllocationTimeout] {
+		logger.V(5).Info("allocation timeout of claim for pod got changed", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "reason", "queueing because allocation timeout changed")
+		return framework.Queue, nil
+	}
+
+	if originalClaim.DeletionTimestamp != nil && modifiedClaim.DeletionTimestamp == nil {
+		logger.V(5).Info("deletion of claim for pod got cancelled", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "reason", "queueing because claim is no longer being deleted")
 		return framework.Queue, nil
 	}
 
@@ -478,16 +1090,187 @@ func (pl *dynamicResources) isSchedulableAfterClaimChange(logger klog.Logger, po
 	// and we don't care. What happens in practice is that the
 	// resource driver adds the finalizer.
 	if apiequality.Semantic.DeepEqual(&originalClaim.Status, &modifiedClaim.Status) {
-		if loggerV := logger.V(7); loggerV.Enabled() {
+		values := []any{"pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "reason", "skipping because claim status is unchanged"}
+		if logger.V(7).Enabled() {
 			// Log more information.
-			loggerV.Info("claim for pod got modified where the pod doesn't care", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "diff", cmp.Diff(originalClaim, modifiedClaim))
-		} else {
-			logger.V(6).Info("claim for pod got modified where the pod doesn't care", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim))
+			values = append(values, "diff", cmp.Diff(originalClaim, modifiedClaim))
+		}
+		logger.V(5).Info("claim for pod got modified where the pod doesn't care", values...)
+		return framework.QueueSkip, nil
+	}
+
+	logger.V(5).Info("status of claim for pod got updated", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim), "reason", claimStatusChangeReason(&originalClaim.Status, &modifiedClaim.Status))
+	return framework.Queue, nil
+}
+
+// isSchedulableAfterResourceSliceChange is invoked for add and update slice
+// events reported by an informer. A pod with pending claims may become
+// schedulable when the slice provides more devices matching the class of one
+// of the requests than before, or provides devices matching a request where
+// it didn't before. That includes claims which asked for more devices than
+// existed in the cluster. Any other change of the devices of a driver which
+// the pod might need, for example removing a taint, also may make the pod
+// schedulable, and so may a new pool generation when the readiness of
+// drivers is checked. It errs on the side of letting a pod scheduling attempt
+// happen.
+func (pl *dynamicResources) isSchedulableAfterResourceSliceChange(logger klog.Logger, pod *v1.Pod, oldObj, newObj interface{}) (framework.QueueingHint, error) {
+	originalSlice, modifiedSlice, err := schedutil.As[*resourceapi.ResourceSlice](oldObj, newObj)
+	if err != nil {
+		// Shouldn't happen.
+		return framework.Queue, fmt.Errorf("unexpected object in isSchedulableAfterResourceSliceChange: %w", err)
+	}
+
+	if allowed, err := pl.isNamespaceAllowed(pod.Namespace); err == nil && !allowed {
+		logger.V(5).Info("pod in namespace without ResourceClaim support", "pod", klog.KObj(pod), "resourceslice", klog.KObj(modifiedSlice), "reason", "skipping because namespace of the pod is not allowed")
+		return framework.QueueSkip, nil
+	}
+
+	var pendingClaims []*resourceapi.ResourceClaim
+	if err := pl.foreachPodResourceClaim(pod, func(_ string, claim *resourceapi.ResourceClaim) {
+		if claim.Status.Allocation != nil || claim.Spec.Controller != "" {
+			return
 		}
+		pendingClaims = append(pendingClaims, claim)
+	}); err != nil {
+		// This is not an unexpected error: we know that
+		// foreachPodResourceClaim only returns errors for "not
+		// schedulable".
+		logger.V(5).Info("pod is not schedulable", "pod", klog.KObj(pod), "resourceslice", klog.KObj(modifiedSlice), "reason", err.Error())
+		return framework.QueueSkip, nil
+	}
+	if len(pendingClaims) == 0 {
+		logger.V(5).Info("pod has no pending claims", "pod", klog.KObj(pod), "resourceslice", klog.KObj(modifiedSlice), "reason", "skipping because all claims are allocated")
 		return framework.QueueSkip, nil
 	}
 
-	logger.V(4).Info("status of claim for pod got updated", "pod", klog.KObj(pod), "claim", klog.KObj(modifiedClaim))
+	if originalSlice != nil && !pl.isSliceUsable(originalSlice) && pl.isSliceUsable(modifiedSlice) {
+		// The devices of the stale slice or of the slice in
+		// maintenance were ignored, so all devices are new.
+		originalSlice = nil
+	}
+
+	ctx := klog.NewContext(context.Background(), logger)
+	podLabels := pl.podLabels(pod)
+	for _, claim := range pendingClaims {
+		for requestIndex, request := range claim.Spec.Devices.Requests {
+			class, err := pl.classLister.Get(request.DeviceClassName)
+			if err != nil {
+				// A missing class is handled by DeviceClass events.
+				continue
+			}
+			numDevices := structured.ClassDevices(ctx, class, podLabels, []*resourceapi.ResourceSlice{modifiedSlice})
+			if originalSlice != nil {
+				numDevices -= structured.ClassDevices(ctx, class, podLabels, []*resourceapi.ResourceSlice{originalSlice})
+			}
+			if numDevices > 0 {
+				logger.V(5).Info("resource slice provides new devices for pod", "pod", klog.KObj(pod), "resourceslice", klog.KObj(modifiedSlice), "deviceclass", klog.KObj(class), "reason", "queueing because more devices of the class are available")
+				return framework.Queue, nil
+			}
+			if structured.RequestDevicesExist(ctx, class, claim, requestIndex, podLabels, []*resourceapi.ResourceSlice{modifiedSlice}) &&
+				(originalSlice == nil || !structured.RequestDevicesExist(ctx, class, claim, requestIndex, podLabels, []*resourceapi.ResourceSlice{originalSlice})) {
+				logger.V(5).Info("resource slice provides new devices for pod", "pod", klog.KObj(pod), "resourceslice", klog.KObj(modifiedSlice), "resourceclaim", klog.KObj(claim), "request", request.Name, "reason", "queueing because devices match the request")
+				return framework.Queue, nil
+			}
+		}
+	}
+
+	// Other changes of the devices, like removing a taint, are not
+	// covered by the checks above. They are relevant if the pod might
+	// need devices of the driver. The same applies to a new generation
+	// of the pool, which makes the driver ready again when Filter
+	// rejected nodes because it wasn't.
+	if originalSlice != nil && pl.pendingDrivers(pendingClaims)(modifiedSlice.Spec.Driver) {
+		if !apiequality.Semantic.DeepEqual(originalSlice.Spec.Devices, modifiedSlice.Spec.Devices) {
+			logger.V(5).Info("resource slice changed devices for pod", "pod", klog.KObj(pod), "resourceslice", klog.KObj(modifiedSlice), "driver", modifiedSlice.Spec.Driver, "reason", "queueing because devices of a driver needed by the pod changed")
+			return framework.Queue, nil
+		}
+		if pl.driverReadiness > 0 && modifiedSlice.Spec.Pool.Generation > originalSlice.Spec.Pool.Generation {
+			logger.V(5).Info("resource slice has new pool generation for pod", "pod", klog.KObj(pod), "resourceslice", klog.KObj(modifiedSlice), "driver", modifiedSlice.Spec.Driver, "reason", "queueing because a driver needed by the pod might be ready now")
+			return framework.Queue, nil
+		}
+	}
+
+	logger.V(5).Info("resource slice provides no new devices for pod", "pod", klog.KObj(pod), "resourceslice", klog.KObj(modifiedSlice), "reason", "skipping because no device matches a pending request")
+	return framework.QueueSkip, nil
+}
+
+// isNoOpUpdate returns true if an update event did not change anything
+// except perhaps the ResourceVersion, as happens for all objects during a
+// resync of an informer. Comparing the ResourceVersion is enough for that
+// case, the more expensive comparison of the content is the fallback.
+func isNoOpUpdate[T any, PT interface {
+	*T
+	metav1.Object
+}](oldObj, newObj PT) bool {
+	if oldObj == nil || newObj == nil {
+		return false
+	}
+	if oldObj.GetResourceVersion() != "" && oldObj.GetResourceVersion() == newObj.GetResourceVersion() {
+		return true
+	}
+	// A shallow copy is enough for comparing with a different ResourceVersion.
+	oldCopy := *oldObj
+	PT(&oldCopy).SetResourceVersion(newObj.GetResourceVersion())
+	return apiequality.Semantic.DeepEqual(&oldCopy, (*T)(newObj))
+}
+
+// isDeallocationRequestedForPod returns true if the only change of the claim
+// is the one made by PostFilter when it requests deallocation on behalf of
+// the pod: DeallocationRequested gets set and the reservation for the pod,
+// if there was one, gets removed. Clearing a structured allocation is not
+// covered because there is no further event for it.
+func isDeallocationRequestedForPod(pod *v1.Pod, originalClaim, modifiedClaim *resourceapi.ResourceClaim) bool {
+	if originalClaim.Status.DeallocationRequested || !modifiedClaim.Status.DeallocationRequested {
+		return false
+	}
+	if isReservedForOthers(pod, originalClaim) || len(modifiedClaim.Status.ReservedFor) > 0 {
+		return false
+	}
+	// Everything else in the status must be unchanged. A shallow copy
+	// is enough for the comparison.
+	status := modifiedClaim.Status
+	status.DeallocationRequested = false
+	status.ReservedFor = originalClaim.Status.ReservedFor
+	return apiequality.Semantic.DeepEqual(&originalClaim.Status, &status)
+}
+
+// claimStatusChangeReason describes which fields of a claim status changed
+// for log output.
+func claimStatusChangeReason(oldStatus, newStatus *resourceapi.ResourceClaimStatus) string {
+	var changed []string
+	if !apiequality.Semantic.DeepEqual(oldStatus.Allocation, newStatus.Allocation) {
+		changed = append(changed, "status.allocation")
+	}
+	if !apiequality.Semantic.DeepEqual(oldStatus.ReservedFor, newStatus.ReservedFor) {
+		changed = append(changed, "status.reservedFor")
+	}
+	if oldStatus.DeallocationRequested != newStatus.DeallocationRequested {
+		changed = append(changed, "status.deallocationRequested")
+	}
+	if len(changed) == 0 {
+		// Some field that this code doesn't know about.
+		return "queueing because claim status changed"
+	}
+	return fmt.Sprintf("queueing because claim %s changed", strings.Join(changed, " and "))
+}
+
+// isSchedulableAfterClassChange is invoked for add and update DeviceClass
+// events reported by an informer. Any new class and any change may make a
+// pod schedulable, for example because a class of the same name replaces
+// one that was deleted or because the deletion of a class got cancelled.
+// The only exception is a class which starts to get deleted: pods which
+// were waiting for it cannot use it now either.
+func (pl *dynamicResources) isSchedulableAfterClassChange(logger klog.Logger, pod *v1.Pod, oldObj, newObj interface{}) (framework.QueueingHint, error) {
+	originalClass, modifiedClass, err := schedutil.As[*resourceapi.DeviceClass](oldObj, newObj)
+	if err != nil {
+		// Shouldn't happen.
+		return framework.Queue, fmt.Errorf("unexpected object in isSchedulableAfterClassChange: %w", err)
+	}
+	if originalClass != nil && originalClass.DeletionTimestamp == nil && modifiedClass.DeletionTimestamp != nil && !pl.allowTerminatingClasses {
+		logger.V(5).Info("device class is being deleted", "pod", klog.KObj(pod), "deviceclass", klog.KObj(modifiedClass), "reason", "skipping because the class cannot be used while it is being deleted")
+		return framework.QueueSkip, nil
+	}
+	logger.V(5).Info("device class changed", "pod", klog.KObj(pod), "deviceclass", klog.KObj(modifiedClass), "reason", "queueing because the class changed")
 	return framework.Queue, nil
 }
 
@@ -500,7 +1283,7 @@ func (pl *dynamicResources) isSchedulableAfterPodSchedulingContextChange(logger
 	// Deleted? That can happen because we ourselves delete the PodSchedulingContext while
 	// working on the pod. This can be ignored.
 	if oldObj != nil && newObj == nil {
-		logger.V(4).Info("PodSchedulingContext got deleted")
+		logger.V(5).Info("PodSchedulingContext got deleted", "pod", klog.KObj(pod), "reason", "skipping because the scheduler deletes the object itself")
 		return framework.QueueSkip, nil
 	}
 
@@ -511,8 +1294,19 @@ func (pl *dynamicResources) isSchedulableAfterPodSchedulingContextChange(logger
 	}
 	podScheduling := newPodScheduling // Never nil because deletes are handled above.
 
+	if oldPodScheduling != nil && isNoOpUpdate(oldPodScheduling, podScheduling) {
+		logger.V(5).Info("PodSchedulingContext did not change", "pod", klog.KObj(pod), "podScheduling", klog.KObj(podScheduling), "reason", "skipping because PodSchedulingContext is unchanged")
+		metrics.SkippedNoOpEvents.WithLabelValues("podschedulingcontexts").Inc()
+		return framework.QueueSkip, nil
+	}
+
 	if podScheduling.Name != pod.Name || podScheduling.Namespace != pod.Namespace {
-		logger.V(7).Info("PodSchedulingContext for unrelated pod got modified", "pod", klog.KObj(pod), "podScheduling", klog.KObj(podScheduling))
+		logger.V(5).Info("PodSchedulingContext for unrelated pod got modified", "pod", klog.KObj(pod), "podScheduling", klog.KObj(podScheduling), "reason", "skipping because PodSchedulingContext is for some other pod")
+		return framework.QueueSkip, nil
+	}
+	if owner := metav1.GetControllerOf(podScheduling); owner != nil && owner.UID != pod.UID {
+		// Left behind for some earlier pod with the same name.
+		logger.V(5).Info("PodSchedulingContext for different pod with same name got modified", "pod", klog.KObj(pod), "podScheduling", klog.KObj(podScheduling), "reason", "skipping because PodSchedulingContext belongs to a different pod with the same name")
 		return framework.QueueSkip, nil
 	}
 
@@ -531,7 +1325,7 @@ func (pl *dynamicResources) isSchedulableAfterPodSchedulingContextChange(logger
 		// This is not an unexpected error: we know that
 		// foreachPodResourceClaim only returns errors for "not
 		// schedulable".
-		logger.V(4).Info("pod is not schedulable, keep waiting", "pod", klog.KObj(pod), "reason", err.Error())
+		logger.V(5).Info("pod is not schedulable, keep waiting", "pod", klog.KObj(pod), "reason", err.Error())
 		return framework.QueueSkip, nil
 	}
 
@@ -541,18 +1335,18 @@ func (pl *dynamicResources) isSchedulableAfterPodSchedulingContextChange(logger
 		// potential nodes list.  But pod scheduling attempts are
 		// expensive and doing them too often causes the pod to enter
 		// backoff. Let's wait instead for all drivers to reply.
-		if loggerV := logger.V(6); loggerV.Enabled() {
-			loggerV.Info("PodSchedulingContext with missing resource claim information, keep waiting", "pod", klog.KObj(pod), "podSchedulingDiff", cmp.Diff(oldPodScheduling, podScheduling))
-		} else {
-			logger.V(5).Info("PodSchedulingContext with missing resource claim information, keep waiting", "pod", klog.KObj(pod))
+		values := []any{"pod", klog.KObj(pod), "reason", "skipping because drivers have not provided information for all claims"}
+		if logger.V(6).Enabled() {
+			values = append(values, "podSchedulingDiff", cmp.Diff(oldPodScheduling, podScheduling))
 		}
+		logger.V(5).Info("PodSchedulingContext with missing resource claim information, keep waiting", values...)
 		return framework.QueueSkip, nil
 	}
 
 	if oldPodScheduling == nil /* create */ ||
 		len(oldPodScheduling.Status.ResourceClaims) < len(podScheduling.Status.ResourceClaims) /* new information and not incomplete (checked above) */ {
 		// This definitely is new information for the scheduler. Try again immediately.
-		logger.V(4).Info("PodSchedulingContext for pod has all required information, schedule immediately", "pod", klog.KObj(pod))
+		logger.V(5).Info("PodSchedulingContext for pod has all required information, schedule immediately", "pod", klog.KObj(pod), "reason", "queueing because drivers provided information for all claims")
 		return framework.Queue, nil
 	}
 
@@ -577,7 +1371,7 @@ func (pl *dynamicResources) isSchedulableAfterPodSchedulingContextChange(logger
 	if podScheduling.Spec.SelectedNode != "" {
 		for _, claimStatus := range podScheduling.Status.ResourceClaims {
 			if slices.Contains(claimStatus.UnsuitableNodes, podScheduling.Spec.SelectedNode) {
-				logger.V(5).Info("PodSchedulingContext has unsuitable selected node, schedule immediately", "pod", klog.KObj(pod), "selectedNode", podScheduling.Spec.SelectedNode, "podResourceName", claimStatus.Name)
+				logger.V(5).Info("PodSchedulingContext has unsuitable selected node, schedule immediately", "pod", klog.KObj(pod), "selectedNode", podScheduling.Spec.SelectedNode, "podResourceName", claimStatus.Name, "reason", "queueing because the selected node is unsuitable")
 				return framework.Queue, nil
 			}
 		}
@@ -587,7 +1381,7 @@ func (pl *dynamicResources) isSchedulableAfterPodSchedulingContextChange(logger
 	if oldPodScheduling != nil &&
 		!apiequality.Semantic.DeepEqual(&oldPodScheduling.Spec, &podScheduling.Spec) &&
 		apiequality.Semantic.DeepEqual(&oldPodScheduling.Status, &podScheduling.Status) {
-		logger.V(5).Info("PodSchedulingContext has only the scheduler spec changes, ignore the update", "pod", klog.KObj(pod))
+		logger.V(5).Info("PodSchedulingContext has only the scheduler spec changes, ignore the update", "pod", klog.KObj(pod), "reason", "skipping because only the spec changed")
 		return framework.QueueSkip, nil
 	}
 
@@ -596,11 +1390,11 @@ func (pl *dynamicResources) isSchedulableAfterPodSchedulingContextChange(logger
 	// to handle it and thus return Queue. This will cause the
 	// scheduler to treat the event as if no event hint callback had been provided.
 	// Developers who want to investigate this can enable a diff at log level 6.
-	if loggerV := logger.V(6); loggerV.Enabled() {
-		loggerV.Info("PodSchedulingContext for pod with unknown changes, maybe schedule", "pod", klog.KObj(pod), "podSchedulingDiff", cmp.Diff(oldPodScheduling, podScheduling))
-	} else {
-		logger.V(5).Info("PodSchedulingContext for pod with unknown changes, maybe schedule", "pod", klog.KObj(pod))
+	values := []any{"pod", klog.KObj(pod), "reason", "queueing because of unknown changes"}
+	if logger.V(6).Enabled() {
+		values = append(values, "podSchedulingDiff", cmp.Diff(oldPodScheduling, podScheduling))
 	}
+	logger.V(5).Info("PodSchedulingContext for pod with unknown changes, maybe schedule", values...)
 	return framework.Queue, nil
 
 }
@@ -629,6 +1423,18 @@ func (pl *dynamicResources) podResourceClaims(pod *v1.Pod) ([]*resourceapi.Resou
 	return claims, nil
 }
 
+// podClaimName returns the name of the entry in pod.spec.resourceClaims
+// which references the claim.
+func podClaimName(pod *v1.Pod, claim *resourceapi.ResourceClaim) string {
+	for _, resource := range pod.Spec.ResourceClaims {
+		claimName, _, err := resourceclaim.Name(pod, &resource)
+		if err == nil && claimName != nil && *claimName == claim.Name {
+			return resource.Name
+		}
+	}
+	return ""
+}
+
 // foreachPodResourceClaim checks that each ResourceClaim for the pod exists.
 // It calls an optional handler for those claims that it finds.
 func (pl *dynamicResources) foreachPodResourceClaim(pod *v1.Pod, cb func(podResourceName string, claim *resourceapi.ResourceClaim)) error {
@@ -653,10 +1459,17 @@ func (pl *dynamicResources) foreachPodResourceClaim(pod *v1.Pod, cb func(podReso
 			return fmt.Errorf("unexpected object type %T for assumed object %s/%s", obj, pod.Namespace, *claimName)
 		}
 
-		if claim.DeletionTimestamp != nil {
+		// A claim which is being deleted is only usable if it is
+		// allocated and a finalizer keeps it alive, for example
+		// because it is still reserved for some other pod. PreBind
+		// then decides whether the pod may use it.
+		if claim.DeletionTimestamp != nil && (claim.Status.Allocation == nil || len(claim.Finalizers) == 0) {
 			return fmt.Errorf("resourceclaim %q is being deleted", claim.Name)
 		}
 
+		// Only claims generated from a template must be owned by
+		// the pod. A claim referenced by name is created by the
+		// user and may be owned by anything, or shared by pods.
 		if mustCheckOwner {
 			if err := resourceclaim.IsForPod(pod, claim); err != nil {
 				return err
@@ -669,11 +1482,68 @@ func (pl *dynamicResources) foreachPodResourceClaim(pod *v1.Pod, cb func(podReso
 	return nil
 }
 
+// hasClaims is checked by all extension points after PreFilter. For a pod
+// without claims, PreFilter returns Skip and they have nothing to do. Some of
+// them still get called, or might get called when plugins are misconfigured.
+// They must then return immediately without looking at the cycle state or
+// the caches.
+func hasClaims(pod *v1.Pod) bool {
+	return len(pod.Spec.ResourceClaims) > 0
+}
+
+// allocatorFeatures returns the feature gates which the allocator needs to know about.
+func (pl *dynamicResources) allocatorFeatures() structured.Features {
+	return structured.Features{
+		ConsumableCapacity: pl.consumableCapacityEnabled,
+		AttributeSelectors: pl.attributeSelectorsEnabled,
+		DeviceTaints:       pl.deviceTaintsEnabled,
+		NodeLocalOnly:      pl.nodeLocalOnlyEnabled,
+	}
+}
+
+// podLabels returns the labels which CEL selectors see as pod.labels.
+// Without the DRAPodLabelSelectors feature, selectors which were stored
+// while it was enabled see a pod without labels.
+func (pl *dynamicResources) podLabels(pod *v1.Pod) map[string]string {
+	if !pl.podLabelSelectorsEnabled {
+		return nil
+	}
+	return pod.Labels
+}
+
 // PreFilter invoked at the prefilter extension point to check if pod has all
 // immediate claims bound. UnschedulableAndUnresolvable is returned if
 // the pod cannot be scheduled at the moment on any node.
 func (pl *dynamicResources) PreFilter(ctx context.Context, state *framework.CycleState, pod *v1.Pod) (*framework.PreFilterResult, *framework.Status) {
 	if !pl.enabled {
+		return pl.preFilter(ctx, state, pod)
+	}
+	pl.startFinalizerCheck()
+
+	// Claim events which arrive from now on might not be seen by this
+	// attempt, so they must not be coalesced with earlier ones.
+	pl.claimEvents.forget(pod)
+
+	if !hasClaims(pod) {
+		return pl.preFilter(ctx, state, pod)
+	}
+	start := time.Now()
+	result, status := pl.preFilter(ctx, state, pod)
+	if s, err := getStateData(state); err == nil {
+		s.metricsDriver = s.driverLabel()
+		observeDuration(schedulermetrics.PreFilter, s, start)
+	}
+	return result, status
+}
+
+// preFilter implements PreFilter. Everything that it changes is in the
+// cycle state, which makes it usable by DescribePod.
+func (pl *dynamicResources) preFilter(ctx context.Context, state *framework.CycleState, pod *v1.Pod) (*framework.PreFilterResult, *framework.Status) {
+	if !pl.enabled {
+		if pl.disabledInProfile && hasClaims(pod) {
+			// Scheduling the pod without its devices would be wrong.
+			return nil, statusUnschedulable(klog.FromContext(ctx), "DRA disabled in this profile", "pod", klog.KObj(pod))
+		}
 		return nil, framework.NewStatus(framework.Skip)
 	}
 	logger := klog.FromContext(ctx)
@@ -683,7 +1553,17 @@ func (pl *dynamicResources) PreFilter(ctx context.Context, state *framework.Cycl
 	// observation for the other functions. This gets updated below
 	// if we get that far.
 	s := &stateData{}
-	state.Write(stateKey, s)
+	state.Write(StateKey, s)
+
+	if hasClaims(pod) {
+		allowed, err := pl.isNamespaceAllowed(pod.Namespace)
+		if err != nil {
+			return nil, statusError(logger, errorContext{pod: pod}.wrap(err))
+		}
+		if !allowed {
+			return nil, statusUnschedulable(logger, fmt.Sprintf("ResourceClaims are not allowed in namespace %s by the scheduler configuration", pod.Namespace), "pod", klog.KObj(pod))
+		}
+	}
 
 	claims, err := pl.podResourceClaims(pod)
 	if err != nil {
@@ -697,17 +1577,59 @@ func (pl *dynamicResources) PreFilter(ctx context.Context, state *framework.Cycl
 		return nil, framework.NewStatus(framework.Skip)
 	}
 
+	s.filterReasons = &FilterReasons{}
+	state.Write(FilterReasonsStateKey, s.filterReasons)
+
+	if pl.claimTemplateLister != nil {
+		message, err := pl.checkClaimTemplates(logger, pod, claims)
+		if err != nil {
+			return nil, statusError(logger, errorContext{pod: pod}.wrap(err))
+		}
+		if message != "" {
+			return nil, statusUnschedulable(logger, message, "pod", klog.KObj(pod))
+		}
+	}
+
+	// The current attempt is not counted because it hasn't failed yet.
+	if failedAttempts := framework.GetPodSchedulingAttempts(state) - 1; pl.escalationAttempts > 0 && failedAttempts >= pl.escalationAttempts {
+		logger.V(5).Info("Escalating because the pod failed to schedule repeatedly", "pod", klog.KObj(pod), "failedAttempts", failedAttempts)
+		s.escalated = true
+	}
+
+	if _, condition := podutil.GetPodCondition(&pod.Status, PodConditionResourceClaimsReady); condition != nil {
+		s.podCondition = condition.DeepCopy()
+	}
+
 	// Fetch PodSchedulingContext, it's going to be needed when checking claims.
 	// Doesn't do anything when DRAControlPlaneController is disabled.
 	if err := s.podSchedulingState.init(ctx, pod, pl.podSchedulingContextLister); err != nil {
-		return nil, statusError(logger, err)
+		return nil, statusError(logger, errorContext{pod: pod}.wrap(err))
+	}
+
+	// Without the DRAReservedDeviceIndices feature, the annotation is
+	// ignored and the pod uses all devices of its claims.
+	var deviceIndices map[string][]int32
+	if pl.deviceIndicesEnabled {
+		deviceIndices, err = podDeviceIndices(pod)
+		if err != nil {
+			return nil, statusUnschedulable(logger, err.Error())
+		}
 	}
 
 	// All claims which the scheduler needs to allocate itself.
 	allocateClaims := make([]*resourceapi.ResourceClaim, 0, len(claims))
 
+	// All slices in the cluster, listed only when needed for checking
+	// request selectors.
+	var allSlices []*resourceapi.ResourceSlice
+
+	// The node to which some claim is pinned, if any.
+	var pinnedNode string
+
 	s.informationsForClaim = make([]informationForClaim, len(claims))
 	for index, claim := range claims {
+		s.informationsForClaim[index].podClaimName = podClaimName(pod, claim)
+		s.informationsForClaim[index].deviceIndices = deviceIndices[s.informationsForClaim[index].podClaimName]
 		if claim.Spec.Controller != "" &&
 			!pl.controlPlaneControllerEnabled {
 			// This keeps the pod as unschedulable until the
@@ -720,6 +1642,7 @@ func (pl *dynamicResources) PreFilter(ctx context.Context, state *framework.Cycl
 
 		if claim.Status.DeallocationRequested {
 			// This will get resolved by the resource driver.
+			s.unschedulableCondition = waitingForDeallocationCondition(claim)
 			return nil, statusUnschedulable(logger, "resourceclaim must be reallocated", "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim))
 		}
 		if claim.Status.Allocation != nil &&
@@ -734,7 +1657,7 @@ func (pl *dynamicResources) PreFilter(ctx context.Context, state *framework.Cycl
 			if claim.Status.Allocation.NodeSelector != nil {
 				nodeSelector, err := nodeaffinity.NewNodeSelector(claim.Status.Allocation.NodeSelector)
 				if err != nil {
-					return nil, statusError(logger, err)
+					return nil, statusError(logger, errorContext{pod: pod, claim: claim}.wrap(err))
 				}
 				s.informationsForClaim[index].availableOnNodes = map[string]*nodeaffinity.NodeSelector{"": nodeSelector}
 			}
@@ -747,24 +1670,42 @@ func (pl *dynamicResources) PreFilter(ctx context.Context, state *framework.Cycl
 				// Allocation in flight? Better wait for that
 				// to finish, see inFlightAllocations
 				// documentation for details.
-				if _, found := pl.inFlightAllocations.Load(claim.UID); found {
-					return nil, statusUnschedulable(logger, fmt.Sprintf("resource claim %s is in the process of being allocated", klog.KObj(claim)))
+				if _, found := pl.inFlightAllocations.load(claim.UID); found {
+					return nil, statusUnschedulable(logger, errorContext{claim: claim}.reason("allocation in progress"), "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim))
+				}
+
+				nodeName, reason, err := pl.pinnedNode(claim)
+				if err != nil {
+					return nil, statusError(logger, errorContext{pod: pod, claim: claim}.wrap(err))
+				}
+				if reason != "" {
+					return nil, statusUnschedulable(logger, reason, "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim))
+				}
+				if nodeName != "" {
+					if pinnedNode != "" && pinnedNode != nodeName {
+						return nil, statusUnschedulable(logger, fmt.Sprintf("resourceclaims pinned to different nodes %s and %s", pinnedNode, nodeName), "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim))
+					}
+					pinnedNode = nodeName
+					s.informationsForClaim[index].pinnedNode = nodeName
 				}
 			} else {
-				s.informationsForClaim[index].status = statusForClaim(s.podSchedulingState.schedulingCtx, pod.Spec.ResourceClaims[index].Name)
+				s.informationsForClaim[index].status = s.podSchedulingState.statusForClaim(pod.Spec.ResourceClaims[index].Name)
 			}
 
 			// Check all requests and device classes. If a class
 			// does not exist, scheduling cannot proceed, no matter
 			// how the claim is being allocated.
 			//
-			// When using a control plane controller, a class might
-			// have a node filter. This is useful for trimming the
+			// A class might have a node filter. When using a control
+			// plane controller, this is useful for trimming the
 			// initial set of potential nodes before we ask the
 			// driver(s) for information about the specific pod.
-			for _, request := range claim.Spec.Devices.Requests {
+			// When the scheduler allocates, it restricts where
+			// devices from the class may be used, for example to
+			// certain node pools.
+			for requestIndex, request := range claim.Spec.Devices.Requests {
 				if request.DeviceClassName == "" {
-					return nil, statusError(logger, fmt.Errorf("request %s: unsupported request type", request.Name))
+					return nil, statusError(logger, errorContext{pod: pod, claim: claim, request: request.Name}.wrap(errors.New("unsupported request type")))
 				}
 
 				class, err := pl.classLister.Get(request.DeviceClassName)
@@ -773,21 +1714,94 @@ func (pl *dynamicResources) PreFilter(ctx context.Context, state *framework.Cycl
 					if apierrors.IsNotFound(err) {
 						// Here we mark the pod as "unschedulable", so it'll sleep in
 						// the unscheduleable queue until a DeviceClass event occurs.
-						return nil, statusUnschedulable(logger, fmt.Sprintf("request %s: device class %s does not exist", request.Name, request.DeviceClassName))
+						return nil, statusUnschedulable(logger, errorContext{claim: claim, request: request.Name, class: request.DeviceClassName}.reason("does not exist"), "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim))
 					}
 					// Other error, retry with backoff.
-					return nil, statusError(logger, fmt.Errorf("request %s: look up device class: %w", request.Name, err))
+					return nil, statusError(logger, errorContext{pod: pod, claim: claim, request: request.Name, class: request.DeviceClassName}.wrap(fmt.Errorf("look up device class: %w", err)))
+				}
+				if class.DeletionTimestamp != nil && !pl.allowTerminatingClasses {
+					// A new allocation would be left with a class that
+					// is about to disappear. Recreating the class is
+					// a DeviceClass event.
+					return nil, statusUnschedulable(logger, errorContext{claim: claim, request: request.Name, class: class.Name}.reason("is being deleted"), "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim), "request", request.Name)
 				}
-				if class.Spec.SuitableNodes != nil && !structuredParameters {
+				if class.Spec.SuitableNodes != nil {
 					selector, err := nodeaffinity.NewNodeSelector(class.Spec.SuitableNodes)
 					if err != nil {
-						return nil, statusError(logger, err)
+						return nil, statusError(logger, errorContext{pod: pod, claim: claim, request: request.Name, class: class.Name}.wrap(err))
 					}
 					if s.informationsForClaim[index].availableOnNodes == nil {
 						s.informationsForClaim[index].availableOnNodes = make(map[string]*nodeaffinity.NodeSelector)
 					}
 					s.informationsForClaim[index].availableOnNodes[class.Name] = selector
 				}
+				if structuredParameters && !pl.skipClassDriverCheck {
+					// A class which selects attributes of a driver
+					// that publishes nothing is most likely
+					// misconfigured. Reporting that is more helpful
+					// than reporting that no devices were found.
+					if err := pl.checkSlices(); err != nil {
+						return nil, statusError(logger, errorContext{pod: pod}.wrap(err))
+					}
+					driver, err := pl.unknownClassDriver(class)
+					if err != nil {
+						return nil, statusError(logger, errorContext{pod: pod, claim: claim, request: request.Name}.wrap(err))
+					}
+					if driver != "" {
+						reason := fmt.Sprintf("request %s: device class %s selects attributes of driver %s which publishes no resources (typo?)", request.Name, class.Name, driver)
+						s.unschedulableCondition = &v1.PodCondition{
+							Type:    PodConditionResourceClaimsReady,
+							Status:  v1.ConditionFalse,
+							Reason:  PodReasonNoDevicesAvailable,
+							Message: fmt.Sprintf("claim %s: %s", claim.Name, reason),
+						}
+						return nil, statusUnschedulable(logger, reason, "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim))
+					}
+				}
+				if structuredParameters && request.AllocationMode == resourceapi.DeviceAllocationModeExactCount {
+					// Running the allocator for each node is pointless
+					// if there aren't enough devices in the entire cluster.
+					if err := pl.checkSlices(); err != nil {
+						return nil, statusError(logger, errorContext{pod: pod}.wrap(err))
+					}
+					numDevices, err := pl.classDevices(ctx, class, pl.podLabels(pod))
+					if err != nil {
+						return nil, statusError(logger, errorContext{pod: pod, claim: claim, request: request.Name}.wrap(err))
+					}
+					if request.Count > int64(numDevices) {
+						reason := fmt.Sprintf("requested %d devices of class %s but only %d exist in the cluster", request.Count, class.Name, numDevices)
+						s.unschedulableCondition = &v1.PodCondition{
+							Type:    PodConditionResourceClaimsReady,
+							Status:  v1.ConditionFalse,
+							Reason:  PodReasonNoDevicesAvailable,
+							Message: fmt.Sprintf("claim %s: %s", claim.Name, reason),
+						}
+						return nil, statusUnschedulable(logger, reason, "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim))
+					}
+
+					// The same applies when none of the devices of
+					// the class match the request selectors, for
+					// example because they ask for an attribute
+					// that no driver provides.
+					if len(request.Selectors) > 0 {
+						if allSlices == nil {
+							allSlices, err = pl.sliceLister.List(labels.Everything())
+							if err != nil {
+								return nil, statusError(logger, errorContext{pod: pod}.wrap(fmt.Errorf("list resource slices: %w", err)))
+							}
+						}
+						if !structured.RequestDevicesExist(ctx, class, claim, requestIndex, pl.podLabels(pod), allSlices) {
+							reason := "no resource slices can satisfy claim"
+							s.unschedulableCondition = &v1.PodCondition{
+								Type:    PodConditionResourceClaimsReady,
+								Status:  v1.ConditionFalse,
+								Reason:  PodReasonNoDevicesAvailable,
+								Message: errorContext{claim: claim, request: request.Name}.reason(reason),
+							}
+							return nil, statusUnschedulable(logger, reason, "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim), "request", request.Name)
+						}
+					}
+				}
 			}
 		}
 	}
@@ -800,37 +1814,78 @@ func (pl *dynamicResources) PreFilter(ctx context.Context, state *framework.Cycl
 		// as changes are observed.
 		//
 		// But that would cause problems for using the plugin in the
-		// Cluster Autoscaler. If this step here turns out to be
-		// expensive, we may have to maintain and update state more
-		// persistently.
+		// Cluster Autoscaler. Only the devices which are in use are
+		// maintained persistently, by pl.deviceTracker, because
+		// gathering them is expensive when there are many allocated
+		// claims.
 		//
 		// Claims are treated as "allocated" if they are in the assume cache
 		// or currently their allocation is in-flight.
-		allocator, err := structured.NewAllocator(ctx, allocateClaims, &claimListerForAssumeCache{assumeCache: pl.claimAssumeCache, inFlightAllocations: &pl.inFlightAllocations}, pl.classLister, pl.sliceLister)
+		allocator, err := structured.NewAllocator(ctx, pl.allocatorFeatures(), allocateClaims, &claimListerForAssumeCache{assumeCache: pl.claimAssumeCache, inFlightAllocations: &pl.inFlightAllocations, deviceTracker: pl.deviceTracker}, pl.classLister, pl.sliceListerForAllocation())
+		if err != nil {
+			return nil, statusError(logger, errorContext{pod: pod}.wrap(err))
+		}
+		allocator, err = pl.withPodSettings(allocator, pod)
 		if err != nil {
-			return nil, statusError(logger, err)
+			return nil, statusUnschedulable(logger, err.Error())
 		}
-		s.allocator = allocator
+		s.allocator = allocator.WithHints(GetAllocationHints(state).structuredHints())
 		s.nodeAllocations = make(map[string][]*resourceapi.AllocationResult)
+		s.targetNode = targetNode(pod)
+		staleDrivers, err := pl.staleDrivers()
+		if err != nil {
+			return nil, statusError(logger, errorContext{pod: pod}.wrap(err))
+		}
+		s.staleDrivers = staleDrivers
+		s.readyDrivers = pl.readyDrivers()
 	}
 
 	s.claims = claims
+	if pinnedNode != "" {
+		return &framework.PreFilterResult{NodeNames: sets.New(pinnedNode)}, nil
+	}
 	return nil, nil
 }
 
+// withPodSettings returns a copy of the allocator with the settings that
+// Filter uses when allocating devices for the pod.
+func (pl *dynamicResources) withPodSettings(allocator *structured.Allocator, pod *v1.Pod) (*structured.Allocator, error) {
+	antiAffinity, err := pl.deviceAntiAffinity(pod)
+	if err != nil {
+		return nil, err
+	}
+	return allocator.WithAntiAffinity(antiAffinity).WithTolerations(pod.Spec.Tolerations).WithPodLabels(pl.podLabels(pod)).WithSelectionPolicy(pl.selectionPolicy).WithMissingAttributeBehavior(pl.missingAttributeBehavior).WithDeviceScorers(pl.deviceScorers).WithAllowedFunctionGroups(pl.celFunctionGroups), nil
+}
+
 type claimListerForAssumeCache struct {
 	assumeCache         *assumecache.AssumeCache
-	inFlightAllocations *sync.Map
+	inFlightAllocations *inFlightAllocations
+	// deviceTracker is optional.
+	deviceTracker *allocatedDevicesTracker
+}
+
+var _ structured.AllocatedDevicesLister = &claimListerForAssumeCache{}
+
+// ListAllocatedDevices implements [structured.AllocatedDevicesLister].
+func (cl *claimListerForAssumeCache) ListAllocatedDevices() *structured.AllocatedDevices {
+	if cl.deviceTracker == nil {
+		return nil
+	}
+	return cl.deviceTracker.snapshot()
 }
 
 func (cl *claimListerForAssumeCache) ListAllAllocated() ([]*resourceapi.ResourceClaim, error) {
 	// Probably not worth adding an index for?
+	//
+	// Who the claims are reserved for doesn't matter here: an allocated
+	// claim blocks its devices even if it is only reserved for consumers
+	// which are not pods, or for no consumer at all.
 	objs := cl.assumeCache.List(nil)
 	allocated := make([]*resourceapi.ResourceClaim, 0, len(objs))
 	for _, obj := range objs {
 		claim := obj.(*resourceapi.ResourceClaim)
-		if obj, ok := cl.inFlightAllocations.Load(claim.UID); ok {
-			claim = obj.(*resourceapi.ResourceClaim)
+		if inFlightClaim, ok := cl.inFlightAllocations.loadClaim(claim.UID); ok {
+			claim = inFlightClaim
 		}
 		if claim.Status.Allocation != nil {
 			allocated = append(allocated, claim)
@@ -839,13 +1894,153 @@ func (cl *claimListerForAssumeCache) ListAllAllocated() ([]*resourceapi.Resource
 	return allocated, nil
 }
 
+// AllocatableDevices returns the number of devices from the device class
+// which could currently be allocated for the pod on the node. Devices which
+// are allocated or for which allocation is in flight are not counted. This
+// uses the same information and allocator settings as Filter, so devices in
+// stale slices or slices under maintenance, devices with taints that the pod
+// does not tolerate and devices of drivers which are not ready on the node
+// are not counted either. A class which is not suitable for the node has no
+// allocatable devices there.
+func (pl *dynamicResources) AllocatableDevices(ctx context.Context, pod *v1.Pod, node *v1.Node, className string) (int, error) {
+	if !pl.enabled {
+		return 0, nil
+	}
+	class, err := pl.classLister.Get(className)
+	if err != nil {
+		return 0, fmt.Errorf("look up device class: %w", err)
+	}
+	if class.Spec.SuitableNodes != nil {
+		selector, err := nodeaffinity.NewNodeSelector(class.Spec.SuitableNodes)
+		if err != nil {
+			return 0, err
+		}
+		if !selector.Match(node) {
+			return 0, nil
+		}
+	}
+	if err := pl.checkSlices(); err != nil {
+		return 0, err
+	}
+	sliceLister := pl.sliceListerForAllocation()
+	if readyDrivers := pl.readyDrivers(); readyDrivers != nil {
+		sliceLister = &readyDriverSliceLister{ResourceSliceLister: sliceLister, ready: readyDrivers[node.Name]}
+	}
+	allocator, err := structured.NewAllocator(ctx, pl.allocatorFeatures(), nil, &claimListerForAssumeCache{assumeCache: pl.claimAssumeCache, inFlightAllocations: &pl.inFlightAllocations, deviceTracker: pl.deviceTracker}, pl.classLister, sliceLister)
+	if err != nil {
+		return 0, err
+	}
+	allocator, err = pl.withPodSettings(allocator, pod)
+	if err != nil {
+		return 0, err
+	}
+	return allocator.AllocatableDevices(ctx, node, class)
+}
+
 // PreFilterExtensions returns prefilter extensions, pod add and remove.
 func (pl *dynamicResources) PreFilterExtensions() framework.PreFilterExtensions {
+	return pl
+}
+
+// AddPod is called by the framework while trying to evaluate the impact
+// of adding podInfoToAdd to the node while scheduling podToSchedule.
+// Only pods which were removed before matter because their claims
+// block devices again.
+func (pl *dynamicResources) AddPod(ctx context.Context, cs *framework.CycleState, podToSchedule *v1.Pod, podInfoToAdd *framework.PodInfo, nodeInfo *framework.NodeInfo) *framework.Status {
+	if !pl.enabled || !hasClaims(podToSchedule) || !hasClaims(podInfoToAdd.Pod) {
+		return nil
+	}
+	if removed := getRemovedPods(cs); removed != nil {
+		removed.Delete(podInfoToAdd.Pod.UID)
+	}
 	return nil
 }
 
+// RemovePod is called by the framework while trying to evaluate the impact
+// of removing podInfoToRemove from the node while scheduling podToSchedule.
+// Filter then treats devices which are allocated for claims that are
+// only reserved for removed pods as available.
+func (pl *dynamicResources) RemovePod(ctx context.Context, cs *framework.CycleState, podToSchedule *v1.Pod, podInfoToRemove *framework.PodInfo, nodeInfo *framework.NodeInfo) *framework.Status {
+	if !pl.enabled || !hasClaims(podToSchedule) || !hasClaims(podInfoToRemove.Pod) {
+		return nil
+	}
+	removed := getRemovedPods(cs)
+	if removed == nil {
+		removed = sets.New[types.UID]()
+		cs.Write(removedPodsKey, &removedPods{pods: removed})
+	}
+	removed.Insert(podInfoToRemove.Pod.UID)
+	return nil
+}
+
+// getRemovedPods returns the UIDs of the pods removed by RemovePod, nil if none.
+func getRemovedPods(cs *framework.CycleState) sets.Set[types.UID] {
+	state, err := cs.Read(removedPodsKey)
+	if err != nil {
+		return nil
+	}
+	removed, ok := state.(*removedPods)
+	if !ok {
+		return nil
+	}
+	return removed.pods
+}
+
+// filterInputs hashes the inputs for the allocator which may change
+// during a scheduling cycle: the claims for which allocation is in flight
+// and the pods which were removed by RemovePod. Allocated claims in the
+// assume cache also change, but only through the in-flight allocations
+// of other pods or through events which cause another scheduling attempt
+// anyway.
+func (pl *dynamicResources) filterInputs(removed sets.Set[types.UID]) uint64 {
+	var inFlight []string
+	pl.inFlightAllocations.forEach(func(allocation *inFlightAllocation) bool {
+		inFlight = append(inFlight, string(allocation.claim.UID))
+		return true
+	})
+	sort.Strings(inFlight)
+
+	hash := fnv.New64a()
+	for _, uid := range inFlight {
+		_, _ = hash.Write([]byte(uid))
+		_, _ = hash.Write([]byte{0})
+	}
+	// Separates the two lists.
+	_, _ = hash.Write([]byte{1})
+	for _, uid := range sets.List(removed) {
+		_, _ = hash.Write([]byte(uid))
+		_, _ = hash.Write([]byte{0})
+	}
+	return hash.Sum64()
+}
+
+// claimListerWithoutPods hides claims which are only reserved for pods
+// that were removed by RemovePod.
+type claimListerWithoutPods struct {
+	structured.ClaimLister
+	removed sets.Set[types.UID]
+}
+
+func (cl *claimListerWithoutPods) ListAllAllocated() ([]*resourceapi.ResourceClaim, error) {
+	claims, err := cl.ClaimLister.ListAllAllocated()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(claims, func(claim *resourceapi.ResourceClaim) bool {
+		if len(claim.Status.ReservedFor) == 0 {
+			return false
+		}
+		for _, consumer := range claim.Status.ReservedFor {
+			if !resourceclaim.IsPodReference(consumer) || !cl.removed.Has(consumer.UID) {
+				return false
+			}
+		}
+		return true
+	}), nil
+}
+
 func getStateData(cs *framework.CycleState) (*stateData, error) {
-	state, err := cs.Read(stateKey)
+	state, err := cs.Read(StateKey)
 	if err != nil {
 		return nil, err
 	}
@@ -866,19 +2061,37 @@ func getStateData(cs *framework.CycleState) (*stateData, error) {
 // For claims that are unbound, it checks whether the claim might get allocated
 // for the node.
 func (pl *dynamicResources) Filter(ctx context.Context, cs *framework.CycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo) *framework.Status {
-	if !pl.enabled {
+	if !pl.enabled || !hasClaims(pod) {
 		return nil
 	}
 	state, err := getStateData(cs)
 	if err != nil {
-		return statusError(klog.FromContext(ctx), err)
+		return statusError(klog.FromContext(ctx), errorContext{pod: pod}.wrap(err))
 	}
 	if len(state.claims) == 0 {
 		return nil
 	}
+	defer observeDuration(schedulermetrics.Filter, state, time.Now())
 
+	status := pl.filter(ctx, cs, state, pod, nodeInfo, &pl.podFilterCache)
+	if code := status.Code(); !state.escalated && (code == framework.Unschedulable || code == framework.UnschedulableAndUnresolvable) {
+		pl.checkMissingSlices(klog.FromContext(ctx), state, pod, nodeInfo.Node())
+	}
+	return status
+}
+
+// filter implements Filter for a pod with claims. Everything that it
+// changes is in the cycle state and, if not nil, in the pod filter cache.
+// DescribePod calls it without that cache.
+func (pl *dynamicResources) filter(ctx context.Context, cs *framework.CycleState, state *stateData, pod *v1.Pod, nodeInfo *framework.NodeInfo, podFilterCache *podFilterCache) (status *framework.Status) {
 	logger := klog.FromContext(ctx)
 	node := nodeInfo.Node()
+	defer func() {
+		if status.Code() == framework.Unschedulable {
+			status = withInsufficientNodeResources(status, pod, nodeInfo)
+		}
+		state.filterReasons.record(node.Name, status)
+	}()
 
 	var unavailableClaims []int
 	for index, claim := range state.claims {
@@ -900,17 +2113,23 @@ func (pl *dynamicResources) Filter(ctx context.Context, cs *framework.CycleState
 			return statusUnschedulable(logger, "resourceclaim must be reallocated", "pod", klog.KObj(pod), "node", klog.KObj(node), "resourceclaim", klog.KObj(claim))
 		}
 
+		if pinnedNode := state.informationsForClaim[index].pinnedNode; pinnedNode != "" && pinnedNode != node.Name {
+			return statusUnschedulable(logger, fmt.Sprintf("claim pinned to node %s", pinnedNode), "pod", klog.KObj(pod), "node", klog.KObj(node), "resourceclaim", klog.KObj(claim))
+		}
+
 		for className, nodeSelector := range state.informationsForClaim[index].availableOnNodes {
 			if !nodeSelector.Match(node) {
 				return statusUnschedulable(logger, "excluded by device class node filter", "pod", klog.KObj(pod), "node", klog.KObj(node), "deviceclass", klog.KRef("", className))
 			}
 		}
 
-		// Use information from control plane controller?
+		// Use information from control plane controller? Rejecting the
+		// nodes here instead of in PreBind keeps the pod from getting
+		// stuck on a node which the driver cannot use.
 		if status := state.informationsForClaim[index].status; status != nil {
 			for _, unsuitableNode := range status.UnsuitableNodes {
 				if node.Name == unsuitableNode {
-					return statusUnschedulable(logger, "resourceclaim cannot be allocated for the node (unsuitable)", "pod", klog.KObj(pod), "node", klog.KObj(node), "resourceclaim", klog.KObj(claim), "unsuitablenodes", status.UnsuitableNodes)
+					return statusUnschedulable(logger, "resource driver reported node as unsuitable", "pod", klog.KObj(pod), "node", klog.KObj(node), "resourceclaim", klog.KObj(claim), "unsuitablenodes", status.UnsuitableNodes)
 				}
 			}
 		}
@@ -919,28 +2138,104 @@ func (pl *dynamicResources) Filter(ctx context.Context, cs *framework.CycleState
 	// Use allocator to check the node and cache the result in case that the node is picked.
 	var allocations []*resourceapi.AllocationResult
 	if state.allocator != nil {
+		if state.targetNode != "" && state.targetNode != node.Name {
+			return statusUnschedulable(logger, fmt.Sprintf("pod can only run on node %s", state.targetNode), "pod", klog.KObj(pod), "node", klog.KObj(node))
+		}
+		if err := pl.checkSlices(); err != nil {
+			return statusError(logger, errorContext{pod: pod, nodeName: node.Name}.wrap(err))
+		}
+
 		allocCtx := ctx
 		if loggerV := logger.V(5); loggerV.Enabled() {
 			allocCtx = klog.NewContext(allocCtx, klog.LoggerWithValues(logger, "node", klog.KObj(node)))
 		}
 
-		a, err := state.allocator.Allocate(allocCtx, node)
+		removed := getRemovedPods(cs)
+		key := filterCacheKey{nodeName: node.Name, inputs: pl.filterInputs(removed)}
+		state.mutex.Lock()
+		entry, cached := state.filterCache[key]
+		state.mutex.Unlock()
+		// Results of simulations with removed pods are only valid
+		// for the current cycle.
+		podKey := podFilterCacheKey{podUID: pod.UID, nodeName: node.Name}
+		var podInputs string
+		var generation int64
+		usePodCache := podFilterCache != nil && !cached && removed.Len() == 0 && pl.usePodFilterCache(state)
+		if usePodCache {
+			podInputs = pl.podFilterInputs(state, pod, node)
+			entry, generation, cached = podFilterCache.lookup(podKey, podInputs)
+		}
+		if !cached {
+			allocator := state.allocator
+			if removed.Len() > 0 {
+				// The claim lister is fixed when creating an allocator,
+				// but creating one is cheap.
+				claimLister := &claimListerWithoutPods{
+					ClaimLister: &claimListerForAssumeCache{assumeCache: pl.claimAssumeCache, inFlightAllocations: &pl.inFlightAllocations},
+					removed:     removed,
+				}
+				var err error
+				allocator, err = structured.NewAllocator(ctx, pl.allocatorFeatures(), state.allocator.ClaimsToAllocate(), claimLister, pl.classLister, pl.sliceListerForAllocation())
+				if err != nil {
+					return statusError(logger, errorContext{pod: pod, nodeName: node.Name}.wrap(err))
+				}
+				allocator = allocator.WithAntiAffinity(state.allocator.AntiAffinity()).WithTolerations(state.allocator.Tolerations()).WithPodLabels(state.allocator.PodLabels()).WithSelectionPolicy(state.allocator.SelectionPolicy()).WithMissingAttributeBehavior(state.allocator.MissingAttributeBehavior()).WithHints(state.allocator.Hints()).WithDeviceScorers(state.allocator.DeviceScorers()).WithAllowedFunctionGroups(state.allocator.AllowedFunctionGroups())
+			}
+			entry.allocations, entry.unsatisfiable, entry.err = allocator.AllocateWithReason(allocCtx, node)
+			if usePodCache {
+				podFilterCache.store(podKey, podInputs, generation, entry)
+			}
+		} else {
+			logger.V(5).Info("reusing allocation result", "pod", klog.KObj(pod), "node", klog.KObj(node))
+		}
+		state.mutex.Lock()
+		if state.filterCache == nil {
+			state.filterCache = make(map[filterCacheKey]filterCacheEntry)
+		}
+		state.filterCache[key] = entry
+		state.mutex.Unlock()
+
+		a, err := entry.allocations, errorContext{}.wrap(entry.err)
+		if removed.Len() == 0 {
+			state.mutex.Lock()
+			state.recordFilterResult(node.Name, err)
+			state.mutex.Unlock()
+		}
 		if err != nil {
-			// This should only fail if there is something wrong with the claim or class.
-			// Return an error to abort scheduling of it.
-			//
-			// This will cause retries. It would be slightly nicer to mark it as unschedulable
-			// *and* abort scheduling. Then only cluster event for updating the claim or class
-			// with the broken CEL expression would trigger rescheduling.
-			//
-			// But we cannot do both. As this shouldn't occur often, aborting like this is
-			// better than the more complicated alternative (return Unschedulable here, remember
-			// the error, then later raise it again later if needed).
-			return statusError(logger, err, "pod", klog.KObj(pod), "node", klog.KObj(node), "resourceclaims", klog.KObjSlice(state.allocator.ClaimsToAllocate()))
+			// This should only fail if there is something wrong with the claim or class,
+			// but the error might also depend on the devices of the node, for
+			// example when a CEL expression accesses an attribute which only
+			// some devices have. Rejecting just this node allows scheduling on
+			// nodes where the allocator succeeded. If it failed the same way
+			// everywhere, PostFilter keeps the pod pending with the error as
+			// reason.
+			return statusUnschedulable(logger, err.Error(), "pod", klog.KObj(pod), "node", klog.KObj(node), "resourceclaims", klog.KObjSlice(state.allocator.ClaimsToAllocate()))
 		}
 		// Check for exact length just to be sure. In practice this is all-or-nothing.
 		if len(a) != len(state.allocator.ClaimsToAllocate()) {
-			return statusUnschedulable(logger, "cannot allocate all claims", "pod", klog.KObj(pod), "node", klog.KObj(node), "resourceclaims", klog.KObjSlice(state.allocator.ClaimsToAllocate()))
+			// Devices in stale slices were ignored. That might be why.
+			// Once escalated, the pod only gets the summary because
+			// it has been told often enough already.
+			if !state.escalated {
+				if drivers := state.staleDrivers[node.Name]; len(drivers) > 0 {
+					return statusResourcesExhausted(logger, fmt.Sprintf("resource slices stale for driver %s", strings.Join(drivers, ", ")), "pod", klog.KObj(pod), "node", klog.KObj(node))
+				}
+				if entry.unsatisfiable != nil {
+					// The hint is merely informational, so failing to
+					// determine it is not an error.
+					classes, err := pl.alternativeClasses(ctx, pod, node, entry.unsatisfiable)
+					if err != nil {
+						logger.V(4).Info("Checking other device classes failed", "pod", klog.KObj(pod), "node", klog.KObj(node), "err", err)
+					}
+					return statusResourcesExhausted(logger, fmt.Sprintf("%s: %s%s", ErrReasonCannotAllocate, entry.unsatisfiable, alternativeClassesHint(classes)), "pod", klog.KObj(pod), "node", klog.KObj(node), "resourceclaims", klog.KObjSlice(state.allocator.ClaimsToAllocate()))
+				}
+			}
+			return statusResourcesExhausted(logger, ErrReasonCannotAllocate, "pod", klog.KObj(pod), "node", klog.KObj(node), "resourceclaims", klog.KObjSlice(state.allocator.ClaimsToAllocate()))
+		}
+		// The kubelet plugins of the drivers must be able to prepare
+		// the devices, otherwise the pod gets stuck on the node.
+		if driver := unreadyDriver(state.readyDrivers, node.Name, a); driver != "" {
+			return statusResourcesExhausted(logger, fmt.Sprintf("DRA driver %s not ready on node", driver), "pod", klog.KObj(pod), "node", klog.KObj(node))
 		}
 		// Reserve uses this information.
 		allocations = a
@@ -963,10 +2258,15 @@ func (pl *dynamicResources) Filter(ctx context.Context, cs *framework.CycleState
 		for _, index := range unavailableClaims {
 			state.unavailableClaims.Insert(index)
 		}
+		if state.unavailableClaimsPerNode == nil {
+			state.unavailableClaimsPerNode = make(map[string]sets.Set[int])
+		}
+		state.unavailableClaimsPerNode[node.Name] = sets.New(unavailableClaims...)
 		return statusUnschedulable(logger, "resourceclaim not available on the node", "pod", klog.KObj(pod))
 	}
 
-	if state.allocator != nil {
+	// The result of a simulation with removed pods cannot be used by Reserve.
+	if state.allocator != nil && getRemovedPods(cs).Len() == 0 {
 		state.nodeAllocations[node.Name] = allocations
 	}
 
@@ -974,28 +2274,71 @@ func (pl *dynamicResources) Filter(ctx context.Context, cs *framework.CycleState
 }
 
 // PostFilter checks whether there are allocated claims that could get
-// deallocated to help get the Pod schedulable. If yes, it picks one and
-// requests its deallocation.  This only gets called when filtering found no
-// suitable node.
+// deallocated to help get the Pod schedulable. If yes, it requests the
+// deallocation of one claim from the smallest set of claims which makes some
+// node usable. This only gets called when filtering found no suitable node.
+// A pod which failed to schedule repeatedly gets all claims of that set
+// deallocated at once.
 func (pl *dynamicResources) PostFilter(ctx context.Context, cs *framework.CycleState, pod *v1.Pod, filteredNodeStatusMap framework.NodeToStatusMap) (*framework.PostFilterResult, *framework.Status) {
 	if !pl.enabled {
 		return nil, framework.NewStatus(framework.Unschedulable, "plugin disabled")
 	}
+	if !hasClaims(pod) {
+		return nil, framework.NewStatus(framework.Unschedulable, "no new claims to deallocate")
+	}
 	logger := klog.FromContext(ctx)
 	state, err := getStateData(cs)
 	if err != nil {
-		return nil, statusError(logger, err)
+		return nil, statusError(logger, errorContext{pod: pod}.wrap(err))
+	}
+	if state.unschedulableCondition != nil {
+		pl.setPodCondition(ctx, state, pod, state.unschedulableCondition)
 	}
 	if len(state.claims) == 0 {
 		return nil, framework.NewStatus(framework.Unschedulable, "no new claims to deallocate")
 	}
+	defer observeDuration(schedulermetrics.PostFilter, state, time.Now())
+	// The same error on all nodes cannot be fixed by deallocating
+	// claims. The pod has to stay pending until the claims or classes
+	// get fixed.
+	if err := state.claimWideFilterError(); err != nil {
+		return nil, statusError(logger, errorContext{pod: pod}.wrap(err))
+	}
+	pl.reportFilterErrors(ctx, state, pod)
+	if !pl.postFilterDeallocation {
+		// Allocation is managed by someone else.
+		return nil, framework.NewStatus(framework.Unschedulable, "deallocation of ResourceClaims is disabled")
+	}
 
-	// Iterating over a map is random. This is intentional here, we want to
-	// pick one claim randomly because there is no better heuristic.
-	for index := range state.unavailableClaims {
+	// Only claims from the smallest set which makes some node usable
+	// get deallocated, so those which are allocated for the right node
+	// are kept. Within that set, claims get picked in random order
+	// because there is no better heuristic.
+	//
+	// Once escalated, all claims of the set get deallocated in this
+	// attempt instead of one per attempt.
+	//
+	// Claims which are already reserved for the pod are kept if
+	// preferred. The pod then has to wait for their node. The same
+	// applies to claims whose allocation must be kept.
+	kept := pl.reservedClaimsToKeep(pod, state)
+	waiting := pl.claimsWaitingForNode(state)
+	deallocated, inProgress, selectedNodeCleared := 0, false, false
+	for _, index := range claimsToDeallocate(pod, state, kept.Union(waiting)) {
 		claim := state.claims[index]
-		if len(claim.Status.ReservedFor) == 0 ||
-			len(claim.Status.ReservedFor) == 1 && claim.Status.ReservedFor[0].UID == pod.UID {
+		if !isReservedForOthers(pod, claim) {
+			// An earlier scheduling attempt might have triggered the
+			// deallocation already. Doing it again would be redundant
+			// and, in the worst case, would overwrite a new allocation.
+			if reason := pl.deallocationInProgress(claim); reason != "" {
+				logger.V(5).Info("Not requesting deallocation of ResourceClaim", "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim), "reason", reason)
+				if state.escalated {
+					inProgress = true
+					continue
+				}
+				return nil, framework.NewStatus(framework.Unschedulable, "deallocation of ResourceClaim in progress")
+			}
+
 			// Is the claim is handled by the builtin controller?
 			// Then we can simply clear the allocation. Once the
 			// claim informer catches up, the controllers will
@@ -1009,40 +2352,121 @@ func (pl *dynamicResources) PostFilter(ctx context.Context, cs *framework.CycleS
 			// allocate again for that same node.
 			if !clearAllocation &&
 				state.podSchedulingState.schedulingCtx != nil &&
-				state.podSchedulingState.schedulingCtx.Spec.SelectedNode != "" {
+				state.podSchedulingState.schedulingCtx.Spec.SelectedNode != "" &&
+				!selectedNodeCleared {
 				state.podSchedulingState.selectedNode = ptr.To("")
 				if err := state.podSchedulingState.publish(ctx, pod, pl.clientset); err != nil {
-					return nil, statusError(logger, err)
+					return nil, statusError(logger, errorContext{pod: pod}.wrap(err))
 				}
+				selectedNodeCleared = true
 			}
 
-			claim := claim.DeepCopy()
-			claim.Status.ReservedFor = nil
+			status := claim.Status.DeepCopy()
+			status.ReservedFor = nil
 			if clearAllocation {
-				claim.Status.Allocation = nil
+				status.Allocation = nil
 			} else {
-				claim.Status.DeallocationRequested = true
+				status.DeallocationRequested = true
 			}
 			logger.V(5).Info("Requesting deallocation of ResourceClaim", "pod", klog.KObj(pod), "resourceclaim", klog.KObj(claim))
-			if _, err := pl.clientset.ResourceV1alpha3().ResourceClaims(claim.Namespace).UpdateStatus(ctx, claim, metav1.UpdateOptions{}); err != nil {
-				return nil, statusError(logger, err)
+			updatedClaim, err := pl.writeClaimStatus(ctx, claim, status)
+			if err != nil {
+				return nil, statusError(logger, errorContext{pod: pod, claim: claim}.wrap(fmt.Errorf("request deallocation: %w", err)))
+			}
+			// Let the next scheduling attempt see the change
+			// even if the informer has not caught up yet.
+			if err := pl.claimAssumeCache.Assume(updatedClaim); err != nil {
+				logger.V(5).Info("Claim not stored in assume cache", "err", err)
 			}
-			return nil, framework.NewStatus(framework.Unschedulable, "deallocation of ResourceClaim completed")
+			if !clearAllocation {
+				pl.setPodCondition(ctx, state, pod, waitingForDeallocationCondition(claim))
+			}
+			if !state.escalated {
+				return nil, framework.NewStatus(framework.Unschedulable, "deallocation of ResourceClaim completed")
+			}
+			deallocated++
 		}
 	}
+	switch {
+	case deallocated > 0:
+		return nil, framework.NewStatus(framework.Unschedulable, fmt.Sprintf("deallocation of %d ResourceClaim(s) completed", deallocated))
+	case inProgress:
+		return nil, framework.NewStatus(framework.Unschedulable, "deallocation of ResourceClaim in progress")
+	case waiting.Len() > 0:
+		index := slices.Min(waiting.UnsortedList())
+		logger.V(5).Info("Keeping allocation of ResourceClaim", "pod", klog.KObj(pod), "resourceclaim", klog.KObj(state.claims[index]))
+		return nil, framework.NewStatus(framework.Unschedulable, waitingForNodeMessage(state.claims[index]))
+	case kept.Len() > 0:
+		return nil, framework.NewStatus(framework.Unschedulable, "ResourceClaim reserved for the pod is kept")
+	}
+	// When Filter accepted all nodes that it saw, some other plugin
+	// rejected them and the devices are not the problem.
+	if state.allocator != nil && state.filterReasons.rejectedAny() {
+		var names []string
+		for index, claim := range state.claims {
+			if claim.Status.Allocation == nil && state.informationsForClaim[index].structuredParameters {
+				names = append(names, claim.Name)
+			}
+		}
+		pl.setPodCondition(ctx, state, pod, &v1.PodCondition{
+			Type:    PodConditionResourceClaimsReady,
+			Status:  v1.ConditionFalse,
+			Reason:  PodReasonNoDevicesAvailable,
+			Message: fmt.Sprintf("no node has the devices required by claim(s) %s", strings.Join(names, ", ")),
+		})
+	}
 	return nil, framework.NewStatus(framework.Unschedulable, "still not schedulable")
 }
 
+// deallocationInProgress checks whether the claim, as known to the assume
+// cache, is already getting deallocated or is in the process of getting
+// allocated. The claim from the start of the scheduling cycle might not
+// reflect that yet. The result is a reason for logging, empty if
+// deallocation may proceed.
+func (pl *dynamicResources) deallocationInProgress(claim *resourceapi.ResourceClaim) string {
+	if _, found := pl.inFlightAllocations.load(claim.UID); found {
+		return "allocation in flight"
+	}
+	obj, err := pl.claimAssumeCache.Get(claim.Namespace + "/" + claim.Name)
+	if err != nil {
+		return ""
+	}
+	latestClaim, ok := obj.(*resourceapi.ResourceClaim)
+	if !ok || latestClaim.UID != claim.UID {
+		return ""
+	}
+	switch {
+	case latestClaim.Status.Allocation == nil:
+		return "allocation already cleared"
+	case latestClaim.Status.DeallocationRequested:
+		return "deallocation already requested"
+	default:
+		return ""
+	}
+}
+
+// isReservedForOthers checks whether the claim is in use by some consumer
+// other than the pod. Consumers which are not pods are opaque for the
+// scheduler: they always keep the claim and its devices allocated.
+func isReservedForOthers(pod *v1.Pod, claim *resourceapi.ResourceClaim) bool {
+	for _, reserved := range claim.Status.ReservedFor {
+		if !resourceclaim.IsPodReference(reserved) || reserved.UID != pod.UID {
+			return true
+		}
+	}
+	return false
+}
+
 // PreScore is passed a list of all nodes that would fit the pod. Not all
 // claims are necessarily allocated yet, so here we can set the SuitableNodes
 // field for those which are pending.
 func (pl *dynamicResources) PreScore(ctx context.Context, cs *framework.CycleState, pod *v1.Pod, nodes []*framework.NodeInfo) *framework.Status {
-	if !pl.enabled {
+	if !pl.enabled || !hasClaims(pod) {
 		return nil
 	}
 	state, err := getStateData(cs)
 	if err != nil {
-		return statusError(klog.FromContext(ctx), err)
+		return statusError(klog.FromContext(ctx), errorContext{pod: pod}.wrap(err))
 	}
 	defer func() {
 		state.preScored = true
@@ -1050,8 +2474,45 @@ func (pl *dynamicResources) PreScore(ctx context.Context, cs *framework.CycleSta
 	if len(state.claims) == 0 {
 		return nil
 	}
+	defer observeDuration(schedulermetrics.PreScore, state, time.Now())
+
+	// Allocation results for nodes which are not candidates anymore, for
+	// example because a Filter plugin running after this one rejected
+	// them, are stale. Filter is done, so the mutex is not needed.
+	if len(state.nodeAllocations) > 0 {
+		candidates := sets.New[string]()
+		for _, node := range nodes {
+			candidates.Insert(node.Node().Name)
+		}
+		for nodeName := range state.nodeAllocations {
+			if !candidates.Has(nodeName) {
+				delete(state.nodeAllocations, nodeName)
+			}
+		}
+	}
+
+	// Nodes where the allocator failed are not candidates, but the
+	// errors should not go unnoticed.
+	pl.reportFilterErrors(ctx, state, pod)
 
 	logger := klog.FromContext(ctx)
+	preferences := pl.nodePreferences(logger, state.claims)
+	attributeScores, err := pl.scoredAttributesScores(logger, state)
+	if err != nil {
+		return statusError(logger, errorContext{pod: pod}.wrap(err))
+	}
+	nominatedNodeName := nominatedNodeWithAllocation(state, pod)
+	if len(preferences) > 0 || attributeScores != nil || nominatedNodeName != "" {
+		state.nodeScores = make(map[string]int64, len(nodes))
+		for _, node := range nodes {
+			state.nodeScores[node.Node().Name] = nodePreferenceScore(preferences, node.Node()) + attributeScores[node.Node().Name]
+		}
+		if nominatedNodeName != "" {
+			logger.V(5).Info("preferring nominated node", "pod", klog.KObj(pod), "node", klog.ObjectRef{Name: nominatedNodeName})
+			withNominatedNodeScore(state.nodeScores, nominatedNodeName)
+		}
+	}
+
 	pending := false
 	for index, claim := range state.claims {
 		if claim.Status.Allocation == nil &&
@@ -1106,6 +2567,30 @@ func (pl *dynamicResources) PreScore(ctx context.Context, cs *framework.CycleSta
 	return nil
 }
 
+// Score adds points for the node preferences and scored attributes of the
+// claims of the pod and for the nominated node of the pod.
+func (pl *dynamicResources) Score(ctx context.Context, cs *framework.CycleState, pod *v1.Pod, nodeName string) (int64, *framework.Status) {
+	if !pl.enabled || !hasClaims(pod) {
+		return 0, nil
+	}
+	state, err := getStateData(cs)
+	if err != nil {
+		return 0, statusError(klog.FromContext(ctx), errorContext{pod: pod}.wrap(err))
+	}
+	return state.nodeScores[nodeName], nil
+}
+
+// ScoreExtensions of the Score plugin.
+func (pl *dynamicResources) ScoreExtensions() framework.ScoreExtensions {
+	return pl
+}
+
+// NormalizeScore scales the scores so that the best node gets the maximum
+// score.
+func (pl *dynamicResources) NormalizeScore(ctx context.Context, cs *framework.CycleState, pod *v1.Pod, scores framework.NodeScoreList) *framework.Status {
+	return helper.DefaultNormalizeScore(framework.MaxNodeScore, false, scores)
+}
+
 func haveAllPotentialNodes(schedulingCtx *resourceapi.PodSchedulingContext, nodes []*framework.NodeInfo) bool {
 	if schedulingCtx == nil {
 		return false
@@ -1120,16 +2605,23 @@ func haveAllPotentialNodes(schedulingCtx *resourceapi.PodSchedulingContext, node
 
 // Reserve reserves claims for the pod.
 func (pl *dynamicResources) Reserve(ctx context.Context, cs *framework.CycleState, pod *v1.Pod, nodeName string) (status *framework.Status) {
-	if !pl.enabled {
+	if !pl.enabled || !hasClaims(pod) {
 		return nil
 	}
 	state, err := getStateData(cs)
 	if err != nil {
-		return statusError(klog.FromContext(ctx), err)
+		return statusError(klog.FromContext(ctx), errorContext{pod: pod}.wrap(err))
 	}
 	if len(state.claims) == 0 {
 		return nil
 	}
+	start := time.Now()
+	defer func() {
+		state.metricsDriver = state.driverLabel()
+		observeDuration(schedulermetrics.Reserve, state, start)
+	}()
+	// PreScore is not called when only one node passed Filter.
+	pl.reportFilterErrors(ctx, state, pod)
 
 	logger := klog.FromContext(ctx)
 
@@ -1156,8 +2648,9 @@ func (pl *dynamicResources) Reserve(ctx context.Context, cs *framework.CycleStat
 		numDelayedAllocationPending++
 
 		// Did the driver provide information that steered node
-		// selection towards a node that it can support?
-		if statusForClaim(state.podSchedulingState.schedulingCtx, pod.Spec.ResourceClaims[index].Name) != nil {
+		// selection towards a node that it can support? PreFilter
+		// looked that up already.
+		if state.informationsForClaim[index].status != nil {
 			numClaimsWithStatusInfo++
 		}
 	}
@@ -1190,22 +2683,47 @@ func (pl *dynamicResources) Reserve(ctx context.Context, cs *framework.CycleStat
 		if !ok {
 			// We checked before that the node is suitable. This shouldn't have failed,
 			// so treat this as an error.
-			return statusError(logger, errors.New("claim allocation not found for node"))
+			return statusError(logger, errorContext{pod: pod, nodeName: nodeName}.wrap(errors.New("claim allocation not found for node")))
 		}
 
 		// Sanity check: do we have results for all pending claims?
 		if len(allocations) != len(claimsToAllocate) ||
 			len(allocations) != numClaimsWithAllocator {
-			return statusError(logger, fmt.Errorf("internal error, have %d allocations, %d claims to allocate, want %d claims", len(allocations), len(claimsToAllocate), numClaimsWithAllocator))
+			return statusError(logger, errorContext{pod: pod, nodeName: nodeName}.wrap(fmt.Errorf("internal error, have %d allocations, %d claims to allocate, want %d claims", len(allocations), len(claimsToAllocate), numClaimsWithAllocator)))
+		}
+
+		// Check quotas before anything gets recorded as in flight.
+		// Requests which were granted before a denial get released
+		// by Unreserve, which the scheduler calls when Reserve fails.
+		if pl.quotaChecker != nil {
+			for _, request := range quotaRequests(pod.Namespace, claimsToAllocate, allocations) {
+				allowed, reason, err := pl.quotaChecker.Reserve(ctx, pod, request)
+				if err != nil {
+					return statusError(logger, errorContext{pod: pod, nodeName: nodeName}.wrap(fmt.Errorf("check device quota: %w", err)))
+				}
+				if !allowed {
+					return statusResourcesExhausted(logger, reason, "pod", klog.KObj(pod), "node", klog.ObjectRef{Name: nodeName})
+				}
+				state.quotaReservations = append(state.quotaReservations, request)
+			}
 		}
 
 		for i, claim := range claimsToAllocate {
 			index := slices.Index(state.claims, claim)
 			if index < 0 {
-				return statusError(logger, fmt.Errorf("internal error, claim %s with allocation not found", claim.Name))
+				return statusError(logger, errorContext{pod: pod, claim: claim, nodeName: nodeName}.wrap(errors.New("internal error, claim with allocation not found")))
 			}
 			allocation := allocations[i]
+			var allocatedDeviceClasses string
+			if pl.recordDeviceClasses {
+				value, err := pl.allocatedDeviceClasses(claim)
+				if err != nil {
+					return statusError(logger, errorContext{pod: pod, claim: claim, nodeName: nodeName}.wrap(err))
+				}
+				allocatedDeviceClasses = value
+			}
 			state.informationsForClaim[index].allocation = allocation
+			state.informationsForClaim[index].allocatedDeviceClasses = allocatedDeviceClasses
 
 			// Strictly speaking, we don't need to store the full modified object.
 			// The allocation would be enough. The full object is useful for
@@ -1214,9 +2732,13 @@ func (pl *dynamicResources) Reserve(ctx context.Context, cs *framework.CycleStat
 			if !slices.Contains(claim.Finalizers, resourceapi.Finalizer) {
 				claim.Finalizers = append(claim.Finalizers, resourceapi.Finalizer)
 			}
+			if allocatedDeviceClasses != "" {
+				metav1.SetMetaDataAnnotation(&claim.ObjectMeta, AnnotationAllocatedDeviceClasses, allocatedDeviceClasses)
+			}
 			claim.Status.Allocation = allocation
-			pl.inFlightAllocations.Store(claim.UID, claim)
-			logger.V(5).Info("Reserved resource in allocation result", "claim", klog.KObj(claim), "allocation", klog.Format(allocation))
+			pl.inFlightAllocations.store(&inFlightAllocation{claim: claim, nodeName: nodeName, podUID: pod.UID, since: pl.clock.Now()})
+			state.recordChange(index, claimAssumed)
+			logger.V(5).Info("Reserved resource in allocation result", "claim", klog.KObj(claim), "allocation", resourceclaim.AllocationSummary(allocation, &claim.Spec))
 		}
 	}
 
@@ -1238,6 +2760,7 @@ func (pl *dynamicResources) Reserve(ctx context.Context, cs *framework.CycleStat
 		if state.podSchedulingState.schedulingCtx == nil ||
 			state.podSchedulingState.schedulingCtx.Spec.SelectedNode != nodeName {
 			state.podSchedulingState.selectedNode = &nodeName
+			state.podSchedulingState.selectedByReserve = true
 			logger.V(5).Info("start allocation", "pod", klog.KObj(pod), "node", klog.ObjectRef{Name: nodeName})
 			// The actual publish happens in PreBind or Unreserve.
 			return nil
@@ -1257,6 +2780,15 @@ func (pl *dynamicResources) Reserve(ctx context.Context, cs *framework.CycleStat
 		return nil
 	}
 
+	// The driver was asked to allocate for this node before. Maybe it
+	// took too long?
+	if state.podSchedulingState.schedulingCtx != nil &&
+		state.podSchedulingState.schedulingCtx.Spec.SelectedNode == nodeName {
+		if status := pl.checkAllocationTimeouts(logger, state, pod, nodeName); status != nil {
+			return status
+		}
+	}
+
 	// More than one pending claim and not enough information about all of them.
 	//
 	// TODO: can or should we ensure that schedulingCtx gets aborted while
@@ -1267,10 +2799,39 @@ func (pl *dynamicResources) Reserve(ctx context.Context, cs *framework.CycleStat
 	return statusPending(logger, "waiting for resource driver to provide information", "pod", klog.KObj(pod))
 }
 
+// checkAllocationTimeouts stops waiting for the driver when some pending
+// claim has an allocation timeout which expired. The selected node gets
+// cleared (published by Unreserve) so that the driver stops allocating
+// and the next scheduling attempt can pick some other node.
+func (pl *dynamicResources) checkAllocationTimeouts(logger klog.Logger, state *stateData, pod *v1.Pod, nodeName string) *framework.Status {
+	now := pl.clock.Now()
+	for index, claim := range state.claims {
+		if claim.Status.Allocation != nil || state.informationsForClaim[index].structuredParameters {
+			continue
+		}
+		wait := pl.driverWaits.start(claim.UID, nodeName, now)
+		timeout, err := allocationTimeout(claim)
+		if err != nil {
+			// Already reported by PreEnqueue, but the claim
+			// might have been changed since then.
+			return statusUnschedulable(logger, errorContext{claim: claim}.wrap(err).Error(), "pod", klog.KObj(pod))
+		}
+		if timeout == 0 || now.Sub(wait.since) < timeout {
+			continue
+		}
+		for _, claim := range state.claims {
+			pl.driverWaits.forget(claim.UID)
+		}
+		state.podSchedulingState.selectedNode = ptr.To("")
+		return statusUnschedulable(logger, errorContext{claim: claim}.reason(fmt.Sprintf("resource driver did not allocate within %s", timeout)), "pod", klog.KObj(pod), "node", klog.ObjectRef{Name: nodeName})
+	}
+	return nil
+}
+
 // Unreserve clears the ReservedFor field for all claims.
 // It's idempotent, and does nothing if no state found for the given pod.
 func (pl *dynamicResources) Unreserve(ctx context.Context, cs *framework.CycleState, pod *v1.Pod, nodeName string) {
-	if !pl.enabled {
+	if !pl.enabled || !hasClaims(pod) {
 		return
 	}
 	state, err := getStateData(cs)
@@ -1280,9 +2841,25 @@ func (pl *dynamicResources) Unreserve(ctx context.Context, cs *framework.CycleSt
 	if len(state.claims) == 0 {
 		return
 	}
+	defer observeDuration(schedulermetrics.Unreserve, state, time.Now())
 
 	logger := klog.FromContext(ctx)
 
+	// Binding is not going to happen after all.
+	if state.podCondition != nil && state.podCondition.Reason == PodReasonDevicesAllocated {
+		pl.setPodCondition(ctx, state, pod, nil)
+	}
+
+	// Did Reserve select a node which PreBind never got to publish? Then
+	// drivers must not start allocating for it. A node selected in an
+	// earlier scheduling cycle gets cleared below because Reserve chose a
+	// different one, so it is not going to be used either.
+	clearSelectedNode := state.podSchedulingState.selectedByReserve
+	if clearSelectedNode {
+		state.podSchedulingState.selectedNode = nil
+		state.podSchedulingState.selectedByReserve = false
+	}
+
 	// Was publishing delayed? If yes, do it now.
 	//
 	// The most common scenario is that a different set of potential nodes
@@ -1293,33 +2870,24 @@ func (pl *dynamicResources) Unreserve(ctx context.Context, cs *framework.CycleSt
 			logger.Error(err, "publish PodSchedulingContext")
 		}
 	}
-
-	for index, claim := range state.claims {
-		// If allocation was in-flight, then it's not anymore and we need to revert the
-		// claim object in the assume cache to what it was before.
-		if state.informationsForClaim[index].structuredParameters {
-			if _, found := pl.inFlightAllocations.LoadAndDelete(state.claims[index].UID); found {
-				pl.claimAssumeCache.Restore(claim.Namespace + "/" + claim.Name)
-			}
+	if clearSelectedNode {
+		if err := state.podSchedulingState.clearSelectedNode(ctx, pod, pl.clientset); err != nil {
+			logger.Error(err, "clear selected node in PodSchedulingContext")
 		}
+	}
 
-		if claim.Status.Allocation != nil &&
-			resourceclaim.IsReservedForPod(pod, claim) {
-			// Remove pod from ReservedFor. A strategic-merge-patch is used
-			// because that allows removing an individual entry without having
-			// the latest slice.
-			patch := fmt.Sprintf(`{"metadata": {"uid": %q}, "status": { "reservedFor": [ {"$patch": "delete", "uid": %q} ] }}`,
-				claim.UID,
-				pod.UID,
-			)
-			logger.V(5).Info("unreserve", "resourceclaim", klog.KObj(claim), "pod", klog.KObj(pod))
-			claim, err := pl.clientset.ResourceV1alpha3().ResourceClaims(claim.Namespace).Patch(ctx, claim.Name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{}, "status")
-			if err != nil {
-				// We will get here again when pod scheduling is retried.
-				logger.Error(err, "unreserve", "resourceclaim", klog.KObj(claim))
-			}
+	if pl.quotaChecker != nil {
+		for _, request := range state.quotaReservations {
+			pl.quotaChecker.Unreserve(ctx, pod, request)
 		}
 	}
+	state.quotaReservations = nil
+
+	// Only what this scheduling cycle changed gets undone. A claim which
+	// was allocated or reserved for the pod before keeps that.
+	for index := range state.claims {
+		pl.undoClaimChanges(ctx, state, index, pod)
+	}
 }
 
 // PreBind gets called in a separate goroutine after it has been determined
@@ -1327,20 +2895,21 @@ func (pl *dynamicResources) Unreserve(ctx context.Context, cs *framework.CycleSt
 // reserve claims, we need to do it now. For claims with the builtin controller,
 // we also handle the allocation.
 //
-// If anything fails, we return an error and
+// If anything fails, claims written so far get rolled back, we return an error and
 // the pod will have to go into the backoff queue. The scheduler will call
 // Unreserve as part of the error handling.
 func (pl *dynamicResources) PreBind(ctx context.Context, cs *framework.CycleState, pod *v1.Pod, nodeName string) *framework.Status {
-	if !pl.enabled {
+	if !pl.enabled || !hasClaims(pod) {
 		return nil
 	}
 	state, err := getStateData(cs)
 	if err != nil {
-		return statusError(klog.FromContext(ctx), err)
+		return statusError(klog.FromContext(ctx), errorContext{pod: pod}.wrap(err))
 	}
 	if len(state.claims) == 0 {
 		return nil
 	}
+	defer observeDuration(schedulermetrics.PreBind, state, time.Now())
 
 	logger := klog.FromContext(ctx)
 
@@ -1348,25 +2917,75 @@ func (pl *dynamicResources) PreBind(ctx context.Context, cs *framework.CycleStat
 	// This will not happen if all claims get handled by builtin controllers.
 	if state.podSchedulingState.isDirty() {
 		if err := state.podSchedulingState.publish(ctx, pod, pl.clientset); err != nil {
-			return statusError(logger, err)
+			return statusError(logger, errorContext{pod: pod, nodeName: nodeName}.wrap(err))
 		}
+		var names []string
+		for index, claim := range state.claims {
+			if claim.Status.Allocation == nil && !state.informationsForClaim[index].structuredParameters {
+				names = append(names, claim.Name)
+			}
+		}
+		pl.setPodCondition(ctx, state, pod, &v1.PodCondition{
+			Type:    PodConditionResourceClaimsReady,
+			Status:  v1.ConditionFalse,
+			Reason:  PodReasonWaitingForDriver,
+			Message: fmt.Sprintf("waiting for resource driver to allocate claim(s) %s", strings.Join(names, ", ")),
+		})
 		return statusPending(logger, "waiting for resource driver", "pod", klog.KObj(pod), "node", klog.ObjectRef{Name: nodeName})
 	}
 
+	// The claims which were written successfully get rolled back when
+	// writing some other claim fails, so the next attempt starts
+	// from a clean slate.
+	var written []int
 	for index, claim := range state.claims {
 		if !resourceclaim.IsReservedForPod(pod, claim) {
 			claim, err := pl.bindClaim(ctx, state, index, pod, nodeName)
 			if err != nil {
-				return statusError(logger, err)
+				pl.rollbackClaims(ctx, state, pod, written)
+				return statusError(logger, errorContext{pod: pod, nodeName: nodeName}.wrap(err))
 			}
 			state.claims[index] = claim
+			written = append(written, index)
 		}
 	}
 	// If we get here, we know that reserving the claim for
 	// the pod worked and we can proceed with binding it.
+	pl.setPodCondition(ctx, state, pod, &v1.PodCondition{
+		Type:    PodConditionResourceClaimsReady,
+		Status:  v1.ConditionTrue,
+		Reason:  PodReasonDevicesAllocated,
+		Message: "all claims are allocated and reserved, binding the pod",
+	})
+
+	// Once all claims are allocated, a PodSchedulingContext that is
+	// still around is stale, for example because the claims got
+	// allocated out-of-band while the pod was pending. PostBind would
+	// remove it as well, but only if binding succeeds. The deletion
+	// goes through the same background worker as in PostBind, so it
+	// neither delays binding nor prevents it by failing. When too many
+	// deletions are pending, it is left to PostBind.
+	if schedulingCtx := state.podSchedulingState.schedulingCtx; schedulingCtx != nil && allClaimsAllocated(state.claims) {
+		ref := newSchedulingContextRef(pod, state)
+		if pl.pendingSchedulingContexts.add(ref, pl.maxPendingSchedulingContexts) {
+			logger.V(5).Info("Removing stale PodSchedulingContext of pod with allocated claims", "pod", klog.KObj(pod), "podSchedulingCtx", klog.KObj(schedulingCtx))
+			pl.schedulingContextQueue.Add(ref)
+		}
+	}
 	return nil
 }
 
+// allClaimsAllocated returns true if none of the claims needs to be
+// allocated anymore.
+func allClaimsAllocated(claims []*resourceapi.ResourceClaim) bool {
+	for _, claim := range claims {
+		if claim.Status.Allocation == nil {
+			return false
+		}
+	}
+	return true
+}
+
 // bindClaim gets called by PreBind for claim which is not reserved for the pod yet.
 // It might not even be allocated. bindClaim then ensures that the allocation
 // and reservation are recorded. This finishes the work started in Reserve.
@@ -1385,21 +3004,26 @@ func (pl *dynamicResources) bindClaim(ctx context.Context, state *stateData, ind
 					logger.V(5).Info("Claim not stored in assume cache", "err", finalErr)
 				}
 			}
-			pl.inFlightAllocations.Delete(claim.UID)
+			pl.inFlightAllocations.delete(claim.UID)
 		}
 	}()
 
-	logger.V(5).Info("preparing claim status update", "claim", klog.KObj(state.claims[index]), "allocation", klog.Format(allocation))
+	logger.V(5).Info("preparing claim status update", "claim", klog.KObj(state.claims[index]), "allocation", resourceclaim.AllocationSummary(allocation, &state.claims[index].Spec))
 
 	// We may run into a ResourceVersion conflict because there may be some
 	// benign concurrent changes. In that case we get the latest claim and
 	// try again.
+	//
+	// The changes get recorded once after retrying. A finalizer
+	// which was added by some attempt gets recorded even if a later
+	// attempt fails, Unreserve has to remove it.
 	refreshClaim := false
-	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+	var finalizerWasAdded, allocationWasWritten, reservationWasAdded bool
+	retryErr := retry.RetryOnConflict(claimUpdateBackoff, func() error {
 		if refreshClaim {
 			updatedClaim, err := pl.clientset.ResourceV1alpha3().ResourceClaims(claim.Namespace).Get(ctx, claim.Name, metav1.GetOptions{})
 			if err != nil {
-				return fmt.Errorf("get updated claim %s after conflict: %w", klog.KObj(claim), err)
+				return fmt.Errorf("get updated claim after conflict: %w", err)
 			}
 			logger.V(5).Info("retrying update after conflict", "claim", klog.KObj(claim))
 			claim = updatedClaim
@@ -1408,59 +3032,155 @@ func (pl *dynamicResources) bindClaim(ctx context.Context, state *stateData, ind
 			refreshClaim = true
 		}
 
-		if claim.DeletionTimestamp != nil {
-			return fmt.Errorf("claim %s got deleted in the meantime", klog.KObj(claim))
+		// The finalizer of a claim which is being deleted only
+		// protects the existing consumers. After a conflict, the
+		// latest claim might already list the pod.
+		if claim.DeletionTimestamp != nil && !resourceclaim.IsReservedForPod(pod, claim) {
+			return errors.New("claim is being deleted, cannot reserve it for a new consumer")
 		}
 
 		// Do we need to store an allocation result from Reserve?
 		if allocation != nil {
 			if claim.Status.Allocation != nil {
-				return fmt.Errorf("claim %s got allocated elsewhere in the meantime", klog.KObj(claim))
+				return errors.New("claim got allocated elsewhere in the meantime")
 			}
 
-			// The finalizer needs to be added in a normal update.
-			// If we were interrupted in the past, it might already be set and we simply continue.
-			if !slices.Contains(claim.Finalizers, resourceapi.Finalizer) {
-				claim.Finalizers = append(claim.Finalizers, resourceapi.Finalizer)
+			// The finalizer and the annotation need to be added in a normal update.
+			// If we were interrupted in the past, they might already be set and we simply continue.
+			addFinalizer := !slices.Contains(claim.Finalizers, resourceapi.Finalizer)
+			allocatedDeviceClasses := state.informationsForClaim[index].allocatedDeviceClasses
+			addAnnotation := allocatedDeviceClasses != "" && claim.Annotations[AnnotationAllocatedDeviceClasses] != allocatedDeviceClasses
+			if addFinalizer || addAnnotation {
+				if addFinalizer {
+					claim.Finalizers = append(claim.Finalizers, resourceapi.Finalizer)
+				}
+				if addAnnotation {
+					metav1.SetMetaDataAnnotation(&claim.ObjectMeta, AnnotationAllocatedDeviceClasses, allocatedDeviceClasses)
+				}
 				updatedClaim, err := pl.clientset.ResourceV1alpha3().ResourceClaims(claim.Namespace).Update(ctx, claim, metav1.UpdateOptions{})
 				if err != nil {
-					return fmt.Errorf("add finalizer to claim %s: %w", klog.KObj(claim), err)
+					return fmt.Errorf("add finalizer: %w", err)
 				}
 				claim = updatedClaim
+				if addFinalizer {
+					finalizerWasAdded = true
+				}
+				if addFinalizer && slices.Contains(state.claims[index].Finalizers, resourceapi.Finalizer) {
+					// It was set at the start of the scheduling cycle,
+					// so someone else must have removed it.
+					logger.V(2).Info("Finalizer was removed during scheduling", "claim", klog.KObj(claim))
+					if recorder := pl.eventRecorder; recorder != nil {
+						recorder.Eventf(claim, nil, v1.EventTypeWarning, ReasonFinalizerReAdded, "PreBind",
+							"ResourceClaim finalizer was removed during scheduling; re-adding")
+					}
+				}
 			}
-			claim.Status.Allocation = allocation
+		}
+
+		status := claim.Status.DeepCopy()
+		if allocation != nil {
+			status.Allocation = allocation
 		}
 
 		// We can simply try to add the pod here without checking
 		// preconditions. The apiserver will tell us with a
 		// non-conflict error if this isn't possible.
-		claim.Status.ReservedFor = append(claim.Status.ReservedFor, resourceapi.ResourceClaimConsumerReference{Resource: "pods", Name: pod.Name, UID: pod.UID})
-		updatedClaim, err := pl.clientset.ResourceV1alpha3().ResourceClaims(claim.Namespace).UpdateStatus(ctx, claim, metav1.UpdateOptions{})
+		//
+		// After a conflict, the latest claim might already list the
+		// pod, for example because an earlier attempt succeeded
+		// without us noticing. Adding it again would be rejected as
+		// a duplicate. Entries of other consumers are kept as they are.
+		addReservation := !resourceclaim.IsReservedForPod(pod, claim)
+		if addReservation {
+			deviceIndices := state.informationsForClaim[index].deviceIndices
+			if err := checkDeviceIndices(deviceIndices, status.Allocation); err != nil {
+				return fmt.Errorf("reserve: %w", err)
+			}
+			reservation := resourceapi.ResourceClaimConsumerReference{Resource: "pods", Name: pod.Name, UID: pod.UID, DeviceIndices: deviceIndices}
+			if pl.reservationDeadlineEnabled {
+				deadline := metav1.NewTime(pl.clock.Now().Add(reservationTimeout))
+				reservation.ReservationDeadline = &deadline
+			}
+			status.ReservedFor = append(status.ReservedFor, reservation)
+		}
+		updatedClaim, err := pl.writeClaimStatus(ctx, claim, status)
 		if err != nil {
 			if allocation != nil {
-				return fmt.Errorf("add allocation and reservation to claim %s: %w", klog.KObj(claim), err)
+				return fmt.Errorf("add allocation and reservation: %w", err)
 			}
-			return fmt.Errorf("add reservation to claim %s: %w", klog.KObj(claim), err)
+			return fmt.Errorf("add reservation: %w", err)
 		}
 		claim = updatedClaim
+		allocationWasWritten = allocation != nil
+		reservationWasAdded = addReservation
 		return nil
 	})
 
+	if finalizerWasAdded {
+		state.recordChange(index, finalizerAdded)
+	}
+	if allocationWasWritten {
+		state.recordChange(index, allocationWritten)
+	}
+	if reservationWasAdded {
+		state.recordChange(index, reservationAdded)
+	}
 	if retryErr != nil {
-		return nil, retryErr
+		return nil, errorContext{claim: claim}.wrap(retryErr)
 	}
 
 	logger.V(5).Info("reserved", "pod", klog.KObj(pod), "node", klog.ObjectRef{Name: nodeName}, "resourceclaim", klog.Format(claim))
 	return claim, nil
 }
 
+// writeClaimStatus stores the new status of the claim, using the configured
+// write strategy. The claim must be the latest known version and not be
+// modified by the caller.
+//
+// With patching, only the differences get sent. Changes of the allocation
+// must not be applied to a claim that was modified in the meantime, so in
+// that case the ResourceVersion is included in the patch as a
+// precondition, which causes the same conflict as an update would. Adding
+// a pod to ReservedFor merges with concurrent changes.
+func (pl *dynamicResources) writeClaimStatus(ctx context.Context, claim *resourceapi.ResourceClaim, status *resourceapi.ResourceClaimStatus) (*resourceapi.ResourceClaim, error) {
+	if pl.writeStrategy != config.PatchWriteStrategy {
+		claim = claim.DeepCopy()
+		claim.Status = *status
+		return pl.clientset.ResourceV1alpha3().ResourceClaims(claim.Namespace).UpdateStatus(ctx, claim, metav1.UpdateOptions{})
+	}
+
+	oldData, err := json.Marshal(resourceapi.ResourceClaim{Status: claim.Status})
+	if err != nil {
+		return nil, err
+	}
+	newClaim := resourceapi.ResourceClaim{
+		ObjectMeta: metav1.ObjectMeta{UID: claim.UID},
+		Status:     *status,
+	}
+	if !apiequality.Semantic.DeepEqual(claim.Status.Allocation, status.Allocation) ||
+		claim.Status.DeallocationRequested != status.DeallocationRequested {
+		newClaim.ResourceVersion = claim.ResourceVersion
+	}
+	newData, err := json.Marshal(newClaim)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := strategicpatch.CreateTwoWayMergePatch(oldData, newData, &resourceapi.ResourceClaim{})
+	if err != nil {
+		return nil, fmt.Errorf("create patch for claim %s: %w", klog.KObj(claim), err)
+	}
+	return pl.clientset.ResourceV1alpha3().ResourceClaims(claim.Namespace).Patch(ctx, claim.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}, "status")
+}
+
 // PostBind is called after a pod is successfully bound to a node. Now we are
 // sure that a PodSchedulingContext object, if it exists, is definitely not going to
-// be needed anymore and can delete it. This is a one-shot thing, there won't
-// be any retries.  This is okay because it should usually work and in those
-// cases where it doesn't, the garbage collector will eventually clean up.
+// be needed anymore and can delete it. The deletion is queued for a background
+// worker which retries failed attempts with backoff a limited number of times.
+// When too many deletions are pending, PostBind tries once itself instead. In
+// those cases where the object cannot be deleted, the garbage collector will
+// eventually clean up.
 func (pl *dynamicResources) PostBind(ctx context.Context, cs *framework.CycleState, pod *v1.Pod, nodeName string) {
-	if !pl.enabled {
+	if !pl.enabled || !hasClaims(pod) {
 		return
 	}
 	state, err := getStateData(cs)
@@ -1470,21 +3190,88 @@ func (pl *dynamicResources) PostBind(ctx context.Context, cs *framework.CycleSta
 	if len(state.claims) == 0 {
 		return
 	}
+	defer observeDuration(schedulermetrics.PostBind, state, time.Now())
 
-	// We cannot know for sure whether the PodSchedulingContext object exists. We
-	// might have created it in the previous pod schedulingCtx cycle and not
-	// have it in our informer cache yet. Let's try to delete, just to be
-	// on the safe side.
-	logger := klog.FromContext(ctx)
-	err = pl.clientset.ResourceV1alpha3().PodSchedulingContexts(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
+	// The PodSchedulingContext object might exist although this cycle
+	// did not see it, for example when it was created in the previous
+	// cycle and the informer cache had not caught up yet. The deletion
+	// then finds it in the cache.
+	//
+	// The claims were reserved in PreBind and the assume cache already
+	// reflects that, so nothing in the scheduler waits for the deletion.
+	// It gets done in the background unless too many deletions are
+	// pending, in which case PostBind slows down scheduling by doing it
+	// itself.
+	if pl.podSchedulingContextLister != nil {
+		ref := newSchedulingContextRef(pod, state)
+		if pl.pendingSchedulingContexts.add(ref, pl.maxPendingSchedulingContexts) {
+			pl.schedulingContextQueue.Add(ref)
+		} else if err := pl.deleteSchedulingContext(ctx, ref); err != nil {
+			klog.FromContext(ctx).Error(err, "delete PodSchedulingContext, not retrying because too many deletions are pending", "podSchedulingCtx", klog.KRef(ref.namespace, ref.name))
+		}
+	}
+
+	pl.allocationsCommitted(klog.FromContext(ctx), state, pod, nodeName)
+
+	// The condition is only relevant while scheduling.
+	pl.setPodCondition(ctx, state, pod, nil)
+}
+
+// waitingForDeallocationCondition returns the pod condition for a pod
+// which has to wait for a resource driver to deallocate the claim.
+func waitingForDeallocationCondition(claim *resourceapi.ResourceClaim) *v1.PodCondition {
+	return &v1.PodCondition{
+		Type:    PodConditionResourceClaimsReady,
+		Status:  v1.ConditionFalse,
+		Reason:  PodReasonWaitingForDeallocation,
+		Message: fmt.Sprintf("waiting for resource driver to deallocate claim %s", claim.Name),
+	}
+}
+
+// setPodCondition ensures that the PodConditionResourceClaimsReady
+// condition of the pod matches the given condition. A nil condition
+// removes it. Nothing is written if the pod already has the desired
+// condition, which avoids updating the pod in each scheduling attempt.
+//
+// Failures are only logged because the condition is informational.
+func (pl *dynamicResources) setPodCondition(ctx context.Context, state *stateData, pod *v1.Pod, condition *v1.PodCondition) {
+	current := state.podCondition
 	switch {
-	case apierrors.IsNotFound(err):
-		logger.V(5).Info("no PodSchedulingContext object to delete")
-	case err != nil:
-		logger.Error(err, "delete PodSchedulingContext")
-	default:
-		logger.V(5).Info("PodSchedulingContext object deleted")
+	case current == nil && condition == nil:
+		return
+	case current != nil && condition != nil &&
+		current.Status == condition.Status &&
+		current.Reason == condition.Reason &&
+		current.Message == condition.Message:
+		return
+	}
+
+	logger := klog.FromContext(ctx)
+	logger.V(5).Info("Updating pod condition", "pod", klog.KObj(pod), "condition", klog.Format(condition))
+	var err error
+	if condition == nil {
+		// A two-way merge patch would replace the entire list when
+		// it becomes empty, so the entry gets removed explicitly.
+		patch := fmt.Sprintf(`{"status": {"conditions": [ {"$patch": "delete", "type": %q} ]}}`, PodConditionResourceClaimsReady)
+		_, err = pl.clientset.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{}, "status")
+	} else {
+		// Only the condition is of interest, so the patch is computed
+		// based on a status which contains nothing else.
+		oldPod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name}}
+		newStatus := &v1.PodStatus{}
+		if current != nil {
+			oldPod.Status.Conditions = []v1.PodCondition{*current}
+			newStatus.Conditions = []v1.PodCondition{*current}
+		}
+		condition = condition.DeepCopy()
+		podutil.UpdatePodCondition(newStatus, condition)
+		err = schedutil.PatchPodStatus(ctx, pl.clientset, oldPod, newStatus)
 	}
+	if err != nil {
+		logger.Error(err, "Updating pod condition failed", "pod", klog.KObj(pod))
+		return
+	}
+	state.podCondition = condition
 }
 
 // statusUnschedulable ensures that there is a log message associated with the
@@ -1500,6 +3287,20 @@ func statusUnschedulable(logger klog.Logger, reason string, kv ...interface{}) *
 	return framework.NewStatus(framework.UnschedulableAndUnresolvable, reason)
 }
 
+// statusResourcesExhausted is like statusUnschedulable, except that the pod
+// might fit once other pods release their devices. Preemption then
+// considers the node.
+func statusResourcesExhausted(logger klog.Logger, reason string, kv ...interface{}) *framework.Status {
+	if loggerV := logger.V(5); loggerV.Enabled() {
+		helper, loggerV := loggerV.WithCallStackHelper()
+		helper()
+		kv = append(kv, "reason", reason)
+		// nolint: logcheck // warns because it cannot check key/values
+		loggerV.Info("pod unschedulable", kv...)
+	}
+	return framework.NewStatus(framework.Unschedulable, reason)
+}
+
 // statusPending ensures that there is a log message associated with the
 // line where the status originated.
 func statusPending(logger klog.Logger, reason string, kv ...interface{}) *framework.Status {
@@ -18,10 +18,17 @@ package dynamicresources
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -32,19 +39,37 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	resourceapi "k8s.io/api/resource/v1alpha3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
+	resourcelisters "k8s.io/client-go/listers/resource/v1alpha3"
 	cgotesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/component-base/metrics/testutil"
+	"k8s.io/dynamic-resource-allocation/resourceclaim"
+	"k8s.io/dynamic-resource-allocation/structured"
+	"k8s.io/klog/v2"
+	podutil "k8s.io/kubernetes/pkg/api/v1/pod"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/dynamicresources/metrics"
 	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/feature"
 	"k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+	schedulermetrics "k8s.io/kubernetes/pkg/scheduler/metrics"
 	st "k8s.io/kubernetes/pkg/scheduler/testing"
 	"k8s.io/kubernetes/pkg/scheduler/util/assumecache"
 	"k8s.io/kubernetes/test/utils/ktesting"
+	"k8s.io/kubernetes/test/utils/ktesting/initoption"
+	"k8s.io/utils/clock"
+	testingclock "k8s.io/utils/clock/testing"
 	"k8s.io/utils/ptr"
 )
 
@@ -57,6 +82,7 @@ var (
 	controller    = "some-driver"
 	driver        = controller
 	podName       = "my-pod"
+	otherPodName  = "other-pod"
 	podUID        = "1234"
 	resourceName  = "my-resource"
 	resourceName2 = resourceName + "-2"
@@ -71,11 +97,56 @@ var (
 			Name: className,
 		},
 	}
+	// Same class, but with a configuration for the driver that must
+	// get passed on to the driver through the allocation result.
+	configuredDeviceClass = &resourceapi.DeviceClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: className,
+		},
+		Spec: resourceapi.DeviceClassSpec{
+			Config: []resourceapi.DeviceClassConfiguration{{
+				DeviceConfiguration: deviceConfig,
+			}},
+		},
+	}
+	deviceConfig = resourceapi.DeviceConfiguration{
+		Opaque: &resourceapi.OpaqueDeviceConfiguration{
+			Driver:     driver,
+			Parameters: apiruntime.RawExtension{Raw: []byte(`{"mode":"shared"}`)},
+		},
+	}
+	// Same class, but only usable on nodes in the premium node pool.
+	premiumDeviceClass = &resourceapi.DeviceClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: className,
+		},
+		Spec: resourceapi.DeviceClassSpec{
+			SuitableNodes: &v1.NodeSelector{
+				NodeSelectorTerms: []v1.NodeSelectorTerm{{
+					MatchExpressions: []v1.NodeSelectorRequirement{{
+						Key:      "tier",
+						Operator: v1.NodeSelectorOpIn,
+						Values:   []string{"premium"},
+					}},
+				}},
+			},
+		},
+	}
 
 	podWithClaimName = st.MakePod().Name(podName).Namespace(namespace).
 				UID(podUID).
 				PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: &claimName}).
 				Obj()
+	podWithDeviceAntiAffinity = st.MakePod().Name(podName).Namespace(namespace).
+					UID(podUID).
+					PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: &claimName}).
+					Annotation(AnnotationDeviceAntiAffinityPods, otherPodName).
+					Annotation(AnnotationDeviceAntiAffinityAttribute, driver+"/card").
+					Obj()
+	otherPodWithClaimName = st.MakePod().Name(otherPodName).Namespace(namespace).
+				UID("other-" + podUID).
+				PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: ptr.To("not-my-claim")}).
+				Obj()
 	podWithClaimTemplate = st.MakePod().Name(podName).Namespace(namespace).
 				UID(podUID).
 				PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimTemplateName: &claimName}).
@@ -99,6 +170,23 @@ var (
 	// Node with "instance-1" device and no device attributes.
 	workerNode      = &st.MakeNode().Name(nodeName).Label("kubernetes.io/hostname", nodeName).Node
 	workerNodeSlice = st.MakeResourceSlice(nodeName, driver).Device("instance-1", nil).Obj()
+	// The same node publishes a second pool, for example for a
+	// different NUMA node, with the same device names.
+	workerNodeSecondPoolSlice = st.MakeResourceSlice(nodeName, driver).Pool(nodeName+"-numa-1").Device("instance-1", nil).Obj()
+	// Both devices are on the same card.
+	workerNodeCardSlice = st.MakeResourceSlice(nodeName, driver).
+				Device("instance-1", map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"card": {IntValue: ptr.To(int64(0))}}).
+				Device("instance-2", map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"card": {IntValue: ptr.To(int64(0))}}).
+				Obj()
+
+	// Node whose hostname label differs from its name, as set up by
+	// some cloud providers.
+	renamedNodeName     = "worker-renamed"
+	renamedNodeHostname = "ip-10-0-0-1"
+	renamedNode         = &st.MakeNode().Name(renamedNodeName).Label("kubernetes.io/hostname", renamedNodeHostname).Node
+
+	// Same node, but in the premium node pool.
+	premiumWorkerNode = &st.MakeNode().Name(nodeName).Label("kubernetes.io/hostname", nodeName).Label("tier", "premium").Node
 
 	// Node with same device, but now with a "healthy" boolean attribute.
 	workerNode2      = &st.MakeNode().Name(node2Name).Label("kubernetes.io/hostname", node2Name).Node
@@ -158,6 +246,21 @@ var (
 	allocatedClaim = st.FromResourceClaim(pendingClaim).
 			Allocation(allocationResult).
 			Obj()
+	allocationResultWithClassConfig = func() *resourceapi.AllocationResult {
+		allocation := allocationResult.DeepCopy()
+		allocation.Devices.Config = []resourceapi.DeviceAllocationConfiguration{{
+			Source:              resourceapi.AllocationConfigSourceClass,
+			Requests:            []string{allocation.Devices.Results[0].Request},
+			DeviceConfiguration: deviceConfig,
+		}}
+		return allocation
+	}()
+	allocatedClaimWithClassConfig = st.FromResourceClaim(allocatedClaim).
+					Allocation(allocationResultWithClassConfig).
+					Obj()
+	inUseClaimWithClassConfig = st.FromResourceClaim(inUseClaim).
+					Allocation(allocationResultWithClassConfig).
+					Obj()
 
 	allocatedClaimWithWrongTopology = st.FromResourceClaim(allocatedClaim).
 					Allocation(&resourceapi.AllocationResult{Controller: controller, NodeSelector: st.MakeNodeSelector().In("no-such-label", []string{"no-such-value"}).Obj()}).
@@ -174,6 +277,22 @@ var (
 				Allocation(allocationResult).
 				Obj()
 
+	// A consumer which is not a pod. The plugin must treat it as opaque.
+	otherConsumer = resourceapi.ResourceClaimConsumerReference{
+		APIGroup: "example.com",
+		Resource: "widgets",
+		Name:     "my-widget",
+		UID:      types.UID("widget-uid"),
+	}
+
+	// Some other pod which shares a claim, for example because it is
+	// being deleted and its replacement got created with the same name.
+	otherPodConsumer = resourceapi.ResourceClaimConsumerReference{
+		Resource: "pods",
+		Name:     podName,
+		UID:      types.UID("other-pod-uid"),
+	}
+
 	scheduling = st.MakePodSchedulingContexts().Name(podName).Namespace(namespace).
 			OwnerReference(podName, podUID, podKind).
 			Obj()
@@ -184,9 +303,88 @@ var (
 					SelectedNode(workerNode.Name).
 					Obj()
 	schedulingInfo = st.FromPodSchedulingContexts(schedulingPotential).
-			ResourceClaims(resourceapi.ResourceClaimSchedulingStatus{Name: resourceName},
+			ResourceClaims(resourceapi.ResourceClaimSchedulingStatus{Name: resourceName, UnsuitableNodes: []string{node2Name}},
 			resourceapi.ResourceClaimSchedulingStatus{Name: resourceName2}).
 		Obj()
+
+	// Time of the plugin clock in scenarios which depend on it.
+	testTime = time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	// Reserved until some time after testTime.
+	structuredReservedClaimWithDeadline = func() *resourceapi.ResourceClaim {
+		claim := reserve(structuredClaim(allocatedClaim), podWithClaimName)
+		claim.Status.ReservedFor[0].ReservationDeadline = &metav1.Time{Time: testTime.Add(reservationTimeout)}
+		return claim
+	}()
+	// Slices whose heartbeat or maintenance window depend on testTime.
+	heartbeatSlice = func() *resourceapi.ResourceSlice {
+		slice := workerNodeSlice.DeepCopy()
+		slice.Annotations = map[string]string{AnnotationResourceSliceHeartbeat: testTime.Format(time.RFC3339)}
+		return slice
+	}()
+	maintenanceSlice = func() *resourceapi.ResourceSlice {
+		slice := workerNodeSlice.DeepCopy()
+		slice.Annotations = map[string]string{AnnotationResourceSliceMaintenanceWindow: testTime.Format(time.RFC3339) + "/" + testTime.Add(time.Hour).Format(time.RFC3339)}
+		return slice
+	}()
+
+	// Only selects devices of the same team as the pod.
+	podLabelsClaim = func() *resourceapi.ResourceClaim {
+		claim := structuredClaim(pendingClaim)
+		claim.Spec.Devices.Requests[0].Selectors = []resourceapi.DeviceSelector{{
+			CEL: &resourceapi.CELDeviceSelector{
+				Expression: fmt.Sprintf(`"team" in pod.labels && pod.labels["team"] == device.attributes["%s"].team`, driver),
+			},
+		}}
+		return claim
+	}()
+	teamSlice = st.MakeResourceSlice(nodeName, driver).
+			Device("instance-1", map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"team": {StringValue: ptr.To("a")}}).
+			Obj()
+
+	// Requires two devices of the same model.
+	sameModelClaim = func() *resourceapi.ResourceClaim {
+		claim := structuredClaim(withDeviceCount(pendingClaim, 2))
+		claim.Spec.Devices.Constraints = []resourceapi.DeviceConstraint{{MatchAttribute: ptr.To(resourceapi.FullyQualifiedName(driver + "/model"))}}
+		return claim
+	}()
+	mixedModelSlice = st.MakeResourceSlice(nodeName, driver).Device("instance-1", modelAttributes("a100")).Device("instance-2", modelAttributes("h100")).Obj()
+	sameModelSlice  = st.MakeResourceSlice(node2Name, driver).Device("instance-1", modelAttributes("a100")).Device("instance-2", modelAttributes("a100")).Obj()
+
+	// Uses a CEL function from the regex group.
+	regexDeviceClass = func() *resourceapi.DeviceClass {
+		class := deviceClass.DeepCopy()
+		class.Spec.Selectors = []resourceapi.DeviceSelector{{CEL: &resourceapi.CELDeviceSelector{Expression: `device.driver.matches("^some-")`}}}
+		return class
+	}()
+	terminatingDeviceClass = func() *resourceapi.DeviceClass {
+		class := deviceClass.DeepCopy()
+		class.DeletionTimestamp = &metav1.Time{Time: testTime}
+		return class
+	}()
+
+	// The template from which pendingClaim was generated, and a claim
+	// which no longer matches it.
+	claimTemplate = &resourceapi.ResourceClaimTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: claimName, Namespace: namespace},
+		Spec: resourceapi.ResourceClaimTemplateSpec{
+			Spec: *structuredClaim(pendingClaim).Spec.DeepCopy(),
+		},
+	}
+	editedClaim = func() *resourceapi.ResourceClaim {
+		claim := structuredClaim(pendingClaim)
+		claim.Spec.Devices.Requests[0].Selectors = []resourceapi.DeviceSelector{{CEL: &resourceapi.CELDeviceSelector{Expression: "true"}}}
+		return claim
+	}()
+
+	deviceTaint  = v1.Taint{Key: "example.com/unhealthy", Effect: v1.TaintEffectNoSchedule}
+	taintedSlice = st.MakeResourceSlice(nodeName, driver).
+			DeviceWithTaints("instance-1", deviceTaint).
+			Obj()
+	podWithTaintToleration = st.MakePod().Name(podName).Namespace(namespace).
+				UID(podUID).
+				PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: &claimName}).
+				Toleration(deviceTaint.Key).
+				Obj()
 )
 
 func reserve(claim *resourceapi.ResourceClaim, pod *v1.Pod) *resourceapi.ResourceClaim {
@@ -201,6 +399,13 @@ func structuredClaim(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClai
 		Obj()
 }
 
+// deletingClaim returns a copy of the claim with a deletion timestamp.
+func deletingClaim(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+	claim = claim.DeepCopy()
+	claim.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	return claim
+}
+
 func breakCELInClaim(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
 	claim = claim.DeepCopy()
 	for i := range claim.Spec.Devices.Requests {
@@ -214,6 +419,24 @@ func breakCELInClaim(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClai
 	return claim
 }
 
+func withDeviceCount(claim *resourceapi.ResourceClaim, count int64) *resourceapi.ResourceClaim {
+	claim = claim.DeepCopy()
+	for i := range claim.Spec.Devices.Requests {
+		claim.Spec.Devices.Requests[i].Count = count
+	}
+	return claim
+}
+
+// withImpossibleRequest returns a copy of the claim with an additional
+// request which no device can satisfy.
+func withImpossibleRequest(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+	claim = st.FromResourceClaim(claim).Request(className).Obj()
+	claim.Spec.Devices.Requests[len(claim.Spec.Devices.Requests)-1].Selectors = []resourceapi.DeviceSelector{{
+		CEL: &resourceapi.CELDeviceSelector{Expression: `device.driver == "no-such-driver"`},
+	}}
+	return claim
+}
+
 func breakCELInClass(class *resourceapi.DeviceClass) *resourceapi.DeviceClass {
 	class = class.DeepCopy()
 	for i := range class.Spec.Selectors {
@@ -226,6 +449,98 @@ func breakCELInClass(class *resourceapi.DeviceClass) *resourceapi.DeviceClass {
 	return class
 }
 
+// withKeepAllocation returns a copy of the claim with AnnotationKeepAllocation.
+func withKeepAllocation(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+	claim = claim.DeepCopy()
+	if claim.Annotations == nil {
+		claim.Annotations = make(map[string]string)
+	}
+	claim.Annotations[AnnotationKeepAllocation] = "true"
+	return claim
+}
+
+// allocatedOnNode returns a copy of the pending claim, allocated with
+// structured parameters for the devices in the pool of the node.
+func allocatedOnNode(claim *resourceapi.ResourceClaim, nodeName string, devices ...string) *resourceapi.ResourceClaim {
+	allocation := allocationResult.DeepCopy()
+	allocation.Devices.Results = nil
+	for _, device := range devices {
+		allocation.Devices.Results = append(allocation.Devices.Results, resourceapi.DeviceRequestAllocationResult{
+			Driver:  driver,
+			Pool:    nodeName,
+			Device:  device,
+			Request: "req-1",
+		})
+	}
+	allocation.NodeSelector.NodeSelectorTerms[0].MatchFields[0].Values = []string{nodeName}
+	return structuredClaim(st.FromResourceClaim(claim).Allocation(allocation).Obj())
+}
+
+// withClaimStatus returns a change which copies the finalizers and the
+// status of the given claim into the stored claim with the same name.
+func withClaimStatus(expected *resourceapi.ResourceClaim) func(*resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+	return func(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+		if claim.Namespace != expected.Namespace || claim.Name != expected.Name {
+			return claim
+		}
+		claim = claim.DeepCopy()
+		claim.Finalizers = expected.Finalizers
+		claim.Status = *expected.Status.DeepCopy()
+		return claim
+	}
+}
+
+// withDeviceIndices returns a copy of the claim where the first
+// consumer has the given device indices.
+func withDeviceIndices(claim *resourceapi.ResourceClaim, indices ...int32) *resourceapi.ResourceClaim {
+	claim = claim.DeepCopy()
+	claim.Status.ReservedFor[0].DeviceIndices = indices
+	return claim
+}
+
+// allocatedWithNodeSelector returns a copy of allocatedClaim with
+// structured parameters and a different node selector.
+func allocatedWithNodeSelector(nodeSelector *v1.NodeSelector) *resourceapi.ResourceClaim {
+	allocation := allocationResult.DeepCopy()
+	allocation.NodeSelector = nodeSelector
+	return structuredClaim(st.FromResourceClaim(pendingClaim).Allocation(allocation).Obj())
+}
+
+// pinnedClaim returns a copy of pendingClaim with structured parameters
+// which is pinned to the node.
+func pinnedClaim(nodeName string) *resourceapi.ResourceClaim {
+	claim := structuredClaim(pendingClaim)
+	claim.Annotations = map[string]string{AnnotationPinnedNode: nodeName}
+	return claim
+}
+
+// classWithDriver returns a copy of deviceClass which selects an
+// attribute of the driver.
+func classWithDriver(driver string) *resourceapi.DeviceClass {
+	class := deviceClass.DeepCopy()
+	class.Spec.Selectors = []resourceapi.DeviceSelector{{
+		CEL: &resourceapi.CELDeviceSelector{Expression: fmt.Sprintf(`device.attributes[%q].%s`, driver, attrName)},
+	}}
+	return class
+}
+
+func modelAttributes(name string) map[resourceapi.QualifiedName]resourceapi.DeviceAttribute {
+	return map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"model": {StringValue: ptr.To(name)}}
+}
+
+// withPodCondition returns a change which sets the ResourceClaimsReady
+// condition of the pod, or removes it if the condition is nil.
+func withPodCondition(condition *v1.PodCondition) func(*v1.Pod) *v1.Pod {
+	return func(pod *v1.Pod) *v1.Pod {
+		pod = pod.DeepCopy()
+		pod.Status.Conditions = nil
+		if condition != nil {
+			pod.Status.Conditions = []v1.PodCondition{*condition}
+		}
+		return pod
+	}
+}
+
 // result defines the expected outcome of some operation. It covers
 // operation's status and the state of the world (= objects).
 type result struct {
@@ -256,6 +571,7 @@ type result struct {
 type change struct {
 	scheduling func(*resourceapi.PodSchedulingContext) *resourceapi.PodSchedulingContext
 	claim      func(*resourceapi.ResourceClaim) *resourceapi.ResourceClaim
+	pod        func(*v1.Pod) *v1.Pod
 }
 type perNodeResult map[string]result
 
@@ -286,6 +602,17 @@ type want struct {
 	// unreserveBeforePreBind, if set, triggers a call to Unreserve
 	// before PreBind, as if the some other PreBind plugin had failed.
 	unreserveBeforePreBind *result
+
+	// events are the events emitted during the cycle, as formatted
+	// by events.FakeRecorder.
+	events []string
+}
+
+// cycle defines the preparation and expected outcome of one additional
+// scheduling cycle.
+type cycle struct {
+	prepare prepare
+	want    want
 }
 
 // prepare contains changes for objects in the API server.
@@ -317,10 +644,29 @@ func TestPlugin(t *testing.T) {
 		prepare prepare
 		want    want
 
+		// furtherCycles get executed after the first scheduling cycle
+		// for the same pod, each with a new CycleState. The informers
+		// keep running, so the plugin may or may not have seen its
+		// own changes from the previous cycle yet.
+		furtherCycles []cycle
+
 		// Feature gates. False is chosen so that the uncommon case
 		// doesn't need to be set.
 		disableDRA        bool
 		disableClassicDRA bool
+
+		// features enables additional feature gates.
+		features feature.Features
+
+		// args modifies the default plugin arguments.
+		args func(args *config.DynamicResourcesArgs)
+
+		// now is the time of the plugin clock. The clock doesn't
+		// advance while the test runs.
+		now time.Time
+
+		// options get passed to NewWithDependencies.
+		options []Option
 	}{
 		"empty": {
 			pod: st.MakePod().Name("foo").Namespace("default").Obj(),
@@ -367,6 +713,28 @@ func TestPlugin(t *testing.T) {
 				},
 			},
 		},
+		"claim-reference-owned-by-other": {
+			// A claim referenced by name is not created for the pod,
+			// so it may be owned by something else. Only claims
+			// generated from a template must be owned by the pod.
+			pod: podWithClaimName,
+			claims: func() []*resourceapi.ResourceClaim {
+				claim := structuredClaim(allocatedClaim).DeepCopy()
+				claim.OwnerReferences[0].UID += "123"
+				return []*resourceapi.ResourceClaim{claim}
+			}(),
+			want: want{
+				prebind: result{
+					changes: change{
+						claim: func(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+							claim = claim.DeepCopy()
+							claim.Status.ReservedFor = inUseClaim.Status.ReservedFor
+							return claim
+						},
+					},
+				},
+			},
+		},
 		"claim-template": {
 			pod:    podWithClaimTemplateInStatus,
 			claims: []*resourceapi.ResourceClaim{allocatedClaim, otherClaim},
@@ -423,6 +791,34 @@ func TestPlugin(t *testing.T) {
 				},
 			},
 		},
+		"deleted-claim-unallocated": {
+			// The finalizer keeps the claim alive, but only for
+			// deallocating it.
+			pod: podWithClaimName,
+			claims: func() []*resourceapi.ResourceClaim {
+				claim := deletingClaim(structuredClaim(pendingClaim))
+				claim.Finalizers = append(claim.Finalizers, resourceapi.Finalizer)
+				return []*resourceapi.ResourceClaim{claim}
+			}(),
+			want: want{
+				preenqueue: result{
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim "my-pod-my-resource" is being deleted`),
+				},
+			},
+		},
+		"deleted-claim-allocated": {
+			// The claim survives because of the finalizer, but
+			// cannot be reserved for another pod.
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{deletingClaim(structuredClaim(allocatedClaim))},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice},
+			want: want{
+				prebind: result{
+					status: framework.AsStatus(errors.New(`pod default/my-pod: claim default/my-pod-my-resource: node worker: claim is being deleted, cannot reserve it for a new consumer`)),
+				},
+			},
+		},
 		"wrong-claim": {
 			pod: podWithClaimTemplateInStatus,
 			claims: func() []*resourceapi.ResourceClaim {
@@ -437,13 +833,144 @@ func TestPlugin(t *testing.T) {
 			},
 		},
 		"structured-no-resources": {
+			// Without any devices in the cluster, the claim gets
+			// rejected without trying the nodes.
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{podWithClaimName},
+			want: want{
+				prefilter: result{
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `requested 1 devices of class my-resource-class but only 0 exist in the cluster`),
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+					changes: change{
+						pod: withPodCondition(&v1.PodCondition{
+							Type:    PodConditionResourceClaimsReady,
+							Status:  v1.ConditionFalse,
+							Reason:  PodReasonNoDevicesAvailable,
+							Message: "claim " + claimName + ": requested 1 devices of class my-resource-class but only 0 exist in the cluster",
+						}),
+					},
+				},
+			},
+		},
+		"structured-too-many-devices": {
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(withDeviceCount(pendingClaim, 3))},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice, workerNode2Slice},
+			want: want{
+				prefilter: result{
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `requested 3 devices of class my-resource-class but only 2 exist in the cluster`),
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+				},
+			},
+		},
+		"structured-no-matching-resources": {
+			// Devices exist, but none of them has the attribute
+			// that the claim asks for.
+			pod: podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{func() *resourceapi.ResourceClaim {
+				claim := structuredClaim(pendingClaim)
+				claim.Spec.Devices.Requests[0].Selectors = []resourceapi.DeviceSelector{{
+					CEL: &resourceapi.CELDeviceSelector{
+						Expression: fmt.Sprintf(`"noSuchAttribute" in device.attributes["%s"]`, driver),
+					},
+				}}
+				return claim
+			}()},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice, workerNode2Slice},
+			want: want{
+				prefilter: result{
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `no resource slices can satisfy claim`),
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+				},
+			},
+		},
+		"structured-with-resources": {
 			pod:     podWithClaimName,
 			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
 			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice, podWithClaimName},
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						claim: func(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+							if claim.Name == claimName {
+								claim = claim.DeepCopy()
+								claim.Finalizers = structuredClaim(allocatedClaim).Finalizers
+								claim.Status = structuredClaim(inUseClaim).Status
+							}
+							return claim
+						},
+						pod: withPodCondition(&v1.PodCondition{
+							Type:    PodConditionResourceClaimsReady,
+							Status:  v1.ConditionTrue,
+							Reason:  PodReasonDevicesAllocated,
+							Message: "all claims are allocated and reserved, binding the pod",
+						}),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						pod: withPodCondition(nil),
+					},
+				},
+			},
+		},
+		"structured-with-class-config": {
+			// The configuration of the class gets copied into the
+			// allocation result, both in the assume cache and in
+			// the claim in the apiserver.
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{configuredDeviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice},
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaimWithClassConfig),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaimWithClassConfig), podWithClaimName),
+					changes: change{
+						claim: func(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+							if claim.Name == claimName {
+								claim = claim.DeepCopy()
+								claim.Finalizers = structuredClaim(allocatedClaimWithClassConfig).Finalizers
+								claim.Status = structuredClaim(inUseClaimWithClassConfig).Status
+							}
+							return claim
+						},
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaimWithClassConfig), podWithClaimName),
+				},
+			},
+		},
+		"structured-class-not-suitable-for-node": {
+			// The node has a matching device, but the class
+			// is restricted to premium nodes.
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{premiumDeviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice},
 			want: want{
 				filter: perNodeResult{
 					workerNode.Name: {
-						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `cannot allocate all claims`),
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `excluded by device class node filter`),
 					},
 				},
 				postfilter: result{
@@ -451,10 +978,11 @@ func TestPlugin(t *testing.T) {
 				},
 			},
 		},
-		"structured-with-resources": {
+		"structured-class-suitable-for-node": {
+			nodes:   []*v1.Node{premiumWorkerNode},
 			pod:     podWithClaimName,
 			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
-			classes: []*resourceapi.DeviceClass{deviceClass},
+			classes: []*resourceapi.DeviceClass{premiumDeviceClass},
 			objs:    []apiruntime.Object{workerNodeSlice},
 			want: want{
 				reserve: result{
@@ -524,6 +1052,7 @@ func TestPlugin(t *testing.T) {
 			prepare: prepare{
 				prebind: change{
 					claim: func(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+						claim = claim.DeepCopy()
 						claim.Finalizers = nil
 						return claim
 					},
@@ -549,6 +1078,7 @@ func TestPlugin(t *testing.T) {
 				postbind: result{
 					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
 				},
+				events: []string{v1.EventTypeWarning + " " + ReasonFinalizerReAdded + " ResourceClaim finalizer was removed during scheduling; re-adding"},
 			},
 		},
 		"structured-with-resources-finalizer-gets-added": {
@@ -599,6 +1129,26 @@ func TestPlugin(t *testing.T) {
 				unreserveBeforePreBind: &result{},
 			},
 		},
+		"scheduling-skip-bind": {
+			// Reserve selects a different node than the one from an
+			// earlier scheduling attempt, but binding is not going to
+			// happen. Neither node may remain selected.
+			pod:         podWithClaimName,
+			claims:      []*resourceapi.ResourceClaim{pendingClaim},
+			schedulings: []*resourceapi.PodSchedulingContext{st.FromPodSchedulingContexts(schedulingInfo).SelectedNode("other-node").Obj()},
+			classes:     []*resourceapi.DeviceClass{deviceClass},
+			want: want{
+				unreserveBeforePreBind: &result{
+					changes: change{
+						scheduling: func(in *resourceapi.PodSchedulingContext) *resourceapi.PodSchedulingContext {
+							return st.FromPodSchedulingContexts(in).
+								SelectedNode("").
+								Obj()
+						},
+					},
+				},
+			},
+		},
 		"structured-exhausted-resources": {
 			pod:     podWithClaimName,
 			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim), structuredClaim(otherAllocatedClaim)},
@@ -607,7 +1157,7 @@ func TestPlugin(t *testing.T) {
 			want: want{
 				filter: perNodeResult{
 					workerNode.Name: {
-						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `cannot allocate all claims`),
+						status: framework.NewStatus(framework.Unschedulable, "cannot allocate all claims: claim "+claimName+": request req-1 unsatisfiable (not enough free devices)"),
 					},
 				},
 				postfilter: result{
@@ -615,71 +1165,129 @@ func TestPlugin(t *testing.T) {
 				},
 			},
 		},
-
-		"claim-parameters-CEL-runtime-error": {
+		"structured-unsatisfiable-request": {
+			// The first request could get the device, but
+			// nothing matches the second one.
 			pod:     podWithClaimName,
-			claims:  []*resourceapi.ResourceClaim{breakCELInClaim(structuredClaim(pendingClaim))},
+			claims:  []*resourceapi.ResourceClaim{withImpossibleRequest(structuredClaim(pendingClaim))},
 			classes: []*resourceapi.DeviceClass{deviceClass},
 			objs:    []apiruntime.Object{workerNodeSlice},
 			want: want{
-				filter: perNodeResult{
-					workerNode.Name: {
-						status: framework.AsStatus(errors.New(`claim default/my-pod-my-resource: selector #0: CEL runtime error: no such key: ` + string(attrName))),
-					},
+				prefilter: result{
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, "no resource slices can satisfy claim"),
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
 				},
 			},
 		},
-
-		"class-parameters-CEL-runtime-error": {
-			pod:     podWithClaimName,
-			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
-			classes: []*resourceapi.DeviceClass{breakCELInClass(deviceClass)},
-			objs:    []apiruntime.Object{workerNodeSlice},
+		"structured-device-anti-affinity": {
+			// The only free device is on the same card as the
+			// device of the other pod.
+			pod:     podWithDeviceAntiAffinity,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim), st.FromResourceClaim(structuredClaim(otherAllocatedClaim)).ReservedForPod(otherPodName, otherPodWithClaimName.UID).Obj()},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeCardSlice, otherPodWithClaimName},
 			want: want{
 				filter: perNodeResult{
 					workerNode.Name: {
-						status: framework.AsStatus(errors.New(`class my-resource-class: selector #0: CEL runtime error: no such key: ` + string(attrName))),
+						status: framework.NewStatus(framework.Unschedulable, "cannot allocate all claims: claim "+claimName+": request req-1 unsatisfiable (no matching device)"),
 					},
 				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `still not schedulable`),
+				},
 			},
 		},
-
-		// When pod scheduling encounters CEL runtime errors for some nodes, but not all,
-		// it should still not schedule the pod because there is something wrong with it.
-		// Scheduling it would make it harder to detect that there is a problem.
+		"structured-device-anti-affinity-without-attribute": {
+			pod: st.MakePod().Name(podName).Namespace(namespace).
+				UID(podUID).
+				PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: &claimName}).
+				Annotation(AnnotationDeviceAntiAffinityPods, otherPodName).
+				Obj(),
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeCardSlice},
+			want: want{
+				prefilter: result{
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, fmt.Sprintf("annotation %s is required when %s is set", AnnotationDeviceAntiAffinityAttribute, AnnotationDeviceAntiAffinityPods)),
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+				},
+			},
+		},
+		"structured-reserved-for-other-consumer": {
+			// The only device is allocated for a claim which is in use
+			// by something other than a pod. It's not available.
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim), st.FromResourceClaim(structuredClaim(otherAllocatedClaim)).ReservedFor(otherConsumer).Obj()},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice},
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.Unschedulable, "cannot allocate all claims: claim "+claimName+": request req-1 unsatisfiable (not enough free devices)"),
+					},
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `still not schedulable`),
+				},
+			},
+		},
+		"structured-shared-with-other-consumer": {
+			// The pod gets added alongside the existing consumer.
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{st.FromResourceClaim(structuredClaim(allocatedClaim)).ReservedFor(otherConsumer).Obj()},
+			want: want{
+				prebind: result{
+					changes: change{
+						claim: func(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+							if claim.Name == claimName {
+								claim = claim.DeepCopy()
+								claim.Status.ReservedFor = append(claim.Status.ReservedFor, inUseClaim.Status.ReservedFor...)
+							}
+							return claim
+						},
+					},
+				},
+			},
+		},
+
+		// An error on all nodes keeps the pod pending because there is
+		// something wrong with the claim.
 		//
 		// This matches the "keeps pod pending because of CEL runtime errors" E2E test.
-		"CEL-runtime-error-for-one-of-two-nodes": {
-			nodes:   []*v1.Node{workerNode, workerNode2},
+		"claim-parameters-CEL-runtime-error": {
 			pod:     podWithClaimName,
 			claims:  []*resourceapi.ResourceClaim{breakCELInClaim(structuredClaim(pendingClaim))},
 			classes: []*resourceapi.DeviceClass{deviceClass},
-			objs:    []apiruntime.Object{workerNodeSlice, workerNode2Slice},
+			objs:    []apiruntime.Object{workerNodeSlice},
 			want: want{
 				filter: perNodeResult{
 					workerNode.Name: {
-						status: framework.AsStatus(errors.New(`claim default/my-pod-my-resource: selector #0: CEL runtime error: no such key: ` + string(attrName))),
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `claim default/my-pod-my-resource: request req-1: selector #0: CEL runtime error: no such key: `+string(attrName)),
 					},
 				},
+				postfilter: result{
+					status: framework.AsStatus(errors.New(`pod default/my-pod: claim default/my-pod-my-resource: request req-1: selector #0: CEL runtime error: no such key: ` + string(attrName))),
+				},
 			},
 		},
 
-		// When two nodes where found, PreScore gets called.
-		"CEL-runtime-error-for-one-of-three-nodes": {
-			nodes:   []*v1.Node{workerNode, workerNode2, workerNode3},
+		"class-parameters-CEL-runtime-error": {
 			pod:     podWithClaimName,
-			claims:  []*resourceapi.ResourceClaim{breakCELInClaim(structuredClaim(pendingClaim))},
-			classes: []*resourceapi.DeviceClass{deviceClass},
-			objs:    []apiruntime.Object{workerNodeSlice, workerNode2Slice, workerNode3Slice},
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{breakCELInClass(deviceClass)},
+			objs:    []apiruntime.Object{workerNodeSlice},
 			want: want{
 				filter: perNodeResult{
 					workerNode.Name: {
-						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `claim default/my-pod-my-resource: selector #0: CEL runtime error: no such key: `+string(attrName)),
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `claim default/my-pod-my-resource: request req-1: class my-resource-class: selector #0: CEL runtime error: no such key: `+string(attrName)),
 					},
 				},
-				prescore: result{
-					// This is the error found during Filter.
-					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `filter node worker: claim default/my-pod-my-resource: selector #0: CEL runtime error: no such key: healthy`),
+				postfilter: result{
+					status: framework.AsStatus(errors.New(`pod default/my-pod: claim default/my-pod-my-resource: request req-1: class my-resource-class: selector #0: CEL runtime error: no such key: ` + string(attrName))),
 				},
 			},
 		},
@@ -687,12 +1295,21 @@ func TestPlugin(t *testing.T) {
 		"waiting-for-deallocation": {
 			pod:    podWithClaimName,
 			claims: []*resourceapi.ResourceClaim{deallocatingClaim},
+			objs:   []apiruntime.Object{podWithClaimName},
 			want: want{
 				prefilter: result{
 					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim must be reallocated`),
 				},
 				postfilter: result{
 					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+					changes: change{
+						pod: withPodCondition(&v1.PodCondition{
+							Type:    PodConditionResourceClaimsReady,
+							Status:  v1.ConditionFalse,
+							Reason:  PodReasonWaitingForDeallocation,
+							Message: "waiting for resource driver to deallocate claim " + claimName,
+						}),
+					},
 				},
 			},
 		},
@@ -701,7 +1318,7 @@ func TestPlugin(t *testing.T) {
 			claims: []*resourceapi.ResourceClaim{pendingClaim},
 			want: want{
 				prefilter: result{
-					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, fmt.Sprintf("request req-1: device class %s does not exist", className)),
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, fmt.Sprintf("claim %s/%s: request req-1: class %s: does not exist", namespace, claimName, className)),
 				},
 				postfilter: result{
 					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
@@ -728,10 +1345,19 @@ func TestPlugin(t *testing.T) {
 			pod:     podWithTwoClaimNames,
 			claims:  []*resourceapi.ResourceClaim{pendingClaim, pendingClaim2},
 			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{podWithTwoClaimNames},
 			want: want{
 				prebind: result{
 					status: framework.NewStatus(framework.Pending, `waiting for resource driver`),
 					added:  []metav1.Object{schedulingPotential},
+					changes: change{
+						pod: withPodCondition(&v1.PodCondition{
+							Type:    PodConditionResourceClaimsReady,
+							Status:  v1.ConditionFalse,
+							Reason:  PodReasonWaitingForDriver,
+							Message: "waiting for resource driver to allocate claim(s) " + claimName + ", " + claimName2,
+						}),
+					},
 				},
 			},
 		},
@@ -755,6 +1381,32 @@ func TestPlugin(t *testing.T) {
 				},
 			},
 		},
+		"scheduling-unsuitable-node": {
+			// The driver already reported one of the nodes as
+			// unsuitable, so Filter must not pick it.
+			nodes:       []*v1.Node{workerNode, workerNode2},
+			pod:         podWithClaimName,
+			claims:      []*resourceapi.ResourceClaim{pendingClaim},
+			schedulings: []*resourceapi.PodSchedulingContext{schedulingInfo},
+			classes:     []*resourceapi.DeviceClass{deviceClass},
+			want: want{
+				filter: perNodeResult{
+					workerNode2.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resource driver reported node as unsuitable`),
+					},
+				},
+				prebind: result{
+					status: framework.NewStatus(framework.Pending, `waiting for resource driver`),
+					changes: change{
+						scheduling: func(in *resourceapi.PodSchedulingContext) *resourceapi.PodSchedulingContext {
+							return st.FromPodSchedulingContexts(in).
+								SelectedNode(workerNode.Name).
+								Obj()
+						},
+					},
+				},
+			},
+		},
 		"scheduling-finish-concurrent-label-update": {
 			// Use the populated PodSchedulingContext object to select a
 			// node.
@@ -781,7 +1433,8 @@ func TestPlugin(t *testing.T) {
 			},
 		},
 		"scheduling-completed": {
-			// Remove PodSchedulingContext object once the pod is scheduled.
+			// Remove the PodSchedulingContext object of an allocated
+			// claim before binding, in case that binding fails.
 			pod:         podWithClaimName,
 			claims:      []*resourceapi.ResourceClaim{allocatedClaim},
 			schedulings: []*resourceapi.PodSchedulingContext{schedulingInfo},
@@ -795,8 +1448,27 @@ func TestPlugin(t *testing.T) {
 								Obj()
 						},
 					},
+					removed: []metav1.Object{schedulingInfo},
 				},
-				postbind: result{
+			},
+		},
+		"scheduling-completed-structured": {
+			// A claim with structured parameters which got allocated
+			// out-of-band while a stale PodSchedulingContext object
+			// remained.
+			pod:         podWithClaimName,
+			claims:      []*resourceapi.ResourceClaim{structuredClaim(allocatedClaim)},
+			schedulings: []*resourceapi.PodSchedulingContext{schedulingInfo},
+			classes:     []*resourceapi.DeviceClass{deviceClass},
+			want: want{
+				prebind: result{
+					changes: change{
+						claim: func(in *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+							return st.FromResourceClaim(in).
+								ReservedFor(resourceapi.ResourceClaimConsumerReference{Resource: "pods", Name: podName, UID: types.UID(podUID)}).
+								Obj()
+						},
+					},
 					removed: []metav1.Object{schedulingInfo},
 				},
 			},
@@ -821,9 +1493,46 @@ func TestPlugin(t *testing.T) {
 								Obj()
 						},
 					},
-					status: framework.NewStatus(framework.Unschedulable, `deallocation of ResourceClaim completed`),
+					assumedClaim: st.FromResourceClaim(allocatedClaimWithWrongTopology).DeallocationRequested(true).Obj(),
+					status:       framework.NewStatus(framework.Unschedulable, `deallocation of ResourceClaim completed`),
+				},
+			},
+			furtherCycles: []cycle{{
+				// The next attempt waits for the driver without
+				// requesting deallocation again.
+				want: want{
+					preenqueue: result{
+						assumedClaim: st.FromResourceClaim(allocatedClaimWithWrongTopology).DeallocationRequested(true).Obj(),
+					},
+					prefilter: result{
+						assumedClaim: st.FromResourceClaim(allocatedClaimWithWrongTopology).DeallocationRequested(true).Obj(),
+						status:       framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim must be reallocated`),
+					},
+					postfilter: result{
+						assumedClaim: st.FromResourceClaim(allocatedClaimWithWrongTopology).DeallocationRequested(true).Obj(),
+						status:       framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+					},
+				},
+			}},
+		},
+		"wrong-topology-no-deallocation": {
+			// PostFilter must not touch the claim when
+			// deallocation is disabled.
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{allocatedClaimWithWrongTopology},
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim not available on the node`),
+					},
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `deallocation of ResourceClaims is disabled`),
 				},
 			},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.EnablePostFilterDeallocation = false
+			},
 		},
 		"wrong-topology-structured": {
 			// PostFilter tries to get the pod scheduleable by
@@ -845,7 +1554,72 @@ func TestPlugin(t *testing.T) {
 								Obj()
 						},
 					},
-					status: framework.NewStatus(framework.Unschedulable, `deallocation of ResourceClaim completed`),
+					assumedClaim: st.FromResourceClaim(structuredClaim(allocatedClaimWithWrongTopology)).Allocation(nil).Obj(),
+					status:       framework.NewStatus(framework.Unschedulable, `deallocation of ResourceClaim completed`),
+				},
+			},
+			furtherCycles: []cycle{{
+				// The next attempt sees the claim as deallocated and
+				// tries to allocate it, which fails because there is
+				// no class. Nothing gets written.
+				want: want{
+					preenqueue: result{
+						assumedClaim: st.FromResourceClaim(structuredClaim(allocatedClaimWithWrongTopology)).Allocation(nil).Obj(),
+					},
+					prefilter: result{
+						assumedClaim: st.FromResourceClaim(structuredClaim(allocatedClaimWithWrongTopology)).Allocation(nil).Obj(),
+						status:       framework.NewStatus(framework.UnschedulableAndUnresolvable, fmt.Sprintf("claim %s/%s: request req-1: class %s: does not exist", namespace, claimName, className)),
+					},
+					postfilter: result{
+						assumedClaim: st.FromResourceClaim(structuredClaim(allocatedClaimWithWrongTopology)).Allocation(nil).Obj(),
+						status:       framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+					},
+				},
+			}},
+		},
+		"wrong-topology-keep-allocation": {
+			// The claim opts out of deallocation, so the pod
+			// waits for a matching node.
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{withKeepAllocation(allocatedClaimWithWrongTopology)},
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim not available on the node`),
+					},
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `waiting for a node matching the existing allocation of claim `+claimName),
+				},
+			},
+		},
+		"wrong-topology-structured-keep-allocation": {
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{withKeepAllocation(structuredClaim(allocatedClaimWithWrongTopology))},
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim not available on the node`),
+					},
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `waiting for a node matching the existing allocation of claim `+claimName),
+				},
+			},
+		},
+		"wrong-topology-other-consumer": {
+			// PostFilter must not deallocate a claim which is
+			// in use by something other than a pod.
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{st.FromResourceClaim(structuredClaim(allocatedClaimWithWrongTopology)).ReservedFor(otherConsumer).Obj()},
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim not available on the node`),
+					},
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `still not schedulable`),
 				},
 			},
 		},
@@ -880,6 +1654,8 @@ func TestPlugin(t *testing.T) {
 				unreserveAfterBindFailure: &result{
 					changes: change{
 						claim: func(in *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+							// The pod was the only consumer, so the
+							// patch leaves an empty list behind.
 							out := in.DeepCopy()
 							out.Status.ReservedFor = []resourceapi.ResourceClaimConsumerReference{}
 							return out
@@ -888,6 +1664,56 @@ func TestPlugin(t *testing.T) {
 				},
 			},
 		},
+		"bind-failure-shared-with-terminating-pod": {
+			// The other pod has the same name and only differs in its UID.
+			// Its entry must survive when the pod gets unreserved.
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{st.FromResourceClaim(allocatedClaimWithGoodTopology).ReservedFor(otherPodConsumer).Obj()},
+			want: want{
+				prebind: result{
+					changes: change{
+						claim: func(in *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+							return st.FromResourceClaim(in).
+								ReservedFor(otherPodConsumer, resourceapi.ResourceClaimConsumerReference{Resource: "pods", Name: podName, UID: types.UID(podUID)}).
+								Obj()
+						},
+					},
+				},
+				unreserveAfterBindFailure: &result{
+					changes: change{
+						claim: func(in *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+							return st.FromResourceClaim(in).
+								ReservedFor(otherPodConsumer).
+								Obj()
+						},
+					},
+				},
+			},
+		},
+		"bind-failure-structured-shared": {
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{st.FromResourceClaim(structuredClaim(allocatedClaimWithGoodTopology)).ReservedFor(otherConsumer).Obj()},
+			want: want{
+				prebind: result{
+					changes: change{
+						claim: func(in *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+							return st.FromResourceClaim(in).
+								ReservedFor(otherConsumer, resourceapi.ResourceClaimConsumerReference{Resource: "pods", Name: podName, UID: types.UID(podUID)}).
+								Obj()
+						},
+					},
+				},
+				unreserveAfterBindFailure: &result{
+					changes: change{
+						claim: func(in *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+							return st.FromResourceClaim(in).
+								ReservedFor(otherConsumer).
+								Obj()
+						},
+					},
+				},
+			},
+		},
 		"bind-failure-structured": {
 			pod:    podWithClaimName,
 			claims: []*resourceapi.ResourceClaim{structuredClaim(allocatedClaimWithGoodTopology)},
@@ -912,456 +1738,4401 @@ func TestPlugin(t *testing.T) {
 				},
 			},
 		},
-		"reserved-okay": {
-			pod:    podWithClaimName,
-			claims: []*resourceapi.ResourceClaim{inUseClaim},
+		"structured-with-reservation-deadline": {
+			// The reservation expires some time after PreBind.
+			pod:      podWithClaimName,
+			claims:   []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:  []*resourceapi.DeviceClass{deviceClass},
+			objs:     []apiruntime.Object{workerNodeSlice},
+			features: feature.Features{EnableDRAReservationDeadline: true},
+			now:      testTime,
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: structuredReservedClaimWithDeadline,
+					changes: change{
+						claim: withClaimStatus(structuredReservedClaimWithDeadline),
+					},
+				},
+				postbind: result{
+					assumedClaim: structuredReservedClaimWithDeadline,
+				},
+			},
 		},
-		"DRA-disabled": {
-			pod:    podWithClaimName,
-			claims: []*resourceapi.ResourceClaim{inUseClaim},
+		"structured-with-device-indices": {
+			pod: func() *v1.Pod {
+				pod := podWithClaimName.DeepCopy()
+				pod.Annotations = map[string]string{AnnotationDeviceIndices: `{"` + resourceName + `": [0]}`}
+				return pod
+			}(),
+			claims:   []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:  []*resourceapi.DeviceClass{deviceClass},
+			objs:     []apiruntime.Object{workerNodeSlice},
+			features: feature.Features{EnableDRAReservedDeviceIndices: true},
 			want: want{
-				prefilter: result{
-					status: framework.NewStatus(framework.Skip),
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: withDeviceIndices(reserve(structuredClaim(allocatedClaim), podWithClaimName), 0),
+					changes: change{
+						claim: withClaimStatus(withDeviceIndices(reserve(structuredClaim(allocatedClaim), podWithClaimName), 0)),
+					},
+				},
+				postbind: result{
+					assumedClaim: withDeviceIndices(reserve(structuredClaim(allocatedClaim), podWithClaimName), 0),
 				},
 			},
-			disableDRA: true,
 		},
-	}
-
-	for name, tc := range testcases {
-		// We can run in parallel because logging is per-test.
-		tc := tc
-		t.Run(name, func(t *testing.T) {
-			t.Parallel()
-			nodes := tc.nodes
-			if nodes == nil {
-				nodes = []*v1.Node{workerNode}
-			}
-			features := feature.Features{
-				EnableDynamicResourceAllocation: !tc.disableDRA,
-				EnableDRAControlPlaneController: !tc.disableClassicDRA,
-			}
-			testCtx := setup(t, nodes, tc.claims, tc.classes, tc.schedulings, tc.objs, features)
-			initialObjects := testCtx.listAll(t)
-
-			status := testCtx.p.PreEnqueue(testCtx.ctx, tc.pod)
-			t.Run("PreEnqueue", func(t *testing.T) {
-				testCtx.verify(t, tc.want.preenqueue, initialObjects, nil, status)
-			})
-			if !status.IsSuccess() {
-				return
-			}
-
-			result, status := testCtx.p.PreFilter(testCtx.ctx, testCtx.state, tc.pod)
-			t.Run("prefilter", func(t *testing.T) {
-				assert.Equal(t, tc.want.preFilterResult, result)
-				testCtx.verify(t, tc.want.prefilter, initialObjects, result, status)
-			})
-			if status.IsSkip() {
-				return
-			}
-			unschedulable := status.Code() != framework.Success
-
-			var potentialNodes []*framework.NodeInfo
-
-			initialObjects = testCtx.listAll(t)
-			testCtx.updateAPIServer(t, initialObjects, tc.prepare.filter)
-			if !unschedulable {
-				for _, nodeInfo := range testCtx.nodeInfos {
-					initialObjects = testCtx.listAll(t)
-					status := testCtx.p.Filter(testCtx.ctx, testCtx.state, tc.pod, nodeInfo)
-					nodeName := nodeInfo.Node().Name
-					t.Run(fmt.Sprintf("filter/%s", nodeInfo.Node().Name), func(t *testing.T) {
-						testCtx.verify(t, tc.want.filter.forNode(nodeName), initialObjects, nil, status)
-					})
-					if status.Code() == framework.Success {
-						potentialNodes = append(potentialNodes, nodeInfo)
-					}
-					if status.Code() == framework.Error {
-						// An error aborts scheduling.
-						return
-					}
-				}
-				if len(potentialNodes) == 0 {
-					unschedulable = true
-				}
-			}
-
-			if !unschedulable && len(potentialNodes) > 1 {
-				initialObjects = testCtx.listAll(t)
-				initialObjects = testCtx.updateAPIServer(t, initialObjects, tc.prepare.prescore)
-				status := testCtx.p.PreScore(testCtx.ctx, testCtx.state, tc.pod, potentialNodes)
-				t.Run("prescore", func(t *testing.T) {
-					testCtx.verify(t, tc.want.prescore, initialObjects, nil, status)
-				})
-				if status.Code() != framework.Success {
-					unschedulable = true
-				}
-			}
-
-			var selectedNode *framework.NodeInfo
-			if !unschedulable && len(potentialNodes) > 0 {
-				selectedNode = potentialNodes[0]
-
-				initialObjects = testCtx.listAll(t)
-				initialObjects = testCtx.updateAPIServer(t, initialObjects, tc.prepare.reserve)
-				status := testCtx.p.Reserve(testCtx.ctx, testCtx.state, tc.pod, selectedNode.Node().Name)
-				t.Run("reserve", func(t *testing.T) {
-					testCtx.verify(t, tc.want.reserve, initialObjects, nil, status)
-				})
-				if status.Code() != framework.Success {
-					unschedulable = true
-				}
-			}
-
-			if selectedNode != nil {
-				if unschedulable {
-					initialObjects = testCtx.listAll(t)
-					initialObjects = testCtx.updateAPIServer(t, initialObjects, tc.prepare.unreserve)
-					testCtx.p.Unreserve(testCtx.ctx, testCtx.state, tc.pod, selectedNode.Node().Name)
-					t.Run("unreserve", func(t *testing.T) {
-						testCtx.verify(t, tc.want.unreserve, initialObjects, nil, status)
-					})
-				} else {
-					if tc.want.unreserveBeforePreBind != nil {
-						initialObjects = testCtx.listAll(t)
-						testCtx.p.Unreserve(testCtx.ctx, testCtx.state, tc.pod, selectedNode.Node().Name)
-						t.Run("unreserveBeforePreBind", func(t *testing.T) {
-							testCtx.verify(t, *tc.want.unreserveBeforePreBind, initialObjects, nil, status)
-						})
-						return
+		"structured-with-device-indices-disabled": {
+			// The annotation is ignored without the feature gate.
+			pod: func() *v1.Pod {
+				pod := podWithClaimName.DeepCopy()
+				pod.Annotations = map[string]string{AnnotationDeviceIndices: `{"` + resourceName + `": [0]}`}
+				return pod
+			}(),
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice},
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(structuredClaim(allocatedClaim), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+				},
+			},
+		},
+		"structured-device-index-out-of-range": {
+			pod: func() *v1.Pod {
+				pod := podWithClaimName.DeepCopy()
+				pod.Annotations = map[string]string{AnnotationDeviceIndices: `{"` + resourceName + `": [1]}`}
+				return pod
+			}(),
+			claims:   []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:  []*resourceapi.DeviceClass{deviceClass},
+			objs:     []apiruntime.Object{workerNodeSlice},
+			features: feature.Features{EnableDRAReservedDeviceIndices: true},
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					status: framework.AsStatus(errors.New("device index 1 is out of range, the claim has 1 allocated devices")),
+					changes: change{
+						// The finalizer was added before the
+						// device indices got checked.
+						claim: func(claim *resourceapi.ResourceClaim) *resourceapi.ResourceClaim {
+							claim = claim.DeepCopy()
+							claim.Finalizers = structuredClaim(allocatedClaim).Finalizers
+							return claim
+						},
+					},
+				},
+			},
+		},
+		"invalid-device-indices": {
+			pod: func() *v1.Pod {
+				pod := podWithClaimName.DeepCopy()
+				pod.Annotations = map[string]string{AnnotationDeviceIndices: `{"no-such-claim": [0]}`}
+				return pod
+			}(),
+			claims:   []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:  []*resourceapi.DeviceClass{deviceClass},
+			objs:     []apiruntime.Object{workerNodeSlice},
+			features: feature.Features{EnableDRAReservedDeviceIndices: true},
+			want: want{
+				prefilter: result{
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `annotation `+AnnotationDeviceIndices+`: pod has no resource claim "no-such-claim"`),
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+				},
+			},
+		},
+		"claim-parameters-CEL-missing-attribute-excluded": {
+			// The device on workerNode doesn't have the attribute and
+			// is treated as not matching instead of causing an error.
+			nodes:   []*v1.Node{workerNode, workerNode2},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{breakCELInClaim(structuredClaim(pendingClaim))},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice, workerNode2Slice},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.MissingAttributeBehavior = config.ExcludeMissingAttributeBehavior
+			},
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.Unschedulable, ErrReasonCannotAllocate+": claim "+claimName+": request req-1 unsatisfiable (no matching device)"),
+					},
+				},
+				reserve: result{
+					inFlightClaim: allocatedOnNode(breakCELInClaim(structuredClaim(pendingClaim)), node2Name, "instance-1"),
+				},
+				prebind: result{
+					assumedClaim: reserve(allocatedOnNode(breakCELInClaim(structuredClaim(pendingClaim)), node2Name, "instance-1"), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(allocatedOnNode(breakCELInClaim(structuredClaim(pendingClaim)), node2Name, "instance-1"), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(allocatedOnNode(breakCELInClaim(structuredClaim(pendingClaim)), node2Name, "instance-1"), podWithClaimName),
+				},
+			},
+		},
+		"class-parameters-CEL-missing-attribute-excluded": {
+			nodes:   []*v1.Node{workerNode, workerNode2},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{breakCELInClass(deviceClass)},
+			objs:    []apiruntime.Object{workerNodeSlice, workerNode2Slice},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.MissingAttributeBehavior = config.ExcludeMissingAttributeBehavior
+			},
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.Unschedulable, ErrReasonCannotAllocate+": claim "+claimName+": request req-1 unsatisfiable (no matching device)"),
+					},
+				},
+				reserve: result{
+					inFlightClaim: allocatedOnNode(pendingClaim, node2Name, "instance-1"),
+				},
+				prebind: result{
+					assumedClaim: reserve(allocatedOnNode(pendingClaim, node2Name, "instance-1"), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(allocatedOnNode(pendingClaim, node2Name, "instance-1"), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(allocatedOnNode(pendingClaim, node2Name, "instance-1"), podWithClaimName),
+				},
+			},
+		},
+		"class-parameters-CEL-disallowed-function": {
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{regexDeviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.AllowedCELFunctionGroups = []string{"strings"}
+			},
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, "claim default/"+claimName+": request req-1: class "+className+": selector #0: selector uses disallowed function matches"),
+					},
+				},
+				postfilter: result{
+					status: framework.AsStatus(errors.New("pod default/my-pod: claim default/" + claimName + ": request req-1: class " + className + ": selector #0: selector uses disallowed function matches")),
+				},
+			},
+		},
+		"class-parameters-CEL-allowed-function": {
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{regexDeviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.AllowedCELFunctionGroups = []string{"strings", "regex"}
+			},
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(structuredClaim(allocatedClaim), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+				},
+			},
+		},
+		"class-parameters-CEL-unrestricted-functions": {
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{regexDeviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice},
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(structuredClaim(allocatedClaim), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+				},
+			},
+		},
+		"structured-with-resources-patch": {
+			// Same result as with updates.
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.WriteStrategy = config.PatchWriteStrategy
+			},
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(structuredClaim(allocatedClaim), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+				},
+			},
+		},
+		"claim-reference-structured-patch": {
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{structuredClaim(allocatedClaim), otherClaim},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.WriteStrategy = config.PatchWriteStrategy
+			},
+			want: want{
+				prebind: result{
+					changes: change{
+						claim: withClaimStatus(reserve(structuredClaim(allocatedClaim), podWithClaimName)),
+					},
+				},
+			},
+		},
+		"structured-pod-labels-match": {
+			// The claim selects devices with the team of the pod.
+			pod:      st.MakePod().Name(podName).Namespace(namespace).UID(podUID).Label("team", "a").PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: &claimName}).Obj(),
+			claims:   []*resourceapi.ResourceClaim{podLabelsClaim},
+			classes:  []*resourceapi.DeviceClass{deviceClass},
+			objs:     []apiruntime.Object{teamSlice},
+			features: feature.Features{EnableDRAPodLabelSelectors: true},
+			want: want{
+				reserve: result{
+					inFlightClaim: allocatedOnNode(podLabelsClaim, nodeName, "instance-1"),
+				},
+				prebind: result{
+					assumedClaim: reserve(allocatedOnNode(podLabelsClaim, nodeName, "instance-1"), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(allocatedOnNode(podLabelsClaim, nodeName, "instance-1"), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(allocatedOnNode(podLabelsClaim, nodeName, "instance-1"), podWithClaimName),
+				},
+			},
+		},
+		"structured-pod-labels-mismatch": {
+			// PreFilter checks whether any device in the cluster
+			// matches, which also depends on the pod labels.
+			pod:      st.MakePod().Name(podName).Namespace(namespace).UID(podUID).Label("team", "b").PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: &claimName}).Obj(),
+			claims:   []*resourceapi.ResourceClaim{podLabelsClaim},
+			classes:  []*resourceapi.DeviceClass{deviceClass},
+			objs:     []apiruntime.Object{teamSlice},
+			features: feature.Features{EnableDRAPodLabelSelectors: true},
+			want: want{
+				prefilter: result{
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `no resource slices can satisfy claim`),
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+				},
+			},
+		},
+		"structured-same-model": {
+			// Only the devices on the second node are of the same model.
+			nodes:   []*v1.Node{workerNode, workerNode2},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{sameModelClaim},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{mixedModelSlice, sameModelSlice},
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.Unschedulable, ErrReasonCannotAllocate+": claim "+claimName+": requests cannot be satisfied together"),
+					},
+				},
+				reserve: result{
+					inFlightClaim: allocatedOnNode(sameModelClaim, node2Name, "instance-1", "instance-2"),
+				},
+				prebind: result{
+					assumedClaim: reserve(allocatedOnNode(sameModelClaim, node2Name, "instance-1", "instance-2"), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(allocatedOnNode(sameModelClaim, node2Name, "instance-1", "instance-2"), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(allocatedOnNode(sameModelClaim, node2Name, "instance-1", "instance-2"), podWithClaimName),
+				},
+			},
+		},
+		"structured-renamed-node": {
+			// The allocator must select the node by its name, not by
+			// the hostname label.
+			nodes:   []*v1.Node{renamedNode},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{st.MakeResourceSlice(renamedNodeName, driver).Device("instance-1", nil).Obj()},
+			want: want{
+				reserve: result{
+					inFlightClaim: allocatedOnNode(pendingClaim, renamedNodeName, "instance-1"),
+				},
+				prebind: result{
+					assumedClaim: reserve(allocatedOnNode(pendingClaim, renamedNodeName, "instance-1"), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(allocatedOnNode(pendingClaim, renamedNodeName, "instance-1"), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(allocatedOnNode(pendingClaim, renamedNodeName, "instance-1"), podWithClaimName),
+				},
+			},
+		},
+		"structured-renamed-node-allocated-by-name": {
+			// Allocation node selectors get evaluated against the
+			// actual labels and fields of the node.
+			nodes:  []*v1.Node{renamedNode},
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{allocatedOnNode(pendingClaim, renamedNodeName, "instance-1")},
+			want: want{
+				prebind: result{
+					changes: change{
+						claim: withClaimStatus(reserve(allocatedOnNode(pendingClaim, renamedNodeName, "instance-1"), podWithClaimName)),
+					},
+				},
+			},
+		},
+		"structured-renamed-node-allocated-by-hostname": {
+			nodes:  []*v1.Node{renamedNode},
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{allocatedWithNodeSelector(st.MakeNodeSelector().In("kubernetes.io/hostname", []string{renamedNodeHostname}).Obj())},
+			want: want{
+				prebind: result{
+					changes: change{
+						claim: withClaimStatus(reserve(allocatedWithNodeSelector(st.MakeNodeSelector().In("kubernetes.io/hostname", []string{renamedNodeHostname}).Obj()), podWithClaimName)),
+					},
+				},
+			},
+		},
+		"structured-renamed-node-allocated-by-name-as-hostname": {
+			nodes:  []*v1.Node{renamedNode},
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{allocatedWithNodeSelector(st.MakeNodeSelector().In("kubernetes.io/hostname", []string{renamedNodeName}).Obj())},
+			want: want{
+				filter: perNodeResult{
+					renamedNodeName: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim not available on the node`),
+					},
+				},
+				postfilter: result{
+					changes: change{
+						claim: withClaimStatus(st.FromResourceClaim(allocatedWithNodeSelector(st.MakeNodeSelector().In("kubernetes.io/hostname", []string{renamedNodeName}).Obj())).Allocation(nil).Obj()),
+					},
+					assumedClaim: st.FromResourceClaim(allocatedWithNodeSelector(st.MakeNodeSelector().In("kubernetes.io/hostname", []string{renamedNodeName}).Obj())).Allocation(nil).Obj(),
+					status:       framework.NewStatus(framework.Unschedulable, `deallocation of ResourceClaim completed`),
+				},
+			},
+		},
+		"structured-device-budget-exhausted": {
+			// The budget is for the entire cluster, so an allocation
+			// in some other namespace counts.
+			pod: podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{
+				structuredClaim(pendingClaim),
+				st.FromResourceClaim(structuredClaim(otherAllocatedClaim)).Namespace("other").Obj(),
+			},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeCardSlice},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.DeviceQuotas = []config.DeviceQuota{{DeviceClassName: className, MaxDevices: 1}}
+			},
+			want: want{
+				reserve: result{
+					status: framework.NewStatus(framework.Unschedulable, "class "+className+" allocation budget exhausted"),
+				},
+			},
+		},
+		"structured-stale-slices": {
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{heartbeatSlice},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.ResourceSliceMaxAgeSeconds = 60
+			},
+			now: testTime.Add(2 * time.Minute),
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.Unschedulable, "resource slices stale for driver "+driver),
+					},
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `still not schedulable`),
+				},
+			},
+		},
+		"structured-recent-slices": {
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{heartbeatSlice},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.ResourceSliceMaxAgeSeconds = 60
+			},
+			now: testTime.Add(30 * time.Second),
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(structuredClaim(allocatedClaim), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+				},
+			},
+		},
+		"structured-slice-age-unchecked": {
+			// Without a maximum age, the heartbeat is not checked.
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{heartbeatSlice},
+			now:     testTime.Add(2 * time.Minute),
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(structuredClaim(allocatedClaim), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+				},
+			},
+		},
+		"structured-during-maintenance": {
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{maintenanceSlice},
+			now:     testTime.Add(30 * time.Minute),
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.Unschedulable, ErrReasonCannotAllocate+": claim "+claimName+": request req-1 unsatisfiable (no matching device)"),
+					},
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `still not schedulable`),
+				},
+			},
+		},
+		"structured-after-maintenance": {
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{maintenanceSlice},
+			now:     testTime.Add(time.Hour),
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(structuredClaim(allocatedClaim), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+				},
+			},
+		},
+		"structured-pinned-node": {
+			nodes:   []*v1.Node{workerNode, workerNode2, workerNode3},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{pinnedClaim(node2Name)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			// The nodes must also exist in the API.
+			objs: []apiruntime.Object{workerNode, workerNode2, workerNode3, workerNodeSlice, workerNode2Slice, workerNode3Slice},
+			want: want{
+				preFilterResult: &framework.PreFilterResult{NodeNames: sets.New(node2Name)},
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, "claim pinned to node "+node2Name),
+					},
+					workerNode3.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, "claim pinned to node "+node2Name),
+					},
+				},
+				reserve: result{
+					inFlightClaim: allocatedOnNode(pinnedClaim(node2Name), node2Name, "instance-1"),
+				},
+				prebind: result{
+					assumedClaim: reserve(allocatedOnNode(pinnedClaim(node2Name), node2Name, "instance-1"), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(allocatedOnNode(pinnedClaim(node2Name), node2Name, "instance-1"), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(allocatedOnNode(pinnedClaim(node2Name), node2Name, "instance-1"), podWithClaimName),
+				},
+			},
+		},
+		"structured-pinned-to-missing-node": {
+			nodes:   []*v1.Node{workerNode, workerNode2, workerNode3},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{pinnedClaim("no-such-node")},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNode, workerNode2, workerNode3, workerNodeSlice, workerNode2Slice, workerNode3Slice},
+			want: want{
+				prefilter: result{
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, "resourceclaim "+claimName+" pinned to node no-such-node which does not exist"),
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+				},
+			},
+		},
+		"claim-template-validated": {
+			pod:     podWithClaimTemplateInStatus,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice, claimTemplate},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.ValidateClaimTemplates = true
+			},
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(structuredClaim(allocatedClaim), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+				},
+			},
+		},
+		"claim-template-edited": {
+			pod:     podWithClaimTemplateInStatus,
+			claims:  []*resourceapi.ResourceClaim{editedClaim},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice, claimTemplate},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.ValidateClaimTemplates = true
+			},
+			want: want{
+				prefilter: result{
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, "resourceclaim "+claimName+" differs from resourceclaimtemplate "+claimName+" in spec.devices.requests[0].selectors"),
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+				},
+			},
+		},
+		"claim-template-deleted": {
+			// Without the template, the claim cannot be checked.
+			pod:     podWithClaimTemplateInStatus,
+			claims:  []*resourceapi.ResourceClaim{editedClaim},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.ValidateClaimTemplates = true
+			},
+			want: want{
+				reserve: result{
+					inFlightClaim: allocatedOnNode(editedClaim, nodeName, "instance-1"),
+				},
+				prebind: result{
+					assumedClaim: reserve(allocatedOnNode(editedClaim, nodeName, "instance-1"), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(allocatedOnNode(editedClaim, nodeName, "instance-1"), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(allocatedOnNode(editedClaim, nodeName, "instance-1"), podWithClaimName),
+				},
+			},
+		},
+		"claim-template-not-validated": {
+			pod:     podWithClaimTemplateInStatus,
+			claims:  []*resourceapi.ResourceClaim{editedClaim},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice, claimTemplate},
+			want: want{
+				reserve: result{
+					inFlightClaim: allocatedOnNode(editedClaim, nodeName, "instance-1"),
+				},
+				prebind: result{
+					assumedClaim: reserve(allocatedOnNode(editedClaim, nodeName, "instance-1"), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(allocatedOnNode(editedClaim, nodeName, "instance-1"), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(allocatedOnNode(editedClaim, nodeName, "instance-1"), podWithClaimName),
+				},
+			},
+		},
+		"wrong-topology-one-of-two-claims": {
+			// The first claim is allocated for workerNode, the second
+			// one for workerNode2, which is not available. Only the
+			// second claim needs to be deallocated to make workerNode
+			// usable.
+			nodes:   []*v1.Node{workerNode, workerNode3},
+			pod:     podWithTwoClaimNames,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(allocatedClaim), allocatedOnNode(pendingClaim2, node2Name, "instance-1")},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice, workerNode3Slice},
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim not available on the node`),
+					},
+					workerNode3.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim not available on the node`),
+					},
+				},
+				postfilter: result{
+					changes: change{
+						claim: withClaimStatus(st.FromResourceClaim(allocatedOnNode(pendingClaim2, node2Name, "instance-1")).Allocation(nil).Obj()),
+					},
+					assumedClaim: st.FromResourceClaim(allocatedOnNode(pendingClaim2, node2Name, "instance-1")).Allocation(nil).Obj(),
+					status:       framework.NewStatus(framework.Unschedulable, `deallocation of ResourceClaim completed`),
+				},
+			},
+		},
+		"structured-tainted-device": {
+			pod:      podWithClaimName,
+			claims:   []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:  []*resourceapi.DeviceClass{deviceClass},
+			objs:     []apiruntime.Object{taintedSlice},
+			features: feature.Features{EnableDRADeviceTaints: true},
+			want: want{
+				filter: perNodeResult{
+					workerNode.Name: {
+						status: framework.NewStatus(framework.Unschedulable, ErrReasonCannotAllocate+": claim "+claimName+": request req-1 unsatisfiable (no matching device)"),
+					},
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `still not schedulable`),
+				},
+			},
+		},
+		"structured-tolerated-device-taint": {
+			pod:      podWithTaintToleration,
+			claims:   []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:  []*resourceapi.DeviceClass{deviceClass},
+			objs:     []apiruntime.Object{taintedSlice},
+			features: feature.Features{EnableDRADeviceTaints: true},
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(structuredClaim(allocatedClaim), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+				},
+			},
+		},
+		"structured-device-taints-disabled": {
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{taintedSlice},
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(structuredClaim(allocatedClaim), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+				},
+			},
+		},
+		"structured-with-device-scorer": {
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeCardSlice},
+			options: []Option{WithDeviceScorers(preferDeviceScorer{device: "instance-2"})},
+			want: want{
+				reserve: result{
+					inFlightClaim: allocatedOnNode(pendingClaim, nodeName, "instance-2"),
+				},
+				prebind: result{
+					assumedClaim: reserve(allocatedOnNode(pendingClaim, nodeName, "instance-2"), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(allocatedOnNode(pendingClaim, nodeName, "instance-2"), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(allocatedOnNode(pendingClaim, nodeName, "instance-2"), podWithClaimName),
+				},
+			},
+		},
+		"structured-class-selects-unknown-driver": {
+			nodes:   []*v1.Node{workerNode2},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{classWithDriver("gpu.example.cmo")},
+			objs:    []apiruntime.Object{workerNode2Slice},
+			want: want{
+				prefilter: result{
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `request req-1: device class my-resource-class selects attributes of driver gpu.example.cmo which publishes no resources (typo?)`),
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+				},
+			},
+		},
+		"structured-class-selects-known-driver": {
+			nodes:   []*v1.Node{workerNode2},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{classWithDriver(driver)},
+			objs:    []apiruntime.Object{workerNode2Slice},
+			want: want{
+				reserve: result{
+					inFlightClaim: allocatedOnNode(pendingClaim, node2Name, "instance-1"),
+				},
+				prebind: result{
+					assumedClaim: reserve(allocatedOnNode(pendingClaim, node2Name, "instance-1"), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(allocatedOnNode(pendingClaim, node2Name, "instance-1"), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(allocatedOnNode(pendingClaim, node2Name, "instance-1"), podWithClaimName),
+				},
+			},
+		},
+		"structured-class-driver-check-skipped": {
+			// The allocator then finds no matching device.
+			nodes:   []*v1.Node{workerNode2},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{classWithDriver("gpu.example.cmo")},
+			objs:    []apiruntime.Object{workerNode2Slice},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.SkipClassDriverCheck = true
+			},
+			want: want{
+				filter: perNodeResult{
+					workerNode2.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `claim default/my-pod-my-resource: request req-1: class my-resource-class: selector #0: CEL runtime error: no such key: `+string(attrName)),
+					},
+				},
+				postfilter: result{
+					status: framework.AsStatus(errors.New(`pod default/my-pod: claim default/my-pod-my-resource: request req-1: class my-resource-class: selector #0: CEL runtime error: no such key: ` + string(attrName))),
+				},
+			},
+		},
+		"structured-terminating-class": {
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{terminatingDeviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice},
+			want: want{
+				prefilter: result{
+					status: framework.NewStatus(framework.UnschedulableAndUnresolvable, "claim default/"+claimName+": request req-1: class "+className+": is being deleted"),
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `no new claims to deallocate`),
+				},
+			},
+		},
+		"structured-terminating-class-allowed": {
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes: []*resourceapi.DeviceClass{terminatingDeviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.AllowTerminatingDeviceClasses = true
+			},
+			want: want{
+				reserve: result{
+					inFlightClaim: structuredClaim(allocatedClaim),
+				},
+				prebind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+					changes: change{
+						claim: withClaimStatus(reserve(structuredClaim(allocatedClaim), podWithClaimName)),
+					},
+				},
+				postbind: result{
+					assumedClaim: reserve(structuredClaim(allocatedClaim), podWithClaimName),
+				},
+			},
+		},
+		"reserved-okay": {
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{inUseClaim},
+		},
+		"reserved-other-node-feasible": {
+			// Like reserved-okay, with a device on workerNode2
+			// which could also be allocated.
+			nodes:   []*v1.Node{workerNode, workerNode2},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredInUseClaim},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNodeSlice, workerNode2Slice},
+			want: want{
+				filter: perNodeResult{
+					workerNode2.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim not available on the node`),
+					},
+				},
+			},
+		},
+		"reserved-kept": {
+			// The pod does not fit workerNode for some other reason,
+			// so it is not passed to the plugin.
+			nodes:   []*v1.Node{workerNode2},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredInUseClaim},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNode, workerNode2, workerNodeSlice, workerNode2Slice},
+			want: want{
+				filter: perNodeResult{
+					workerNode2.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim not available on the node`),
+					},
+				},
+				postfilter: result{
+					status: framework.NewStatus(framework.Unschedulable, `ResourceClaim reserved for the pod is kept`),
+				},
+			},
+		},
+		"reserved-not-preferred": {
+			nodes:   []*v1.Node{workerNode2},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredInUseClaim},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNode, workerNode2, workerNodeSlice, workerNode2Slice},
+			args: func(args *config.DynamicResourcesArgs) {
+				args.PreferReservedClaims = false
+			},
+			want: want{
+				filter: perNodeResult{
+					workerNode2.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim not available on the node`),
+					},
+				},
+				postfilter: result{
+					changes: change{
+						claim: withClaimStatus(st.FromResourceClaim(structuredInUseClaim).Allocation(nil).ReservedFor().Obj()),
+					},
+					assumedClaim: st.FromResourceClaim(structuredInUseClaim).Allocation(nil).ReservedFor().Obj(),
+					status:       framework.NewStatus(framework.Unschedulable, `deallocation of ResourceClaim completed`),
+				},
+			},
+		},
+		"reserved-node-removed": {
+			nodes:   []*v1.Node{workerNode2},
+			pod:     podWithClaimName,
+			claims:  []*resourceapi.ResourceClaim{structuredInUseClaim},
+			classes: []*resourceapi.DeviceClass{deviceClass},
+			objs:    []apiruntime.Object{workerNode2, workerNodeSlice, workerNode2Slice},
+			want: want{
+				filter: perNodeResult{
+					workerNode2.Name: {
+						status: framework.NewStatus(framework.UnschedulableAndUnresolvable, `resourceclaim not available on the node`),
+					},
+				},
+				postfilter: result{
+					changes: change{
+						claim: withClaimStatus(st.FromResourceClaim(structuredInUseClaim).Allocation(nil).ReservedFor().Obj()),
+					},
+					assumedClaim: st.FromResourceClaim(structuredInUseClaim).Allocation(nil).ReservedFor().Obj(),
+					status:       framework.NewStatus(framework.Unschedulable, `deallocation of ResourceClaim completed`),
+				},
+			},
+		},
+		"DRA-disabled": {
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{inUseClaim},
+			want: want{
+				prefilter: result{
+					status: framework.NewStatus(framework.Skip),
+				},
+			},
+			disableDRA: true,
+		},
+	}
+
+	for name, tc := range testcases {
+		// We can run in parallel because logging is per-test.
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			nodes := tc.nodes
+			if nodes == nil {
+				nodes = []*v1.Node{workerNode}
+			}
+			features := tc.features
+			features.EnableDynamicResourceAllocation = !tc.disableDRA
+			features.EnableDRAControlPlaneController = !tc.disableClassicDRA
+			now := tc.now
+			if now.IsZero() {
+				now = time.Now()
+			}
+			setupOpts := []setupOption{withClock(testingclock.NewFakeClock(now))}
+			if tc.args != nil {
+				setupOpts = append(setupOpts, withArgs(tc.args))
+			}
+			if tc.options != nil {
+				setupOpts = append(setupOpts, withOptions(tc.options...))
+			}
+			testCtx := setup(t, nodes, tc.claims, tc.classes, tc.schedulings, tc.objs, features, setupOpts...)
+			testCtx.schedule(t, tc.pod, tc.prepare, tc.want)
+			for i, cycle := range tc.furtherCycles {
+				t.Run(fmt.Sprintf("cycle-%d", i+2), func(t *testing.T) {
+					testCtx.schedule(t, tc.pod, cycle.prepare, cycle.want)
+				})
+			}
+		})
+	}
+}
+
+// schedule runs one scheduling cycle for the pod and verifies the
+// outcome of each step.
+func (tc *testContext) schedule(t *testing.T, pod *v1.Pod, prepare prepare, want want) {
+	t.Helper()
+	tc.state = framework.NewCycleState()
+	initialObjects := tc.listAll(t)
+	defer t.Run("events", func(t *testing.T) {
+		var events []string
+		for len(tc.recorder.Events) > 0 {
+			events = append(events, <-tc.recorder.Events)
+		}
+		assert.Equal(t, want.events, events)
+	})
+
+	status := tc.p.PreEnqueue(tc.ctx, pod)
+	t.Run("PreEnqueue", func(t *testing.T) {
+		tc.verify(t, want.preenqueue, initialObjects, nil, status)
+	})
+	if !status.IsSuccess() {
+		return
+	}
+
+	result, status := tc.p.PreFilter(tc.ctx, tc.state, pod)
+	t.Run("prefilter", func(t *testing.T) {
+		assert.Equal(t, want.preFilterResult, result)
+		tc.verify(t, want.prefilter, initialObjects, result, status)
+	})
+	if status.IsSkip() {
+		return
+	}
+	unschedulable := status.Code() != framework.Success
+
+	var potentialNodes []*framework.NodeInfo
+
+	initialObjects = tc.listAll(t)
+	tc.updateAPIServer(t, initialObjects, prepare.filter)
+	if !unschedulable {
+		for _, nodeInfo := range tc.nodeInfos {
+			initialObjects = tc.listAll(t)
+			status := tc.p.Filter(tc.ctx, tc.state, pod, nodeInfo)
+			nodeName := nodeInfo.Node().Name
+			t.Run(fmt.Sprintf("filter/%s", nodeInfo.Node().Name), func(t *testing.T) {
+				tc.verify(t, want.filter.forNode(nodeName), initialObjects, nil, status)
+			})
+			if status.Code() == framework.Success {
+				potentialNodes = append(potentialNodes, nodeInfo)
+			}
+			if status.Code() == framework.Error {
+				// An error aborts scheduling.
+				return
+			}
+		}
+		if len(potentialNodes) == 0 {
+			unschedulable = true
+		}
+	}
+
+	if !unschedulable && len(potentialNodes) > 1 {
+		initialObjects = tc.listAll(t)
+		initialObjects = tc.updateAPIServer(t, initialObjects, prepare.prescore)
+		status := tc.p.PreScore(tc.ctx, tc.state, pod, potentialNodes)
+		t.Run("prescore", func(t *testing.T) {
+			tc.verify(t, want.prescore, initialObjects, nil, status)
+		})
+		if status.Code() != framework.Success {
+			unschedulable = true
+		}
+	}
+
+	var selectedNode *framework.NodeInfo
+	if !unschedulable && len(potentialNodes) > 0 {
+		selectedNode = potentialNodes[0]
+
+		initialObjects = tc.listAll(t)
+		initialObjects = tc.updateAPIServer(t, initialObjects, prepare.reserve)
+		status := tc.p.Reserve(tc.ctx, tc.state, pod, selectedNode.Node().Name)
+		t.Run("reserve", func(t *testing.T) {
+			tc.verify(t, want.reserve, initialObjects, nil, status)
+			tc.p.inFlightAllocations.forEach(func(allocation *inFlightAllocation) bool {
+				assert.Equal(t, selectedNode.Node().Name, allocation.nodeName, "node of in-flight allocation for %s", allocation.claim.Name)
+				assert.Equal(t, pod.UID, allocation.podUID, "pod of in-flight allocation for %s", allocation.claim.Name)
+				return true
+			})
+		})
+		if status.Code() != framework.Success {
+			unschedulable = true
+		}
+	}
+
+	if selectedNode != nil {
+		if unschedulable {
+			initialObjects = tc.listAll(t)
+			initialObjects = tc.updateAPIServer(t, initialObjects, prepare.unreserve)
+			tc.p.Unreserve(tc.ctx, tc.state, pod, selectedNode.Node().Name)
+			t.Run("unreserve", func(t *testing.T) {
+				tc.verify(t, want.unreserve, initialObjects, nil, status)
+			})
+		} else {
+			if want.unreserveBeforePreBind != nil {
+				initialObjects = tc.listAll(t)
+				tc.p.Unreserve(tc.ctx, tc.state, pod, selectedNode.Node().Name)
+				t.Run("unreserveBeforePreBind", func(t *testing.T) {
+					tc.verify(t, *want.unreserveBeforePreBind, initialObjects, nil, status)
+				})
+				return
+			}
+
+			initialObjects = tc.listAll(t)
+			initialObjects = tc.updateAPIServer(t, initialObjects, prepare.prebind)
+			status := tc.p.PreBind(tc.ctx, tc.state, pod, selectedNode.Node().Name)
+			tc.waitForSchedulingContexts(t)
+			t.Run("prebind", func(t *testing.T) {
+				tc.verify(t, want.prebind, initialObjects, nil, status)
+			})
+
+			if want.unreserveAfterBindFailure != nil {
+				initialObjects = tc.listAll(t)
+				tc.p.Unreserve(tc.ctx, tc.state, pod, selectedNode.Node().Name)
+				t.Run("unreserverAfterBindFailure", func(t *testing.T) {
+					tc.verify(t, *want.unreserveAfterBindFailure, initialObjects, nil, status)
+				})
+			} else if status.IsSuccess() {
+				initialObjects = tc.listAll(t)
+				initialObjects = tc.updateAPIServer(t, initialObjects, prepare.postbind)
+				tc.p.PostBind(tc.ctx, tc.state, pod, selectedNode.Node().Name)
+				tc.waitForSchedulingContexts(t)
+				t.Run("postbind", func(t *testing.T) {
+					tc.verify(t, want.postbind, initialObjects, nil, nil)
+				})
+			}
+		}
+	} else if len(potentialNodes) == 0 {
+		initialObjects = tc.listAll(t)
+		initialObjects = tc.updateAPIServer(t, initialObjects, prepare.postfilter)
+		result, status := tc.p.PostFilter(tc.ctx, tc.state, pod, nil /* filteredNodeStatusMap not used by plugin */)
+		t.Run("postfilter", func(t *testing.T) {
+			assert.Equal(t, want.postFilterResult, result)
+			tc.verify(t, want.postfilter, initialObjects, nil, status)
+		})
+	}
+}
+
+type testContext struct {
+	ctx              context.Context
+	client           *fake.Clientset
+	informerFactory  informers.SharedInformerFactory
+	claimAssumeCache *assumecache.AssumeCache
+	p                *dynamicResources
+	nodeInfos        []*framework.NodeInfo
+	state            *framework.CycleState
+	recorder         *events.FakeRecorder
+}
+
+func (tc *testContext) verify(t *testing.T, expected result, initialObjects []metav1.Object, result interface{}, status *framework.Status) {
+	t.Helper()
+	if expectedErr := expected.status.AsError(); expectedErr != nil {
+		// Compare only the code and the error strings.
+		assert.Equal(t, expected.status.Code(), status.Code(), "status code")
+		assert.ErrorContains(t, status.AsError(), expectedErr.Error())
+	} else {
+		assert.Equal(t, expected.status, status)
+	}
+	objects := tc.listAll(t)
+	wantObjects := update(t, initialObjects, expected.changes)
+	wantObjects = append(wantObjects, expected.added...)
+	for _, remove := range expected.removed {
+		for i, obj := range wantObjects {
+			// This is a bit relaxed (no GVR comparison, no UID
+			// comparison) to simplify writing the test cases.
+			if obj.GetName() == remove.GetName() && obj.GetNamespace() == remove.GetNamespace() {
+				wantObjects = append(wantObjects[0:i], wantObjects[i+1:]...)
+				break
+			}
+		}
+	}
+	sortObjects(wantObjects)
+	// Sometimes assert strips the diff too much, let's do it ourselves...
+	if diff := cmp.Diff(wantObjects, objects, cmpopts.IgnoreFields(metav1.ObjectMeta{}, "UID", "ResourceVersion"), cmpopts.IgnoreFields(v1.PodCondition{}, "LastTransitionTime")); diff != "" {
+		t.Errorf("Stored objects are different (- expected, + actual):\n%s", diff)
+	}
+
+	var expectAssumedClaims []metav1.Object
+	if expected.assumedClaim != nil {
+		expectAssumedClaims = append(expectAssumedClaims, expected.assumedClaim)
+	}
+	actualAssumedClaims := tc.listAssumedClaims()
+	if diff := cmp.Diff(expectAssumedClaims, actualAssumedClaims, cmpopts.IgnoreFields(metav1.ObjectMeta{}, "UID", "ResourceVersion")); diff != "" {
+		t.Errorf("Assumed claims are different (- expected, + actual):\n%s", diff)
+	}
+
+	var expectInFlightClaims []metav1.Object
+	if expected.inFlightClaim != nil {
+		expectInFlightClaims = append(expectInFlightClaims, expected.inFlightClaim)
+	}
+	actualInFlightClaims := tc.listInFlightClaims()
+	if diff := cmp.Diff(expectInFlightClaims, actualInFlightClaims, cmpopts.IgnoreFields(metav1.ObjectMeta{}, "UID", "ResourceVersion")); diff != "" {
+		t.Errorf("In-flight claims are different (- expected, + actual):\n%s", diff)
+	}
+}
+
+// waitForPools waits until the plugin has observed the given number of
+// pools. Their generation counts as updated at that time of the plugin
+// clock.
+func (tc *testContext) waitForPools(t *testing.T, numPools int) {
+	t.Helper()
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		tc.p.poolGenerations.mutex.Lock()
+		defer tc.p.poolGenerations.mutex.Unlock()
+		assert.Len(t, tc.p.poolGenerations.pools, numPools)
+	}, time.Minute, 10*time.Millisecond, "pools must be observed")
+}
+
+func (tc *testContext) listAll(t *testing.T) (objects []metav1.Object) {
+	t.Helper()
+	claims, err := tc.client.ResourceV1alpha3().ResourceClaims("").List(tc.ctx, metav1.ListOptions{})
+	require.NoError(t, err, "list claims")
+	for _, claim := range claims.Items {
+		claim := claim
+		objects = append(objects, &claim)
+	}
+	schedulings, err := tc.client.ResourceV1alpha3().PodSchedulingContexts("").List(tc.ctx, metav1.ListOptions{})
+	require.NoError(t, err, "list pod scheduling")
+	for _, scheduling := range schedulings.Items {
+		scheduling := scheduling
+		objects = append(objects, &scheduling)
+	}
+	pods, err := tc.client.CoreV1().Pods("").List(tc.ctx, metav1.ListOptions{})
+	require.NoError(t, err, "list pods")
+	for _, pod := range pods.Items {
+		pod := pod
+		// Removing the last condition may leave an empty slice.
+		if len(pod.Status.Conditions) == 0 {
+			pod.Status.Conditions = nil
+		}
+		objects = append(objects, &pod)
+	}
+
+	sortObjects(objects)
+	return
+}
+
+// waitForSchedulingContexts waits until the deletions queued by PostBind
+// are done.
+func (tc *testContext) waitForSchedulingContexts(t *testing.T) {
+	t.Helper()
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		assert.Zero(t, tc.p.pendingSchedulingContexts.len(), "pending PodSchedulingContext deletions")
+	}, 10*time.Second, time.Millisecond)
+}
+
+func (tc *testContext) listAssumedClaims() []metav1.Object {
+	if tc.p.claimAssumeCache == nil {
+		return nil
+	}
+	var assumedClaims []metav1.Object
+	for _, obj := range tc.p.claimAssumeCache.List(nil) {
+		claim := obj.(*resourceapi.ResourceClaim)
+		obj, _ := tc.p.claimAssumeCache.Get(claim.Namespace + "/" + claim.Name)
+		apiObj, _ := tc.p.claimAssumeCache.GetAPIObj(claim.Namespace + "/" + claim.Name)
+		if obj != apiObj {
+			assumedClaims = append(assumedClaims, claim)
+		}
+	}
+	sortObjects(assumedClaims)
+	return assumedClaims
+}
+
+func (tc *testContext) listInFlightClaims() []metav1.Object {
+	var inFlightClaims []metav1.Object
+	tc.p.inFlightAllocations.forEach(func(allocation *inFlightAllocation) bool {
+		inFlightClaims = append(inFlightClaims, allocation.claim)
+		return true
+	})
+	sortObjects(inFlightClaims)
+	return inFlightClaims
+}
+
+// updateAPIServer modifies objects and stores any changed object in the API server.
+func (tc *testContext) updateAPIServer(t *testing.T, objects []metav1.Object, updates change) []metav1.Object {
+	modified := update(t, objects, updates)
+	for i := range modified {
+		obj := modified[i]
+		if diff := cmp.Diff(objects[i], obj); diff != "" {
+			t.Logf("Updating %T %q, diff (-old, +new):\n%s", obj, obj.GetName(), diff)
+			switch obj := obj.(type) {
+			case *resourceapi.ResourceClaim:
+				obj, err := tc.client.ResourceV1alpha3().ResourceClaims(obj.Namespace).Update(tc.ctx, obj, metav1.UpdateOptions{})
+				if err != nil {
+					t.Fatalf("unexpected error during prepare update: %v", err)
+				}
+				modified[i] = obj
+			case *resourceapi.PodSchedulingContext:
+				obj, err := tc.client.ResourceV1alpha3().PodSchedulingContexts(obj.Namespace).Update(tc.ctx, obj, metav1.UpdateOptions{})
+				if err != nil {
+					t.Fatalf("unexpected error during prepare update: %v", err)
+				}
+				modified[i] = obj
+			default:
+				t.Fatalf("unsupported object type %T", obj)
+			}
+		}
+	}
+	return modified
+}
+
+func sortObjects(objects []metav1.Object) {
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].GetNamespace() != objects[j].GetNamespace() {
+			return objects[i].GetNamespace() < objects[j].GetNamespace()
+		}
+		if objects[i].GetName() != objects[j].GetName() {
+			return objects[i].GetName() < objects[j].GetName()
+		}
+		// A pod and its PodSchedulingContext have the same name.
+		return fmt.Sprintf("%T", objects[i]) < fmt.Sprintf("%T", objects[j])
+	})
+}
+
+// update walks through all existing objects, finds the corresponding update
+// function based on name and kind, and replaces those objects that have an
+// update function. The rest is left unchanged.
+func update(t *testing.T, objects []metav1.Object, updates change) []metav1.Object {
+	var updated []metav1.Object
+
+	for _, obj := range objects {
+		switch in := obj.(type) {
+		case *resourceapi.ResourceClaim:
+			if updates.claim != nil {
+				obj = updates.claim(in)
+			}
+		case *resourceapi.PodSchedulingContext:
+			if updates.scheduling != nil {
+				obj = updates.scheduling(in)
+			}
+		case *v1.Pod:
+			if updates.pod != nil {
+				obj = updates.pod(in)
+			}
+		}
+		updated = append(updated, obj)
+	}
+
+	return updated
+}
+
+// setupOption modifies the dependencies and options that setup passes
+// to NewWithDependencies.
+type setupOption func(deps *Dependencies, opts *[]Option)
+
+// withArgs modifies the plugin arguments, starting with the defaults if
+// none were set yet.
+func withArgs(modify func(args *config.DynamicResourcesArgs)) setupOption {
+	return func(deps *Dependencies, opts *[]Option) {
+		if deps.Args == nil {
+			deps.Args, _ = getArgs(nil)
+		}
+		modify(deps.Args)
+	}
+}
+
+// withOptions passes additional options to NewWithDependencies.
+func withOptions(options ...Option) setupOption {
+	return func(deps *Dependencies, opts *[]Option) {
+		*opts = append(*opts, options...)
+	}
+}
+
+// withClock replaces the clock of the plugin.
+func withClock(clock clock.Clock) setupOption {
+	return func(deps *Dependencies, opts *[]Option) {
+		*opts = append(*opts, func(pl *dynamicResources) { pl.clock = clock })
+	}
+}
+
+func setup(t testing.TB, nodes []*v1.Node, claims []*resourceapi.ResourceClaim, classes []*resourceapi.DeviceClass, schedulings []*resourceapi.PodSchedulingContext, objs []apiruntime.Object, features feature.Features, setupOpts ...setupOption) (result *testContext) {
+	t.Helper()
+
+	tc := &testContext{}
+	tCtx := ktesting.Init(t)
+	tc.ctx = tCtx
+	tc.recorder = events.NewFakeRecorder(10)
+
+	tc.client = fake.NewSimpleClientset(objs...)
+	reactor := createReactor(tc.client.Tracker())
+	tc.client.PrependReactor("*", "*", reactor)
+
+	tc.informerFactory = informers.NewSharedInformerFactory(tc.client, 0)
+	tc.claimAssumeCache = assumecache.NewAssumeCache(tCtx.Logger(), tc.informerFactory.Resource().V1alpha3().ResourceClaims().Informer(), "resource claim", "", nil)
+	// The plugin must not modify claims that it gets from the cache.
+	tc.claimAssumeCache.EnableMutationDetection()
+	t.Cleanup(func() {
+		if err := tc.claimAssumeCache.CheckMutations(); err != nil {
+			t.Errorf("Claims in assume cache were modified: %v", err)
+		}
+	})
+	deps := Dependencies{
+		Client:          tc.client,
+		ClaimCache:      tc.claimAssumeCache,
+		ClassLister:     tc.informerFactory.Resource().V1alpha3().DeviceClasses().Lister(),
+		SliceLister:     tc.informerFactory.Resource().V1alpha3().ResourceSlices().Lister(),
+		PodLister:       tc.informerFactory.Core().V1().Pods().Lister(),
+		NodeLister:      tc.informerFactory.Core().V1().Nodes().Lister(),
+		NamespaceLister: tc.informerFactory.Core().V1().Namespaces().Lister(),
+		SliceInformer:   tc.informerFactory.Resource().V1alpha3().ResourceSlices().Informer(),
+		NodeInformer:    tc.informerFactory.Core().V1().Nodes().Informer(),
+		CachesSynced: []cache.InformerSynced{
+			tc.informerFactory.Resource().V1alpha3().ResourceClaims().Informer().HasSynced,
+			tc.informerFactory.Core().V1().Pods().Informer().HasSynced,
+		},
+		EventRecorder: tc.recorder,
+		Features:      features,
+	}
+	if features.EnableDRAControlPlaneController {
+		deps.PodSchedulingContextLister = tc.informerFactory.Resource().V1alpha3().PodSchedulingContexts().Lister()
+	}
+	var opts []Option
+	for _, setupOpt := range setupOpts {
+		setupOpt(&deps, &opts)
+	}
+	if deps.Args != nil && deps.Args.ValidateClaimTemplates {
+		deps.ClaimTemplateLister = tc.informerFactory.Resource().V1alpha3().ResourceClaimTemplates().Lister()
+	}
+	pl, err := NewWithDependencies(tCtx, deps, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.p = pl.(*dynamicResources)
+
+	// The tests use the API to create the objects because then reactors
+	// get triggered.
+	for _, claim := range claims {
+		_, err := tc.client.ResourceV1alpha3().ResourceClaims(claim.Namespace).Create(tc.ctx, claim, metav1.CreateOptions{})
+		require.NoError(t, err, "create resource claim")
+	}
+	for _, class := range classes {
+		_, err := tc.client.ResourceV1alpha3().DeviceClasses().Create(tc.ctx, class, metav1.CreateOptions{})
+		require.NoError(t, err, "create resource class")
+	}
+	for _, scheduling := range schedulings {
+		_, err := tc.client.ResourceV1alpha3().PodSchedulingContexts(scheduling.Namespace).Create(tc.ctx, scheduling, metav1.CreateOptions{})
+		require.NoError(t, err, "create pod scheduling")
+	}
+
+	tc.informerFactory.Start(tc.ctx.Done())
+	t.Cleanup(func() {
+		// Need to cancel before waiting for the shutdown.
+		tCtx.Cancel("test is done")
+		// Now we can wait for all goroutines to stop.
+		tc.informerFactory.Shutdown()
+		// The next test must be able to take over checking finalizers.
+		tc.p.finalizerCheckRunning.Wait()
+	})
+
+	tc.informerFactory.WaitForCacheSync(tc.ctx.Done())
+
+	for _, node := range nodes {
+		nodeInfo := framework.NewNodeInfo()
+		nodeInfo.SetNode(node)
+		tc.nodeInfos = append(tc.nodeInfos, nodeInfo)
+	}
+	tc.state = framework.NewCycleState()
+
+	return tc
+}
+
+// createReactor implements the logic required for the UID and ResourceVersion
+// fields to work when using the fake client. Add it with client.PrependReactor
+// to your fake client. ResourceVersion handling is required for conflict
+// detection during updates, which is covered by some scenarios.
+func createReactor(tracker cgotesting.ObjectTracker) func(action cgotesting.Action) (handled bool, ret apiruntime.Object, err error) {
+	var uidCounter int
+	var resourceVersionCounter int
+	var mutex sync.Mutex
+
+	return func(action cgotesting.Action) (handled bool, ret apiruntime.Object, err error) {
+		createAction, ok := action.(cgotesting.CreateAction)
+		if !ok {
+			return false, nil, nil
+		}
+		obj, ok := createAction.GetObject().(metav1.Object)
+		if !ok {
+			return false, nil, nil
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch action.GetVerb() {
+		case "create":
+			if obj.GetUID() != "" {
+				return true, nil, errors.New("UID must not be set on create")
+			}
+			if obj.GetResourceVersion() != "" {
+				return true, nil, errors.New("ResourceVersion must not be set on create")
+			}
+			obj.SetUID(types.UID(fmt.Sprintf("UID-%d", uidCounter)))
+			uidCounter++
+			obj.SetResourceVersion(fmt.Sprintf("%d", resourceVersionCounter))
+			resourceVersionCounter++
+		case "update":
+			uid := obj.GetUID()
+			resourceVersion := obj.GetResourceVersion()
+			if uid == "" {
+				return true, nil, errors.New("UID must be set on update")
+			}
+			if resourceVersion == "" {
+				return true, nil, errors.New("ResourceVersion must be set on update")
+			}
+
+			oldObj, err := tracker.Get(action.GetResource(), obj.GetNamespace(), obj.GetName())
+			if err != nil {
+				return true, nil, err
+			}
+			oldObjMeta, ok := oldObj.(metav1.Object)
+			if !ok {
+				return true, nil, errors.New("internal error: unexpected old object type")
+			}
+			if oldObjMeta.GetResourceVersion() != resourceVersion {
+				err := errors.New("ResourceVersion must match the object that gets updated")
+				if _, ok := obj.(*resourceapi.ResourceClaim); ok {
+					// Like the apiserver, report a conflict, which
+					// PreBind handles by retrying. For other objects,
+					// the plain error avoids the fallback to server-side
+					// apply for PodSchedulingContexts.
+					err = apierrors.NewConflict(action.GetResource().GroupResource(), obj.GetName(), err)
+				}
+				return true, nil, err
+			}
+
+			obj.SetResourceVersion(fmt.Sprintf("%d", resourceVersionCounter))
+			resourceVersionCounter++
+		}
+		return false, nil, nil
+	}
+}
+
+func TestAllocatableDevices(t *testing.T) {
+	slice := st.MakeResourceSlice(nodeName, driver).
+		Device("instance-1", nil).
+		Device("instance-2", nil).
+		Device("instance-3", nil).
+		Obj()
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+		EnableDRAControlPlaneController: true,
+	}
+	// "instance-1" is allocated to some other claim.
+	claims := []*resourceapi.ResourceClaim{structuredClaim(otherAllocatedClaim), structuredClaim(pendingClaim)}
+	testCtx := setup(t, []*v1.Node{workerNode}, claims, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{slice}, features)
+
+	numDevices, err := testCtx.p.AllocatableDevices(testCtx.ctx, podWithClaimName, workerNode, className)
+	require.NoError(t, err)
+	assert.Equal(t, 2, numDevices, "allocatable devices")
+
+	// Allocating "instance-2" is in flight.
+	obj, err := testCtx.claimAssumeCache.Get(namespace + "/" + claimName)
+	require.NoError(t, err)
+	claim := obj.(*resourceapi.ResourceClaim).DeepCopy()
+	claim.Status.Allocation = &resourceapi.AllocationResult{
+		Devices: resourceapi.DeviceAllocationResult{
+			Results: []resourceapi.DeviceRequestAllocationResult{{
+				Driver:  driver,
+				Pool:    nodeName,
+				Device:  "instance-2",
+				Request: "req-1",
+			}},
+		},
+	}
+	testCtx.p.inFlightAllocations.store(&inFlightAllocation{claim: claim, nodeName: workerNode.Name})
+
+	numDevices, err = testCtx.p.AllocatableDevices(testCtx.ctx, podWithClaimName, workerNode, className)
+	require.NoError(t, err)
+	assert.Equal(t, 1, numDevices, "allocatable devices with in-flight allocation")
+}
+
+// TestAllocatableDevicesFilter checks that AllocatableDevices counts no
+// devices on a node exactly when Filter refuses to allocate there.
+func TestAllocatableDevicesFilter(t *testing.T) {
+	for name, tc := range map[string]struct {
+		slice         *resourceapi.ResourceSlice
+		pod           *v1.Pod
+		args          func(args *config.DynamicResourcesArgs)
+		expectDevices int
+	}{
+		"available": {
+			slice:         workerNodeSlice,
+			expectDevices: 1,
+		},
+		"tainted": {
+			slice: taintedSlice,
+		},
+		"tolerated": {
+			slice:         taintedSlice,
+			pod:           podWithTaintToleration,
+			expectDevices: 1,
+		},
+		"maintenance": {
+			slice: maintenanceSlice,
+		},
+		"stale": {
+			slice: heartbeatSlice,
+			args: func(args *config.DynamicResourcesArgs) {
+				args.ResourceSliceMaxAgeSeconds = 60
+			},
+		},
+		"driver-not-ready": {
+			slice: workerNodeSlice,
+			args: func(args *config.DynamicResourcesArgs) {
+				args.DriverReadinessSeconds = 60
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			features := feature.Features{
+				EnableDynamicResourceAllocation: true,
+				EnableDRADeviceTaints:           true,
+			}
+			pod := tc.pod
+			if pod == nil {
+				pod = podWithClaimName
+			}
+			fakeClock := testingclock.NewFakeClock(testTime)
+			setupOpts := []setupOption{withClock(fakeClock)}
+			if tc.args != nil {
+				setupOpts = append(setupOpts, withArgs(tc.args))
+			}
+			testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{tc.slice}, features, setupOpts...)
+			// The pool was published a while ago.
+			testCtx.waitForPools(t, 1)
+			fakeClock.Step(5 * time.Minute)
+
+			numDevices, err := testCtx.p.AllocatableDevices(testCtx.ctx, pod, workerNode, className)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectDevices, numDevices, "allocatable devices")
+
+			state := framework.NewCycleState()
+			_, status := testCtx.p.PreFilter(testCtx.ctx, state, pod)
+			require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+			status = testCtx.p.Filter(testCtx.ctx, state, pod, testCtx.nodeInfos[0])
+			assert.Equal(t, numDevices > 0, status.IsSuccess(), "Filter: %v", status)
+		})
+	}
+}
+
+func TestConsumableCapacity(t *testing.T) {
+	testcases := map[string]struct {
+		// The device has enough capacity for two of the claims.
+		deviceCapacity  map[resourceapi.QualifiedName]resource.Quantity
+		requestCapacity map[resourceapi.QualifiedName]resource.Quantity
+	}{
+		"memory": {
+			deviceCapacity:  map[resourceapi.QualifiedName]resource.Quantity{"memory": resource.MustParse("8Gi")},
+			requestCapacity: map[resourceapi.QualifiedName]resource.Quantity{"memory": resource.MustParse("4Gi")},
+		},
+		"encoders": {
+			deviceCapacity:  map[resourceapi.QualifiedName]resource.Quantity{"encoders": resource.MustParse("2"), "decoders": resource.MustParse("4")},
+			requestCapacity: map[resourceapi.QualifiedName]resource.Quantity{"encoders": resource.MustParse("1")},
+		},
+		"encoders-and-decoders": {
+			deviceCapacity:  map[resourceapi.QualifiedName]resource.Quantity{"encoders": resource.MustParse("4"), "decoders": resource.MustParse("2")},
+			requestCapacity: map[resourceapi.QualifiedName]resource.Quantity{"encoders": resource.MustParse("1"), "decoders": resource.MustParse("1")},
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			testConsumableCapacity(t, tc.deviceCapacity, tc.requestCapacity)
+		})
+	}
+}
+
+func testConsumableCapacity(t *testing.T, deviceCapacity, requestCapacity map[resourceapi.QualifiedName]resource.Quantity) {
+	slice := st.MakeResourceSlice(nodeName, driver).
+		DeviceWithCapacity("instance-1", deviceCapacity).
+		Obj()
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+		EnableDRAConsumableCapacity:     true,
+	}
+	var pods []*v1.Pod
+	var claims []*resourceapi.ResourceClaim
+	for i := 0; i < 3; i++ {
+		podName := fmt.Sprintf("pod-%d", i)
+		claimName := podName + "-" + resourceName
+		pods = append(pods, st.MakePod().Name(podName).Namespace(namespace).
+			UID(podName+"-uid").
+			PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: &claimName}).
+			Obj())
+		claims = append(claims, st.MakeResourceClaim("").
+			Name(claimName).
+			Namespace(namespace).
+			RequestWithCapacity(className, requestCapacity).
+			Obj())
+	}
+	testCtx := setup(t, []*v1.Node{workerNode}, claims, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{slice}, features)
+	nodeInfo := testCtx.nodeInfos[0]
+
+	filter := func(pod *v1.Pod) (*framework.CycleState, *framework.Status) {
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, pod)
+		require.True(t, status.IsSuccess(), "PreFilter %s: %v", pod.Name, status)
+		return state, testCtx.p.Filter(testCtx.ctx, state, pod, nodeInfo)
+	}
+
+	// The first two pods share the device. Their allocations are
+	// in flight after Reserve.
+	var states []*framework.CycleState
+	for _, pod := range pods[:2] {
+		state, status := filter(pod)
+		require.True(t, status.IsSuccess(), "Filter %s: %v", pod.Name, status)
+		status = testCtx.p.Reserve(testCtx.ctx, state, pod, nodeName)
+		require.True(t, status.IsSuccess(), "Reserve %s: %v", pod.Name, status)
+		states = append(states, state)
+	}
+
+	// The third pod must not overcommit the device.
+	_, status := filter(pods[2])
+	assert.Equal(t, framework.Unschedulable, status.Code(), "Filter %s with in-flight allocations: %v", pods[2].Name, status)
+
+	// Same after storing the allocations, which must record what they consume.
+	for i, pod := range pods[:2] {
+		status := testCtx.p.PreBind(testCtx.ctx, states[i], pod, nodeName)
+		require.True(t, status.IsSuccess(), "PreBind %s: %v", pod.Name, status)
+		claim, err := testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).Get(testCtx.ctx, claims[i].Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.NotNil(t, claim.Status.Allocation, "allocation of %s", claim.Name)
+		require.Len(t, claim.Status.Allocation.Devices.Results, 1)
+		result := claim.Status.Allocation.Devices.Results[0]
+		assert.Equal(t, "instance-1", result.Device)
+		require.Len(t, result.ConsumedCapacity, len(requestCapacity))
+		for name, quantity := range requestCapacity {
+			assert.True(t, result.ConsumedCapacity[name].Equal(quantity), "consumed %s: %s", name, result.ConsumedCapacity)
+		}
+	}
+	_, status = filter(pods[2])
+	assert.Equal(t, framework.Unschedulable, status.Code(), "Filter %s with stored allocations: %v", pods[2].Name, status)
+}
+
+func TestUnavailableSlices(t *testing.T) {
+	oldGracePeriod := sliceSyncGracePeriod
+	sliceSyncGracePeriod = 0
+	t.Cleanup(func() { sliceSyncGracePeriod = oldGracePeriod })
+	metrics.RegisterMetrics()
+
+	tCtx := ktesting.Init(t)
+	claim := structuredClaim(pendingClaim)
+	client := fake.NewSimpleClientset(claim, deviceClass, workerNodeSlice)
+	var forbidden atomic.Bool
+	forbidden.Store(true)
+	client.PrependReactor("list", "resourceslices", func(action cgotesting.Action) (bool, apiruntime.Object, error) {
+		if forbidden.Load() {
+			return true, nil, apierrors.NewForbidden(resourceapi.Resource("resourceslices"), "", errors.New("fake error"))
+		}
+		return false, nil, nil
+	})
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	sliceInformer := informerFactory.Resource().V1alpha3().ResourceSlices().Informer()
+	pl, err := NewWithDependencies(tCtx, Dependencies{
+		Client:          client,
+		ClaimCache:      assumecache.NewAssumeCache(tCtx.Logger(), informerFactory.Resource().V1alpha3().ResourceClaims().Informer(), "resource claim", "", nil),
+		ClassLister:     informerFactory.Resource().V1alpha3().DeviceClasses().Lister(),
+		SliceLister:     informerFactory.Resource().V1alpha3().ResourceSlices().Lister(),
+		PodLister:       informerFactory.Core().V1().Pods().Lister(),
+		NodeLister:      informerFactory.Core().V1().Nodes().Lister(),
+		NamespaceLister: informerFactory.Core().V1().Namespaces().Lister(),
+		SliceInformer:   sliceInformer,
+		SliceSynced:     sliceInformer.HasSynced,
+		NodeInformer:    informerFactory.Core().V1().Nodes().Informer(),
+		Features:        feature.Features{EnableDynamicResourceAllocation: true},
+	})
+	require.NoError(t, err)
+	p := pl.(*dynamicResources)
+	informerFactory.Start(tCtx.Done())
+	t.Cleanup(func() {
+		tCtx.Cancel("test is done")
+		informerFactory.Shutdown()
+	})
+	require.True(t, cache.WaitForCacheSync(tCtx.Done(),
+		informerFactory.Resource().V1alpha3().ResourceClaims().Informer().HasSynced,
+		informerFactory.Resource().V1alpha3().DeviceClasses().Informer().HasSynced,
+	))
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(workerNode)
+
+	// Scheduling fails with an error which explains why instead of
+	// rejecting the node for lack of devices.
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		state := framework.NewCycleState()
+		_, status := p.PreFilter(tCtx, state, podWithClaimName)
+		if status.IsSuccess() {
+			status = p.Filter(tCtx, state, podWithClaimName, nodeInfo)
+		}
+		assert.Equal(t, framework.Error, status.Code(), "status: %v", status)
+		assert.ErrorContains(t, status.AsError(), "cannot list ResourceSlices: failed to list *v1alpha3.ResourceSlice: resourceslices.resource.k8s.io is forbidden: fake error")
+	}, time.Minute, 100*time.Millisecond)
+	value, err := testutil.GetGaugeMetricValue(metrics.ResourceSlicesUnavailable)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, value, "metric while unavailable")
+
+	// Recovery is automatic.
+	forbidden.Store(false)
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		state := framework.NewCycleState()
+		_, status := p.PreFilter(tCtx, state, podWithClaimName)
+		if assert.True(t, status.IsSuccess(), "PreFilter: %v", status) {
+			status = p.Filter(tCtx, state, podWithClaimName, nodeInfo)
+			assert.True(t, status.IsSuccess(), "Filter: %v", status)
+		}
+	}, time.Minute, 100*time.Millisecond)
+	value, err = testutil.GetGaugeMetricValue(metrics.ResourceSlicesUnavailable)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, value, "metric after recovery")
+}
+
+func TestOrphanedFinalizers(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+
+	// Has the finalizer without being allocated.
+	orphanedClaim := structuredClaim(pendingClaim)
+	orphanedClaim.Finalizers = structuredClaim(allocatedClaim).Finalizers
+	orphanedClaim.UID = "orphaned-uid"
+	orphanedClaim.ResourceVersion = "1"
+
+	// Allocated for a pod which doesn't exist anymore.
+	unusedClaim := st.FromResourceClaim(structuredClaim(allocatedClaim)).
+		Name(claimName2).
+		ReservedForPod("gone-pod", types.UID("gone-uid")).
+		Obj()
+	unusedClaim.UID = "unused-uid"
+	unusedClaim.ResourceVersion = "1"
+
+	// Allocated for an existing pod.
+	usedClaim := st.FromResourceClaim(structuredInUseClaim).
+		Name(otherClaim.Name).
+		Obj()
+	usedClaim.UID = "used-uid"
+	usedClaim.ResourceVersion = "1"
+
+	// Seed the objects before New is called, like they would exist
+	// when the scheduler starts.
+	objs := []apiruntime.Object{orphanedClaim, unusedClaim, usedClaim, podWithClaimName}
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	testCtx := setup(t, []*v1.Node{workerNode}, nil, nil, nil, objs, features, withClock(fakeClock))
+	otherCtx := setup(t, []*v1.Node{workerNode}, nil, nil, nil, nil, features)
+
+	// Plugins of earlier tests gave up ownership when they were done.
+	owner := func() *dynamicResources {
+		finalizerCheckOwner.mutex.Lock()
+		defer finalizerCheckOwner.mutex.Unlock()
+		return finalizerCheckOwner.pl
+	}
+	require.Nil(t, owner(), "no plugin checks finalizers before scheduling")
+
+	// The check starts with the first scheduling cycle. Plugins in other
+	// profiles leave it to the first one.
+	testCtx.p.PreFilter(testCtx.ctx, framework.NewCycleState(), podWithClaimName)
+	otherCtx.p.PreFilter(otherCtx.ctx, framework.NewCycleState(), podWithClaimName)
+	assert.Same(t, testCtx.p, owner(), "plugin checking finalizers")
+
+	// Nothing happens before the initial delay.
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		assert.True(t, fakeClock.HasWaiters(), "waiting for initial delay")
+	}, 10*time.Second, 10*time.Millisecond)
+	claim, err := testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).Get(testCtx.ctx, orphanedClaim.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, orphanedClaim.Finalizers, claim.Finalizers, "finalizers of claim without allocation before the check")
+	fakeClock.Step(testCtx.p.finalizerCheckDelay)
+
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		claim, err := testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).Get(testCtx.ctx, orphanedClaim.Name, metav1.GetOptions{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Empty(t, claim.Finalizers, "finalizers of claim without allocation")
+	}, 10*time.Second, 10*time.Millisecond)
+
+	select {
+	case event := <-testCtx.recorder.Events:
+		assert.Contains(t, event, v1.EventTypeWarning+" "+ReasonOrphanedFinalizer+" ")
+		assert.Contains(t, event, "resourceclaim controller")
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for warning event")
+	}
+
+	// Allocated claims are never modified.
+	for _, allocated := range []*resourceapi.ResourceClaim{unusedClaim, usedClaim} {
+		claim, err := testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).Get(testCtx.ctx, allocated.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, allocated.Finalizers, claim.Finalizers, "finalizers of allocated claim %s", allocated.Name)
+	}
+}
+
+func TestDebugHandler(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice}, features)
+
+	get := func(t *testing.T) DebugAllocations {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		testCtx.p.DebugHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		assert.Contains(t, recorder.Body.String(), `"claim":"`+namespace+`/`+claimName+`"`)
+		assert.Contains(t, recorder.Body.String(), `"device":"instance-1"`)
+		var allocations DebugAllocations
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &allocations))
+		return allocations
+	}
+
+	state := framework.NewCycleState()
+	_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos[0])
+	require.True(t, status.IsSuccess(), "Filter: %v", status)
+	status = testCtx.p.Reserve(testCtx.ctx, state, podWithClaimName, nodeName)
+	require.True(t, status.IsSuccess(), "Reserve: %v", status)
+
+	allocations := get(t)
+	require.Len(t, allocations.InFlight, 1, "in-flight allocations")
+	assert.Empty(t, allocations.Assumed, "assumed allocations")
+
+	recorder := httptest.NewRecorder()
+	testCtx.p.DebugHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestPreBindConflicts(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+
+	// removeFinalizer emulates someone else removing the finalizer
+	// together with each conflict.
+	preBind := func(t *testing.T, conflicts int, removeFinalizer bool) (*testContext, *framework.Status, int, []claimChange) {
+		t.Helper()
+		testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice, podWithClaimName}, features)
+		tracker := testCtx.client.Tracker()
+		var mutex sync.Mutex
+		attempts := 0
+		testCtx.client.PrependReactor("update", "resourceclaims", func(action cgotesting.Action) (bool, apiruntime.Object, error) {
+			if action.GetSubresource() != "status" {
+				return false, nil, nil
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			attempts++
+			if conflicts == 0 {
+				return false, nil, nil
+			}
+			conflicts--
+			if removeFinalizer {
+				obj, err := tracker.Get(action.GetResource(), namespace, claimName)
+				if err != nil {
+					return true, nil, err
+				}
+				claim := obj.(*resourceapi.ResourceClaim).DeepCopy()
+				claim.Finalizers = slices.DeleteFunc(claim.Finalizers, func(finalizer string) bool { return finalizer == resourceapi.Finalizer })
+				if err := tracker.Update(action.GetResource(), claim, namespace); err != nil {
+					return true, nil, err
+				}
+			}
+			return true, nil, apierrors.NewConflict(resourceapi.Resource("resourceclaims"), claimName, errors.New("injected conflict"))
+		})
+
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos[0])
+		require.True(t, status.IsSuccess(), "Filter: %v", status)
+		status = testCtx.p.Reserve(testCtx.ctx, state, podWithClaimName, nodeName)
+		require.True(t, status.IsSuccess(), "Reserve: %v", status)
+		status = testCtx.p.PreBind(testCtx.ctx, state, podWithClaimName, nodeName)
+		stateData, err := getStateData(state)
+		require.NoError(t, err)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		return testCtx, status, attempts, stateData.informationsForClaim[0].changes
+	}
+
+	t.Run("retried", func(t *testing.T) {
+		testCtx, status, attempts, changes := preBind(t, claimUpdateBackoff.Steps-1, false)
+		require.True(t, status.IsSuccess(), "PreBind: %v", status)
+		assert.Equal(t, claimUpdateBackoff.Steps, attempts, "status updates")
+		assert.Equal(t, []claimChange{claimAssumed, finalizerAdded, allocationWritten, reservationAdded}, changes, "recorded changes")
+		claim, err := testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).Get(testCtx.ctx, claimName, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.NotNil(t, claim.Status.Allocation, "allocation")
+		assert.True(t, resourceclaim.IsReservedForPod(podWithClaimName, claim), "reserved for pod")
+	})
+
+	t.Run("persistent", func(t *testing.T) {
+		testCtx, status, attempts, changes := preBind(t, claimUpdateBackoff.Steps, false)
+		assert.Equal(t, framework.Error, status.Code(), "PreBind: %v", status)
+		assert.Contains(t, status.Message(), "injected conflict", "PreBind")
+		assert.Equal(t, claimUpdateBackoff.Steps, attempts, "status updates")
+		assert.Equal(t, []claimChange{claimAssumed, finalizerAdded}, changes, "recorded changes")
+		claim, err := testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).Get(testCtx.ctx, claimName, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Nil(t, claim.Status.Allocation, "allocation")
+	})
+
+	t.Run("finalizer-removed", func(t *testing.T) {
+		// The finalizer gets added in each attempt, but that is
+		// still only one change which needs to be undone.
+		testCtx, status, attempts, changes := preBind(t, 1, true)
+		require.True(t, status.IsSuccess(), "PreBind: %v", status)
+		assert.Equal(t, 2, attempts, "status updates")
+		assert.Equal(t, []claimChange{claimAssumed, finalizerAdded, allocationWritten, reservationAdded}, changes, "recorded changes")
+		claim, err := testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).Get(testCtx.ctx, claimName, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Contains(t, claim.Finalizers, resourceapi.Finalizer, "finalizer")
+	})
+}
+
+func TestPreBindRollback(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	otherConsumer := resourceapi.ResourceClaimConsumerReference{Resource: "pods", Name: "other-pod", UID: "other-pod-uid"}
+
+	// preBind fails to store the status of the second claim. consume gets
+	// called for the first claim, which is stored already at that point.
+	preBind := func(t *testing.T, consume func(claim *resourceapi.ResourceClaim)) *testContext {
+		t.Helper()
+		testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim), structuredClaim(pendingClaim2)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeCardSlice, podWithTwoClaimNames}, features)
+		tracker := testCtx.client.Tracker()
+		testCtx.client.PrependReactor("update", "resourceclaims", func(action cgotesting.Action) (bool, apiruntime.Object, error) {
+			claim := action.(cgotesting.UpdateAction).GetObject().(*resourceapi.ResourceClaim)
+			if action.GetSubresource() != "status" || claim.Name != claimName2 {
+				return false, nil, nil
+			}
+			if consume != nil {
+				obj, err := tracker.Get(action.GetResource(), namespace, claimName)
+				if err != nil {
+					return true, nil, err
+				}
+				claim := obj.(*resourceapi.ResourceClaim).DeepCopy()
+				consume(claim)
+				claim.ResourceVersion = "concurrent-update"
+				if err := tracker.Update(action.GetResource(), claim, namespace); err != nil {
+					return true, nil, err
+				}
+			}
+			return true, nil, apierrors.NewInternalError(errors.New("injected error"))
+		})
+
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithTwoClaimNames)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		status = testCtx.p.Filter(testCtx.ctx, state, podWithTwoClaimNames, testCtx.nodeInfos[0])
+		require.True(t, status.IsSuccess(), "Filter: %v", status)
+		status = testCtx.p.Reserve(testCtx.ctx, state, podWithTwoClaimNames, nodeName)
+		require.True(t, status.IsSuccess(), "Reserve: %v", status)
+		status = testCtx.p.PreBind(testCtx.ctx, state, podWithTwoClaimNames, nodeName)
+		require.Equal(t, framework.Error, status.Code(), "PreBind: %v", status)
+		assert.Contains(t, status.Message(), "injected error", "PreBind")
+		testCtx.p.Unreserve(testCtx.ctx, state, podWithTwoClaimNames, nodeName)
+		return testCtx
+	}
+	getClaim := func(t *testing.T, testCtx *testContext, name string) (*resourceapi.ResourceClaim, *resourceapi.ResourceClaim) {
+		t.Helper()
+		claim, err := testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).Get(testCtx.ctx, name, metav1.GetOptions{})
+		require.NoError(t, err)
+		obj, err := testCtx.claimAssumeCache.Get(namespace + "/" + name)
+		require.NoError(t, err)
+		return claim, obj.(*resourceapi.ResourceClaim)
+	}
+
+	t.Run("rolled-back", func(t *testing.T) {
+		testCtx := preBind(t, nil)
+		for _, name := range []string{claimName, claimName2} {
+			claim, cachedClaim := getClaim(t, testCtx, name)
+			assert.Nil(t, claim.Status.Allocation, "allocation of %s", name)
+			assert.Empty(t, claim.Status.ReservedFor, "reservations of %s", name)
+			assert.NotContains(t, claim.Finalizers, resourceapi.Finalizer, "finalizer of %s", name)
+			assert.Nil(t, cachedClaim.Status.Allocation, "allocation of %s in assume cache", name)
+			_, inFlight := testCtx.p.inFlightAllocations.load(claim.UID)
+			assert.False(t, inFlight, "allocation of %s in flight", name)
+		}
+	})
+
+	t.Run("concurrently-consumed", func(t *testing.T) {
+		testCtx := preBind(t, func(claim *resourceapi.ResourceClaim) {
+			claim.Status.ReservedFor = append(claim.Status.ReservedFor, otherConsumer)
+		})
+		claim, _ := getClaim(t, testCtx, claimName)
+		assert.NotNil(t, claim.Status.Allocation, "allocation of %s", claimName)
+		assert.Equal(t, []resourceapi.ResourceClaimConsumerReference{otherConsumer}, claim.Status.ReservedFor, "reservations of %s", claimName)
+		assert.Contains(t, claim.Finalizers, resourceapi.Finalizer, "finalizer of %s", claimName)
+		claim, _ = getClaim(t, testCtx, claimName2)
+		assert.Nil(t, claim.Status.Allocation, "allocation of %s", claimName2)
+		assert.NotContains(t, claim.Finalizers, resourceapi.Finalizer, "finalizer of %s", claimName2)
+	})
+}
+
+func TestUnreserveUndoesChanges(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+
+	// The first claim was allocated before, the second one gets
+	// allocated in the scheduling cycle.
+	schedule := func(t *testing.T, preBind bool) (*testContext, *framework.CycleState) {
+		t.Helper()
+		testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(allocatedClaim), structuredClaim(pendingClaim2)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeCardSlice, podWithTwoClaimNames}, features)
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithTwoClaimNames)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		status = testCtx.p.Filter(testCtx.ctx, state, podWithTwoClaimNames, testCtx.nodeInfos[0])
+		require.True(t, status.IsSuccess(), "Filter: %v", status)
+		status = testCtx.p.Reserve(testCtx.ctx, state, podWithTwoClaimNames, nodeName)
+		require.True(t, status.IsSuccess(), "Reserve: %v", status)
+		stateData, err := getStateData(state)
+		require.NoError(t, err)
+		assert.Empty(t, stateData.informationsForClaim[0].changes, "changes of %s after Reserve", claimName)
+		assert.Equal(t, []claimChange{claimAssumed}, stateData.informationsForClaim[1].changes, "changes of %s after Reserve", claimName2)
+		if preBind {
+			status = testCtx.p.PreBind(testCtx.ctx, state, podWithTwoClaimNames, nodeName)
+			require.True(t, status.IsSuccess(), "PreBind: %v", status)
+			assert.Equal(t, []claimChange{reservationAdded}, stateData.informationsForClaim[0].changes, "changes of %s after PreBind", claimName)
+			assert.Equal(t, []claimChange{claimAssumed, finalizerAdded, allocationWritten, reservationAdded}, stateData.informationsForClaim[1].changes, "changes of %s after PreBind", claimName2)
+		}
+		testCtx.p.Unreserve(testCtx.ctx, state, podWithTwoClaimNames, nodeName)
+		for i := range stateData.informationsForClaim {
+			assert.Empty(t, stateData.informationsForClaim[i].changes, "changes of claim #%d after Unreserve", i)
+		}
+		return testCtx, state
+	}
+	check := func(t *testing.T, testCtx *testContext) {
+		t.Helper()
+		claim, err := testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).Get(testCtx.ctx, claimName, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.NotNil(t, claim.Status.Allocation, "allocation of %s", claimName)
+		assert.Contains(t, claim.Finalizers, resourceapi.Finalizer, "finalizer of %s", claimName)
+		assert.False(t, resourceclaim.IsReservedForPod(podWithTwoClaimNames, claim), "%s reserved for pod", claimName)
+
+		claim, err = testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).Get(testCtx.ctx, claimName2, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Nil(t, claim.Status.Allocation, "allocation of %s", claimName2)
+		assert.NotContains(t, claim.Finalizers, resourceapi.Finalizer, "finalizer of %s", claimName2)
+		assert.Empty(t, claim.Status.ReservedFor, "reservations of %s", claimName2)
+		_, inFlight := testCtx.p.inFlightAllocations.load(claim.UID)
+		assert.False(t, inFlight, "allocation of %s in flight", claimName2)
+		obj, err := testCtx.claimAssumeCache.Get(namespace + "/" + claimName2)
+		require.NoError(t, err)
+		assert.Nil(t, obj.(*resourceapi.ResourceClaim).Status.Allocation, "allocation of %s in assume cache", claimName2)
+	}
+
+	t.Run("skip-bind", func(t *testing.T) {
+		testCtx, _ := schedule(t, false)
+		check(t, testCtx)
+	})
+
+	t.Run("bind-failure", func(t *testing.T) {
+		testCtx, _ := schedule(t, true)
+		check(t, testCtx)
+	})
+}
+
+func TestAllocationTimeout(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+		EnableDRAControlPlaneController: true,
+	}
+	start := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	withTimeout := func(timeout string) *resourceapi.ResourceClaim {
+		claim := pendingClaim.DeepCopy()
+		claim.Annotations = map[string]string{AnnotationAllocationTimeout: timeout}
+		return claim
+	}
+
+	// attempt runs one scheduling cycle up to Reserve for the node that
+	// the driver was asked to allocate for.
+	attempt := func(t *testing.T, testCtx *testContext) *framework.Status {
+		t.Helper()
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos[0])
+		require.True(t, status.IsSuccess(), "Filter: %v", status)
+		status = testCtx.p.Reserve(testCtx.ctx, state, podWithClaimName, nodeName)
+		if !status.IsSuccess() {
+			testCtx.p.Unreserve(testCtx.ctx, state, podWithClaimName, nodeName)
+		}
+		return status
+	}
+
+	for name, tc := range map[string]struct {
+		claim         *resourceapi.ResourceClaim
+		expectTimeout bool
+	}{
+		"no-timeout": {
+			claim: pendingClaim,
+		},
+		"claim-timeout": {
+			claim:         withTimeout("1m"),
+			expectTimeout: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fakeClock := testingclock.NewFakeClock(start)
+			testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{tc.claim}, []*resourceapi.DeviceClass{deviceClass}, []*resourceapi.PodSchedulingContext{schedulingSelectedPotential}, nil, features, withClock(fakeClock))
+
+			status := attempt(t, testCtx)
+			require.Equal(t, framework.Pending, status.Code(), "Reserve at start: %v", status)
+			fakeClock.SetTime(start.Add(30 * time.Second))
+			status = attempt(t, testCtx)
+			require.Equal(t, framework.Pending, status.Code(), "Reserve before timeout: %v", status)
+
+			fakeClock.SetTime(start.Add(time.Minute))
+			status = attempt(t, testCtx)
+			schedulingCtx, err := testCtx.client.ResourceV1alpha3().PodSchedulingContexts(namespace).Get(testCtx.ctx, podName, metav1.GetOptions{})
+			require.NoError(t, err, "get PodSchedulingContext")
+			if !tc.expectTimeout {
+				assert.Equal(t, framework.Pending, status.Code(), "Reserve without timeout: %v", status)
+				assert.Equal(t, nodeName, schedulingCtx.Spec.SelectedNode, "selected node")
+				return
+			}
+			assert.Equal(t, framework.NewStatus(framework.UnschedulableAndUnresolvable, "claim default/"+claimName+": resource driver did not allocate within 1m0s"), status, "Reserve after timeout")
+			assert.Empty(t, schedulingCtx.Spec.SelectedNode, "selected node")
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{withTimeout("soon")}, []*resourceapi.DeviceClass{deviceClass}, nil, nil, features)
+		status := testCtx.p.PreEnqueue(testCtx.ctx, podWithClaimName)
+		assert.Equal(t, framework.UnschedulableAndUnresolvable, status.Code(), "PreEnqueue: %v", status)
+		assert.Contains(t, status.Message(), "claim default/"+claimName+": annotation "+AnnotationAllocationTimeout, "PreEnqueue")
+	})
+}
+
+func TestSchedulingCompletedDeleteFailure(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+		EnableDRAControlPlaneController: true,
+	}
+
+	postBind := func(t *testing.T, failures int) *testContext {
+		t.Helper()
+		testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{allocatedClaim}, []*resourceapi.DeviceClass{deviceClass}, []*resourceapi.PodSchedulingContext{schedulingInfo}, nil, features)
+		tracker := testCtx.client.Tracker()
+		var mutex sync.Mutex
+		testCtx.client.PrependReactor("delete", "podschedulingcontexts", func(action cgotesting.Action) (bool, apiruntime.Object, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if failures == 0 {
+				// The fake client ignores preconditions.
+				deleteAction := action.(cgotesting.DeleteAction)
+				if preconditions := deleteAction.GetDeleteOptions().Preconditions; preconditions != nil && preconditions.UID != nil {
+					obj, err := tracker.Get(action.GetResource(), action.GetNamespace(), deleteAction.GetName())
+					if err == nil && obj.(metav1.Object).GetUID() != *preconditions.UID {
+						return true, nil, apierrors.NewConflict(action.GetResource().GroupResource(), deleteAction.GetName(), errors.New("UID mismatch"))
 					}
+				}
+				return false, nil, nil
+			}
+			failures--
+			return true, nil, apierrors.NewServiceUnavailable("injected error")
+		})
+
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		testCtx.p.PostBind(testCtx.ctx, state, podWithClaimName, nodeName)
+		return testCtx
+	}
+
+	t.Run("retry", func(t *testing.T) {
+		testCtx := postBind(t, 1)
+		require.EventuallyWithT(t, func(t *assert.CollectT) {
+			_, err := testCtx.client.ResourceV1alpha3().PodSchedulingContexts(namespace).Get(testCtx.ctx, podName, metav1.GetOptions{})
+			assert.True(t, apierrors.IsNotFound(err), "PodSchedulingContext should have been deleted, got error: %v", err)
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("recreated-for-other-pod", func(t *testing.T) {
+		testCtx := postBind(t, 1)
+		schedulingCtx, err := testCtx.client.ResourceV1alpha3().PodSchedulingContexts(namespace).Get(testCtx.ctx, podName, metav1.GetOptions{})
+		require.NoError(t, err)
+		ref := schedulingContextRef{namespace: namespace, name: podName, podUID: podWithClaimName.UID, uid: schedulingCtx.UID}
+		require.EventuallyWithT(t, func(t *assert.CollectT) {
+			assert.Equal(t, 1, testCtx.p.schedulingContextQueue.NumRequeues(ref), "failed attempts")
+		}, 10*time.Second, time.Millisecond)
+
+		// Replace the object before the retry, as if the pod had been
+		// recreated with the same name and a different UID.
+		err = testCtx.client.ResourceV1alpha3().PodSchedulingContexts(namespace).Delete(testCtx.ctx, podName, metav1.DeleteOptions{})
+		require.NoError(t, err)
+		other := st.FromPodSchedulingContexts(scheduling).OwnerReference(podName, "other-pod-uid", podKind).Obj()
+		_, err = testCtx.client.ResourceV1alpha3().PodSchedulingContexts(namespace).Create(testCtx.ctx, other, metav1.CreateOptions{})
+		require.NoError(t, err)
+
+		// Wait for the retry to be done with the object, then check
+		// that it was left alone.
+		testCtx.waitForSchedulingContexts(t)
+		_, err = testCtx.client.ResourceV1alpha3().PodSchedulingContexts(namespace).Get(testCtx.ctx, podName, metav1.GetOptions{})
+		require.NoError(t, err, "PodSchedulingContext of other pod")
+	})
+}
+
+func TestSchedulingCompletedInBackground(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+		EnableDRAControlPlaneController: true,
+	}
+
+	postBind := func(t *testing.T, maxPending int) (*testContext, chan struct{}) {
+		t.Helper()
+		testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{allocatedClaim}, []*resourceapi.DeviceClass{deviceClass}, []*resourceapi.PodSchedulingContext{schedulingInfo}, nil, features, withOptions(func(pl *dynamicResources) {
+			pl.maxPendingSchedulingContexts = maxPending
+		}))
+		release := make(chan struct{})
+		testCtx.client.PrependReactor("delete", "podschedulingcontexts", func(action cgotesting.Action) (bool, apiruntime.Object, error) {
+			<-release
+			return false, nil, nil
+		})
+
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		if maxPending == 0 {
+			// PostBind blocks.
+			close(release)
+		}
+		testCtx.p.PostBind(testCtx.ctx, state, podWithClaimName, nodeName)
+		return testCtx, release
+	}
+	get := func(testCtx *testContext) error {
+		_, err := testCtx.client.ResourceV1alpha3().PodSchedulingContexts(namespace).Get(testCtx.ctx, podName, metav1.GetOptions{})
+		return err
+	}
+
+	t.Run("background", func(t *testing.T) {
+		testCtx, release := postBind(t, defaultMaxPendingSchedulingContexts)
+
+		// PostBind returned although the deletion cannot complete yet.
+		require.NoError(t, get(testCtx), "PodSchedulingContext after PostBind")
+		close(release)
+		require.EventuallyWithT(t, func(t *assert.CollectT) {
+			err := get(testCtx)
+			assert.True(t, apierrors.IsNotFound(err), "PodSchedulingContext should have been deleted, got error: %v", err)
+		}, 10*time.Second, 10*time.Millisecond)
+		testCtx.waitForSchedulingContexts(t)
+	})
+
+	t.Run("prebind", func(t *testing.T) {
+		testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{allocatedClaim}, []*resourceapi.DeviceClass{deviceClass}, []*resourceapi.PodSchedulingContext{schedulingInfo}, nil, features)
+		release := make(chan struct{})
+		testCtx.client.PrependReactor("delete", "podschedulingcontexts", func(action cgotesting.Action) (bool, apiruntime.Object, error) {
+			<-release
+			return false, nil, nil
+		})
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		status = testCtx.p.PreBind(testCtx.ctx, state, podWithClaimName, nodeName)
+		require.True(t, status.IsSuccess(), "PreBind: %v", status)
+
+		// PreBind returned although the deletion cannot complete yet.
+		require.NoError(t, get(testCtx), "PodSchedulingContext after PreBind")
+		close(release)
+		require.EventuallyWithT(t, func(t *assert.CollectT) {
+			err := get(testCtx)
+			assert.True(t, apierrors.IsNotFound(err), "PodSchedulingContext should have been deleted, got error: %v", err)
+		}, 10*time.Second, 10*time.Millisecond)
+		testCtx.waitForSchedulingContexts(t)
+	})
+
+	t.Run("limit-reached", func(t *testing.T) {
+		testCtx, _ := postBind(t, 0)
+		err := get(testCtx)
+		require.True(t, apierrors.IsNotFound(err), "PodSchedulingContext should have been deleted by PostBind, got error: %v", err)
+		require.Zero(t, testCtx.p.pendingSchedulingContexts.len(), "pending deletions")
+	})
+}
+
+func TestNewArgs(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	tCtx := ktesting.Init(t)
+	client := fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	opts := []runtime.Option{
+		runtime.WithClientSet(client),
+		runtime.WithInformerFactory(informerFactory),
+		runtime.WithResourceClaimCache(assumecache.NewAssumeCache(tCtx.Logger(), informerFactory.Resource().V1alpha3().ResourceClaims().Informer(), "resource claim", "", nil)),
+	}
+	fh, err := runtime.NewFramework(tCtx, nil, nil, opts...)
+	require.NoError(t, err)
+
+	pl, err := New(tCtx, &config.DynamicResourcesArgs{WriteStrategy: config.PatchWriteStrategy, DeviceSelectionPolicy: config.BestFitDeviceSelectionPolicy, MissingAttributeBehavior: config.ErrorMissingAttributeBehavior}, fh, features)
+	require.NoError(t, err)
+	assert.Equal(t, config.PatchWriteStrategy, pl.(*dynamicResources).writeStrategy)
+
+	pl, err = New(tCtx, nil, fh, features)
+	require.NoError(t, err)
+	assert.Equal(t, config.UpdateWriteStrategy, pl.(*dynamicResources).writeStrategy, "default")
+	assert.Equal(t, structured.BestFit, pl.(*dynamicResources).selectionPolicy, "default selection policy")
+
+	_, err = New(tCtx, &config.DynamicResourcesArgs{WriteStrategy: "Apply", DeviceSelectionPolicy: config.BestFitDeviceSelectionPolicy, MissingAttributeBehavior: config.ErrorMissingAttributeBehavior}, fh, features)
+	assert.Error(t, err, "unknown write strategy")
+}
+
+func TestDisabledInProfile(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	tCtx := ktesting.Init(t)
+	client := fake.NewSimpleClientset(structuredClaim(pendingClaim), deviceClass, workerNodeSlice)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	claimCache := assumecache.NewAssumeCache(tCtx.Logger(), informerFactory.Resource().V1alpha3().ResourceClaims().Informer(), "resource claim", "", nil)
+	opts := []runtime.Option{
+		runtime.WithClientSet(client),
+		runtime.WithInformerFactory(informerFactory),
+		runtime.WithResourceClaimCache(claimCache),
+	}
+	fh, err := runtime.NewFramework(tCtx, nil, nil, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		tCtx.Cancel("test is done")
+		informerFactory.Shutdown()
+	})
+
+	// The default profile does not use DRA.
+	disabled, err := New(tCtx, &config.DynamicResourcesArgs{Disabled: true}, fh, features)
+	require.NoError(t, err, "disabled plugin")
+	informerFactory.Start(tCtx.Done())
+	synced := informerFactory.WaitForCacheSync(tCtx.Done())
+	assert.Len(t, synced, 1, "only the ResourceClaim informer of the assume cache should exist")
+
+	// The "gpu" profile uses it.
+	enabled, err := New(tCtx, nil, fh, features)
+	require.NoError(t, err, "enabled plugin")
+	assert.Same(t, claimCache, enabled.(*dynamicResources).claimAssumeCache, "shared assume cache")
+	informerFactory.Start(tCtx.Done())
+	informerFactory.WaitForCacheSync(tCtx.Done())
+
+	_, status := disabled.(framework.PreFilterPlugin).PreFilter(tCtx, framework.NewCycleState(), podWithClaimName)
+	assert.Equal(t, framework.NewStatus(framework.UnschedulableAndUnresolvable, "DRA disabled in this profile"), status, "disabled profile")
+	_, status = disabled.(framework.PreFilterPlugin).PreFilter(tCtx, framework.NewCycleState(), st.MakePod().Name("foo").Namespace(namespace).Obj())
+	assert.Equal(t, framework.NewStatus(framework.Skip), status, "disabled profile, pod without claims")
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		_, status := enabled.(framework.PreFilterPlugin).PreFilter(tCtx, framework.NewCycleState(), podWithClaimName)
+		assert.True(t, status.IsSuccess(), "enabled profile: %v", status)
+	}, time.Minute, 10*time.Millisecond)
+}
+
+func TestNewWithDependencies(t *testing.T) {
+	tCtx := ktesting.Init(t)
+	pl, err := NewWithDependencies(tCtx, Dependencies{})
+	require.NoError(t, err, "disabled")
+	assert.False(t, pl.(*dynamicResources).enabled, "disabled")
+
+	_, err = NewWithDependencies(tCtx, Dependencies{Features: feature.Features{EnableDynamicResourceAllocation: true}})
+	assert.EqualError(t, err, "missing dependency Client")
+}
+
+func TestNamespacePolicy(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: map[string]string{"dra": "enabled"}}}
+	notAllowed := framework.NewStatus(framework.UnschedulableAndUnresolvable, "ResourceClaims are not allowed in namespace "+namespace+" by the scheduler configuration")
+
+	for name, tc := range map[string]struct {
+		args         config.DynamicResourcesArgs
+		expectStatus *framework.Status
+	}{
+		"default": {},
+		"allowed": {
+			args: config.DynamicResourcesArgs{AllowedNamespaces: []string{namespace}},
+		},
+		"not-allowed": {
+			args:         config.DynamicResourcesArgs{AllowedNamespaces: []string{"team-a"}},
+			expectStatus: notAllowed,
+		},
+		"selector-matches": {
+			args: config.DynamicResourcesArgs{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"dra": "enabled"}}},
+		},
+		"selector-does-not-match": {
+			args:         config.DynamicResourcesArgs{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"dra": "disabled"}}},
+			expectStatus: notAllowed,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{ns, workerNodeSlice}, features, withArgs(func(args *config.DynamicResourcesArgs) {
+				args.AllowedNamespaces = tc.args.AllowedNamespaces
+				args.NamespaceSelector = tc.args.NamespaceSelector
+			}))
+
+			_, status := testCtx.p.PreFilter(testCtx.ctx, testCtx.state, podWithClaimName)
+			if tc.expectStatus != nil {
+				assert.Equal(t, tc.expectStatus, status, "PreFilter")
+			} else {
+				assert.True(t, status.IsSuccess(), "PreFilter: %v", status)
+			}
+
+			// Pods without claims are not affected.
+			_, status = testCtx.p.PreFilter(testCtx.ctx, framework.NewCycleState(), st.MakePod().Name("foo").Namespace(namespace).Obj())
+			assert.Equal(t, framework.NewStatus(framework.Skip), status, "PreFilter without claims")
+
+			storedClaim, err := testCtx.claimAssumeCache.Get(namespace + "/" + claimName)
+			require.NoError(t, err, "retrieve claim")
+			hint, err := testCtx.p.isSchedulableAfterClaimChange(klog.FromContext(testCtx.ctx), podWithClaimName, nil, storedClaim)
+			require.NoError(t, err, "claim hint")
+			if tc.expectStatus != nil {
+				assert.Equal(t, framework.QueueSkip, hint, "claim hint")
+			} else {
+				assert.Equal(t, framework.Queue, hint, "claim hint")
+			}
+		})
+	}
+}
+
+func TestMultiplePoolsOnOneNode(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	claim := st.MakeResourceClaim(controller).
+		Name(claimName).
+		Namespace(namespace).
+		Request(className).
+		Request(className).
+		OwnerReference(podName, podUID, podKind).
+		Structured().
+		Obj()
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{claim}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice, workerNodeSecondPoolSlice}, features)
+
+	_, status := testCtx.p.PreFilter(testCtx.ctx, testCtx.state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, testCtx.state, podWithClaimName, testCtx.nodeInfos[0])
+	require.True(t, status.IsSuccess(), "Filter: %v", status)
+	status = testCtx.p.Reserve(testCtx.ctx, testCtx.state, podWithClaimName, nodeName)
+	require.True(t, status.IsSuccess(), "Reserve: %v", status)
+
+	inFlight := testCtx.listInFlightClaims()
+	require.Len(t, inFlight, 1, "in-flight claims")
+	allocation := inFlight[0].(*resourceapi.ResourceClaim).Status.Allocation
+	require.NotNil(t, allocation, "allocation")
+	// Which request gets which pool depends on the order of the pools.
+	var pools []string
+	for _, result := range allocation.Devices.Results {
+		assert.Equal(t, "instance-1", result.Device, "device for %s", result.Request)
+		pools = append(pools, result.Pool)
+	}
+	assert.ElementsMatch(t, []string{nodeName, nodeName + "-numa-1"}, pools, "pools")
+	// The node selector pins the allocation to the node, not to one of the pools.
+	assert.Equal(t, allocationResult.NodeSelector, allocation.NodeSelector, "node selector")
+}
+
+func TestDeviceQuota(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	otherClaimName := otherPodName + "-" + resourceName
+	otherPod := st.MakePod().Name(otherPodName).Namespace(namespace).
+		UID(otherPodName + "-uid").
+		PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: &otherClaimName}).
+		Obj()
+	otherClaim := st.FromResourceClaim(structuredClaim(pendingClaim)).
+		Name(otherClaimName).
+		OwnerReference(otherPodName, otherPodName+"-uid", podKind).
+		Obj()
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim), otherClaim}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeCardSlice}, features, withArgs(func(args *config.DynamicResourcesArgs) {
+		args.DeviceQuotas = []config.DeviceQuota{{Namespace: namespace, DeviceClassName: className, MaxDevices: 1}}
+	}))
+
+	// Both pods fit onto the node, but only one of them may get a device.
+	state := framework.NewCycleState()
+	otherState := framework.NewCycleState()
+	for _, pod := range []struct {
+		pod   *v1.Pod
+		state *framework.CycleState
+	}{{podWithClaimName, state}, {otherPod, otherState}} {
+		_, status := testCtx.p.PreFilter(testCtx.ctx, pod.state, pod.pod)
+		require.True(t, status.IsSuccess(), "%s: PreFilter: %v", pod.pod.Name, status)
+		status = testCtx.p.Filter(testCtx.ctx, pod.state, pod.pod, testCtx.nodeInfos[0])
+		require.True(t, status.IsSuccess(), "%s: Filter: %v", pod.pod.Name, status)
+	}
+
+	status := testCtx.p.Reserve(testCtx.ctx, state, podWithClaimName, nodeName)
+	require.True(t, status.IsSuccess(), "Reserve: %v", status)
+	status = testCtx.p.Reserve(testCtx.ctx, otherState, otherPod, nodeName)
+	assert.Equal(t, framework.Unschedulable, status.Code(), "Reserve of other pod: %v", status)
+	assert.Contains(t, status.Message(), "device quota exceeded", "reason")
+	testCtx.p.Unreserve(testCtx.ctx, otherState, otherPod, nodeName)
+	assert.Len(t, testCtx.listInFlightClaims(), 1, "in-flight claims after denied Reserve")
+
+	// Releasing the first pod makes room for the other one.
+	testCtx.p.Unreserve(testCtx.ctx, state, podWithClaimName, nodeName)
+	stateData, err := getStateData(state)
+	require.NoError(t, err)
+	assert.Empty(t, stateData.quotaReservations, "quota reservations after Unreserve")
+	status = testCtx.p.Reserve(testCtx.ctx, otherState, otherPod, nodeName)
+	require.True(t, status.IsSuccess(), "Reserve of other pod after Unreserve: %v", status)
+}
+
+func TestMissingSlices(t *testing.T) {
+	metrics.RegisterMetrics()
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	// The slice of the second node keeps the claim allocatable in
+	// the cluster.
+	fakeClock := testingclock.NewFakeClock(time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC))
+	testCtx := setup(t, []*v1.Node{workerNode, workerNode2}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNode, workerNodeSlice, workerNode2Slice}, features, withClock(fakeClock))
+	missingSlices := metrics.MissingSlices.WithLabelValues(driver, nodeName)
+
+	filter := func() *framework.Status {
+		t.Helper()
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		return testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos[0])
+	}
+	status := filter()
+	require.True(t, status.IsSuccess(), "Filter before deleting slice: %v", status)
+
+	require.NoError(t, testCtx.client.ResourceV1alpha3().ResourceSlices().Delete(testCtx.ctx, workerNodeSlice.Name, metav1.DeleteOptions{}))
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		slices, err := testCtx.p.sliceLister.List(labels.Everything())
+		require.NoError(t, err)
+		assert.Len(t, slices, 1)
+	}, time.Minute, 10*time.Millisecond, "slice must be removed from informer cache")
+
+	warning := v1.EventTypeWarning + " " + ReasonMissingResourceSlices + " ResourceSlices of driver " + driver + " are no longer published for the node, pods cannot use its devices"
+	rejectAndGetEvents := func(what string) []string {
+		t.Helper()
+		status := filter()
+		assert.Equal(t, framework.Unschedulable, status.Code(), "Filter %s: %v", what, status)
+		var events []string
+		for len(testCtx.recorder.Events) > 0 {
+			events = append(events, <-testCtx.recorder.Events)
+		}
+		return events
+	}
+
+	// Only the first rejection emits an event until the warning
+	// interval has passed.
+	assert.Equal(t, []string{warning}, rejectAndGetEvents("after deleting slice"), "events of first rejection")
+	assert.Empty(t, rejectAndGetEvents("again"), "events of second rejection")
+	fakeClock.Step(missingSlicesWarningInterval - time.Second)
+	assert.Empty(t, rejectAndGetEvents("before warning interval"), "events before warning interval")
+	fakeClock.Step(time.Second)
+	assert.Equal(t, []string{warning}, rejectAndGetEvents("after warning interval"), "events after warning interval")
+	value, err := testutil.GetGaugeMetricValue(missingSlices)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, value, "metric after deleting slice")
+
+	// Deleting the node cleans up.
+	require.NoError(t, testCtx.client.CoreV1().Nodes().Delete(testCtx.ctx, nodeName, metav1.DeleteOptions{}))
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		testCtx.p.sliceTracker.mutex.Lock()
+		defer testCtx.p.sliceTracker.mutex.Unlock()
+		assert.Len(t, testCtx.p.sliceTracker.entries, 1)
+	}, time.Minute, 10*time.Millisecond, "node must be forgotten")
+	value, err = testutil.GetGaugeMetricValue(metrics.MissingSlices.WithLabelValues(driver, nodeName))
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, value, "metric after deleting node")
+}
+
+func TestMissingSlicesOtherDriver(t *testing.T) {
+	metrics.RegisterMetrics()
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	// The class only selects devices of some other driver, which
+	// publishes them for the second node.
+	const otherDriver = "other.example.com"
+	class := deviceClass.DeepCopy()
+	class.Spec.Selectors = []resourceapi.DeviceSelector{{CEL: &resourceapi.CELDeviceSelector{Expression: `device.driver == "` + otherDriver + `"`}}}
+	otherSlice := st.MakeResourceSlice(node2Name, otherDriver).Device("instance-1", nil).Obj()
+	testCtx := setup(t, []*v1.Node{workerNode, workerNode2}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{class}, nil, []apiruntime.Object{workerNode, workerNodeSlice, otherSlice}, features)
+
+	require.NoError(t, testCtx.client.ResourceV1alpha3().ResourceSlices().Delete(testCtx.ctx, workerNodeSlice.Name, metav1.DeleteOptions{}))
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		slices, err := testCtx.p.sliceLister.List(labels.Everything())
+		require.NoError(t, err)
+		assert.Len(t, slices, 1)
+	}, time.Minute, 10*time.Millisecond, "slice must be removed from informer cache")
+
+	state := framework.NewCycleState()
+	_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos[0])
+	assert.Equal(t, framework.Unschedulable, status.Code(), "Filter: %v", status)
+
+	// The pod does not need the driver whose slices are missing.
+	assert.Empty(t, testCtx.recorder.Events, "events")
+	value, err := testutil.GetGaugeMetricValue(metrics.MissingSlices.WithLabelValues(driver, nodeName))
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, value, "metric")
+}
+
+func TestDriverReadiness(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	fakeClock := testingclock.NewFakeClock(testTime)
+	testCtx := setup(t, []*v1.Node{workerNode, workerNode2}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice}, features, withClock(fakeClock), withArgs(func(args *config.DynamicResourcesArgs) {
+		args.DriverReadinessSeconds = 60
+	}))
+	testCtx.waitForPools(t, 1)
+
+	// The pool of the first node was published a while ago, the one of
+	// the second node just now.
+	fakeClock.Step(5 * time.Minute)
+	readySlice := st.MakeResourceSlice(node2Name, driver).Device("instance-1", nil).Obj()
+	_, err := testCtx.client.ResourceV1alpha3().ResourceSlices().Create(testCtx.ctx, readySlice, metav1.CreateOptions{})
+	require.NoError(t, err, "create resource slice")
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		assert.Equal(t, map[string]sets.Set[string]{node2Name: sets.New(driver)}, testCtx.p.readyDrivers())
+	}, time.Minute, 10*time.Millisecond, "new pool must be observed")
+
+	_, status := testCtx.p.PreFilter(testCtx.ctx, testCtx.state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, testCtx.state, podWithClaimName, testCtx.nodeInfos[0])
+	assert.Equal(t, framework.NewStatus(framework.Unschedulable, "DRA driver "+driver+" not ready on node"), status, "Filter %s", nodeName)
+	status = testCtx.p.Filter(testCtx.ctx, testCtx.state, podWithClaimName, testCtx.nodeInfos[1])
+	require.True(t, status.IsSuccess(), "Filter %s: %v", node2Name, status)
+	status = testCtx.p.Reserve(testCtx.ctx, testCtx.state, podWithClaimName, node2Name)
+	require.True(t, status.IsSuccess(), "Reserve: %v", status)
+
+	inFlight := testCtx.listInFlightClaims()
+	require.Len(t, inFlight, 1, "in-flight claims")
+	allocation := inFlight[0].(*resourceapi.ResourceClaim).Status.Allocation
+	require.NotNil(t, allocation, "allocation")
+	assert.Equal(t, node2Name, allocation.Devices.Results[0].Pool, "pool")
+}
+
+func TestPoolGenerations(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC))
+	p := &poolGenerations{clock: fakeClock}
+	handler := p.resourceEventHandler()
+	slice := st.MakeResourceSlice(nodeName, driver).Device("instance-1", nil).Obj()
+	secondSlice := slice.DeepCopy()
+	secondSlice.Name += "-2"
+	networkSlice := st.MakeResourceSlice("", driver).Pool("network").Device("instance-1", nil).Obj()
+	ready := func() map[string]sets.Set[string] {
+		return p.readyDrivers(time.Minute)
+	}
+
+	handler.OnAdd(slice, true)
+	handler.OnAdd(secondSlice, true)
+	handler.OnAdd(networkSlice, true)
+	assert.Equal(t, map[string]sets.Set[string]{nodeName: sets.New(driver)}, ready(), "added")
+
+	fakeClock.Step(2 * time.Minute)
+	assert.Empty(t, ready(), "old generation")
+
+	// Updates without a new generation do not count.
+	updatedSlice := slice.DeepCopy()
+	updatedSlice.Labels = map[string]string{"some": "label"}
+	handler.OnUpdate(slice, updatedSlice)
+	assert.Empty(t, ready(), "same generation")
+
+	newSlice := updatedSlice.DeepCopy()
+	newSlice.Spec.Pool.Generation++
+	handler.OnUpdate(updatedSlice, newSlice)
+	assert.Equal(t, map[string]sets.Set[string]{nodeName: sets.New(driver)}, ready(), "new generation")
+
+	// The pool is gone once all of its slices are.
+	handler.OnDelete(newSlice)
+	assert.Equal(t, map[string]sets.Set[string]{nodeName: sets.New(driver)}, ready(), "one slice deleted")
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: secondSlice.Name, Obj: secondSlice})
+	assert.Empty(t, p.pools, "all slices deleted")
+}
+
+func TestTargetNode(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	nodeNamePod := podWithClaimName.DeepCopy()
+	nodeNamePod.Spec.NodeName = node2Name
+	// Like a DaemonSet pod.
+	affinityPod := podWithClaimName.DeepCopy()
+	affinityPod.Spec.Affinity = &v1.Affinity{
+		NodeAffinity: &v1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+				NodeSelectorTerms: []v1.NodeSelectorTerm{{
+					MatchFields: []v1.NodeSelectorRequirement{{Key: metav1.ObjectNameField, Operator: v1.NodeSelectorOpIn, Values: []string{node2Name}}},
+				}},
+			},
+		},
+	}
+
+	for name, pod := range map[string]*v1.Pod{
+		"node-name":     nodeNamePod,
+		"node-affinity": affinityPod,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var calls atomic.Int64
+			testCtx := setup(t, []*v1.Node{workerNode, workerNode2, workerNode3}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice, workerNode2Slice, workerNode3Slice}, features, withOptions(WithDeviceScorers(countingDeviceScorer{calls: &calls})))
+
+			_, status := testCtx.p.PreFilter(testCtx.ctx, testCtx.state, pod)
+			require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+			for _, nodeInfo := range testCtx.nodeInfos {
+				before := calls.Load()
+				status := testCtx.p.Filter(testCtx.ctx, testCtx.state, pod, nodeInfo)
+				if nodeInfo.Node().Name == node2Name {
+					assert.True(t, status.IsSuccess(), "Filter %s: %v", nodeInfo.Node().Name, status)
+					assert.Greater(t, calls.Load(), before, "allocation attempted on %s", nodeInfo.Node().Name)
+				} else {
+					assert.Equal(t, framework.NewStatus(framework.UnschedulableAndUnresolvable, "pod can only run on node "+node2Name), status, "Filter %s", nodeInfo.Node().Name)
+					assert.Equal(t, before, calls.Load(), "allocation attempted on %s", nodeInfo.Node().Name)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	for name, tc := range map[string]struct {
+		value       string
+		expectError bool
+	}{
+		"valid":         {value: "2024-07-01T10:00:00Z/2024-07-01T11:00:00Z"},
+		"empty":         {value: "2024-07-01T10:00:00Z/2024-07-01T10:00:00Z"},
+		"no-separator":  {value: "2024-07-01T10:00:00Z", expectError: true},
+		"invalid-start": {value: "today/2024-07-01T11:00:00Z", expectError: true},
+		"invalid-end":   {value: "2024-07-01T10:00:00Z/tomorrow", expectError: true},
+		"reversed":      {value: "2024-07-01T11:00:00Z/2024-07-01T10:00:00Z", expectError: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := parseMaintenanceWindow(tc.value)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
 
-					initialObjects = testCtx.listAll(t)
-					initialObjects = testCtx.updateAPIServer(t, initialObjects, tc.prepare.prebind)
-					status := testCtx.p.PreBind(testCtx.ctx, testCtx.state, tc.pod, selectedNode.Node().Name)
-					t.Run("prebind", func(t *testing.T) {
-						testCtx.verify(t, tc.want.prebind, initialObjects, nil, status)
-					})
-
-					if tc.want.unreserveAfterBindFailure != nil {
-						initialObjects = testCtx.listAll(t)
-						testCtx.p.Unreserve(testCtx.ctx, testCtx.state, tc.pod, selectedNode.Node().Name)
-						t.Run("unreserverAfterBindFailure", func(t *testing.T) {
-							testCtx.verify(t, *tc.want.unreserveAfterBindFailure, initialObjects, nil, status)
-						})
-					} else if status.IsSuccess() {
-						initialObjects = testCtx.listAll(t)
-						initialObjects = testCtx.updateAPIServer(t, initialObjects, tc.prepare.postbind)
-						testCtx.p.PostBind(testCtx.ctx, testCtx.state, tc.pod, selectedNode.Node().Name)
-						t.Run("postbind", func(t *testing.T) {
-							testCtx.verify(t, tc.want.postbind, initialObjects, nil, nil)
-						})
-					}
+func TestEscalation(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+
+	// attempt runs PreFilter, Filter and PostFilter for the pod the way
+	// the scheduler does it for the given attempt when no node fits.
+	attempt := func(t *testing.T, testCtx *testContext, pod *v1.Pod, attempts int) (filterStatus, postFilterStatus *framework.Status) {
+		t.Helper()
+		state := framework.NewCycleState()
+		state.Write(framework.PodSchedulingAttemptsKey, &framework.PodSchedulingAttempts{Attempts: attempts})
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, pod)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		filterStatus = testCtx.p.Filter(testCtx.ctx, state, pod, testCtx.nodeInfos[0])
+		_, postFilterStatus = testCtx.p.PostFilter(testCtx.ctx, state, pod, nil)
+		return filterStatus, postFilterStatus
+	}
+
+	t.Run("filter", func(t *testing.T) {
+		testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{heartbeatSlice}, features, withClock(testingclock.NewFakeClock(testTime.Add(2*time.Minute))), withArgs(func(args *config.DynamicResourcesArgs) {
+			args.ResourceSliceMaxAgeSeconds = 60
+			args.FailedAttemptsBeforeEscalation = 2
+		}))
+
+		for attempts := 1; attempts <= 2; attempts++ {
+			status, _ := attempt(t, testCtx, podWithClaimName, attempts)
+			assert.Equal(t, framework.NewStatus(framework.Unschedulable, "resource slices stale for driver "+driver), status, "Filter in attempt #%d", attempts)
+		}
+		status, _ := attempt(t, testCtx, podWithClaimName, 3)
+		assert.Equal(t, framework.NewStatus(framework.Unschedulable, ErrReasonCannotAllocate), status, "Filter after two failed attempts")
+	})
+
+	t.Run("postfilter", func(t *testing.T) {
+		claims := []*resourceapi.ResourceClaim{
+			structuredClaim(allocatedClaimWithWrongTopology),
+			st.FromResourceClaim(structuredClaim(allocatedClaimWithWrongTopology)).Name(claimName2).Obj(),
+		}
+		// deallocated counts the claims whose allocation was cleared.
+		deallocated := func(t *testing.T, testCtx *testContext) int {
+			t.Helper()
+			claims, err := testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).List(testCtx.ctx, metav1.ListOptions{})
+			require.NoError(t, err, "list claims")
+			count := 0
+			for _, claim := range claims.Items {
+				if claim.Status.Allocation == nil {
+					count++
 				}
-			} else if len(potentialNodes) == 0 {
-				initialObjects = testCtx.listAll(t)
-				initialObjects = testCtx.updateAPIServer(t, initialObjects, tc.prepare.postfilter)
-				result, status := testCtx.p.PostFilter(testCtx.ctx, testCtx.state, tc.pod, nil /* filteredNodeStatusMap not used by plugin */)
-				t.Run("postfilter", func(t *testing.T) {
-					assert.Equal(t, tc.want.postFilterResult, result)
-					testCtx.verify(t, tc.want.postfilter, initialObjects, nil, status)
-				})
 			}
+			return count
+		}
+
+		for attempts, expect := range map[int]struct {
+			status      *framework.Status
+			deallocated int
+		}{
+			1: {status: framework.NewStatus(framework.Unschedulable, "deallocation of ResourceClaim completed"), deallocated: 1},
+			2: {status: framework.NewStatus(framework.Unschedulable, "deallocation of 2 ResourceClaim(s) completed"), deallocated: 2},
+		} {
+			t.Run(fmt.Sprintf("attempt-%d", attempts), func(t *testing.T) {
+				testCtx := setup(t, []*v1.Node{workerNode}, claims, nil, nil, []apiruntime.Object{podWithTwoClaimNames}, features, withArgs(func(args *config.DynamicResourcesArgs) {
+					args.FailedAttemptsBeforeEscalation = 1
+				}))
+
+				status, postFilterStatus := attempt(t, testCtx, podWithTwoClaimNames, attempts)
+				assert.Equal(t, framework.NewStatus(framework.UnschedulableAndUnresolvable, "resourceclaim not available on the node"), status, "Filter")
+				assert.Equal(t, expect.status, postFilterStatus, "PostFilter")
+				assert.Equal(t, expect.deallocated, deallocated(t, testCtx), "deallocated claims")
+			})
+		}
+	})
+}
+
+func TestFilterErrors(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	brokenClaim := breakCELInClaim(structuredClaim(pendingClaim))
+	celErr := `claim default/my-pod-my-resource: request req-1: selector #0: CEL runtime error: no such key: ` + string(attrName)
+
+	filter := func(t *testing.T, testCtx *testContext, state *framework.CycleState) []*framework.NodeInfo {
+		t.Helper()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		var feasibleNodes []*framework.NodeInfo
+		for _, nodeInfo := range testCtx.nodeInfos {
+			status := testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, nodeInfo)
+			require.NotEqual(t, framework.Error, status.Code(), "Filter %s: %v", nodeInfo.Node().Name, status)
+			if status.IsSuccess() {
+				feasibleNodes = append(feasibleNodes, nodeInfo)
+			}
+		}
+		return feasibleNodes
+	}
+	events := func(testCtx *testContext) []string {
+		var events []string
+		for len(testCtx.recorder.Events) > 0 {
+			events = append(events, <-testCtx.recorder.Events)
+		}
+		return events
+	}
+	wantEvents := []string{v1.EventTypeWarning + " " + ReasonAllocationFailed + " Allocating devices failed on 1 node(s), for example on node " + nodeName + ": " + celErr}
+
+	t.Run("node-local-error-one-of-three-nodes", func(t *testing.T) {
+		// Only the devices on workerNode lack the attribute, so the
+		// other nodes are used. PreScore must not fail.
+		testCtx := setup(t, []*v1.Node{workerNode, workerNode2, workerNode3}, []*resourceapi.ResourceClaim{brokenClaim}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice, workerNode2Slice, workerNode3Slice}, features)
+		state := framework.NewCycleState()
+		feasibleNodes := filter(t, testCtx, state)
+		require.Len(t, feasibleNodes, 2, "feasible nodes")
+		status := testCtx.p.PreScore(testCtx.ctx, state, podWithClaimName, feasibleNodes)
+		require.True(t, status.IsSuccess(), "PreScore: %v", status)
+		status = testCtx.p.Reserve(testCtx.ctx, state, podWithClaimName, feasibleNodes[0].Node().Name)
+		require.True(t, status.IsSuccess(), "Reserve: %v", status)
+		assert.Equal(t, wantEvents, events(testCtx), "events")
+	})
+
+	t.Run("node-local-error-one-of-two-nodes", func(t *testing.T) {
+		// PreScore is not called for a single feasible node,
+		// Reserve reports the error instead.
+		testCtx := setup(t, []*v1.Node{workerNode, workerNode2}, []*resourceapi.ResourceClaim{brokenClaim}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice, workerNode2Slice}, features)
+		state := framework.NewCycleState()
+		feasibleNodes := filter(t, testCtx, state)
+		require.Len(t, feasibleNodes, 1, "feasible nodes")
+		assert.Equal(t, node2Name, feasibleNodes[0].Node().Name, "feasible node")
+		status := testCtx.p.Reserve(testCtx.ctx, state, podWithClaimName, node2Name)
+		require.True(t, status.IsSuccess(), "Reserve: %v", status)
+		assert.Equal(t, wantEvents, events(testCtx), "events")
+	})
+
+	t.Run("claim-wide-error", func(t *testing.T) {
+		// None of the devices have the attribute, so the error is the
+		// same everywhere and the pod must remain pending because of it.
+		workerNode2SliceWithoutAttribute := st.MakeResourceSlice(node2Name, driver).Device("instance-1", nil).Obj()
+		testCtx := setup(t, []*v1.Node{workerNode, workerNode2}, []*resourceapi.ResourceClaim{brokenClaim}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice, workerNode2SliceWithoutAttribute}, features)
+		state := framework.NewCycleState()
+		feasibleNodes := filter(t, testCtx, state)
+		require.Empty(t, feasibleNodes, "feasible nodes")
+		_, status := testCtx.p.PostFilter(testCtx.ctx, state, podWithClaimName, nil)
+		require.Equal(t, framework.Error, status.Code(), "PostFilter: %v", status)
+		assert.EqualError(t, status.AsError(), "pod default/my-pod: "+celErr)
+		assert.Empty(t, events(testCtx), "events")
+	})
+}
+
+func TestPostFilterOtherPluginRejected(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice, podWithClaimName}, features)
+
+	// The devices are available, but some other plugin rejected the node.
+	state := framework.NewCycleState()
+	_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos[0])
+	require.True(t, status.IsSuccess(), "Filter: %v", status)
+	_, status = testCtx.p.PostFilter(testCtx.ctx, state, podWithClaimName, nil)
+	assert.Equal(t, framework.NewStatus(framework.Unschedulable, "still not schedulable"), status, "PostFilter")
+
+	pod, err := testCtx.client.CoreV1().Pods(namespace).Get(testCtx.ctx, podName, metav1.GetOptions{})
+	require.NoError(t, err, "get pod")
+	_, condition := podutil.GetPodCondition(&pod.Status, PodConditionResourceClaimsReady)
+	assert.Nil(t, condition, "pod condition")
+}
+
+func TestNodePreferences(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	preferredNode := &st.MakeNode().Name(node2Name).Label("kubernetes.io/hostname", node2Name).Label("example.com/numa-aligned", "true").Node
+
+	score := func(t *testing.T, annotation string) (nodeScore, node2Score int64) {
+		t.Helper()
+		class := deviceClass.DeepCopy()
+		if annotation != "" {
+			class.Annotations = map[string]string{AnnotationNodePreferences: annotation}
+		}
+		testCtx := setup(t, []*v1.Node{workerNode, preferredNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{class}, nil, []apiruntime.Object{workerNodeSlice, workerNode2Slice}, features)
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		for _, nodeInfo := range testCtx.nodeInfos {
+			status := testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, nodeInfo)
+			require.True(t, status.IsSuccess(), "Filter %s: %v", nodeInfo.Node().Name, status)
+		}
+		status = testCtx.p.PreScore(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos)
+		require.True(t, status.IsSuccess(), "PreScore: %v", status)
+
+		scores := make(framework.NodeScoreList, 0, len(testCtx.nodeInfos))
+		for _, nodeInfo := range testCtx.nodeInfos {
+			score, status := testCtx.p.Score(testCtx.ctx, state, podWithClaimName, nodeInfo.Node().Name)
+			require.True(t, status.IsSuccess(), "Score %s: %v", nodeInfo.Node().Name, status)
+			scores = append(scores, framework.NodeScore{Name: nodeInfo.Node().Name, Score: score})
+		}
+		status = testCtx.p.ScoreExtensions().NormalizeScore(testCtx.ctx, state, podWithClaimName, scores)
+		require.True(t, status.IsSuccess(), "NormalizeScore: %v", status)
+		for _, score := range scores {
+			switch score.Name {
+			case nodeName:
+				nodeScore = score.Score
+			case node2Name:
+				node2Score = score.Score
+			}
+		}
+		return nodeScore, node2Score
+	}
+
+	t.Run("none", func(t *testing.T) {
+		nodeScore, node2Score := score(t, "")
+		assert.Equal(t, nodeScore, node2Score, "scores")
+	})
+
+	t.Run("preferred", func(t *testing.T) {
+		nodeScore, node2Score := score(t, `[{"labelKey": "example.com/numa-aligned", "labelValues": ["true"], "weight": 10}]`)
+		assert.Equal(t, int64(0), nodeScore, "score of other node")
+		assert.Equal(t, int64(framework.MaxNodeScore), node2Score, "score of preferred node")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		nodeScore, node2Score := score(t, `[{"labelKey": "example.com/numa-aligned", "weight": 1000}]`)
+		assert.Equal(t, nodeScore, node2Score, "scores")
+	})
+}
+
+func TestScoredAttributes(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	// The device on the first node has less memory, but more bandwidth.
+	// The first node is also hotter, as reported by a sensor device.
+	nodeSlice := st.MakeResourceSlice(nodeName, driver).Device("instance-1", map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+		"memory": {IntValue: ptr.To(int64(40))},
+		resourceapi.QualifiedName(driver + "/bandwidth"): {IntValue: ptr.To(int64(2))},
+		"other.example.com/powered":                      {BoolValue: ptr.To(true)},
+	}).Device("sensor", map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+		"temperature": {IntValue: ptr.To(int64(85))},
+	}).Obj()
+	node2Slice := st.MakeResourceSlice(node2Name, driver).Device("instance-1", map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+		"memory": {IntValue: ptr.To(int64(80))},
+		resourceapi.QualifiedName(driver + "/bandwidth"): {IntValue: ptr.To(int64(1))},
+		"other.example.com/powered":                      {BoolValue: ptr.To(true)},
+	}).Device("sensor", map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+		"temperature": {IntValue: ptr.To(int64(45))},
+	}).Obj()
+
+	score := func(t *testing.T, annotation string) (nodeScore, node2Score int64) {
+		t.Helper()
+		claim := structuredClaim(pendingClaim).DeepCopy()
+		claim.Annotations = map[string]string{AnnotationScoredAttributes: annotation}
+		testCtx := setup(t, []*v1.Node{workerNode, workerNode2}, []*resourceapi.ResourceClaim{claim}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{nodeSlice, node2Slice}, features)
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		for _, nodeInfo := range testCtx.nodeInfos {
+			status := testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, nodeInfo)
+			require.True(t, status.IsSuccess(), "Filter %s: %v", nodeInfo.Node().Name, status)
+		}
+		status = testCtx.p.PreScore(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos)
+		require.True(t, status.IsSuccess(), "PreScore: %v", status)
+		nodeScore, status = testCtx.p.Score(testCtx.ctx, state, podWithClaimName, nodeName)
+		require.True(t, status.IsSuccess(), "Score %s: %v", nodeName, status)
+		node2Score, status = testCtx.p.Score(testCtx.ctx, state, podWithClaimName, node2Name)
+		require.True(t, status.IsSuccess(), "Score %s: %v", node2Name, status)
+		return nodeScore, node2Score
+	}
+
+	testcases := map[string]struct {
+		annotation                        string
+		expectNodeScore, expectNode2Score int64
+	}{
+		"bandwidth-weighs-more": {
+			annotation:       `[{"name": "` + driver + `/memory", "weight": 30}, {"name": "` + driver + `/bandwidth", "weight": 50}]`,
+			expectNodeScore:  50,
+			expectNode2Score: 30,
+		},
+		"memory-weighs-more": {
+			annotation:       `[{"name": "` + driver + `/memory", "weight": 60}, {"name": "` + driver + `/bandwidth", "weight": 50}]`,
+			expectNodeScore:  50,
+			expectNode2Score: 60,
+		},
+		"lower": {
+			annotation:       `[{"name": "` + driver + `/memory", "weight": 60, "direction": "Lower"}, {"name": "` + driver + `/bandwidth", "weight": 50}]`,
+			expectNodeScore:  110,
+			expectNode2Score: 0,
+		},
+		"same-value": {
+			annotation: `[{"name": "other.example.com/powered", "weight": 100}]`,
+		},
+		"cooler-node": {
+			annotation:       `[{"name": "` + driver + `/temperature", "weight": 40, "direction": "Lower", "source": "Node"}]`,
+			expectNodeScore:  0,
+			expectNode2Score: 40,
+		},
+		"invalid-source": {
+			annotation: `[{"name": "` + driver + `/temperature", "weight": 40, "source": "Cluster"}]`,
+		},
+		"invalid": {
+			annotation: `[{"name": "memory", "weight": 30}]`,
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			nodeScore, node2Score := score(t, tc.annotation)
+			assert.Equal(t, tc.expectNodeScore, nodeScore, "score of %s", nodeName)
+			assert.Equal(t, tc.expectNode2Score, node2Score, "score of %s", node2Name)
 		})
 	}
 }
 
-type testContext struct {
-	ctx              context.Context
-	client           *fake.Clientset
-	informerFactory  informers.SharedInformerFactory
-	claimAssumeCache *assumecache.AssumeCache
-	p                *dynamicResources
-	nodeInfos        []*framework.NodeInfo
-	state            *framework.CycleState
+func TestNominatedNode(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	testCtx := setup(t, []*v1.Node{workerNode, workerNode2}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice, workerNode2Slice}, features)
+	pod := podWithClaimName.DeepCopy()
+	pod.Status.NominatedNodeName = node2Name
+	state := framework.NewCycleState()
+	_, status := testCtx.p.PreFilter(testCtx.ctx, state, pod)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	for _, nodeInfo := range testCtx.nodeInfos {
+		status := testCtx.p.Filter(testCtx.ctx, state, pod, nodeInfo)
+		require.True(t, status.IsSuccess(), "Filter %s: %v", nodeInfo.Node().Name, status)
+	}
+	status = testCtx.p.PreScore(testCtx.ctx, state, pod, testCtx.nodeInfos)
+	require.True(t, status.IsSuccess(), "PreScore: %v", status)
+
+	// Pick the node with the highest score, like the framework does.
+	scores := make(framework.NodeScoreList, 0, len(testCtx.nodeInfos))
+	for _, nodeInfo := range testCtx.nodeInfos {
+		score, status := testCtx.p.Score(testCtx.ctx, state, pod, nodeInfo.Node().Name)
+		require.True(t, status.IsSuccess(), "Score %s: %v", nodeInfo.Node().Name, status)
+		scores = append(scores, framework.NodeScore{Name: nodeInfo.Node().Name, Score: score})
+	}
+	status = testCtx.p.ScoreExtensions().NormalizeScore(testCtx.ctx, state, pod, scores)
+	require.True(t, status.IsSuccess(), "NormalizeScore: %v", status)
+	selectedNode := scores[0]
+	for _, score := range scores[1:] {
+		if score.Score > selectedNode.Score {
+			selectedNode = score
+		}
+	}
+	assert.Equal(t, node2Name, selectedNode.Name, "selected node")
+	assert.Equal(t, int64(framework.MaxNodeScore), selectedNode.Score, "score of nominated node")
+
+	status = testCtx.p.Reserve(testCtx.ctx, state, pod, selectedNode.Name)
+	require.True(t, status.IsSuccess(), "Reserve: %v", status)
+	var nodeNames []string
+	testCtx.p.inFlightAllocations.forEach(func(allocation *inFlightAllocation) bool {
+		nodeNames = append(nodeNames, allocation.nodeName)
+		return true
+	})
+	assert.Equal(t, []string{node2Name}, nodeNames, "nodes of in-flight allocations")
 }
 
-func (tc *testContext) verify(t *testing.T, expected result, initialObjects []metav1.Object, result interface{}, status *framework.Status) {
-	t.Helper()
-	if expectedErr := status.AsError(); expectedErr != nil {
-		// Compare only the error strings.
-		assert.ErrorContains(t, status.AsError(), expectedErr.Error())
-	} else {
-		assert.Equal(t, expected.status, status)
+func TestPotentialNodesOrder(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+		EnableDRAControlPlaneController: true,
 	}
-	objects := tc.listAll(t)
-	wantObjects := update(t, initialObjects, expected.changes)
-	wantObjects = append(wantObjects, expected.added...)
-	for _, remove := range expected.removed {
-		for i, obj := range wantObjects {
-			// This is a bit relaxed (no GVR comparison, no UID
-			// comparison) to simplify writing the test cases.
-			if obj.GetName() == remove.GetName() && obj.GetNamespace() == remove.GetNamespace() {
-				wantObjects = append(wantObjects[0:i], wantObjects[i+1:]...)
-				break
+	nodes := []*v1.Node{workerNode, workerNode2, workerNode3}
+
+	// The framework may pass the feasible nodes in any order. What
+	// gets published must not depend on it.
+	for _, order := range [][]int{{0, 1, 2}, {2, 1, 0}, {1, 2, 0}} {
+		t.Run(fmt.Sprintf("%v", order), func(t *testing.T) {
+			testCtx := setup(t, nodes, []*resourceapi.ResourceClaim{pendingClaim, pendingClaim2}, []*resourceapi.DeviceClass{deviceClass}, nil, nil, features)
+			state := framework.NewCycleState()
+			_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithTwoClaimNames)
+			require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+			var feasibleNodes []*framework.NodeInfo
+			for _, index := range order {
+				nodeInfo := testCtx.nodeInfos[index]
+				status := testCtx.p.Filter(testCtx.ctx, state, podWithTwoClaimNames, nodeInfo)
+				require.True(t, status.IsSuccess(), "Filter %s: %v", nodeInfo.Node().Name, status)
+				feasibleNodes = append(feasibleNodes, nodeInfo)
+			}
+			status = testCtx.p.PreScore(testCtx.ctx, state, podWithTwoClaimNames, feasibleNodes)
+			require.True(t, status.IsSuccess(), "PreScore: %v", status)
+
+			stateData, err := getStateData(state)
+			require.NoError(t, err)
+			assert.Equal(t, &[]string{nodeName, node2Name, node3Name}, stateData.podSchedulingState.potentialNodes, "potential nodes")
+		})
+	}
+}
+
+func TestParseNodePreferences(t *testing.T) {
+	testcases := map[string]struct {
+		value       string
+		expect      []NodePreference
+		expectError bool
+	}{
+		"valid": {
+			value:  `[{"labelKey": "example.com/rack", "labelValues": ["a", "b"], "weight": 5}, {"labelKey": "gpu", "weight": 100}]`,
+			expect: []NodePreference{{LabelKey: "example.com/rack", LabelValues: []string{"a", "b"}, Weight: 5}, {LabelKey: "gpu", Weight: 100}},
+		},
+		"empty": {
+			value:  `[]`,
+			expect: []NodePreference{},
+		},
+		"not-json": {
+			value:       `rack=a`,
+			expectError: true,
+		},
+		"unknown-field": {
+			value:       `[{"labelKey": "rack", "weight": 1, "required": true}]`,
+			expectError: true,
+		},
+		"bad-key": {
+			value:       `[{"labelKey": "-rack-", "weight": 1}]`,
+			expectError: true,
+		},
+		"zero-weight": {
+			value:       `[{"labelKey": "rack"}]`,
+			expectError: true,
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			preferences, err := parseNodePreferences(tc.value)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, preferences)
+		})
+	}
+}
+
+func TestDescribePod(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	claim := structuredClaim(allocatedClaim)
+	testCtx := setup(t, nil, []*resourceapi.ResourceClaim{claim}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNode, workerNode2, podWithClaimName}, features)
+	before := testCtx.listAll(t)
+
+	description, err := testCtx.p.DescribePod(testCtx.ctx, podWithClaimName)
+	require.NoError(t, err)
+	require.Len(t, description.Claims, 1, "claims")
+	assert.Equal(t, ClaimState{PodClaimName: resourceName, ClaimName: claimName, Phase: ClaimPhaseAllocated}, description.Claims[0].ClaimState, "claim state")
+	assert.Equal(t, claim.Status.Allocation, description.Claims[0].Allocation, "allocation")
+	assert.Nil(t, description.Claims[0].InFlightAllocation, "in-flight allocation")
+	assert.Empty(t, description.Unschedulable, "unschedulable")
+	assert.Equal(t, []string{nodeName}, description.FeasibleNodes, "feasible nodes")
+	assert.Contains(t, description.RejectedNodes, node2Name, "rejected nodes")
+	assert.NotContains(t, description.RejectedNodes, nodeName, "rejected nodes")
+	assert.Nil(t, description.SchedulingContext, "scheduling context")
+
+	assert.Equal(t, before, testCtx.listAll(t), "objects after DescribePod")
+	assert.Empty(t, testCtx.listInFlightClaims(), "in-flight claims after DescribePod")
+}
+
+func TestDescribePodSideEffects(t *testing.T) {
+	metrics.RegisterMetrics()
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	count := func(extensionPoint string) uint64 {
+		t.Helper()
+		vec, err := testutil.GetHistogramVecFromGatherer(legacyregistry.DefaultGatherer, "scheduler_dra_extension_point_duration_seconds", map[string]string{"extension_point": extensionPoint, "driver": ""})
+		if err != nil {
+			// Nothing observed yet.
+			return 0
+		}
+		return vec.GetAggregatedSampleCount()
+	}
+
+	// The first node has lost its slice, so Filter would report it as
+	// missing. The slice of the second node keeps the claim allocatable.
+	testCtx := setup(t, []*v1.Node{workerNode, workerNode2}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNode, workerNode2, workerNodeSlice, workerNode2Slice}, features, withClock(testingclock.NewFakeClock(time.Now())), withArgs(func(args *config.DynamicResourcesArgs) {
+		args.ClaimEventCoalescingMilliseconds = time.Minute.Milliseconds()
+	}))
+	require.NoError(t, testCtx.client.ResourceV1alpha3().ResourceSlices().Delete(testCtx.ctx, workerNodeSlice.Name, metav1.DeleteOptions{}))
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		slices, err := testCtx.p.sliceLister.List(labels.Everything())
+		require.NoError(t, err)
+		assert.Len(t, slices, 1)
+	}, time.Minute, 10*time.Millisecond, "slice must be removed from informer cache")
+	require.True(t, testCtx.p.claimEvents.requeue(podWithClaimName), "first requeue")
+
+	missingSlices := metrics.MissingSlices.WithLabelValues(driver, nodeName)
+	missingBefore, err := testutil.GetGaugeMetricValue(missingSlices)
+	require.NoError(t, err)
+	preFilterBefore, filterBefore := count(schedulermetrics.PreFilter), count(schedulermetrics.Filter)
+
+	description, err := testCtx.p.DescribePod(testCtx.ctx, podWithClaimName)
+	require.NoError(t, err)
+	assert.Equal(t, []string{node2Name}, description.FeasibleNodes, "feasible nodes")
+	assert.Contains(t, description.RejectedNodes, nodeName, "rejected nodes")
+
+	assert.Empty(t, testCtx.recorder.Events, "events")
+	missingAfter, err := testutil.GetGaugeMetricValue(missingSlices)
+	require.NoError(t, err)
+	assert.Equal(t, missingBefore, missingAfter, "missing slices metric")
+	assert.Equal(t, preFilterBefore, count(schedulermetrics.PreFilter), "PreFilter duration samples")
+	assert.Equal(t, filterBefore, count(schedulermetrics.Filter), "Filter duration samples")
+	testCtx.p.podFilterCache.mutex.Lock()
+	assert.Empty(t, testCtx.p.podFilterCache.entries, "pod filter cache")
+	testCtx.p.podFilterCache.mutex.Unlock()
+	assert.False(t, testCtx.p.claimEvents.requeue(podWithClaimName), "requeue must still be coalesced")
+}
+
+func TestAllocatedDevices(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	ignore := cmpopts.IgnoreFields(AllocatedDevice{}, "ClaimResourceVersion")
+
+	t.Run("structured-with-resources", func(t *testing.T) {
+		testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice, podWithClaimName}, features)
+		devices, err := testCtx.p.AllocatedDevices(nodeName)
+		require.NoError(t, err)
+		assert.Empty(t, devices.Devices, "devices before scheduling")
+
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos[0])
+		require.True(t, status.IsSuccess(), "Filter: %v", status)
+		status = testCtx.p.Reserve(testCtx.ctx, state, podWithClaimName, nodeName)
+		require.True(t, status.IsSuccess(), "Reserve: %v", status)
+
+		expect := []AllocatedDevice{{
+			Driver:   driver,
+			Pool:     nodeName,
+			Device:   "instance-1",
+			Claim:    types.NamespacedName{Namespace: namespace, Name: claimName},
+			InFlight: true,
+		}}
+		devices, err = testCtx.p.AllocatedDevices(nodeName)
+		require.NoError(t, err)
+		if diff := cmp.Diff(expect, devices.Devices, ignore); diff != "" {
+			t.Errorf("devices after Reserve (-want, +got):\n%s", diff)
+		}
+
+		status = testCtx.p.PreBind(testCtx.ctx, state, podWithClaimName, nodeName)
+		require.True(t, status.IsSuccess(), "PreBind: %v", status)
+
+		expect[0].InFlight = false
+		expect[0].Pods = []string{podName}
+		devices, err = testCtx.p.AllocatedDevices(nodeName)
+		require.NoError(t, err)
+		if diff := cmp.Diff(expect, devices.Devices, ignore); diff != "" {
+			t.Errorf("devices after PreBind (-want, +got):\n%s", diff)
+		}
+	})
+
+	t.Run("structured-exhausted-resources", func(t *testing.T) {
+		testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim), structuredClaim(otherAllocatedClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice}, features)
+		expect := []AllocatedDevice{{
+			Driver: driver,
+			Pool:   nodeName,
+			Device: "instance-1",
+			Claim:  types.NamespacedName{Namespace: namespace, Name: otherAllocatedClaim.Name},
+		}}
+		devices, err := testCtx.p.AllocatedDevices(nodeName)
+		require.NoError(t, err)
+		assert.Equal(t, nodeName, devices.NodeName, "node name")
+		assert.False(t, devices.Time.IsZero(), "time")
+		if diff := cmp.Diff(expect, devices.Devices, ignore); diff != "" {
+			t.Errorf("devices (-want, +got):\n%s", diff)
+		}
+
+		devices, err = testCtx.p.AllocatedDevices(node2Name)
+		require.NoError(t, err)
+		assert.Empty(t, devices.Devices, "devices of node without slices")
+	})
+}
+
+// TestAllocatedDevicesTrackerStress modifies claims concurrently in the
+// same way as the informer and the plugin do. It is meant to be run with
+// the race detector. Each time that all changes of a round are done, the
+// snapshot must match the devices of the allocated claims in the assume
+// cache.
+func TestAllocatedDevicesTrackerStress(t *testing.T) {
+	const (
+		numClaims     = 20
+		numDevices    = 10
+		numRounds     = 100
+		numIterations = 20
+	)
+	tCtx := ktesting.Init(t)
+	claimCache := assumecache.NewAssumeCache(tCtx.Logger(), nil, "resource claim", "", nil)
+	var inFlight inFlightAllocations
+	tracker := newAllocatedDevicesTracker(claimCache, &inFlight)
+	inFlight.deviceTracker = tracker
+	claimCache.AddEventHandler(tracker.claimEventHandler())
+
+	var version atomic.Int64
+	newClaim := func(i int, allocate bool) *resourceapi.ResourceClaim {
+		claim := st.MakeResourceClaim(controller).
+			Name(fmt.Sprintf("claim-%d", i)).
+			Namespace(namespace).
+			UID(fmt.Sprintf("uid-%d", i)).
+			Request(className).
+			Obj()
+		claim.ResourceVersion = fmt.Sprintf("%d", version.Add(1))
+		if allocate {
+			claim.Status.Allocation = &resourceapi.AllocationResult{
+				Devices: resourceapi.DeviceAllocationResult{
+					Results: []resourceapi.DeviceRequestAllocationResult{{
+						Request: "req-1",
+						Driver:  driver,
+						Pool:    nodeName,
+						Device:  fmt.Sprintf("device-%d", rand.IntN(numDevices)),
+					}},
+				},
+			}
+		}
+		return claim
+	}
+
+	verify := func(t *testing.T) {
+		t.Helper()
+		claims, err := (&claimListerForAssumeCache{assumeCache: claimCache, inFlightAllocations: &inFlight}).ListAllAllocated()
+		require.NoError(t, err, "list allocated claims")
+		expected := sets.New[structured.DeviceID]()
+		for _, claim := range claims {
+			for _, result := range claim.Status.Allocation.Devices.Results {
+				expected.Insert(structured.DeviceID{Driver: result.Driver, Pool: result.Pool, Device: result.Device})
+			}
+		}
+		snapshot := tracker.snapshot()
+		require.Equal(t, expected.Len(), snapshot.Len(), "number of devices")
+		for i := 0; i < numDevices; i++ {
+			deviceID := structured.DeviceID{Driver: driver, Pool: nodeName, Device: fmt.Sprintf("device-%d", i)}
+			require.Equal(t, expected.Has(deviceID), snapshot.InUse(deviceID), "device %s in use", deviceID)
+		}
+	}
+
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < numIterations; n++ {
+				f(rand.IntN(numClaims))
+			}
+		}()
+	}
+	for round := 0; round < numRounds; round++ {
+		// Informer.
+		run(func(i int) {
+			if rand.IntN(4) == 0 {
+				assumecache.DeleteTestObject(claimCache, newClaim(i, false))
+				return
 			}
+			assumecache.AddTestObject(claimCache, newClaim(i, rand.IntN(2) == 0))
+		})
+		// Reserve, PreBind and Unreserve.
+		for w := 0; w < 2; w++ {
+			run(func(i int) {
+				claim := newClaim(i, true)
+				switch rand.IntN(4) {
+				case 0:
+					inFlight.store(&inFlightAllocation{claim: claim})
+				case 1:
+					inFlight.delete(claim.UID)
+				case 2:
+					// Fails if the claim does not exist.
+					_ = claimCache.Assume(claim)
+				case 3:
+					claimCache.Restore(namespace + "/" + claim.Name)
+				}
+			})
 		}
+		// Allocators.
+		run(func(i int) {
+			tracker.snapshot().InUse(structured.DeviceID{Driver: driver, Pool: nodeName, Device: fmt.Sprintf("device-%d", i%numDevices)})
+		})
+		wg.Wait()
+		verify(t)
+	}
+}
+
+func TestClaimsUsingDevice(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice, podWithClaimName}, features)
+	assert.Empty(t, testCtx.p.ClaimsUsingDevice(driver, nodeName, "instance-1"), "claims before scheduling")
+
+	state := framework.NewCycleState()
+	_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos[0])
+	require.True(t, status.IsSuccess(), "Filter: %v", status)
+	status = testCtx.p.Reserve(testCtx.ctx, state, podWithClaimName, nodeName)
+	require.True(t, status.IsSuccess(), "Reserve: %v", status)
+
+	expect := []ClaimRef{{
+		Claim:    types.NamespacedName{Namespace: namespace, Name: claimName},
+		Request:  "req-1",
+		InFlight: true,
+	}}
+	if diff := cmp.Diff(expect, testCtx.p.ClaimsUsingDevice(driver, nodeName, "instance-1")); diff != "" {
+		t.Errorf("claims after Reserve (-want, +got):\n%s", diff)
+	}
+
+	status = testCtx.p.PreBind(testCtx.ctx, state, podWithClaimName, nodeName)
+	require.True(t, status.IsSuccess(), "PreBind: %v", status)
+
+	expect[0].InFlight = false
+	expect[0].Pods = []string{podName}
+	if diff := cmp.Diff(expect, testCtx.p.ClaimsUsingDevice(driver, nodeName, "instance-1")); diff != "" {
+		t.Errorf("claims after PreBind (-want, +got):\n%s", diff)
+	}
+	assert.Empty(t, testCtx.p.ClaimsUsingDevice(driver, nodeName, "instance-2"), "claims of other device")
+	assert.Empty(t, testCtx.p.ClaimsUsingDevice(driver, node2Name, "instance-1"), "claims of device in other pool")
+}
+
+func TestAllocatedDeviceClasses(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	expression := fmt.Sprintf(`device.driver == %q`, driver)
+
+	for name, tc := range map[string]struct {
+		disabled      bool
+		expression    string
+		expectOmitted bool
+	}{
+		"disabled": {
+			disabled:   true,
+			expression: expression,
+		},
+		"selectors": {
+			expression: expression,
+		},
+		"too-large": {
+			expression:    expression + strings.Repeat(" ", maxAllocatedDeviceClassesSize),
+			expectOmitted: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			class := deviceClass.DeepCopy()
+			class.Generation = 3
+			class.Spec.Selectors = []resourceapi.DeviceSelector{{CEL: &resourceapi.CELDeviceSelector{Expression: tc.expression}}}
+			testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{class}, nil, []apiruntime.Object{workerNodeSlice, podWithClaimName}, features, withArgs(func(args *config.DynamicResourcesArgs) {
+				args.RecordAllocatedDeviceClasses = !tc.disabled
+			}))
+
+			state := framework.NewCycleState()
+			_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+			require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+			status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos[0])
+			require.True(t, status.IsSuccess(), "Filter: %v", status)
+			status = testCtx.p.Reserve(testCtx.ctx, state, podWithClaimName, nodeName)
+			require.True(t, status.IsSuccess(), "Reserve: %v", status)
+			status = testCtx.p.PreBind(testCtx.ctx, state, podWithClaimName, nodeName)
+			require.True(t, status.IsSuccess(), "PreBind: %v", status)
+
+			claim, err := testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).Get(testCtx.ctx, claimName, metav1.GetOptions{})
+			require.NoError(t, err, "get claim")
+			require.NotNil(t, claim.Status.Allocation, "allocation")
+			value, ok := claim.Annotations[AnnotationAllocatedDeviceClasses]
+			if tc.disabled {
+				assert.False(t, ok, "annotation")
+				return
+			}
+			require.True(t, ok, "annotation")
+			assert.LessOrEqual(t, len(value), maxAllocatedDeviceClassesSize, "size of annotation")
+
+			storedClass, err := testCtx.client.ResourceV1alpha3().DeviceClasses().Get(testCtx.ctx, className, metav1.GetOptions{})
+			require.NoError(t, err, "get class")
+			expect := []AllocatedDeviceClass{{
+				Name:            className,
+				UID:             storedClass.UID,
+				Generation:      3,
+				ResourceVersion: storedClass.ResourceVersion,
+			}}
+			if tc.expectOmitted {
+				expect[0].SelectorsOmitted = true
+			} else {
+				expect[0].Selectors = []string{tc.expression}
+			}
+			var actual []AllocatedDeviceClass
+			require.NoError(t, json.Unmarshal([]byte(value), &actual), "decode annotation")
+			assert.Equal(t, expect, actual)
+		})
+	}
+}
+
+func TestDetectDoubleAllocations(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	// withDevice returns a copy of the allocated claim with a different
+	// name which uses a device of the given driver and pool.
+	withDevice := func(name, driverName, pool string) *resourceapi.ResourceClaim {
+		claim := allocatedClaim.DeepCopy()
+		claim.Name = name
+		claim.Status.Allocation.Devices.Results[0].Driver = driverName
+		claim.Status.Allocation.Devices.Results[0].Pool = pool
+		return claim
+	}
+
+	testcases := map[string]struct {
+		claims []*resourceapi.ResourceClaim
+		expect []DeviceRef
+	}{
+		"none": {
+			claims: []*resourceapi.ResourceClaim{allocatedClaim},
+		},
+		"same-device": {
+			claims: []*resourceapi.ResourceClaim{allocatedClaim, otherAllocatedClaim},
+			expect: []DeviceRef{{
+				Driver: driver,
+				Pool:   nodeName,
+				Device: "instance-1",
+				Claims: []types.NamespacedName{
+					{Namespace: namespace, Name: claimName},
+					{Namespace: namespace, Name: otherAllocatedClaim.Name},
+				},
+			}},
+		},
+		"other-pool": {
+			claims: []*resourceapi.ResourceClaim{allocatedClaim, withDevice("other-pool", driver, "other-pool")},
+		},
+		"other-driver": {
+			claims: []*resourceapi.ResourceClaim{allocatedClaim, withDevice("other-driver", "other.example.com", nodeName)},
+		},
+		"admin-access": {
+			claims: []*resourceapi.ResourceClaim{allocatedClaim, func() *resourceapi.ResourceClaim {
+				claim := otherAllocatedClaim.DeepCopy()
+				claim.Spec.Devices.Requests[0].AdminAccess = true
+				return claim
+			}()},
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			testCtx := setup(t, []*v1.Node{workerNode}, tc.claims, []*resourceapi.DeviceClass{deviceClass}, nil, nil, features)
+			refs, err := testCtx.p.DetectDoubleAllocations(testCtx.ctx)
+			require.NoError(t, err)
+			if diff := cmp.Diff(tc.expect, refs); diff != "" {
+				t.Errorf("double allocations (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFilterReasons(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	// The only device on the premium node is in use, the other
+	// node is not suitable for the class.
+	nodes := []*v1.Node{premiumWorkerNode, workerNode2}
+	claims := []*resourceapi.ResourceClaim{structuredClaim(pendingClaim), structuredClaim(otherAllocatedClaim)}
+	testCtx := setup(t, nodes, claims, []*resourceapi.DeviceClass{premiumDeviceClass}, nil, []apiruntime.Object{workerNodeSlice, workerNode2Slice}, features)
+
+	_, status := testCtx.p.PreFilter(testCtx.ctx, testCtx.state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	reasons := GetFilterReasons(testCtx.state)
+	require.NotNil(t, reasons, "filter reasons")
+
+	expected := map[string]struct {
+		code   framework.Code
+		reason FilterReason
+	}{
+		nodeName:  {code: framework.Unschedulable, reason: FilterReasonResourcesExhausted},
+		node2Name: {code: framework.UnschedulableAndUnresolvable, reason: FilterReasonUnresolvable},
+	}
+	for _, nodeInfo := range testCtx.nodeInfos {
+		name := nodeInfo.Node().Name
+		status := testCtx.p.Filter(testCtx.ctx, testCtx.state, podWithClaimName, nodeInfo)
+		assert.Equal(t, expected[name].code, status.Code(), "Filter %s: %v", name, status)
+		reason, ok := reasons.Get(name)
+		assert.True(t, ok, "reason recorded for %s", name)
+		assert.Equal(t, expected[name].reason, reason, "reason for %s", name)
+	}
+
+	// Simulated Filter calls on a copy of the state don't change the original.
+	cloned := testCtx.state.Clone()
+	clonedReasons := GetFilterReasons(cloned)
+	require.NotNil(t, clonedReasons, "cloned filter reasons")
+	clonedReasons.record("other-node", framework.NewStatus(framework.Unschedulable))
+	_, ok := reasons.Get("other-node")
+	assert.False(t, ok, "reason for other-node in original state")
+}
+
+func TestNoClaims(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice}, features)
+	pod := st.MakePod().Name("foo").Namespace(namespace).UID("foo-uid").Obj()
+	initialObjects := testCtx.listAll(t)
+
+	// PreFilter was not called, so there is nothing in the cycle state.
+	// The extension points must not depend on it.
+	state := framework.NewCycleState()
+	status := testCtx.p.Filter(testCtx.ctx, state, pod, testCtx.nodeInfos[0])
+	assert.Nil(t, status, "Filter")
+	status = testCtx.p.PreScore(testCtx.ctx, state, pod, testCtx.nodeInfos)
+	assert.Nil(t, status, "PreScore")
+	_, status = testCtx.p.PostFilter(testCtx.ctx, state, pod, nil)
+	assert.Equal(t, framework.Unschedulable, status.Code(), "PostFilter: %v", status)
+	status = testCtx.p.Reserve(testCtx.ctx, state, pod, nodeName)
+	assert.Nil(t, status, "Reserve")
+	testCtx.p.Unreserve(testCtx.ctx, state, pod, nodeName)
+	status = testCtx.p.PreBind(testCtx.ctx, state, pod, nodeName)
+	assert.Nil(t, status, "PreBind")
+	testCtx.p.PostBind(testCtx.ctx, state, pod, nodeName)
+
+	testCtx.verify(t, result{}, initialObjects, nil, nil)
+}
+
+// victimClaims creates a pod and an allocated claim which is reserved for it
+// for each device.
+func victimClaims(devices ...string) ([]*v1.Pod, []*resourceapi.ResourceClaim) {
+	var pods []*v1.Pod
+	var claims []*resourceapi.ResourceClaim
+	for _, device := range devices {
+		podName := "victim-" + device
+		claimName := podName + "-" + resourceName
+		pod := st.MakePod().Name(podName).Namespace(namespace).
+			UID(podName + "-uid").
+			PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: &claimName}).
+			Obj()
+		allocation := allocationResult.DeepCopy()
+		allocation.Devices.Results[0].Device = device
+		claim := st.MakeResourceClaim("").
+			Name(claimName).
+			Namespace(namespace).
+			Request(className).
+			Allocation(allocation).
+			ReservedForPod(pod.Name, pod.UID).
+			Structured().
+			Obj()
+		pods = append(pods, pod)
+		claims = append(claims, claim)
 	}
-	sortObjects(wantObjects)
-	// Sometimes assert strips the diff too much, let's do it ourselves...
-	if diff := cmp.Diff(wantObjects, objects, cmpopts.IgnoreFields(metav1.ObjectMeta{}, "UID", "ResourceVersion")); diff != "" {
-		t.Errorf("Stored objects are different (- expected, + actual):\n%s", diff)
+	return pods, claims
+}
+
+func TestFilterWithRemovedPods(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
 	}
+	victims, victimClaims := victimClaims("instance-1")
+	victim, err := framework.NewPodInfo(victims[0])
+	require.NoError(t, err)
+	claims := append([]*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, victimClaims...)
+	testCtx := setup(t, []*v1.Node{workerNode}, claims, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice}, features)
+	nodeInfo := testCtx.nodeInfos[0]
 
-	var expectAssumedClaims []metav1.Object
-	if expected.assumedClaim != nil {
-		expectAssumedClaims = append(expectAssumedClaims, expected.assumedClaim)
+	state := framework.NewCycleState()
+	_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, nodeInfo)
+	assert.Equal(t, framework.Unschedulable, status.Code(), "Filter: %v", status)
+
+	// Preemption evaluates the node without the victim in a copy of the state.
+	simulated := state.Clone()
+	status = testCtx.p.RemovePod(testCtx.ctx, simulated, podWithClaimName, victim, nodeInfo)
+	require.True(t, status.IsSuccess(), "RemovePod: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, simulated, podWithClaimName, nodeInfo)
+	assert.True(t, status.IsSuccess(), "Filter without victim: %v", status)
+
+	// Neither the result of the simulation nor the cached result
+	// for it affect the original state.
+	status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, nodeInfo)
+	assert.Equal(t, framework.Unschedulable, status.Code(), "Filter again: %v", status)
+	stateData, err := getStateData(state)
+	require.NoError(t, err)
+	assert.Nil(t, stateData.nodeAllocations[nodeName], "allocations stored for Reserve")
+
+	status = testCtx.p.AddPod(testCtx.ctx, simulated, podWithClaimName, victim, nodeInfo)
+	require.True(t, status.IsSuccess(), "AddPod: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, simulated, podWithClaimName, nodeInfo)
+	assert.Equal(t, framework.Unschedulable, status.Code(), "Filter with victim added again: %v", status)
+
+	// The allocator ran once with and once without the victim.
+	assert.Len(t, stateData.filterCache, 2, "cached results")
+}
+
+func BenchmarkFilterWithRemovedPods(b *testing.B) {
+	// All devices on the node are in use. Each iteration does what
+	// preemption does on a node: remove all victims, then add
+	// them back one after the other.
+	const numDevices = 8
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
 	}
-	actualAssumedClaims := tc.listAssumedClaims()
-	if diff := cmp.Diff(expectAssumedClaims, actualAssumedClaims, cmpopts.IgnoreFields(metav1.ObjectMeta{}, "UID", "ResourceVersion")); diff != "" {
-		t.Errorf("Assumed claims are different (- expected, + actual):\n%s", diff)
+	slice := st.MakeResourceSlice(nodeName, driver)
+	var devices []string
+	for i := 0; i < numDevices; i++ {
+		device := fmt.Sprintf("instance-%d", i)
+		devices = append(devices, device)
+		slice = slice.Device(device, nil)
 	}
+	victims, victimClaims := victimClaims(devices...)
+	var victimInfos []*framework.PodInfo
+	for _, victim := range victims {
+		podInfo, err := framework.NewPodInfo(victim)
+		require.NoError(b, err)
+		victimInfos = append(victimInfos, podInfo)
+	}
+	claims := append([]*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, victimClaims...)
+	testCtx := setup(b, []*v1.Node{workerNode}, claims, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{slice.Obj()}, features)
+	nodeInfo := testCtx.nodeInfos[0]
 
-	var expectInFlightClaims []metav1.Object
-	if expected.inFlightClaim != nil {
-		expectInFlightClaims = append(expectInFlightClaims, expected.inFlightClaim)
+	preFilter := func() *framework.CycleState {
+		state := framework.NewCycleState()
+		if _, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName); !status.IsSuccess() {
+			b.Fatalf("PreFilter: %v", status)
+		}
+		return state
 	}
-	actualInFlightClaims := tc.listInFlightClaims()
-	if diff := cmp.Diff(expectInFlightClaims, actualInFlightClaims, cmpopts.IgnoreFields(metav1.ObjectMeta{}, "UID", "ResourceVersion")); diff != "" {
-		t.Errorf("In-flight claims are different (- expected, + actual):\n%s", diff)
+	preempt := func(state *framework.CycleState) {
+		simulated := state.Clone()
+		for _, victim := range victimInfos {
+			testCtx.p.RemovePod(testCtx.ctx, simulated, podWithClaimName, victim, nodeInfo)
+		}
+		testCtx.p.Filter(testCtx.ctx, simulated, podWithClaimName, nodeInfo)
+		for _, victim := range victimInfos {
+			testCtx.p.AddPod(testCtx.ctx, simulated, podWithClaimName, victim, nodeInfo)
+			testCtx.p.Filter(testCtx.ctx, simulated, podWithClaimName, nodeInfo)
+		}
 	}
+
+	b.Run("same-cycle", func(b *testing.B) {
+		state := preFilter()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			preempt(state)
+		}
+	})
+	b.Run("new-cycle", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			preempt(preFilter())
+		}
+	})
 }
 
-func (tc *testContext) listAll(t *testing.T) (objects []metav1.Object) {
-	t.Helper()
-	claims, err := tc.client.ResourceV1alpha3().ResourceClaims("").List(tc.ctx, metav1.ListOptions{})
-	require.NoError(t, err, "list claims")
-	for _, claim := range claims.Items {
-		claim := claim
-		objects = append(objects, &claim)
+func TestExtensionPointDuration(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
 	}
-	schedulings, err := tc.client.ResourceV1alpha3().PodSchedulingContexts("").List(tc.ctx, metav1.ListOptions{})
-	require.NoError(t, err, "list pod scheduling")
-	for _, scheduling := range schedulings.Items {
-		scheduling := scheduling
-		objects = append(objects, &scheduling)
+	metrics.RegisterMetrics()
+	count := func(extensionPoint, driver string) uint64 {
+		t.Helper()
+		vec, err := testutil.GetHistogramVecFromGatherer(legacyregistry.DefaultGatherer, "scheduler_dra_extension_point_duration_seconds", map[string]string{"extension_point": extensionPoint, "driver": driver})
+		if err != nil {
+			// Nothing observed yet.
+			return 0
+		}
+		return vec.GetAggregatedSampleCount()
+	}
+	// The pending claim cannot be attributed to a driver before Reserve
+	// has allocated it.
+	expected := []struct{ extensionPoint, driver string }{
+		{schedulermetrics.PreFilter, ""},
+		{schedulermetrics.Filter, ""},
+		{schedulermetrics.Reserve, driver},
+		{schedulermetrics.PreBind, driver},
+	}
+	before := make([]uint64, len(expected))
+	for i, e := range expected {
+		before[i] = count(e.extensionPoint, e.driver)
 	}
 
-	sortObjects(objects)
-	return
-}
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice, podWithClaimName}, features)
+	state := framework.NewCycleState()
+	_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos[0])
+	require.True(t, status.IsSuccess(), "Filter: %v", status)
+	status = testCtx.p.Reserve(testCtx.ctx, state, podWithClaimName, nodeName)
+	require.True(t, status.IsSuccess(), "Reserve: %v", status)
+	status = testCtx.p.PreBind(testCtx.ctx, state, podWithClaimName, nodeName)
+	require.True(t, status.IsSuccess(), "PreBind: %v", status)
 
-func (tc *testContext) listAssumedClaims() []metav1.Object {
-	if tc.p.claimAssumeCache == nil {
-		return nil
+	for i, e := range expected {
+		assert.Greater(t, count(e.extensionPoint, e.driver), before[i], "samples for %s with driver %q", e.extensionPoint, e.driver)
 	}
-	var assumedClaims []metav1.Object
-	for _, obj := range tc.p.claimAssumeCache.List(nil) {
-		claim := obj.(*resourceapi.ResourceClaim)
-		obj, _ := tc.p.claimAssumeCache.Get(claim.Namespace + "/" + claim.Name)
-		apiObj, _ := tc.p.claimAssumeCache.GetAPIObj(claim.Namespace + "/" + claim.Name)
-		if obj != apiObj {
-			assumedClaims = append(assumedClaims, claim)
-		}
+}
+
+func TestClassDevicesCache(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
 	}
-	sortObjects(assumedClaims)
-	return assumedClaims
+	claim := structuredClaim(withDeviceCount(pendingClaim, 2))
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{claim}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice}, features)
+
+	_, status := testCtx.p.PreFilter(testCtx.ctx, framework.NewCycleState(), podWithClaimName)
+	require.Equal(t, framework.NewStatus(framework.UnschedulableAndUnresolvable, `requested 2 devices of class my-resource-class but only 1 exist in the cluster`), status, "PreFilter with one device")
+
+	// A second device gets published. The cached count must be
+	// updated once the informer has seen the new slice.
+	_, err := testCtx.client.ResourceV1alpha3().ResourceSlices().Create(testCtx.ctx, workerNode2Slice, metav1.CreateOptions{})
+	require.NoError(t, err, "create resource slice")
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		_, status := testCtx.p.PreFilter(testCtx.ctx, framework.NewCycleState(), podWithClaimName)
+		assert.True(t, status.IsSuccess(), "PreFilter with two devices: %v", status)
+	}, time.Minute, time.Second, "PreFilter must succeed")
 }
 
-func (tc *testContext) listInFlightClaims() []metav1.Object {
-	var inFlightClaims []metav1.Object
-	tc.p.inFlightAllocations.Range(func(key, value any) bool {
-		inFlightClaims = append(inFlightClaims, value.(*resourceapi.ResourceClaim))
+func TestInFlightAllocations(t *testing.T) {
+	var allocations inFlightAllocations
+	claim := structuredClaim(allocatedClaim)
+	since := time.Now()
+
+	_, found := allocations.load(claim.UID)
+	assert.False(t, found, "load before store")
+	_, found = allocations.loadClaim(claim.UID)
+	assert.False(t, found, "loadClaim before store")
+	assert.False(t, allocations.delete(claim.UID), "delete before store")
+
+	allocations.store(&inFlightAllocation{claim: claim, nodeName: nodeName, podUID: types.UID(podUID), since: since})
+	allocation, found := allocations.load(claim.UID)
+	if assert.True(t, found, "load after store") {
+		assert.Equal(t, claim, allocation.claim, "claim")
+		assert.Equal(t, nodeName, allocation.nodeName, "node name")
+		assert.Equal(t, types.UID(podUID), allocation.podUID, "pod UID")
+		assert.Equal(t, since, allocation.since, "since")
+	}
+	inFlightClaim, found := allocations.loadClaim(claim.UID)
+	assert.True(t, found, "loadClaim after store")
+	assert.Equal(t, claim, inFlightClaim, "claim from loadClaim")
+
+	var nodeNames []string
+	allocations.forEach(func(allocation *inFlightAllocation) bool {
+		nodeNames = append(nodeNames, allocation.nodeName)
 		return true
 	})
-	sortObjects(inFlightClaims)
-	return inFlightClaims
+	assert.Equal(t, []string{nodeName}, nodeNames, "forEach")
+
+	assert.True(t, allocations.delete(claim.UID), "delete after store")
+	_, found = allocations.load(claim.UID)
+	assert.False(t, found, "load after delete")
+	assert.False(t, allocations.delete(claim.UID), "second delete")
 }
 
-// updateAPIServer modifies objects and stores any changed object in the API server.
-func (tc *testContext) updateAPIServer(t *testing.T, objects []metav1.Object, updates change) []metav1.Object {
-	modified := update(t, objects, updates)
-	for i := range modified {
-		obj := modified[i]
-		if diff := cmp.Diff(objects[i], obj); diff != "" {
-			t.Logf("Updating %T %q, diff (-old, +new):\n%s", obj, obj.GetName(), diff)
-			switch obj := obj.(type) {
-			case *resourceapi.ResourceClaim:
-				obj, err := tc.client.ResourceV1alpha3().ResourceClaims(obj.Namespace).Update(tc.ctx, obj, metav1.UpdateOptions{})
-				if err != nil {
-					t.Fatalf("unexpected error during prepare update: %v", err)
-				}
-				modified[i] = obj
-			case *resourceapi.PodSchedulingContext:
-				obj, err := tc.client.ResourceV1alpha3().PodSchedulingContexts(obj.Namespace).Update(tc.ctx, obj, metav1.UpdateOptions{})
-				if err != nil {
-					t.Fatalf("unexpected error during prepare update: %v", err)
-				}
-				modified[i] = obj
-			default:
-				t.Fatalf("unsupported object type %T", obj)
+func TestInsufficientNodeResources(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	counter := v1.ResourceName("vendor.com/counter")
+	node := &st.MakeNode().Name(nodeName).Label("kubernetes.io/hostname", nodeName).Capacity(map[v1.ResourceName]string{counter: "1"}).Node
+	pod := st.MakePod().Name(podName).Namespace(namespace).
+		UID(podUID).
+		PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: &claimName}).
+		Req(map[v1.ResourceName]string{counter: "1"}).
+		Obj()
+	cannotAllocate := ErrReasonCannotAllocate + ": claim " + claimName + ": request req-1 unsatisfiable (not enough free devices)"
+
+	testcases := map[string]struct {
+		counterInUse bool
+		expectStatus *framework.Status
+	}{
+		"counter-available": {
+			expectStatus: framework.NewStatus(framework.Unschedulable, cannotAllocate),
+		},
+		"counter-exhausted": {
+			counterInUse: true,
+			expectStatus: framework.NewStatus(framework.Unschedulable, cannotAllocate+"; also insufficient "+string(counter)),
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			testCtx := setup(t, []*v1.Node{node}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim), structuredClaim(otherAllocatedClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice}, features)
+			nodeInfo := testCtx.nodeInfos[0]
+			if tc.counterInUse {
+				nodeInfo.AddPod(st.MakePod().Name(otherPodName).Namespace(namespace).Req(map[v1.ResourceName]string{counter: "1"}).Obj())
 			}
-		}
+
+			state := framework.NewCycleState()
+			_, status := testCtx.p.PreFilter(testCtx.ctx, state, pod)
+			require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+			status = testCtx.p.Filter(testCtx.ctx, state, pod, nodeInfo)
+			assert.Equal(t, tc.expectStatus, status)
+		})
 	}
-	return modified
 }
 
-func sortObjects(objects []metav1.Object) {
-	sort.Slice(objects, func(i, j int) bool {
-		if objects[i].GetNamespace() < objects[j].GetNamespace() {
-			return true
+func TestAlternativeClasses(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	kind := resourceapi.QualifiedName("kind")
+	slice := st.MakeResourceSlice(nodeName, driver).
+		Device("instance-1", map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{kind: {StringValue: ptr.To("standard")}}).
+		Device("instance-2", map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{kind: {StringValue: ptr.To("premium")}}).
+		Obj()
+	classForKind := func(name, kind string) *resourceapi.DeviceClass {
+		return &resourceapi.DeviceClass{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: resourceapi.DeviceClassSpec{
+				Selectors: []resourceapi.DeviceSelector{{
+					CEL: &resourceapi.CELDeviceSelector{Expression: fmt.Sprintf(`device.attributes[%q].kind == %q`, driver, kind)},
+				}},
+			},
 		}
-		return objects[i].GetName() < objects[j].GetName()
-	})
-}
+	}
 
-// update walks through all existing objects, finds the corresponding update
-// function based on name and kind, and replaces those objects that have an
-// update function. The rest is left unchanged.
-func update(t *testing.T, objects []metav1.Object, updates change) []metav1.Object {
-	var updated []metav1.Object
+	// The only standard device is allocated, but a premium one is free.
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim), structuredClaim(otherAllocatedClaim)}, []*resourceapi.DeviceClass{classForKind(className, "standard"), classForKind("premium-class", "premium")}, nil, []apiruntime.Object{slice}, features)
+	state := framework.NewCycleState()
+	_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos[0])
+	assert.Equal(t, framework.NewStatus(framework.Unschedulable, ErrReasonCannotAllocate+": claim "+claimName+": request req-1 unsatisfiable (not enough free devices); consider class premium-class which has available devices"), status)
+}
 
-	for _, obj := range objects {
-		switch in := obj.(type) {
-		case *resourceapi.ResourceClaim:
-			if updates.claim != nil {
-				obj = updates.claim(in)
-			}
-		case *resourceapi.PodSchedulingContext:
-			if updates.scheduling != nil {
-				obj = updates.scheduling(in)
-			}
-		}
-		updated = append(updated, obj)
+func TestCanReserve(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
 	}
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim), structuredClaim(otherClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice}, features)
 
-	return updated
+	_, status := testCtx.p.PreFilter(testCtx.ctx, testCtx.state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, testCtx.state, podWithClaimName, testCtx.nodeInfos[0])
+	require.True(t, status.IsSuccess(), "Filter: %v", status)
+	status = testCtx.p.CanReserve(testCtx.ctx, testCtx.state, podWithClaimName, nodeName)
+	assert.True(t, status.IsSuccess(), "CanReserve: %v", status)
+	assert.Empty(t, testCtx.listInFlightClaims(), "in-flight claims")
+	obj, err := testCtx.claimAssumeCache.Get(pendingClaim.Namespace + "/" + pendingClaim.Name)
+	require.NoError(t, err, "get claim from assume cache")
+	assert.Nil(t, obj.(*resourceapi.ResourceClaim).Status.Allocation, "allocation in assume cache")
+
+	// Some other pod got the only device in the meantime.
+	obj, err = testCtx.claimAssumeCache.Get(otherClaim.Namespace + "/" + otherClaim.Name)
+	require.NoError(t, err, "get other claim from assume cache")
+	other := obj.(*resourceapi.ResourceClaim).DeepCopy()
+	other.Status.Allocation = allocationResult
+	testCtx.p.inFlightAllocations.store(&inFlightAllocation{claim: other, nodeName: nodeName, podUID: otherPodWithClaimName.UID})
+	status = testCtx.p.CanReserve(testCtx.ctx, testCtx.state, podWithClaimName, nodeName)
+	assert.Equal(t, framework.Unschedulable, status.Code(), "CanReserve with device in use: %v", status)
 }
 
-func setup(t *testing.T, nodes []*v1.Node, claims []*resourceapi.ResourceClaim, classes []*resourceapi.DeviceClass, schedulings []*resourceapi.PodSchedulingContext, objs []apiruntime.Object, features feature.Features) (result *testContext) {
-	t.Helper()
+// countingSchedulingContextLister counts how often a PodSchedulingContext
+// gets retrieved.
+type countingSchedulingContextLister struct {
+	resourcelisters.PodSchedulingContextLister
+	gets atomic.Int64
+}
 
-	tc := &testContext{}
-	tCtx := ktesting.Init(t)
-	tc.ctx = tCtx
+func (l *countingSchedulingContextLister) PodSchedulingContexts(namespace string) resourcelisters.PodSchedulingContextNamespaceLister {
+	return countingSchedulingContextNamespaceLister{PodSchedulingContextNamespaceLister: l.PodSchedulingContextLister.PodSchedulingContexts(namespace), gets: &l.gets}
+}
 
-	tc.client = fake.NewSimpleClientset(objs...)
-	reactor := createReactor(tc.client.Tracker())
-	tc.client.PrependReactor("*", "*", reactor)
+type countingSchedulingContextNamespaceLister struct {
+	resourcelisters.PodSchedulingContextNamespaceLister
+	gets *atomic.Int64
+}
 
-	tc.informerFactory = informers.NewSharedInformerFactory(tc.client, 0)
-	tc.claimAssumeCache = assumecache.NewAssumeCache(tCtx.Logger(), tc.informerFactory.Resource().V1alpha3().ResourceClaims().Informer(), "resource claim", "", nil)
-	opts := []runtime.Option{
-		runtime.WithClientSet(tc.client),
-		runtime.WithInformerFactory(tc.informerFactory),
-		runtime.WithResourceClaimCache(tc.claimAssumeCache),
+func (l countingSchedulingContextNamespaceLister) Get(name string) (*resourceapi.PodSchedulingContext, error) {
+	l.gets.Add(1)
+	return l.PodSchedulingContextNamespaceLister.Get(name)
+}
+
+func TestSchedulingContextManyClaims(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+		EnableDRAControlPlaneController: true,
 	}
-	fh, err := runtime.NewFramework(tCtx, nil, nil, opts...)
-	if err != nil {
-		t.Fatal(err)
+	const numClaims = 10
+	podBuilder := st.MakePod().Name(podName).Namespace(namespace).UID(podUID)
+	var statuses []resourceapi.ResourceClaimSchedulingStatus
+	var claims []*resourceapi.ResourceClaim
+	for i := 0; i < numClaims; i++ {
+		name := fmt.Sprintf("claim-%d", i)
+		podBuilder = podBuilder.PodResourceClaims(v1.PodResourceClaim{Name: name, ResourceClaimName: ptr.To(name)})
+		statuses = append(statuses, resourceapi.ResourceClaimSchedulingStatus{Name: name, UnsuitableNodes: []string{node2Name}})
+		claim := pendingClaim.DeepCopy()
+		claim.Name = name
+		claims = append(claims, claim)
 	}
+	pod := podBuilder.Obj()
+	scheduling := st.FromPodSchedulingContexts(schedulingPotential).ResourceClaims(statuses...).Obj()
+	var lister *countingSchedulingContextLister
+	testCtx := setup(t, []*v1.Node{workerNode, workerNode2}, claims, []*resourceapi.DeviceClass{deviceClass}, []*resourceapi.PodSchedulingContext{scheduling}, nil, features, func(deps *Dependencies, opts *[]Option) {
+		lister = &countingSchedulingContextLister{PodSchedulingContextLister: deps.PodSchedulingContextLister}
+		deps.PodSchedulingContextLister = lister
+	})
 
-	pl, err := New(tCtx, nil, fh, features)
-	if err != nil {
-		t.Fatal(err)
-	}
-	tc.p = pl.(*dynamicResources)
+	_, status := testCtx.p.PreFilter(testCtx.ctx, testCtx.state, pod)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, testCtx.state, pod, testCtx.nodeInfos[0])
+	require.True(t, status.IsSuccess(), "Filter %s: %v", nodeName, status)
+	status = testCtx.p.Filter(testCtx.ctx, testCtx.state, pod, testCtx.nodeInfos[1])
+	assert.Equal(t, framework.UnschedulableAndUnresolvable, status.Code(), "Filter %s: %v", node2Name, status)
+	status = testCtx.p.Reserve(testCtx.ctx, testCtx.state, pod, nodeName)
+	require.True(t, status.IsSuccess(), "Reserve: %v", status)
 
-	// The tests use the API to create the objects because then reactors
-	// get triggered.
-	for _, claim := range claims {
-		_, err := tc.client.ResourceV1alpha3().ResourceClaims(claim.Namespace).Create(tc.ctx, claim, metav1.CreateOptions{})
-		require.NoError(t, err, "create resource claim")
+	testCtx.client.ClearActions()
+	status = testCtx.p.PreBind(testCtx.ctx, testCtx.state, pod, nodeName)
+	assert.Equal(t, framework.NewStatus(framework.Pending, "waiting for resource driver"), status, "PreBind")
+
+	assert.Equal(t, int64(1), lister.gets.Load(), "PodSchedulingContext reads")
+	var writes int
+	for _, action := range testCtx.client.Actions() {
+		if action.GetResource().Resource == "podschedulingcontexts" && action.GetVerb() != "get" && action.GetVerb() != "list" && action.GetVerb() != "watch" {
+			writes++
+		}
 	}
-	for _, class := range classes {
-		_, err := tc.client.ResourceV1alpha3().DeviceClasses().Create(tc.ctx, class, metav1.CreateOptions{})
-		require.NoError(t, err, "create resource class")
+	assert.LessOrEqual(t, writes, 1, "PodSchedulingContext writes")
+	schedulingCtx, err := testCtx.client.ResourceV1alpha3().PodSchedulingContexts(namespace).Get(testCtx.ctx, podName, metav1.GetOptions{})
+	require.NoError(t, err, "get PodSchedulingContext")
+	assert.Equal(t, nodeName, schedulingCtx.Spec.SelectedNode, "selected node")
+}
+
+// countingSliceLister counts how often the allocator lists slices,
+// which it does once per allocation attempt.
+type countingSliceLister struct {
+	resourcelisters.ResourceSliceLister
+	calls int
+}
+
+func (l *countingSliceLister) List(selector labels.Selector) ([]*resourceapi.ResourceSlice, error) {
+	l.calls++
+	return l.ResourceSliceLister.List(selector)
+}
+
+func TestReserveUsesFilterResult(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
 	}
-	for _, scheduling := range schedulings {
-		_, err := tc.client.ResourceV1alpha3().PodSchedulingContexts(scheduling.Namespace).Create(tc.ctx, scheduling, metav1.CreateOptions{})
-		require.NoError(t, err, "create pod scheduling")
+	var lister *countingSliceLister
+	testCtx := setup(t, []*v1.Node{workerNode, workerNode2}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice, workerNode2Slice}, features, func(deps *Dependencies, opts *[]Option) {
+		lister = &countingSliceLister{ResourceSliceLister: deps.SliceLister}
+		deps.SliceLister = lister
+	})
+
+	state := framework.NewCycleState()
+	_, status := testCtx.p.PreFilter(testCtx.ctx, state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	for _, nodeInfo := range testCtx.nodeInfos {
+		status = testCtx.p.Filter(testCtx.ctx, state, podWithClaimName, nodeInfo)
+		require.True(t, status.IsSuccess(), "Filter %s: %v", nodeInfo.Node().Name, status)
 	}
+	calls := lister.calls
 
-	tc.informerFactory.Start(tc.ctx.Done())
-	t.Cleanup(func() {
-		// Need to cancel before waiting for the shutdown.
-		tCtx.Cancel("test is done")
-		// Now we can wait for all goroutines to stop.
-		tc.informerFactory.Shutdown()
-	})
+	// Some other plugin rejected the second node.
+	status = testCtx.p.PreScore(testCtx.ctx, state, podWithClaimName, testCtx.nodeInfos[:1])
+	require.True(t, status.IsSuccess(), "PreScore: %v", status)
+	status = testCtx.p.Reserve(testCtx.ctx, state, podWithClaimName, workerNode2.Name)
+	assert.Equal(t, framework.Error, status.Code(), "Reserve with stale result: %v", status)
+	status = testCtx.p.Reserve(testCtx.ctx, state, podWithClaimName, workerNode.Name)
+	assert.True(t, status.IsSuccess(), "Reserve: %v", status)
+	assert.Equal(t, calls, lister.calls, "slices listed again after Filter")
+}
 
-	tc.informerFactory.WaitForCacheSync(tc.ctx.Done())
+// preferDeviceScorer is an example DeviceScorer which prefers one device.
+type preferDeviceScorer struct {
+	device string
+}
 
-	for _, node := range nodes {
-		nodeInfo := framework.NewNodeInfo()
-		nodeInfo.SetNode(node)
-		tc.nodeInfos = append(tc.nodeInfos, nodeInfo)
+func (s preferDeviceScorer) ScoreDevice(ctx context.Context, claim *resourceapi.ResourceClaim, request *resourceapi.DeviceRequest, node *v1.Node, deviceID structured.DeviceID, device *resourceapi.BasicDevice) int64 {
+	if deviceID.Device == s.device {
+		return 1
 	}
-	tc.state = framework.NewCycleState()
+	return 0
+}
 
-	return tc
+// countingDeviceScorer counts how often the allocator considered some
+// device which matched the CEL selectors.
+type countingDeviceScorer struct {
+	calls *atomic.Int64
 }
 
-// createReactor implements the logic required for the UID and ResourceVersion
-// fields to work when using the fake client. Add it with client.PrependReactor
-// to your fake client. ResourceVersion handling is required for conflict
-// detection during updates, which is covered by some scenarios.
-func createReactor(tracker cgotesting.ObjectTracker) func(action cgotesting.Action) (handled bool, ret apiruntime.Object, err error) {
-	var uidCounter int
-	var resourceVersionCounter int
-	var mutex sync.Mutex
+func (s countingDeviceScorer) ScoreDevice(ctx context.Context, claim *resourceapi.ResourceClaim, request *resourceapi.DeviceRequest, node *v1.Node, deviceID structured.DeviceID, device *resourceapi.BasicDevice) int64 {
+	s.calls.Add(1)
+	return 0
+}
 
-	return func(action cgotesting.Action) (handled bool, ret apiruntime.Object, err error) {
-		createAction, ok := action.(cgotesting.CreateAction)
-		if !ok {
-			return false, nil, nil
-		}
-		obj, ok := createAction.GetObject().(metav1.Object)
-		if !ok {
-			return false, nil, nil
-		}
+func TestPodFilterCache(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	class := deviceClass.DeepCopy()
+	class.Spec.Selectors = []resourceapi.DeviceSelector{{CEL: &resourceapi.CELDeviceSelector{Expression: `device.driver == "` + driver + `"`}}}
+	var calls atomic.Int64
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{class}, nil, []apiruntime.Object{workerNodeCardSlice}, features, withOptions(WithDeviceScorers(countingDeviceScorer{calls: &calls})))
 
-		mutex.Lock()
-		defer mutex.Unlock()
-		switch action.GetVerb() {
-		case "create":
-			if obj.GetUID() != "" {
-				return true, nil, errors.New("UID must not be set on create")
-			}
-			if obj.GetResourceVersion() != "" {
-				return true, nil, errors.New("ResourceVersion must not be set on create")
-			}
-			obj.SetUID(types.UID(fmt.Sprintf("UID-%d", uidCounter)))
-			uidCounter++
-			obj.SetResourceVersion(fmt.Sprintf("%d", resourceVersionCounter))
-			resourceVersionCounter++
-		case "update":
-			uid := obj.GetUID()
-			resourceVersion := obj.GetResourceVersion()
-			if uid == "" {
-				return true, nil, errors.New("UID must be set on update")
-			}
-			if resourceVersion == "" {
-				return true, nil, errors.New("ResourceVersion must be set on update")
-			}
+	filterPod := func(pod *v1.Pod) {
+		t.Helper()
+		state := framework.NewCycleState()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, state, pod)
+		require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+		status = testCtx.p.Filter(testCtx.ctx, state, pod, testCtx.nodeInfos[0])
+		require.True(t, status.IsSuccess(), "Filter: %v", status)
+	}
+	filter := func() {
+		t.Helper()
+		filterPod(podWithClaimName)
+	}
+	filter()
+	evaluated := calls.Load()
+	require.NotZero(t, evaluated, "devices evaluated by first Filter")
+	filter()
+	assert.Equal(t, evaluated, calls.Load(), "devices evaluated again without slice changes")
+
+	// The same pod with device anti-affinity is a different input.
+	antiAffinityPod := podWithClaimName.DeepCopy()
+	antiAffinityPod.Annotations = map[string]string{
+		AnnotationDeviceAntiAffinityPods:      "other-pod",
+		AnnotationDeviceAntiAffinityAttribute: driver + "/card",
+	}
+	filterPod(antiAffinityPod)
+	assert.Greater(t, calls.Load(), evaluated, "devices evaluated after adding anti-affinity")
+	evaluated = calls.Load()
+	filterPod(antiAffinityPod)
+	assert.Equal(t, evaluated, calls.Load(), "devices evaluated again with same anti-affinity")
+
+	testCtx.p.podFilterCache.mutex.Lock()
+	generation := testCtx.p.podFilterCache.generation
+	testCtx.p.podFilterCache.mutex.Unlock()
+	// Any slice change invalidates the cache, even for some other node.
+	_, err := testCtx.client.ResourceV1alpha3().ResourceSlices().Create(testCtx.ctx, st.MakeResourceSlice(node2Name, driver).Device("instance-1", nil).Obj(), metav1.CreateOptions{})
+	require.NoError(t, err, "create slice")
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		testCtx.p.podFilterCache.mutex.Lock()
+		defer testCtx.p.podFilterCache.mutex.Unlock()
+		assert.NotEqual(t, generation, testCtx.p.podFilterCache.generation, "generation")
+	}, time.Minute, 10*time.Millisecond)
+	filter()
+	assert.Greater(t, calls.Load(), evaluated, "devices evaluated after slice change")
+}
+
+func TestAllocationObservers(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	type call struct {
+		claim    *resourceapi.ResourceClaim
+		pod      *v1.Pod
+		nodeName string
+	}
+	// Unbuffered, so the observer blocks until the test reads.
+	calls := make(chan call)
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{deviceClass}, nil, []apiruntime.Object{workerNodeSlice}, features, withOptions(WithAllocationObservers(AllocationObserverFunc(func(claim *resourceapi.ResourceClaim, pod *v1.Pod, nodeName string) {
+		calls <- call{claim: claim, pod: pod, nodeName: nodeName}
+	}))))
+
+	_, status := testCtx.p.PreFilter(testCtx.ctx, testCtx.state, podWithClaimName)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = testCtx.p.Filter(testCtx.ctx, testCtx.state, podWithClaimName, testCtx.nodeInfos[0])
+	require.True(t, status.IsSuccess(), "Filter: %v", status)
+	status = testCtx.p.Reserve(testCtx.ctx, testCtx.state, podWithClaimName, nodeName)
+	require.True(t, status.IsSuccess(), "Reserve: %v", status)
+	status = testCtx.p.PreBind(testCtx.ctx, testCtx.state, podWithClaimName, nodeName)
+	require.True(t, status.IsSuccess(), "PreBind: %v", status)
+	select {
+	case c := <-calls:
+		t.Fatalf("observer called before PostBind for claim %s", klog.KObj(c.claim))
+	default:
+	}
 
-			oldObj, err := tracker.Get(action.GetResource(), obj.GetNamespace(), obj.GetName())
-			if err != nil {
-				return true, nil, err
-			}
-			oldObjMeta, ok := oldObj.(metav1.Object)
-			if !ok {
-				return true, nil, errors.New("internal error: unexpected old object type")
-			}
-			if oldObjMeta.GetResourceVersion() != resourceVersion {
-				return true, nil, errors.New("ResourceVersion must match the object that gets updated")
-			}
+	// PostBind returns although the observer is still blocked.
+	testCtx.p.PostBind(testCtx.ctx, testCtx.state, podWithClaimName, nodeName)
+	select {
+	case c := <-calls:
+		assert.Equal(t, claimName, c.claim.Name, "claim")
+		assert.NotNil(t, c.claim.Status.Allocation, "allocation")
+		assert.True(t, resourceclaim.IsReservedForPod(podWithClaimName, c.claim), "claim reserved for pod")
+		assert.Equal(t, podWithClaimName, c.pod, "pod")
+		assert.Equal(t, nodeName, c.nodeName, "node")
+	case <-time.After(10 * time.Second):
+		t.Fatal("observer not called")
+	}
+	testCtx.waitForSchedulingContexts(t)
+}
 
-			obj.SetResourceVersion(fmt.Sprintf("%d", resourceVersionCounter))
-			resourceVersionCounter++
+func TestClaimEventCoalescing(t *testing.T) {
+	const window = 100 * time.Millisecond
+	otherPod := st.MakePod().Name("other-pod").Namespace(namespace).UID("other-uid").Obj()
+
+	// burst returns the hints for n claim events of the pod.
+	burst := func(t *testing.T, hintFn framework.QueueingHintFn, logger klog.Logger, pod *v1.Pod, n int) []framework.QueueingHint {
+		t.Helper()
+		var hints []framework.QueueingHint
+		for i := 0; i < n; i++ {
+			hint, err := hintFn(logger, pod, nil, pendingClaim)
+			require.NoError(t, err)
+			hints = append(hints, hint)
 		}
-		return false, nil, nil
+		return hints
+	}
+	queue := func(logger klog.Logger, pod *v1.Pod, oldObj, newObj interface{}) (framework.QueueingHint, error) {
+		return framework.Queue, nil
 	}
+	skip := func(logger klog.Logger, pod *v1.Pod, oldObj, newObj interface{}) (framework.QueueingHint, error) {
+		return framework.QueueSkip, nil
+	}
+	oneQueue := []framework.QueueingHint{framework.Queue, framework.QueueSkip, framework.QueueSkip, framework.QueueSkip, framework.QueueSkip, framework.QueueSkip, framework.QueueSkip, framework.QueueSkip}
+
+	t.Run("burst", func(t *testing.T) {
+		logger := ktesting.Init(t).Logger()
+		fakeClock := testingclock.NewFakePassiveClock(time.Now())
+		coalescer := newClaimEventCoalescer(window, fakeClock)
+		hintFn := coalescer.wrap(queue)
+
+		assert.Equal(t, oneQueue, burst(t, hintFn, logger, podWithClaimName, 8), "burst")
+		assert.Equal(t, []framework.QueueingHint{framework.Queue}, burst(t, hintFn, logger, otherPod, 1), "other pod")
+
+		fakeClock.SetTime(fakeClock.Now().Add(window))
+		assert.Equal(t, oneQueue, burst(t, hintFn, logger, podWithClaimName, 8), "burst after window")
+
+		coalescer.forget(podWithClaimName)
+		assert.Equal(t, oneQueue, burst(t, hintFn, logger, podWithClaimName, 8), "burst after scheduling attempt")
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		logger := ktesting.Init(t).Logger()
+		coalescer := newClaimEventCoalescer(window, testingclock.NewFakePassiveClock(time.Now()))
+
+		assert.Equal(t, []framework.QueueingHint{framework.QueueSkip, framework.QueueSkip}, burst(t, coalescer.wrap(skip), logger, podWithClaimName, 2), "skipped events")
+		// Skipped events don't count.
+		assert.Equal(t, []framework.QueueingHint{framework.Queue}, burst(t, coalescer.wrap(queue), logger, podWithClaimName, 1), "first queued event")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		logger := ktesting.Init(t).Logger()
+		coalescer := newClaimEventCoalescer(0, testingclock.NewFakePassiveClock(time.Now()))
+		require.Nil(t, coalescer)
+		coalescer.forget(podWithClaimName)
+
+		hints := burst(t, coalescer.wrap(queue), logger, podWithClaimName, 8)
+		assert.Equal(t, []framework.QueueingHint{framework.Queue, framework.Queue, framework.Queue, framework.Queue, framework.Queue, framework.Queue, framework.Queue, framework.Queue}, hints, "pass-through")
+	})
+
+	t.Run("plugin", func(t *testing.T) {
+		testCtx := setup(t, nil, []*resourceapi.ResourceClaim{pendingClaim}, nil, nil, nil, feature.Features{EnableDynamicResourceAllocation: true})
+		assert.Nil(t, testCtx.p.claimEvents, "coalescing must be disabled by default")
+
+		testCtx = setup(t, nil, []*resourceapi.ResourceClaim{pendingClaim}, nil, nil, nil, feature.Features{EnableDynamicResourceAllocation: true}, withClock(testingclock.NewFakeClock(time.Now())), withArgs(func(args *config.DynamicResourcesArgs) {
+			args.ClaimEventCoalescingMilliseconds = window.Milliseconds()
+		}))
+		hintFn := testCtx.p.claimEvents.wrap(queue)
+		logger := ktesting.Init(t).Logger()
+		assert.Equal(t, []framework.QueueingHint{framework.Queue, framework.QueueSkip}, burst(t, hintFn, logger, podWithClaimName, 2), "before PreFilter")
+
+		// PreFilter starts a new attempt. Whether it succeeds
+		// doesn't matter.
+		testCtx.p.PreFilter(testCtx.ctx, framework.NewCycleState(), podWithClaimName)
+		assert.Equal(t, []framework.QueueingHint{framework.Queue, framework.QueueSkip}, burst(t, hintFn, logger, podWithClaimName, 2), "after PreFilter")
+	})
 }
 
 func Test_isSchedulableAfterClaimChange(t *testing.T) {
+	claimReservedForOtherPod := st.FromResourceClaim(allocatedClaim).ReservedForPod(otherPodName, types.UID(otherPodName)).Obj()
 	testcases := map[string]struct {
 		pod            *v1.Pod
 		claims         []*resourceapi.ResourceClaim
 		oldObj, newObj interface{}
 		expectedHint   framework.QueueingHint
 		expectedErr    bool
+		// expectedReason, if set, must be logged as reason for the hint.
+		expectedReason string
 	}{
 		"skip-deletes": {
 			pod:          podWithClaimTemplate,
@@ -1426,7 +6197,17 @@ func Test_isSchedulableAfterClaimChange(t *testing.T) {
 				claim.Status.Allocation = &resourceapi.AllocationResult{}
 				return claim
 			}(),
-			expectedHint: framework.Queue,
+			expectedHint:   framework.Queue,
+			expectedReason: "queueing because claim status.allocation changed",
+		},
+		"queue-on-deletion-cancelled": {
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{deletingClaim(pendingClaim)},
+			oldObj: deletingClaim(pendingClaim),
+			newObj: pendingClaim,
+
+			expectedHint:   framework.Queue,
+			expectedReason: "queueing because claim is no longer being deleted",
 		},
 		"structured-claim-deallocate": {
 			pod:    podWithClaimName,
@@ -1441,11 +6222,59 @@ func Test_isSchedulableAfterClaimChange(t *testing.T) {
 			// claims not using structured parameters.
 			expectedHint: framework.Queue,
 		},
+		// The next three cases are the sequence of events after
+		// PostFilter requested deallocation of a claim for the pod.
+		"skip-deallocation-requested-by-postfilter": {
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{inUseClaim},
+			oldObj: inUseClaim,
+			newObj: func() *resourceapi.ResourceClaim {
+				claim := inUseClaim.DeepCopy()
+				claim.Status.ReservedFor = nil
+				claim.Status.DeallocationRequested = true
+				return claim
+			}(),
+			expectedHint:   framework.QueueSkip,
+			expectedReason: "skipping because deallocation is still pending",
+		},
+		"queue-on-deallocation-by-driver": {
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{deallocatingClaim},
+			oldObj: deallocatingClaim,
+			newObj: func() *resourceapi.ResourceClaim {
+				claim := deallocatingClaim.DeepCopy()
+				claim.Status.Allocation = nil
+				claim.Status.DeallocationRequested = false
+				return claim
+			}(),
+			expectedHint:   framework.Queue,
+			expectedReason: "queueing because claim status.allocation and status.deallocationRequested changed",
+		},
+		"queue-on-reallocation": {
+			pod:            podWithClaimName,
+			claims:         []*resourceapi.ResourceClaim{pendingClaim},
+			oldObj:         pendingClaim,
+			newObj:         allocatedClaim,
+			expectedHint:   framework.Queue,
+			expectedReason: "queueing because claim status.allocation changed",
+		},
+		"queue-on-deallocation-requested-for-other-consumer": {
+			pod:    podWithClaimName,
+			claims: []*resourceapi.ResourceClaim{claimReservedForOtherPod},
+			oldObj: claimReservedForOtherPod,
+			newObj: func() *resourceapi.ResourceClaim {
+				claim := allocatedClaim.DeepCopy()
+				claim.Status.DeallocationRequested = true
+				return claim
+			}(),
+			expectedHint: framework.Queue,
+		},
 	}
 
 	for name, tc := range testcases {
 		t.Run(name, func(t *testing.T) {
-			logger, tCtx := ktesting.NewTestContext(t)
+			tCtx := ktesting.Init(t, initoption.BufferLogs(true))
+			logger := tCtx.Logger()
 			features := feature.Features{
 				EnableDynamicResourceAllocation: true,
 			}
@@ -1497,6 +6326,10 @@ func Test_isSchedulableAfterClaimChange(t *testing.T) {
 
 			require.NoError(t, err)
 			require.Equal(t, tc.expectedHint, actualHint)
+			if tc.expectedReason != "" {
+				output := logger.GetSink().(ktesting.Underlier).GetBuffer().String()
+				assert.Contains(t, output, fmt.Sprintf("reason=%q", tc.expectedReason))
+			}
 		})
 	}
 }
@@ -1509,6 +6342,8 @@ func Test_isSchedulableAfterPodSchedulingContextChange(t *testing.T) {
 		oldObj, newObj interface{}
 		expectedHint   framework.QueueingHint
 		expectedErr    bool
+		// expectedReason, if set, must be logged as reason for the hint.
+		expectedReason string
 	}{
 		"skip-deleted": {
 			pod:          podWithClaimTemplate,
@@ -1558,6 +6393,13 @@ func Test_isSchedulableAfterPodSchedulingContextChange(t *testing.T) {
 			newObj:       schedulingInfo,
 			expectedHint: framework.QueueSkip,
 		},
+		"skip-other-pod": {
+			pod:          podWithClaimTemplateInStatus,
+			claims:       []*resourceapi.ResourceClaim{pendingClaim},
+			oldObj:       st.FromPodSchedulingContexts(scheduling).OwnerReference(podName, "other-pod-uid", podKind).Obj(),
+			newObj:       st.FromPodSchedulingContexts(schedulingInfo).OwnerReference(podName, "other-pod-uid", podKind).Obj(),
+			expectedHint: framework.QueueSkip,
+		},
 		"skip-missing-infos": {
 			pod:          podWithClaimTemplateInStatus,
 			claims:       []*resourceapi.ResourceClaim{pendingClaim},
@@ -1566,11 +6408,12 @@ func Test_isSchedulableAfterPodSchedulingContextChange(t *testing.T) {
 			expectedHint: framework.QueueSkip,
 		},
 		"queue-new-infos": {
-			pod:          podWithClaimTemplateInStatus,
-			claims:       []*resourceapi.ResourceClaim{pendingClaim},
-			oldObj:       scheduling,
-			newObj:       schedulingInfo,
-			expectedHint: framework.Queue,
+			pod:            podWithClaimTemplateInStatus,
+			claims:         []*resourceapi.ResourceClaim{pendingClaim},
+			oldObj:         scheduling,
+			newObj:         schedulingInfo,
+			expectedHint:   framework.Queue,
+			expectedReason: "queueing because drivers provided information for all claims",
 		},
 		"queue-bad-selected-node": {
 			pod:    podWithClaimTemplateInStatus,
@@ -1586,7 +6429,8 @@ func Test_isSchedulableAfterPodSchedulingContextChange(t *testing.T) {
 				scheduling.Status.ResourceClaims[0].UnsuitableNodes = append(scheduling.Status.ResourceClaims[0].UnsuitableNodes, scheduling.Spec.SelectedNode)
 				return scheduling
 			}(),
-			expectedHint: framework.Queue,
+			expectedHint:   framework.Queue,
+			expectedReason: "queueing because the selected node is unsuitable",
 		},
 		"skip-spec-changes": {
 			pod:    podWithClaimTemplateInStatus,
@@ -1597,7 +6441,8 @@ func Test_isSchedulableAfterPodSchedulingContextChange(t *testing.T) {
 				scheduling.Spec.SelectedNode = workerNode.Name
 				return scheduling
 			}(),
-			expectedHint: framework.QueueSkip,
+			expectedHint:   framework.QueueSkip,
+			expectedReason: "skipping because only the spec changed",
 		},
 		"backoff-other-changes": {
 			pod:    podWithClaimTemplateInStatus,
@@ -1608,7 +6453,8 @@ func Test_isSchedulableAfterPodSchedulingContextChange(t *testing.T) {
 				scheduling.Finalizers = append(scheduling.Finalizers, "foo")
 				return scheduling
 			}(),
-			expectedHint: framework.Queue,
+			expectedHint:   framework.Queue,
+			expectedReason: "queueing because of unknown changes",
 		},
 	}
 
@@ -1616,7 +6462,7 @@ func Test_isSchedulableAfterPodSchedulingContextChange(t *testing.T) {
 		tc := tc
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
-			logger, _ := ktesting.NewTestContext(t)
+			logger := ktesting.Init(t, initoption.BufferLogs(true)).Logger()
 			features := feature.Features{
 				EnableDynamicResourceAllocation: true,
 				EnableDRAControlPlaneController: true,
@@ -1630,6 +6476,472 @@ func Test_isSchedulableAfterPodSchedulingContextChange(t *testing.T) {
 
 			require.NoError(t, err)
 			require.Equal(t, tc.expectedHint, actualHint)
+			if tc.expectedReason != "" {
+				output := logger.GetSink().(ktesting.Underlier).GetBuffer().String()
+				assert.Contains(t, output, fmt.Sprintf("reason=%q", tc.expectedReason))
+			}
+		})
+	}
+}
+
+// TestNoOpUpdates covers the update events that an informer delivers for all
+// objects during a resync. They must not cause pods to be retried.
+func TestNoOpUpdates(t *testing.T) {
+	metrics.RegisterMetrics()
+	withResourceVersion := func(obj metav1.Object, resourceVersion string) metav1.Object {
+		obj.SetResourceVersion(resourceVersion)
+		return obj
+	}
+	claim := withResourceVersion(pendingClaim.DeepCopy(), "1")
+	podScheduling := withResourceVersion(schedulingInfo.DeepCopy(), "1")
+
+	testcases := map[string]struct {
+		resource       string
+		oldObj, newObj interface{}
+		expectSkip     bool
+	}{
+		"claim-identical": {
+			resource:   "resourceclaims",
+			oldObj:     claim,
+			newObj:     claim,
+			expectSkip: true,
+		},
+		"claim-resource-version": {
+			resource:   "resourceclaims",
+			oldObj:     claim,
+			newObj:     withResourceVersion(pendingClaim.DeepCopy(), "2"),
+			expectSkip: true,
+		},
+		"claim-changed": {
+			resource:   "resourceclaims",
+			oldObj:     claim,
+			newObj:     withResourceVersion(deallocatingClaim.DeepCopy(), "2"),
+			expectSkip: false,
+		},
+		"scheduling-identical": {
+			resource:   "podschedulingcontexts",
+			oldObj:     podScheduling,
+			newObj:     podScheduling,
+			expectSkip: true,
+		},
+		"scheduling-resource-version": {
+			resource:   "podschedulingcontexts",
+			oldObj:     podScheduling,
+			newObj:     withResourceVersion(schedulingInfo.DeepCopy(), "2"),
+			expectSkip: true,
+		},
+		"scheduling-changed": {
+			resource:   "podschedulingcontexts",
+			oldObj:     podScheduling,
+			newObj:     withResourceVersion(schedulingPotential.DeepCopy(), "2"),
+			expectSkip: false,
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			logger := ktesting.Init(t).Logger()
+			features := feature.Features{
+				EnableDynamicResourceAllocation: true,
+				EnableDRAControlPlaneController: true,
+			}
+			testCtx := setup(t, nil, []*resourceapi.ResourceClaim{pendingClaim}, nil, nil, nil, features)
+			skipped := metrics.SkippedNoOpEvents.WithLabelValues(tc.resource)
+			before, err := testutil.GetCounterMetricValue(skipped)
+			require.NoError(t, err)
+
+			var hint framework.QueueingHint
+			if tc.resource == "resourceclaims" {
+				hint, err = testCtx.p.isSchedulableAfterClaimChange(logger, podWithClaimTemplateInStatus, tc.oldObj, tc.newObj)
+			} else {
+				hint, err = testCtx.p.isSchedulableAfterPodSchedulingContextChange(logger, podWithClaimTemplateInStatus, tc.oldObj, tc.newObj)
+			}
+			require.NoError(t, err)
+			after, err := testutil.GetCounterMetricValue(skipped)
+			require.NoError(t, err)
+			if tc.expectSkip {
+				assert.Equal(t, framework.QueueSkip, hint, "hint")
+				assert.Equal(t, before+1, after, "skipped no-op events")
+			} else {
+				assert.Equal(t, before, after, "skipped no-op events")
+			}
+		})
+	}
+}
+
+func Test_isSchedulableAfterResourceSliceChange(t *testing.T) {
+	driverClass := &resourceapi.DeviceClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: className,
+		},
+		Spec: resourceapi.DeviceClassSpec{
+			Selectors: []resourceapi.DeviceSelector{{
+				CEL: &resourceapi.CELDeviceSelector{
+					Expression: fmt.Sprintf(`device.driver == "%s"`, driver),
+				},
+			}},
+		},
+	}
+	otherDriverSlice := st.MakeResourceSlice(nodeName, "other-driver").Device("instance-1", nil).Obj()
+	largerOtherDriverSlice := st.MakeResourceSlice(nodeName, "other-driver").Device("instance-1", nil).Device("instance-2", nil).Obj()
+	largerWorkerNodeSlice := st.MakeResourceSlice(nodeName, driver).Device("instance-1", nil).Device("instance-2", nil).Obj()
+	taintedWorkerNodeSlice := st.MakeResourceSlice(nodeName, driver).DeviceWithTaints("instance-1", v1.Taint{Key: "example.com/unhealthy", Effect: v1.TaintEffectNoSchedule}).Obj()
+	modelSlice := func(model string) *resourceapi.ResourceSlice {
+		return st.MakeResourceSlice(nodeName, driver).Device("instance-1", map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"model": {StringValue: &model}}).Obj()
+	}
+	nextGenerationWorkerNodeSlice := workerNodeSlice.DeepCopy()
+	nextGenerationWorkerNodeSlice.Spec.Pool.Generation++
+	modelClaim := structuredClaim(pendingClaim).DeepCopy()
+	modelClaim.Spec.Devices.Requests[0].Selectors = []resourceapi.DeviceSelector{{
+		CEL: &resourceapi.CELDeviceSelector{
+			Expression: fmt.Sprintf(`device.attributes["%s"].model == "b"`, driver),
+		},
+	}}
+
+	testcases := map[string]struct {
+		pod            *v1.Pod
+		claims         []*resourceapi.ResourceClaim
+		classes        []*resourceapi.DeviceClass
+		oldObj, newObj interface{}
+		// driverReadiness sets DriverReadinessSeconds.
+		driverReadiness int64
+		expectedHint    framework.QueueingHint
+		expectedErr     bool
+	}{
+		"backoff-wrong-new-object": {
+			pod:         podWithClaimName,
+			newObj:      "not-a-slice",
+			expectedErr: true,
+		},
+		"skip-missing-claim": {
+			pod:          podWithClaimName,
+			newObj:       workerNodeSlice,
+			expectedHint: framework.QueueSkip,
+		},
+		"skip-allocated-claim": {
+			pod:          podWithClaimName,
+			claims:       []*resourceapi.ResourceClaim{structuredClaim(allocatedClaim)},
+			classes:      []*resourceapi.DeviceClass{driverClass},
+			newObj:       workerNodeSlice,
+			expectedHint: framework.QueueSkip,
+		},
+		"skip-missing-class": {
+			pod:          podWithClaimName,
+			claims:       []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			newObj:       workerNodeSlice,
+			expectedHint: framework.QueueSkip,
+		},
+		"skip-other-driver": {
+			pod:          podWithClaimName,
+			claims:       []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:      []*resourceapi.DeviceClass{driverClass},
+			newObj:       otherDriverSlice,
+			expectedHint: framework.QueueSkip,
+		},
+		"skip-same-devices": {
+			pod:          podWithClaimName,
+			claims:       []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:      []*resourceapi.DeviceClass{driverClass},
+			oldObj:       workerNodeSlice,
+			newObj:       workerNodeSlice,
+			expectedHint: framework.QueueSkip,
+		},
+		"queue-on-add": {
+			pod:          podWithClaimName,
+			claims:       []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:      []*resourceapi.DeviceClass{driverClass},
+			newObj:       workerNodeSlice,
+			expectedHint: framework.Queue,
+		},
+		"queue-on-more-devices": {
+			pod:          podWithClaimName,
+			claims:       []*resourceapi.ResourceClaim{structuredClaim(withDeviceCount(pendingClaim, 2))},
+			classes:      []*resourceapi.DeviceClass{driverClass},
+			oldObj:       workerNodeSlice,
+			newObj:       largerWorkerNodeSlice,
+			expectedHint: framework.Queue,
+		},
+		"queue-on-matching-request": {
+			pod:          podWithClaimName,
+			claims:       []*resourceapi.ResourceClaim{modelClaim},
+			classes:      []*resourceapi.DeviceClass{driverClass},
+			oldObj:       modelSlice("a"),
+			newObj:       modelSlice("b"),
+			expectedHint: framework.Queue,
+		},
+		"queue-on-removed-taint": {
+			pod:          podWithClaimName,
+			claims:       []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:      []*resourceapi.DeviceClass{driverClass},
+			oldObj:       taintedWorkerNodeSlice,
+			newObj:       workerNodeSlice,
+			expectedHint: framework.Queue,
+		},
+		"queue-on-new-pool-generation": {
+			pod:             podWithClaimName,
+			claims:          []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:         []*resourceapi.DeviceClass{driverClass},
+			oldObj:          workerNodeSlice,
+			newObj:          nextGenerationWorkerNodeSlice,
+			driverReadiness: 60,
+			expectedHint:    framework.Queue,
+		},
+		"skip-new-pool-generation-without-readiness": {
+			pod:          podWithClaimName,
+			claims:       []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:      []*resourceapi.DeviceClass{driverClass},
+			oldObj:       workerNodeSlice,
+			newObj:       nextGenerationWorkerNodeSlice,
+			expectedHint: framework.QueueSkip,
+		},
+		"skip-other-driver-update": {
+			pod:          podWithClaimName,
+			claims:       []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)},
+			classes:      []*resourceapi.DeviceClass{driverClass},
+			oldObj:       otherDriverSlice,
+			newObj:       largerOtherDriverSlice,
+			expectedHint: framework.QueueSkip,
+		},
+	}
+
+	for name, tc := range testcases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			logger := ktesting.Init(t).Logger()
+			features := feature.Features{
+				EnableDynamicResourceAllocation: true,
+			}
+			testCtx := setup(t, nil, tc.claims, tc.classes, nil, nil, features, withArgs(func(args *config.DynamicResourcesArgs) {
+				args.DriverReadinessSeconds = tc.driverReadiness
+			}))
+			actualHint, err := testCtx.p.isSchedulableAfterResourceSliceChange(logger, tc.pod, tc.oldObj, tc.newObj)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedHint, actualHint)
+		})
+	}
+}
+
+func Test_isSchedulableAfterNodeChange(t *testing.T) {
+	matchingNode := &st.MakeNode().Name(node2Name).Label("no-such-label", "no-such-value").Node
+
+	testcases := map[string]struct {
+		claims         []*resourceapi.ResourceClaim
+		keepByDefault  bool
+		oldObj, newObj interface{}
+		// driverReadiness sets DriverReadinessSeconds.
+		driverReadiness int64
+		expectedHint    framework.QueueingHint
+		expectedErr     bool
+	}{
+		"backoff-wrong-new-object": {
+			newObj:      "not-a-node",
+			expectedErr: true,
+		},
+		"queue-missing-claim": {
+			newObj:       workerNode,
+			expectedHint: framework.Queue,
+		},
+		"queue-deallocation": {
+			claims:       []*resourceapi.ResourceClaim{allocatedClaimWithWrongTopology},
+			newObj:       workerNode,
+			expectedHint: framework.Queue,
+		},
+		"skip-mismatch": {
+			claims:       []*resourceapi.ResourceClaim{withKeepAllocation(allocatedClaimWithWrongTopology)},
+			newObj:       workerNode,
+			expectedHint: framework.QueueSkip,
+		},
+		"skip-mismatch-by-default": {
+			claims:        []*resourceapi.ResourceClaim{structuredClaim(allocatedClaimWithWrongTopology)},
+			keepByDefault: true,
+			newObj:        workerNode,
+			expectedHint:  framework.QueueSkip,
+		},
+		"queue-match": {
+			claims:       []*resourceapi.ResourceClaim{withKeepAllocation(allocatedClaimWithWrongTopology)},
+			newObj:       matchingNode,
+			expectedHint: framework.Queue,
+		},
+		"queue-label-change": {
+			claims:       []*resourceapi.ResourceClaim{withKeepAllocation(structuredClaim(allocatedClaimWithWrongTopology))},
+			oldObj:       workerNode2,
+			newObj:       matchingNode,
+			expectedHint: framework.Queue,
+		},
+	}
+
+	for name, tc := range testcases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			logger := ktesting.Init(t).Logger()
+			features := feature.Features{
+				EnableDynamicResourceAllocation: true,
+			}
+			testCtx := setup(t, nil, tc.claims, nil, nil, nil, features, withArgs(func(args *config.DynamicResourcesArgs) {
+				args.KeepAllocationOnTopologyMismatch = tc.keepByDefault
+			}))
+			actualHint, err := testCtx.p.isSchedulableAfterNodeChange(logger, podWithClaimName, tc.oldObj, tc.newObj)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedHint, actualHint)
+		})
+	}
+}
+
+func TestVerifyAllocatedSelectors(t *testing.T) {
+	metrics.RegisterMetrics()
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	claim := structuredClaim(allocatedClaim).DeepCopy()
+	claim.Spec.Devices.Requests[0].Selectors = []resourceapi.DeviceSelector{{
+		CEL: &resourceapi.CELDeviceSelector{Expression: `device.attributes["` + driver + `"].healthy`},
+	}}
+	testCtx := setup(t, nil, []*resourceapi.ResourceClaim{claim}, []*resourceapi.DeviceClass{deviceClass}, nil, nil, features, withArgs(func(args *config.DynamicResourcesArgs) {
+		args.VerifyAllocatedSelectors = true
+	}))
+	mismatches := metrics.AllocatedSelectorMismatches.WithLabelValues(driver)
+	initialMismatches, err := testutil.GetCounterMetricValue(mismatches)
+	require.NoError(t, err)
+
+	healthy := func(value bool) map[resourceapi.QualifiedName]resourceapi.DeviceAttribute {
+		return map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"healthy": {BoolValue: ptr.To(value)}}
+	}
+	slice, err := testCtx.client.ResourceV1alpha3().ResourceSlices().Create(testCtx.ctx, st.MakeResourceSlice(nodeName, driver).Device("instance-1", healthy(true)).Obj(), metav1.CreateOptions{})
+	require.NoError(t, err, "create slice")
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		_, err := testCtx.p.sliceLister.Get(slice.Name)
+		assert.NoError(t, err)
+	}, 10*time.Second, 10*time.Millisecond)
+	testCtx.client.ClearActions()
+
+	// The driver flips the health of the allocated device.
+	slice = slice.DeepCopy()
+	slice.Spec.Devices[0].Basic.Attributes = healthy(false)
+	_, err = testCtx.client.ResourceV1alpha3().ResourceSlices().Update(testCtx.ctx, slice, metav1.UpdateOptions{})
+	require.NoError(t, err, "update slice")
+
+	select {
+	case event := <-testCtx.recorder.Events:
+		assert.Contains(t, event, v1.EventTypeWarning+" "+ReasonAllocatedDeviceMismatch+" ")
+		assert.Contains(t, event, "instance-1")
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for warning event")
+	}
+	value, err := testutil.GetCounterMetricValue(mismatches)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), value-initialMismatches, "mismatches")
+
+	// The allocation is not touched.
+	for _, action := range testCtx.client.Actions() {
+		assert.NotEqual(t, "resourceclaims", action.GetResource().Resource, "unexpected claim %s", action.GetVerb())
+	}
+	actualClaim, err := testCtx.client.ResourceV1alpha3().ResourceClaims(namespace).Get(testCtx.ctx, claim.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, claim.Status.Allocation, actualClaim.Status.Allocation, "allocation")
+}
+
+func TestErrorContext(t *testing.T) {
+	cause := errors.New("fake error")
+	testcases := map[string]struct {
+		err       error
+		expectErr string
+	}{
+		"nil": {
+			err: errorContext{pod: podWithClaimName}.wrap(nil),
+		},
+		"empty": {
+			err:       errorContext{}.wrap(cause),
+			expectErr: "fake error",
+		},
+		"all": {
+			err:       errorContext{pod: podWithClaimName, claim: pendingClaim, request: "req-1", class: className, nodeName: nodeName}.wrap(cause),
+			expectErr: "pod default/my-pod: claim default/my-pod-my-resource: request req-1: class my-resource-class: node worker: fake error",
+		},
+		"claim-selector": {
+			err:       errorContext{pod: podWithClaimName, nodeName: nodeName}.wrap(&structured.SelectorError{Claim: pendingClaim, Request: "req-1", Index: 1, Err: cause}),
+			expectErr: "pod default/my-pod: claim default/my-pod-my-resource: request req-1: node worker: selector #1: fake error",
+		},
+		"class-selector": {
+			err:       errorContext{pod: podWithClaimName, nodeName: nodeName}.wrap(&structured.SelectorError{Claim: pendingClaim, Request: "req-1", Class: deviceClass, Index: 0, Err: cause}),
+			expectErr: "pod default/my-pod: claim default/my-pod-my-resource: request req-1: class my-resource-class: node worker: selector #0: fake error",
+		},
+		"merged": {
+			// The order is the same, no matter in which order
+			// the context gets added.
+			err:       errorContext{pod: podWithClaimName, nodeName: nodeName}.wrap(errorContext{claim: pendingClaim, request: "req-1"}.wrap(cause)),
+			expectErr: "pod default/my-pod: claim default/my-pod-my-resource: request req-1: node worker: fake error",
+		},
+		"existing-context-kept": {
+			err:       errorContext{nodeName: "other-node"}.wrap(errorContext{nodeName: nodeName}.wrap(cause)),
+			expectErr: "node worker: fake error",
+		},
+		"nested": {
+			// Context inside some other error is left alone.
+			err:       errorContext{pod: podWithClaimName}.wrap(fmt.Errorf("some operation: %w", errorContext{claim: pendingClaim}.wrap(cause))),
+			expectErr: "pod default/my-pod: some operation: claim default/my-pod-my-resource: fake error",
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			if tc.expectErr == "" {
+				assert.NoError(t, tc.err)
+				return
+			}
+			assert.EqualError(t, tc.err, tc.expectErr)
+			assert.ErrorIs(t, tc.err, cause)
 		})
 	}
 }
+
+func TestTerminatingDeviceClass(t *testing.T) {
+	features := feature.Features{
+		EnableDynamicResourceAllocation: true,
+	}
+	preFilter := func(t *testing.T, testCtx *testContext) *framework.Status {
+		t.Helper()
+		_, status := testCtx.p.PreFilter(testCtx.ctx, framework.NewCycleState(), podWithClaimName)
+		return status
+	}
+	logger := ktesting.Init(t).Logger()
+
+	testCtx := setup(t, []*v1.Node{workerNode}, []*resourceapi.ResourceClaim{structuredClaim(pendingClaim)}, []*resourceapi.DeviceClass{terminatingDeviceClass}, nil, []apiruntime.Object{workerNodeSlice}, features)
+	require.Equal(t, framework.UnschedulableAndUnresolvable, preFilter(t, testCtx).Code(), "PreFilter with terminating class")
+
+	// Deleting a class does not help the pod...
+	hint, err := testCtx.p.isSchedulableAfterClassChange(logger, podWithClaimName, deviceClass, terminatingDeviceClass)
+	require.NoError(t, err)
+	assert.Equal(t, framework.QueueSkip, hint, "class deletion")
+	// ... but cancelling the deletion or a replacement does.
+	hint, err = testCtx.p.isSchedulableAfterClassChange(logger, podWithClaimName, terminatingDeviceClass, deviceClass)
+	require.NoError(t, err)
+	assert.Equal(t, framework.Queue, hint, "deletion cancelled")
+	hint, err = testCtx.p.isSchedulableAfterClassChange(logger, podWithClaimName, nil, deviceClass)
+	require.NoError(t, err)
+	assert.Equal(t, framework.Queue, hint, "class recreated")
+
+	err = testCtx.client.ResourceV1alpha3().DeviceClasses().Delete(testCtx.ctx, className, metav1.DeleteOptions{})
+	require.NoError(t, err, "delete class")
+	_, err = testCtx.client.ResourceV1alpha3().DeviceClasses().Create(testCtx.ctx, deviceClass, metav1.CreateOptions{})
+	require.NoError(t, err, "recreate class")
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		class, err := testCtx.p.classLister.Get(className)
+		if assert.NoError(t, err) {
+			assert.Nil(t, class.DeletionTimestamp, "deletion timestamp")
+		}
+	}, 10*time.Second, 10*time.Millisecond)
+	status := preFilter(t, testCtx)
+	assert.True(t, status.IsSuccess(), "PreFilter with recreated class: %v", status)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/dynamic-resource-allocation/structured"
+	"k8s.io/klog/v2"
+)
+
+// errorContext describes which objects an error of the plugin is about.
+// All errors which the plugin returns as status use the same format:
+//
+//	pod <namespace>/<name>: claim <namespace>/<name>: request <name>: class <name>: node <name>: <cause>
+//
+// Parts which are not known or do not apply are left out, the order of
+// the others is always the same. The cause stays at the end unchanged,
+// which matters for errors of the allocator: a CEL runtime error still
+// ends with the message of CEL, so searching for it keeps working. The
+// allocator describes the claim or class of a selector error itself, wrap
+// turns that into context.
+//
+// Reasons of Unschedulable statuses use the same format without pod and
+// node. The status is about the pod anyway and identical reasons get
+// aggregated across nodes.
+//
+// Context can be added in several steps. A function which handles one
+// claim wraps its error with the claim, the extension point then adds the
+// pod and the node. The result is the same as if all of them had been
+// added at once.
+type errorContext struct {
+	pod      *v1.Pod
+	claim    *resourceapi.ResourceClaim
+	request  string
+	class    string
+	nodeName string
+}
+
+// contextError is an error with context, see errorContext.
+type contextError struct {
+	errorContext
+	err error
+}
+
+func (e *contextError) Error() string {
+	var prefix strings.Builder
+	if e.pod != nil {
+		fmt.Fprintf(&prefix, "pod %s: ", klog.KObj(e.pod))
+	}
+	if e.claim != nil {
+		fmt.Fprintf(&prefix, "claim %s: ", klog.KObj(e.claim))
+	}
+	if e.request != "" {
+		fmt.Fprintf(&prefix, "request %s: ", e.request)
+	}
+	if e.class != "" {
+		fmt.Fprintf(&prefix, "class %s: ", e.class)
+	}
+	if e.nodeName != "" {
+		fmt.Fprintf(&prefix, "node %s: ", e.nodeName)
+	}
+	return prefix.String() + e.err.Error()
+}
+
+func (e *contextError) Unwrap() error {
+	return e.err
+}
+
+// reason returns the reason for an Unschedulable status with the context.
+func (c errorContext) reason(message string) string {
+	return c.wrap(errors.New(message)).Error()
+}
+
+// wrap adds the context to the error. Nil is returned unchanged. If the
+// error already has context, the parts which are missing there get added,
+// existing ones are kept.
+func (c errorContext) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	if selectorErr, ok := err.(*structured.SelectorError); ok {
+		selectorContext := errorContext{claim: selectorErr.Claim, request: selectorErr.Request}
+		if selectorErr.Class != nil {
+			selectorContext.class = selectorErr.Class.Name
+		}
+		err = &contextError{errorContext: selectorContext, err: fmt.Errorf("selector #%d: %w", selectorErr.Index, selectorErr.Err)}
+	}
+	// Only context at the outermost level gets merged. Context further
+	// down belongs to some other part of the message.
+	if existing, ok := err.(*contextError); ok {
+		merged := existing.errorContext
+		if merged.pod == nil {
+			merged.pod = c.pod
+		}
+		if merged.claim == nil {
+			merged.claim = c.claim
+		}
+		if merged.request == "" {
+			merged.request = c.request
+		}
+		if merged.class == "" {
+			merged.class = c.class
+		}
+		if merged.nodeName == "" {
+			merged.nodeName = c.nodeName
+		}
+		return &contextError{errorContext: merged, err: existing.err}
+	}
+	return &contextError{errorContext: c, err: err}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ReasonAllocationFailed is used for the warning event that gets
+	// emitted for a pod when allocating devices failed with an error on
+	// some nodes while other nodes are still usable.
+	ReasonAllocationFailed = "AllocationFailed"
+)
+
+// recordFilterResult remembers whether the allocator failed with an
+// error for the node. The mutex of the state must be locked.
+func (state *stateData) recordFilterResult(nodeName string, err error) {
+	if state.filterErrors == nil {
+		state.filterErrors = make(map[string]error)
+	}
+	state.filterErrors[nodeName] = err
+}
+
+// claimWideFilterError returns the error if the allocator failed with
+// the same error on all nodes where it was invoked. Such an error is
+// caused by the claims or classes, not by the nodes, for example a CEL
+// expression which cannot be evaluated. The pod must remain pending in
+// that case, with the error as reason.
+func (state *stateData) claimWideFilterError() error {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	var claimWideErr error
+	for _, err := range state.filterErrors {
+		switch {
+		case err == nil:
+			return nil
+		case claimWideErr == nil:
+			claimWideErr = err
+		case claimWideErr.Error() != err.Error():
+			return nil
+		}
+	}
+	return claimWideErr
+}
+
+// reportFilterErrors logs the errors of the allocator for nodes which
+// Filter rejected because of them and emits one warning event for the
+// pod. It does that only once per scheduling cycle.
+func (pl *dynamicResources) reportFilterErrors(ctx context.Context, state *stateData, pod *v1.Pod) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if state.filterErrorsReported {
+		return
+	}
+	state.filterErrorsReported = true
+
+	var nodeNames []string
+	for nodeName, err := range state.filterErrors {
+		if err != nil {
+			nodeNames = append(nodeNames, nodeName)
+		}
+	}
+	if len(nodeNames) == 0 {
+		return
+	}
+	sort.Strings(nodeNames)
+	logger := klog.FromContext(ctx)
+	for _, nodeName := range nodeNames {
+		logger.V(2).Info("Allocating devices failed, node not used", "pod", klog.KObj(pod), "node", klog.ObjectRef{Name: nodeName}, "err", state.filterErrors[nodeName])
+	}
+	if pl.eventRecorder != nil {
+		pl.eventRecorder.Eventf(pod, nil, v1.EventTypeWarning, ReasonAllocationFailed, "Scheduling",
+			"Allocating devices failed on %d node(s), for example on node %s: %v", len(nodeNames), nodeNames[0], state.filterErrors[nodeNames[0]])
+	}
+}
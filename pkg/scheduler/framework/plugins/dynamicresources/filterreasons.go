@@ -0,0 +1,107 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"maps"
+	"sync"
+
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+// FilterReason classifies why Filter rejected a node.
+type FilterReason string
+
+const (
+	// FilterReasonUnresolvable means that the pod cannot run on the node
+	// regardless of what other pods do, for example because one of its
+	// claims is allocated for some other node. Filter returns
+	// UnschedulableAndUnresolvable in this case.
+	FilterReasonUnresolvable FilterReason = "Unresolvable"
+
+	// FilterReasonResourcesExhausted means that the node does not have
+	// enough free devices for the pod. Removing other pods which use
+	// devices on the node might help. Filter returns Unschedulable
+	// in this case.
+	FilterReasonResourcesExhausted FilterReason = "ResourcesExhausted"
+)
+
+// FilterReasons records for each node rejected by Filter why it was
+// rejected. Other plugins, for example in PostFilter, can retrieve it
+// with GetFilterReasons.
+type FilterReasons struct {
+	mutex   sync.Mutex
+	reasons map[string]FilterReason
+}
+
+var _ framework.StateData = &FilterReasons{}
+
+// GetFilterReasons returns the reasons recorded during the current
+// scheduling cycle. It returns nil if the pod has no claims.
+func GetFilterReasons(cs *framework.CycleState) *FilterReasons {
+	state, err := cs.Read(FilterReasonsStateKey)
+	if err != nil {
+		return nil
+	}
+	reasons, _ := state.(*FilterReasons)
+	return reasons
+}
+
+// Get returns the reason why Filter rejected the node and false if
+// it didn't reject it.
+func (r *FilterReasons) Get(nodeName string) (FilterReason, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	reason, ok := r.reasons[nodeName]
+	return reason, ok
+}
+
+// rejectedAny returns true if Filter rejected at least one node.
+func (r *FilterReasons) rejectedAny() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.reasons) > 0
+}
+
+// Clone is used when the scheduler simulates scheduling, for example during
+// preemption. Those Filter calls must not affect the original reasons.
+func (r *FilterReasons) Clone() framework.StateData {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return &FilterReasons{reasons: maps.Clone(r.reasons)}
+}
+
+// record stores the reason matching the status returned by Filter for
+// the node. Success and errors are not recorded.
+func (r *FilterReasons) record(nodeName string, status *framework.Status) {
+	var reason FilterReason
+	switch status.Code() {
+	case framework.UnschedulableAndUnresolvable:
+		reason = FilterReasonUnresolvable
+	case framework.Unschedulable:
+		reason = FilterReasonResourcesExhausted
+	default:
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.reasons == nil {
+		r.reasons = make(map[string]FilterReason)
+	}
+	r.reasons[nodeName] = reason
+}
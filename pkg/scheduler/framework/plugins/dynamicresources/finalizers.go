@@ -0,0 +1,200 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/dynamic-resource-allocation/resourceclaim"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// orphanedFinalizerCheckPeriod is the interval between checks
+	// after the initial one.
+	orphanedFinalizerCheckPeriod = 10 * time.Minute
+
+	// orphanedFinalizerQPS and orphanedFinalizerBurst limit the API calls
+	// and events caused by the check, which could otherwise flood the
+	// apiserver after an unclean shutdown left many claims behind.
+	orphanedFinalizerQPS   = 5
+	orphanedFinalizerBurst = 10
+
+	// ReasonOrphanedFinalizer is used for the warning event that gets
+	// emitted for an allocated claim which is not in use anymore.
+	ReasonOrphanedFinalizer = "OrphanedFinalizer"
+
+	// ReasonFinalizerReAdded is used for the warning event that gets
+	// emitted when the finalizer of a claim was removed by someone else
+	// while the scheduler was allocating the claim.
+	ReasonFinalizerReAdded = "FinalizerReAdded"
+
+	// orphanedFinalizerCheckDelay is the default for how long the plugin
+	// waits before checking claims for the first time. This avoids
+	// competing with the scheduling of pods right after startup.
+	orphanedFinalizerCheckDelay = time.Minute
+)
+
+// finalizerCheckOwner is the plugin instance which checks finalizers.
+// There is at most one per process because the instances of all
+// scheduler profiles see the same claims.
+var finalizerCheckOwner struct {
+	mutex sync.Mutex
+	pl    *dynamicResources
+}
+
+// runFinalizerCheck starts checkFinalizers in the background unless some
+// other instance of the plugin already runs it. It must not be called
+// before the first scheduling cycle: all replicas of a scheduler create
+// their plugins, but only the leader schedules pods. A replica which loses
+// the leadership exits, so the check never needs to be stopped while ctx
+// is still active. Once it is canceled, the instance gives up ownership.
+//
+// Claims which some other instance is allocating are not known to the
+// owner. When their finalizer gets removed, that other instance adds it
+// again before storing the allocation.
+//
+// finalizerCheckRunning is done once the instance has given up ownership.
+func (pl *dynamicResources) runFinalizerCheck(ctx context.Context) {
+	finalizerCheckOwner.mutex.Lock()
+	defer finalizerCheckOwner.mutex.Unlock()
+	if finalizerCheckOwner.pl != nil {
+		return
+	}
+	finalizerCheckOwner.pl = pl
+	pl.finalizerCheckRunning.Add(1)
+	go func() {
+		defer pl.finalizerCheckRunning.Done()
+		defer func() {
+			finalizerCheckOwner.mutex.Lock()
+			defer finalizerCheckOwner.mutex.Unlock()
+			finalizerCheckOwner.pl = nil
+		}()
+		pl.checkFinalizers(ctx, pl.finalizerCheckDelay)
+	}()
+}
+
+// checkFinalizers periodically looks at all claims which have the
+// scheduler's finalizer and handles those where the finalizer is no longer
+// needed:
+//   - If the claim is not allocated and the scheduler isn't in the process
+//     of allocating it, then the finalizer is removed. This can happen when
+//     the scheduler was interrupted between adding the finalizer and
+//     storing the allocation.
+//   - If the claim is allocated but not reserved for any existing
+//     consumer, then a warning event is emitted for the claim. Deallocating
+//     it is the responsibility of the resourceclaim controller, so
+//     this case points towards a problem with that controller.
+//
+// It returns when the context is canceled.
+func (pl *dynamicResources) checkFinalizers(ctx context.Context, delay time.Duration) {
+	logger := klog.FromContext(ctx)
+	logger = klog.LoggerWithName(logger, "finalizers")
+	ctx = klog.NewContext(ctx, logger)
+
+	if !cache.WaitForCacheSync(ctx.Done(), pl.cachesSynced...) {
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-pl.clock.After(delay):
+	}
+
+	limiter := flowcontrol.NewTokenBucketRateLimiter(orphanedFinalizerQPS, orphanedFinalizerBurst)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		pl.checkFinalizersOnce(ctx, limiter)
+	}, orphanedFinalizerCheckPeriod)
+}
+
+func (pl *dynamicResources) checkFinalizersOnce(ctx context.Context, limiter flowcontrol.RateLimiter) {
+	logger := klog.FromContext(ctx)
+	for _, obj := range pl.claimAssumeCache.List(nil) {
+		claim, ok := obj.(*resourceapi.ResourceClaim)
+		if !ok || !slices.Contains(claim.Finalizers, resourceapi.Finalizer) {
+			continue
+		}
+		if _, found := pl.inFlightAllocations.load(claim.UID); found {
+			// Currently being allocated by us.
+			continue
+		}
+
+		switch {
+		case claim.Status.Allocation == nil:
+			if err := limiter.Wait(ctx); err != nil {
+				return
+			}
+			pl.removeOrphanedFinalizer(ctx, claim)
+		case !pl.hasExistingConsumer(claim):
+			if err := limiter.Wait(ctx); err != nil {
+				return
+			}
+			logger.V(2).Info("Allocated claim without consumers", "claim", klog.KObj(claim))
+			if recorder := pl.eventRecorder; recorder != nil {
+				recorder.Eventf(claim, nil, v1.EventTypeWarning, ReasonOrphanedFinalizer, "CheckFinalizer",
+					"claim is allocated by the scheduler but not reserved for any existing consumer, it should get deallocated by the resourceclaim controller in kube-controller-manager")
+			}
+		}
+	}
+}
+
+// removeOrphanedFinalizer removes the scheduler's finalizer from a claim
+// which is not allocated. The update is based on the ResourceVersion that
+// the check was done for, so any concurrent change, for example by a
+// scheduling cycle which allocates the claim, causes a conflict and the
+// claim is left alone.
+func (pl *dynamicResources) removeOrphanedFinalizer(ctx context.Context, claim *resourceapi.ResourceClaim) {
+	logger := klog.FromContext(ctx)
+	claim = claim.DeepCopy()
+	claim.Finalizers = slices.DeleteFunc(claim.Finalizers, func(f string) bool { return f == resourceapi.Finalizer })
+	if _, err := pl.clientset.ResourceV1alpha3().ResourceClaims(claim.Namespace).Update(ctx, claim, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			logger.V(5).Info("Claim changed, not removing finalizer", "claim", klog.KObj(claim), "err", err)
+			return
+		}
+		logger.Error(err, "Removing orphaned finalizer failed", "claim", klog.KObj(claim))
+		return
+	}
+	logger.V(2).Info("Removed orphaned finalizer from claim", "claim", klog.KObj(claim))
+}
+
+// hasExistingConsumer returns true if at least one of the consumers in
+// ReservedFor still exists. Only pods can be checked, other consumers
+// are assumed to exist.
+func (pl *dynamicResources) hasExistingConsumer(claim *resourceapi.ResourceClaim) bool {
+	for _, consumer := range claim.Status.ReservedFor {
+		if !resourceclaim.IsPodReference(consumer) {
+			return true
+		}
+		pod, err := pl.podLister.Pods(claim.Namespace).Get(consumer.Name)
+		if err == nil && pod.UID == consumer.UID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"sync"
+	"time"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// inFlightAllocation describes a claim for which Reserve decided on an
+// allocation which has not been written by PreBind yet.
+type inFlightAllocation struct {
+	// claim is a copy of the claim with the allocation in its status.
+	claim *resourceapi.ResourceClaim
+
+	// nodeName is the node for which the claim got allocated.
+	nodeName string
+
+	// podUID identifies the pod which triggered the allocation.
+	podUID types.UID
+
+	// since is when Reserve stored the allocation.
+	since time.Time
+}
+
+// inFlightAllocations maps claim UIDs to their in-flight allocation.
+// See dynamicResources.inFlightAllocations for how it is used.
+type inFlightAllocations struct {
+	m sync.Map
+
+	// deviceTracker gets notified about changes, nil if not needed.
+	deviceTracker *allocatedDevicesTracker
+}
+
+// store adds or replaces the in-flight allocation of the claim.
+func (a *inFlightAllocations) store(allocation *inFlightAllocation) {
+	a.m.Store(allocation.claim.UID, allocation)
+	if a.deviceTracker != nil {
+		a.deviceTracker.markClaim(allocation.claim)
+	}
+}
+
+// load returns the in-flight allocation of the claim with the given UID.
+func (a *inFlightAllocations) load(uid types.UID) (*inFlightAllocation, bool) {
+	obj, ok := a.m.Load(uid)
+	if !ok {
+		return nil, false
+	}
+	return obj.(*inFlightAllocation), true
+}
+
+// loadClaim returns the claim with the in-flight allocation in its status.
+func (a *inFlightAllocations) loadClaim(uid types.UID) (*resourceapi.ResourceClaim, bool) {
+	allocation, ok := a.load(uid)
+	if !ok {
+		return nil, false
+	}
+	return allocation.claim, true
+}
+
+// delete removes the in-flight allocation of the claim and reports
+// whether there was one.
+func (a *inFlightAllocations) delete(uid types.UID) bool {
+	obj, found := a.m.LoadAndDelete(uid)
+	if found && a.deviceTracker != nil {
+		a.deviceTracker.markClaim(obj.(*inFlightAllocation).claim)
+	}
+	return found
+}
+
+// forEach calls f for each in-flight allocation until f returns false.
+func (a *inFlightAllocations) forEach(f func(allocation *inFlightAllocation) bool) {
+	a.m.Range(func(_, value any) bool {
+		return f(value.(*inFlightAllocation))
+	})
+}
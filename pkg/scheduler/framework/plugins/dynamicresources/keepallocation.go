@@ -0,0 +1,111 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/component-helpers/scheduling/corev1/nodeaffinity"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	schedutil "k8s.io/kubernetes/pkg/scheduler/util"
+)
+
+// AnnotationKeepAllocation may be set on a ResourceClaim to "true" or
+// "false". When true, PostFilter does not deallocate the claim if its
+// allocation cannot be used on any of the nodes considered for the pod.
+// The pod then waits for a node which matches the allocation. This is
+// useful when preparing the allocated devices is expensive. The annotation
+// overrides the KeepAllocationOnTopologyMismatch plugin argument.
+const AnnotationKeepAllocation = "resource.kubernetes.io/keep-allocation"
+
+// keepAllocation returns true if the allocation of the claim must be kept
+// even when it doesn't fit the pod. Invalid annotation values are ignored.
+func (pl *dynamicResources) keepAllocation(claim *resourceapi.ResourceClaim) bool {
+	if value, ok := claim.Annotations[AnnotationKeepAllocation]; ok {
+		if keep, err := strconv.ParseBool(value); err == nil {
+			return keep
+		}
+	}
+	return pl.keepAllocationDefault
+}
+
+// claimsWaitingForNode returns the indices of the unavailable claims whose
+// allocation must be kept. Claims for which deallocation was already
+// requested are not included, that cannot be undone.
+func (pl *dynamicResources) claimsWaitingForNode(state *stateData) sets.Set[int] {
+	waiting := sets.New[int]()
+	for index := range state.unavailableClaims {
+		claim := state.claims[index]
+		if claim.Status.Allocation != nil &&
+			!claim.Status.DeallocationRequested &&
+			pl.keepAllocation(claim) {
+			waiting.Insert(index)
+		}
+	}
+	return waiting
+}
+
+// waitingForNodeMessage is the status message of PostFilter when the pod
+// has to wait for a node matching the allocation of the claim.
+func waitingForNodeMessage(claim *resourceapi.ResourceClaim) string {
+	return fmt.Sprintf("waiting for a node matching the existing allocation of claim %s", claim.Name)
+}
+
+// isSchedulableAfterNodeChange is invoked for add and update node events
+// reported by an informer. A pod which has a claim whose allocation gets
+// kept cannot run on a node that doesn't match the allocation, so such a
+// node is not a reason to try again. In all other cases, the pod gets
+// queued: the node might have the devices or labels that it needs.
+func (pl *dynamicResources) isSchedulableAfterNodeChange(logger klog.Logger, pod *v1.Pod, oldObj, newObj interface{}) (framework.QueueingHint, error) {
+	_, modifiedNode, err := schedutil.As[*v1.Node](oldObj, newObj)
+	if err != nil {
+		// Shouldn't happen.
+		return framework.Queue, fmt.Errorf("unexpected object in isSchedulableAfterNodeChange: %w", err)
+	}
+
+	var mismatch *resourceapi.ResourceClaim
+	if err := pl.foreachPodResourceClaim(pod, func(_ string, claim *resourceapi.ResourceClaim) {
+		if mismatch != nil ||
+			claim.Status.Allocation == nil ||
+			claim.Status.Allocation.NodeSelector == nil ||
+			claim.Status.DeallocationRequested ||
+			!pl.keepAllocation(claim) {
+			return
+		}
+		selector, err := nodeaffinity.NewNodeSelector(claim.Status.Allocation.NodeSelector)
+		if err != nil {
+			// Let the scheduling attempt report the problem.
+			return
+		}
+		if !selector.Match(modifiedNode) {
+			mismatch = claim
+		}
+	}); err != nil {
+		logger.V(5).Info("claims of pod not available", "pod", klog.KObj(pod), "node", klog.KObj(modifiedNode), "reason", "queueing because claims cannot be checked", "err", err)
+		return framework.Queue, nil
+	}
+	if mismatch != nil {
+		logger.V(5).Info("node does not match the kept allocation of a claim", "pod", klog.KObj(pod), "node", klog.KObj(modifiedNode), "claim", klog.KObj(mismatch), "reason", "skipping because the pod cannot run on the node")
+		return framework.QueueSkip, nil
+	}
+	return framework.Queue, nil
+}
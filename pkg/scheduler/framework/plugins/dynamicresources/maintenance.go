@@ -0,0 +1,70 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+)
+
+const (
+	// AnnotationResourceSliceMaintenanceWindow may be set by a driver on
+	// its ResourceSlices. The value is "<start>/<end>" with both times in
+	// RFC 3339 format. While the window is active, the devices of the
+	// slice are not used for new allocations. Existing allocations are
+	// not affected.
+	AnnotationResourceSliceMaintenanceWindow = "resource.kubernetes.io/maintenance-window"
+)
+
+// parseMaintenanceWindow parses the value of the maintenance window annotation.
+func parseMaintenanceWindow(value string) (start, end time.Time, err error) {
+	startValue, endValue, ok := strings.Cut(value, "/")
+	if !ok {
+		return time.Time{}, time.Time{}, fmt.Errorf("expected <start>/<end>, got %q", value)
+	}
+	start, err = time.Parse(time.RFC3339, startValue)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("start: %w", err)
+	}
+	end, err = time.Parse(time.RFC3339, endValue)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("end: %w", err)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end %s before start %s", endValue, startValue)
+	}
+	return start, end, nil
+}
+
+// isSliceInMaintenance returns true if the slice has a maintenance window
+// which includes the current time. An invalid window is ignored, i.e. a
+// typo in the annotation does not take devices out of service.
+func (pl *dynamicResources) isSliceInMaintenance(slice *resourceapi.ResourceSlice) bool {
+	value, ok := slice.Annotations[AnnotationResourceSliceMaintenanceWindow]
+	if !ok {
+		return false
+	}
+	start, end, err := parseMaintenanceWindow(value)
+	if err != nil {
+		return false
+	}
+	now := pl.clock.Now()
+	return !now.Before(start) && now.Before(end)
+}
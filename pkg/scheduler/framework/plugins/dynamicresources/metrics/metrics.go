@@ -0,0 +1,122 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// DRASchedulerSubsystem - subsystem name used by the DynamicResources plugin.
+const DRASchedulerSubsystem = "scheduler_dra"
+
+var (
+	// MissingSlices is 1 for each node and driver where the plugin
+	// rejected a pod while the ResourceSlices that the driver published
+	// for the node earlier were gone.
+	MissingSlices = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      DRASchedulerSubsystem,
+			Name:           "missing_slices",
+			Help:           "Set to 1 for a node and driver when the ResourceSlices of the driver for the node are no longer published and a pod could not be scheduled onto the node.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"driver", "node"},
+	)
+
+	// SkippedNoOpEvents counts how often a queueing hint ignored an
+	// update event for a pending pod because the object did not change,
+	// as during a resync of the informer.
+	SkippedNoOpEvents = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      DRASchedulerSubsystem,
+			Name:           "skipped_noop_events_total",
+			Help:           "Number of times that an update event which did not change the object was ignored for a pending pod, by resource.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"resource"},
+	)
+
+	// ResourceSlicesUnavailable is 1 while the plugin cannot list
+	// ResourceSlices because the informer has not synced.
+	ResourceSlicesUnavailable = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      DRASchedulerSubsystem,
+			Name:           "resource_slices_unavailable",
+			Help:           "Set to 1 while ResourceSlices cannot be listed because the informer has not synced, for example because of missing permissions.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	// AllocatedDevices is the number of devices in the most recent
+	// snapshot of devices which are in use by allocated claims.
+	AllocatedDevices = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      DRASchedulerSubsystem,
+			Name:           "allocated_devices",
+			Help:           "Number of devices which are in use by allocated claims, as tracked by the scheduler between scheduling cycles.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	// ExtensionPointDuration is the time spent in the extension points
+	// of the plugin for pods with claims. The driver label is the driver
+	// of the claims of the pod if all of them are known to belong to the
+	// same driver, "multiple" if they belong to different ones, and empty
+	// otherwise, for example while a claim is not allocated yet.
+	ExtensionPointDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Subsystem: DRASchedulerSubsystem,
+			Name:      "extension_point_duration_seconds",
+			Help:      "Time spent in an extension point of the DynamicResources plugin for pods with ResourceClaims, by extension point and driver.",
+			// Start with 10µs with the last bucket being [~160ms, Inf).
+			Buckets:        metrics.ExponentialBuckets(0.00001, 2, 15),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"extension_point", "driver"},
+	)
+
+	// AllocatedSelectorMismatches counts how often a device which is
+	// allocated for a claim stopped matching the selectors of the claim
+	// because the driver changed its attributes.
+	AllocatedSelectorMismatches = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      DRASchedulerSubsystem,
+			Name:           "allocated_selector_mismatches_total",
+			Help:           "Number of times that an allocated device no longer matched the selectors of its ResourceClaim after a ResourceSlice update, by driver.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"driver"},
+	)
+
+	registerMetrics sync.Once
+)
+
+// RegisterMetrics registers the metrics of the DynamicResources plugin.
+// It may be called more than once.
+func RegisterMetrics() {
+	registerMetrics.Do(func() {
+		legacyregistry.MustRegister(MissingSlices)
+		legacyregistry.MustRegister(SkippedNoOpEvents)
+		legacyregistry.MustRegister(ResourceSlicesUnavailable)
+		legacyregistry.MustRegister(AllocatedDevices)
+		legacyregistry.MustRegister(ExtensionPointDuration)
+		legacyregistry.MustRegister(AllocatedSelectorMismatches)
+	})
+}
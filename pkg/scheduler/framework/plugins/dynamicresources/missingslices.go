@@ -0,0 +1,279 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/dynamicresources/metrics"
+	"k8s.io/utils/clock"
+)
+
+const (
+	// ReasonMissingResourceSlices is used for the warning event that
+	// gets emitted for a node when a pod could not be scheduled onto it
+	// and a driver which published ResourceSlices for the node before
+	// no longer does.
+	ReasonMissingResourceSlices = "MissingResourceSlices"
+
+	// missingSlicesWarningInterval is the minimum time between two
+	// warning events for the same node and driver.
+	missingSlicesWarningInterval = 10 * time.Minute
+
+	// maxSliceTrackerEntries limits the number of node/driver pairs
+	// that the sliceTracker remembers.
+	maxSliceTrackerEntries = 10000
+)
+
+type nodeDriver struct {
+	nodeName, driver string
+}
+
+type sliceTrackerEntry struct {
+	// numSlices is the number of ResourceSlices which currently exist.
+	numSlices int
+	// lastSeen is when a ResourceSlice was last added, updated or removed.
+	lastSeen time.Time
+	// lastWarning is when the last warning event was emitted.
+	lastWarning time.Time
+	// reported is true while the metric is set.
+	reported bool
+}
+
+// sliceTracker remembers for which drivers ResourceSlices were published
+// for a node. When all slices of a driver are gone, the kubelet plugin of
+// the driver might have stopped working. Pods which need the devices of
+// that driver then avoid the node without telling anyone, so Filter
+// warns about it.
+type sliceTracker struct {
+	mutex   sync.Mutex
+	clock   clock.PassiveClock
+	entries map[nodeDriver]*sliceTrackerEntry
+}
+
+// resourceEventHandler returns a handler for ResourceSlice events.
+func (t *sliceTracker) resourceEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			t.update(nil, sliceFromObj(obj))
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			t.update(sliceFromObj(oldObj), sliceFromObj(newObj))
+		},
+		DeleteFunc: func(obj interface{}) {
+			t.update(sliceFromObj(obj), nil)
+		},
+	}
+}
+
+// nodeEventHandler returns a handler for Node events which forgets
+// about deleted nodes.
+func (t *sliceTracker) nodeEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if node, ok := obj.(*v1.Node); ok {
+				t.forgetNode(node.Name)
+			}
+		},
+	}
+}
+
+func sliceFromObj(obj interface{}) *resourceapi.ResourceSlice {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	slice, _ := obj.(*resourceapi.ResourceSlice)
+	return slice
+}
+
+// update handles one ResourceSlice event. Slices which are not local
+// to a single node are ignored.
+func (t *sliceTracker) update(oldSlice, newSlice *resourceapi.ResourceSlice) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.clock.Now()
+	if oldSlice != nil && oldSlice.Spec.NodeName != "" {
+		if entry := t.entries[nodeDriver{nodeName: oldSlice.Spec.NodeName, driver: oldSlice.Spec.Driver}]; entry != nil && entry.numSlices > 0 {
+			entry.numSlices--
+			entry.lastSeen = now
+		}
+	}
+	if newSlice != nil && newSlice.Spec.NodeName != "" {
+		key := nodeDriver{nodeName: newSlice.Spec.NodeName, driver: newSlice.Spec.Driver}
+		entry := t.entries[key]
+		if entry == nil {
+			if !t.makeRoom() {
+				return
+			}
+			entry = &sliceTrackerEntry{}
+			if t.entries == nil {
+				t.entries = make(map[nodeDriver]*sliceTrackerEntry)
+			}
+			t.entries[key] = entry
+		}
+		entry.numSlices++
+		entry.lastSeen = now
+		if entry.reported {
+			metrics.MissingSlices.DeleteLabelValues(key.driver, key.nodeName)
+			entry.reported = false
+		}
+	}
+}
+
+// makeRoom ensures that there is space for one more entry by removing the
+// entry without slices which was seen least recently. It returns false if
+// all entries are in use. Must be called while holding the mutex.
+func (t *sliceTracker) makeRoom() bool {
+	if len(t.entries) < maxSliceTrackerEntries {
+		return true
+	}
+	var oldestKey *nodeDriver
+	var oldest *sliceTrackerEntry
+	for key, entry := range t.entries {
+		if entry.numSlices == 0 && (oldest == nil || entry.lastSeen.Before(oldest.lastSeen)) {
+			key := key
+			oldestKey, oldest = &key, entry
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+	t.remove(*oldestKey)
+	return true
+}
+
+// forgetNode removes all entries for the node.
+func (t *sliceTracker) forgetNode(nodeName string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for key := range t.entries {
+		if key.nodeName == nodeName {
+			t.remove(key)
+		}
+	}
+}
+
+// remove must be called while holding the mutex.
+func (t *sliceTracker) remove(key nodeDriver) {
+	if t.entries[key].reported {
+		metrics.MissingSlices.DeleteLabelValues(key.driver, key.nodeName)
+	}
+	delete(t.entries, key)
+}
+
+// missingDrivers returns those drivers accepted by the filter which
+// published slices for the node before and have none now, sorted by name,
+// and records that as metric. The second result contains those drivers
+// among them for which a warning is due. Warnings for the same node and
+// driver are at least missingSlicesWarningInterval apart.
+func (t *sliceTracker) missingDrivers(nodeName string, filter func(driver string) bool) (missing, warn []string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.clock.Now()
+	for key, entry := range t.entries {
+		if key.nodeName != nodeName || entry.numSlices > 0 || !filter(key.driver) {
+			continue
+		}
+		missing = append(missing, key.driver)
+		if !entry.reported {
+			metrics.MissingSlices.WithLabelValues(key.driver, key.nodeName).Set(1)
+			entry.reported = true
+		}
+		if entry.lastWarning.IsZero() || now.Sub(entry.lastWarning) >= missingSlicesWarningInterval {
+			entry.lastWarning = now
+			warn = append(warn, key.driver)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(warn)
+	return missing, warn
+}
+
+// celDriver matches the driver name in CEL expressions like
+// device.driver == "gpu.example.com".
+var celDriver = regexp.MustCompile(`device\.driver\s*==\s*["']([^"']+)["']`)
+
+// pendingDrivers returns a filter for the drivers which might provide
+// devices for those of the claims that still need to be allocated.
+// Selectors of a request and its class which compare device.driver with
+// a name limit the request to the drivers with those names. Without
+// such a comparison, all drivers are candidates.
+func (pl *dynamicResources) pendingDrivers(claims []*resourceapi.ResourceClaim) func(driver string) bool {
+	drivers := sets.New[string]()
+	anyDriver := false
+	for _, claim := range claims {
+		if claim.Status.Allocation != nil {
+			continue
+		}
+		for _, request := range claim.Spec.Devices.Requests {
+			referenced := sets.New[string]()
+			addDrivers := func(selectors []resourceapi.DeviceSelector) {
+				for _, selector := range selectors {
+					if selector.CEL == nil {
+						continue
+					}
+					for _, match := range celDriver.FindAllStringSubmatch(selector.CEL.Expression, -1) {
+						referenced.Insert(match[1])
+					}
+				}
+			}
+			addDrivers(request.Selectors)
+			if class, err := pl.classLister.Get(request.DeviceClassName); err == nil {
+				addDrivers(class.Spec.Selectors)
+			}
+			if referenced.Len() == 0 {
+				anyDriver = true
+			}
+			drivers = drivers.Union(referenced)
+		}
+	}
+	return func(driver string) bool {
+		return anyDriver || drivers.Has(driver)
+	}
+}
+
+// checkMissingSlices gets called by Filter when it rejects the node.
+// Only drivers which matter for the pod are checked.
+func (pl *dynamicResources) checkMissingSlices(logger klog.Logger, state *stateData, pod *v1.Pod, node *v1.Node) {
+	missing, warn := pl.sliceTracker.missingDrivers(node.Name, pl.pendingDrivers(state.claims))
+	if len(missing) == 0 {
+		return
+	}
+	logger.V(5).Info("ResourceSlices of some drivers are missing for node", "pod", klog.KObj(pod), "node", klog.KObj(node), "drivers", missing)
+	recorder := pl.eventRecorder
+	if recorder == nil {
+		return
+	}
+	for _, driver := range warn {
+		recorder.Eventf(node, nil, v1.EventTypeWarning, ReasonMissingResourceSlices, "Filter",
+			"ResourceSlices of driver %s are no longer published for the node, pods cannot use its devices", driver)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+)
+
+// namespacePolicy determines in which namespaces pods may use
+// ResourceClaims. The zero value allows all namespaces.
+type namespacePolicy struct {
+	// allowed is nil if all namespaces are allowed.
+	allowed sets.Set[string]
+	// selector is nil if namespace labels don't matter.
+	selector labels.Selector
+}
+
+func newNamespacePolicy(args *config.DynamicResourcesArgs) (namespacePolicy, error) {
+	var policy namespacePolicy
+	if len(args.AllowedNamespaces) > 0 {
+		policy.allowed = sets.New(args.AllowedNamespaces...)
+	}
+	if args.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(args.NamespaceSelector)
+		if err != nil {
+			return namespacePolicy{}, fmt.Errorf("namespace selector: %w", err)
+		}
+		policy.selector = selector
+	}
+	return policy, nil
+}
+
+// isNamespaceAllowed checks the namespace against the configured policy.
+// A namespace which does not exist has no labels and therefore only
+// matches when there is no selector.
+func (pl *dynamicResources) isNamespaceAllowed(namespace string) (bool, error) {
+	if pl.namespacePolicy.allowed != nil && !pl.namespacePolicy.allowed.Has(namespace) {
+		return false, nil
+	}
+	if pl.namespacePolicy.selector == nil {
+		return true, nil
+	}
+	ns, err := pl.namespaceLister.Get(namespace)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("look up namespace %s: %w", namespace, err)
+	}
+	return pl.namespacePolicy.selector.Matches(labels.Set(ns.Labels)), nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// AnnotationNodePreferences may be set on a DeviceClass or a
+	// ResourceClaim by a driver or an admin. The value is a JSON list of
+	// NodePreference entries. Nodes which match a preference get its
+	// weight added to their score. Preferences are never required,
+	// nodes which don't match remain feasible.
+	AnnotationNodePreferences = "resource.kubernetes.io/node-preferences"
+
+	// maxNodePreferenceWeight is the maximum weight of a single
+	// preference.
+	maxNodePreferenceWeight = 100
+
+	// maxInvalidPreferencesLogged limits the memory used for
+	// remembering which invalid preferences were already logged.
+	maxInvalidPreferencesLogged = 1000
+)
+
+// NodePreference is one entry in AnnotationNodePreferences.
+type NodePreference struct {
+	// LabelKey is the key of a node label.
+	LabelKey string `json:"labelKey"`
+
+	// LabelValues are the values of the label which match. If empty,
+	// all nodes which have the label match.
+	LabelValues []string `json:"labelValues,omitempty"`
+
+	// Weight gets added to the score of matching nodes.
+	// Must be in the range 1 to 100.
+	Weight int32 `json:"weight"`
+}
+
+// matches returns true if the node has the label with one of the values.
+func (p NodePreference) matches(node *v1.Node) bool {
+	value, ok := node.Labels[p.LabelKey]
+	if !ok {
+		return false
+	}
+	return len(p.LabelValues) == 0 || slices.Contains(p.LabelValues, value)
+}
+
+// parseNodePreferences parses and validates the annotation value.
+func parseNodePreferences(value string) ([]NodePreference, error) {
+	decoder := json.NewDecoder(bytes.NewBufferString(value))
+	decoder.DisallowUnknownFields()
+	var preferences []NodePreference
+	if err := decoder.Decode(&preferences); err != nil {
+		return nil, err
+	}
+	var errs []error
+	for i, preference := range preferences {
+		for _, msg := range validation.IsQualifiedName(preference.LabelKey) {
+			errs = append(errs, fmt.Errorf("entry #%d: labelKey: %s", i, msg))
+		}
+		if preference.Weight < 1 || preference.Weight > maxNodePreferenceWeight {
+			errs = append(errs, fmt.Errorf("entry #%d: weight must be in the range 1 to %d", i, maxNodePreferenceWeight))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return preferences, nil
+}
+
+// invalidPreferencesLog remembers which objects had invalid node preferences,
+// so that each invalid annotation only gets logged once. A modified object
+// gets logged again.
+type invalidPreferencesLog struct {
+	mutex  sync.Mutex
+	logged sets.Set[string]
+}
+
+// firstTime returns true if the object was not seen before.
+func (l *invalidPreferencesLog) firstTime(uid types.UID, resourceVersion string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	key := string(uid) + "/" + resourceVersion
+	if l.logged.Has(key) {
+		return false
+	}
+	if l.logged == nil || l.logged.Len() >= maxInvalidPreferencesLogged {
+		// Starting over may log some objects twice, which is okay.
+		l.logged = sets.New[string]()
+	}
+	l.logged.Insert(key)
+	return true
+}
+
+// nodePreferences collects the node preferences of the claims and their
+// device classes. Invalid preferences are ignored.
+func (pl *dynamicResources) nodePreferences(logger klog.Logger, claims []*resourceapi.ResourceClaim) []NodePreference {
+	var preferences []NodePreference
+	add := func(kind string, obj klog.KMetadata, uid types.UID, resourceVersion string, annotations map[string]string) {
+		value, ok := annotations[AnnotationNodePreferences]
+		if !ok {
+			return
+		}
+		parsed, err := parseNodePreferences(value)
+		if err != nil {
+			if pl.invalidPreferencesLog.firstTime(uid, resourceVersion) {
+				logger.Info("Warning: ignoring invalid node preferences", kind, klog.KObj(obj), "annotation", AnnotationNodePreferences, "err", err)
+			}
+			return
+		}
+		preferences = append(preferences, parsed...)
+	}
+	classNames := sets.New[string]()
+	for _, claim := range claims {
+		add("resourceclaim", claim, claim.UID, claim.ResourceVersion, claim.Annotations)
+		for _, request := range claim.Spec.Devices.Requests {
+			classNames.Insert(request.DeviceClassName)
+		}
+	}
+	for _, className := range sets.List(classNames) {
+		class, err := pl.classLister.Get(className)
+		if err != nil {
+			// Not a reason to fail scheduling. If the class is
+			// needed, PreFilter has checked that it exists.
+			continue
+		}
+		add("deviceclass", class, class.UID, class.ResourceVersion, class.Annotations)
+	}
+	return preferences
+}
+
+// nodePreferenceScore returns the sum of the weights of all preferences
+// which match the node.
+func nodePreferenceScore(preferences []NodePreference, node *v1.Node) int64 {
+	var score int64
+	for _, preference := range preferences {
+		if preference.matches(node) {
+			score += int64(preference.Weight)
+		}
+	}
+	return score
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	v1helper "k8s.io/kubernetes/pkg/apis/core/v1/helper"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/noderesources"
+)
+
+// withInsufficientNodeResources extends the last reason of a status which
+// reports that devices are exhausted with the extended resources and huge
+// pages that the node also doesn't have enough of for the pod, as in
+// "cannot allocate all claims; also insufficient vendor.com/counter".
+//
+// The framework stops at the first Filter plugin which rejects a node, so
+// the NodeResourcesFit plugin never gets to report this when it runs after
+// this plugin. When it runs first, as in the default profile, nodes without
+// enough of those resources never reach this plugin and the status is
+// returned unchanged.
+func withInsufficientNodeResources(status *framework.Status, pod *v1.Pod, nodeInfo *framework.NodeInfo) *framework.Status {
+	var names []string
+	for _, insufficient := range noderesources.Fits(pod, nodeInfo) {
+		if v1helper.IsExtendedResourceName(insufficient.ResourceName) || v1helper.IsHugePageResourceName(insufficient.ResourceName) {
+			names = append(names, string(insufficient.ResourceName))
+		}
+	}
+	if len(names) == 0 {
+		return status
+	}
+	slices.Sort(names)
+	reasons := slices.Clone(status.Reasons())
+	reasons[len(reasons)-1] += "; also insufficient " + strings.Join(names, ", ")
+	return framework.NewStatus(status.Code(), reasons...)
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// nominatedNodeWithAllocation returns the nominated node of the pod if
+// Filter found devices for the pending claims there, otherwise the
+// empty string. Preemption nominates a node after evicting pods which
+// used the devices, so the pod should use those devices instead of
+// taking devices on some other node.
+//
+// Filter does not need to handle the nominated node: the scheduler
+// already evaluates it before all other nodes. Must be called after
+// PreScore dropped the allocations of nodes which are not candidates.
+func nominatedNodeWithAllocation(state *stateData, pod *v1.Pod) string {
+	nodeName := pod.Status.NominatedNodeName
+	if nodeName == "" {
+		return ""
+	}
+	if allocations := state.nodeAllocations[nodeName]; len(allocations) == 0 {
+		return ""
+	}
+	return nodeName
+}
+
+// withNominatedNodeScore gives the nominated node a higher score than all
+// other nodes. Scores of other nodes remain unchanged.
+func withNominatedNodeScore(scores map[string]int64, nominatedNodeName string) {
+	var maxScore int64
+	for nodeName, score := range scores {
+		if nodeName != nominatedNodeName && score > maxScore {
+			maxScore = score
+		}
+	}
+	scores[nominatedNodeName] = maxScore + 1
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"fmt"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// AnnotationPinnedNode may be set by an admin on a ResourceClaim
+	// with structured parameters before it gets allocated. The value is
+	// the name of a node. The claim then only gets allocated with
+	// devices which are available on that node, which also means that
+	// pods using the claim only run there. This is useful for
+	// reserving devices on a certain node, for example for
+	// benchmarking.
+	AnnotationPinnedNode = "resource.kubernetes.io/pinned-node"
+)
+
+// pinnedNode returns the name of the node to which the claim is pinned,
+// the empty string if it is not pinned. An unknown node is reported
+// with a reason why the claim cannot be allocated.
+func (pl *dynamicResources) pinnedNode(claim *resourceapi.ResourceClaim) (nodeName, reason string, err error) {
+	nodeName, ok := claim.Annotations[AnnotationPinnedNode]
+	if !ok {
+		return "", "", nil
+	}
+	if _, err := pl.nodeLister.Get(nodeName); err != nil {
+		if apierrors.IsNotFound(err) {
+			// A node event will try again.
+			return "", fmt.Sprintf("resourceclaim %s pinned to node %s which does not exist", claim.Name, nodeName), nil
+		}
+		return "", "", fmt.Errorf("look up pinned node: %w", err)
+	}
+	return nodeName, "", nil
+}
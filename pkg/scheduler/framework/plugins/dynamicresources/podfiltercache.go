@@ -0,0 +1,246 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/dynamic-resource-allocation/structured"
+)
+
+// maxPodFilterCacheEntries limits the memory used by podFilterCache.
+// All entries get dropped when the limit is reached.
+const maxPodFilterCacheEntries = 10000
+
+// podFilterCache remembers the result of the allocator in Filter across
+// scheduling cycles. A pod which gets scheduled again, for example
+// because some unrelated event moved it back into the active queue,
+// then does not need to evaluate the CEL selectors again for nodes where
+// nothing changed.
+//
+// In contrast to stateData.filterCache, the cached results become stale
+// whenever some ResourceSlice or ResourceClaim changes. Changes of the
+// claims of the pod and their device classes are detected through their
+// ResourceVersion, changes of the pod and the node by comparing what the
+// allocator uses from them.
+type podFilterCache struct {
+	mutex sync.Mutex
+
+	// generation gets incremented for each reset. A result which was
+	// computed while a reset happened is not stored because it might
+	// be based on out-dated slices or claims.
+	generation int64
+	entries    map[podFilterCacheKey]podFilterCacheEntry
+
+	// maintenanceSlices are the names of the ResourceSlices with a
+	// maintenance window. Whether their devices are usable depends
+	// on the time, so results are not cached while there are any.
+	maintenanceSlices sets.Set[string]
+}
+
+type podFilterCacheKey struct {
+	podUID   types.UID
+	nodeName string
+}
+
+type podFilterCacheEntry struct {
+	generation int64
+	// inputs is everything besides slices and claims which the
+	// result depends upon, see podFilterInputs.
+	inputs string
+	result filterCacheEntry
+}
+
+// reset forgets all cached results.
+func (c *podFilterCache) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	c.entries = nil
+}
+
+// sliceChanged resets the cache and tracks maintenance windows.
+func (c *podFilterCache) sliceChanged(obj interface{}, deleted bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	slice, ok := obj.(*resourceapi.ResourceSlice)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	c.entries = nil
+	if !ok {
+		return
+	}
+	if _, inMaintenance := slice.Annotations[AnnotationResourceSliceMaintenanceWindow]; inMaintenance && !deleted {
+		if c.maintenanceSlices == nil {
+			c.maintenanceSlices = sets.New[string]()
+		}
+		c.maintenanceSlices.Insert(slice.Name)
+	} else {
+		c.maintenanceSlices.Delete(slice.Name)
+	}
+}
+
+// sliceEventHandler returns a handler which resets the cache
+// for all ResourceSlice events.
+func (c *podFilterCache) sliceEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.sliceChanged(obj, false) },
+		UpdateFunc: func(_, obj interface{}) { c.sliceChanged(obj, false) },
+		DeleteFunc: func(obj interface{}) { c.sliceChanged(obj, true) },
+	}
+}
+
+// claimEventHandler returns a handler which resets the cache for all
+// ResourceClaim events because the devices allocated for other pods
+// are not available.
+func (c *podFilterCache) claimEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(_ interface{}) { c.reset() },
+		UpdateFunc: func(_, _ interface{}) { c.reset() },
+		DeleteFunc: func(_ interface{}) { c.reset() },
+	}
+}
+
+// lookup returns a cached result and the current generation, which must
+// be passed to store.
+func (c *podFilterCache) lookup(key podFilterCacheKey, inputs string) (filterCacheEntry, int64, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.generation != c.generation || entry.inputs != inputs || len(c.maintenanceSlices) > 0 {
+		return filterCacheEntry{}, c.generation, false
+	}
+	return entry.result, c.generation, true
+}
+
+// store caches the result unless the cache was reset since lookup.
+func (c *podFilterCache) store(key podFilterCacheKey, inputs string, generation int64, result filterCacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.generation != generation || len(c.maintenanceSlices) > 0 {
+		return
+	}
+	if c.entries == nil || len(c.entries) >= maxPodFilterCacheEntries {
+		c.entries = make(map[podFilterCacheKey]podFilterCacheEntry)
+	}
+	c.entries[key] = podFilterCacheEntry{generation: generation, inputs: inputs, result: result}
+}
+
+// usePodFilterCache returns true if results of the allocator may be
+// reused in later scheduling cycles. This is not the case when slices
+// become stale over time or when the scheduler provides hints which
+// may differ between cycles.
+func (pl *dynamicResources) usePodFilterCache(state *stateData) bool {
+	return pl.sliceMaxAge == 0 && state.allocator.Hints() == nil
+}
+
+// podFilterInputs returns the inputs of the allocator which are not
+// covered by the generation of podFilterCache: the in-flight allocations,
+// the labels, tolerations and device anti-affinity of the pod, the node,
+// the claims which need to be allocated and their device classes. A
+// class which cannot be retrieved is recorded as missing, the allocator
+// then reports the error. Everything else that the allocator reads is
+// the same for all pods and does not change while the plugin runs.
+//
+// The result is compared as a whole, so two different sets of inputs
+// never share a cached result.
+func (pl *dynamicResources) podFilterInputs(state *stateData, pod *v1.Pod, node *v1.Node) string {
+	var inputs strings.Builder
+	write := func(value string) {
+		inputs.WriteString(value)
+		inputs.WriteByte(0)
+	}
+	// Separates lists from what follows.
+	end := func() {
+		inputs.WriteByte(1)
+	}
+
+	var inFlight []string
+	pl.inFlightAllocations.forEach(func(allocation *inFlightAllocation) bool {
+		var devices []string
+		if allocation.claim.Status.Allocation != nil {
+			for _, result := range allocation.claim.Status.Allocation.Devices.Results {
+				devices = append(devices, structured.DeviceID{Driver: result.Driver, Pool: result.Pool, Device: result.Device}.String())
+			}
+		}
+		inFlight = append(inFlight, string(allocation.claim.UID)+"="+strings.Join(devices, ","))
+		return true
+	})
+	sort.Strings(inFlight)
+	for _, allocation := range inFlight {
+		write(allocation)
+	}
+	end()
+
+	allocator := state.allocator
+	writeLabels := func(labels map[string]string) {
+		for _, key := range sets.List(sets.KeySet(labels)) {
+			write(key)
+			write(labels[key])
+		}
+		end()
+	}
+	writeLabels(allocator.PodLabels())
+	for _, toleration := range allocator.Tolerations() {
+		write(fmt.Sprintf("%s/%s/%s/%s", toleration.Key, toleration.Operator, toleration.Value, toleration.Effect))
+	}
+	end()
+	if antiAffinity := allocator.AntiAffinity(); antiAffinity != nil {
+		write(string(antiAffinity.Attribute))
+		for _, device := range antiAffinity.Devices {
+			write(device.String())
+		}
+	}
+	end()
+
+	// Node selectors only check labels and the name, which is part of
+	// the key. Device scorers get the entire node.
+	if len(pl.deviceScorers) > 0 {
+		write(node.ResourceVersion)
+	} else {
+		writeLabels(node.Labels)
+	}
+
+	for _, claim := range allocator.ClaimsToAllocate() {
+		write(string(claim.UID))
+		write(claim.ResourceVersion)
+		for _, request := range claim.Spec.Devices.Requests {
+			write(request.DeviceClassName)
+			if class, err := pl.classLister.Get(request.DeviceClassName); err == nil {
+				write(class.ResourceVersion)
+			} else {
+				write("")
+			}
+		}
+	}
+	return inputs.String()
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/feature"
+)
+
+// QuotaRequest describes the devices of one class which Reserve is about
+// to allocate for the claims of a pod.
+type QuotaRequest struct {
+	// Namespace of the pod and its claims.
+	Namespace string
+
+	// ClassName is the name of the DeviceClass.
+	ClassName string
+
+	// Count is the number of devices.
+	Count int
+}
+
+// QuotaChecker gets consulted by Reserve before it commits to allocating
+// devices for a pod. Reserve calls it once per device class, in the order
+// of the class names.
+type QuotaChecker interface {
+	// Reserve returns true if the devices may be allocated. When it
+	// returns false, the reason is shown to the user as the reason why
+	// the pod is unschedulable. A reservation which was granted remains
+	// in effect until Unreserve is called for it or the allocation gets
+	// written by PreBind.
+	Reserve(ctx context.Context, pod *v1.Pod, request QuotaRequest) (allowed bool, reason string, err error)
+
+	// Unreserve releases a reservation which was granted earlier.
+	Unreserve(ctx context.Context, pod *v1.Pod, request QuotaRequest)
+}
+
+// NewWithQuotaChecker returns a plugin factory which is like New, but
+// uses the given checker instead of the one configured through the
+// plugin arguments.
+func NewWithQuotaChecker(checker QuotaChecker) func(context.Context, runtime.Object, framework.Handle, feature.Features) (framework.Plugin, error) {
+	return func(ctx context.Context, plArgs runtime.Object, fh framework.Handle, fts feature.Features) (framework.Plugin, error) {
+		pl, err := New(ctx, plArgs, fh, fts)
+		if err != nil {
+			return nil, err
+		}
+		if pl, ok := pl.(*dynamicResources); ok && pl.enabled {
+			pl.quotaChecker = checker
+		}
+		return pl, nil
+	}
+}
+
+type quotaKey struct {
+	namespace, className string
+}
+
+// argsQuotaChecker enforces the DeviceQuotas from the plugin arguments.
+// Quotas without a namespace are budgets for the entire cluster.
+//
+// It doesn't need to track reservations itself: allocations which
+// are in flight are part of the allocated claims, so usage is simply
+// recomputed for each request and Unreserve has nothing to release.
+type argsQuotaChecker struct {
+	claimLister *claimListerForAssumeCache
+	maxDevices  map[quotaKey]int
+}
+
+var _ QuotaChecker = &argsQuotaChecker{}
+
+// newArgsQuotaChecker returns nil if there are no quotas.
+func newArgsQuotaChecker(quotas []config.DeviceQuota, claimLister *claimListerForAssumeCache) *argsQuotaChecker {
+	if len(quotas) == 0 {
+		return nil
+	}
+	maxDevices := make(map[quotaKey]int, len(quotas))
+	for _, quota := range quotas {
+		maxDevices[quotaKey{namespace: quota.Namespace, className: quota.DeviceClassName}] = int(quota.MaxDevices)
+	}
+	return &argsQuotaChecker{claimLister: claimLister, maxDevices: maxDevices}
+}
+
+func (c *argsQuotaChecker) Reserve(ctx context.Context, pod *v1.Pod, request QuotaRequest) (bool, string, error) {
+	maxDevices, limited := c.maxDevices[quotaKey{namespace: request.Namespace, className: request.ClassName}]
+	budget, budgeted := c.maxDevices[quotaKey{className: request.ClassName}]
+	if !limited && !budgeted {
+		return true, "", nil
+	}
+	claims, err := c.claimLister.ListAllAllocated()
+	if err != nil {
+		return false, "", fmt.Errorf("list allocated claims: %w", err)
+	}
+	used, usedInNamespace := 0, 0
+	for _, claim := range claims {
+		count := countDevices(claim, claim.Status.Allocation)[request.ClassName]
+		used += count
+		if claim.Namespace == request.Namespace {
+			usedInNamespace += count
+		}
+	}
+	if limited && usedInNamespace+request.Count > maxDevices {
+		return false, fmt.Sprintf("device quota exceeded: %d device(s) of class %s requested, %d of %d allocated in namespace %s", request.Count, request.ClassName, usedInNamespace, maxDevices, request.Namespace), nil
+	}
+	if budgeted && used+request.Count > budget {
+		return false, fmt.Sprintf("class %s allocation budget exhausted", request.ClassName), nil
+	}
+	return true, "", nil
+}
+
+func (c *argsQuotaChecker) Unreserve(ctx context.Context, pod *v1.Pod, request QuotaRequest) {
+}
+
+// countDevices returns the number of allocated devices per device class.
+// The class is the one of the request for which a device was allocated.
+func countDevices(claim *resourceapi.ResourceClaim, allocation *resourceapi.AllocationResult) map[string]int {
+	counts := make(map[string]int)
+	for _, result := range allocation.Devices.Results {
+		for _, request := range claim.Spec.Devices.Requests {
+			if request.Name == result.Request {
+				counts[request.DeviceClassName]++
+				break
+			}
+		}
+	}
+	return counts
+}
+
+// quotaRequests returns the devices which are about to get allocated,
+// summed up per device class and sorted by class name.
+func quotaRequests(namespace string, claims []*resourceapi.ResourceClaim, allocations []*resourceapi.AllocationResult) []QuotaRequest {
+	counts := make(map[string]int)
+	for i, claim := range claims {
+		for className, count := range countDevices(claim, allocations[i]) {
+			counts[className] += count
+		}
+	}
+	requests := make([]QuotaRequest, 0, len(counts))
+	for className, count := range counts {
+		requests = append(requests, QuotaRequest{Namespace: namespace, ClassName: className, Count: count})
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ClassName < requests[j].ClassName })
+	return requests
+}
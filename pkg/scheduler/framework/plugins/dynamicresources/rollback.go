@@ -0,0 +1,115 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/dynamic-resource-allocation/resourceclaim"
+	"k8s.io/klog/v2"
+)
+
+// rollbackClaims gets called by PreBind when storing the allocation or
+// reservation of a claim failed. It undoes what PreBind already wrote for
+// the claims with the given indices in the same scheduling cycle. The next
+// attempt might pick a different node, so keeping allocations for this
+// node would be wrong.
+//
+// Failures are only logged. The changes which were undone are no longer
+// recorded, Unreserve then tries again to undo the rest.
+func (pl *dynamicResources) rollbackClaims(ctx context.Context, state *stateData, pod *v1.Pod, indices []int) {
+	logger := klog.FromContext(ctx)
+	for _, index := range indices {
+		claim, err := pl.rollbackClaim(ctx, state, index, pod)
+		if err != nil {
+			logger.Error(err, "Rolling back claim failed", "pod", klog.KObj(pod), "resourceclaim", klog.KObj(state.claims[index]))
+			continue
+		}
+		state.claims[index] = claim
+		state.forgetChange(index, reservationAdded)
+		if claim.Status.Allocation == nil {
+			state.forgetChange(index, allocationWritten)
+		}
+	}
+}
+
+// rollbackClaim removes the reservation for the pod from the claim and,
+// if the scheduler allocated the claim in this cycle, also the allocation.
+// Another consumer might have started to use the newly allocated claim in
+// the meantime. The allocation is kept in that case.
+func (pl *dynamicResources) rollbackClaim(ctx context.Context, state *stateData, index int, pod *v1.Pod) (*resourceapi.ResourceClaim, error) {
+	logger := klog.FromContext(ctx)
+	claim := state.claims[index]
+	allocation := state.informationsForClaim[index].allocation
+
+	refreshClaim := false
+	err := retry.RetryOnConflict(claimUpdateBackoff, func() error {
+		if refreshClaim {
+			updatedClaim, err := pl.clientset.ResourceV1alpha3().ResourceClaims(claim.Namespace).Get(ctx, claim.Name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("get updated claim %s after conflict: %w", klog.KObj(claim), err)
+			}
+			logger.V(5).Info("retrying rollback after conflict", "claim", klog.KObj(claim))
+			claim = updatedClaim
+		} else {
+			// All future retries must get a new claim first.
+			refreshClaim = true
+		}
+
+		if !resourceclaim.IsReservedForPod(pod, claim) {
+			// Someone else already removed the reservation.
+			return nil
+		}
+		status := claim.Status.DeepCopy()
+		status.ReservedFor = slices.DeleteFunc(status.ReservedFor, func(consumer resourceapi.ResourceClaimConsumerReference) bool {
+			return consumer.UID == pod.UID
+		})
+		if allocation != nil && len(status.ReservedFor) == 0 {
+			status.Allocation = nil
+		}
+		updatedClaim, err := pl.writeClaimStatus(ctx, claim, status)
+		if err != nil {
+			return fmt.Errorf("roll back claim %s: %w", klog.KObj(claim), err)
+		}
+		claim = updatedClaim
+		return nil
+	})
+	if apierrors.IsNotFound(err) {
+		// Nothing left to roll back.
+		return state.claims[index], nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if allocation != nil && claim.Status.Allocation == nil {
+		// bindClaim stored the allocated claim in the assume cache.
+		// This can fail, but only for reasons that are okay (concurrent delete or update).
+		if err := pl.claimAssumeCache.Assume(claim); err != nil {
+			logger.V(5).Info("Claim not stored in assume cache", "err", err)
+		}
+	}
+	logger.V(5).Info("rolled back", "pod", klog.KObj(pod), "resourceclaim", klog.Format(claim))
+	return claim, nil
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// schedulingContextDeleteBaseDelay and schedulingContextDeleteMaxDelay
+	// define the exponential backoff between attempts to delete a
+	// PodSchedulingContext after an attempt failed.
+	schedulingContextDeleteBaseDelay = 500 * time.Millisecond
+	schedulingContextDeleteMaxDelay  = 30 * time.Second
+
+	// maxSchedulingContextDeleteRetries is the number of retries before
+	// giving up. The object then has to be removed by the garbage
+	// collector once the pod is gone.
+	maxSchedulingContextDeleteRetries = 5
+
+	// defaultMaxPendingSchedulingContexts is the number of
+	// PodSchedulingContexts which may wait for deletion in the
+	// background.
+	defaultMaxPendingSchedulingContexts = 1000
+)
+
+// schedulingContextRef identifies a PodSchedulingContext which needs to be
+// deleted. podUID is the UID of the pod which the object was meant for.
+// Pods get recreated with the same name, so an object with that name
+// might belong to a newer pod by the time that the retry runs. uid is the
+// UID of the object if it was known in the scheduling cycle.
+type schedulingContextRef struct {
+	namespace, name string
+	podUID          types.UID
+	uid             types.UID
+}
+
+// newSchedulingContextRef returns the reference for the PodSchedulingContext
+// of the pod.
+func newSchedulingContextRef(pod *v1.Pod, state *stateData) schedulingContextRef {
+	ref := schedulingContextRef{namespace: pod.Namespace, name: pod.Name, podUID: pod.UID}
+	if schedulingCtx := state.podSchedulingState.schedulingCtx; schedulingCtx != nil {
+		ref.uid = schedulingCtx.UID
+	}
+	return ref
+}
+
+// pendingSchedulingContexts contains the PodSchedulingContexts which were
+// added to the queue and not done yet, including those which wait for a
+// retry. The queue itself does not count the latter.
+type pendingSchedulingContexts struct {
+	mutex sync.Mutex
+	refs  sets.Set[schedulingContextRef]
+}
+
+// add records the object as pending and returns true, unless there
+// already are max pending objects.
+func (p *pendingSchedulingContexts) add(ref schedulingContextRef, max int) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.refs.Has(ref) {
+		return true
+	}
+	if p.refs.Len() >= max {
+		return false
+	}
+	if p.refs == nil {
+		p.refs = sets.New[schedulingContextRef]()
+	}
+	p.refs.Insert(ref)
+	return true
+}
+
+// done removes the object.
+func (p *pendingSchedulingContexts) done(ref schedulingContextRef) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.refs.Delete(ref)
+}
+
+// len returns the number of pending objects.
+func (p *pendingSchedulingContexts) len() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.refs.Len()
+}
+
+func newSchedulingContextQueue() workqueue.TypedRateLimitingInterface[schedulingContextRef] {
+	return workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[schedulingContextRef](schedulingContextDeleteBaseDelay, schedulingContextDeleteMaxDelay),
+		workqueue.TypedRateLimitingQueueConfig[schedulingContextRef]{Name: "dra_scheduling_context_cleanup"},
+	)
+}
+
+// deleteSchedulingContexts deletes the objects queued by PostBind and
+// retries failed deletions. It returns when the context is canceled.
+func (pl *dynamicResources) deleteSchedulingContexts(ctx context.Context) {
+	logger := klog.FromContext(ctx)
+	logger = klog.LoggerWithName(logger, "schedulingcontexts")
+	ctx = klog.NewContext(ctx, logger)
+
+	go func() {
+		<-ctx.Done()
+		pl.schedulingContextQueue.ShutDown()
+	}()
+	for pl.deleteNextSchedulingContext(ctx) {
+	}
+}
+
+func (pl *dynamicResources) deleteNextSchedulingContext(ctx context.Context) bool {
+	ref, shutdown := pl.schedulingContextQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer pl.schedulingContextQueue.Done(ref)
+
+	logger := klog.FromContext(ctx)
+	err := pl.deleteSchedulingContext(ctx, ref)
+	switch {
+	case err == nil:
+		pl.schedulingContextQueue.Forget(ref)
+		pl.pendingSchedulingContexts.done(ref)
+	case pl.schedulingContextQueue.NumRequeues(ref) < maxSchedulingContextDeleteRetries:
+		logger.V(5).Info("Deleting PodSchedulingContext failed, will retry", "podSchedulingCtx", klog.KRef(ref.namespace, ref.name), "err", err)
+		pl.schedulingContextQueue.AddRateLimited(ref)
+	default:
+		logger.Error(err, "Deleting PodSchedulingContext failed, giving up", "podSchedulingCtx", klog.KRef(ref.namespace, ref.name))
+		pl.schedulingContextQueue.Forget(ref)
+		pl.pendingSchedulingContexts.done(ref)
+	}
+	return true
+}
+
+// deleteSchedulingContext deletes the object unless it is gone or belongs
+// to some other pod. Without the UID from the scheduling cycle, the object
+// is looked up in the informer cache. Objects which are not in the cache
+// have not been seen by the plugin, so they cannot be stale. The UID is
+// used as precondition, so an object which got recreated in the meantime
+// is left alone.
+func (pl *dynamicResources) deleteSchedulingContext(ctx context.Context, ref schedulingContextRef) error {
+	logger := klog.FromContext(ctx)
+	uid := ref.uid
+	if uid == "" {
+		if pl.podSchedulingContextLister == nil {
+			return nil
+		}
+		schedulingCtx, err := pl.podSchedulingContextLister.PodSchedulingContexts(ref.namespace).Get(ref.name)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if owner := metav1.GetControllerOf(schedulingCtx); owner != nil && owner.UID != ref.podUID {
+			logger.V(5).Info("PodSchedulingContext belongs to a different pod, not deleting it", "podSchedulingCtx", klog.KObj(schedulingCtx))
+			return nil
+		}
+		uid = schedulingCtx.UID
+	}
+	err := pl.clientset.ResourceV1alpha3().PodSchedulingContexts(ref.namespace).Delete(ctx, ref.name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &uid}})
+	if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+		// Gone or replaced in the meantime.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	logger.V(5).Info("PodSchedulingContext object deleted", "podSchedulingCtx", klog.KRef(ref.namespace, ref.name))
+	return nil
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/dynamic-resource-allocation/structured"
+	"k8s.io/klog/v2"
+)
+
+// AnnotationScoredAttributes may be set on a ResourceClaim. The value is a
+// JSON list of ScoredAttribute entries. Nodes where the devices chosen for
+// the claim have better values for those attributes get a higher score.
+// Like node preferences, this never makes a node infeasible.
+const AnnotationScoredAttributes = "resource.kubernetes.io/scored-attributes"
+
+// ScoreDirection determines which attribute values are better.
+type ScoreDirection string
+
+const (
+	// ScoreDirectionHigher prefers higher values. This is the default.
+	ScoreDirectionHigher ScoreDirection = "Higher"
+	// ScoreDirectionLower prefers lower values.
+	ScoreDirectionLower ScoreDirection = "Lower"
+)
+
+// ScoreSource determines which devices provide the attribute values.
+type ScoreSource string
+
+const (
+	// ScoreSourceAllocation uses the devices chosen for the claim.
+	// This is the default.
+	ScoreSourceAllocation ScoreSource = "Allocation"
+	// ScoreSourceNode uses all devices which are local to the node,
+	// regardless of whether they get allocated. This is useful for
+	// attributes which describe the node, for example a temperature
+	// which should be low to avoid hot spots.
+	ScoreSourceNode ScoreSource = "Node"
+)
+
+// ScoredAttribute is one entry in AnnotationScoredAttributes.
+type ScoredAttribute struct {
+	// Name is the fully qualified name of a device attribute
+	// (<domain>/<identifier>). Integer attributes are used as they are,
+	// boolean attributes count as 1 when true and 0 otherwise. Other
+	// types are ignored.
+	Name resourceapi.FullyQualifiedName `json:"name"`
+
+	// Weight is the maximum score which a node gets for this attribute.
+	// Must be in the range 1 to 100.
+	Weight int32 `json:"weight"`
+
+	// Direction is either Higher or Lower, empty means Higher.
+	Direction ScoreDirection `json:"direction,omitempty"`
+
+	// Source is either Allocation or Node, empty means Allocation.
+	// With Node, the value on a node is the maximum over all devices
+	// in the node-local ResourceSlices of that node.
+	Source ScoreSource `json:"source,omitempty"`
+}
+
+// parseScoredAttributes parses and validates the annotation value.
+func parseScoredAttributes(value string) ([]ScoredAttribute, error) {
+	decoder := json.NewDecoder(bytes.NewBufferString(value))
+	decoder.DisallowUnknownFields()
+	var attributes []ScoredAttribute
+	if err := decoder.Decode(&attributes); err != nil {
+		return nil, err
+	}
+	var errs []error
+	for i, attribute := range attributes {
+		domain, id, ok := strings.Cut(string(attribute.Name), "/")
+		if !ok {
+			errs = append(errs, fmt.Errorf("entry #%d: name: must be <domain>/<identifier>", i))
+		} else {
+			for _, msg := range validation.IsDNS1123Subdomain(domain) {
+				errs = append(errs, fmt.Errorf("entry #%d: name: %s", i, msg))
+			}
+			for _, msg := range validation.IsCIdentifier(id) {
+				errs = append(errs, fmt.Errorf("entry #%d: name: %s", i, msg))
+			}
+		}
+		if attribute.Weight < 1 || attribute.Weight > maxNodePreferenceWeight {
+			errs = append(errs, fmt.Errorf("entry #%d: weight must be in the range 1 to %d", i, maxNodePreferenceWeight))
+		}
+		switch attribute.Direction {
+		case "", ScoreDirectionHigher, ScoreDirectionLower:
+		default:
+			errs = append(errs, fmt.Errorf("entry #%d: direction must be %s or %s", i, ScoreDirectionHigher, ScoreDirectionLower))
+		}
+		switch attribute.Source {
+		case "", ScoreSourceAllocation, ScoreSourceNode:
+		default:
+			errs = append(errs, fmt.Errorf("entry #%d: source must be %s or %s", i, ScoreSourceAllocation, ScoreSourceNode))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return attributes, nil
+}
+
+// numericAttribute returns the value of the attribute as a number. A driver
+// may publish attributes of its own domain without the domain prefix.
+func numericAttribute(device *resourceapi.BasicDevice, driver string, name resourceapi.FullyQualifiedName) (float64, bool) {
+	attribute, ok := device.Attributes[resourceapi.QualifiedName(name)]
+	if domain, id, _ := strings.Cut(string(name), "/"); !ok && domain == driver {
+		attribute, ok = device.Attributes[resourceapi.QualifiedName(id)]
+	}
+	switch {
+	case !ok:
+		return 0, false
+	case attribute.IntValue != nil:
+		return float64(*attribute.IntValue), true
+	case attribute.BoolValue != nil:
+		if *attribute.BoolValue {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// scoredAttributesScores returns the scores for the scored attributes of the
+// claims which get allocated by the scheduler, nil if there are none. The
+// value of an attribute on a node is the sum over all devices that Filter
+// picked for the claim there. Those values get scaled so that the best node
+// gets the weight of the attribute and the worst node nothing. Nodes where
+// none of the devices have the attribute get nothing. For attributes with
+// ScoreSourceNode, the value is taken from the devices of the node instead.
+//
+// Must be called after PreScore dropped the allocations of nodes which are
+// not candidates.
+func (pl *dynamicResources) scoredAttributesScores(logger klog.Logger, state *stateData) (map[string]int64, error) {
+	if state.allocator == nil || len(state.nodeAllocations) == 0 {
+		return nil, nil
+	}
+	claims := state.allocator.ClaimsToAllocate()
+	attributesForClaim := make([][]ScoredAttribute, len(claims))
+	haveAttributes := false
+	for i, claim := range claims {
+		value, ok := claim.Annotations[AnnotationScoredAttributes]
+		if !ok {
+			continue
+		}
+		attributes, err := parseScoredAttributes(value)
+		if err != nil {
+			if pl.invalidPreferencesLog.firstTime(claim.UID, claim.ResourceVersion) {
+				logger.Info("Warning: ignoring invalid scored attributes", "resourceclaim", klog.KObj(claim), "annotation", AnnotationScoredAttributes, "err", err)
+			}
+			continue
+		}
+		attributesForClaim[i] = attributes
+		haveAttributes = haveAttributes || len(attributes) > 0
+	}
+	if !haveAttributes {
+		return nil, nil
+	}
+
+	slices, err := pl.sliceLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list resource slices: %w", err)
+	}
+	devices := make(map[structured.DeviceID]*resourceapi.BasicDevice)
+	nodeDevices := make(map[string][]structured.DeviceID)
+	for _, slice := range slices {
+		for i := range slice.Spec.Devices {
+			if basic := slice.Spec.Devices[i].Basic; basic != nil {
+				deviceID := structured.DeviceID{Driver: slice.Spec.Driver, Pool: slice.Spec.Pool.Name, Device: slice.Spec.Devices[i].Name}
+				devices[deviceID] = basic
+				if slice.Spec.NodeName != "" {
+					nodeDevices[slice.Spec.NodeName] = append(nodeDevices[slice.Spec.NodeName], deviceID)
+				}
+			}
+		}
+	}
+
+	scores := make(map[string]int64, len(state.nodeAllocations))
+	for i, attributes := range attributesForClaim {
+		for _, attribute := range attributes {
+			values := make(map[string]float64, len(state.nodeAllocations))
+			for nodeName, allocations := range state.nodeAllocations {
+				if attribute.Source == ScoreSourceNode {
+					for _, deviceID := range nodeDevices[nodeName] {
+						if value, ok := numericAttribute(devices[deviceID], deviceID.Driver, attribute.Name); ok {
+							if current, found := values[nodeName]; !found || value > current {
+								values[nodeName] = value
+							}
+						}
+					}
+					continue
+				}
+				if i >= len(allocations) || allocations[i] == nil {
+					continue
+				}
+				for _, result := range allocations[i].Devices.Results {
+					device := devices[structured.DeviceID{Driver: result.Driver, Pool: result.Pool, Device: result.Device}]
+					if device == nil {
+						continue
+					}
+					if value, ok := numericAttribute(device, result.Driver, attribute.Name); ok {
+						values[nodeName] += value
+					}
+				}
+			}
+			minValue, maxValue := math.Inf(1), math.Inf(-1)
+			for _, value := range values {
+				minValue = min(minValue, value)
+				maxValue = max(maxValue, value)
+			}
+			if maxValue <= minValue {
+				// No difference between nodes.
+				continue
+			}
+			for nodeName, value := range values {
+				fraction := (value - minValue) / (maxValue - minValue)
+				if attribute.Direction == ScoreDirectionLower {
+					fraction = 1 - fraction
+				}
+				scores[nodeName] += int64(math.Round(fraction * float64(attribute.Weight)))
+			}
+		}
+	}
+	return scores, nil
+}
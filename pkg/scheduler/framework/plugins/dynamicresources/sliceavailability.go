@@ -0,0 +1,155 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/dynamicresources/metrics"
+)
+
+// sliceSyncCheckPeriod is the interval between checks whether the
+// ResourceSlice informer has synced after it was found to be unavailable.
+const sliceSyncCheckPeriod = 10 * time.Second
+
+// sliceSyncGracePeriod is how long the plugin waits for the ResourceSlice
+// informer to sync before Filter treats the slices as unavailable. It's a
+// variable so that tests can change it.
+var sliceSyncGracePeriod = time.Minute
+
+// watchErrorHandlerSetter is implemented by [cache.SharedInformer]. It is
+// not part of Informer because that would break existing callers of
+// NewWithDependencies.
+type watchErrorHandlerSetter interface {
+	SetWatchErrorHandler(handler cache.WatchErrorHandler) error
+}
+
+// sliceAvailability detects when the ResourceSlice informer cannot list
+// slices, for example because the RBAC rules of the scheduler lack the
+// permission or the API group is disabled. Without that check the plugin
+// sees no devices at all and rejects all nodes, which looks like a lack
+// of devices instead of the configuration problem that it is.
+type sliceAvailability struct {
+	// hasSynced is nil if the plugin was created without
+	// Dependencies.SliceSynced. Slices then are always available.
+	hasSynced cache.InformerSynced
+
+	mutex sync.Mutex
+	// unavailable is set once the grace period has passed without
+	// the informer having synced.
+	unavailable bool
+	// lastErr is the most recent error reported by the informer.
+	lastErr error
+}
+
+// watchErrorHandler records the error and then handles it like
+// the informer would by default.
+func (s *sliceAvailability) watchErrorHandler(r *cache.Reflector, err error) {
+	s.mutex.Lock()
+	s.lastErr = err
+	s.mutex.Unlock()
+	cache.DefaultWatchErrorHandler(r, err)
+}
+
+// err returns the reason why ResourceSlices cannot be listed or nil if
+// they can be listed. It recovers automatically once the informer has
+// synced.
+func (s *sliceAvailability) err() error {
+	if s.hasSynced == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.unavailable {
+		return nil
+	}
+	if s.hasSynced() {
+		s.setAvailable()
+		return nil
+	}
+	return s.cause()
+}
+
+// check returns true if the informer has synced. Otherwise it marks
+// the slices as unavailable and logs that once.
+func (s *sliceAvailability) check(logger klog.Logger) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.hasSynced() {
+		if s.unavailable {
+			logger.Info("ResourceSlice informer has synced, pods with claims can be scheduled again")
+			s.setAvailable()
+		}
+		return true
+	}
+	if !s.unavailable {
+		logger.Error(s.cause(), "Cannot list ResourceSlices, pods with claims cannot be scheduled", "gracePeriod", sliceSyncGracePeriod)
+		metrics.ResourceSlicesUnavailable.Set(1)
+		s.unavailable = true
+	}
+	return false
+}
+
+// setAvailable must be called while holding the mutex.
+func (s *sliceAvailability) setAvailable() {
+	s.unavailable = false
+	s.lastErr = nil
+	metrics.ResourceSlicesUnavailable.Set(0)
+}
+
+// cause must be called while holding the mutex.
+func (s *sliceAvailability) cause() error {
+	if s.lastErr != nil {
+		return s.lastErr
+	}
+	return errors.New("informer has not synced")
+}
+
+// checkSlices returns an error if ResourceSlices cannot be listed.
+// PreFilter and Filter use it because rejecting the pod for lack of
+// devices would be misleading when the devices are simply not known.
+func (pl *dynamicResources) checkSlices() error {
+	if err := pl.sliceAvailability.err(); err != nil {
+		return fmt.Errorf("cannot list ResourceSlices: %w", err)
+	}
+	return nil
+}
+
+// checkSliceAvailability waits for the grace period and then checks the
+// ResourceSlice informer until it has synced. It returns when that is
+// the case or the context is canceled.
+func (pl *dynamicResources) checkSliceAvailability(ctx context.Context, gracePeriod time.Duration) {
+	logger := klog.FromContext(ctx)
+	logger = klog.LoggerWithName(logger, "slices")
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(gracePeriod):
+	}
+
+	_ = wait.PollUntilContextCancel(ctx, sliceSyncCheckPeriod, true, func(ctx context.Context) (bool, error) {
+		return pl.sliceAvailability.check(logger), nil
+	})
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"fmt"
+	"time"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	resourcelisters "k8s.io/client-go/listers/resource/v1alpha3"
+)
+
+const (
+	// AnnotationResourceSliceHeartbeat may be set by a driver on its
+	// ResourceSlices. The value is the time in RFC 3339 format when the
+	// driver last confirmed that the slice is up-to-date. When the
+	// plugin is configured with a maximum age for slices, slices with an
+	// older heartbeat are ignored. Slices without this annotation never
+	// become stale.
+	AnnotationResourceSliceHeartbeat = "resource.kubernetes.io/heartbeat"
+)
+
+// isSliceStale returns true if the heartbeat of the slice is older than
+// the configured maximum age. An invalid heartbeat also counts as stale
+// because it is unknown when the driver updated the slice.
+func (pl *dynamicResources) isSliceStale(slice *resourceapi.ResourceSlice) bool {
+	if pl.sliceMaxAge <= 0 {
+		return false
+	}
+	heartbeat, ok := slice.Annotations[AnnotationResourceSliceHeartbeat]
+	if !ok {
+		return false
+	}
+	timestamp, err := time.Parse(time.RFC3339, heartbeat)
+	if err != nil {
+		return true
+	}
+	return pl.clock.Since(timestamp) > pl.sliceMaxAge
+}
+
+// sliceListerForAllocation returns the lister used by the allocator.
+// It hides slices in maintenance and, if a maximum age is configured,
+// stale slices.
+func (pl *dynamicResources) sliceListerForAllocation() resourcelisters.ResourceSliceLister {
+	return &freshSliceLister{ResourceSliceLister: pl.sliceLister, pl: pl}
+}
+
+// isSliceUsable returns true if the devices of the slice may be used
+// for new allocations.
+func (pl *dynamicResources) isSliceUsable(slice *resourceapi.ResourceSlice) bool {
+	return !pl.isSliceStale(slice) && !pl.isSliceInMaintenance(slice)
+}
+
+// freshSliceLister filters out slices which are not usable when listing.
+// Get is not used by the allocator and therefore not filtered.
+type freshSliceLister struct {
+	resourcelisters.ResourceSliceLister
+	pl *dynamicResources
+}
+
+func (l *freshSliceLister) List(selector labels.Selector) ([]*resourceapi.ResourceSlice, error) {
+	slices, err := l.ResourceSliceLister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	fresh := make([]*resourceapi.ResourceSlice, 0, len(slices))
+	for _, slice := range slices {
+		if l.pl.isSliceUsable(slice) {
+			fresh = append(fresh, slice)
+		}
+	}
+	return fresh, nil
+}
+
+// staleDrivers returns, for each node, the sorted names of the drivers
+// which have stale slices for the node. PreFilter determines this once
+// for all nodes.
+func (pl *dynamicResources) staleDrivers() (map[string][]string, error) {
+	if pl.sliceMaxAge <= 0 {
+		return nil, nil
+	}
+	slices, err := pl.sliceLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list resource slices: %w", err)
+	}
+	drivers := make(map[string]sets.Set[string])
+	for _, slice := range slices {
+		if slice.Spec.NodeName == "" || !pl.isSliceStale(slice) {
+			continue
+		}
+		if drivers[slice.Spec.NodeName] == nil {
+			drivers[slice.Spec.NodeName] = sets.New[string]()
+		}
+		drivers[slice.Spec.NodeName].Insert(slice.Spec.Driver)
+	}
+	staleDrivers := make(map[string][]string, len(drivers))
+	for nodeName, nodeDrivers := range drivers {
+		staleDrivers[nodeName] = sets.List(nodeDrivers)
+	}
+	return staleDrivers, nil
+}
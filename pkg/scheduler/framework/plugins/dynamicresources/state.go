@@ -0,0 +1,234 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"slices"
+	"sort"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/dynamic-resource-allocation/structured"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+const (
+	// StateKey is the key under which PreFilter stores the state of the
+	// plugin in the CycleState. The stored value is internal, other
+	// plugins must use ReadState to access it.
+	StateKey framework.StateKey = Name
+
+	// FilterReasonsStateKey is the key under which PreFilter stores
+	// *FilterReasons in the CycleState of pods which have claims.
+	FilterReasonsStateKey framework.StateKey = Name + "/FilterReasons"
+
+	// AllocationHintsStateKey is the key under which other plugins may
+	// store *AllocationHints in the CycleState. They must do that
+	// before PreFilter of this plugin runs.
+	AllocationHintsStateKey framework.StateKey = Name + "/AllocationHints"
+
+	// removedPodsKey is the key under which RemovePod stores
+	// *removedPods in the CycleState.
+	removedPodsKey framework.StateKey = Name + "/removedPods"
+)
+
+// StateReaderVersion is the version of the StateReader API. Fields and
+// methods may get added without changing it. It gets incremented when
+// the meaning of existing ones changes or when they get removed, so
+// plugins which depend on a specific behavior can check it at
+// compile time.
+const StateReaderVersion = 1
+
+// ClaimPhase describes how far the handling of a claim has progressed
+// in the current scheduling cycle.
+type ClaimPhase string
+
+const (
+	// ClaimPhaseAllocated means that the claim is allocated, either
+	// since the start of the scheduling cycle or because PreBind
+	// has written the allocation.
+	ClaimPhaseAllocated ClaimPhase = "Allocated"
+
+	// ClaimPhasePending means that the scheduler needs to allocate
+	// the claim and no node has been chosen yet.
+	ClaimPhasePending ClaimPhase = "Pending"
+
+	// ClaimPhaseReserved means that Reserve has picked the allocation
+	// for the chosen node. PreBind will write it.
+	ClaimPhaseReserved ClaimPhase = "Reserved"
+
+	// ClaimPhaseWaitingForDriver means that a DRA driver with a control
+	// plane controller needs to allocate the claim.
+	ClaimPhaseWaitingForDriver ClaimPhase = "WaitingForDriver"
+)
+
+// ClaimState describes one claim of the pod.
+type ClaimState struct {
+	// PodClaimName is the name of the entry in pod.spec.resourceClaims.
+	PodClaimName string
+
+	// ClaimName is the name of the ResourceClaim object.
+	ClaimName string
+
+	// Phase is the phase of the claim in the current scheduling cycle.
+	Phase ClaimPhase
+}
+
+// NodeAllocation is the tentative allocation for one claim which Filter
+// computed for a node.
+type NodeAllocation struct {
+	// ClaimName is the name of the ResourceClaim object.
+	ClaimName string
+
+	// Devices are the devices which would get allocated.
+	Devices []resourceapi.DeviceRequestAllocationResult
+}
+
+// StateReader provides read-only access to the state of the plugin in
+// a CycleState. It is meant for other plugins which cooperate with this
+// one, for example custom binders.
+//
+// All data is deep-copied, modifying it has no effect on the plugin.
+// The reader observes changes made by later extension points of the
+// same scheduling cycle.
+type StateReader struct {
+	state *stateData
+}
+
+// ReadState returns a reader for the state stored by PreFilter. It
+// returns nil if PreFilter has not been called for the pod.
+func ReadState(cs *framework.CycleState) *StateReader {
+	state, err := getStateData(cs)
+	if err != nil {
+		return nil
+	}
+	return &StateReader{state: state}
+}
+
+// Claims returns the claims of the pod in the order in which they are
+// listed in the pod spec. It is empty for pods without claims.
+func (r *StateReader) Claims() []ClaimState {
+	r.state.mutex.Lock()
+	defer r.state.mutex.Unlock()
+
+	claims := make([]ClaimState, 0, len(r.state.claims))
+	for index, claim := range r.state.claims {
+		info := r.state.informationsForClaim[index]
+		var phase ClaimPhase
+		switch {
+		case claim.Status.Allocation != nil:
+			phase = ClaimPhaseAllocated
+		case info.allocation != nil:
+			phase = ClaimPhaseReserved
+		case info.structuredParameters:
+			phase = ClaimPhasePending
+		default:
+			phase = ClaimPhaseWaitingForDriver
+		}
+		claims = append(claims, ClaimState{
+			PodClaimName: info.podClaimName,
+			ClaimName:    claim.Name,
+			Phase:        phase,
+		})
+	}
+	return claims
+}
+
+// Nodes returns the names of all nodes for which Filter computed an
+// allocation, in alphabetical order.
+func (r *StateReader) Nodes() []string {
+	r.state.mutex.Lock()
+	defer r.state.mutex.Unlock()
+
+	nodeNames := make([]string, 0, len(r.state.nodeAllocations))
+	for nodeName := range r.state.nodeAllocations {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	return nodeNames
+}
+
+// NodeAllocations returns the allocations which Filter computed for
+// the pending claims on the node and false if it has none for the node.
+func (r *StateReader) NodeAllocations(nodeName string) ([]NodeAllocation, bool) {
+	r.state.mutex.Lock()
+	defer r.state.mutex.Unlock()
+
+	allocations, ok := r.state.nodeAllocations[nodeName]
+	if !ok || r.state.allocator == nil {
+		return nil, false
+	}
+	// Entries in these two slices match each other.
+	claimsToAllocate := r.state.allocator.ClaimsToAllocate()
+	result := make([]NodeAllocation, 0, len(allocations))
+	for i, allocation := range allocations {
+		devices := make([]resourceapi.DeviceRequestAllocationResult, len(allocation.Devices.Results))
+		for j := range allocation.Devices.Results {
+			allocation.Devices.Results[j].DeepCopyInto(&devices[j])
+		}
+		result = append(result, NodeAllocation{
+			ClaimName: claimsToAllocate[i].Name,
+			Devices:   devices,
+		})
+	}
+	return result, true
+}
+
+// AllocationHints can be provided by a plugin which runs before this one,
+// for example one which already decided which devices are best for the
+// pod. When allocating claims, the hinted devices and pools get tried
+// first. Other devices are still used when the hinted ones are not
+// available.
+type AllocationHints struct {
+	// Devices are tried before all other devices, in this order.
+	Devices []structured.DeviceID
+
+	// Pools are tried before the remaining pools, in this order.
+	Pools []structured.PoolID
+}
+
+var _ framework.StateData = &AllocationHints{}
+
+// GetAllocationHints returns the hints stored in the CycleState, nil if
+// there are none.
+func GetAllocationHints(cs *framework.CycleState) *AllocationHints {
+	state, err := cs.Read(AllocationHintsStateKey)
+	if err != nil {
+		return nil
+	}
+	hints, _ := state.(*AllocationHints)
+	return hints
+}
+
+// Clone is used when the scheduler simulates scheduling, for example during
+// preemption.
+func (h *AllocationHints) Clone() framework.StateData {
+	return &AllocationHints{
+		Devices: slices.Clone(h.Devices),
+		Pools:   slices.Clone(h.Pools),
+	}
+}
+
+// structuredHints converts the hints for the allocator.
+func (h *AllocationHints) structuredHints() *structured.Hints {
+	if h == nil {
+		return nil
+	}
+	return &structured.Hints{
+		Devices: h.Devices,
+		Pools:   h.Pools,
+	}
+}
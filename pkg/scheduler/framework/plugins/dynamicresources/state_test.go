@@ -0,0 +1,191 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The tests in this file use a separate package to ensure that
+// other plugins can use the StateReader without access to internals.
+package dynamicresources_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/dynamic-resource-allocation/structured"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/dynamicresources"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/feature"
+	"k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+	st "k8s.io/kubernetes/pkg/scheduler/testing"
+	"k8s.io/kubernetes/pkg/scheduler/util/assumecache"
+	"k8s.io/kubernetes/test/utils/ktesting"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	nodeName     = "worker"
+	driver       = "some-driver"
+	className    = "my-resource-class"
+	namespace    = "default"
+	podName      = "my-pod"
+	resourceName = "my-resource"
+	claimName    = podName + "-" + resourceName
+)
+
+// plugin is the subset of the plugin interfaces used by the tests.
+type plugin interface {
+	framework.PreFilterPlugin
+	framework.FilterPlugin
+	framework.ReservePlugin
+}
+
+func setup(t *testing.T, objs ...apiruntime.Object) (ktesting.TContext, plugin) {
+	tCtx := ktesting.Init(t)
+	objs = append(objs,
+		&resourceapi.DeviceClass{ObjectMeta: metav1.ObjectMeta{Name: className}},
+		st.MakeResourceSlice(nodeName, driver).Device("instance-1", nil).Obj(),
+		st.MakeResourceClaim("").Name(claimName).Namespace(namespace).Request(className).Obj(),
+	)
+	client := fake.NewSimpleClientset(objs...)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	claimCache := assumecache.NewAssumeCache(tCtx.Logger(), informerFactory.Resource().V1alpha3().ResourceClaims().Informer(), "resource claim", "", nil)
+	fh, err := runtime.NewFramework(tCtx, nil, nil,
+		runtime.WithClientSet(client),
+		runtime.WithInformerFactory(informerFactory),
+		runtime.WithResourceClaimCache(claimCache),
+	)
+	require.NoError(t, err, "create framework")
+	pl, err := dynamicresources.New(tCtx, nil, fh, feature.Features{EnableDynamicResourceAllocation: true})
+	require.NoError(t, err, "create plugin")
+
+	informerFactory.Start(tCtx.Done())
+	t.Cleanup(func() {
+		tCtx.Cancel("test is done")
+		informerFactory.Shutdown()
+	})
+	informerFactory.WaitForCacheSync(tCtx.Done())
+
+	return tCtx, pl.(plugin)
+}
+
+func TestStateReader(t *testing.T) {
+	tCtx, pl := setup(t)
+	pod := st.MakePod().Name(podName).Namespace(namespace).UID("1234").
+		PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: ptr.To(claimName)}).
+		Obj()
+	node := st.MakeNode().Name(nodeName).Obj()
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(node)
+	state := framework.NewCycleState()
+
+	assert.Nil(t, dynamicresources.ReadState(state), "state before PreFilter")
+
+	_, status := pl.PreFilter(tCtx, state, pod)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	reader := dynamicresources.ReadState(state)
+	require.NotNil(t, reader, "state after PreFilter")
+	assert.Equal(t, []dynamicresources.ClaimState{{PodClaimName: resourceName, ClaimName: claimName, Phase: dynamicresources.ClaimPhasePending}}, reader.Claims(), "claims after PreFilter")
+	assert.Empty(t, reader.Nodes(), "nodes after PreFilter")
+
+	status = pl.Filter(tCtx, state, pod, nodeInfo)
+	require.True(t, status.IsSuccess(), "Filter: %v", status)
+	assert.Equal(t, []string{nodeName}, reader.Nodes(), "nodes after Filter")
+	expectAllocations := []dynamicresources.NodeAllocation{{
+		ClaimName: claimName,
+		Devices: []resourceapi.DeviceRequestAllocationResult{{
+			Request: "req-1",
+			Driver:  driver,
+			Pool:    nodeName,
+			Device:  "instance-1",
+		}},
+	}}
+	allocations, ok := reader.NodeAllocations(nodeName)
+	require.True(t, ok, "allocations for node")
+	assert.Equal(t, expectAllocations, allocations, "allocations after Filter")
+	_, ok = reader.NodeAllocations("no-such-node")
+	assert.False(t, ok, "allocations for unknown node")
+
+	// The caller gets a copy.
+	allocations[0].ClaimName = "modified"
+	allocations[0].Devices[0].Device = "modified"
+	allocations[0].Devices = append(allocations[0].Devices, resourceapi.DeviceRequestAllocationResult{Device: "added"})
+	allocations, _ = reader.NodeAllocations(nodeName)
+	assert.Equal(t, expectAllocations, allocations, "allocations after modifying the result")
+	claims := reader.Claims()
+	claims[0].Phase = dynamicresources.ClaimPhaseAllocated
+	assert.Equal(t, []dynamicresources.ClaimState{{PodClaimName: resourceName, ClaimName: claimName, Phase: dynamicresources.ClaimPhasePending}}, reader.Claims(), "claims after modifying the result")
+	nodes := reader.Nodes()
+	nodes[0] = "modified"
+	assert.Equal(t, []string{nodeName}, reader.Nodes(), "nodes after modifying the result")
+
+	status = pl.Reserve(tCtx, state, pod, nodeName)
+	require.True(t, status.IsSuccess(), "Reserve: %v", status)
+	assert.Equal(t, []dynamicresources.ClaimState{{PodClaimName: resourceName, ClaimName: claimName, Phase: dynamicresources.ClaimPhaseReserved}}, reader.Claims(), "claims after Reserve")
+	pl.Unreserve(tCtx, state, pod, nodeName)
+}
+
+func TestStateReaderNoClaims(t *testing.T) {
+	tCtx, pl := setup(t)
+	pod := st.MakePod().Name(podName).Namespace(namespace).UID("1234").Obj()
+	state := framework.NewCycleState()
+
+	_, status := pl.PreFilter(tCtx, state, pod)
+	require.Equal(t, framework.Skip, status.Code(), "PreFilter: %v", status)
+	reader := dynamicresources.ReadState(state)
+	require.NotNil(t, reader, "state after PreFilter")
+	assert.Empty(t, reader.Claims(), "claims")
+	assert.Empty(t, reader.Nodes(), "nodes")
+}
+
+func TestAllocationHints(t *testing.T) {
+	poolSlice := func(pool string) *resourceapi.ResourceSlice {
+		slice := st.MakeResourceSlice(nodeName, driver).Device("instance-1", nil).Obj()
+		slice.Name = nodeName + "-" + pool
+		slice.Spec.Pool.Name = pool
+		return slice
+	}
+	tCtx, pl := setup(t, poolSlice("pool-a"), poolSlice("pool-b"))
+	pod := st.MakePod().Name(podName).Namespace(namespace).UID("1234").
+		PodResourceClaims(v1.PodResourceClaim{Name: resourceName, ResourceClaimName: ptr.To(claimName)}).
+		Obj()
+	node := st.MakeNode().Name(nodeName).Obj()
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(node)
+	state := framework.NewCycleState()
+
+	// Some plugin which runs earlier prefers pool-b.
+	state.Write(dynamicresources.AllocationHintsStateKey, &dynamicresources.AllocationHints{
+		Pools: []structured.PoolID{{Driver: driver, Pool: "pool-b"}},
+	})
+	assert.NotNil(t, dynamicresources.GetAllocationHints(state), "hints")
+
+	_, status := pl.PreFilter(tCtx, state, pod)
+	require.True(t, status.IsSuccess(), "PreFilter: %v", status)
+	status = pl.Filter(tCtx, state, pod, nodeInfo)
+	require.True(t, status.IsSuccess(), "Filter: %v", status)
+
+	allocations, ok := dynamicresources.ReadState(state).NodeAllocations(nodeName)
+	require.True(t, ok, "allocations for node")
+	require.Len(t, allocations, 1)
+	require.Len(t, allocations[0].Devices, 1)
+	assert.Equal(t, "pool-b", allocations[0].Devices[0].Pool, "pool of allocated device")
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// targetNode returns the name of the only node on which the pod can run,
+// the empty string if there is no such node. That is the case for pods
+// with spec.nodeName and for pods which require that node by name
+// through node affinity, like the DaemonSet controller does.
+//
+// Allocating devices for such a pod on other nodes is pointless
+// because other plugins reject those nodes anyway.
+func targetNode(pod *v1.Pod) string {
+	if pod.Spec.NodeName != "" {
+		return pod.Spec.NodeName
+	}
+	affinity := pod.Spec.Affinity
+	if affinity == nil ||
+		affinity.NodeAffinity == nil ||
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	// Terms are ORed, so only a single term can limit the pod to one node.
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 {
+		return ""
+	}
+	var nodeName string
+	for _, requirement := range terms[0].MatchFields {
+		if requirement.Key != metav1.ObjectNameField ||
+			requirement.Operator != v1.NodeSelectorOpIn ||
+			len(requirement.Values) != 1 {
+			continue
+		}
+		if nodeName != "" && nodeName != requirement.Values[0] {
+			// Cannot run anywhere. The NodeAffinity plugin
+			// reports that.
+			return ""
+		}
+		nodeName = requirement.Values[0]
+	}
+	return nodeName
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dynamicresources
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/dynamicresources/metrics"
+)
+
+// multipleDrivers is the driver label of ExtensionPointDuration for pods
+// whose claims belong to different drivers.
+const multipleDrivers = "multiple"
+
+// driverLabel determines the driver label of ExtensionPointDuration for the
+// claims of the pod. Claims which are neither allocated nor handled by a
+// control plane controller cannot be attributed to a driver yet.
+func (s *stateData) driverLabel() string {
+	drivers := sets.New[string]()
+	unknown := false
+	for index, claim := range s.claims {
+		allocation := claim.Status.Allocation
+		if allocation == nil && index < len(s.informationsForClaim) {
+			allocation = s.informationsForClaim[index].allocation
+		}
+		switch {
+		case allocation != nil:
+			if allocation.Controller != "" {
+				drivers.Insert(allocation.Controller)
+			}
+			for _, result := range allocation.Devices.Results {
+				drivers.Insert(result.Driver)
+			}
+		case claim.Spec.Controller != "":
+			drivers.Insert(claim.Spec.Controller)
+		default:
+			unknown = true
+		}
+	}
+	switch {
+	case drivers.Len() > 1:
+		return multipleDrivers
+	case drivers.Len() == 1 && !unknown:
+		return sets.List(drivers)[0]
+	default:
+		return ""
+	}
+}
+
+// observeDuration records the time spent in an extension point since start.
+// It must only be called for pods with claims, which keeps the overhead for
+// other pods at zero.
+func observeDuration(extensionPoint string, state *stateData, start time.Time) {
+	metrics.ExtensionPointDuration.WithLabelValues(extensionPoint, state.metricsDriver).Observe(time.Since(start).Seconds())
+}
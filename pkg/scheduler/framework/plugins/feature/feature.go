@@ -20,7 +20,14 @@ package feature
 // This struct allows us to break the dependency of the plugins on
 // the internal k8s features pkg.
 type Features struct {
+	EnableDRAAttributeSelectors                  bool
+	EnableDRAConsumableCapacity                  bool
 	EnableDRAControlPlaneController              bool
+	EnableDRADeviceTaints                        bool
+	EnableDRANodeLocalOnly                       bool
+	EnableDRAPodLabelSelectors                   bool
+	EnableDRAReservationDeadline                 bool
+	EnableDRAReservedDeviceIndices               bool
 	EnableDynamicResourceAllocation              bool
 	EnableVolumeCapacityPriority                 bool
 	EnableNodeInclusionPolicyInPodTopologySpread bool
@@ -46,7 +46,14 @@ import (
 // through the WithFrameworkOutOfTreeRegistry option.
 func NewInTreeRegistry() runtime.Registry {
 	fts := plfeature.Features{
+		EnableDRAAttributeSelectors:                  feature.DefaultFeatureGate.Enabled(features.DRAAttributeSelectors),
+		EnableDRAConsumableCapacity:                  feature.DefaultFeatureGate.Enabled(features.DRAConsumableCapacity),
 		EnableDRAControlPlaneController:              feature.DefaultFeatureGate.Enabled(features.DRAControlPlaneController),
+		EnableDRADeviceTaints:                        feature.DefaultFeatureGate.Enabled(features.DRADeviceTaints),
+		EnableDRANodeLocalOnly:                       feature.DefaultFeatureGate.Enabled(features.DRANodeLocalOnly),
+		EnableDRAPodLabelSelectors:                   feature.DefaultFeatureGate.Enabled(features.DRAPodLabelSelectors),
+		EnableDRAReservationDeadline:                 feature.DefaultFeatureGate.Enabled(features.DRAReservationDeadline),
+		EnableDRAReservedDeviceIndices:               feature.DefaultFeatureGate.Enabled(features.DRAReservedDeviceIndices),
 		EnableDynamicResourceAllocation:              feature.DefaultFeatureGate.Enabled(features.DynamicResourceAllocation),
 		EnableVolumeCapacityPriority:                 feature.DefaultFeatureGate.Enabled(features.VolumeCapacityPriority),
 		EnableNodeInclusionPolicyInPodTopologySpread: feature.DefaultFeatureGate.Enabled(features.NodeInclusionPolicyInPodTopologySpread),
@@ -34,6 +34,7 @@ import (
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"k8s.io/kubernetes/pkg/scheduler/framework/parallelize"
 	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/defaultbinder"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/dynamicresources"
 	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/interpodaffinity"
 	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/nodeaffinity"
 	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/nodename"
@@ -221,6 +222,16 @@ func TestNodesWherePreemptionMightHelp(t *testing.T) {
 			},
 			expected: sets.New("node1", "node3"),
 		},
+		{
+			name: "ErrReasonCannotAllocate should be tried as it indicates that the devices on the node are in use by other pods",
+			nodesStatuses: framework.NodeToStatusMap{
+				"node1": framework.NewStatus(framework.Unschedulable, dynamicresources.ErrReasonCannotAllocate),
+				"node2": framework.NewStatus(framework.UnschedulableAndUnresolvable, nodename.ErrReason),
+				"node3": framework.NewStatus(framework.Unschedulable, dynamicresources.ErrReasonCannotAllocate),
+				"node4": framework.NewStatus(framework.UnschedulableAndUnresolvable, ""),
+			},
+			expected: sets.New("node1", "node3"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"reflect"
 	"sort"
 	"time"
@@ -444,6 +445,18 @@ func (f *frameworkImpl) Close() error {
 	return errors.Join(errs...)
 }
 
+// DebugHandlers returns the handlers of all plugins which implement
+// framework.DebugHandlerProvider.
+func (f *frameworkImpl) DebugHandlers() map[string]http.Handler {
+	handlers := make(map[string]http.Handler)
+	for name, plugin := range f.pluginsMap {
+		if provider, ok := plugin.(framework.DebugHandlerProvider); ok {
+			handlers[name] = provider.DebugHandler()
+		}
+	}
+	return handlers
+}
+
 // getScoreWeights makes sure that, between MultiPoint-Score plugin weights and individual Score
 // plugin weights there is not an overflow of MaxTotalScore.
 func getScoreWeights(f *frameworkImpl, plugins []config.Plugin) error {
@@ -126,6 +126,7 @@ const (
 	PodSchedulingContext  GVK = "PodSchedulingContext"
 	ResourceClaim         GVK = "ResourceClaim"
 	DeviceClass           GVK = "DeviceClass"
+	ResourceSlice         GVK = "ResourceSlice"
 
 	// WildCard is a special GVK to match all resources.
 	// e.g., If you register `{Resource: "*", ActionType: All}` in EventsToRegister,
@@ -220,6 +221,7 @@ func UnrollWildCardResource() []ClusterEventWithHint {
 		{Event: ClusterEvent{Resource: PodSchedulingContext, ActionType: All}},
 		{Event: ClusterEvent{Resource: ResourceClaim, ActionType: All}},
 		{Event: ClusterEvent{Resource: DeviceClass, ActionType: All}},
+		{Event: ClusterEvent{Resource: ResourceSlice, ActionType: All}},
 	}
 }
 
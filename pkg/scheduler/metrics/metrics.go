@@ -24,6 +24,7 @@ import (
 	"k8s.io/component-base/metrics"
 	"k8s.io/component-base/metrics/legacyregistry"
 	"k8s.io/kubernetes/pkg/features"
+	dynamicresourcesmetrics "k8s.io/kubernetes/pkg/scheduler/framework/plugins/dynamicresources/metrics"
 	volumebindingmetrics "k8s.io/kubernetes/pkg/scheduler/framework/plugins/volumebinding/metrics"
 )
 
@@ -294,6 +295,7 @@ func Register() {
 			RegisterMetrics(queueingHintExecutionDuration)
 		}
 		volumebindingmetrics.RegisterVolumeSchedulingMetrics()
+		dynamicresourcesmetrics.RegisterMetrics()
 	})
 }
 
@@ -103,6 +103,7 @@ func (sched *Scheduler) ScheduleOne(ctx context.Context) {
 	// Initialize an empty podsToActivate struct, which will be filled up by plugins or stay empty.
 	podsToActivate := framework.NewPodsToActivate()
 	state.Write(framework.PodsToActivateKey, podsToActivate)
+	state.Write(framework.PodSchedulingAttemptsKey, &framework.PodSchedulingAttempts{Attempts: podInfo.Attempts})
 
 	schedulingCycleCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
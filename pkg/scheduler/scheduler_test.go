@@ -649,6 +649,9 @@ func Test_buildQueueingHintMap(t *testing.T) {
 				{Resource: framework.DeviceClass, ActionType: framework.All}: {
 					{PluginName: filterWithoutEnqueueExtensions, QueueingHintFn: defaultQueueingHintFn},
 				},
+				{Resource: framework.ResourceSlice, ActionType: framework.All}: {
+					{PluginName: filterWithoutEnqueueExtensions, QueueingHintFn: defaultQueueingHintFn},
+				},
 			},
 		},
 		{
@@ -809,6 +812,7 @@ func Test_UnionedGVKs(t *testing.T) {
 				framework.PodSchedulingContext:  framework.All,
 				framework.ResourceClaim:         framework.All,
 				framework.DeviceClass:           framework.All,
+				framework.ResourceSlice:         framework.All,
 			},
 		},
 		{
@@ -960,6 +960,15 @@ func (wrapper *ResourceClaimWrapper) Request(deviceClassName string) *ResourceCl
 	return wrapper
 }
 
+// RequestWithCapacity adds one device request for the given device class
+// which consumes the given capacity of the allocated device instead of
+// using it exclusively.
+func (wrapper *ResourceClaimWrapper) RequestWithCapacity(deviceClassName string, capacity map[resourceapi.QualifiedName]resource.Quantity) *ResourceClaimWrapper {
+	wrapper.Request(deviceClassName)
+	wrapper.Spec.Devices.Requests[len(wrapper.Spec.Devices.Requests)-1].Capacity = capacity
+	return wrapper
+}
+
 // Allocation sets the allocation of the inner object.
 func (wrapper *ResourceClaimWrapper) Allocation(allocation *resourceapi.AllocationResult) *ResourceClaimWrapper {
 	wrapper.ResourceClaim.Status.Allocation = allocation
@@ -1092,6 +1101,14 @@ func (wrapper *ResourceSliceWrapper) Obj() *resourceapi.ResourceSlice {
 	return &wrapper.ResourceSlice
 }
 
+// Pool moves the slice into a different pool. The name of the slice
+// gets derived from the pool name to keep it unique.
+func (wrapper *ResourceSliceWrapper) Pool(name string) *ResourceSliceWrapper {
+	wrapper.Name = name + "-" + wrapper.Spec.Driver
+	wrapper.Spec.Pool.Name = name
+	return wrapper
+}
+
 func (wrapper *ResourceSliceWrapper) Devices(names ...string) *ResourceSliceWrapper {
 	for _, name := range names {
 		wrapper.Spec.Devices = append(wrapper.Spec.Devices, resourceapi.Device{Name: name})
@@ -1103,3 +1120,15 @@ func (wrapper *ResourceSliceWrapper) Device(name string, attrs map[resourceapi.Q
 	wrapper.Spec.Devices = append(wrapper.Spec.Devices, resourceapi.Device{Name: name, Basic: &resourceapi.BasicDevice{Attributes: attrs}})
 	return wrapper
 }
+
+// DeviceWithTaints adds a device which has no attributes and the given taints.
+func (wrapper *ResourceSliceWrapper) DeviceWithTaints(name string, taints ...v1.Taint) *ResourceSliceWrapper {
+	wrapper.Spec.Devices = append(wrapper.Spec.Devices, resourceapi.Device{Name: name, Basic: &resourceapi.BasicDevice{Taints: taints}})
+	return wrapper
+}
+
+// DeviceWithCapacity adds a device which has no attributes and the given capacity.
+func (wrapper *ResourceSliceWrapper) DeviceWithCapacity(name string, capacity map[resourceapi.QualifiedName]resource.Quantity) *ResourceSliceWrapper {
+	wrapper.Spec.Devices = append(wrapper.Spec.Devices, resourceapi.Device{Name: name, Basic: &resourceapi.BasicDevice{Capacity: capacity}})
+	return wrapper
+}
@@ -19,12 +19,17 @@ package assumecache
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"sync"
 
+	"github.com/google/go-cmp/cmp"
+
 	"k8s.io/klog/v2"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/kubernetes/pkg/scheduler/util/queue"
@@ -116,6 +121,9 @@ func (e ObjectNameError) Is(err error) bool {
 // Assume() only updates the latest object pointer.
 // Restore() sets the latest object pointer back to the informer object.
 // Get/List() always returns the latest object pointer.
+//
+// Objects returned by the cache are shared and must not be modified.
+// EnableMutationDetection can be used in tests to catch code which does.
 type AssumeCache struct {
 	// The logger that was chosen when setting up the cache.
 	// Will be used for all operations.
@@ -158,6 +166,13 @@ type AssumeCache struct {
 	// Index function for object
 	indexFunc cache.IndexFunc
 	indexName string
+
+	// mutationDetection is set by EnableMutationDetection.
+	mutationDetection bool
+
+	// mutations are the modifications found for objects which are
+	// not in the cache anymore.
+	mutations []error
 }
 
 type objInfo struct {
@@ -169,6 +184,10 @@ type objInfo struct {
 
 	// Latest object from informer
 	apiObj interface{}
+
+	// Deep copies of latestObj and apiObj, only with mutation detection.
+	latestCopy interface{}
+	apiCopy    interface{}
 }
 
 func objInfoKeyFunc(obj interface{}) (string, error) {
@@ -251,9 +270,11 @@ func (c *AssumeCache) add(obj interface{}) {
 			return
 		}
 		oldObj = objInfo.latestObj
+		c.recordMutations(objInfo)
 	}
 
-	objInfo := &objInfo{name: name, latestObj: obj, apiObj: obj}
+	objCopy := c.copyObj(obj)
+	objInfo := &objInfo{name: name, latestObj: obj, apiObj: obj, latestCopy: objCopy, apiCopy: objCopy}
 	if err = c.store.Update(objInfo); err != nil {
 		c.logger.Info("Error occurred while updating stored object", "err", err)
 	} else {
@@ -282,8 +303,9 @@ func (c *AssumeCache) delete(obj interface{}) {
 	defer c.rwMutex.Unlock()
 
 	var oldObj interface{}
-	if len(c.eventHandlers) > 0 {
-		if objInfo, _ := c.getObjInfo(name); objInfo != nil {
+	if objInfo, _ := c.getObjInfo(name); objInfo != nil {
+		c.recordMutations(objInfo)
+		if len(c.eventHandlers) > 0 {
 			oldObj = objInfo.latestObj
 		}
 	}
@@ -455,7 +477,11 @@ func (c *AssumeCache) Assume(obj interface{}) error {
 	c.pushEvent(objInfo.latestObj, obj)
 
 	// Only update the cached object
+	if objInfo.latestObj != objInfo.apiObj {
+		c.recordMutation(name, objInfo.latestObj, objInfo.latestCopy)
+	}
 	objInfo.latestObj = obj
+	objInfo.latestCopy = c.copyObj(obj)
 	c.logger.V(4).Info("Assumed object", "description", c.description, "cacheKey", name, "version", newVersion)
 	return nil
 }
@@ -473,7 +499,9 @@ func (c *AssumeCache) Restore(objName string) {
 	} else {
 		if objInfo.latestObj != objInfo.apiObj {
 			c.pushEvent(objInfo.latestObj, objInfo.apiObj)
+			c.recordMutation(objName, objInfo.latestObj, objInfo.latestCopy)
 			objInfo.latestObj = objInfo.apiObj
+			objInfo.latestCopy = objInfo.apiCopy
 		}
 		c.logger.V(4).Info("Restored object", "description", c.description, "cacheKey", objName)
 	}
@@ -506,6 +534,93 @@ func (c *AssumeCache) AddEventHandler(handler cache.ResourceEventHandler) cache.
 	return c.handlerRegistration
 }
 
+// EnableMutationDetection makes the cache keep a deep copy of each object
+// that it stores. CheckMutations then reports objects which were
+// modified after they were stored, typically by code which got them
+// from Get or List and forgot to copy them before making changes.
+// Only objects which implement [runtime.Object] are checked.
+//
+// This doubles the memory usage of the cache and therefore is meant
+// for tests.
+func (c *AssumeCache) EnableMutationDetection() {
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+
+	if c.mutationDetection {
+		return
+	}
+	c.mutationDetection = true
+	for _, obj := range c.store.List() {
+		objInfo := obj.(*objInfo)
+		objInfo.latestCopy = c.copyObj(objInfo.latestObj)
+		objInfo.apiCopy = objInfo.latestCopy
+		if objInfo.apiObj != objInfo.latestObj {
+			objInfo.apiCopy = c.copyObj(objInfo.apiObj)
+		}
+	}
+}
+
+// CheckMutations returns an error which describes all objects that were
+// modified after they were stored in the cache, including those that
+// have been replaced or removed since then. Always returns nil without
+// mutation detection.
+func (c *AssumeCache) CheckMutations() error {
+	c.rwMutex.RLock()
+	defer c.rwMutex.RUnlock()
+
+	errs := slices.Clone(c.mutations)
+	for _, obj := range c.store.List() {
+		errs = append(errs, c.objInfoMutations(obj.(*objInfo))...)
+	}
+	return errors.Join(errs...)
+}
+
+// copyObj returns a deep copy of the object for mutation detection, nil
+// if not needed or not possible.
+func (c *AssumeCache) copyObj(obj interface{}) interface{} {
+	if !c.mutationDetection {
+		return nil
+	}
+	if obj, ok := obj.(runtime.Object); ok {
+		return obj.DeepCopyObject()
+	}
+	return nil
+}
+
+// recordMutations gets called while the mutex is locked for writing,
+// before the object info gets replaced or removed.
+func (c *AssumeCache) recordMutations(objInfo *objInfo) {
+	c.mutations = append(c.mutations, c.objInfoMutations(objInfo)...)
+}
+
+// recordMutation gets called while the mutex is locked for writing,
+// before an object gets replaced.
+func (c *AssumeCache) recordMutation(name string, obj, objCopy interface{}) {
+	if err := c.objMutation(name, obj, objCopy); err != nil {
+		c.mutations = append(c.mutations, err)
+	}
+}
+
+func (c *AssumeCache) objInfoMutations(objInfo *objInfo) []error {
+	var errs []error
+	if err := c.objMutation(objInfo.name, objInfo.latestObj, objInfo.latestCopy); err != nil {
+		errs = append(errs, err)
+	}
+	if objInfo.apiObj != objInfo.latestObj {
+		if err := c.objMutation(objInfo.name, objInfo.apiObj, objInfo.apiCopy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (c *AssumeCache) objMutation(name string, obj, objCopy interface{}) error {
+	if objCopy == nil || equality.Semantic.DeepEqual(objCopy, obj) {
+		return nil
+	}
+	return fmt.Errorf("%v %q was modified after storing it in the assume cache (- stored, + modified):\n%s", c.description, name, cmp.Diff(objCopy, obj))
+}
+
 // emitEvents delivers all pending events that are in the queue, in the order
 // in which they were stored there (FIFO).
 func (c *AssumeCache) emitEvents() {
@@ -559,3 +559,45 @@ func TestListWithIndexer(t *testing.T) {
 	// List them
 	verifyList(ktesting.WithStep(tCtx, "after delete"), cache, objs, objs[0])
 }
+
+func TestMutationDetection(t *testing.T) {
+	tCtx, cache, informer := newTest(t)
+	cache.EnableMutationDetection()
+
+	newObj := func(name, version string) *metav1.PartialObjectMetadata {
+		return &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: version}}
+	}
+	obj := newObj("obj", "1")
+	otherObj := newObj("other-obj", "1")
+	informer.add(obj)
+	informer.add(otherObj)
+	if err := cache.CheckMutations(); err != nil {
+		tCtx.Fatalf("unexpected error without modifications: %v", err)
+	}
+
+	// Modifying an object which is still in the cache gets detected.
+	obj.Labels = map[string]string{"modified": "true"}
+	if err := cache.CheckMutations(); err == nil {
+		tCtx.Fatal("modification of object in cache not detected")
+	}
+	obj.Labels = nil
+
+	// Modifying an assumed object which got replaced also does.
+	assumedObj := newObj("other-obj", "2")
+	if err := cache.Assume(assumedObj); err != nil {
+		tCtx.Fatalf("Assume failed: %v", err)
+	}
+	cache.Restore("other-obj")
+	assumedObj.Labels = map[string]string{"modified": "true"}
+	if err := cache.CheckMutations(); err != nil {
+		tCtx.Fatalf("unexpected error for object which is not in the cache anymore: %v", err)
+	}
+	if err := cache.Assume(newObj("other-obj", "3")); err != nil {
+		tCtx.Fatalf("Assume failed: %v", err)
+	}
+	otherObj.Labels = map[string]string{"modified": "true"}
+	informer.update(newObj("other-obj", "4"))
+	if err := cache.CheckMutations(); err == nil {
+		tCtx.Fatal("modification of replaced object not detected")
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package defaultdeviceclass
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/admission"
+	genericadmissioninitializer "k8s.io/apiserver/pkg/admission/initializer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/apis/resource"
+)
+
+const (
+	// PluginName is the name of this admission controller plugin
+	PluginName = "DefaultDeviceClass"
+
+	// AnnotationDefaultDeviceClass may be set on a namespace. The value
+	// is the name of the DeviceClass that is used for requests of
+	// ResourceClaims and ResourceClaimTemplates in that namespace which
+	// do not reference a class.
+	AnnotationDefaultDeviceClass = "resource.kubernetes.io/default-device-class"
+)
+
+// Register registers a plugin
+func Register(plugins *admission.Plugins) {
+	plugins.Register(PluginName, func(config io.Reader) (admission.Interface, error) {
+		plugin := newPlugin()
+		return plugin, nil
+	})
+}
+
+// classDefaulterPlugin holds state for and implements the admission plugin.
+type classDefaulterPlugin struct {
+	*admission.Handler
+
+	client          kubernetes.Interface
+	namespaceLister corev1listers.NamespaceLister
+}
+
+var _ admission.Interface = &classDefaulterPlugin{}
+var _ admission.MutationInterface = &classDefaulterPlugin{}
+var _ = genericadmissioninitializer.WantsExternalKubeClientSet(&classDefaulterPlugin{})
+var _ = genericadmissioninitializer.WantsExternalKubeInformerFactory(&classDefaulterPlugin{})
+
+// newPlugin creates a new admission plugin.
+func newPlugin() *classDefaulterPlugin {
+	return &classDefaulterPlugin{
+		Handler: admission.NewHandler(admission.Create),
+	}
+}
+
+// SetExternalKubeClientSet sets the client which is used for namespaces
+// that are not in the informer cache yet.
+func (a *classDefaulterPlugin) SetExternalKubeClientSet(client kubernetes.Interface) {
+	a.client = client
+}
+
+// SetExternalKubeInformerFactory sets a lister and readyFunc for this
+// classDefaulterPlugin using the provided SharedInformerFactory.
+func (a *classDefaulterPlugin) SetExternalKubeInformerFactory(f informers.SharedInformerFactory) {
+	informer := f.Core().V1().Namespaces()
+	a.namespaceLister = informer.Lister()
+	a.SetReadyFunc(informer.Informer().HasSynced)
+}
+
+// ValidateInitialization ensures lister and client are set.
+func (a *classDefaulterPlugin) ValidateInitialization() error {
+	if a.namespaceLister == nil {
+		return fmt.Errorf("missing namespaceLister")
+	}
+	if a.client == nil {
+		return fmt.Errorf("missing client")
+	}
+	return nil
+}
+
+// Admit sets the device class of those requests in a ResourceClaim or
+// ResourceClaimTemplate which do not specify one to the default of the
+// namespace. Without a default, the object is left unchanged and
+// validation rejects it.
+func (a *classDefaulterPlugin) Admit(ctx context.Context, attr admission.Attributes, o admission.ObjectInterfaces) error {
+	if len(attr.GetSubresource()) != 0 {
+		return nil
+	}
+
+	var spec *resource.ResourceClaimSpec
+	switch attr.GetResource().GroupResource() {
+	case resource.Resource("resourceclaims"):
+		claim, ok := attr.GetObject().(*resource.ResourceClaim)
+		if !ok {
+			return apierrors.NewInternalError(fmt.Errorf("expected ResourceClaim resource, got: %v", attr.GetKind()))
+		}
+		spec = &claim.Spec
+	case resource.Resource("resourceclaimtemplates"):
+		template, ok := attr.GetObject().(*resource.ResourceClaimTemplate)
+		if !ok {
+			return apierrors.NewInternalError(fmt.Errorf("expected ResourceClaimTemplate resource, got: %v", attr.GetKind()))
+		}
+		spec = &template.Spec.Spec
+	default:
+		return nil
+	}
+
+	if !needsDefault(spec) {
+		return nil
+	}
+
+	if !a.WaitForReady() {
+		return admission.NewForbidden(attr, fmt.Errorf("not yet ready to handle request"))
+	}
+	className, err := a.defaultDeviceClass(ctx, attr.GetNamespace())
+	if err != nil {
+		return err
+	}
+	if className == "" {
+		// No default class, no need to set a default value.
+		return nil
+	}
+
+	klog.V(4).InfoS("Defaulting device class", "kind", attr.GetKind().Kind, "namespace", attr.GetNamespace(), "name", attr.GetName(), "deviceClass", className)
+	for i := range spec.Devices.Requests {
+		if spec.Devices.Requests[i].DeviceClassName == "" {
+			spec.Devices.Requests[i].DeviceClassName = className
+		}
+	}
+	return nil
+}
+
+// needsDefault returns true if some request does not reference a device class.
+func needsDefault(spec *resource.ResourceClaimSpec) bool {
+	for _, request := range spec.Devices.Requests {
+		if request.DeviceClassName == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultDeviceClass returns the name of the default device class of the
+// namespace, or the empty string if the namespace has none.
+func (a *classDefaulterPlugin) defaultDeviceClass(ctx context.Context, namespaceName string) (string, error) {
+	namespace, err := a.namespaceLister.Get(namespaceName)
+	if apierrors.IsNotFound(err) {
+		// The namespace might have been created so recently that
+		// the informer has not seen it yet.
+		namespace, err = a.client.CoreV1().Namespaces().Get(ctx, namespaceName, metav1.GetOptions{})
+	}
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", apierrors.NewInternalError(err)
+	}
+	return namespace.Annotations[AnnotationDefaultDeviceClass], nil
+}
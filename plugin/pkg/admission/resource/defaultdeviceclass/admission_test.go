@@ -0,0 +1,152 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package defaultdeviceclass
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/admission"
+	admissiontesting "k8s.io/apiserver/pkg/admission/testing"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/kubernetes/pkg/apis/resource"
+	"k8s.io/kubernetes/pkg/controller"
+)
+
+func TestAdmission(t *testing.T) {
+	const (
+		namespaceName = "testing"
+		defaultClass  = "default-class"
+		otherClass    = "other-class"
+	)
+	namespaceWithDefault := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        namespaceName,
+			Annotations: map[string]string{AnnotationDefaultDeviceClass: defaultClass},
+		},
+	}
+	namespaceWithoutDefault := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: namespaceName,
+		},
+	}
+	spec := func(classNames ...string) resource.ResourceClaimSpec {
+		var spec resource.ResourceClaimSpec
+		for _, className := range classNames {
+			spec.Devices.Requests = append(spec.Devices.Requests, resource.DeviceRequest{DeviceClassName: className})
+		}
+		return spec
+	}
+
+	testcases := map[string]struct {
+		// cachedNamespace is known to the informer.
+		cachedNamespace *corev1.Namespace
+		// namespace is only known to the client.
+		namespace  *corev1.Namespace
+		spec       resource.ResourceClaimSpec
+		expectSpec resource.ResourceClaimSpec
+	}{
+		"default": {
+			cachedNamespace: namespaceWithDefault,
+			spec:            spec("", otherClass),
+			expectSpec:      spec(defaultClass, otherClass),
+		},
+		"no-default": {
+			cachedNamespace: namespaceWithoutDefault,
+			spec:            spec("", otherClass),
+			expectSpec:      spec("", otherClass),
+		},
+		"class-set": {
+			cachedNamespace: namespaceWithDefault,
+			spec:            spec(otherClass),
+			expectSpec:      spec(otherClass),
+		},
+		"new-namespace": {
+			namespace:  namespaceWithDefault,
+			spec:       spec(""),
+			expectSpec: spec(defaultClass),
+		},
+		"missing-namespace": {
+			spec:       spec(""),
+			expectSpec: spec(""),
+		},
+	}
+
+	for name, tc := range testcases {
+		for _, kind := range []string{"ResourceClaim", "ResourceClaimTemplate"} {
+			t.Run(name+"/"+kind, func(t *testing.T) {
+				var objects []runtime.Object
+				if tc.namespace != nil {
+					objects = append(objects, tc.namespace)
+				}
+				ctrl := newPlugin()
+				ctrl.SetExternalKubeClientSet(fake.NewSimpleClientset(objects...))
+				informerFactory := informers.NewSharedInformerFactory(nil, controller.NoResyncPeriodFunc())
+				ctrl.SetExternalKubeInformerFactory(informerFactory)
+				// The informer is filled directly instead of being started.
+				ctrl.SetReadyFunc(func() bool { return true })
+				require.NoError(t, admission.ValidateInitialization(ctrl), "initialization")
+				if tc.cachedNamespace != nil {
+					require.NoError(t, informerFactory.Core().V1().Namespaces().Informer().GetStore().Add(tc.cachedNamespace))
+				}
+
+				var obj runtime.Object
+				var actualSpec *resource.ResourceClaimSpec
+				var resourceName string
+				switch kind {
+				case "ResourceClaim":
+					claim := &resource.ResourceClaim{
+						ObjectMeta: metav1.ObjectMeta{Name: "claim", Namespace: namespaceName},
+						Spec:       *tc.spec.DeepCopy(),
+					}
+					obj, actualSpec, resourceName = claim, &claim.Spec, "resourceclaims"
+				case "ResourceClaimTemplate":
+					template := &resource.ResourceClaimTemplate{
+						ObjectMeta: metav1.ObjectMeta{Name: "template", Namespace: namespaceName},
+						Spec:       resource.ResourceClaimTemplateSpec{Spec: *tc.spec.DeepCopy()},
+					}
+					obj, actualSpec, resourceName = template, &template.Spec.Spec, "resourceclaimtemplates"
+				}
+
+				attrs := admission.NewAttributesRecord(
+					obj, // new object
+					nil, // old object
+					resource.Kind(kind).WithVersion("version"),
+					namespaceName,
+					"testing",
+					resource.Resource(resourceName).WithVersion("version"),
+					"", // subresource
+					admission.Create,
+					&metav1.CreateOptions{},
+					false, // dryRun
+					nil,   // userInfo
+				)
+
+				err := admissiontesting.WithReinvocationTesting(t, ctrl).Admit(context.TODO(), attrs, nil)
+				require.NoError(t, err, "admit")
+				assert.Equal(t, tc.expectSpec, *actualSpec, "spec")
+			})
+		}
+	}
+}
@@ -38,10 +38,22 @@ func (AllocationResult) SwaggerDoc() map[string]string {
 	return map_AllocationResult
 }
 
+var map_AttributeDeviceSelector = map[string]string{
+	"":         "AttributeDeviceSelector compares one attribute of a device against a list of values. Only string and boolean attributes are supported. A device which does not have the attribute does not match, regardless of the operator.",
+	"name":     "Name is the name of the attribute. It must be fully qualified, i.e. include the domain. If the domain is the name of the driver of a device, attributes without the domain are also found, like in a MatchAttribute constraint.",
+	"operator": "Operator defines how the attribute gets compared against the values. Supported values are:\n - NotEquals: the attribute must not be equal to the single value.\n - In: the attribute must be equal to one of the values.\n - NotIn: the attribute must not be equal to any of the values.\n\nMore operators may get added in the future. Clients must refuse to handle selectors with unknown operators.",
+	"values":   "Values are the values which the attribute gets compared against. Boolean attributes are compared against \"true\" and \"false\". NotEquals requires exactly one value, In and NotIn at least one.",
+}
+
+func (AttributeDeviceSelector) SwaggerDoc() map[string]string {
+	return map_AttributeDeviceSelector
+}
+
 var map_BasicDevice = map[string]string{
 	"":           "BasicDevice defines one device instance.",
 	"attributes": "Attributes defines the set of attributes for this device. The name of each attribute must be unique in that set.\n\nThe maximum number of attributes and capacities combined is 32.",
 	"capacity":   "Capacity defines the set of capacities for this device. The name of each capacity must be unique in that set.\n\nThe maximum number of attributes and capacities combined is 32.",
+	"taints":     "Taints are the taints of the device. A device with a taint only gets allocated for a pod which tolerates the taint, see pod.spec.tolerations. This is useful for devices which should not be used by default, for example because they are experimental. Taints of a device are independent of the taints of the node that the device is attached to.\n\nOnly the NoSchedule effect is supported.\n\nThe maximum number of taints is 8.",
 }
 
 func (BasicDevice) SwaggerDoc() map[string]string {
@@ -88,11 +100,12 @@ func (DeviceAllocationResult) SwaggerDoc() map[string]string {
 }
 
 var map_DeviceAttribute = map[string]string{
-	"":        "DeviceAttribute must have exactly one field set.",
-	"int":     "IntValue is a number.",
-	"bool":    "BoolValue is a true/false value.",
-	"string":  "StringValue is a string. Must not be longer than 64 characters.",
-	"version": "VersionValue is a semantic version according to semver.org spec 2.0.0. Must not be longer than 64 characters.",
+	"":           "DeviceAttribute must have exactly one field set.",
+	"int":        "IntValue is a number.",
+	"bool":       "BoolValue is a true/false value.",
+	"string":     "StringValue is a string. Must not be longer than 64 characters.",
+	"version":    "VersionValue is a semantic version according to semver.org spec 2.0.0. Must not be longer than 64 characters.",
+	"stringList": "StringListValue is a list of strings, for example the numeric precisions supported by a device. It must have at least one and at most 16 entries. Each entry must not be longer than 64 characters.\n\nThis is an alpha field and requires enabling the DRAStringListAttributes feature gate.",
 }
 
 func (DeviceAttribute) SwaggerDoc() map[string]string {
@@ -151,7 +164,7 @@ var map_DeviceClassSpec = map[string]string{
 	"":              "DeviceClassSpec is used in a [DeviceClass] to define what can be allocated and how to configure it.",
 	"selectors":     "Each selector must be satisfied by a device which is claimed via this class.",
 	"config":        "Config defines configuration parameters that apply to each device that is claimed via this class. Some classses may potentially be satisfied by multiple drivers, so each instance of a vendor configuration applies to exactly one driver.\n\nThey are passed to the driver, but are not considered while allocating the claim.",
-	"suitableNodes": "Only nodes matching the selector will be considered by the scheduler when trying to find a Node that fits a Pod when that Pod uses a claim that has not been allocated yet *and* that claim gets allocated through a control plane controller. It is ignored when the claim does not use a control plane controller for allocation.\n\nSetting this field is optional. If unset, all Nodes are candidates.\n\nThis is an alpha field and requires enabling the DRAControlPlaneController feature gate.",
+	"suitableNodes": "Only nodes matching the selector will be considered by the scheduler when trying to find a Node that fits a Pod when that Pod uses a claim that has not been allocated yet. This applies to claims which get allocated by the scheduler and to claims which get allocated through a control plane controller.\n\nSetting this field is optional. If unset, all Nodes are candidates.\n\nThis is an alpha field and requires enabling the DRAControlPlaneController feature gate.",
 }
 
 func (DeviceClassSpec) SwaggerDoc() map[string]string {
@@ -185,6 +198,8 @@ var map_DeviceRequest = map[string]string{
 	"allocationMode":  "AllocationMode and its related fields define how devices are allocated to satisfy this request. Supported values are:\n\n- ExactCount: This request is for a specific number of devices.\n  This is the default. The exact number is provided in the\n  count field.\n\n- All: This request is for all of the matching devices in a pool.\n  Allocation will fail if some devices are already allocated,\n  unless adminAccess is requested.\n\nIf AlloctionMode is not specified, the default mode is ExactCount. If the mode is ExactCount and count is not specified, the default count is one. Any other requests must specify this field.\n\nMore modes may get added in the future. Clients must refuse to handle requests with unknown modes.",
 	"count":           "Count is used only when the count mode is \"ExactCount\". Must be greater than zero. If AllocationMode is ExactCount and this field is not specified, the default is one.",
 	"adminAccess":     "AdminAccess indicates that this is a claim for administrative access to the device(s). Claims with AdminAccess are expected to be used for monitoring or other management services for a device.  They ignore all ordinary claims to the device with respect to access modes and any resource allocations.",
+	"capacity":        "Capacity defines how much of the named capacities of a device each device allocated for this request consumes. When set, a device may get shared with other requests which also specify capacity, as long as the sum of what all of them consume does not exceed what the device provides. Each named capacity must be provided by the device, otherwise the device is not suitable.\n\nWhen not set, each allocated device is used exclusively.\n\nThis is an alpha field and requires enabling the DRAConsumableCapacity feature gate.",
+	"nodeLocalOnly":   "NodeLocalOnly restricts the devices for this request to those from resource pools which belong to the node that the pod gets scheduled to, i.e. ResourceSlices which have NodeName set. Devices in pools which are shared by several nodes, i.e. in slices with NodeSelector or AllNodes, are not considered for this request.\n\nThis is an alpha field and requires enabling the DRANodeLocalOnly feature gate.",
 }
 
 func (DeviceRequest) SwaggerDoc() map[string]string {
@@ -192,11 +207,12 @@ func (DeviceRequest) SwaggerDoc() map[string]string {
 }
 
 var map_DeviceRequestAllocationResult = map[string]string{
-	"":        "DeviceRequestAllocationResult contains the allocation result for one request.",
-	"request": "Request is the name of the request in the claim which caused this device to be allocated. Multiple devices may have been allocated per request.",
-	"driver":  "Driver specifies the name of the DRA driver whose kubelet plugin should be invoked to process the allocation once the claim is needed on a node.\n\nMust be a DNS subdomain and should end with a DNS domain owned by the vendor of the driver.",
-	"pool":    "This name together with the driver name and the device name field identify which device was allocated (`<driver name>/<pool name>/<device name>`).\n\nMust not be longer than 253 characters and may contain one or more DNS sub-domains separated by slashes.",
-	"device":  "Device references one device instance via its name in the driver's resource pool. It must be a DNS label.",
+	"":                 "DeviceRequestAllocationResult contains the allocation result for one request.",
+	"request":          "Request is the name of the request in the claim which caused this device to be allocated. Multiple devices may have been allocated per request.",
+	"driver":           "Driver specifies the name of the DRA driver whose kubelet plugin should be invoked to process the allocation once the claim is needed on a node.\n\nMust be a DNS subdomain and should end with a DNS domain owned by the vendor of the driver.",
+	"pool":             "This name together with the driver name and the device name field identify which device was allocated (`<driver name>/<pool name>/<device name>`).\n\nMust not be longer than 253 characters and may contain one or more DNS sub-domains separated by slashes.",
+	"device":           "Device references one device instance via its name in the driver's resource pool. It must be a DNS label.",
+	"consumedCapacity": "ConsumedCapacity records how much of the named capacities of the device is consumed by this allocation. It is set if (and only if) the request specified capacity, in which case the device may be shared with other allocations.\n\nThis is an alpha field and requires enabling the DRAConsumableCapacity feature gate.",
 }
 
 func (DeviceRequestAllocationResult) SwaggerDoc() map[string]string {
@@ -204,8 +220,9 @@ func (DeviceRequestAllocationResult) SwaggerDoc() map[string]string {
 }
 
 var map_DeviceSelector = map[string]string{
-	"":    "DeviceSelector must have exactly one field set.",
-	"cel": "CEL contains a CEL expression for selecting a device.",
+	"":          "DeviceSelector must have exactly one field set.",
+	"cel":       "CEL contains a CEL expression for selecting a device.",
+	"attribute": "Attribute compares one attribute of a device against a list of values. It covers common filters without having to write a CEL expression.\n\nThis is an alpha field and requires enabling the DRAAttributeSelectors feature gate.",
 }
 
 func (DeviceSelector) SwaggerDoc() map[string]string {
@@ -274,11 +291,13 @@ func (ResourceClaim) SwaggerDoc() map[string]string {
 }
 
 var map_ResourceClaimConsumerReference = map[string]string{
-	"":         "ResourceClaimConsumerReference contains enough information to let you locate the consumer of a ResourceClaim. The user must be a resource in the same namespace as the ResourceClaim.",
-	"apiGroup": "APIGroup is the group for the resource being referenced. It is empty for the core API. This matches the group in the APIVersion that is used when creating the resources.",
-	"resource": "Resource is the type of resource being referenced, for example \"pods\".",
-	"name":     "Name is the name of resource being referenced.",
-	"uid":      "UID identifies exactly one incarnation of the resource.",
+	"":                    "ResourceClaimConsumerReference contains enough information to let you locate the consumer of a ResourceClaim. The user must be a resource in the same namespace as the ResourceClaim.",
+	"apiGroup":            "APIGroup is the group for the resource being referenced. It is empty for the core API. This matches the group in the APIVersion that is used when creating the resources.",
+	"resource":            "Resource is the type of resource being referenced, for example \"pods\".",
+	"name":                "Name is the name of resource being referenced.",
+	"uid":                 "UID identifies exactly one incarnation of the resource.",
+	"reservationDeadline": "ReservationDeadline, if set, is the time until which the consumer is expected to start using the claim. The scheduler sets it for pods when reserving the claim in the binding phase. If the pod has not been bound to a node by then, the reservation may be removed again by a controller.\n\nThis is an alpha field and requires enabling the DRAReservationDeadline feature gate.",
+	"deviceIndices":       "DeviceIndices, if set, are the indices of those entries in status.allocation.devices.results which the consumer uses. If unset, the consumer uses all allocated devices. This is useful when a claim is shared and each consumer only needs some of its devices.\n\nThis is an alpha field and requires enabling the DRAReservedDeviceIndices feature gate.",
 }
 
 func (ResourceClaimConsumerReference) SwaggerDoc() map[string]string {
@@ -49,6 +49,27 @@ func (in *AllocationResult) DeepCopy() *AllocationResult {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AttributeDeviceSelector) DeepCopyInto(out *AttributeDeviceSelector) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AttributeDeviceSelector.
+func (in *AttributeDeviceSelector) DeepCopy() *AttributeDeviceSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(AttributeDeviceSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BasicDevice) DeepCopyInto(out *BasicDevice) {
 	*out = *in
@@ -66,6 +87,13 @@ func (in *BasicDevice) DeepCopyInto(out *BasicDevice) {
 			(*out)[key] = val.DeepCopy()
 		}
 	}
+	if in.Taints != nil {
+		in, out := &in.Taints, &out.Taints
+		*out = make([]v1.Taint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -144,7 +172,9 @@ func (in *DeviceAllocationResult) DeepCopyInto(out *DeviceAllocationResult) {
 	if in.Results != nil {
 		in, out := &in.Results, &out.Results
 		*out = make([]DeviceRequestAllocationResult, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Config != nil {
 		in, out := &in.Config, &out.Config
@@ -189,6 +219,11 @@ func (in *DeviceAttribute) DeepCopyInto(out *DeviceAttribute) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.StringListValue != nil {
+		in, out := &in.StringListValue, &out.StringListValue
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -430,6 +465,13 @@ func (in *DeviceRequest) DeepCopyInto(out *DeviceRequest) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = make(map[QualifiedName]resource.Quantity, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
 	return
 }
 
@@ -446,6 +488,13 @@ func (in *DeviceRequest) DeepCopy() *DeviceRequest {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeviceRequestAllocationResult) DeepCopyInto(out *DeviceRequestAllocationResult) {
 	*out = *in
+	if in.ConsumedCapacity != nil {
+		in, out := &in.ConsumedCapacity, &out.ConsumedCapacity
+		*out = make(map[QualifiedName]resource.Quantity, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
 	return
 }
 
@@ -467,6 +516,11 @@ func (in *DeviceSelector) DeepCopyInto(out *DeviceSelector) {
 		*out = new(CELDeviceSelector)
 		**out = **in
 	}
+	if in.Attribute != nil {
+		in, out := &in.Attribute, &out.Attribute
+		*out = new(AttributeDeviceSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -633,6 +687,15 @@ func (in *ResourceClaim) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceClaimConsumerReference) DeepCopyInto(out *ResourceClaimConsumerReference) {
 	*out = *in
+	if in.ReservationDeadline != nil {
+		in, out := &in.ReservationDeadline, &out.ReservationDeadline
+		*out = (*in).DeepCopy()
+	}
+	if in.DeviceIndices != nil {
+		in, out := &in.DeviceIndices, &out.DeviceIndices
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -728,7 +791,9 @@ func (in *ResourceClaimStatus) DeepCopyInto(out *ResourceClaimStatus) {
 	if in.ReservedFor != nil {
 		in, out := &in.ReservedFor, &out.ReservedFor
 		*out = make([]ResourceClaimConsumerReference, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	return
 }
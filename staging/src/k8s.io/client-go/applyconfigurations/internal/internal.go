@@ -12257,6 +12257,23 @@ var schemaYAML = typed.YAMLObject(`types:
     - name: nodeSelector
       type:
         namedType: io.k8s.api.core.v1.NodeSelector
+- name: io.k8s.api.resource.v1alpha3.AttributeDeviceSelector
+  map:
+    fields:
+    - name: name
+      type:
+        scalar: string
+      default: ""
+    - name: operator
+      type:
+        scalar: string
+      default: ""
+    - name: values
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
 - name: io.k8s.api.resource.v1alpha3.BasicDevice
   map:
     fields:
@@ -12270,6 +12287,12 @@ var schemaYAML = typed.YAMLObject(`types:
         map:
           elementType:
             namedType: io.k8s.apimachinery.pkg.api.resource.Quantity
+    - name: taints
+      type:
+        list:
+          elementType:
+            namedType: io.k8s.api.core.v1.Taint
+          elementRelationship: atomic
 - name: io.k8s.api.resource.v1alpha3.CELDeviceSelector
   map:
     fields:
@@ -12330,6 +12353,12 @@ var schemaYAML = typed.YAMLObject(`types:
     - name: string
       type:
         scalar: string
+    - name: stringList
+      type:
+        list:
+          elementType:
+            scalar: string
+          elementRelationship: atomic
     - name: version
       type:
         scalar: string
@@ -12429,6 +12458,11 @@ var schemaYAML = typed.YAMLObject(`types:
     - name: allocationMode
       type:
         scalar: string
+    - name: capacity
+      type:
+        map:
+          elementType:
+            namedType: io.k8s.apimachinery.pkg.api.resource.Quantity
     - name: count
       type:
         scalar: numeric
@@ -12440,6 +12474,10 @@ var schemaYAML = typed.YAMLObject(`types:
       type:
         scalar: string
       default: ""
+    - name: nodeLocalOnly
+      type:
+        scalar: boolean
+      default: false
     - name: selectors
       type:
         list:
@@ -12449,6 +12487,11 @@ var schemaYAML = typed.YAMLObject(`types:
 - name: io.k8s.api.resource.v1alpha3.DeviceRequestAllocationResult
   map:
     fields:
+    - name: consumedCapacity
+      type:
+        map:
+          elementType:
+            namedType: io.k8s.apimachinery.pkg.api.resource.Quantity
     - name: device
       type:
         scalar: string
@@ -12468,6 +12511,9 @@ var schemaYAML = typed.YAMLObject(`types:
 - name: io.k8s.api.resource.v1alpha3.DeviceSelector
   map:
     fields:
+    - name: attribute
+      type:
+        namedType: io.k8s.api.resource.v1alpha3.AttributeDeviceSelector
     - name: cel
       type:
         namedType: io.k8s.api.resource.v1alpha3.CELDeviceSelector
@@ -12552,10 +12598,19 @@ var schemaYAML = typed.YAMLObject(`types:
     - name: apiGroup
       type:
         scalar: string
+    - name: deviceIndices
+      type:
+        list:
+          elementType:
+            scalar: numeric
+          elementRelationship: atomic
     - name: name
       type:
         scalar: string
       default: ""
+    - name: reservationDeadline
+      type:
+        namedType: io.k8s.apimachinery.pkg.apis.meta.v1.Time
     - name: resource
       type:
         scalar: string
@@ -21,10 +21,11 @@ package v1alpha3
 // DeviceAttributeApplyConfiguration represents a declarative configuration of the DeviceAttribute type for use
 // with apply.
 type DeviceAttributeApplyConfiguration struct {
-	IntValue     *int64  `json:"int,omitempty"`
-	BoolValue    *bool   `json:"bool,omitempty"`
-	StringValue  *string `json:"string,omitempty"`
-	VersionValue *string `json:"version,omitempty"`
+	IntValue        *int64   `json:"int,omitempty"`
+	BoolValue       *bool    `json:"bool,omitempty"`
+	StringValue     *string  `json:"string,omitempty"`
+	VersionValue    *string  `json:"version,omitempty"`
+	StringListValue []string `json:"stringList,omitempty"`
 }
 
 // DeviceAttributeApplyConfiguration constructs a declarative configuration of the DeviceAttribute type for use with
@@ -64,3 +65,13 @@ func (b *DeviceAttributeApplyConfiguration) WithVersionValue(value string) *Devi
 	b.VersionValue = &value
 	return b
 }
+
+// WithStringListValue adds the given value to the StringListValue field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the StringListValue field.
+func (b *DeviceAttributeApplyConfiguration) WithStringListValue(values ...string) *DeviceAttributeApplyConfiguration {
+	for i := range values {
+		b.StringListValue = append(b.StringListValue, values[i])
+	}
+	return b
+}
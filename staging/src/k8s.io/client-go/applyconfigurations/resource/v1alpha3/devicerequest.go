@@ -20,17 +20,20 @@ package v1alpha3
 
 import (
 	resourcev1alpha3 "k8s.io/api/resource/v1alpha3"
+	resource "k8s.io/apimachinery/pkg/api/resource"
 )
 
 // DeviceRequestApplyConfiguration represents a declarative configuration of the DeviceRequest type for use
 // with apply.
 type DeviceRequestApplyConfiguration struct {
-	Name            *string                                `json:"name,omitempty"`
-	DeviceClassName *string                                `json:"deviceClassName,omitempty"`
-	Selectors       []DeviceSelectorApplyConfiguration     `json:"selectors,omitempty"`
-	AllocationMode  *resourcev1alpha3.DeviceAllocationMode `json:"allocationMode,omitempty"`
-	Count           *int64                                 `json:"count,omitempty"`
-	AdminAccess     *bool                                  `json:"adminAccess,omitempty"`
+	Name            *string                                              `json:"name,omitempty"`
+	DeviceClassName *string                                              `json:"deviceClassName,omitempty"`
+	Selectors       []DeviceSelectorApplyConfiguration                   `json:"selectors,omitempty"`
+	AllocationMode  *resourcev1alpha3.DeviceAllocationMode               `json:"allocationMode,omitempty"`
+	Count           *int64                                               `json:"count,omitempty"`
+	AdminAccess     *bool                                                `json:"adminAccess,omitempty"`
+	Capacity        map[resourcev1alpha3.QualifiedName]resource.Quantity `json:"capacity,omitempty"`
+	NodeLocalOnly   *bool                                                `json:"nodeLocalOnly,omitempty"`
 }
 
 // DeviceRequestApplyConfiguration constructs a declarative configuration of the DeviceRequest type for use with
@@ -91,3 +94,25 @@ func (b *DeviceRequestApplyConfiguration) WithAdminAccess(value bool) *DeviceReq
 	b.AdminAccess = &value
 	return b
 }
+
+// WithCapacity puts the entries into the Capacity field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, the entries provided by each call will be put on the Capacity field,
+// overwriting an existing map entries in Capacity field with the same key.
+func (b *DeviceRequestApplyConfiguration) WithCapacity(entries map[resourcev1alpha3.QualifiedName]resource.Quantity) *DeviceRequestApplyConfiguration {
+	if b.Capacity == nil && len(entries) > 0 {
+		b.Capacity = make(map[resourcev1alpha3.QualifiedName]resource.Quantity, len(entries))
+	}
+	for k, v := range entries {
+		b.Capacity[k] = v
+	}
+	return b
+}
+
+// WithNodeLocalOnly sets the NodeLocalOnly field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the NodeLocalOnly field is set to the value of the last call.
+func (b *DeviceRequestApplyConfiguration) WithNodeLocalOnly(value bool) *DeviceRequestApplyConfiguration {
+	b.NodeLocalOnly = &value
+	return b
+}
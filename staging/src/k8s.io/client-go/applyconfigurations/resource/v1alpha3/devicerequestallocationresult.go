@@ -18,13 +18,19 @@ limitations under the License.
 
 package v1alpha3
 
+import (
+	v1alpha3 "k8s.io/api/resource/v1alpha3"
+	resource "k8s.io/apimachinery/pkg/api/resource"
+)
+
 // DeviceRequestAllocationResultApplyConfiguration represents a declarative configuration of the DeviceRequestAllocationResult type for use
 // with apply.
 type DeviceRequestAllocationResultApplyConfiguration struct {
-	Request *string `json:"request,omitempty"`
-	Driver  *string `json:"driver,omitempty"`
-	Pool    *string `json:"pool,omitempty"`
-	Device  *string `json:"device,omitempty"`
+	Request          *string                                      `json:"request,omitempty"`
+	Driver           *string                                      `json:"driver,omitempty"`
+	Pool             *string                                      `json:"pool,omitempty"`
+	Device           *string                                      `json:"device,omitempty"`
+	ConsumedCapacity map[v1alpha3.QualifiedName]resource.Quantity `json:"consumedCapacity,omitempty"`
 }
 
 // DeviceRequestAllocationResultApplyConfiguration constructs a declarative configuration of the DeviceRequestAllocationResult type for use with
@@ -64,3 +70,17 @@ func (b *DeviceRequestAllocationResultApplyConfiguration) WithDevice(value strin
 	b.Device = &value
 	return b
 }
+
+// WithConsumedCapacity puts the entries into the ConsumedCapacity field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, the entries provided by each call will be put on the ConsumedCapacity field,
+// overwriting an existing map entries in ConsumedCapacity field with the same key.
+func (b *DeviceRequestAllocationResultApplyConfiguration) WithConsumedCapacity(entries map[v1alpha3.QualifiedName]resource.Quantity) *DeviceRequestAllocationResultApplyConfiguration {
+	if b.ConsumedCapacity == nil && len(entries) > 0 {
+		b.ConsumedCapacity = make(map[v1alpha3.QualifiedName]resource.Quantity, len(entries))
+	}
+	for k, v := range entries {
+		b.ConsumedCapacity[k] = v
+	}
+	return b
+}
@@ -21,7 +21,8 @@ package v1alpha3
 // DeviceSelectorApplyConfiguration represents a declarative configuration of the DeviceSelector type for use
 // with apply.
 type DeviceSelectorApplyConfiguration struct {
-	CEL *CELDeviceSelectorApplyConfiguration `json:"cel,omitempty"`
+	CEL       *CELDeviceSelectorApplyConfiguration       `json:"cel,omitempty"`
+	Attribute *AttributeDeviceSelectorApplyConfiguration `json:"attribute,omitempty"`
 }
 
 // DeviceSelectorApplyConfiguration constructs a declarative configuration of the DeviceSelector type for use with
@@ -37,3 +38,11 @@ func (b *DeviceSelectorApplyConfiguration) WithCEL(value *CELDeviceSelectorApply
 	b.CEL = value
 	return b
 }
+
+// WithAttribute sets the Attribute field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Attribute field is set to the value of the last call.
+func (b *DeviceSelectorApplyConfiguration) WithAttribute(value *AttributeDeviceSelectorApplyConfiguration) *DeviceSelectorApplyConfiguration {
+	b.Attribute = value
+	return b
+}
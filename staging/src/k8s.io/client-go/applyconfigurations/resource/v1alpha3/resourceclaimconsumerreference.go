@@ -19,16 +19,19 @@ limitations under the License.
 package v1alpha3
 
 import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	types "k8s.io/apimachinery/pkg/types"
 )
 
 // ResourceClaimConsumerReferenceApplyConfiguration represents a declarative configuration of the ResourceClaimConsumerReference type for use
 // with apply.
 type ResourceClaimConsumerReferenceApplyConfiguration struct {
-	APIGroup *string    `json:"apiGroup,omitempty"`
-	Resource *string    `json:"resource,omitempty"`
-	Name     *string    `json:"name,omitempty"`
-	UID      *types.UID `json:"uid,omitempty"`
+	APIGroup            *string    `json:"apiGroup,omitempty"`
+	Resource            *string    `json:"resource,omitempty"`
+	Name                *string    `json:"name,omitempty"`
+	UID                 *types.UID `json:"uid,omitempty"`
+	ReservationDeadline *v1.Time   `json:"reservationDeadline,omitempty"`
+	DeviceIndices       []int32    `json:"deviceIndices,omitempty"`
 }
 
 // ResourceClaimConsumerReferenceApplyConfiguration constructs a declarative configuration of the ResourceClaimConsumerReference type for use with
@@ -68,3 +71,21 @@ func (b *ResourceClaimConsumerReferenceApplyConfiguration) WithUID(value types.U
 	b.UID = &value
 	return b
 }
+
+// WithReservationDeadline sets the ReservationDeadline field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ReservationDeadline field is set to the value of the last call.
+func (b *ResourceClaimConsumerReferenceApplyConfiguration) WithReservationDeadline(value v1.Time) *ResourceClaimConsumerReferenceApplyConfiguration {
+	b.ReservationDeadline = &value
+	return b
+}
+
+// WithDeviceIndices adds the given value to the DeviceIndices field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the DeviceIndices field.
+func (b *ResourceClaimConsumerReferenceApplyConfiguration) WithDeviceIndices(values ...int32) *ResourceClaimConsumerReferenceApplyConfiguration {
+	for i := range values {
+		b.DeviceIndices = append(b.DeviceIndices, values[i])
+	}
+	return b
+}
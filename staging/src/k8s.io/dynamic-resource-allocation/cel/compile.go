@@ -44,18 +44,38 @@ const (
 	driverVar     = "driver"
 	attributesVar = "attributes"
 	capacityVar   = "capacity"
+	podVar        = "pod"
+	labelsVar     = "labels"
+
+	// podLabelsMaxElements is only used for estimating the cost of
+	// expressions. Pods are not limited to that many labels.
+	podLabelsMaxElements = 64
 )
 
 var (
-	lazyCompilerInit sync.Once
-	lazyCompiler     *compiler
+	lazyCompilersMutex sync.Mutex
+	lazyCompilers      = make(map[Features]*compiler)
 )
 
-func GetCompiler() *compiler {
-	lazyCompilerInit.Do(func() {
-		lazyCompiler = newCompiler()
-	})
-	return lazyCompiler
+// Features determines which optional parts of the CEL environment are
+// available to new expressions. Stored expressions always have access
+// to all of them.
+type Features struct {
+	// PodLabels makes pod.labels available, see Device.PodLabels.
+	PodLabels bool
+}
+
+// GetCompiler returns the compiler for the given features. It gets
+// created once and then reused.
+func GetCompiler(features Features) *compiler {
+	lazyCompilersMutex.Lock()
+	defer lazyCompilersMutex.Unlock()
+	compiler := lazyCompilers[features]
+	if compiler == nil {
+		compiler = newCompiler(features)
+		lazyCompilers[features] = compiler
+	}
+	return compiler
 }
 
 // CompilationResult represents a compiled expression.
@@ -67,6 +87,7 @@ type CompilationResult struct {
 	Environment *cel.Env
 
 	emptyMapVal ref.Val
+	ast         *cel.Ast
 }
 
 // Device defines the input values for a CEL selector expression.
@@ -77,14 +98,19 @@ type Device struct {
 	Driver     string
 	Attributes map[resourceapi.QualifiedName]resourceapi.DeviceAttribute
 	Capacity   map[resourceapi.QualifiedName]resource.Quantity
+
+	// PodLabels are the labels of the pod for which the device gets
+	// checked. They are available as pod.labels. Nil is treated
+	// like a pod without labels.
+	PodLabels map[string]string
 }
 
 type compiler struct {
 	envset *environment.EnvSet
 }
 
-func newCompiler() *compiler {
-	return &compiler{envset: mustBuildEnv()}
+func newCompiler(features Features) *compiler {
+	return &compiler{envset: mustBuildEnv(features)}
 }
 
 // CompileCELExpression returns a compiled CEL expression. It evaluates to bool.
@@ -132,6 +158,7 @@ func (c compiler) CompileCELExpression(expression string, envType environment.Ty
 		OutputType:  ast.OutputType(),
 		Environment: env,
 		emptyMapVal: env.CELTypeAdapter().NativeToValue(map[string]any{}),
+		ast:         ast,
 	}
 }
 
@@ -152,6 +179,10 @@ func getAttributeValue(attr resourceapi.DeviceAttribute) (any, error) {
 			return nil, fmt.Errorf("parse semantic version: %w", err)
 		}
 		return Semver{Version: v}, nil
+	case attr.StringListValue != nil:
+		// Becomes a CEL list, so selectors can check for
+		// an entry with "in".
+		return attr.StringListValue, nil
 	default:
 		return nil, errors.New("unsupported attribute value")
 	}
@@ -159,6 +190,21 @@ func getAttributeValue(attr resourceapi.DeviceAttribute) (any, error) {
 
 var boolType = reflect.TypeOf(true)
 
+// MissingAttributeError is returned by DeviceMatches when evaluating the
+// expression failed after it looked up an attribute or capacity which the
+// device does not have. Err is the CEL runtime error.
+type MissingAttributeError struct {
+	Err error
+}
+
+func (e *MissingAttributeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *MissingAttributeError) Unwrap() error {
+	return e.Err
+}
+
 func (c CompilationResult) DeviceMatches(ctx context.Context, input Device) (bool, error) {
 	// TODO (future): avoid building these maps and instead use a proxy
 	// which wraps the underlying maps and directly looks up values.
@@ -184,16 +230,35 @@ func (c CompilationResult) DeviceMatches(ctx context.Context, input Device) (boo
 		capacity[domain].(map[string]apiservercel.Quantity)[id] = apiservercel.Quantity{Quantity: &quantity}
 	}
 
+	// CEL does not have a typed error for looking up a key which
+	// a map does not have. Instead, the maps of each domain record
+	// such lookups.
+	adapter := c.Environment.CELTypeAdapter()
+	lookupFailed := false
+	for _, values := range []map[string]any{attributes, capacity} {
+		for domain, value := range values {
+			values[domain] = recordingMapper{Mapper: adapter.NativeToValue(value).(traits.Mapper), lookupFailed: &lookupFailed}
+		}
+	}
+	emptyMapVal := recordingMapper{Mapper: c.emptyMapVal.(traits.Mapper), lookupFailed: &lookupFailed}
+
 	variables := map[string]any{
 		deviceVar: map[string]any{
 			driverVar:     input.Driver,
-			attributesVar: newStringInterfaceMapWithDefault(c.Environment.CELTypeAdapter(), attributes, c.emptyMapVal),
-			capacityVar:   newStringInterfaceMapWithDefault(c.Environment.CELTypeAdapter(), capacity, c.emptyMapVal),
+			attributesVar: newStringInterfaceMapWithDefault(adapter, attributes, emptyMapVal),
+			capacityVar:   newStringInterfaceMapWithDefault(adapter, capacity, emptyMapVal),
+		},
+		podVar: map[string]any{
+			labelsVar: podLabels(input.PodLabels),
 		},
 	}
 
 	result, _, err := c.Program.ContextEval(ctx, variables)
 	if err != nil {
+		var celErr *types.Err
+		if lookupFailed && errors.As(err, &celErr) {
+			return false, &MissingAttributeError{Err: err}
+		}
 		return false, err
 	}
 	resultAny, err := result.ConvertToNative(boolType)
@@ -207,7 +272,16 @@ func (c CompilationResult) DeviceMatches(ctx context.Context, input Device) (boo
 	return resultBool, nil
 }
 
-func mustBuildEnv() *environment.EnvSet {
+// podLabels returns a non-nil map because looking up a key in a nil
+// map would fail with a type error instead of "no such key".
+func podLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return map[string]string{}
+	}
+	return labels
+}
+
+func mustBuildEnv(features Features) *environment.EnvSet {
 	envset := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), false /* strictCost */)
 	field := func(name string, declType *apiservercel.DeclType, required bool) *apiservercel.DeclField {
 		return apiservercel.NewDeclField(name, declType, required, nil, nil)
@@ -224,6 +298,9 @@ func mustBuildEnv() *environment.EnvSet {
 		field(attributesVar, apiservercel.NewMapType(apiservercel.StringType, apiservercel.NewMapType(apiservercel.StringType, apiservercel.AnyType, resourceapi.ResourceSliceMaxAttributesAndCapacitiesPerDevice), resourceapi.ResourceSliceMaxAttributesAndCapacitiesPerDevice), true),
 		field(capacityVar, apiservercel.NewMapType(apiservercel.StringType, apiservercel.NewMapType(apiservercel.StringType, apiservercel.QuantityDeclType, resourceapi.ResourceSliceMaxAttributesAndCapacitiesPerDevice), resourceapi.ResourceSliceMaxAttributesAndCapacitiesPerDevice), true),
 	))
+	podType := apiservercel.NewObjectType("kubernetes.DRAPod", fields(
+		field(labelsVar, apiservercel.NewMapType(apiservercel.StringType, apiservercel.StringType, podLabelsMaxElements), true),
+	))
 
 	versioned := []environment.VersionedOptions{
 		{
@@ -250,6 +327,21 @@ func mustBuildEnv() *environment.EnvSet {
 				deviceType,
 			},
 		},
+		{
+			// New expressions may only use pod.labels when the
+			// feature is enabled. Stored expressions keep
+			// working without it.
+			IntroducedVersion: version.MajorMinor(1, 31),
+			FeatureEnabled: func() bool {
+				return features.PodLabels
+			},
+			EnvOptions: []cel.EnvOption{
+				cel.Variable(podVar, podType.CelType()),
+			},
+			DeclTypes: []*apiservercel.DeclType{
+				podType,
+			},
+		},
 	}
 	envset, err := envset.Extend(versioned...)
 	if err != nil {
@@ -293,3 +385,18 @@ func (m mapper) Find(key ref.Val) (ref.Val, bool) {
 
 	return m.defaultValue, true
 }
+
+// recordingMapper wraps the mapper's Find so that looking up a key which
+// the map does not have is recorded.
+type recordingMapper struct {
+	traits.Mapper
+	lookupFailed *bool
+}
+
+func (m recordingMapper) Find(key ref.Val) (ref.Val, bool) {
+	value, found := m.Mapper.Find(key)
+	if !found {
+		*m.lookupFailed = true
+	}
+	return value, found
+}
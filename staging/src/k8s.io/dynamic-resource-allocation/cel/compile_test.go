@@ -17,6 +17,7 @@ limitations under the License.
 package cel
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
@@ -33,8 +34,10 @@ func TestCompile(t *testing.T) {
 		driver             string
 		attributes         map[resourceapi.QualifiedName]resourceapi.DeviceAttribute
 		capacity           map[resourceapi.QualifiedName]resource.Quantity
+		podLabels          map[string]string
 		expectCompileError string
 		expectMatchError   string
+		expectMissing      bool
 		expectMatch        bool
 	}{
 		"true": {
@@ -56,10 +59,29 @@ func TestCompile(t *testing.T) {
 		"runtime-error-lookup-identifier": {
 			expression:       `device.attributes["no-such-domain"].noSuchAttr.isGreaterThan(quantity("0"))`,
 			expectMatchError: "no such key: noSuchAttr",
+			expectMissing:    true,
 		},
 		"runtime-error-lookup-map": {
 			expression:       `device.attributes["no-such-domain"]["noSuchAttr"].isGreaterThan(quantity("0"))`,
 			expectMatchError: "no such key: noSuchAttr",
+			expectMissing:    true,
+		},
+		"runtime-error-lookup-existing-domain": {
+			expression:       `device.attributes["dra.example.com"].noSuchAttr`,
+			attributes:       map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"dra.example.com/something": {BoolValue: ptr.To(true)}},
+			expectMatchError: "no such key: noSuchAttr",
+			expectMissing:    true,
+		},
+		"runtime-error-lookup-capacity": {
+			expression:       `device.capacity["dra.example.com"].noSuchCapacity.isGreaterThan(quantity("0"))`,
+			capacity:         map[resourceapi.QualifiedName]resource.Quantity{"dra.example.com/memory": resource.MustParse("1Gi")},
+			expectMatchError: "no such key: noSuchCapacity",
+			expectMissing:    true,
+		},
+		"presence-check-missing-attribute": {
+			expression:  `has(device.attributes["dra.example.com"].noSuchAttr) || device.attributes["dra.example.com"].something`,
+			attributes:  map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"dra.example.com/something": {BoolValue: ptr.To(true)}},
+			expectMatch: true,
 		},
 		"domain-check-negative": {
 			expression:  `"no-such-domain" in device.attributes`,
@@ -178,10 +200,44 @@ device.attributes["dra.example.com"]["version"].isGreaterThan(semver("0.0.1"))
 			driver:      "dra.example.com",
 			expectMatch: true,
 		},
+		"string-list": {
+			expression:  `"fp16" in device.attributes["dra.example.com"].precisions`,
+			attributes:  map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"precisions": {StringListValue: []string{"fp16", "fp32"}}},
+			driver:      "dra.example.com",
+			expectMatch: true,
+		},
+		"string-list-no-match": {
+			expression:  `"bf16" in device.attributes["dra.example.com"].precisions`,
+			attributes:  map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"precisions": {StringListValue: []string{"fp16", "fp32"}}},
+			driver:      "dra.example.com",
+			expectMatch: false,
+		},
+		"pod-label": {
+			expression:  `pod.labels["team"] == device.attributes["dra.example.com"].team`,
+			attributes:  map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"team": {StringValue: ptr.To("a")}},
+			driver:      "dra.example.com",
+			podLabels:   map[string]string{"team": "a"},
+			expectMatch: true,
+		},
+		"pod-label-other-value": {
+			expression:  `pod.labels["team"] == device.attributes["dra.example.com"].team`,
+			attributes:  map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"team": {StringValue: ptr.To("a")}},
+			driver:      "dra.example.com",
+			podLabels:   map[string]string{"team": "b"},
+			expectMatch: false,
+		},
+		"pod-label-missing": {
+			expression:       `pod.labels["team"] == "a"`,
+			expectMatchError: "no such key: team",
+		},
+		"pod-label-check": {
+			expression:  `"team" in pod.labels`,
+			expectMatch: false,
+		},
 	} {
 		t.Run(name, func(t *testing.T) {
 			_, ctx := ktesting.NewTestContext(t)
-			result := GetCompiler().CompileCELExpression(scenario.expression, environment.StoredExpressions)
+			result := GetCompiler(Features{}).CompileCELExpression(scenario.expression, environment.StoredExpressions)
 			if scenario.expectCompileError != "" && result.Error == nil {
 				t.Fatalf("expected compile error %q, got none", scenario.expectCompileError)
 			}
@@ -194,7 +250,7 @@ device.attributes["dra.example.com"]["version"].isGreaterThan(semver("0.0.1"))
 				}
 				return
 			}
-			match, err := result.DeviceMatches(ctx, Device{Attributes: scenario.attributes, Capacity: scenario.capacity, Driver: scenario.driver})
+			match, err := result.DeviceMatches(ctx, Device{Attributes: scenario.attributes, Capacity: scenario.capacity, Driver: scenario.driver, PodLabels: scenario.podLabels})
 			if err != nil {
 				if scenario.expectMatchError == "" {
 					t.Fatalf("unexpected evaluation error: %v", err)
@@ -202,6 +258,10 @@ device.attributes["dra.example.com"]["version"].isGreaterThan(semver("0.0.1"))
 				if !strings.Contains(err.Error(), scenario.expectMatchError) {
 					t.Fatalf("expected evaluation error to contain %q, but got instead: %v", scenario.expectMatchError, err)
 				}
+				var missingErr *MissingAttributeError
+				if errors.As(err, &missingErr) != scenario.expectMissing {
+					t.Fatalf("expected MissingAttributeError %v, got instead: %T", scenario.expectMissing, err)
+				}
 				return
 			}
 			if match != scenario.expectMatch {
@@ -210,3 +270,79 @@ device.attributes["dra.example.com"]["version"].isGreaterThan(semver("0.0.1"))
 		})
 	}
 }
+
+func TestPodLabelsFeature(t *testing.T) {
+	expression := `pod.labels["team"] == "a"`
+	for name, scenario := range map[string]struct {
+		features           Features
+		envType            environment.Type
+		expectCompileError string
+	}{
+		"new-disabled": {
+			envType:            environment.NewExpressions,
+			expectCompileError: "undeclared reference to 'pod'",
+		},
+		"new-enabled": {
+			features: Features{PodLabels: true},
+			envType:  environment.NewExpressions,
+		},
+		"stored-disabled": {
+			envType: environment.StoredExpressions,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			result := GetCompiler(scenario.features).CompileCELExpression(expression, scenario.envType)
+			if scenario.expectCompileError == "" {
+				if result.Error != nil {
+					t.Fatalf("unexpected compile error: %v", result.Error)
+				}
+				return
+			}
+			if result.Error == nil {
+				t.Fatalf("expected compile error %q, got none", scenario.expectCompileError)
+			}
+			if !strings.Contains(result.Error.Error(), scenario.expectCompileError) {
+				t.Fatalf("expected compile error to contain %q, but got instead: %v", scenario.expectCompileError, result.Error)
+			}
+		})
+	}
+}
+
+func TestDisallowedFunction(t *testing.T) {
+	for name, scenario := range map[string]struct {
+		expression       string
+		allowedGroups    []string
+		expectDisallowed string
+	}{
+		"standard-only": {
+			expression: `device.driver == "dra.example.com" && size(device.attributes) > 0`,
+		},
+		"regex-disallowed": {
+			expression:       `device.driver.matches("^dra")`,
+			expectDisallowed: "matches",
+		},
+		"regex-allowed": {
+			expression:    `device.driver.matches("^dra")`,
+			allowedGroups: []string{"regex"},
+		},
+		"nested": {
+			expression:       `device.driver == "dra.example.com" && device.driver.lowerAscii().matches("^dra")`,
+			allowedGroups:    []string{"regex"},
+			expectDisallowed: "lowerAscii",
+		},
+		"name-in-several-groups": {
+			expression:    `device.attributes["dra.example.com"].version.isGreaterThan(semver("1.0.0"))`,
+			allowedGroups: []string{"semver"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			result := GetCompiler(Features{}).CompileCELExpression(scenario.expression, environment.StoredExpressions)
+			if result.Error != nil {
+				t.Fatalf("unexpected compile error: %v", result.Error)
+			}
+			if actual := result.DisallowedFunction(scenario.allowedGroups); actual != scenario.expectDisallowed {
+				t.Errorf("expected disallowed function %q, got %q", scenario.expectDisallowed, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cel
+
+import (
+	"sort"
+
+	celast "github.com/google/cel-go/common/ast"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// functionGroups maps the name of a group of optional CEL functions to the
+// names of the functions in it. Some names are in more than one group
+// because different libraries define functions with the same name for
+// different types. Operators, macros and the other functions of the
+// standard CEL library are always allowed.
+var functionGroups = map[string][]string{
+	// Regular expressions can be expensive to evaluate.
+	"regex":    {"find", "findAll", "matches"},
+	"strings":  {"charAt", "format", "indexOf", "join", "lastIndexOf", "lowerAscii", "replace", "reverse", "split", "strings.quote", "substring", "trim", "upperAscii"},
+	"lists":    {"indexOf", "isSorted", "lastIndexOf", "max", "min", "sum"},
+	"sets":     {"sets.contains", "sets.equivalent", "sets.intersects"},
+	"quantity": {"add", "asApproximateFloat", "asInteger", "compareTo", "isGreaterThan", "isInteger", "isLessThan", "isQuantity", "quantity", "sign", "sub"},
+	"semver":   {"compareTo", "isGreaterThan", "isLessThan", "isSemver", "major", "minor", "patch", "semver"},
+	"network":  {"cidr", "containsCIDR", "containsIP", "family", "getEscapedPath", "getHost", "getHostname", "getPort", "getQuery", "getScheme", "ip", "isCIDR", "isGlobalUnicast", "isIP", "isLinkLocalMulticast", "isLinkLocalUnicast", "isLoopback", "isUnspecified", "isURL", "masked", "prefixLength", "url"},
+}
+
+// FunctionGroups returns the sorted names of the groups of optional
+// functions which can be allowed with [CompilationResult.DisallowedFunction].
+func FunctionGroups() []string {
+	groups := make([]string, 0, len(functionGroups))
+	for group := range functionGroups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// DisallowedFunction returns the name of the first function called by the
+// expression which is in none of the allowed groups but in some other
+// group. Functions which are not in any group are always allowed. Returns
+// an empty string if all functions are allowed or the expression was not
+// compiled successfully.
+func (c CompilationResult) DisallowedFunction(allowedGroups []string) string {
+	if c.ast == nil {
+		return ""
+	}
+	allowed := sets.New[string]()
+	restricted := sets.New[string]()
+	for group, functions := range functionGroups {
+		restricted.Insert(functions...)
+		if sets.New(allowedGroups...).Has(group) {
+			allowed.Insert(functions...)
+		}
+	}
+	var disallowed string
+	celast.PostOrderVisit(c.ast.NativeRep().Expr(), celast.NewExprVisitor(func(expr celast.Expr) {
+		if disallowed != "" || expr.Kind() != celast.CallKind {
+			return
+		}
+		name := expr.AsCall().FunctionName()
+		if restricted.Has(name) && !allowed.Has(name) {
+			disallowed = name
+		}
+	}))
+	return disallowed
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourceclaim
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+)
+
+// AllocationSummary describes the allocated devices on a single line,
+// for example "some-driver: instance-1@worker (req-1)". Devices are
+// listed as <device>@<pool> in the order of the allocation result.
+//
+// The spec of the claim is optional. If given, devices for requests
+// with admin access are marked as such.
+func AllocationSummary(allocation *resourceapi.AllocationResult, spec *resourceapi.ResourceClaimSpec) string {
+	if allocation == nil || len(allocation.Devices.Results) == 0 {
+		return "no devices"
+	}
+	var parts []string
+	for _, result := range allocation.Devices.Results {
+		request := result.Request
+		if isAdminAccess(spec, result.Request) {
+			request += ", admin access"
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s@%s (%s)", result.Driver, result.Device, result.Pool, request))
+	}
+	summary := strings.Join(parts, ", ")
+	switch len(allocation.Devices.Config) {
+	case 0:
+	case 1:
+		summary += " with 1 configuration"
+	default:
+		summary += fmt.Sprintf(" with %d configurations", len(allocation.Devices.Config))
+	}
+	return summary
+}
+
+// AllocationDetails describes the allocation result with one line per
+// allocated device and per configuration. The spec is optional, as for
+// AllocationSummary.
+func AllocationDetails(allocation *resourceapi.AllocationResult, spec *resourceapi.ResourceClaimSpec) string {
+	if allocation == nil || len(allocation.Devices.Results) == 0 {
+		return "no devices"
+	}
+	var lines []string
+	for _, result := range allocation.Devices.Results {
+		line := fmt.Sprintf("request %s: driver %s, pool %s, device %s", result.Request, result.Driver, result.Pool, result.Device)
+		if isAdminAccess(spec, result.Request) {
+			line += ", admin access"
+		}
+		if len(result.ConsumedCapacity) > 0 {
+			var capacity []string
+			for name, quantity := range result.ConsumedCapacity {
+				capacity = append(capacity, fmt.Sprintf("%s=%s", name, quantity.String()))
+			}
+			slices.Sort(capacity)
+			line += ", consumed capacity " + strings.Join(capacity, " ")
+		}
+		lines = append(lines, line)
+	}
+	for _, config := range allocation.Devices.Config {
+		requests := "all requests"
+		if len(config.Requests) > 0 {
+			requests = "requests " + strings.Join(config.Requests, ", ")
+		}
+		line := fmt.Sprintf("config %s for %s", config.Source, requests)
+		if config.Opaque != nil {
+			line += fmt.Sprintf(": opaque parameters for driver %s", config.Opaque.Driver)
+		}
+		lines = append(lines, line)
+	}
+	if allocation.Controller != "" {
+		lines = append(lines, fmt.Sprintf("allocated by controller %s", allocation.Controller))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isAdminAccess(spec *resourceapi.ResourceClaimSpec, requestName string) bool {
+	if spec == nil {
+		return false
+	}
+	for _, request := range spec.Devices.Requests {
+		if request.Name == requestName {
+			return request.AdminAccess
+		}
+	}
+	return false
+}
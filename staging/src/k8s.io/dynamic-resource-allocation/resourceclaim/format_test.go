@@ -0,0 +1,138 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourceclaim
+
+import (
+	"testing"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestFormatAllocation(t *testing.T) {
+	device := func(request, device string) resourceapi.DeviceRequestAllocationResult {
+		return resourceapi.DeviceRequestAllocationResult{Request: request, Driver: "some-driver", Pool: "worker", Device: device}
+	}
+
+	for name, tc := range map[string]struct {
+		allocation    *resourceapi.AllocationResult
+		spec          *resourceapi.ResourceClaimSpec
+		expectSummary string
+		expectDetails string
+	}{
+		"nil": {
+			expectSummary: "no devices",
+			expectDetails: "no devices",
+		},
+		"empty": {
+			allocation:    &resourceapi.AllocationResult{},
+			expectSummary: "no devices",
+			expectDetails: "no devices",
+		},
+		"one-device": {
+			allocation: &resourceapi.AllocationResult{
+				Devices: resourceapi.DeviceAllocationResult{
+					Results: []resourceapi.DeviceRequestAllocationResult{device("req-1", "instance-1")},
+				},
+			},
+			expectSummary: "some-driver: instance-1@worker (req-1)",
+			expectDetails: "request req-1: driver some-driver, pool worker, device instance-1",
+		},
+		"multiple-requests": {
+			allocation: &resourceapi.AllocationResult{
+				Devices: resourceapi.DeviceAllocationResult{
+					Results: []resourceapi.DeviceRequestAllocationResult{device("req-1", "instance-1"), device("req-2", "instance-2")},
+				},
+			},
+			expectSummary: "some-driver: instance-1@worker (req-1), some-driver: instance-2@worker (req-2)",
+			expectDetails: "request req-1: driver some-driver, pool worker, device instance-1\n" +
+				"request req-2: driver some-driver, pool worker, device instance-2",
+		},
+		"admin-access": {
+			allocation: &resourceapi.AllocationResult{
+				Devices: resourceapi.DeviceAllocationResult{
+					Results: []resourceapi.DeviceRequestAllocationResult{device("req-1", "instance-1")},
+				},
+			},
+			spec: &resourceapi.ResourceClaimSpec{
+				Devices: resourceapi.DeviceClaim{
+					Requests: []resourceapi.DeviceRequest{{Name: "req-1", AdminAccess: true}},
+				},
+			},
+			expectSummary: "some-driver: instance-1@worker (req-1, admin access)",
+			expectDetails: "request req-1: driver some-driver, pool worker, device instance-1, admin access",
+		},
+		"consumed-capacity": {
+			allocation: &resourceapi.AllocationResult{
+				Devices: resourceapi.DeviceAllocationResult{
+					Results: []resourceapi.DeviceRequestAllocationResult{{
+						Request: "req-1", Driver: "some-driver", Pool: "worker", Device: "instance-1",
+						ConsumedCapacity: map[resourceapi.QualifiedName]resource.Quantity{
+							"memory": resource.MustParse("1Gi"),
+							"cores":  resource.MustParse("2"),
+						},
+					}},
+				},
+			},
+			expectSummary: "some-driver: instance-1@worker (req-1)",
+			expectDetails: "request req-1: driver some-driver, pool worker, device instance-1, consumed capacity cores=2 memory=1Gi",
+		},
+		"configs": {
+			allocation: &resourceapi.AllocationResult{
+				Devices: resourceapi.DeviceAllocationResult{
+					Results: []resourceapi.DeviceRequestAllocationResult{device("req-1", "instance-1")},
+					Config: []resourceapi.DeviceAllocationConfiguration{
+						{
+							Source: resourceapi.AllocationConfigSourceClass,
+							DeviceConfiguration: resourceapi.DeviceConfiguration{
+								Opaque: &resourceapi.OpaqueDeviceConfiguration{Driver: "some-driver"},
+							},
+						},
+						{
+							Source:   resourceapi.AllocationConfigSourceClaim,
+							Requests: []string{"req-1"},
+						},
+					},
+				},
+			},
+			expectSummary: "some-driver: instance-1@worker (req-1) with 2 configurations",
+			expectDetails: "request req-1: driver some-driver, pool worker, device instance-1\n" +
+				"config FromClass for all requests: opaque parameters for driver some-driver\n" +
+				"config FromClaim for requests req-1",
+		},
+		"controller": {
+			allocation: &resourceapi.AllocationResult{
+				Controller: "some-driver",
+				Devices: resourceapi.DeviceAllocationResult{
+					Results: []resourceapi.DeviceRequestAllocationResult{device("req-1", "instance-1")},
+				},
+			},
+			expectSummary: "some-driver: instance-1@worker (req-1)",
+			expectDetails: "request req-1: driver some-driver, pool worker, device instance-1\n" +
+				"allocated by controller some-driver",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if summary := AllocationSummary(tc.allocation, tc.spec); summary != tc.expectSummary {
+				t.Errorf("expected summary %q, got %q", tc.expectSummary, summary)
+			}
+			if details := AllocationDetails(tc.allocation, tc.spec); details != tc.expectDetails {
+				t.Errorf("expected details:\n%s\ngot:\n%s", tc.expectDetails, details)
+			}
+		})
+	}
+}
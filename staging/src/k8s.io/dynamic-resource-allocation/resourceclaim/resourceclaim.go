@@ -26,6 +26,8 @@ package resourceclaim
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	resourceapi "k8s.io/api/resource/v1alpha3"
@@ -94,15 +96,45 @@ func IsForPod(pod *v1.Pod, claim *resourceapi.ResourceClaim) error {
 // that the claim was reserved for.
 func IsReservedForPod(pod *v1.Pod, claim *resourceapi.ResourceClaim) bool {
 	for _, reserved := range claim.Status.ReservedFor {
-		if reserved.UID == pod.UID {
+		if IsPodReference(reserved) && reserved.UID == pod.UID {
 			return true
 		}
 	}
 	return false
 }
 
+// IsReservationExpired checks whether the claim is reserved for the pod
+// with a ReservationDeadline that has passed at the given time. A pod which
+// is already bound to a node uses the claim, so its reservation does not
+// expire anymore.
+func IsReservationExpired(claim *resourceapi.ResourceClaim, pod *v1.Pod, now time.Time) bool {
+	if pod.Spec.NodeName != "" {
+		return false
+	}
+	for _, reserved := range claim.Status.ReservedFor {
+		if IsPodReference(reserved) && reserved.UID == pod.UID {
+			return reserved.ReservationDeadline != nil && now.After(reserved.ReservationDeadline.Time)
+		}
+	}
+	return false
+}
+
+// UsesDevice checks whether the consumer uses the allocated device with the
+// given index in status.allocation.devices.results. Consumers without
+// DeviceIndices use all devices.
+func UsesDevice(reference resourceapi.ResourceClaimConsumerReference, index int) bool {
+	return len(reference.DeviceIndices) == 0 || slices.Contains(reference.DeviceIndices, int32(index))
+}
+
+// IsPodReference checks whether a consumer reference points to a pod.
+// Other consumers are valid, but opaque: their presence keeps a claim
+// in use and they must never be removed by code which manages pods.
+func IsPodReference(reference resourceapi.ResourceClaimConsumerReference) bool {
+	return reference.APIGroup == "" && reference.Resource == "pods"
+}
+
 // CanBeReserved checks whether the claim could be reserved for another object.
+// All consumers count towards the limit, regardless of their type.
 func CanBeReserved(claim *resourceapi.ResourceClaim) bool {
-	// Currently no restrictions on sharing...
-	return true
+	return len(claim.Status.ReservedFor) < resourceapi.ResourceClaimReservedForMaxSize
 }
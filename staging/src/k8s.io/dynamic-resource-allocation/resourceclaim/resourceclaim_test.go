@@ -19,6 +19,7 @@ package resourceclaim
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	resourceapi "k8s.io/api/resource/v1alpha3"
@@ -125,3 +126,133 @@ func TestResourceClaimIsForPod(t *testing.T) {
 		})
 	}
 }
+
+func TestIsReservedForPod(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "kube-system",
+			Name:      "pod",
+			UID:       "pod-uid",
+		},
+	}
+	podReference := resourceapi.ResourceClaimConsumerReference{Resource: "pods", Name: pod.Name, UID: pod.UID}
+	otherReference := resourceapi.ResourceClaimConsumerReference{APIGroup: "example.com", Resource: "widgets", Name: "widget", UID: "widget-uid"}
+
+	testcases := map[string]struct {
+		reservedFor []resourceapi.ResourceClaimConsumerReference
+		expected    bool
+	}{
+		"empty": {},
+		"pod": {
+			reservedFor: []resourceapi.ResourceClaimConsumerReference{podReference},
+			expected:    true,
+		},
+		"other-consumer": {
+			reservedFor: []resourceapi.ResourceClaimConsumerReference{otherReference},
+		},
+		"other-consumer-and-pod": {
+			reservedFor: []resourceapi.ResourceClaimConsumerReference{otherReference, podReference},
+			expected:    true,
+		},
+		"other-consumer-with-same-uid": {
+			reservedFor: func() []resourceapi.ResourceClaimConsumerReference {
+				reference := otherReference
+				reference.UID = pod.UID
+				return []resourceapi.ResourceClaimConsumerReference{reference}
+			}(),
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			claim := &resourceapi.ResourceClaim{Status: resourceapi.ResourceClaimStatus{ReservedFor: tc.reservedFor}}
+			if actual := IsReservedForPod(pod, claim); actual != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsReservationExpired(t *testing.T) {
+	now := time.Now()
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "kube-system",
+			Name:      "pod",
+			UID:       "pod-uid",
+		},
+	}
+	boundPod := pod.DeepCopy()
+	boundPod.Spec.NodeName = "worker"
+	podReference := func(deadline *metav1.Time) resourceapi.ResourceClaimConsumerReference {
+		return resourceapi.ResourceClaimConsumerReference{Resource: "pods", Name: pod.Name, UID: pod.UID, ReservationDeadline: deadline}
+	}
+	past := metav1.NewTime(now.Add(-time.Minute))
+	future := metav1.NewTime(now.Add(time.Minute))
+
+	testcases := map[string]struct {
+		pod         *v1.Pod
+		reservedFor []resourceapi.ResourceClaimConsumerReference
+		expected    bool
+	}{
+		"not-reserved": {
+			pod: pod,
+		},
+		"no-deadline": {
+			pod:         pod,
+			reservedFor: []resourceapi.ResourceClaimConsumerReference{podReference(nil)},
+		},
+		"before-deadline": {
+			pod:         pod,
+			reservedFor: []resourceapi.ResourceClaimConsumerReference{podReference(&future)},
+		},
+		"after-deadline": {
+			pod:         pod,
+			reservedFor: []resourceapi.ResourceClaimConsumerReference{podReference(&past)},
+			expected:    true,
+		},
+		"bound-after-deadline": {
+			pod:         boundPod,
+			reservedFor: []resourceapi.ResourceClaimConsumerReference{podReference(&past)},
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			claim := &resourceapi.ResourceClaim{Status: resourceapi.ResourceClaimStatus{ReservedFor: tc.reservedFor}}
+			if actual := IsReservationExpired(claim, tc.pod, now); actual != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUsesDevice(t *testing.T) {
+	all := resourceapi.ResourceClaimConsumerReference{Resource: "pods", Name: "pod", UID: "pod-uid"}
+	some := all
+	some.DeviceIndices = []int32{1}
+
+	if !UsesDevice(all, 0) || !UsesDevice(all, 1) {
+		t.Error("consumer without device indices should use all devices")
+	}
+	if UsesDevice(some, 0) {
+		t.Error("consumer should not use device #0")
+	}
+	if !UsesDevice(some, 1) {
+		t.Error("consumer should use device #1")
+	}
+}
+
+func TestCanBeReserved(t *testing.T) {
+	claim := &resourceapi.ResourceClaim{}
+	for i := 0; i < resourceapi.ResourceClaimReservedForMaxSize; i++ {
+		if !CanBeReserved(claim) {
+			t.Fatalf("claim with %d consumers should be reservable", i)
+		}
+		// Non-pod consumers count towards the limit, too.
+		claim.Status.ReservedFor = append(claim.Status.ReservedFor, resourceapi.ResourceClaimConsumerReference{APIGroup: "example.com", Resource: "widgets", Name: fmt.Sprintf("widget-%d", i), UID: types.UID(fmt.Sprintf("%d", i))})
+	}
+	if CanBeReserved(claim) {
+		t.Errorf("claim with %d consumers should not be reservable", len(claim.Status.ReservedFor))
+	}
+}
@@ -0,0 +1,321 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package structured
+
+import (
+	"maps"
+	"sync"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// AllocatedDevicesLister may be implemented by a ClaimLister in addition to
+// ListAllAllocated. The allocator then uses the snapshot instead of
+// gathering the devices of all allocated claims each time that it runs.
+type AllocatedDevicesLister interface {
+	// ListAllocatedDevices returns the devices which are in use by the
+	// claims that ListAllAllocated would return. Nil means that no
+	// snapshot is available and ListAllAllocated has to be used.
+	ListAllocatedDevices() *AllocatedDevices
+}
+
+// deviceUsage describes how a device is used by allocated claims.
+// The zero value is an unused device.
+type deviceUsage struct {
+	// exclusive is true if at least one claim uses the device
+	// without sharing it.
+	exclusive bool
+
+	// consumed is the sum of the capacity consumed by claims which
+	// share the device, nil if there are none.
+	consumed map[resourceapi.QualifiedName]resource.Quantity
+}
+
+// AllocatedDevices is a snapshot of the devices which are in use by
+// allocated claims. It does not change after it was created and may be
+// used concurrently.
+//
+// Internally, it consists of a base which gets shared with other snapshots
+// and the changes relative to that base. Changed devices which are no
+// longer in use are recorded with their zero value.
+type AllocatedDevices struct {
+	base    map[DeviceID]deviceUsage
+	changes map[DeviceID]deviceUsage
+	len     int
+}
+
+// Len returns the number of devices which are in use.
+func (d *AllocatedDevices) Len() int {
+	return d.len
+}
+
+// InUse returns true if some allocated claim uses the device, either
+// exclusively or by consuming some of its capacity.
+func (d *AllocatedDevices) InUse(deviceID DeviceID) bool {
+	usage := d.usage(deviceID)
+	return usage.exclusive || usage.consumed != nil
+}
+
+func (d *AllocatedDevices) usage(deviceID DeviceID) deviceUsage {
+	if usage, ok := d.changes[deviceID]; ok {
+		return usage
+	}
+	return d.base[deviceID]
+}
+
+// AllocatedDevicesTracker maintains the devices which are in use by
+// allocated claims incrementally. Rebuilding that information for each
+// allocation attempt takes time and creates garbage which is proportional
+// to the number of allocated claims, which matters in large clusters.
+//
+// Claims get identified by an arbitrary key, typically namespace/name.
+// The strings in the device IDs get interned, so a driver, pool or device
+// name is stored only once regardless of how many claims refer to it.
+//
+// All methods are thread-safe.
+type AllocatedDevicesTracker struct {
+	mutex sync.Mutex
+
+	// claims contains the devices allocated for each claim.
+	claims map[string][]claimDevice
+
+	// devices contains only devices which are in use.
+	devices map[DeviceID]*trackedDevice
+
+	// strings contains the interned strings of the device IDs in devices.
+	strings map[string]*internedString
+
+	// base is shared with snapshots and must not be modified.
+	base map[DeviceID]deviceUsage
+
+	// changes are the devices which changed since base was built.
+	changes map[DeviceID]deviceUsage
+
+	// snapshot is the most recent snapshot, nil if something changed
+	// since it was created.
+	snapshot *AllocatedDevices
+}
+
+// claimDevice is one entry in the allocation result of a claim.
+type claimDevice struct {
+	deviceID DeviceID
+	// consumed is nil if the device is not shared.
+	consumed map[resourceapi.QualifiedName]resource.Quantity
+}
+
+type trackedDevice struct {
+	// exclusive is the number of claims which use the device exclusively.
+	exclusive int
+	// shared is the number of claims which consume some of its capacity.
+	shared   int
+	consumed map[resourceapi.QualifiedName]resource.Quantity
+}
+
+func (d *trackedDevice) usage() deviceUsage {
+	return deviceUsage{exclusive: d.exclusive > 0, consumed: d.consumed}
+}
+
+type internedString struct {
+	value string
+	refs  int
+}
+
+// minChanges is the number of changes which are always accepted before
+// the base gets rebuilt.
+const minChanges = 64
+
+// NewAllocatedDevicesTracker returns an empty tracker.
+func NewAllocatedDevicesTracker() *AllocatedDevicesTracker {
+	return &AllocatedDevicesTracker{
+		claims:  make(map[string][]claimDevice),
+		devices: make(map[DeviceID]*trackedDevice),
+		strings: make(map[string]*internedString),
+		base:    make(map[DeviceID]deviceUsage),
+		changes: make(map[DeviceID]deviceUsage),
+	}
+}
+
+// Update replaces the devices recorded for the claim with the given key.
+// A nil claim or one which is not allocated removes all of them.
+func (t *AllocatedDevicesTracker) Update(key string, claim *resourceapi.ResourceClaim) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if old, ok := t.claims[key]; ok {
+		t.release(old)
+		delete(t.claims, key)
+	}
+	if claim == nil || claim.Status.Allocation == nil || len(claim.Status.Allocation.Devices.Results) == 0 {
+		return
+	}
+	devices := make([]claimDevice, 0, len(claim.Status.Allocation.Devices.Results))
+	for _, result := range claim.Status.Allocation.Devices.Results {
+		device := claimDevice{deviceID: DeviceID{Driver: result.Driver, Pool: result.Pool, Device: result.Device}}
+		if len(result.ConsumedCapacity) > 0 {
+			device.consumed = make(map[resourceapi.QualifiedName]resource.Quantity, len(result.ConsumedCapacity))
+			for name, quantity := range result.ConsumedCapacity {
+				device.consumed[name] = quantity.DeepCopy()
+			}
+		}
+		devices = append(devices, device)
+	}
+	t.claims[key] = t.acquire(devices)
+}
+
+// Snapshot returns the devices which are currently in use. The result
+// is shared with other callers and must not be modified.
+func (t *AllocatedDevicesTracker) Snapshot() *AllocatedDevices {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.snapshot == nil {
+		t.snapshot = &AllocatedDevices{
+			base:    t.base,
+			changes: maps.Clone(t.changes),
+			len:     len(t.devices),
+		}
+	}
+	return t.snapshot
+}
+
+// acquire records that a claim uses the devices and returns them with
+// interned device IDs.
+func (t *AllocatedDevicesTracker) acquire(devices []claimDevice) []claimDevice {
+	for i := range devices {
+		deviceID := devices[i].deviceID
+		device := t.devices[deviceID]
+		if device == nil {
+			device = &trackedDevice{}
+			deviceID = DeviceID{
+				Driver: t.intern(deviceID.Driver),
+				Pool:   t.intern(deviceID.Pool),
+				Device: t.intern(deviceID.Device),
+			}
+			t.devices[deviceID] = device
+		} else {
+			deviceID = t.lookupID(deviceID)
+		}
+		devices[i].deviceID = deviceID
+		if devices[i].consumed != nil {
+			device.shared++
+			device.consumed = addCapacity(device.consumed, devices[i].consumed)
+		} else {
+			device.exclusive++
+		}
+		t.changed(deviceID, device)
+	}
+	return devices
+}
+
+// release is the reverse of acquire.
+func (t *AllocatedDevicesTracker) release(devices []claimDevice) {
+	for _, claimDevice := range devices {
+		deviceID := claimDevice.deviceID
+		device := t.devices[deviceID]
+		if device == nil {
+			// Cannot happen.
+			continue
+		}
+		if claimDevice.consumed != nil {
+			device.shared--
+			device.consumed = subtractCapacity(device.consumed, claimDevice.consumed)
+			if device.shared == 0 {
+				device.consumed = nil
+			}
+		} else {
+			device.exclusive--
+		}
+		if device.exclusive == 0 && device.shared == 0 {
+			delete(t.devices, deviceID)
+			t.unintern(deviceID.Driver)
+			t.unintern(deviceID.Pool)
+			t.unintern(deviceID.Device)
+			device = nil
+		}
+		t.changed(deviceID, device)
+	}
+}
+
+// lookupID returns the interned strings for a device which is in use.
+func (t *AllocatedDevicesTracker) lookupID(deviceID DeviceID) DeviceID {
+	return DeviceID{
+		Driver: t.strings[deviceID.Driver].value,
+		Pool:   t.strings[deviceID.Pool].value,
+		Device: t.strings[deviceID.Device].value,
+	}
+}
+
+func (t *AllocatedDevicesTracker) intern(value string) string {
+	interned := t.strings[value]
+	if interned == nil {
+		interned = &internedString{value: value}
+		t.strings[value] = interned
+	}
+	interned.refs++
+	return interned.value
+}
+
+func (t *AllocatedDevicesTracker) unintern(value string) {
+	interned := t.strings[value]
+	if interned == nil {
+		return
+	}
+	interned.refs--
+	if interned.refs == 0 {
+		delete(t.strings, value)
+	}
+}
+
+// changed records the new usage of a device, nil if it is no longer in
+// use. The base gets rebuilt when the changes become too large, which
+// keeps both the time needed for Snapshot and the memory used by the
+// changes proportional to a fraction of the devices in use.
+func (t *AllocatedDevicesTracker) changed(deviceID DeviceID, device *trackedDevice) {
+	t.snapshot = nil
+	var usage deviceUsage
+	if device != nil {
+		usage = device.usage()
+	}
+	if _, inBase := t.base[deviceID]; !inBase && device == nil {
+		delete(t.changes, deviceID)
+	} else {
+		t.changes[deviceID] = usage
+	}
+	if len(t.changes) <= minChanges+len(t.base)/64 {
+		return
+	}
+	t.base = make(map[DeviceID]deviceUsage, len(t.devices))
+	for deviceID, device := range t.devices {
+		t.base[deviceID] = device.usage()
+	}
+	t.changes = make(map[DeviceID]deviceUsage)
+}
+
+// subtractCapacity is the reverse of addCapacity.
+func subtractCapacity(consumed, released map[resourceapi.QualifiedName]resource.Quantity) map[resourceapi.QualifiedName]resource.Quantity {
+	diff := make(map[resourceapi.QualifiedName]resource.Quantity, len(consumed))
+	for name, quantity := range consumed {
+		diff[name] = quantity.DeepCopy()
+	}
+	for name, quantity := range released {
+		total := diff[name]
+		total.Sub(quantity)
+		diff[name] = total
+	}
+	return diff
+}
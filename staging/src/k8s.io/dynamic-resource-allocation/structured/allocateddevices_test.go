@@ -0,0 +1,141 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package structured
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/ktesting"
+)
+
+func TestAllocatedDevicesTracker(t *testing.T) {
+	device1ID := DeviceID{Driver: driverA, Pool: pool1, Device: device1}
+	device2ID := DeviceID{Driver: driverA, Pool: pool1, Device: device2}
+	capacity := map[resourceapi.QualifiedName]resource.Quantity{"memory": resource.MustParse("1Gi")}
+	exclusiveClaim := allocatedClaim(claim0, req0, classA, deviceAllocationResult(req0, driverA, pool1, device1))
+	sharedClaim := allocatedClaim(claim1, req0, classA, sharedDeviceAllocationResult(req0, driverA, pool1, device2, capacity))
+
+	tracker := NewAllocatedDevicesTracker()
+	empty := tracker.Snapshot()
+	assert.Equal(t, 0, empty.Len(), "initial length")
+
+	tracker.Update("exclusive", exclusiveClaim)
+	tracker.Update("shared-1", sharedClaim)
+	tracker.Update("shared-2", sharedClaim)
+	snapshot := tracker.Snapshot()
+	assert.Same(t, snapshot, tracker.Snapshot(), "unchanged snapshot gets reused")
+	assert.Equal(t, 2, snapshot.Len(), "length")
+	assert.True(t, snapshot.usage(device1ID).exclusive, "exclusive device")
+	consumed := snapshot.usage(device2ID).consumed["memory"]
+	assert.Equal(t, "2Gi", consumed.String(), "consumed capacity")
+	assert.False(t, empty.InUse(device1ID), "older snapshot modified")
+
+	tracker.Update("shared-1", nil)
+	consumed = tracker.Snapshot().usage(device2ID).consumed["memory"]
+	assert.Equal(t, "1Gi", consumed.String(), "consumed capacity after removing one claim")
+
+	tracker.Update("exclusive", claim(claim0, req0, classA))
+	tracker.Update("shared-2", nil)
+	assert.Equal(t, 0, tracker.Snapshot().Len(), "final length")
+	assert.True(t, snapshot.InUse(device1ID), "older snapshot modified")
+	assert.Empty(t, tracker.strings, "interned strings")
+	assert.Empty(t, tracker.claims, "claims")
+}
+
+func TestAllocatedDevicesTrackerCompaction(t *testing.T) {
+	tracker := NewAllocatedDevicesTracker()
+	var snapshots []*AllocatedDevices
+	for i := 0; i < 10*minChanges; i++ {
+		tracker.Update(fmt.Sprintf("claim-%d", i), allocatedClaim(claim0, req0, classA, deviceAllocationResult(req0, driverA, pool1, fmt.Sprintf("device-%d", i))))
+		if i%2 == 1 {
+			tracker.Update(fmt.Sprintf("claim-%d", i-1), nil)
+		}
+		snapshots = append(snapshots, tracker.Snapshot())
+	}
+	assert.LessOrEqual(t, len(tracker.changes), minChanges+len(tracker.base)/64, "changes")
+
+	// Each snapshot must still describe the state when it was taken.
+	for i, snapshot := range snapshots {
+		for e := 0; e < 10*minChanges; e++ {
+			deviceID := DeviceID{Driver: driverA, Pool: pool1, Device: fmt.Sprintf("device-%d", e)}
+			expectInUse := e == i || (e%2 == 1 && e < i)
+			require.Equal(t, expectInUse, snapshot.InUse(deviceID), "snapshot %d, %s", i, deviceID)
+		}
+	}
+}
+
+// BenchmarkAllocatedDevices compares gathering the allocated devices from
+// all claims, as the allocator does for each Allocate call without a
+// snapshot, with updating one claim in the tracker and taking a snapshot.
+func BenchmarkAllocatedDevices(b *testing.B) {
+	const numClaims = 100000
+	claims := make([]*resourceapi.ResourceClaim, 0, numClaims)
+	for i := 0; i < numClaims; i++ {
+		claims = append(claims, allocatedClaim(fmt.Sprintf("claim-%d", i), req0, classA, deviceAllocationResult(req0, driverA, fmt.Sprintf("pool-%d", i/100), fmt.Sprintf("device-%d", i%100))))
+	}
+	_, ctx := ktesting.NewTestContext(b)
+
+	b.Run("rebuild", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			alloc := &allocator{
+				Allocator: &Allocator{claimLister: claimLister{claims: claims}},
+				ctx:       ctx,
+				logger:    klog.FromContext(ctx),
+				allocated: make(map[DeviceID]bool),
+				consumed:  make(map[DeviceID]map[resourceapi.QualifiedName]resource.Quantity),
+			}
+			if err := alloc.gatherAllocatedDevices(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("incremental", func(b *testing.B) {
+		tracker := NewAllocatedDevicesTracker()
+		for i, claim := range claims {
+			tracker.Update(fmt.Sprintf("claim-%d", i), claim)
+		}
+		unallocated := claim(claim0, req0, classA)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// One claim gets deallocated and allocated again
+			// between two scheduling cycles.
+			key := fmt.Sprintf("claim-%d", i%numClaims)
+			tracker.Update(key, unallocated)
+			tracker.Update(key, claims[i%numClaims])
+			alloc := &allocator{
+				Allocator: &Allocator{claimLister: snapshotLister{allocatedDevices: tracker.Snapshot()}},
+				ctx:       ctx,
+				logger:    klog.FromContext(ctx),
+				allocated: make(map[DeviceID]bool),
+				consumed:  make(map[DeviceID]map[resourceapi.QualifiedName]resource.Quantity),
+			}
+			if err := alloc.gatherAllocatedDevices(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -21,13 +21,19 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
 	v1 "k8s.io/api/core/v1"
 	resourceapi "k8s.io/api/resource/v1alpha3"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/cel/environment"
 	resourcelisters "k8s.io/client-go/listers/resource/v1alpha3"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+	"k8s.io/component-helpers/scheduling/corev1/nodeaffinity"
 	"k8s.io/dynamic-resource-allocation/cel"
 	"k8s.io/klog/v2"
 )
@@ -39,6 +45,28 @@ type ClaimLister interface {
 	ListAllAllocated() ([]*resourceapi.ResourceClaim, error)
 }
 
+// Features contains all feature gates that may influence the behavior
+// of the allocator.
+type Features struct {
+	// ConsumableCapacity enables sharing of devices by several claims
+	// which request some of the device's capacity. Without it, such
+	// requests are treated like requests for exclusive access.
+	ConsumableCapacity bool
+
+	// AttributeSelectors enables selectors which compare an attribute
+	// against a list of values. Without it, claims which use them
+	// cannot be allocated.
+	AttributeSelectors bool
+
+	// DeviceTaints enables taints for devices. Without it, taints
+	// are ignored.
+	DeviceTaints bool
+
+	// NodeLocalOnly enables requests which only accept devices from
+	// pools of the node. Without it, NodeLocalOnly is ignored.
+	NodeLocalOnly bool
+}
+
 // Allocator calculates how to allocate a set of unallocated claims which use
 // structured parameters.
 //
@@ -46,21 +74,104 @@ type ClaimLister interface {
 // available and the current state of the cluster (claims, classes, resource
 // slices).
 type Allocator struct {
-	claimsToAllocate []*resourceapi.ResourceClaim
-	claimLister      ClaimLister
-	classLister      resourcelisters.DeviceClassLister
-	sliceLister      resourcelisters.ResourceSliceLister
+	features                 Features
+	claimsToAllocate         []*resourceapi.ResourceClaim
+	claimLister              ClaimLister
+	classLister              resourcelisters.DeviceClassLister
+	sliceLister              resourcelisters.ResourceSliceLister
+	antiAffinity             *AntiAffinity
+	tolerations              []v1.Toleration
+	podLabels                map[string]string
+	selectionPolicy          SelectionPolicy
+	missingAttributeBehavior MissingAttributeBehavior
+	hints                    *Hints
+	scorers                  []DeviceScorer
+	allowedFunctionGroups    []string
+}
+
+// AntiAffinity prevents allocating devices which have the same value
+// for an attribute as certain other devices. If the attribute identifies
+// the physical card that a device is on, then this can be used to avoid
+// sharing cards with the devices of some other pod.
+type AntiAffinity struct {
+	// Attribute is the attribute which must have different values.
+	// Devices which don't have it are not affected.
+	Attribute resourceapi.FullyQualifiedName
+
+	// Devices are the devices to stay away from. Typically these
+	// are allocated already. Devices which are not accessible from
+	// the node are ignored.
+	Devices []DeviceID
+}
+
+// SelectionPolicy determines which device gets tried first when several
+// devices could satisfy a request.
+type SelectionPolicy string
+
+const (
+	// FirstFit tries devices in the order in which they are listed
+	// in the resource slices. This is the default.
+	FirstFit SelectionPolicy = "FirstFit"
+
+	// BestFit tries those devices first which are left with the least
+	// unused capacity after satisfying a request for some of their
+	// capacity. This keeps devices with more unused capacity available
+	// for larger requests. Requests for entire devices are handled
+	// like with FirstFit.
+	BestFit SelectionPolicy = "BestFit"
+)
+
+// Hints tell the allocator which devices to try first. They are only a
+// preference: when the hinted devices are unavailable or don't satisfy
+// the claims, other devices get allocated as usual.
+type Hints struct {
+	// Devices are tried before all other devices, in this order.
+	Devices []DeviceID
+
+	// Pools are tried before the remaining pools, in this order.
+	Pools []PoolID
 }
 
+// DeviceScorer can be implemented by placement policies which want to
+// influence which of the devices that satisfy a request gets picked, for
+// example to prefer devices close to some other hardware used by the pod.
+type DeviceScorer interface {
+	// ScoreDevice scores a device which can be allocated for the request
+	// of the claim on the node. Devices with a higher score are tried
+	// first. The scores of all scorers get added up.
+	//
+	// It gets called concurrently for different nodes and must not modify
+	// its parameters. A panic is treated like a score of zero.
+	ScoreDevice(ctx context.Context, claim *resourceapi.ResourceClaim, request *resourceapi.DeviceRequest, node *v1.Node, deviceID DeviceID, device *resourceapi.BasicDevice) int64
+}
+
+// MissingAttributeBehavior determines how CEL runtime errors are handled
+// which occur when a selector looks up an attribute that a device doesn't
+// have.
+type MissingAttributeBehavior string
+
+const (
+	// MissingAttributeError reports the lookup as an error, the same
+	// way as other CEL runtime errors. This is the default.
+	MissingAttributeError MissingAttributeBehavior = "Error"
+
+	// MissingAttributeExclude treats the device as not matching the
+	// selector. This is useful when only some devices provide the
+	// attribute.
+	MissingAttributeExclude MissingAttributeBehavior = "Exclude"
+)
+
 // NewAllocator returns an allocator for a certain set of claims or an error if
 // some problem was detected which makes it impossible to allocate claims.
 func NewAllocator(ctx context.Context,
+	features Features,
 	claimsToAllocate []*resourceapi.ResourceClaim,
 	claimLister ClaimLister,
 	classLister resourcelisters.DeviceClassLister,
 	sliceLister resourcelisters.ResourceSliceLister,
 ) (*Allocator, error) {
 	return &Allocator{
+		features:         features,
 		claimsToAllocate: claimsToAllocate,
 		claimLister:      claimLister,
 		classLister:      classLister,
@@ -68,6 +179,281 @@ func NewAllocator(ctx context.Context,
 	}, nil
 }
 
+// AllocatableDevices returns the number of devices which are accessible from
+// the node, match the selectors of the class and are not allocated yet
+// according to the claim lister. The claims to allocate are ignored.
+// Otherwise the same checks apply as in Allocate, with the settings of
+// the allocator: devices with untolerated taints and devices excluded by
+// the anti-affinity are not counted and the selectors are evaluated with
+// the pod labels, the missing attribute behavior and the allowed function
+// groups. Devices which are shared by claims that consume some of their
+// capacity are not counted either.
+//
+// Like Allocate, it returns an error for invalid input data like errors in
+// CEL selectors.
+func (a *Allocator) AllocatableDevices(ctx context.Context, node *v1.Node, class *resourceapi.DeviceClass) (int, error) {
+	alloc := &allocator{
+		Allocator: a,
+		ctx:       ctx,
+		logger:    klog.FromContext(ctx),
+		node:      node,
+		allocated: make(map[DeviceID]bool),
+		consumed:  make(map[DeviceID]map[resourceapi.QualifiedName]resource.Quantity),
+	}
+	pools, err := GatherPools(ctx, alloc.sliceLister, node)
+	if err != nil {
+		return 0, fmt.Errorf("gather pool information: %w", err)
+	}
+	alloc.pools = pools
+	alloc.gatherExcludedAttributeValues()
+	if err := alloc.gatherAllocatedDevices(); err != nil {
+		return 0, err
+	}
+
+	numDevices := 0
+	for _, pool := range pools {
+		for _, slice := range pool.Slices {
+			for _, device := range slice.Spec.Devices {
+				if device.Basic == nil {
+					// Some future, unknown device type.
+					continue
+				}
+				deviceID := DeviceID{Driver: slice.Spec.Driver, Pool: slice.Spec.Pool.Name, Device: device.Name}
+				if alloc.isAllocated(deviceID) || alloc.consumedCapacity(deviceID) != nil {
+					continue
+				}
+				if alloc.excludedAttributeValues.Len() > 0 {
+					if attribute := lookupAttribute(device.Basic, deviceID, alloc.antiAffinity.Attribute); attribute != nil && alloc.excludedAttributeValues.Has(attributeValueKey(attribute)) {
+						continue
+					}
+				}
+				if alloc.features.DeviceTaints {
+					if _, untolerated := corev1helpers.FindMatchingUntoleratedTaint(device.Basic.Taints, alloc.tolerations, isNoScheduleTaint); untolerated {
+						continue
+					}
+				}
+				// No claim is involved when checking class selectors.
+				matches, err := alloc.selectorsMatch(noRequest, device.Basic, deviceID, class, class.Spec.Selectors)
+				if err != nil {
+					return 0, err
+				}
+				if matches {
+					numDevices++
+				}
+			}
+		}
+	}
+	return numDevices, nil
+}
+
+// ClassDevices returns the number of devices in the slices which match the
+// selectors of the class. Neither node access nor availability are checked
+// and out-dated slices are not filtered out, so the result is an upper
+// bound for the number of devices that can be allocated for the class.
+//
+// Devices for which the selectors cannot be evaluated are counted because
+// they might match. Allocate reports such errors.
+//
+// The pod labels are made available to the selectors like WithPodLabels
+// does.
+func ClassDevices(ctx context.Context, class *resourceapi.DeviceClass, podLabels map[string]string, slices []*resourceapi.ResourceSlice) int {
+	alloc := &allocator{
+		Allocator: &Allocator{podLabels: podLabels},
+		ctx:       ctx,
+		logger:    klog.FromContext(ctx),
+	}
+	numDevices := 0
+	for _, slice := range slices {
+		for _, device := range slice.Spec.Devices {
+			if device.Basic == nil {
+				// Some future, unknown device type.
+				continue
+			}
+			deviceID := DeviceID{Driver: slice.Spec.Driver, Pool: slice.Spec.Pool.Name, Device: device.Name}
+			// No claim is involved when checking class selectors.
+			matches, err := alloc.selectorsMatch(noRequest, device.Basic, deviceID, class, class.Spec.Selectors)
+			if matches || err != nil {
+				numDevices++
+			}
+		}
+	}
+	return numDevices
+}
+
+// RequestDevicesExist returns true if at least one device in the slices
+// matches the selectors of the class and of the request with the given
+// index in the claim. Like ClassDevices, it ignores node access,
+// availability and out-dated slices, so false means that the request
+// cannot be satisfied anywhere in the cluster.
+//
+// Devices for which the selectors cannot be evaluated count as a match
+// because they might match. Allocate reports such errors.
+//
+// The pod labels are made available to the selectors like WithPodLabels
+// does.
+func RequestDevicesExist(ctx context.Context, class *resourceapi.DeviceClass, claim *resourceapi.ResourceClaim, requestIndex int, podLabels map[string]string, slices []*resourceapi.ResourceSlice) bool {
+	alloc := &allocator{
+		Allocator: &Allocator{claimsToAllocate: []*resourceapi.ResourceClaim{claim}, podLabels: podLabels},
+		ctx:       ctx,
+		logger:    klog.FromContext(ctx),
+	}
+	r := requestIndices{claimIndex: 0, requestIndex: requestIndex}
+	request := &claim.Spec.Devices.Requests[requestIndex]
+	for _, slice := range slices {
+		for _, device := range slice.Spec.Devices {
+			if device.Basic == nil {
+				// Some future, unknown device type.
+				continue
+			}
+			deviceID := DeviceID{Driver: slice.Spec.Driver, Pool: slice.Spec.Pool.Name, Device: device.Name}
+			matches, err := alloc.selectorsMatch(r, device.Basic, deviceID, class, class.Spec.Selectors)
+			if err != nil {
+				return true
+			}
+			if !matches {
+				continue
+			}
+			matches, err = alloc.selectorsMatch(r, device.Basic, deviceID, nil, request.Selectors)
+			if matches || err != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DeviceMatchesRequest returns true if the device matches the selectors of
+// the class, if one is given, and of the request with the given index in the
+// claim. Selectors which cannot be evaluated are reported as error. This can
+// be used to check whether a device which was allocated for the claim still
+// matches after its attributes changed. The pod labels are not known, so
+// selectors which depend on them do not match.
+func DeviceMatchesRequest(ctx context.Context, class *resourceapi.DeviceClass, claim *resourceapi.ResourceClaim, requestIndex int, deviceID DeviceID, device *resourceapi.BasicDevice) (bool, error) {
+	alloc := &allocator{
+		Allocator: &Allocator{claimsToAllocate: []*resourceapi.ResourceClaim{claim}},
+		ctx:       ctx,
+		logger:    klog.FromContext(ctx),
+	}
+	r := requestIndices{claimIndex: 0, requestIndex: requestIndex}
+	if class != nil {
+		matches, err := alloc.selectorsMatch(r, device, deviceID, class, class.Spec.Selectors)
+		if !matches || err != nil {
+			return matches, err
+		}
+	}
+	return alloc.selectorsMatch(r, device, deviceID, nil, claim.Spec.Devices.Requests[requestIndex].Selectors)
+}
+
+// WithAntiAffinity returns a copy of the allocator which doesn't pick devices
+// that conflict with the anti-affinity. Nil removes any anti-affinity.
+func (a *Allocator) WithAntiAffinity(antiAffinity *AntiAffinity) *Allocator {
+	allocator := *a
+	allocator.antiAffinity = antiAffinity
+	return &allocator
+}
+
+// AntiAffinity returns the anti-affinity set with WithAntiAffinity.
+func (a *Allocator) AntiAffinity() *AntiAffinity {
+	return a.antiAffinity
+}
+
+// WithTolerations returns a copy of the allocator which may pick devices
+// with taints that are tolerated by the given tolerations. Typically these
+// are the tolerations of the pod. Devices with other taints are not picked
+// if the DeviceTaints feature is enabled.
+func (a *Allocator) WithTolerations(tolerations []v1.Toleration) *Allocator {
+	allocator := *a
+	allocator.tolerations = tolerations
+	return &allocator
+}
+
+// Tolerations returns the tolerations set with WithTolerations.
+func (a *Allocator) Tolerations() []v1.Toleration {
+	return a.tolerations
+}
+
+// WithPodLabels returns a copy of the allocator which makes the given
+// labels available to CEL selectors as pod.labels. Typically these are
+// the labels of the pod which needs the devices.
+func (a *Allocator) WithPodLabels(labels map[string]string) *Allocator {
+	allocator := *a
+	allocator.podLabels = labels
+	return &allocator
+}
+
+// PodLabels returns the labels set with WithPodLabels.
+func (a *Allocator) PodLabels() map[string]string {
+	return a.podLabels
+}
+
+// WithSelectionPolicy returns a copy of the allocator which uses the given
+// policy. Empty is the same as FirstFit.
+func (a *Allocator) WithSelectionPolicy(policy SelectionPolicy) *Allocator {
+	allocator := *a
+	allocator.selectionPolicy = policy
+	return &allocator
+}
+
+// SelectionPolicy returns the policy set with WithSelectionPolicy.
+func (a *Allocator) SelectionPolicy() SelectionPolicy {
+	return a.selectionPolicy
+}
+
+// WithMissingAttributeBehavior returns a copy of the allocator which handles
+// lookups of missing attributes in CEL selectors as defined by the given
+// behavior. Empty is the same as MissingAttributeError.
+func (a *Allocator) WithMissingAttributeBehavior(behavior MissingAttributeBehavior) *Allocator {
+	allocator := *a
+	allocator.missingAttributeBehavior = behavior
+	return &allocator
+}
+
+// MissingAttributeBehavior returns the behavior set with WithMissingAttributeBehavior.
+func (a *Allocator) MissingAttributeBehavior() MissingAttributeBehavior {
+	return a.missingAttributeBehavior
+}
+
+// WithHints returns a copy of the allocator which prefers the hinted
+// devices and pools. Nil removes all hints.
+func (a *Allocator) WithHints(hints *Hints) *Allocator {
+	allocator := *a
+	allocator.hints = hints
+	return &allocator
+}
+
+// Hints returns the hints set with WithHints.
+func (a *Allocator) Hints() *Hints {
+	return a.hints
+}
+
+// WithDeviceScorers returns a copy of the allocator which tries the devices
+// for a request in the order of their scores. Devices with the same score
+// are tried in the same order as without scorers. Nil removes all scorers.
+func (a *Allocator) WithDeviceScorers(scorers []DeviceScorer) *Allocator {
+	allocator := *a
+	allocator.scorers = scorers
+	return &allocator
+}
+
+// DeviceScorers returns the scorers set with WithDeviceScorers.
+func (a *Allocator) DeviceScorers() []DeviceScorer {
+	return a.scorers
+}
+
+// WithAllowedFunctionGroups returns a copy of the allocator which rejects
+// CEL selectors that call functions of other groups than the given ones,
+// see [cel.FunctionGroups]. Nil allows all functions.
+func (a *Allocator) WithAllowedFunctionGroups(groups []string) *Allocator {
+	allocator := *a
+	allocator.allowedFunctionGroups = groups
+	return &allocator
+}
+
+// AllowedFunctionGroups returns the groups set with WithAllowedFunctionGroups.
+func (a *Allocator) AllowedFunctionGroups() []string {
+	return a.allowedFunctionGroups
+}
+
 // ClaimsToAllocate returns the claims that the allocated was created for.
 func (a *Allocator) ClaimsToAllocate() []*resourceapi.ResourceClaim {
 	return a.claimsToAllocate
@@ -96,15 +482,25 @@ func (a *Allocator) ClaimsToAllocate() []*resourceapi.ResourceClaim {
 // additional value. A name can also be useful because log messages do not
 // have a common prefix. V(5) is used for one-time log entries, V(6) for important
 // progress reports, and V(7) for detailed debug output.
-func (a *Allocator) Allocate(ctx context.Context, node *v1.Node) (finalResult []*resourceapi.AllocationResult, finalErr error) {
+func (a *Allocator) Allocate(ctx context.Context, node *v1.Node) ([]*resourceapi.AllocationResult, error) {
+	result, _, err := a.AllocateWithReason(ctx, node)
+	return result, err
+}
+
+// AllocateWithReason is like Allocate. In addition, when the claims cannot be
+// allocated, it tries to identify which request is to blame. The reason is
+// nil if the claims can be allocated or an error occurred.
+func (a *Allocator) AllocateWithReason(ctx context.Context, node *v1.Node) (finalResult []*resourceapi.AllocationResult, unsatisfiable *UnsatisfiableRequest, finalErr error) {
 	alloc := &allocator{
 		Allocator:            a,
 		ctx:                  ctx, // all methods share the same a and thus ctx
 		logger:               klog.FromContext(ctx),
+		node:                 node,
 		deviceMatchesRequest: make(map[matchKey]bool),
 		constraints:          make([][]constraint, len(a.claimsToAllocate)),
 		requestData:          make(map[requestIndices]requestData),
 		allocated:            make(map[DeviceID]bool),
+		consumed:             make(map[DeviceID]map[resourceapi.QualifiedName]resource.Quantity),
 		result:               make([]*resourceapi.AllocationResult, len(a.claimsToAllocate)),
 	}
 	alloc.logger.V(5).Info("Starting allocation", "numClaims", len(alloc.claimsToAllocate))
@@ -113,14 +509,15 @@ func (a *Allocator) Allocate(ctx context.Context, node *v1.Node) (finalResult []
 	// First determine all eligible pools.
 	pools, err := GatherPools(ctx, alloc.sliceLister, node)
 	if err != nil {
-		return nil, fmt.Errorf("gather pool information: %w", err)
+		return nil, nil, fmt.Errorf("gather pool information: %w", err)
 	}
-	alloc.pools = pools
+	alloc.pools = applyHints(pools, a.hints)
 	if loggerV := alloc.logger.V(7); loggerV.Enabled() {
 		loggerV.Info("Gathered pool information", "numPools", len(pools), "pools", pools)
 	} else {
 		alloc.logger.V(5).Info("Gathered pool information", "numPools", len(pools))
 	}
+	alloc.gatherExcludedAttributeValues()
 
 	// We allocate one claim after the other and for each claim, all of
 	// its requests. For each individual device we pick one possible
@@ -154,19 +551,27 @@ func (a *Allocator) Allocate(ctx context.Context, node *v1.Node) (finalResult []
 		for requestIndex := range claim.Spec.Devices.Requests {
 			request := &claim.Spec.Devices.Requests[requestIndex]
 			for i, selector := range request.Selectors {
+				if selector.Attribute != nil && alloc.features.AttributeSelectors {
+					continue
+				}
 				if selector.CEL == nil {
 					// Unknown future selector type!
-					return nil, fmt.Errorf("claim %s, request %s, selector #%d: CEL expression empty (unsupported selector type?)", klog.KObj(claim), request.Name, i)
+					return nil, nil, fmt.Errorf("claim %s, request %s, selector #%d: CEL expression empty (unsupported selector type?)", klog.KObj(claim), request.Name, i)
 				}
 			}
 
 			// Should be set. If it isn't, something changed and we should refuse to proceed.
 			if request.DeviceClassName == "" {
-				return nil, fmt.Errorf("claim %s, request %s: missing device class name (unsupported request type?)", klog.KObj(claim), request.Name)
+				return nil, nil, fmt.Errorf("claim %s, request %s: missing device class name (unsupported request type?)", klog.KObj(claim), request.Name)
 			}
 			class, err := alloc.classLister.Get(request.DeviceClassName)
 			if err != nil {
-				return nil, fmt.Errorf("claim %s, request %s: could not retrieve device class %s: %w", klog.KObj(claim), request.Name, request.DeviceClassName, err)
+				return nil, nil, fmt.Errorf("claim %s, request %s: could not retrieve device class %s: %w", klog.KObj(claim), request.Name, request.DeviceClassName, err)
+			}
+			for i, selector := range class.Spec.Selectors {
+				if selector.Attribute != nil && !alloc.features.AttributeSelectors {
+					return nil, nil, fmt.Errorf("class %s: selector #%d: attribute selectors are not enabled", class.Name, i)
+				}
 			}
 
 			requestData := requestData{
@@ -178,21 +583,21 @@ func (a *Allocator) Allocate(ctx context.Context, node *v1.Node) (finalResult []
 				numDevices := request.Count
 				if numDevices > math.MaxInt {
 					// Allowed by API validation, but doesn't make sense.
-					return nil, fmt.Errorf("claim %s, request %s: exact count %d is too large", klog.KObj(claim), request.Name, numDevices)
+					return nil, nil, fmt.Errorf("claim %s, request %s: exact count %d is too large", klog.KObj(claim), request.Name, numDevices)
 				}
 				requestData.numDevices = int(numDevices)
 			case resourceapi.DeviceAllocationModeAll:
 				requestData.allDevices = make([]deviceWithID, 0, resourceapi.AllocationResultsMaxSize)
 				for _, pool := range pools {
 					if pool.IsIncomplete {
-						return nil, fmt.Errorf("claim %s, request %s: asks for all devices, but resource pool %s is currently being updated", klog.KObj(claim), request.Name, pool.PoolID)
+						return nil, nil, fmt.Errorf("claim %s, request %s: asks for all devices, but resource pool %s is currently being updated", klog.KObj(claim), request.Name, pool.PoolID)
 					}
 
 					for _, slice := range pool.Slices {
 						for deviceIndex := range slice.Spec.Devices {
 							selectable, err := alloc.isSelectable(requestIndices{claimIndex: claimIndex, requestIndex: requestIndex}, slice, deviceIndex)
 							if err != nil {
-								return nil, err
+								return nil, nil, err
 							}
 							if selectable {
 								requestData.allDevices = append(requestData.allDevices, deviceWithID{device: slice.Spec.Devices[deviceIndex].Basic, DeviceID: DeviceID{Driver: slice.Spec.Driver, Pool: slice.Spec.Pool.Name, Device: slice.Spec.Devices[deviceIndex].Name}})
@@ -203,7 +608,7 @@ func (a *Allocator) Allocate(ctx context.Context, node *v1.Node) (finalResult []
 				requestData.numDevices = len(requestData.allDevices)
 				alloc.logger.V(6).Info("Request for 'all' devices", "claim", klog.KObj(claim), "request", request.Name, "numDevicesPerRequest", requestData.numDevices)
 			default:
-				return nil, fmt.Errorf("claim %s, request %s: unsupported count mode %s", klog.KObj(claim), request.Name, request.AllocationMode)
+				return nil, nil, fmt.Errorf("claim %s, request %s: unsupported count mode %s", klog.KObj(claim), request.Name, request.AllocationMode)
 			}
 			alloc.requestData[requestIndices{claimIndex: claimIndex, requestIndex: requestIndex}] = requestData
 			numDevices += requestData.numDevices
@@ -212,7 +617,7 @@ func (a *Allocator) Allocate(ctx context.Context, node *v1.Node) (finalResult []
 
 		// Check that we don't end up with too many results.
 		if numDevices > resourceapi.AllocationResultsMaxSize {
-			return nil, fmt.Errorf("claim %s: number of requested devices %d exceeds the claim limit of %d", klog.KObj(claim), numDevices, resourceapi.AllocationResultsMaxSize)
+			return nil, nil, fmt.Errorf("claim %s: number of requested devices %d exceeds the claim limit of %d", klog.KObj(claim), numDevices, resourceapi.AllocationResultsMaxSize)
 		}
 
 		// If we don't, then we can pre-allocate the result slices for
@@ -243,7 +648,7 @@ func (a *Allocator) Allocate(ctx context.Context, node *v1.Node) (finalResult []
 				constraints[i] = m
 			default:
 				// Unknown constraint type!
-				return nil, fmt.Errorf("claim %s, constraint #%d: empty constraint (unsupported constraint type?)", klog.KObj(claim), i)
+				return nil, nil, fmt.Errorf("claim %s, constraint #%d: empty constraint (unsupported constraint type?)", klog.KObj(claim), i)
 			}
 		}
 		alloc.constraints[claimIndex] = constraints
@@ -258,23 +663,9 @@ func (a *Allocator) Allocate(ctx context.Context, node *v1.Node) (finalResult []
 
 	// Some of the existing devices are probably already allocated by
 	// claims...
-	claims, err := alloc.claimLister.ListAllAllocated()
-	numAllocated := 0
-	if err != nil {
-		return nil, fmt.Errorf("list allocated claims: %w", err)
+	if err := alloc.gatherAllocatedDevices(); err != nil {
+		return nil, nil, err
 	}
-	for _, claim := range claims {
-		// Sanity check..
-		if claim.Status.Allocation == nil {
-			continue
-		}
-		for _, result := range claim.Status.Allocation.Devices.Results {
-			deviceID := DeviceID{Driver: result.Driver, Pool: result.Pool, Device: result.Device}
-			alloc.allocated[deviceID] = true
-			numAllocated++
-		}
-	}
-	alloc.logger.V(6).Info("Gathered information about allocated devices", "numAllocated", numAllocated)
 
 	// In practice, there aren't going to be many different CEL
 	// expressions. Most likely, there is going to be handful of different
@@ -291,23 +682,31 @@ func (a *Allocator) Allocate(ctx context.Context, node *v1.Node) (finalResult []
 	// without further wrapping.
 	done, err := alloc.allocateOne(deviceIndices{})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if errors.Is(err, errStop) || !done {
-		return nil, nil
+		alloc.logUnmatchedAttributeSelectors()
+		unsatisfiable, err := alloc.findUnsatisfiableRequest()
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, unsatisfiable, nil
 	}
 
 	for claimIndex, allocationResult := range alloc.result {
 		claim := alloc.claimsToAllocate[claimIndex]
 
-		// Populate configs.
-		for requestIndex := range claim.Spec.Devices.Requests {
+		// Populate configs. The configuration of a class only applies to
+		// the requests which use the class, so it has to be repeated for
+		// each of them. Class configs come first, so claim configs
+		// which are applied after them can override class defaults.
+		for requestIndex, request := range claim.Spec.Devices.Requests {
 			class := alloc.requestData[requestIndices{claimIndex: claimIndex, requestIndex: requestIndex}].class
 			if class != nil {
 				for _, config := range class.Spec.Config {
 					allocationResult.Devices.Config = append(allocationResult.Devices.Config, resourceapi.DeviceAllocationConfiguration{
 						Source:              resourceapi.AllocationConfigSourceClass,
-						Requests:            nil, // All of them...
+						Requests:            []string{request.Name},
 						DeviceConfiguration: config.DeviceConfiguration,
 					})
 				}
@@ -324,12 +723,109 @@ func (a *Allocator) Allocate(ctx context.Context, node *v1.Node) (finalResult []
 		// Determine node selector.
 		nodeSelector, err := alloc.createNodeSelector(allocationResult)
 		if err != nil {
-			return nil, fmt.Errorf("create NodeSelector for claim %s: %w", claim.Name, err)
+			return nil, nil, fmt.Errorf("create NodeSelector for claim %s: %w", claim.Name, err)
+		}
+		if err := checkNodeSelector(nodeSelector, node); err != nil {
+			return nil, nil, fmt.Errorf("NodeSelector for claim %s: %w", claim.Name, err)
 		}
 		allocationResult.NodeSelector = nodeSelector
 	}
 
-	return alloc.result, nil
+	return alloc.result, nil, nil
+}
+
+// UnsatisfiableRequest describes why claims could not be allocated.
+type UnsatisfiableRequest struct {
+	// Claim is the first claim which could not be allocated.
+	Claim *resourceapi.ResourceClaim
+	// Request is the name of the request within that claim which cannot
+	// be satisfied. It is empty if each request could be satisfied on its
+	// own and only the combination of them fails, for example because of
+	// constraints or because the requests compete for the same devices.
+	Request string
+	// Reason is a short explanation, like "no matching device".
+	Reason string
+}
+
+// String returns a message like "claim X: request req-1 unsatisfiable (no matching device)".
+func (u *UnsatisfiableRequest) String() string {
+	if u.Request == "" {
+		return fmt.Sprintf("claim %s: %s", u.Claim.Name, u.Reason)
+	}
+	return fmt.Sprintf("claim %s: request %s unsatisfiable (%s)", u.Claim.Name, u.Request, u.Reason)
+}
+
+// findUnsatisfiableRequest is called after the search failed. It checks each
+// request on its own against the devices which were already allocated before
+// the search. Devices picked for other requests are not taken into account,
+// so the first request which fails here cannot be satisfied regardless of
+// how the other requests are handled.
+func (alloc *allocator) findUnsatisfiableRequest() (*UnsatisfiableRequest, error) {
+	for claimIndex, claim := range alloc.claimsToAllocate {
+		for requestIndex := range claim.Spec.Devices.Requests {
+			request := &claim.Spec.Devices.Requests[requestIndex]
+			r := requestIndices{claimIndex: claimIndex, requestIndex: requestIndex}
+			requestData := alloc.requestData[r]
+
+			var numMatching, numAvailable int
+			if request.AllocationMode == resourceapi.DeviceAllocationModeAll {
+				// All selectable devices are needed, so each one
+				// of them must be available.
+				numMatching = len(requestData.allDevices)
+				for _, device := range requestData.allDevices {
+					if alloc.isAvailable(request, device.device, device.DeviceID) {
+						numAvailable++
+					}
+				}
+			} else {
+				for _, pool := range alloc.pools {
+					for _, slice := range pool.Slices {
+						for deviceIndex := range slice.Spec.Devices {
+							selectable, err := alloc.isSelectable(r, slice, deviceIndex)
+							if err != nil {
+								return nil, err
+							}
+							if !selectable {
+								continue
+							}
+							numMatching++
+							deviceID := DeviceID{Driver: pool.Driver, Pool: pool.Pool, Device: slice.Spec.Devices[deviceIndex].Name}
+							if alloc.isAvailable(request, slice.Spec.Devices[deviceIndex].Basic, deviceID) {
+								numAvailable++
+							}
+						}
+					}
+				}
+			}
+
+			switch {
+			case requestData.numDevices > 0 && numMatching == 0:
+				return &UnsatisfiableRequest{Claim: claim, Request: request.Name, Reason: "no matching device"}, nil
+			case numAvailable < requestData.numDevices:
+				return &UnsatisfiableRequest{Claim: claim, Request: request.Name, Reason: "not enough free devices"}, nil
+			}
+		}
+	}
+	if len(alloc.claimsToAllocate) == 0 {
+		return nil, nil
+	}
+	return &UnsatisfiableRequest{Claim: alloc.claimsToAllocate[0], Reason: "requests cannot be satisfied together"}, nil
+}
+
+// isAvailable checks whether the device could still be allocated for the
+// request, ignoring constraints and other requests being allocated.
+func (alloc *allocator) isAvailable(request *resourceapi.DeviceRequest, device *resourceapi.BasicDevice, deviceID DeviceID) bool {
+	if request.AdminAccess {
+		return true
+	}
+	if alloc.isShared(request) {
+		if alloc.isAllocated(deviceID) {
+			return false
+		}
+		_, ok := alloc.hasCapacity(device, deviceID, request.Capacity)
+		return ok
+	}
+	return !alloc.isAllocated(deviceID) && alloc.consumedCapacity(deviceID) == nil
 }
 
 // errStop is a special error that gets returned by allocateOne if it detects
@@ -342,6 +838,7 @@ type allocator struct {
 	*Allocator
 	ctx                  context.Context
 	logger               klog.Logger
+	node                 *v1.Node
 	pools                []*Pool
 	deviceMatchesRequest map[matchKey]bool
 	constraints          [][]constraint                 // one list of constraints per claim
@@ -349,6 +846,22 @@ type allocator struct {
 	allocated            map[DeviceID]bool
 	skippedUnknownDevice bool
 	result               []*resourceapi.AllocationResult
+
+	// excludedAttributeValues contains the values of the anti-affinity
+	// attribute which must be avoided, see attributeValueKey.
+	excludedAttributeValues sets.Set[string]
+
+	// consumed has an entry for each device which is shared by claims
+	// that requested some of its capacity. The value is the sum of those
+	// requests. Each map gets replaced instead of modified in place, which
+	// makes rolling back simple.
+	consumed map[DeviceID]map[resourceapi.QualifiedName]resource.Quantity
+
+	// allocatedDevices is set if the claim lister provides a snapshot
+	// of the devices which are in use. allocated and consumed then
+	// only contain the devices allocated by this allocator, with
+	// entries in consumed replacing those in the snapshot.
+	allocatedDevices *AllocatedDevices
 }
 
 // matchKey identifies a device/request pair.
@@ -363,6 +876,9 @@ type requestIndices struct {
 	claimIndex, requestIndex int
 }
 
+// noRequest is used for class selectors which get checked without a claim.
+var noRequest = requestIndices{claimIndex: -1, requestIndex: -1}
+
 // deviceIndices identifies one specific required device inside
 // a request of a certain claim.
 type deviceIndices struct {
@@ -455,6 +971,11 @@ func (m *matchAttributeConstraint) add(requestName string, device *resourceapi.B
 			m.logger.V(7).Info("Version values different")
 			return false
 		}
+	case attribute.StringListValue != nil:
+		if m.attribute.StringListValue == nil || !slices.Equal(attribute.StringListValue, m.attribute.StringListValue) {
+			m.logger.V(7).Info("String list values different")
+			return false
+		}
 	default:
 		// Unknown value type, cannot match.
 		m.logger.V(7).Info("Match attribute type unknown")
@@ -553,13 +1074,16 @@ func (alloc *allocator) allocateOne(r deviceIndices) (bool, error) {
 	}
 
 	// We need to find suitable devices.
+	if (alloc.selectionPolicy == BestFit && alloc.isShared(request)) || len(alloc.scorers) > 0 {
+		return alloc.allocateSorted(r, request)
+	}
 	for _, pool := range alloc.pools {
 		for _, slice := range pool.Slices {
 			for deviceIndex := range slice.Spec.Devices {
 				deviceID := DeviceID{Driver: pool.Driver, Pool: pool.Pool, Device: slice.Spec.Devices[deviceIndex].Name}
 
 				// Checking for "in use" is cheap and thus gets done first.
-				if !request.AdminAccess && alloc.allocated[deviceID] {
+				if !request.AdminAccess && alloc.isAllocated(deviceID) {
 					alloc.logger.V(7).Info("Device in use", "device", deviceID)
 					continue
 				}
@@ -604,6 +1128,248 @@ func (alloc *allocator) allocateOne(r deviceIndices) (bool, error) {
 	return false, nil
 }
 
+// allocateSorted is the variant of the search in allocateOne which sorts
+// the devices before trying them. It first collects all devices which could
+// be allocated. Those with a higher score from the device scorers get tried
+// first. With the BestFit policy, devices with the same score are tried in
+// order of increasing unused capacity after allocating a request which
+// consumes capacity.
+func (alloc *allocator) allocateSorted(r deviceIndices, request *resourceapi.DeviceRequest) (bool, error) {
+	type candidate struct {
+		device   *resourceapi.BasicDevice
+		deviceID DeviceID
+		score    int64
+		unused   float64
+	}
+	shared := alloc.isShared(request)
+	bestFit := alloc.selectionPolicy == BestFit && shared
+	var candidates []candidate
+	for _, pool := range alloc.pools {
+		for _, slice := range pool.Slices {
+			for deviceIndex := range slice.Spec.Devices {
+				deviceID := DeviceID{Driver: pool.Driver, Pool: pool.Pool, Device: slice.Spec.Devices[deviceIndex].Name}
+				if !request.AdminAccess && alloc.isAllocated(deviceID) {
+					alloc.logger.V(7).Info("Device in use", "device", deviceID)
+					continue
+				}
+				selectable, err := alloc.isSelectable(requestIndices{claimIndex: r.claimIndex, requestIndex: r.requestIndex}, slice, deviceIndex)
+				if err != nil {
+					return false, err
+				}
+				if !selectable {
+					alloc.logger.V(7).Info("Device not selectable", "device", deviceID)
+					continue
+				}
+				device := slice.Spec.Devices[deviceIndex].Basic
+				c := candidate{device: device, deviceID: deviceID}
+				if shared {
+					if name, ok := alloc.hasCapacity(device, deviceID, request.Capacity); !ok {
+						alloc.logger.V(7).Info("Device has insufficient capacity", "device", deviceID, "capacity", name)
+						continue
+					}
+				}
+				if bestFit {
+					c.unused = alloc.unusedCapacity(device, deviceID, request.Capacity)
+				}
+				c.score = alloc.scoreDevice(r, request, deviceID, device)
+				candidates = append(candidates, c)
+			}
+		}
+	}
+
+	// The sort is stable, so devices which are equally good are
+	// tried in the same order as with FirstFit.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].unused < candidates[j].unused
+	})
+	for _, candidate := range candidates {
+		allocated, deallocate, err := alloc.allocateDevice(r, candidate.device, candidate.deviceID, false)
+		if err != nil {
+			return false, err
+		}
+		if !allocated {
+			alloc.logger.V(7).Info("Device not usable", "device", candidate.deviceID)
+			continue
+		}
+		done, err := alloc.allocateOne(deviceIndices{claimIndex: r.claimIndex, requestIndex: r.requestIndex, deviceIndex: r.deviceIndex + 1})
+		if err != nil {
+			return false, err
+		}
+		if done {
+			return done, nil
+		}
+		deallocate()
+	}
+	return false, nil
+}
+
+// scoreDevice returns the sum of the scores of all device scorers.
+func (alloc *allocator) scoreDevice(r deviceIndices, request *resourceapi.DeviceRequest, deviceID DeviceID, device *resourceapi.BasicDevice) int64 {
+	var score int64
+	for _, scorer := range alloc.scorers {
+		score += alloc.callScorer(scorer, alloc.claimsToAllocate[r.claimIndex], request, deviceID, device)
+	}
+	return score
+}
+
+// callScorer returns the score of one device scorer. A scorer which panics
+// must not bring down the caller, so the panic is logged and the device
+// gets a neutral score.
+func (alloc *allocator) callScorer(scorer DeviceScorer, claim *resourceapi.ResourceClaim, request *resourceapi.DeviceRequest, deviceID DeviceID, device *resourceapi.BasicDevice) (score int64) {
+	defer func() {
+		if r := recover(); r != nil {
+			alloc.logger.Error(nil, "Device scorer panicked, using score 0", "scorer", fmt.Sprintf("%T", scorer), "claim", klog.KObj(claim), "request", request.Name, "device", deviceID, "panic", r)
+			score = 0
+		}
+	}()
+	return scorer.ScoreDevice(alloc.ctx, claim, request, alloc.node, deviceID, device)
+}
+
+// unusedCapacity returns how much of the requested capacities of the device
+// would remain unused after allocating the request. Each capacity
+// contributes the unused fraction of its total, so capacities with
+// different units can be compared. The device must have enough capacity.
+func (alloc *allocator) unusedCapacity(device *resourceapi.BasicDevice, deviceID DeviceID, requested map[resourceapi.QualifiedName]resource.Quantity) float64 {
+	consumed := alloc.consumedCapacity(deviceID)
+	unused := 0.0
+	for name, quantity := range requested {
+		available := device.Capacity[name]
+		total := available.AsApproximateFloat64()
+		if total <= 0 {
+			continue
+		}
+		remaining := available.DeepCopy()
+		used := consumed[name]
+		remaining.Sub(used)
+		remaining.Sub(quantity)
+		unused += remaining.AsApproximateFloat64() / total
+	}
+	return unused
+}
+
+// gatherAllocatedDevices marks all devices as allocated which are in use by
+// some already allocated claim. Devices which are only shared by claims
+// that consume some of their capacity are recorded in alloc.consumed instead.
+func (alloc *allocator) gatherAllocatedDevices() error {
+	if lister, ok := alloc.claimLister.(AllocatedDevicesLister); ok {
+		if allocatedDevices := lister.ListAllocatedDevices(); allocatedDevices != nil {
+			alloc.allocatedDevices = allocatedDevices
+			alloc.logger.V(6).Info("Using snapshot of allocated devices", "numDevices", allocatedDevices.Len())
+			return nil
+		}
+	}
+	claims, err := alloc.claimLister.ListAllAllocated()
+	if err != nil {
+		return fmt.Errorf("list allocated claims: %w", err)
+	}
+	numAllocated := 0
+	numShared := 0
+	for _, claim := range claims {
+		// Sanity check..
+		if claim.Status.Allocation == nil {
+			continue
+		}
+		for _, result := range claim.Status.Allocation.Devices.Results {
+			deviceID := DeviceID{Driver: result.Driver, Pool: result.Pool, Device: result.Device}
+			if alloc.features.ConsumableCapacity && len(result.ConsumedCapacity) > 0 {
+				alloc.consumed[deviceID] = addCapacity(alloc.consumed[deviceID], result.ConsumedCapacity)
+				numShared++
+				continue
+			}
+			alloc.allocated[deviceID] = true
+			numAllocated++
+		}
+	}
+	alloc.logger.V(6).Info("Gathered information about allocated devices", "numAllocated", numAllocated, "numShared", numShared)
+	return nil
+}
+
+// isAllocated checks whether the device is in use by a claim which does
+// not share it.
+func (alloc *allocator) isAllocated(deviceID DeviceID) bool {
+	if alloc.allocated[deviceID] {
+		return true
+	}
+	if alloc.allocatedDevices == nil {
+		return false
+	}
+	usage := alloc.allocatedDevices.usage(deviceID)
+	// Without consumable capacity, gatherAllocatedDevices treats
+	// shared devices as allocated. The snapshot does not know about
+	// features, so that has to be done here.
+	return usage.exclusive || (!alloc.features.ConsumableCapacity && usage.consumed != nil)
+}
+
+// consumedCapacity returns the capacity of the device which is consumed
+// by claims that share it, nil if there are none.
+func (alloc *allocator) consumedCapacity(deviceID DeviceID) map[resourceapi.QualifiedName]resource.Quantity {
+	if consumed, ok := alloc.consumed[deviceID]; ok {
+		return consumed
+	}
+	if alloc.allocatedDevices == nil || !alloc.features.ConsumableCapacity {
+		return nil
+	}
+	return alloc.allocatedDevices.usage(deviceID).consumed
+}
+
+// gatherExcludedAttributeValues looks up the anti-affinity devices in the
+// pools and records their values of the anti-affinity attribute.
+func (alloc *allocator) gatherExcludedAttributeValues() {
+	if alloc.antiAffinity == nil || len(alloc.antiAffinity.Devices) == 0 {
+		return
+	}
+	devices := sets.New(alloc.antiAffinity.Devices...)
+	alloc.excludedAttributeValues = sets.New[string]()
+	for _, pool := range alloc.pools {
+		for _, slice := range pool.Slices {
+			for _, device := range slice.Spec.Devices {
+				deviceID := DeviceID{Driver: pool.Driver, Pool: pool.Pool, Device: device.Name}
+				if device.Basic == nil || !devices.Has(deviceID) {
+					continue
+				}
+				attribute := lookupAttribute(device.Basic, deviceID, alloc.antiAffinity.Attribute)
+				if attribute == nil {
+					continue
+				}
+				if key := attributeValueKey(attribute); key != "" {
+					alloc.excludedAttributeValues.Insert(key)
+				}
+			}
+		}
+	}
+	alloc.logger.V(6).Info("Gathered anti-affinity information", "attribute", alloc.antiAffinity.Attribute, "excludedValues", sets.List(alloc.excludedAttributeValues))
+}
+
+// attributeValueKey turns an attribute value into a string which is
+// unique for the value and its type. It returns an empty string for
+// unknown types.
+func attributeValueKey(attribute *resourceapi.DeviceAttribute) string {
+	switch {
+	case attribute.StringValue != nil:
+		return "string:" + *attribute.StringValue
+	case attribute.IntValue != nil:
+		return fmt.Sprintf("int:%d", *attribute.IntValue)
+	case attribute.BoolValue != nil:
+		return fmt.Sprintf("bool:%t", *attribute.BoolValue)
+	case attribute.VersionValue != nil:
+		// Version strings are in their minimal form, see matchAttributeConstraint.
+		return "version:" + *attribute.VersionValue
+	case attribute.StringListValue != nil:
+		// Quoting keeps lists apart where an entry contains the separator.
+		quoted := make([]string, 0, len(attribute.StringListValue))
+		for _, value := range attribute.StringListValue {
+			quoted = append(quoted, strconv.Quote(value))
+		}
+		return "stringList:" + strings.Join(quoted, ",")
+	default:
+		// Unknown value type, cannot be compared.
+		return ""
+	}
+}
+
 // isSelectable checks whether a device satisfies the request and class selectors.
 func (alloc *allocator) isSelectable(r requestIndices, slice *resourceapi.ResourceSlice, deviceIndex int) (bool, error) {
 	// This is the only supported device type at the moment.
@@ -623,6 +1389,32 @@ func (alloc *allocator) isSelectable(r requestIndices, slice *resourceapi.Resour
 		return matches, nil
 	}
 
+	if alloc.excludedAttributeValues.Len() > 0 {
+		if attribute := lookupAttribute(device, deviceID, alloc.antiAffinity.Attribute); attribute != nil && alloc.excludedAttributeValues.Has(attributeValueKey(attribute)) {
+			alloc.logger.V(7).Info("Device excluded by anti-affinity", "device", deviceID)
+			alloc.deviceMatchesRequest[matchKey] = false
+			return false, nil
+		}
+	}
+
+	if alloc.features.DeviceTaints {
+		if taint, untolerated := corev1helpers.FindMatchingUntoleratedTaint(device.Taints, alloc.tolerations, isNoScheduleTaint); untolerated {
+			alloc.logger.V(7).Info("Device excluded by untolerated taint", "device", deviceID, "taint", taint.ToString())
+			alloc.deviceMatchesRequest[matchKey] = false
+			return false, nil
+		}
+	}
+
+	request := &alloc.claimsToAllocate[r.claimIndex].Spec.Devices.Requests[r.requestIndex]
+	if alloc.features.NodeLocalOnly && request.NodeLocalOnly && slice.Spec.NodeName == "" {
+		// Only pools which are available on the node are considered,
+		// so a slice with a node name belongs to the node. All
+		// others are shared with other nodes.
+		alloc.logger.V(7).Info("Device excluded because its pool is not local to the node", "device", deviceID)
+		alloc.deviceMatchesRequest[matchKey] = false
+		return false, nil
+	}
+
 	requestData := alloc.requestData[r]
 	if requestData.class != nil {
 		match, err := alloc.selectorsMatch(r, device, deviceID, requestData.class, requestData.class.Spec.Selectors)
@@ -635,7 +1427,6 @@ func (alloc *allocator) isSelectable(r requestIndices, slice *resourceapi.Resour
 		}
 	}
 
-	request := &alloc.claimsToAllocate[r.claimIndex].Spec.Devices.Requests[r.requestIndex]
 	match, err := alloc.selectorsMatch(r, device, deviceID, nil, request.Selectors)
 	if err != nil {
 		return false, err
@@ -650,42 +1441,248 @@ func (alloc *allocator) isSelectable(r requestIndices, slice *resourceapi.Resour
 
 }
 
+// isNoScheduleTaint is the filter for FindMatchingUntoleratedTaint.
+// Validation only allows the NoSchedule effect, anything else is
+// ignored.
+func isNoScheduleTaint(taint *v1.Taint) bool {
+	return taint.Effect == v1.TaintEffectNoSchedule
+}
+
 func (alloc *allocator) selectorsMatch(r requestIndices, device *resourceapi.BasicDevice, deviceID DeviceID, class *resourceapi.DeviceClass, selectors []resourceapi.DeviceSelector) (bool, error) {
+	// A class or claim with many broken selectors would produce a huge
+	// error if all errors were reported. Instead, only the first error
+	// is reported together with the number of additional ones. The
+	// remaining selectors only get evaluated to determine that number,
+	// up to maxSelectorErrors.
+	var firstErr *SelectorError
+	numErrs := 0
+	truncated := false
 	for i, selector := range selectors {
-		expr := cel.GetCompiler().CompileCELExpression(selector.CEL.Expression, environment.StoredExpressions)
-		if expr.Error != nil {
-			// Could happen if some future apiserver accepted some
-			// future expression and then got downgraded. Normally
-			// the "stored expression" mechanism prevents that, but
-			// this code here might be more than one release older
-			// than the cluster it runs in.
-			if class != nil {
-				return false, fmt.Errorf("class %s: selector #%d: CEL compile error: %w", class.Name, i, expr.Error)
+		if numErrs == maxSelectorErrors {
+			truncated = true
+			break
+		}
+		matches, err := alloc.selectorMatches(r, device, deviceID, class, i, selector)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = alloc.selectorError(r, class, i, err)
 			}
-			return false, fmt.Errorf("claim %s: selector #%d: CEL compile error: %w", klog.KObj(alloc.claimsToAllocate[r.claimIndex]), i, expr.Error)
+			numErrs++
+			continue
 		}
+		if !matches && firstErr == nil {
+			return false, nil
+		}
+	}
+
+	switch {
+	case numErrs == 0:
+		// All of them match.
+		return true, nil
+	case numErrs == 1:
+		return false, firstErr
+	case truncated:
+		firstErr.Err = fmt.Errorf("%w (and at least %d more selector errors)", firstErr.Err, numErrs-1)
+		return false, firstErr
+	case numErrs == 2:
+		firstErr.Err = fmt.Errorf("%w (and 1 more selector error)", firstErr.Err)
+		return false, firstErr
+	default:
+		firstErr.Err = fmt.Errorf("%w (and %d more selector errors)", firstErr.Err, numErrs-1)
+		return false, firstErr
+	}
+}
+
+// maxSelectorErrors limits how many failing selectors selectorsMatch
+// evaluates for a device. It runs for each device, so evaluating all
+// selectors of a class or claim with many broken ones would be slow.
+const maxSelectorErrors = 10
+
+// SelectorError is returned by Allocate when a selector of a claim or of a
+// device class cannot be evaluated. The message includes the class or the
+// claim. Callers which format that context themselves can use the fields
+// instead.
+type SelectorError struct {
+	// Claim is the claim which was being allocated, nil if not known.
+	Claim *resourceapi.ResourceClaim
+	// Request is the name of the request in the claim, empty if not known.
+	Request string
+	// Class is set if the selector is one of the class, otherwise it
+	// is one of the request.
+	Class *resourceapi.DeviceClass
+	// Index is the index of the selector in the class or the request.
+	Index int
+	// Err is the cause, for example a CEL runtime error.
+	Err error
+}
+
+func (e *SelectorError) Error() string {
+	if e.Class != nil {
+		return fmt.Sprintf("class %s: selector #%d: %v", e.Class.Name, e.Index, e.Err)
+	}
+	return fmt.Sprintf("claim %s: selector #%d: %v", klog.KObj(e.Claim), e.Index, e.Err)
+}
+
+func (e *SelectorError) Unwrap() error {
+	return e.Err
+}
 
-		matches, err := expr.DeviceMatches(alloc.ctx, cel.Device{Driver: deviceID.Driver, Attributes: device.Attributes, Capacity: device.Capacity})
+// selectorError returns the error for the selector with index i of the
+// class (if not nil) or of the request.
+func (alloc *allocator) selectorError(r requestIndices, class *resourceapi.DeviceClass, i int, err error) *SelectorError {
+	selectorErr := &SelectorError{Class: class, Index: i, Err: err}
+	if r.claimIndex >= 0 && r.claimIndex < len(alloc.claimsToAllocate) {
+		selectorErr.Claim = alloc.claimsToAllocate[r.claimIndex]
+		if requests := selectorErr.Claim.Spec.Devices.Requests; r.requestIndex >= 0 && r.requestIndex < len(requests) {
+			selectorErr.Request = requests[r.requestIndex].Name
+		}
+	}
+	return selectorErr
+}
+
+// selectorMatches evaluates the selector with index i of a class (if not nil)
+// or of the claim.
+func (alloc *allocator) selectorMatches(r requestIndices, device *resourceapi.BasicDevice, deviceID DeviceID, class *resourceapi.DeviceClass, i int, selector resourceapi.DeviceSelector) (bool, error) {
+	if selector.Attribute != nil {
+		return alloc.attributeSelectorMatches(r, device, deviceID, class, i, selector.Attribute)
+	}
+
+	expr := cel.GetCompiler(cel.Features{}).CompileCELExpression(selector.CEL.Expression, environment.StoredExpressions)
+	if expr.Error != nil {
+		// Could happen if some future apiserver accepted some
+		// future expression and then got downgraded. Normally
+		// the "stored expression" mechanism prevents that, but
+		// this code here might be more than one release older
+		// than the cluster it runs in.
+		return false, fmt.Errorf("CEL compile error: %w", expr.Error)
+	}
+	if alloc.allowedFunctionGroups != nil {
+		if function := expr.DisallowedFunction(alloc.allowedFunctionGroups); function != "" {
+			return false, fmt.Errorf("selector uses disallowed function %s", function)
+		}
+	}
+
+	matches, err := expr.DeviceMatches(alloc.ctx, cel.Device{Driver: deviceID.Driver, Attributes: device.Attributes, Capacity: device.Capacity, PodLabels: alloc.podLabels})
+	if class != nil {
+		alloc.logger.V(7).Info("CEL result", "device", deviceID, "class", klog.KObj(class), "selector", i, "expression", selector.CEL.Expression, "matches", matches, "err", err)
+	} else {
+		alloc.logger.V(7).Info("CEL result", "device", deviceID, "claim", klog.KObj(alloc.claimsToAllocate[r.claimIndex]), "selector", i, "expression", selector.CEL.Expression, "matches", matches, "err", err)
+	}
+
+	var missingErr *cel.MissingAttributeError
+	if alloc.missingAttributeBehavior == MissingAttributeExclude && errors.As(err, &missingErr) {
 		if class != nil {
-			alloc.logger.V(7).Info("CEL result", "device", deviceID, "class", klog.KObj(class), "selector", i, "expression", selector.CEL.Expression, "matches", matches, "err", err)
+			alloc.logger.V(5).Info("Excluding device because a selector looks up a missing attribute", "device", deviceID, "class", klog.KObj(class), "selector", i, "reason", err.Error())
 		} else {
-			alloc.logger.V(7).Info("CEL result", "device", deviceID, "claim", klog.KObj(alloc.claimsToAllocate[r.claimIndex]), "selector", i, "expression", selector.CEL.Expression, "matches", matches, "err", err)
+			alloc.logger.V(5).Info("Excluding device because a selector looks up a missing attribute", "device", deviceID, "claim", klog.KObj(alloc.claimsToAllocate[r.claimIndex]), "selector", i, "reason", err.Error())
 		}
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("CEL runtime error: %w", err)
+	}
+	return matches, nil
+}
 
-		if err != nil {
-			// TODO (future): more detailed errors which reference class resp. claim.
-			if class != nil {
-				return false, fmt.Errorf("class %s: selector #%d: CEL runtime error: %w", class.Name, i, err)
+// attributeSelectorMatches evaluates the attribute selector with index i of
+// a class (if not nil) or of the claim. Devices without the attribute
+// don't match.
+func (alloc *allocator) attributeSelectorMatches(r requestIndices, device *resourceapi.BasicDevice, deviceID DeviceID, class *resourceapi.DeviceClass, i int, selector *resourceapi.AttributeDeviceSelector) (bool, error) {
+	matches, err := attributeMatches(device, deviceID, selector)
+	if class != nil {
+		alloc.logger.V(7).Info("Attribute selector result", "device", deviceID, "class", klog.KObj(class), "selector", i, "condition", describeAttributeSelector(selector), "matches", matches, "err", err)
+	} else {
+		alloc.logger.V(7).Info("Attribute selector result", "device", deviceID, "claim", klog.KObj(alloc.claimsToAllocate[r.claimIndex]), "selector", i, "condition", describeAttributeSelector(selector), "matches", matches, "err", err)
+	}
+
+	if err != nil {
+		return false, err
+	}
+	return matches, nil
+}
+
+func attributeMatches(device *resourceapi.BasicDevice, deviceID DeviceID, selector *resourceapi.AttributeDeviceSelector) (bool, error) {
+	attribute := lookupAttribute(device, deviceID, selector.Name)
+	if attribute == nil {
+		return false, nil
+	}
+
+	var value string
+	switch {
+	case attribute.StringValue != nil:
+		value = *attribute.StringValue
+	case attribute.BoolValue != nil:
+		value = strconv.FormatBool(*attribute.BoolValue)
+	default:
+		return false, fmt.Errorf("attribute %s of device %s is neither a string nor a boolean", selector.Name, deviceID)
+	}
+
+	found := slices.Contains(selector.Values, value)
+	switch selector.Operator {
+	case resourceapi.AttributeSelectorOpIn:
+		return found, nil
+	case resourceapi.AttributeSelectorOpNotEquals, resourceapi.AttributeSelectorOpNotIn:
+		return !found, nil
+	default:
+		// Unknown future operator!
+		return false, fmt.Errorf("attribute %s: unsupported operator %q", selector.Name, selector.Operator)
+	}
+}
+
+// describeAttributeSelector returns a short, human-readable description
+// of the condition checked by the selector, for example
+// "attribute model in [A100,H100]".
+func describeAttributeSelector(selector *resourceapi.AttributeDeviceSelector) string {
+	switch selector.Operator {
+	case resourceapi.AttributeSelectorOpNotEquals:
+		return fmt.Sprintf("attribute %s != %s", selector.Name, strings.Join(selector.Values, ","))
+	case resourceapi.AttributeSelectorOpIn:
+		return fmt.Sprintf("attribute %s in [%s]", selector.Name, strings.Join(selector.Values, ","))
+	case resourceapi.AttributeSelectorOpNotIn:
+		return fmt.Sprintf("attribute %s not in [%s]", selector.Name, strings.Join(selector.Values, ","))
+	default:
+		return fmt.Sprintf("attribute %s %s [%s]", selector.Name, selector.Operator, strings.Join(selector.Values, ","))
+	}
+}
+
+// logUnmatchedAttributeSelectors gets called when allocation failed. For
+// each attribute selector in the requests of the claims it checks whether
+// any device in the pools satisfies it. If none does, that explains why
+// the claim cannot be allocated better than a generic failure.
+func (alloc *allocator) logUnmatchedAttributeSelectors() {
+	loggerV := alloc.logger.V(5)
+	if !loggerV.Enabled() {
+		return
+	}
+	for _, claim := range alloc.claimsToAllocate {
+		for _, request := range claim.Spec.Devices.Requests {
+			for _, selector := range request.Selectors {
+				if selector.Attribute == nil || alloc.anyDeviceMatches(selector.Attribute) {
+					continue
+				}
+				loggerV.Info("Claim cannot be allocated", "claim", klog.KObj(claim), "request", request.Name, "reason", "no device with "+describeAttributeSelector(selector.Attribute))
 			}
-			return false, fmt.Errorf("claim %s: selector #%d: CEL runtime error: %w", klog.KObj(alloc.claimsToAllocate[r.claimIndex]), i, err)
-		}
-		if !matches {
-			return false, nil
 		}
 	}
+}
 
-	// All of them match.
-	return true, nil
+// anyDeviceMatches returns true if some device in the pools satisfies
+// the attribute selector, regardless of whether it is allocated.
+func (alloc *allocator) anyDeviceMatches(selector *resourceapi.AttributeDeviceSelector) bool {
+	for _, pool := range alloc.pools {
+		for _, slice := range pool.Slices {
+			for _, device := range slice.Spec.Devices {
+				if device.Basic == nil {
+					continue
+				}
+				deviceID := DeviceID{Driver: slice.Spec.Driver, Pool: slice.Spec.Pool.Name, Device: device.Name}
+				if matches, err := attributeMatches(device.Basic, deviceID, selector); matches || err != nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
 }
 
 // allocateDevice checks device availability and constraints for one
@@ -699,9 +1696,21 @@ func (alloc *allocator) allocateDevice(r deviceIndices, device *resourceapi.Basi
 	claim := alloc.claimsToAllocate[r.claimIndex]
 	request := &claim.Spec.Devices.Requests[r.requestIndex]
 	adminAccess := request.AdminAccess
-	if !adminAccess && alloc.allocated[deviceID] {
+	shared := alloc.isShared(request)
+	switch {
+	case adminAccess:
+		// Can always be used.
+	case alloc.isAllocated(deviceID):
 		alloc.logger.V(7).Info("Device in use", "device", deviceID)
 		return false, nil, nil
+	case !shared && alloc.consumedCapacity(deviceID) != nil:
+		alloc.logger.V(7).Info("Device in use by claims which share it", "device", deviceID)
+		return false, nil, nil
+	case shared:
+		if name, ok := alloc.hasCapacity(device, deviceID, request.Capacity); !ok {
+			alloc.logger.V(7).Info("Device has insufficient capacity", "device", deviceID, "capacity", name)
+			return false, nil, nil
+		}
 	}
 
 	// It's available. Now check constraints.
@@ -725,7 +1734,11 @@ func (alloc *allocator) allocateDevice(r deviceIndices, device *resourceapi.Basi
 	// All constraints satisfied. Mark as in use (unless we do admin access)
 	// and record the result.
 	alloc.logger.V(7).Info("Device allocated", "device", deviceID)
-	if !adminAccess {
+	previousConsumed := alloc.consumedCapacity(deviceID)
+	switch {
+	case shared:
+		alloc.consumed[deviceID] = addCapacity(previousConsumed, request.Capacity)
+	case !adminAccess:
 		alloc.allocated[deviceID] = true
 	}
 	result := resourceapi.DeviceRequestAllocationResult{
@@ -734,6 +1747,12 @@ func (alloc *allocator) allocateDevice(r deviceIndices, device *resourceapi.Basi
 		Pool:    deviceID.Pool,
 		Device:  deviceID.Device,
 	}
+	if shared {
+		result.ConsumedCapacity = make(map[resourceapi.QualifiedName]resource.Quantity, len(request.Capacity))
+		for name, quantity := range request.Capacity {
+			result.ConsumedCapacity[name] = quantity.DeepCopy()
+		}
+	}
 	previousNumResults := len(alloc.result[r.claimIndex].Devices.Results)
 	alloc.result[r.claimIndex].Devices.Results = append(alloc.result[r.claimIndex].Devices.Results, result)
 
@@ -741,7 +1760,12 @@ func (alloc *allocator) allocateDevice(r deviceIndices, device *resourceapi.Basi
 		for _, constraint := range alloc.constraints[r.claimIndex] {
 			constraint.remove(request.Name, device, deviceID)
 		}
-		if !adminAccess {
+		switch {
+		case shared && previousConsumed == nil:
+			delete(alloc.consumed, deviceID)
+		case shared:
+			alloc.consumed[deviceID] = previousConsumed
+		case !adminAccess:
 			alloc.allocated[deviceID] = false
 		}
 		// Truncate, but keep the underlying slice.
@@ -750,6 +1774,47 @@ func (alloc *allocator) allocateDevice(r deviceIndices, device *resourceapi.Basi
 	}, nil
 }
 
+// isShared returns true if the device for the request is going to be
+// shared with other claims which consume some of its capacity.
+func (alloc *allocator) isShared(request *resourceapi.DeviceRequest) bool {
+	return !request.AdminAccess && alloc.features.ConsumableCapacity && len(request.Capacity) > 0
+}
+
+// hasCapacity checks whether the device still has enough of each
+// requested capacity after taking into account what is already consumed
+// by other claims. If not, it returns the name of the first capacity which
+// is missing or insufficient.
+func (alloc *allocator) hasCapacity(device *resourceapi.BasicDevice, deviceID DeviceID, requested map[resourceapi.QualifiedName]resource.Quantity) (resourceapi.QualifiedName, bool) {
+	consumed := alloc.consumedCapacity(deviceID)
+	for name, quantity := range requested {
+		available, ok := device.Capacity[name]
+		if !ok {
+			return name, false
+		}
+		sum := consumed[name].DeepCopy()
+		sum.Add(quantity)
+		if sum.Cmp(available) > 0 {
+			return name, false
+		}
+	}
+	return "", true
+}
+
+// addCapacity returns a new map with the sum of both capacities.
+// Neither input gets modified.
+func addCapacity(consumed, requested map[resourceapi.QualifiedName]resource.Quantity) map[resourceapi.QualifiedName]resource.Quantity {
+	sum := make(map[resourceapi.QualifiedName]resource.Quantity, len(consumed)+len(requested))
+	for name, quantity := range consumed {
+		sum[name] = quantity.DeepCopy()
+	}
+	for name, quantity := range requested {
+		total := sum[name]
+		total.Add(quantity)
+		sum[name] = total
+	}
+	return sum
+}
+
 // createNodeSelector constructs a node selector for the allocation, if needed,
 // otherwise it returns nil.
 func (alloc *allocator) createNodeSelector(allocation *resourceapi.AllocationResult) (*v1.NodeSelector, error) {
@@ -764,31 +1829,19 @@ func (alloc *allocator) createNodeSelector(allocation *resourceapi.AllocationRes
 		if slice == nil {
 			return nil, fmt.Errorf("internal error: device %+v not found in pools", deviceAllocation)
 		}
+		sliceSelector, err := NodeSelectorForSlice(slice)
+		if err != nil {
+			return nil, err
+		}
 		if slice.Spec.NodeName != "" {
 			// At least one device is local to one node. This
 			// restricts the allocation to that node.
-			return &v1.NodeSelector{
-				NodeSelectorTerms: []v1.NodeSelectorTerm{{
-					MatchFields: []v1.NodeSelectorRequirement{{
-						Key:      "metadata.name",
-						Operator: v1.NodeSelectorOpIn,
-						Values:   []string{slice.Spec.NodeName},
-					}},
-				}},
-			}, nil
-		}
-		if slice.Spec.NodeSelector != nil {
-			switch len(slice.Spec.NodeSelector.NodeSelectorTerms) {
-			case 0:
-				// Nothing?
-			case 1:
-				// Add all terms if they are not present already.
-				addNewNodeSelectorRequirements(slice.Spec.NodeSelector.NodeSelectorTerms[0].MatchFields, &nodeSelector.NodeSelectorTerms[0].MatchFields)
-				addNewNodeSelectorRequirements(slice.Spec.NodeSelector.NodeSelectorTerms[0].MatchExpressions, &nodeSelector.NodeSelectorTerms[0].MatchExpressions)
-			default:
-				// This shouldn't occur, validation must prevent creation of such slices.
-				return nil, fmt.Errorf("unsupported ResourceSlice.NodeSelector with %d terms", len(slice.Spec.NodeSelector.NodeSelectorTerms))
-			}
+			return sliceSelector, nil
+		}
+		if sliceSelector != nil && len(sliceSelector.NodeSelectorTerms) == 1 {
+			// Add all terms if they are not present already.
+			addNewNodeSelectorRequirements(sliceSelector.NodeSelectorTerms[0].MatchFields, &nodeSelector.NodeSelectorTerms[0].MatchFields)
+			addNewNodeSelectorRequirements(sliceSelector.NodeSelectorTerms[0].MatchExpressions, &nodeSelector.NodeSelectorTerms[0].MatchExpressions)
 		}
 	}
 
@@ -801,6 +1854,9 @@ func (alloc *allocator) createNodeSelector(allocation *resourceapi.AllocationRes
 	return nil, nil
 }
 
+// findSlice returns the slice with the allocated device. A node may have
+// several pools of the same driver with the same device names, so the
+// pool must match, too.
 func (alloc *allocator) findSlice(deviceAllocation resourceapi.DeviceRequestAllocationResult) *resourceapi.ResourceSlice {
 	for _, pool := range alloc.pools {
 		if pool.Driver != deviceAllocation.Driver ||
@@ -818,6 +1874,60 @@ func (alloc *allocator) findSlice(deviceAllocation resourceapi.DeviceRequestAllo
 	return nil
 }
 
+// NodeSelectorForSlice returns the node selector which describes where the
+// devices of the slice are accessible:
+//   - a single term with a single "metadata.name In [<node name>]" field
+//     requirement for node-local slices
+//   - a copy of the node selector of the slice for network-attached devices
+//   - nil for devices which are available on all nodes
+//
+// Slices with more than one node selector term are not supported because
+// the terms could not be merged into the single term of an allocation result.
+func NodeSelectorForSlice(slice *resourceapi.ResourceSlice) (*v1.NodeSelector, error) {
+	switch {
+	case slice.Spec.NodeName != "":
+		return &v1.NodeSelector{
+			NodeSelectorTerms: []v1.NodeSelectorTerm{{
+				MatchFields: []v1.NodeSelectorRequirement{{
+					Key:      "metadata.name",
+					Operator: v1.NodeSelectorOpIn,
+					Values:   []string{slice.Spec.NodeName},
+				}},
+			}},
+		}, nil
+	case slice.Spec.NodeSelector != nil:
+		switch len(slice.Spec.NodeSelector.NodeSelectorTerms) {
+		case 0:
+			// Nothing?
+			return nil, nil
+		case 1:
+			return slice.Spec.NodeSelector.DeepCopy(), nil
+		default:
+			// This shouldn't occur, validation must prevent creation of such slices.
+			return nil, fmt.Errorf("unsupported ResourceSlice.NodeSelector with %d terms in slice %s", len(slice.Spec.NodeSelector.NodeSelectorTerms), slice.Name)
+		}
+	default:
+		// AllNodes or some future, unknown extension.
+		return nil, nil
+	}
+}
+
+// checkNodeSelector ensures that a node selector is valid and, if a node
+// is given, matches that node.
+func checkNodeSelector(nodeSelector *v1.NodeSelector, node *v1.Node) error {
+	if nodeSelector == nil {
+		return nil
+	}
+	selector, err := nodeaffinity.NewNodeSelector(nodeSelector)
+	if err != nil {
+		return fmt.Errorf("invalid node selector: %w", err)
+	}
+	if node != nil && !selector.Match(node) {
+		return fmt.Errorf("node selector does not match node %s", node.Name)
+	}
+	return nil
+}
+
 func addNewNodeSelectorRequirements(from []v1.NodeSelectorRequirement, to *[]v1.NodeSelectorRequirement) {
 	for _, requirement := range from {
 		if !containsNodeSelectorRequirement(*to, requirement) {
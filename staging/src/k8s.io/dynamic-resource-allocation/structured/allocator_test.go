@@ -17,6 +17,7 @@ limitations under the License.
 package structured
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -58,10 +59,12 @@ const (
 	req3    = "req-3"
 	claim0  = "claim-0"
 	claim1  = "claim-1"
+	claim2  = "claim-2"
 	slice1  = "slice-1"
 	slice2  = "slice-2"
 	device1 = "device-1"
 	device2 = "device-2"
+	device3 = "device-3"
 )
 
 func init() {
@@ -163,6 +166,33 @@ func request(name, class string, count int64, selectors ...resourceapi.DeviceSel
 	}
 }
 
+// generate a DeviceSelector which compares an attribute against the values.
+func attributeSelector(name resourceapi.FullyQualifiedName, operator resourceapi.AttributeSelectorOperator, values ...string) resourceapi.DeviceSelector {
+	return resourceapi.DeviceSelector{
+		Attribute: &resourceapi.AttributeDeviceSelector{
+			Name:     name,
+			Operator: operator,
+			Values:   values,
+		},
+	}
+}
+
+// generate a DeviceRequest object with the given name and class which
+// consumes the given capacity of the allocated device.
+func requestWithCapacity(name, class string, capacity map[resourceapi.QualifiedName]resource.Quantity) resourceapi.DeviceRequest {
+	request := request(name, class, 1)
+	request.Capacity = capacity
+	return request
+}
+
+// generate a DeviceRequest object with the given name and class which
+// only accepts devices from pools which are local to the node.
+func nodeLocalOnlyRequest(name, class string) resourceapi.DeviceRequest {
+	request := request(name, class, 1)
+	request.NodeLocalOnly = true
+	return request
+}
+
 // generate a ResourceClaim object with the given name, request and class.
 func claim(name, req, class string, constraints ...resourceapi.DeviceConstraint) *resourceapi.ResourceClaim {
 	claim := claimWithRequests(name, constraints, request(req, class, 1))
@@ -203,6 +233,13 @@ func device(name string, capacity map[resourceapi.QualifiedName]resource.Quantit
 	}
 }
 
+// generate a Device object with the given name and taints.
+func taintedDevice(name string, taints ...v1.Taint) resourceapi.Device {
+	device := device(name, nil, nil)
+	device.Basic.Taints = taints
+	return device
+}
+
 // generate a ResourceSlice object with the given name, node,
 // driver and pool names, generation and a list of devices.
 // The nodeSelection parameter may be a string (= node name),
@@ -249,6 +286,12 @@ func deviceAllocationResult(request, driver, pool, device string) resourceapi.De
 	}
 }
 
+func sharedDeviceAllocationResult(request, driver, pool, device string, consumedCapacity map[resourceapi.QualifiedName]resource.Quantity) resourceapi.DeviceRequestAllocationResult {
+	result := deviceAllocationResult(request, driver, pool, device)
+	result.ConsumedCapacity = consumedCapacity
+	return result
+}
+
 // nodeLabelSelector creates a node selector with a label match for "key" in "values".
 func nodeLabelSelector(key string, values ...string) *v1.NodeSelector {
 	requirements := []v1.NodeSelectorRequirement{{
@@ -321,21 +364,28 @@ func matchNodeSelectorRequirement(requirement v1.NodeSelectorRequirement) types.
 	})
 }
 
-func allocationResultWithConfig(selector *v1.NodeSelector, driver string, source resourceapi.AllocationConfigSource, attribute string, results ...resourceapi.DeviceRequestAllocationResult) *resourceapi.AllocationResult {
+func allocationResultWithConfig(selector *v1.NodeSelector, driver string, source resourceapi.AllocationConfigSource, requests []string, attribute string, results ...resourceapi.DeviceRequestAllocationResult) *resourceapi.AllocationResult {
+	return allocationResultWithConfigs(selector, results, allocationConfig(driver, source, requests, attribute))
+}
+
+func allocationResultWithConfigs(selector *v1.NodeSelector, results []resourceapi.DeviceRequestAllocationResult, configs ...resourceapi.DeviceAllocationConfiguration) *resourceapi.AllocationResult {
 	return &resourceapi.AllocationResult{
 		Devices: resourceapi.DeviceAllocationResult{
 			Results: results,
-			Config: []resourceapi.DeviceAllocationConfiguration{
-				{
-					Source:              source,
-					DeviceConfiguration: deviceConfiguration(driver, attribute),
-				},
-			},
+			Config:  configs,
 		},
 		NodeSelector: selector,
 	}
 }
 
+func allocationConfig(driver string, source resourceapi.AllocationConfigSource, requests []string, attribute string) resourceapi.DeviceAllocationConfiguration {
+	return resourceapi.DeviceAllocationConfiguration{
+		Source:              source,
+		Requests:            requests,
+		DeviceConfiguration: deviceConfiguration(driver, attribute),
+	}
+}
+
 // Helpers
 
 // convert a list of objects to a slice
@@ -348,23 +398,86 @@ func sliceWithOneDevice(name string, nodeSelection any, pool, driver string) *re
 	return slice(name, nodeSelection, pool, driver, device(device1, nil, nil))
 }
 
+type allocatorTestCase struct {
+	claimsToAllocate         []*resourceapi.ResourceClaim
+	allocatedClaims          []*resourceapi.ResourceClaim
+	classes                  []*resourceapi.DeviceClass
+	slices                   []*resourceapi.ResourceSlice
+	node                     *v1.Node
+	features                 Features
+	antiAffinity             *AntiAffinity
+	tolerations              []v1.Toleration
+	selectionPolicy          SelectionPolicy
+	missingAttributeBehavior MissingAttributeBehavior
+	hints                    *Hints
+	scorers                  []DeviceScorer
+
+	expectResults []any
+	expectError   types.GomegaMatcher // can be used to check for no error or match specific error types
+}
+
 func TestAllocator(t *testing.T) {
 	nonExistentAttribute := resourceapi.FullyQualifiedName("NonExistentAttribute")
 	boolAttribute := resourceapi.FullyQualifiedName("boolAttribute")
 	stringAttribute := resourceapi.FullyQualifiedName("stringAttribute")
 	versionAttribute := resourceapi.FullyQualifiedName("driverVersion")
 	intAttribute := resourceapi.FullyQualifiedName("numa")
+	stringListAttribute := resourceapi.FullyQualifiedName("precisions")
+
+	// Two devices which differ in their model.
+	modelAttribute := resourceapi.FullyQualifiedName(driverA + "/model")
+	modelSlice := slice(slice1, node1, pool1, driverA,
+		device(device1, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+			"model":  {StringValue: ptr.To("A100")},
+			"shared": {BoolValue: ptr.To(false)},
+			"memory": {IntValue: ptr.To(int64(40))},
+		}),
+		device(device2, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+			"model":  {StringValue: ptr.To("H100")},
+			"shared": {BoolValue: ptr.To(true)},
+			"memory": {IntValue: ptr.To(int64(80))},
+		}),
+	)
 
-	testcases := map[string]struct {
-		claimsToAllocate []*resourceapi.ResourceClaim
-		allocatedClaims  []*resourceapi.ResourceClaim
-		classes          []*resourceapi.DeviceClass
-		slices           []*resourceapi.ResourceSlice
-		node             *v1.Node
-
-		expectResults []any
-		expectError   types.GomegaMatcher // can be used to check for no error or match specific error types
-	}{
+	// Only device-2 has the "healthy" attribute.
+	healthySelector := resourceapi.DeviceSelector{
+		CEL: &resourceapi.CELDeviceSelector{
+			Expression: fmt.Sprintf(`device.attributes["%s"].healthy`, driverA),
+		},
+	}
+	partiallyHealthySlice := slice(slice1, node1, pool1, driverA,
+		device(device1, nil, nil),
+		device(device2, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+			"healthy": {BoolValue: ptr.To(true)},
+		}),
+	)
+
+	taint := v1.Taint{Key: "example.com/unhealthy", Value: "true", Effect: v1.TaintEffectNoSchedule}
+
+	// Two devices with the same capacity, device-1 with 6Gi
+	// and device-2 with 2Gi of memory left.
+	capacitySlice := slice(slice1, node1, pool1, driverA,
+		device(device1, map[resourceapi.QualifiedName]resource.Quantity{
+			"memory": resource.MustParse("8Gi"),
+		}, nil),
+		device(device2, map[resourceapi.QualifiedName]resource.Quantity{
+			"memory": resource.MustParse("8Gi"),
+		}, nil),
+	)
+	partiallyConsumedDevices := objects(
+		allocatedClaim(claim1, req0, classA,
+			sharedDeviceAllocationResult(req0, driverA, pool1, device1, map[resourceapi.QualifiedName]resource.Quantity{
+				"memory": resource.MustParse("2Gi"),
+			}),
+		),
+		allocatedClaim(claim2, req0, classA,
+			sharedDeviceAllocationResult(req0, driverA, pool1, device2, map[resourceapi.QualifiedName]resource.Quantity{
+				"memory": resource.MustParse("6Gi"),
+			}),
+		),
+	)
+
+	testcases := map[string]allocatorTestCase{
 
 		"empty": {},
 		"simple": {
@@ -378,6 +491,25 @@ func TestAllocator(t *testing.T) {
 				deviceAllocationResult(req0, driverA, pool1, device1),
 			)},
 		},
+		"multiple-pools-one-node": {
+			// Both pools have a device with the same name, so
+			// they must be told apart by pool. The hint makes
+			// the order of pools deterministic.
+			claimsToAllocate: objects(claimWithRequests(claim0, nil, request(req0, classA, 1), request(req1, classA, 1))),
+			classes:          objects(class(classA, driverA)),
+			slices: objects(
+				sliceWithOneDevice(slice1, node1, pool1, driverA),
+				sliceWithOneDevice(slice2, node1, pool2, driverA),
+			),
+			node:  node(node1, region1),
+			hints: &Hints{Pools: []PoolID{{Driver: driverA, Pool: pool1}}},
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device1),
+				deviceAllocationResult(req1, driverA, pool2, device1),
+			)},
+		},
 		"other-node": {
 			claimsToAllocate: objects(claim(claim0, req0, classA)),
 			classes:          objects(class(classA, driverA)),
@@ -392,219 +524,687 @@ func TestAllocator(t *testing.T) {
 				deviceAllocationResult(req0, driverA, pool2, device1),
 			)},
 		},
-		"small-and-large": {
+		"many-broken-selectors": {
 			claimsToAllocate: objects(claimWithRequests(
 				claim0,
 				nil,
-				request(req0, classA, 1, resourceapi.DeviceSelector{
-					CEL: &resourceapi.CELDeviceSelector{
-						Expression: fmt.Sprintf(`device.capacity["%s"].memory.compareTo(quantity("1Gi")) >= 0`, driverA),
-					}}),
-				request(req1, classA, 1, resourceapi.DeviceSelector{
-					CEL: &resourceapi.CELDeviceSelector{
-						Expression: fmt.Sprintf(`device.capacity["%s"].memory.compareTo(quantity("2Gi")) >= 0`, driverA),
-					}}),
+				request(req0, classA, 1, func() []resourceapi.DeviceSelector {
+					var selectors []resourceapi.DeviceSelector
+					for i := 0; i < 5; i++ {
+						selectors = append(selectors, resourceapi.DeviceSelector{
+							CEL: &resourceapi.CELDeviceSelector{
+								Expression: fmt.Sprintf(`device.attributes["%s"].missing%d`, driverA, i),
+							}})
+					}
+					return selectors
+				}()...),
 			)),
 			classes: objects(class(classA, driverA)),
-			slices: objects(slice(slice1, node1, pool1, driverA,
-				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
-					"memory": resource.MustParse("1Gi"),
-				}, nil),
-				device(device2, map[resourceapi.QualifiedName]resource.Quantity{
-					"memory": resource.MustParse("2Gi"),
-				}, nil),
-			)),
-			node: node(node1, region1),
+			slices:  objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			node:    node(node1, region1),
 
-			expectResults: []any{allocationResult(
-				localNodeSelector(node1),
-				deviceAllocationResult(req0, driverA, pool1, device1),
-				deviceAllocationResult(req1, driverA, pool1, device2),
-			)},
+			expectResults: nil,
+			expectError:   gomega.MatchError(gomega.ContainSubstring("claim claim-0: selector #0: CEL runtime error: no such key: missing0 (and 4 more selector errors)")),
 		},
-		"small-and-large-backtrack-requests": {
+		"too-many-broken-selectors": {
 			claimsToAllocate: objects(claimWithRequests(
 				claim0,
 				nil,
-				request(req0, classA, 1, resourceapi.DeviceSelector{
-					CEL: &resourceapi.CELDeviceSelector{
-						Expression: fmt.Sprintf(`device.capacity["%s"].memory.compareTo(quantity("1Gi")) >= 0`, driverA),
-					}}),
-				request(req1, classA, 1, resourceapi.DeviceSelector{
-					CEL: &resourceapi.CELDeviceSelector{
-						Expression: fmt.Sprintf(`device.capacity["%s"].memory.compareTo(quantity("2Gi")) >= 0`, driverA),
-					}}),
+				request(req0, classA, 1, func() []resourceapi.DeviceSelector {
+					var selectors []resourceapi.DeviceSelector
+					for i := 0; i < 20; i++ {
+						selectors = append(selectors, resourceapi.DeviceSelector{
+							CEL: &resourceapi.CELDeviceSelector{
+								Expression: fmt.Sprintf(`device.attributes["%s"].missing%d`, driverA, i),
+							}})
+					}
+					return selectors
+				}()...),
 			)),
 			classes: objects(class(classA, driverA)),
-			// Reversing the order in which the devices are listed causes the "large" device to
-			// be allocated for the "small" request, leaving the "large" request unsatisfied.
-			// The initial decision needs to be undone before a solution is found.
-			slices: objects(slice(slice1, node1, pool1, driverA,
-				device(device2, map[resourceapi.QualifiedName]resource.Quantity{
-					"memory": resource.MustParse("2Gi"),
-				}, nil),
-				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
-					"memory": resource.MustParse("1Gi"),
-				}, nil),
-			)),
-			node: node(node1, region1),
+			slices:  objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			node:    node(node1, region1),
+
+			expectResults: nil,
+			expectError:   gomega.MatchError(gomega.ContainSubstring("claim claim-0: selector #0: CEL runtime error: no such key: missing0 (and at least 9 more selector errors)")),
+		},
+		"missing-attribute-error": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil, request(req0, classA, 1, healthySelector))),
+			classes:          objects(class(classA, driverA)),
+			slices:           objects(partiallyHealthySlice),
+			node:             node(node1, region1),
+
+			expectResults: nil,
+			expectError: gomega.And(
+				gomega.MatchError(gomega.ContainSubstring("claim claim-0: selector #0: CEL runtime error: no such key: healthy")),
+				gomega.HaveField("Request", req0),
+				gomega.HaveField("Index", 0),
+			),
+		},
+		"missing-attribute-exclude": {
+			claimsToAllocate:         objects(claimWithRequests(claim0, nil, request(req0, classA, 1, healthySelector))),
+			classes:                  objects(class(classA, driverA)),
+			slices:                   objects(partiallyHealthySlice),
+			node:                     node(node1, region1),
+			missingAttributeBehavior: MissingAttributeExclude,
 
 			expectResults: []any{allocationResult(
 				localNodeSelector(node1),
-				deviceAllocationResult(req0, driverA, pool1, device1),
-				deviceAllocationResult(req1, driverA, pool1, device2),
+				deviceAllocationResult(req0, driverA, pool1, device2),
 			)},
 		},
-		"small-and-large-backtrack-claims": {
-			claimsToAllocate: objects(
-				claimWithRequests(
-					claim0,
-					nil,
-					request(req0, classA, 1, resourceapi.DeviceSelector{
-						CEL: &resourceapi.CELDeviceSelector{
-							Expression: fmt.Sprintf(`device.capacity["%s"].memory.compareTo(quantity("1Gi")) >= 0`, driverA),
-						}})),
-				claimWithRequests(
-					claim1,
-					nil,
-					request(req1, classA, 1, resourceapi.DeviceSelector{
-						CEL: &resourceapi.CELDeviceSelector{
-							Expression: fmt.Sprintf(`device.capacity["%s"].memory.compareTo(quantity("2Gi")) >= 0`, driverA),
-						}}),
-				)),
-			classes: objects(class(classA, driverA)),
-			// Reversing the order in which the devices are listed causes the "large" device to
-			// be allocated for the "small" request, leaving the "large" request unsatisfied.
-			// The initial decision needs to be undone before a solution is found.
-			slices: objects(slice(slice1, node1, pool1, driverA,
-				device(device2, map[resourceapi.QualifiedName]resource.Quantity{
-					"memory": resource.MustParse("2Gi"),
-				}, nil),
-				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
-					"memory": resource.MustParse("1Gi"),
-				}, nil),
-			)),
-			node: node(node1, region1),
+		"missing-attribute-exclude-all": {
+			claimsToAllocate:         objects(claimWithRequests(claim0, nil, request(req0, classA, 2, healthySelector))),
+			classes:                  objects(class(classA, driverA)),
+			slices:                   objects(partiallyHealthySlice),
+			node:                     node(node1, region1),
+			missingAttributeBehavior: MissingAttributeExclude,
 
-			expectResults: []any{
-				allocationResult(localNodeSelector(node1), deviceAllocationResult(req0, driverA, pool1, device1)),
-				allocationResult(localNodeSelector(node1), deviceAllocationResult(req1, driverA, pool1, device2)),
-			},
+			expectResults: nil,
 		},
-		"devices-split-across-different-slices": {
-			claimsToAllocate: objects(claimWithRequests(claim0, nil, resourceapi.DeviceRequest{
-				Name:            req0,
-				Count:           2,
-				AllocationMode:  resourceapi.DeviceAllocationModeExactCount,
-				DeviceClassName: classA,
-			})),
-			classes: objects(class(classA, driverA)),
+		"missing-attribute-exclude-other-error": {
+			// Only lookups of missing attributes are affected. device-1
+			// gets excluded, comparing the attribute of device-2 fails.
+			claimsToAllocate: objects(claimWithRequests(claim0, nil, request(req0, classA, 1, resourceapi.DeviceSelector{
+				CEL: &resourceapi.CELDeviceSelector{
+					Expression: fmt.Sprintf(`device.attributes["%s"].healthy > 1`, driverA),
+				},
+			}))),
+			classes:                  objects(class(classA, driverA)),
+			slices:                   objects(partiallyHealthySlice),
+			node:                     node(node1, region1),
+			missingAttributeBehavior: MissingAttributeExclude,
+
+			expectResults: nil,
+			expectError:   gomega.MatchError(gomega.ContainSubstring("CEL runtime error")),
+		},
+		"hint-pool": {
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			classes:          objects(class(classA, driverA)),
 			slices: objects(
 				sliceWithOneDevice(slice1, node1, pool1, driverA),
 				sliceWithOneDevice(slice2, node1, pool2, driverA),
 			),
-			node: node(node1, region1),
+			node:  node(node1, region1),
+			hints: &Hints{Pools: []PoolID{{Driver: driverA, Pool: pool2}}},
 
 			expectResults: []any{allocationResult(
 				localNodeSelector(node1),
-				deviceAllocationResult(req0, driverA, pool1, device1),
 				deviceAllocationResult(req0, driverA, pool2, device1),
 			)},
 		},
-		"obsolete-slice": {
+		"hint-device": {
 			claimsToAllocate: objects(claim(claim0, req0, classA)),
 			classes:          objects(class(classA, driverA)),
-			slices: objects(
-				sliceWithOneDevice("slice-1-obsolete", node1, pool1, driverA),
-				func() *resourceapi.ResourceSlice {
-					slice := sliceWithOneDevice(slice1, node1, pool1, driverA)
-					// This makes the other slice obsolete.
-					slice.Spec.Pool.Generation++
-					return slice
-				}(),
-			),
-			node: node(node1, region1),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, nil, nil),
+				device(device2, nil, nil),
+				device(device3, nil, nil),
+			)),
+			node:  node(node1, region1),
+			hints: &Hints{Devices: []DeviceID{{Driver: driverA, Pool: pool1, Device: device3}}},
 
 			expectResults: []any{allocationResult(
 				localNodeSelector(node1),
-				deviceAllocationResult(req0, driverA, pool1, device1),
+				deviceAllocationResult(req0, driverA, pool1, device3),
 			)},
 		},
-		"no-slices": {
+		"scorer": {
 			claimsToAllocate: objects(claim(claim0, req0, classA)),
 			classes:          objects(class(classA, driverA)),
-			slices:           nil,
-			node:             node(node1, region1),
-
-			expectResults: nil,
-		},
-		"not-enough-suitable-devices": {
-			claimsToAllocate: objects(claim(claim0, req0, classA), claim(claim0, req1, classA)),
-			classes:          objects(class(classA, driverA)),
-			slices:           objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
-
-			node: node(node1, region1),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, nil, nil),
+				device(device2, nil, nil),
+				device(device3, nil, nil),
+			)),
+			node:    node(node1, region1),
+			scorers: []DeviceScorer{preferDeviceScorer{device: device2}},
 
-			expectResults: nil,
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device2),
+			)},
 		},
-		"no-classes": {
+		"scorer-tie": {
+			// No device is preferred, so the default order applies.
 			claimsToAllocate: objects(claim(claim0, req0, classA)),
-			classes:          nil,
-			slices:           objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
-			node:             node(node1, region1),
-
-			expectResults: nil,
-			expectError:   gomega.MatchError(gomega.ContainSubstring("could not retrieve device class class-a")),
-		},
-		"unknown-class": {
-			claimsToAllocate: objects(claim(claim0, req0, "unknown-class")),
-			classes:          objects(class(classA, driverA)),
-			slices:           objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
-			node:             node(node1, region1),
-
-			expectResults: nil,
-			expectError:   gomega.MatchError(gomega.ContainSubstring("could not retrieve device class unknown-class")),
-		},
-		"empty-class": {
-			claimsToAllocate: objects(claim(claim0, req0, "")),
 			classes:          objects(class(classA, driverA)),
-			slices:           objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
-			node:             node(node1, region1),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, nil, nil),
+				device(device2, nil, nil),
+				device(device3, nil, nil),
+			)),
+			node:    node(node1, region1),
+			scorers: []DeviceScorer{preferDeviceScorer{device: "no-such-device"}},
 
-			expectResults: nil,
-			expectError:   gomega.MatchError(gomega.ContainSubstring("claim claim-0, request req-0: missing device class name (unsupported request type?)")),
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device1),
+			)},
 		},
-		"no-claims-to-allocate": {
-			claimsToAllocate: nil,
+		"scorer-panic": {
+			// The panic counts as score zero, the other scorer still
+			// gets considered.
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
 			classes:          objects(class(classA, driverA)),
-			slices:           objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
-			node:             node(node1, region1),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, nil, nil),
+				device(device2, nil, nil),
+				device(device3, nil, nil),
+			)),
+			node:    node(node1, region1),
+			scorers: []DeviceScorer{panickingScorer{}, preferDeviceScorer{device: device3}},
 
-			expectResults: nil,
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device3),
+			)},
 		},
-		"all-devices": {
-			claimsToAllocate: objects(claimWithRequests(claim0, nil, resourceapi.DeviceRequest{
-				Name:            req0,
-				AllocationMode:  resourceapi.DeviceAllocationModeAll,
-				Count:           1,
-				DeviceClassName: classA,
-			})),
+		"hint-unavailable": {
+			// The hinted pool has no free device, so some other
+			// device gets allocated.
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			allocatedClaims: objects(
+				allocatedClaim(claim1, req0, classA,
+					deviceAllocationResult(req0, driverA, pool2, device1),
+				),
+			),
 			classes: objects(class(classA, driverA)),
-			slices:  objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
-			node:    node(node1, region1),
+			slices: objects(
+				sliceWithOneDevice(slice1, node1, pool1, driverA),
+				sliceWithOneDevice(slice2, node1, pool2, driverA),
+			),
+			node:  node(node1, region1),
+			hints: &Hints{Pools: []PoolID{{Driver: driverA, Pool: pool2}}},
 
 			expectResults: []any{allocationResult(
 				localNodeSelector(node1),
 				deviceAllocationResult(req0, driverA, pool1, device1),
 			)},
 		},
-		"all-devices-of-the-incomplete-pool": {
-			claimsToAllocate: objects(claimWithRequests(claim0, nil, resourceapi.DeviceRequest{
-				Name:            req0,
-				AllocationMode:  resourceapi.DeviceAllocationModeAll,
-				Count:           1,
-				DeviceClassName: classA,
-			})),
+		"consumable-capacity-shared": {
+			claimsToAllocate: objects(
+				claimWithRequests(claim0, nil, requestWithCapacity(req0, classA, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("4Gi"),
+				})),
+				claimWithRequests(claim1, nil, requestWithCapacity(req0, classA, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("4Gi"),
+				})),
+			),
+			classes: objects(class(classA, driverA)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("8Gi"),
+				}, nil),
+			)),
+			node:     node(node1, region1),
+			features: Features{ConsumableCapacity: true},
+
+			expectResults: []any{
+				allocationResult(
+					localNodeSelector(node1),
+					sharedDeviceAllocationResult(req0, driverA, pool1, device1, map[resourceapi.QualifiedName]resource.Quantity{
+						"memory": resource.MustParse("4Gi"),
+					}),
+				),
+				allocationResult(
+					localNodeSelector(node1),
+					sharedDeviceAllocationResult(req0, driverA, pool1, device1, map[resourceapi.QualifiedName]resource.Quantity{
+						"memory": resource.MustParse("4Gi"),
+					}),
+				),
+			},
+		},
+		"consumable-capacity-exhausted": {
+			claimsToAllocate: objects(
+				claimWithRequests(claim0, nil, requestWithCapacity(req0, classA, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("4Gi"),
+				})),
+			),
+			allocatedClaims: objects(
+				allocatedClaim(claim1, req0, classA,
+					sharedDeviceAllocationResult(req0, driverA, pool1, device1, map[resourceapi.QualifiedName]resource.Quantity{
+						"memory": resource.MustParse("6Gi"),
+					}),
+				),
+			),
+			classes: objects(class(classA, driverA)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("8Gi"),
+				}, nil),
+			)),
+			node:     node(node1, region1),
+			features: Features{ConsumableCapacity: true},
+
+			expectResults: nil,
+		},
+		"consumable-capacity-missing": {
+			claimsToAllocate: objects(
+				claimWithRequests(claim0, nil, requestWithCapacity(req0, classA, map[resourceapi.QualifiedName]resource.Quantity{
+					"cores": resource.MustParse("1"),
+				})),
+			),
+			classes: objects(class(classA, driverA)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("8Gi"),
+				}, nil),
+			)),
+			node:     node(node1, region1),
+			features: Features{ConsumableCapacity: true},
+
+			expectResults: nil,
+		},
+		"consumable-capacity-blocks-exclusive": {
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			allocatedClaims: objects(
+				allocatedClaim(claim1, req0, classA,
+					sharedDeviceAllocationResult(req0, driverA, pool1, device1, map[resourceapi.QualifiedName]resource.Quantity{
+						"memory": resource.MustParse("1Gi"),
+					}),
+				),
+			),
+			classes:  objects(class(classA, driverA)),
+			slices:   objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			node:     node(node1, region1),
+			features: Features{ConsumableCapacity: true},
+
+			expectResults: nil,
+		},
+		"consumable-capacity-disabled": {
+			claimsToAllocate: objects(
+				claimWithRequests(claim0, nil, requestWithCapacity(req0, classA, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("4Gi"),
+				})),
+				claimWithRequests(claim1, nil, requestWithCapacity(req0, classA, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("4Gi"),
+				})),
+			),
+			classes: objects(class(classA, driverA)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("8Gi"),
+				}, nil),
+			)),
+			node: node(node1, region1),
+
+			// Each device can only be used exclusively.
+			expectResults: nil,
+		},
+		"consumable-capacity-counted": {
+			claimsToAllocate: objects(
+				claimWithRequests(claim0, nil, requestWithCapacity(req0, classA, map[resourceapi.QualifiedName]resource.Quantity{
+					"encoders": resource.MustParse("1"),
+				})),
+			),
+			allocatedClaims: objects(
+				allocatedClaim(claim1, req0, classA,
+					sharedDeviceAllocationResult(req0, driverA, pool1, device1, map[resourceapi.QualifiedName]resource.Quantity{
+						"encoders": resource.MustParse("1"),
+					}),
+				),
+				allocatedClaim(claim2, req0, classA,
+					sharedDeviceAllocationResult(req0, driverA, pool1, device1, map[resourceapi.QualifiedName]resource.Quantity{
+						"encoders": resource.MustParse("1"),
+					}),
+				),
+			),
+			classes: objects(class(classA, driverA)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
+					"encoders": resource.MustParse("2"),
+				}, nil),
+			)),
+			node:     node(node1, region1),
+			features: Features{ConsumableCapacity: true},
+
+			// Both encoders are in use.
+			expectResults: nil,
+		},
+		"consumable-capacity-per-name": {
+			claimsToAllocate: objects(
+				claimWithRequests(claim0, nil, requestWithCapacity(req0, classA, map[resourceapi.QualifiedName]resource.Quantity{
+					"encoders": resource.MustParse("1"),
+					"decoders": resource.MustParse("1"),
+				})),
+			),
+			allocatedClaims: objects(
+				allocatedClaim(claim1, req0, classA,
+					sharedDeviceAllocationResult(req0, driverA, pool1, device1, map[resourceapi.QualifiedName]resource.Quantity{
+						"encoders": resource.MustParse("1"),
+						"decoders": resource.MustParse("1"),
+					}),
+				),
+			),
+			classes: objects(class(classA, driverA)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
+					"encoders": resource.MustParse("4"),
+					"decoders": resource.MustParse("1"),
+				}, nil),
+			)),
+			node:     node(node1, region1),
+			features: Features{ConsumableCapacity: true},
+
+			// Encoders are left, but not decoders.
+			expectResults: nil,
+		},
+		"consumable-capacity-per-name-available": {
+			claimsToAllocate: objects(
+				claimWithRequests(claim0, nil, requestWithCapacity(req0, classA, map[resourceapi.QualifiedName]resource.Quantity{
+					"encoders": resource.MustParse("1"),
+				})),
+			),
+			allocatedClaims: objects(
+				allocatedClaim(claim1, req0, classA,
+					sharedDeviceAllocationResult(req0, driverA, pool1, device1, map[resourceapi.QualifiedName]resource.Quantity{
+						"encoders": resource.MustParse("1"),
+						"decoders": resource.MustParse("1"),
+					}),
+				),
+			),
+			classes: objects(class(classA, driverA)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
+					"encoders": resource.MustParse("2"),
+					"decoders": resource.MustParse("1"),
+				}, nil),
+			)),
+			node:     node(node1, region1),
+			features: Features{ConsumableCapacity: true},
+
+			expectResults: []any{
+				allocationResult(
+					localNodeSelector(node1),
+					sharedDeviceAllocationResult(req0, driverA, pool1, device1, map[resourceapi.QualifiedName]resource.Quantity{
+						"encoders": resource.MustParse("1"),
+					}),
+				),
+			},
+		},
+		"consumable-capacity-first-fit": {
+			claimsToAllocate: objects(
+				claimWithRequests(claim0, nil, requestWithCapacity(req0, classA, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("2Gi"),
+				})),
+			),
+			allocatedClaims: partiallyConsumedDevices,
+			classes:         objects(class(classA, driverA)),
+			slices:          objects(capacitySlice),
+			node:            node(node1, region1),
+			features:        Features{ConsumableCapacity: true},
+			selectionPolicy: FirstFit,
+
+			expectResults: []any{
+				allocationResult(
+					localNodeSelector(node1),
+					sharedDeviceAllocationResult(req0, driverA, pool1, device1, map[resourceapi.QualifiedName]resource.Quantity{
+						"memory": resource.MustParse("2Gi"),
+					}),
+				),
+			},
+		},
+		"consumable-capacity-best-fit": {
+			// device-2 has less memory left, so device-1 remains
+			// available for larger requests.
+			claimsToAllocate: objects(
+				claimWithRequests(claim0, nil, requestWithCapacity(req0, classA, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("2Gi"),
+				})),
+			),
+			allocatedClaims: partiallyConsumedDevices,
+			classes:         objects(class(classA, driverA)),
+			slices:          objects(capacitySlice),
+			node:            node(node1, region1),
+			features:        Features{ConsumableCapacity: true},
+			selectionPolicy: BestFit,
+
+			expectResults: []any{
+				allocationResult(
+					localNodeSelector(node1),
+					sharedDeviceAllocationResult(req0, driverA, pool1, device2, map[resourceapi.QualifiedName]resource.Quantity{
+						"memory": resource.MustParse("2Gi"),
+					}),
+				),
+			},
+		},
+		"consumable-capacity-best-fit-too-small": {
+			// device-2 fits more tightly, but has not enough memory left.
+			claimsToAllocate: objects(
+				claimWithRequests(claim0, nil, requestWithCapacity(req0, classA, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("4Gi"),
+				})),
+			),
+			allocatedClaims: partiallyConsumedDevices,
+			classes:         objects(class(classA, driverA)),
+			slices:          objects(capacitySlice),
+			node:            node(node1, region1),
+			features:        Features{ConsumableCapacity: true},
+			selectionPolicy: BestFit,
+
+			expectResults: []any{
+				allocationResult(
+					localNodeSelector(node1),
+					sharedDeviceAllocationResult(req0, driverA, pool1, device1, map[resourceapi.QualifiedName]resource.Quantity{
+						"memory": resource.MustParse("4Gi"),
+					}),
+				),
+			},
+		},
+		"small-and-large": {
+			claimsToAllocate: objects(claimWithRequests(
+				claim0,
+				nil,
+				request(req0, classA, 1, resourceapi.DeviceSelector{
+					CEL: &resourceapi.CELDeviceSelector{
+						Expression: fmt.Sprintf(`device.capacity["%s"].memory.compareTo(quantity("1Gi")) >= 0`, driverA),
+					}}),
+				request(req1, classA, 1, resourceapi.DeviceSelector{
+					CEL: &resourceapi.CELDeviceSelector{
+						Expression: fmt.Sprintf(`device.capacity["%s"].memory.compareTo(quantity("2Gi")) >= 0`, driverA),
+					}}),
+			)),
+			classes: objects(class(classA, driverA)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("1Gi"),
+				}, nil),
+				device(device2, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("2Gi"),
+				}, nil),
+			)),
+			node: node(node1, region1),
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device1),
+				deviceAllocationResult(req1, driverA, pool1, device2),
+			)},
+		},
+		"small-and-large-backtrack-requests": {
+			claimsToAllocate: objects(claimWithRequests(
+				claim0,
+				nil,
+				request(req0, classA, 1, resourceapi.DeviceSelector{
+					CEL: &resourceapi.CELDeviceSelector{
+						Expression: fmt.Sprintf(`device.capacity["%s"].memory.compareTo(quantity("1Gi")) >= 0`, driverA),
+					}}),
+				request(req1, classA, 1, resourceapi.DeviceSelector{
+					CEL: &resourceapi.CELDeviceSelector{
+						Expression: fmt.Sprintf(`device.capacity["%s"].memory.compareTo(quantity("2Gi")) >= 0`, driverA),
+					}}),
+			)),
+			classes: objects(class(classA, driverA)),
+			// Reversing the order in which the devices are listed causes the "large" device to
+			// be allocated for the "small" request, leaving the "large" request unsatisfied.
+			// The initial decision needs to be undone before a solution is found.
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device2, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("2Gi"),
+				}, nil),
+				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("1Gi"),
+				}, nil),
+			)),
+			node: node(node1, region1),
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device1),
+				deviceAllocationResult(req1, driverA, pool1, device2),
+			)},
+		},
+		"small-and-large-backtrack-claims": {
+			claimsToAllocate: objects(
+				claimWithRequests(
+					claim0,
+					nil,
+					request(req0, classA, 1, resourceapi.DeviceSelector{
+						CEL: &resourceapi.CELDeviceSelector{
+							Expression: fmt.Sprintf(`device.capacity["%s"].memory.compareTo(quantity("1Gi")) >= 0`, driverA),
+						}})),
+				claimWithRequests(
+					claim1,
+					nil,
+					request(req1, classA, 1, resourceapi.DeviceSelector{
+						CEL: &resourceapi.CELDeviceSelector{
+							Expression: fmt.Sprintf(`device.capacity["%s"].memory.compareTo(quantity("2Gi")) >= 0`, driverA),
+						}}),
+				)),
+			classes: objects(class(classA, driverA)),
+			// Reversing the order in which the devices are listed causes the "large" device to
+			// be allocated for the "small" request, leaving the "large" request unsatisfied.
+			// The initial decision needs to be undone before a solution is found.
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device2, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("2Gi"),
+				}, nil),
+				device(device1, map[resourceapi.QualifiedName]resource.Quantity{
+					"memory": resource.MustParse("1Gi"),
+				}, nil),
+			)),
+			node: node(node1, region1),
+
+			expectResults: []any{
+				allocationResult(localNodeSelector(node1), deviceAllocationResult(req0, driverA, pool1, device1)),
+				allocationResult(localNodeSelector(node1), deviceAllocationResult(req1, driverA, pool1, device2)),
+			},
+		},
+		"devices-split-across-different-slices": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil, resourceapi.DeviceRequest{
+				Name:            req0,
+				Count:           2,
+				AllocationMode:  resourceapi.DeviceAllocationModeExactCount,
+				DeviceClassName: classA,
+			})),
+			classes: objects(class(classA, driverA)),
+			slices: objects(
+				sliceWithOneDevice(slice1, node1, pool1, driverA),
+				sliceWithOneDevice(slice2, node1, pool2, driverA),
+			),
+			node: node(node1, region1),
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device1),
+				deviceAllocationResult(req0, driverA, pool2, device1),
+			)},
+		},
+		"obsolete-slice": {
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			classes:          objects(class(classA, driverA)),
+			slices: objects(
+				sliceWithOneDevice("slice-1-obsolete", node1, pool1, driverA),
+				func() *resourceapi.ResourceSlice {
+					slice := sliceWithOneDevice(slice1, node1, pool1, driverA)
+					// This makes the other slice obsolete.
+					slice.Spec.Pool.Generation++
+					return slice
+				}(),
+			),
+			node: node(node1, region1),
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device1),
+			)},
+		},
+		"no-slices": {
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			classes:          objects(class(classA, driverA)),
+			slices:           nil,
+			node:             node(node1, region1),
+
+			expectResults: nil,
+		},
+		"not-enough-suitable-devices": {
+			claimsToAllocate: objects(claim(claim0, req0, classA), claim(claim0, req1, classA)),
+			classes:          objects(class(classA, driverA)),
+			slices:           objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+
+			node: node(node1, region1),
+
+			expectResults: nil,
+		},
+		"no-classes": {
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			classes:          nil,
+			slices:           objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			node:             node(node1, region1),
+
+			expectResults: nil,
+			expectError:   gomega.MatchError(gomega.ContainSubstring("could not retrieve device class class-a")),
+		},
+		"unknown-class": {
+			claimsToAllocate: objects(claim(claim0, req0, "unknown-class")),
+			classes:          objects(class(classA, driverA)),
+			slices:           objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			node:             node(node1, region1),
+
+			expectResults: nil,
+			expectError:   gomega.MatchError(gomega.ContainSubstring("could not retrieve device class unknown-class")),
+		},
+		"empty-class": {
+			claimsToAllocate: objects(claim(claim0, req0, "")),
+			classes:          objects(class(classA, driverA)),
+			slices:           objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			node:             node(node1, region1),
+
+			expectResults: nil,
+			expectError:   gomega.MatchError(gomega.ContainSubstring("claim claim-0, request req-0: missing device class name (unsupported request type?)")),
+		},
+		"no-claims-to-allocate": {
+			claimsToAllocate: nil,
+			classes:          objects(class(classA, driverA)),
+			slices:           objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			node:             node(node1, region1),
+
+			expectResults: nil,
+		},
+		"all-devices": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil, resourceapi.DeviceRequest{
+				Name:            req0,
+				AllocationMode:  resourceapi.DeviceAllocationModeAll,
+				Count:           1,
+				DeviceClassName: classA,
+			})),
+			classes: objects(class(classA, driverA)),
+			slices:  objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			node:    node(node1, region1),
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device1),
+			)},
+		},
+		"all-devices-of-the-incomplete-pool": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil, resourceapi.DeviceRequest{
+				Name:            req0,
+				AllocationMode:  resourceapi.DeviceAllocationModeAll,
+				Count:           1,
+				DeviceClassName: classA,
+			})),
 			classes: objects(class(classA, driverA)),
 			slices: objects(
 				func() *resourceapi.ResourceSlice {
@@ -639,6 +1239,43 @@ func TestAllocator(t *testing.T) {
 
 			expectResults: nil,
 		},
+		"node-local-only-network-attached-device": {
+			features:         Features{NodeLocalOnly: true},
+			claimsToAllocate: objects(claimWithRequests(claim0, nil, nodeLocalOnlyRequest(req0, classA))),
+			classes:          objects(class(classA, driverA)),
+			// The pool is available on the node, but shared with other nodes.
+			slices: objects(sliceWithOneDevice(slice1, nodeLabelSelector(regionKey, region1), pool1, driverA)),
+			node:   node(node1, region1),
+
+			expectResults: nil,
+		},
+		"node-local-only-disabled": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil, nodeLocalOnlyRequest(req0, classA))),
+			classes:          objects(class(classA, driverA)),
+			// Without the feature, the request accepts shared pools.
+			slices: objects(sliceWithOneDevice(slice1, nodeLabelSelector(regionKey, region1), pool1, driverA)),
+			node:   node(node1, region1),
+
+			expectResults: []any{allocationResult(
+				nodeLabelSelector(regionKey, region1),
+				deviceAllocationResult(req0, driverA, pool1, device1),
+			)},
+		},
+		"node-local-only": {
+			features:         Features{NodeLocalOnly: true},
+			claimsToAllocate: objects(claimWithRequests(claim0, nil, nodeLocalOnlyRequest(req0, classA))),
+			classes:          objects(class(classA, driverA)),
+			slices: objects(
+				sliceWithOneDevice(slice1, nodeLabelSelector(regionKey, region1), pool1, driverA),
+				sliceWithOneDevice(slice2, node1, pool2, driverA),
+			),
+			node: node(node1, region1),
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool2, device1),
+			)},
+		},
 		"many-network-attached-devices": {
 			claimsToAllocate: objects(claimWithRequests(claim0, nil, request(req0, classA, 4))),
 			classes:          objects(class(classA, driverA)),
@@ -781,62 +1418,318 @@ func TestAllocator(t *testing.T) {
 
 			expectResults: nil,
 		},
-		"with-constraint-not-matching-version-attribute": {
-			claimsToAllocate: objects(claimWithRequests(
-				claim0,
-				[]resourceapi.DeviceConstraint{{MatchAttribute: &versionAttribute}},
-				request(req0, classA, 3)),
+		"with-constraint-not-matching-version-attribute": {
+			claimsToAllocate: objects(claimWithRequests(
+				claim0,
+				[]resourceapi.DeviceConstraint{{MatchAttribute: &versionAttribute}},
+				request(req0, classA, 3)),
+			),
+			classes: objects(class(classA, driverA), class(classB, driverB)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+					"driverVersion": {VersionValue: ptr.To("1.0.0")},
+				}),
+				device(device2, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+					"driverVersion": {VersionValue: ptr.To("2.0.0")},
+				}),
+			)),
+			node: node(node1, region1),
+
+			expectResults: nil,
+		},
+		"with-constraint-not-matching-string-attribute": {
+			claimsToAllocate: objects(claimWithRequests(
+				claim0,
+				[]resourceapi.DeviceConstraint{{MatchAttribute: &stringAttribute}},
+				request(req0, classA, 3)),
+			),
+			classes: objects(class(classA, driverA), class(classB, driverB)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+					"stringAttribute": {StringValue: ptr.To("stringAttributeValue")},
+				}),
+				device(device2, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+					"stringAttribute": {StringValue: ptr.To("stringAttributeValue2")},
+				}),
+			)),
+			node: node(node1, region1),
+
+			expectResults: nil,
+		},
+		"with-constraint-not-matching-bool-attribute": {
+			claimsToAllocate: objects(claimWithRequests(
+				claim0,
+				[]resourceapi.DeviceConstraint{{MatchAttribute: &boolAttribute}},
+				request(req0, classA, 3)),
+			),
+			classes: objects(class(classA, driverA), class(classB, driverB)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+					"boolAttribute": {BoolValue: ptr.To(true)},
+				}),
+				device(device2, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+					"boolAttribute": {BoolValue: ptr.To(false)},
+				}),
+			)),
+			node: node(node1, region1),
+
+			expectResults: nil,
+		},
+		"with-constraint-string-list-attribute": {
+			claimsToAllocate: objects(claimWithRequests(
+				claim0,
+				[]resourceapi.DeviceConstraint{{MatchAttribute: &stringListAttribute}},
+				request(req0, classA, 2)),
+			),
+			classes: objects(class(classA, driverA)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+					"precisions": {StringListValue: []string{"fp16", "fp32"}},
+				}),
+				device(device2, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+					"precisions": {StringListValue: []string{"fp16"}},
+				}),
+				device(device3, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+					"precisions": {StringListValue: []string{"fp16", "fp32"}},
+				}),
+			)),
+			node: node(node1, region1),
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device1),
+				deviceAllocationResult(req0, driverA, pool1, device3),
+			)},
+		},
+		"attribute-selector-not-equals": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil,
+				request(req0, classA, 1, attributeSelector(modelAttribute, resourceapi.AttributeSelectorOpNotEquals, "A100")),
+			)),
+			classes:  objects(class(classA, driverA)),
+			slices:   objects(modelSlice),
+			node:     node(node1, region1),
+			features: Features{AttributeSelectors: true},
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device2),
+			)},
+		},
+		"attribute-selector-in": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil,
+				request(req0, classA, 1, attributeSelector(modelAttribute, resourceapi.AttributeSelectorOpIn, "H100", "H200")),
+			)),
+			classes:  objects(class(classA, driverA)),
+			slices:   objects(modelSlice),
+			node:     node(node1, region1),
+			features: Features{AttributeSelectors: true},
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device2),
+			)},
+		},
+		"attribute-selector-in-no-match": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil,
+				request(req0, classA, 1, attributeSelector(modelAttribute, resourceapi.AttributeSelectorOpIn, "H200")),
+			)),
+			classes:  objects(class(classA, driverA)),
+			slices:   objects(modelSlice),
+			node:     node(node1, region1),
+			features: Features{AttributeSelectors: true},
+
+			expectResults: nil,
+		},
+		"attribute-selector-not-in": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil,
+				request(req0, classA, 1, attributeSelector(modelAttribute, resourceapi.AttributeSelectorOpNotIn, "H100", "H200")),
+			)),
+			classes:  objects(class(classA, driverA)),
+			slices:   objects(modelSlice),
+			node:     node(node1, region1),
+			features: Features{AttributeSelectors: true},
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device1),
+			)},
+		},
+		"attribute-selector-bool": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil,
+				request(req0, classA, 1, attributeSelector(driverA+"/shared", resourceapi.AttributeSelectorOpIn, "true")),
+			)),
+			classes:  objects(class(classA, driverA)),
+			slices:   objects(modelSlice),
+			node:     node(node1, region1),
+			features: Features{AttributeSelectors: true},
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device2),
+			)},
+		},
+		"attribute-selector-missing-attribute": {
+			// NotIn does not match devices without the attribute.
+			claimsToAllocate: objects(claimWithRequests(claim0, nil,
+				request(req0, classA, 1, attributeSelector(driverA+"/other", resourceapi.AttributeSelectorOpNotIn, "A100")),
+			)),
+			classes:  objects(class(classA, driverA)),
+			slices:   objects(modelSlice),
+			node:     node(node1, region1),
+			features: Features{AttributeSelectors: true},
+
+			expectResults: nil,
+		},
+		"attribute-selector-wrong-type": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil,
+				request(req0, classA, 1, attributeSelector(driverA+"/memory", resourceapi.AttributeSelectorOpIn, "1")),
+			)),
+			classes:  objects(class(classA, driverA)),
+			slices:   objects(modelSlice),
+			node:     node(node1, region1),
+			features: Features{AttributeSelectors: true},
+
+			expectError: gomega.MatchError(gomega.ContainSubstring("claim claim-0: selector #0: attribute driver-a/memory of device driver-a/pool-1/device-1 is neither a string nor a boolean")),
+		},
+		"attribute-selector-disabled": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil,
+				request(req0, classA, 1, attributeSelector(modelAttribute, resourceapi.AttributeSelectorOpIn, "A100")),
+			)),
+			classes: objects(class(classA, driverA)),
+			slices:  objects(modelSlice),
+			node:    node(node1, region1),
+
+			expectError: gomega.MatchError(gomega.ContainSubstring("claim claim-0, request req-0, selector #0: CEL expression empty (unsupported selector type?)")),
+		},
+		"device-taint": {
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			classes:          objects(class(classA, driverA)),
+			slices:           objects(slice(slice1, node1, pool1, driverA, taintedDevice(device1, taint))),
+			node:             node(node1, region1),
+			features:         Features{DeviceTaints: true},
+
+			expectResults: nil,
+		},
+		"device-taint-skipped": {
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			classes:          objects(class(classA, driverA)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				taintedDevice(device1, taint),
+				device(device2, nil, nil),
+			)),
+			node:     node(node1, region1),
+			features: Features{DeviceTaints: true},
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device2),
+			)},
+		},
+		"device-taint-tolerated": {
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			classes:          objects(class(classA, driverA)),
+			slices:           objects(slice(slice1, node1, pool1, driverA, taintedDevice(device1, taint))),
+			node:             node(node1, region1),
+			features:         Features{DeviceTaints: true},
+			tolerations:      []v1.Toleration{{Key: taint.Key, Operator: v1.TolerationOpEqual, Value: taint.Value, Effect: v1.TaintEffectNoSchedule}},
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device1),
+			)},
+		},
+		"device-taint-other-toleration": {
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			classes:          objects(class(classA, driverA)),
+			slices:           objects(slice(slice1, node1, pool1, driverA, taintedDevice(device1, taint))),
+			node:             node(node1, region1),
+			features:         Features{DeviceTaints: true},
+			tolerations:      []v1.Toleration{{Key: "other", Operator: v1.TolerationOpExists}},
+
+			expectResults: nil,
+		},
+		"device-taint-disabled": {
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			classes:          objects(class(classA, driverA)),
+			slices:           objects(slice(slice1, node1, pool1, driverA, taintedDevice(device1, taint))),
+			node:             node(node1, region1),
+
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device1),
+			)},
+		},
+		"anti-affinity": {
+			// device-1 and device-2 are on the same card,
+			// device-3 is on a different one.
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			allocatedClaims: objects(
+				allocatedClaim(claim1, req0, classA,
+					deviceAllocationResult(req0, driverA, pool1, device1),
+				),
 			),
-			classes: objects(class(classA, driverA), class(classB, driverB)),
+			classes: objects(class(classA, driverA)),
 			slices: objects(slice(slice1, node1, pool1, driverA,
-				device(device1, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
-					"driverVersion": {VersionValue: ptr.To("1.0.0")},
-				}),
-				device(device2, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
-					"driverVersion": {VersionValue: ptr.To("2.0.0")},
-				}),
+				device(device1, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"card": {IntValue: ptr.To(int64(0))}}),
+				device(device2, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"card": {IntValue: ptr.To(int64(0))}}),
+				device(device3, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"card": {IntValue: ptr.To(int64(1))}}),
 			)),
 			node: node(node1, region1),
+			antiAffinity: &AntiAffinity{
+				Attribute: driverA + "/card",
+				Devices:   []DeviceID{{Driver: driverA, Pool: pool1, Device: device1}},
+			},
 
-			expectResults: nil,
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device3),
+			)},
 		},
-		"with-constraint-not-matching-string-attribute": {
-			claimsToAllocate: objects(claimWithRequests(
-				claim0,
-				[]resourceapi.DeviceConstraint{{MatchAttribute: &stringAttribute}},
-				request(req0, classA, 3)),
+		"anti-affinity-unsatisfiable": {
+			// The only free device is on the same card
+			// as the device that must be avoided.
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			allocatedClaims: objects(
+				allocatedClaim(claim1, req0, classA,
+					deviceAllocationResult(req0, driverA, pool1, device1),
+				),
 			),
-			classes: objects(class(classA, driverA), class(classB, driverB)),
+			classes: objects(class(classA, driverA)),
 			slices: objects(slice(slice1, node1, pool1, driverA,
-				device(device1, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
-					"stringAttribute": {StringValue: ptr.To("stringAttributeValue")},
-				}),
-				device(device2, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
-					"stringAttribute": {StringValue: ptr.To("stringAttributeValue2")},
-				}),
+				device(device1, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"card": {IntValue: ptr.To(int64(0))}}),
+				device(device2, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{"card": {IntValue: ptr.To(int64(0))}}),
 			)),
 			node: node(node1, region1),
+			antiAffinity: &AntiAffinity{
+				Attribute: driverA + "/card",
+				Devices:   []DeviceID{{Driver: driverA, Pool: pool1, Device: device1}},
+			},
 
 			expectResults: nil,
 		},
-		"with-constraint-not-matching-bool-attribute": {
-			claimsToAllocate: objects(claimWithRequests(
-				claim0,
-				[]resourceapi.DeviceConstraint{{MatchAttribute: &boolAttribute}},
-				request(req0, classA, 3)),
+		"anti-affinity-without-attribute": {
+			// Devices without the attribute are not affected.
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			allocatedClaims: objects(
+				allocatedClaim(claim1, req0, classA,
+					deviceAllocationResult(req0, driverA, pool1, device1),
+				),
 			),
-			classes: objects(class(classA, driverA), class(classB, driverB)),
+			classes: objects(class(classA, driverA)),
 			slices: objects(slice(slice1, node1, pool1, driverA,
-				device(device1, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
-					"boolAttribute": {BoolValue: ptr.To(true)},
-				}),
-				device(device2, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
-					"boolAttribute": {BoolValue: ptr.To(false)},
-				}),
+				device(device1, nil, nil),
+				device(device2, nil, nil),
 			)),
 			node: node(node1, region1),
+			antiAffinity: &AntiAffinity{
+				Attribute: driverA + "/card",
+				Devices:   []DeviceID{{Driver: driverA, Pool: pool1, Device: device1}},
+			},
 
-			expectResults: nil,
+			expectResults: []any{allocationResult(
+				localNodeSelector(node1),
+				deviceAllocationResult(req0, driverA, pool1, device2),
+			)},
 		},
 		"with-class-device-config": {
 			claimsToAllocate: objects(claim(claim0, req0, classA)),
@@ -849,11 +1742,45 @@ func TestAllocator(t *testing.T) {
 					localNodeSelector(node1),
 					driverA,
 					resourceapi.AllocationConfigSourceClass,
+					[]string{req0},
 					"classAttribute",
 					deviceAllocationResult(req0, driverA, pool1, device1),
 				),
 			},
 		},
+		"with-class-and-claim-device-config": {
+			// Each class config is limited to the request which uses
+			// the class. Class configs come before claim configs.
+			claimsToAllocate: func() []*resourceapi.ResourceClaim {
+				claim := claimWithRequests(claim0, nil, request(req0, classA, 1), request(req1, classB, 1))
+				claim.Spec.Devices.Config = []resourceapi.DeviceClaimConfiguration{
+					{
+						Requests:            []string{req1},
+						DeviceConfiguration: deviceConfiguration(driverB, "deviceAttribute"),
+					},
+				}
+				return objects(claim)
+			}(),
+			classes: objects(classWithConfig(classA, driverA, "classAttributeA"), classWithConfig(classB, driverB, "classAttributeB")),
+			slices: objects(
+				sliceWithOneDevice(slice1, node1, pool1, driverA),
+				sliceWithOneDevice(slice2, node1, pool2, driverB),
+			),
+			node: node(node1, region1),
+
+			expectResults: []any{
+				allocationResultWithConfigs(
+					localNodeSelector(node1),
+					[]resourceapi.DeviceRequestAllocationResult{
+						deviceAllocationResult(req0, driverA, pool1, device1),
+						deviceAllocationResult(req1, driverB, pool2, device1),
+					},
+					allocationConfig(driverA, resourceapi.AllocationConfigSourceClass, []string{req0}, "classAttributeA"),
+					allocationConfig(driverB, resourceapi.AllocationConfigSourceClass, []string{req1}, "classAttributeB"),
+					allocationConfig(driverB, resourceapi.AllocationConfigSourceClaim, []string{req1}, "deviceAttribute"),
+				),
+			},
+		},
 		"claim-with-device-config": {
 			claimsToAllocate: objects(claimWithDeviceConfig(claim0, req0, classA, driverA, "deviceAttribute")),
 			classes:          objects(class(classA, driverA)),
@@ -865,6 +1792,7 @@ func TestAllocator(t *testing.T) {
 					localNodeSelector(node1),
 					driverA,
 					resourceapi.AllocationConfigSourceClaim,
+					nil,
 					"deviceAttribute",
 					deviceAllocationResult(req0, driverA, pool1, device1),
 				),
@@ -872,48 +1800,424 @@ func TestAllocator(t *testing.T) {
 		},
 	}
 
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Run("list", func(t *testing.T) {
+				testAllocator(t, tc, false)
+			})
+			t.Run("snapshot", func(t *testing.T) {
+				testAllocator(t, tc, true)
+			})
+		})
+	}
+}
+
+func testAllocator(t *testing.T, tc allocatorTestCase, useSnapshot bool) {
+	_, ctx := ktesting.NewTestContext(t)
+	g := gomega.NewWithT(t)
+
+	// Listing objects is deterministic and returns them in the same
+	// order as in the test case. That makes the allocation result
+	// also deterministic.
+	var allocated, toAllocate claimLister
+	var classLister informerLister[resourceapi.DeviceClass]
+	var sliceLister informerLister[resourceapi.ResourceSlice]
+	for _, claim := range tc.claimsToAllocate {
+		toAllocate.claims = append(toAllocate.claims, claim.DeepCopy())
+	}
+	for _, claim := range tc.allocatedClaims {
+		allocated.claims = append(allocated.claims, claim.DeepCopy())
+	}
+	for _, slice := range tc.slices {
+		sliceLister.objs = append(sliceLister.objs, slice.DeepCopy())
+	}
+	for _, class := range tc.classes {
+		classLister.objs = append(classLister.objs, class.DeepCopy())
+	}
+
+	var allocatedLister ClaimLister = allocated
+	if useSnapshot {
+		allocatedLister = newSnapshotLister(allocated.claims)
+	}
+	allocator, err := NewAllocator(ctx, tc.features, toAllocate.claims, allocatedLister, classLister, sliceLister)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	if tc.antiAffinity != nil {
+		allocator = allocator.WithAntiAffinity(tc.antiAffinity)
+	}
+	if tc.tolerations != nil {
+		allocator = allocator.WithTolerations(tc.tolerations)
+	}
+	if tc.selectionPolicy != "" {
+		allocator = allocator.WithSelectionPolicy(tc.selectionPolicy)
+	}
+	if tc.missingAttributeBehavior != "" {
+		allocator = allocator.WithMissingAttributeBehavior(tc.missingAttributeBehavior)
+	}
+	if tc.hints != nil {
+		allocator = allocator.WithHints(tc.hints)
+	}
+	if tc.scorers != nil {
+		allocator = allocator.WithDeviceScorers(tc.scorers)
+	}
+
+	results, err := allocator.Allocate(ctx, tc.node)
+	matchError := tc.expectError
+	if matchError == nil {
+		matchError = gomega.Not(gomega.HaveOccurred())
+	}
+	g.Expect(err).To(matchError)
+	g.Expect(results).To(gomega.ConsistOf(tc.expectResults...))
+
+	// Objects that the allocator had access to should not have been modified.
+	g.Expect(toAllocate.claims).To(gomega.HaveExactElements(tc.claimsToAllocate))
+	g.Expect(allocated.claims).To(gomega.HaveExactElements(tc.allocatedClaims))
+	g.Expect(sliceLister.objs).To(gomega.ConsistOf(tc.slices))
+	g.Expect(classLister.objs).To(gomega.ConsistOf(tc.classes))
+}
+
+func TestUnsatisfiableRequest(t *testing.T) {
+	impossibleSelector := resourceapi.DeviceSelector{
+		CEL: &resourceapi.CELDeviceSelector{
+			Expression: `device.driver == "no-such-driver"`,
+		},
+	}
+
+	testcases := map[string]struct {
+		claimsToAllocate []*resourceapi.ResourceClaim
+		allocatedClaims  []*resourceapi.ResourceClaim
+		slices           []*resourceapi.ResourceSlice
+		expectMessage    string
+	}{
+		"success": {
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			slices:           objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+		},
+		"no-matching-device": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil,
+				request(req0, classA, 1),
+				request(req1, classA, 1, impossibleSelector),
+			)),
+			slices: objects(slice(slice1, node1, pool1, driverA,
+				device(device1, nil, nil),
+				device(device2, nil, nil),
+			)),
+			expectMessage: "claim claim-0: request req-1 unsatisfiable (no matching device)",
+		},
+		"not-enough-free-devices": {
+			claimsToAllocate: objects(claim(claim0, req0, classA)),
+			allocatedClaims: objects(
+				allocatedClaim(claim1, req0, classA,
+					deviceAllocationResult(req0, driverA, pool1, device1)),
+			),
+			slices:        objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			expectMessage: "claim claim-0: request req-0 unsatisfiable (not enough free devices)",
+		},
+		"competing-requests": {
+			claimsToAllocate: objects(claimWithRequests(claim0, nil,
+				request(req0, classA, 1),
+				request(req1, classA, 1),
+			)),
+			slices:        objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			expectMessage: "claim claim-0: requests cannot be satisfied together",
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			_, ctx := ktesting.NewTestContext(t)
+			g := gomega.NewWithT(t)
+
+			classLister := informerLister[resourceapi.DeviceClass]{objs: objects(class(classA, driverA))}
+			sliceLister := informerLister[resourceapi.ResourceSlice]{objs: tc.slices}
+			allocator, err := NewAllocator(ctx, Features{}, tc.claimsToAllocate, claimLister{claims: tc.allocatedClaims}, classLister, sliceLister)
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+
+			results, unsatisfiable, err := allocator.AllocateWithReason(ctx, node(node1, region1))
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			if tc.expectMessage == "" {
+				g.Expect(unsatisfiable).To(gomega.BeNil())
+				g.Expect(results).To(gomega.HaveLen(len(tc.claimsToAllocate)))
+				return
+			}
+			g.Expect(results).To(gomega.BeEmpty())
+			g.Expect(unsatisfiable).ToNot(gomega.BeNil())
+			g.Expect(unsatisfiable.String()).To(gomega.Equal(tc.expectMessage))
+		})
+	}
+}
+
+func TestClassDevices(t *testing.T) {
+	testcases := map[string]struct {
+		class         *resourceapi.DeviceClass
+		podLabels     map[string]string
+		slices        []*resourceapi.ResourceSlice
+		expectDevices int
+	}{
+		"empty": {
+			class: class(classA, driverA),
+		},
+		"several-nodes": {
+			class: class(classA, driverA),
+			slices: objects(
+				slice(slice1, node1, pool1, driverA,
+					device(device1, nil, nil),
+					device(device2, nil, nil),
+				),
+				slice(slice2, node2, pool2, driverA,
+					device(device1, nil, nil),
+				),
+			),
+			expectDevices: 3,
+		},
+		"other-driver": {
+			class: class(classA, driverA),
+			slices: objects(
+				slice(slice1, node1, pool1, driverA, device(device1, nil, nil)),
+				slice(slice2, node1, pool2, driverB, device(device1, nil, nil)),
+			),
+			expectDevices: 1,
+		},
+		"invalid-selector": {
+			class: &resourceapi.DeviceClass{
+				ObjectMeta: metav1.ObjectMeta{Name: classA},
+				Spec: resourceapi.DeviceClassSpec{
+					Selectors: []resourceapi.DeviceSelector{{CEL: &resourceapi.CELDeviceSelector{Expression: "noSuchVar"}}},
+				},
+			},
+			slices:        objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			expectDevices: 1,
+		},
+		"pod-label": {
+			class: &resourceapi.DeviceClass{
+				ObjectMeta: metav1.ObjectMeta{Name: classA},
+				Spec: resourceapi.DeviceClassSpec{
+					Selectors: []resourceapi.DeviceSelector{{CEL: &resourceapi.CELDeviceSelector{Expression: `"team" in pod.labels`}}},
+				},
+			},
+			podLabels:     map[string]string{"team": "a"},
+			slices:        objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			expectDevices: 1,
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			_, ctx := ktesting.NewTestContext(t)
+			g := gomega.NewWithT(t)
+
+			numDevices := ClassDevices(ctx, tc.class, tc.podLabels, tc.slices)
+			g.Expect(numDevices).To(gomega.Equal(tc.expectDevices))
+		})
+	}
+}
+
+func TestRequestDevicesExist(t *testing.T) {
+	withSelector := func(expression string) *resourceapi.ResourceClaim {
+		claim := claim(claim0, req0, classA)
+		claim.Spec.Devices.Requests[0].Selectors = []resourceapi.DeviceSelector{{CEL: &resourceapi.CELDeviceSelector{Expression: expression}}}
+		return claim
+	}
+
+	testcases := map[string]struct {
+		class        *resourceapi.DeviceClass
+		claim        *resourceapi.ResourceClaim
+		podLabels    map[string]string
+		slices       []*resourceapi.ResourceSlice
+		expectExists bool
+	}{
+		"empty": {
+			class: class(classA, driverA),
+			claim: claim(claim0, req0, classA),
+		},
+		"match": {
+			class:        class(classA, driverA),
+			claim:        claim(claim0, req0, classA),
+			slices:       objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			expectExists: true,
+		},
+		"other-driver": {
+			class:  class(classA, driverA),
+			claim:  claim(claim0, req0, classA),
+			slices: objects(sliceWithOneDevice(slice1, node1, pool1, driverB)),
+		},
+		"missing-attribute": {
+			class:  class(classA, driverA),
+			claim:  withSelector(`"noSuchAttribute" in device.attributes["` + driverA + `"]`),
+			slices: objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+		},
+		"invalid-selector": {
+			class:        class(classA, driverA),
+			claim:        withSelector("noSuchVar"),
+			slices:       objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			expectExists: true,
+		},
+		"pod-label": {
+			class:        class(classA, driverA),
+			claim:        withSelector(`"team" in pod.labels && pod.labels["team"] == "a"`),
+			podLabels:    map[string]string{"team": "a"},
+			slices:       objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+			expectExists: true,
+		},
+		"pod-label-other-value": {
+			class:     class(classA, driverA),
+			claim:     withSelector(`"team" in pod.labels && pod.labels["team"] == "a"`),
+			podLabels: map[string]string{"team": "b"},
+			slices:    objects(sliceWithOneDevice(slice1, node1, pool1, driverA)),
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			_, ctx := ktesting.NewTestContext(t)
+			g := gomega.NewWithT(t)
+
+			exists := RequestDevicesExist(ctx, tc.class, tc.claim, 0, tc.podLabels, tc.slices)
+			g.Expect(exists).To(gomega.Equal(tc.expectExists))
+		})
+	}
+}
+
+func TestDeviceMatchesRequest(t *testing.T) {
+	withSelector := func(expression string) *resourceapi.ResourceClaim {
+		claim := claim(claim0, req0, classA)
+		claim.Spec.Devices.Requests[0].Selectors = []resourceapi.DeviceSelector{{CEL: &resourceapi.CELDeviceSelector{Expression: expression}}}
+		return claim
+	}
+	healthy := func(value bool) *resourceapi.BasicDevice {
+		return device(device1, nil, map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+			"healthy": {BoolValue: ptr.To(value)},
+		}).Basic
+	}
+	deviceID := DeviceID{Driver: driverA, Pool: pool1, Device: device1}
+
+	testcases := map[string]struct {
+		class         *resourceapi.DeviceClass
+		claim         *resourceapi.ResourceClaim
+		device        *resourceapi.BasicDevice
+		expectMatches bool
+		expectError   bool
+	}{
+		"match": {
+			class:         class(classA, driverA),
+			claim:         withSelector(`device.attributes["` + driverA + `"].healthy`),
+			device:        healthy(true),
+			expectMatches: true,
+		},
+		"no-match": {
+			class:  class(classA, driverA),
+			claim:  withSelector(`device.attributes["` + driverA + `"].healthy`),
+			device: healthy(false),
+		},
+		"no-class": {
+			claim:         withSelector(`device.attributes["` + driverA + `"].healthy`),
+			device:        healthy(true),
+			expectMatches: true,
+		},
+		"class-mismatch": {
+			class:  class(classA, driverB),
+			claim:  claim(claim0, req0, classA),
+			device: healthy(true),
+		},
+		"invalid-selector": {
+			class:       class(classA, driverA),
+			claim:       withSelector("noSuchVar"),
+			device:      healthy(true),
+			expectError: true,
+		},
+	}
+
 	for name, tc := range testcases {
 		t.Run(name, func(t *testing.T) {
 			_, ctx := ktesting.NewTestContext(t)
 			g := gomega.NewWithT(t)
 
-			// Listing objects is deterministic and returns them in the same
-			// order as in the test case. That makes the allocation result
-			// also deterministic.
-			var allocated, toAllocate claimLister
-			var classLister informerLister[resourceapi.DeviceClass]
-			var sliceLister informerLister[resourceapi.ResourceSlice]
-			for _, claim := range tc.claimsToAllocate {
-				toAllocate.claims = append(toAllocate.claims, claim.DeepCopy())
+			matches, err := DeviceMatchesRequest(ctx, tc.class, tc.claim, 0, deviceID, tc.device)
+			if tc.expectError {
+				g.Expect(err).To(gomega.HaveOccurred())
+				return
 			}
-			for _, claim := range tc.allocatedClaims {
-				allocated.claims = append(allocated.claims, claim.DeepCopy())
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			g.Expect(matches).To(gomega.Equal(tc.expectMatches))
+		})
+	}
+}
+
+func TestNodeSelectorForSlice(t *testing.T) {
+	multipleTerms := nodeLabelSelector(regionKey, region1)
+	multipleTerms.NodeSelectorTerms = append(multipleTerms.NodeSelectorTerms, nodeLabelSelector(regionKey, region2).NodeSelectorTerms...)
+
+	testcases := map[string]struct {
+		slice          *resourceapi.ResourceSlice
+		expectSelector *v1.NodeSelector
+		expectError    types.GomegaMatcher
+		matchingNode   *v1.Node
+		otherNode      *v1.Node
+	}{
+		"node-local": {
+			slice:          sliceWithOneDevice(slice1, node1, pool1, driverA),
+			expectSelector: localNodeSelector(node1),
+			matchingNode:   node(node1, region1),
+			otherNode:      node(node2, region1),
+		},
+		"node-selector": {
+			slice:          sliceWithOneDevice(slice1, nodeLabelSelector(regionKey, region1), pool1, driverA),
+			expectSelector: nodeLabelSelector(regionKey, region1),
+			matchingNode:   node(node1, region1),
+			otherNode:      node(node2, region2),
+		},
+		"all-nodes": {
+			slice: sliceWithOneDevice(slice1, true, pool1, driverA),
+		},
+		"multiple-terms": {
+			slice:       sliceWithOneDevice(slice1, multipleTerms, pool1, driverA),
+			expectError: gomega.MatchError(gomega.ContainSubstring("unsupported ResourceSlice.NodeSelector with 2 terms")),
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+
+			selector, err := NodeSelectorForSlice(tc.slice)
+			if tc.expectError != nil {
+				g.Expect(err).To(tc.expectError)
+				return
 			}
-			for _, slice := range tc.slices {
-				sliceLister.objs = append(sliceLister.objs, slice.DeepCopy())
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			if tc.expectSelector == nil {
+				g.Expect(selector).To(gomega.BeNil())
+				return
 			}
-			for _, class := range tc.classes {
-				classLister.objs = append(classLister.objs, class.DeepCopy())
+			g.Expect(selector).To(matchNodeSelector(tc.expectSelector))
+			g.Expect(checkNodeSelector(selector, tc.matchingNode)).To(gomega.Succeed())
+			g.Expect(checkNodeSelector(selector, tc.otherNode)).To(gomega.MatchError(gomega.ContainSubstring("does not match node " + tc.otherNode.Name)))
+
+			// The result must be a copy.
+			if tc.slice.Spec.NodeSelector != nil {
+				selector.NodeSelectorTerms[0].MatchExpressions[0].Values[0] = "modified"
+				g.Expect(tc.slice.Spec.NodeSelector).To(matchNodeSelector(tc.expectSelector))
 			}
+		})
+	}
+}
 
-			allocator, err := NewAllocator(ctx, toAllocate.claims, allocated, classLister, sliceLister)
-			g.Expect(err).ToNot(gomega.HaveOccurred())
+// preferDeviceScorer is an example DeviceScorer. It prefers devices with
+// a certain name.
+type preferDeviceScorer struct {
+	device string
+}
 
-			results, err := allocator.Allocate(ctx, tc.node)
-			matchError := tc.expectError
-			if matchError == nil {
-				matchError = gomega.Not(gomega.HaveOccurred())
-			}
-			g.Expect(err).To(matchError)
-			g.Expect(results).To(gomega.ConsistOf(tc.expectResults...))
-
-			// Objects that the allocator had access to should not have been modified.
-			g.Expect(toAllocate.claims).To(gomega.HaveExactElements(tc.claimsToAllocate))
-			g.Expect(allocated.claims).To(gomega.HaveExactElements(tc.allocatedClaims))
-			g.Expect(sliceLister.objs).To(gomega.ConsistOf(tc.slices))
-			g.Expect(classLister.objs).To(gomega.ConsistOf(tc.classes))
-		})
+func (s preferDeviceScorer) ScoreDevice(ctx context.Context, claim *resourceapi.ResourceClaim, request *resourceapi.DeviceRequest, node *v1.Node, deviceID DeviceID, device *resourceapi.BasicDevice) int64 {
+	if deviceID.Device == s.device {
+		return 10
 	}
+	return 0
+}
+
+type panickingScorer struct{}
+
+func (panickingScorer) ScoreDevice(ctx context.Context, claim *resourceapi.ResourceClaim, request *resourceapi.DeviceRequest, node *v1.Node, deviceID DeviceID, device *resourceapi.BasicDevice) int64 {
+	panic("fake panic")
 }
 
 type claimLister struct {
@@ -925,6 +2229,27 @@ func (l claimLister) ListAllAllocated() ([]*resourceapi.ResourceClaim, error) {
 	return l.claims, l.err
 }
 
+// snapshotLister provides the allocated devices only as snapshot.
+type snapshotLister struct {
+	allocatedDevices *AllocatedDevices
+}
+
+func newSnapshotLister(claims []*resourceapi.ResourceClaim) snapshotLister {
+	tracker := NewAllocatedDevicesTracker()
+	for _, claim := range claims {
+		tracker.Update(claim.Namespace+"/"+claim.Name, claim)
+	}
+	return snapshotLister{allocatedDevices: tracker.Snapshot()}
+}
+
+func (l snapshotLister) ListAllAllocated() ([]*resourceapi.ResourceClaim, error) {
+	return nil, errors.New("unexpected call of ListAllAllocated")
+}
+
+func (l snapshotLister) ListAllocatedDevices() *AllocatedDevices {
+	return l.allocatedDevices
+}
+
 type informerLister[T any] struct {
 	objs []*T
 	err  error
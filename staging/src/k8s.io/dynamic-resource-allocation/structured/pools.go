@@ -19,6 +19,7 @@ package structured
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	v1 "k8s.io/api/core/v1"
 	resourceapi "k8s.io/api/resource/v1alpha3"
@@ -129,3 +130,82 @@ type DeviceID struct {
 func (d DeviceID) String() string {
 	return d.Driver + "/" + d.Pool + "/" + d.Device
 }
+
+// applyHints orders pools such that the hinted devices come first, followed
+// by the remaining devices of the hinted pools and then all other pools.
+// The pools and slices themselves are not modified. Where devices need to
+// be reordered, shallow copies of the slices get created.
+func applyHints(pools []*Pool, hints *Hints) []*Pool {
+	if hints == nil || len(hints.Devices) == 0 && len(hints.Pools) == 0 {
+		return pools
+	}
+
+	// Lower rank is better. Pools which aren't hinted are ranked last
+	// and keep their original order.
+	rank := func(pool *Pool) int {
+		for i, device := range hints.Devices {
+			if device.Driver == pool.Driver && device.Pool == pool.Pool {
+				return i
+			}
+		}
+		for i, id := range hints.Pools {
+			if id == pool.PoolID {
+				return len(hints.Devices) + i
+			}
+		}
+		return len(hints.Devices) + len(hints.Pools)
+	}
+	result := slices.Clone(pools)
+	slices.SortStableFunc(result, func(a, b *Pool) int {
+		return rank(a) - rank(b)
+	})
+
+	for i, pool := range result {
+		var devices []string
+		for _, device := range hints.Devices {
+			if device.Driver == pool.Driver && device.Pool == pool.Pool {
+				devices = append(devices, device.Device)
+			}
+		}
+		if len(devices) > 0 {
+			result[i] = preferDevices(pool, devices)
+		}
+	}
+	return result
+}
+
+// preferDevices returns a copy of the pool where the named devices are
+// listed first, in the given order.
+func preferDevices(pool *Pool, devices []string) *Pool {
+	rank := func(name string) int {
+		if index := slices.Index(devices, name); index >= 0 {
+			return index
+		}
+		return len(devices)
+	}
+	sliceRank := func(slice *resourceapi.ResourceSlice) int {
+		best := len(devices)
+		for _, device := range slice.Spec.Devices {
+			best = min(best, rank(device.Name))
+		}
+		return best
+	}
+
+	result := *pool
+	result.Slices = make([]*resourceapi.ResourceSlice, 0, len(pool.Slices))
+	for _, slice := range pool.Slices {
+		if sliceRank(slice) < len(devices) {
+			sliceCopy := *slice
+			sliceCopy.Spec.Devices = slices.Clone(slice.Spec.Devices)
+			slices.SortStableFunc(sliceCopy.Spec.Devices, func(a, b resourceapi.Device) int {
+				return rank(a.Name) - rank(b.Name)
+			})
+			slice = &sliceCopy
+		}
+		result.Slices = append(result.Slices, slice)
+	}
+	slices.SortStableFunc(result.Slices, func(a, b *resourceapi.ResourceSlice) int {
+		return sliceRank(a) - sliceRank(b)
+	})
+	return &result
+}
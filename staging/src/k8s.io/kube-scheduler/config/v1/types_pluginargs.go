@@ -227,3 +227,254 @@ type RequestedToCapacityRatioParam struct {
 	// +listType=atomic
 	Shape []UtilizationShapePoint `json:"shape,omitempty"`
 }
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DynamicResourcesArgs holds arguments used to configure the DynamicResources plugin.
+type DynamicResourcesArgs struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// WriteStrategy determines how the plugin writes the status of
+	// ResourceClaims. Valid values are "Update" and "Patch".
+	// Defaults to "Update".
+	// +optional
+	WriteStrategy WriteStrategyType `json:"writeStrategy,omitempty"`
+
+	// DeviceQuotas limit how many devices of a class the plugin may
+	// allocate for claims in a namespace. An entry without a namespace
+	// is a budget for the class in the entire cluster which applies in
+	// addition to the namespace quotas. Devices which were allocated
+	// by the plugin count against the quota, regardless of which pod
+	// they were allocated for. Without an entry for a namespace and
+	// class, the number of devices is not limited.
+	// +optional
+	// +listType=atomic
+	DeviceQuotas []DeviceQuota `json:"deviceQuotas,omitempty"`
+
+	// DeviceSelectionPolicy determines which device the plugin picks
+	// for a request which consumes some capacity of a device when
+	// several devices have enough capacity left. "FirstFit" picks the
+	// first one, "BestFit" the one which is left with the least unused
+	// capacity, which keeps devices with more capacity available for
+	// larger requests. Requests for entire devices are not affected.
+	// Defaults to "BestFit".
+	// +optional
+	DeviceSelectionPolicy DeviceSelectionPolicyType `json:"deviceSelectionPolicy,omitempty"`
+
+	// ResourceSliceMaxAgeSeconds is the maximum age in seconds of the
+	// heartbeat of a ResourceSlice. Drivers may record the heartbeat in
+	// the "resource.kubernetes.io/heartbeat" annotation of their slices.
+	// Devices in slices with an older heartbeat are not allocated.
+	// Slices without a heartbeat are always used. Zero disables the
+	// check, which is the default.
+	// +optional
+	ResourceSliceMaxAgeSeconds int64 `json:"resourceSliceMaxAgeSeconds,omitempty"`
+
+	// MissingAttributeBehavior determines how the plugin handles CEL
+	// selectors which look up an attribute that a device doesn't have.
+	// With "Error", the lookup is an error which keeps the pod pending
+	// until the selector or the device gets fixed. With "Exclude", the
+	// device is treated like a device which doesn't match the selector,
+	// which is useful when only some devices in a cluster provide the
+	// attribute. Other CEL runtime errors are always reported.
+	// Defaults to "Error".
+	// +optional
+	MissingAttributeBehavior MissingAttributeBehaviorType `json:"missingAttributeBehavior,omitempty"`
+
+	// ClaimEventCoalescingMilliseconds is the time window in milliseconds
+	// during which repeated ResourceClaim events for the same pod only
+	// requeue it once. This helps when a driver updates several claims
+	// of a pod in quick succession. The first event still requeues the
+	// pod immediately. Events after the next scheduling attempt of the
+	// pod are never suppressed. Zero disables coalescing, which is the
+	// default.
+	// +optional
+	ClaimEventCoalescingMilliseconds int64 `json:"claimEventCoalescingMilliseconds,omitempty"`
+
+	// FailedAttemptsBeforeEscalation is the number of failed scheduling
+	// attempts after which the plugin handles a pod more aggressively:
+	// PostFilter deallocates all claims which are allocated for nodes
+	// that the pod cannot run on instead of one claim per attempt and
+	// Filter no longer checks why devices could not be allocated on a
+	// node. Zero disables escalation. Defaults to 10.
+	// +optional
+	FailedAttemptsBeforeEscalation *int32 `json:"failedAttemptsBeforeEscalation,omitempty"`
+
+	// ValidateClaimTemplates enables checking that a ResourceClaim which
+	// was generated for a pod from a ResourceClaimTemplate still has the
+	// same spec as the template. A pod whose claim was edited after
+	// it was generated is then not scheduled. Claims whose template
+	// was deleted are not checked. Defaults to false.
+	// +optional
+	ValidateClaimTemplates bool `json:"validateClaimTemplates,omitempty"`
+
+	// EnablePostFilterDeallocation determines whether PostFilter may
+	// deallocate claims which are allocated for nodes that the pod cannot
+	// run on. When disabled, such pods remain unschedulable until the
+	// claims get deallocated by something else, for example because
+	// allocation is managed outside of the scheduler. Defaults to true.
+	// +optional
+	EnablePostFilterDeallocation *bool `json:"enablePostFilterDeallocation,omitempty"`
+
+	// AllowedNamespaces restricts dynamic resource allocation to pods in
+	// the listed namespaces. Pods in other namespaces which reference
+	// ResourceClaims are not scheduled. When combined with
+	// NamespaceSelector, a namespace must satisfy both. Empty allows all
+	// namespaces, which is the default.
+	// +optional
+	// +listType=set
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
+	// NamespaceSelector restricts dynamic resource allocation to pods in
+	// namespaces whose labels match the selector. Nil allows all
+	// namespaces, which is the default.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// RecordAllocatedDeviceClasses enables storing the generation,
+	// ResourceVersion and CEL selectors of the DeviceClasses that were
+	// used for allocating a ResourceClaim in the
+	// resource.kubernetes.io/allocated-device-classes annotation of the
+	// claim. Auditors and drivers can then check which selectors were
+	// in force at allocation time even if the classes were modified
+	// later. Defaults to false.
+	// +optional
+	RecordAllocatedDeviceClasses bool `json:"recordAllocatedDeviceClasses,omitempty"`
+
+	// PreferReservedClaims determines whether a claim which is allocated
+	// and already reserved for the pod, for example because the pod was
+	// scheduled before, is kept as it is. PostFilter then does not
+	// deallocate such a claim to make the pod fit some other node, which
+	// keeps the pod on the node of the existing allocation. Claims whose
+	// allocation is not usable on any existing node still get deallocated.
+	// Defaults to true.
+	// +optional
+	PreferReservedClaims *bool `json:"preferReservedClaims,omitempty"`
+
+	// SkipClassDriverCheck disables a check in PreFilter which rejects
+	// pods when a DeviceClass selects attributes of a driver for which
+	// no ResourceSlice exists in the cluster, which usually is caused by
+	// a misspelled driver name. The check is only a heuristic: a selector
+	// may deliberately reference attributes which are not published yet.
+	// Defaults to false.
+	// +optional
+	SkipClassDriverCheck bool `json:"skipClassDriverCheck,omitempty"`
+
+	// KeepAllocationOnTopologyMismatch determines the default for claims
+	// without the resource.kubernetes.io/keep-allocation annotation. When
+	// true, PostFilter does not deallocate an allocated claim which cannot
+	// be used on any of the candidate nodes. The pod then waits for a node
+	// which matches the existing allocation instead of getting its claim
+	// allocated anew elsewhere, which avoids repeating expensive device
+	// preparation. Defaults to false.
+	// +optional
+	KeepAllocationOnTopologyMismatch bool `json:"keepAllocationOnTopologyMismatch,omitempty"`
+
+	// DriverReadinessSeconds is the maximum time in seconds since the
+	// pool generation of a driver on a node was updated which makes the
+	// driver count as ready on that node. When set, the scheduler only
+	// allocates devices of a driver for a pod on a node if the scheduler
+	// observed a new generation of one of the pools that the driver
+	// publishes for that node recently. Otherwise the pod might get bound
+	// to a node where the kubelet plugin of the driver is not running
+	// and then cannot start. Drivers need to publish a new generation
+	// more often than that. All pools count as updated when the
+	// scheduler starts. Claims which are already allocated are not
+	// checked. Zero disables the check, which is the default.
+	// +optional
+	DriverReadinessSeconds int64 `json:"driverReadinessSeconds,omitempty"`
+
+	// AllowedCELFunctionGroups restricts which functions the CEL selectors
+	// of DeviceClasses and ResourceClaims may call. Operators, macros and
+	// the functions of the standard CEL library are always allowed. The
+	// optional functions are grouped: "regex", "strings", "lists", "sets",
+	// "quantity", "semver" and "network". Selectors which call a function
+	// of a group which is not listed cannot be used for allocation, which
+	// keeps pods that depend on them pending. This can be used to exclude
+	// functions which are expensive to evaluate, like regular expressions.
+	// Empty allows all functions, which is the default.
+	// +optional
+	// +listType=set
+	AllowedCELFunctionGroups []string `json:"allowedCELFunctionGroups,omitempty"`
+
+	// Enabled determines whether the plugin handles ResourceClaims in
+	// the scheduler profile. Disabling it is useful when only some
+	// profiles are meant for pods which need devices: a disabled
+	// plugin does not watch any DRA objects and rejects pods which
+	// reference ResourceClaims instead of scheduling them without their
+	// devices. Defaults to true.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// VerifyAllocatedSelectors enables checking whether devices which are
+	// allocated for a ResourceClaim still match the selectors of the
+	// claim and its DeviceClass after a driver changed their attributes.
+	// A warning event is emitted for a claim whose devices no longer
+	// match. The allocation itself is never changed. Defaults to false.
+	// +optional
+	VerifyAllocatedSelectors bool `json:"verifyAllocatedSelectors,omitempty"`
+
+	// AllowTerminatingDeviceClasses determines whether new claims may get
+	// allocated with a DeviceClass which is being deleted. By default,
+	// pods with such claims are unschedulable until the class is
+	// recreated because the allocated claims would be left with a class
+	// which no longer exists. Enabling this is useful when classes get
+	// deleted and recreated routinely. Defaults to false.
+	// +optional
+	AllowTerminatingDeviceClasses bool `json:"allowTerminatingDeviceClasses,omitempty"`
+}
+
+// DeviceQuota limits the number of devices of one class which may be
+// allocated for claims in one namespace or, without a namespace, for
+// all claims in the cluster.
+type DeviceQuota struct {
+	// Namespace of the claims. Empty for all namespaces.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// DeviceClassName is the name of the DeviceClass.
+	DeviceClassName string `json:"deviceClassName"`
+	// MaxDevices is the maximum number of allocated devices.
+	// Zero prevents allocating devices of the class in the
+	// namespace or the cluster.
+	MaxDevices int32 `json:"maxDevices"`
+}
+
+// WriteStrategyType defines how the DynamicResources plugin writes changes
+// of ResourceClaim status to the apiserver.
+type WriteStrategyType string
+
+const (
+	// UpdateWriteStrategy replaces the status with an update. Concurrent
+	// changes of the claim cause a conflict and the plugin retries with
+	// the latest claim.
+	UpdateWriteStrategy WriteStrategyType = "Update"
+	// PatchWriteStrategy only sends the changes with a patch. Adding
+	// the pod to the claim's ReservedFor list does not conflict with
+	// concurrent changes, everything else still does.
+	PatchWriteStrategy WriteStrategyType = "Patch"
+)
+
+// DeviceSelectionPolicyType defines which device the DynamicResources plugin
+// picks when several devices could satisfy a request.
+type DeviceSelectionPolicyType string
+
+const (
+	// FirstFitDeviceSelectionPolicy picks the first suitable device.
+	FirstFitDeviceSelectionPolicy DeviceSelectionPolicyType = "FirstFit"
+	// BestFitDeviceSelectionPolicy picks the device which is left with
+	// the least unused capacity.
+	BestFitDeviceSelectionPolicy DeviceSelectionPolicyType = "BestFit"
+)
+
+// MissingAttributeBehaviorType defines how the DynamicResources plugin handles
+// CEL selectors which look up an attribute that a device doesn't have.
+type MissingAttributeBehaviorType string
+
+const (
+	// ErrorMissingAttributeBehavior treats the lookup as an error which
+	// keeps the pod pending.
+	ErrorMissingAttributeBehavior MissingAttributeBehaviorType = "Error"
+	// ExcludeMissingAttributeBehavior treats the device as not matching
+	// the selector.
+	ExcludeMissingAttributeBehavior MissingAttributeBehaviorType = "Exclude"
+)
@@ -23,6 +23,7 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -61,6 +62,87 @@ func (in *DefaultPreemptionArgs) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceQuota) DeepCopyInto(out *DeviceQuota) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceQuota.
+func (in *DeviceQuota) DeepCopy() *DeviceQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DynamicResourcesArgs) DeepCopyInto(out *DynamicResourcesArgs) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	if in.DeviceQuotas != nil {
+		in, out := &in.DeviceQuotas, &out.DeviceQuotas
+		*out = make([]DeviceQuota, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailedAttemptsBeforeEscalation != nil {
+		in, out := &in.FailedAttemptsBeforeEscalation, &out.FailedAttemptsBeforeEscalation
+		*out = new(int32)
+		**out = **in
+	}
+	if in.EnablePostFilterDeallocation != nil {
+		in, out := &in.EnablePostFilterDeallocation, &out.EnablePostFilterDeallocation
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PreferReservedClaims != nil {
+		in, out := &in.PreferReservedClaims, &out.PreferReservedClaims
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowedCELFunctionGroups != nil {
+		in, out := &in.AllowedCELFunctionGroups, &out.AllowedCELFunctionGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynamicResourcesArgs.
+func (in *DynamicResourcesArgs) DeepCopy() *DynamicResourcesArgs {
+	if in == nil {
+		return nil
+	}
+	out := new(DynamicResourcesArgs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DynamicResourcesArgs) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Extender) DeepCopyInto(out *Extender) {
 	*out = *in